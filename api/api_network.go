@@ -5,7 +5,18 @@
 
 package api
 
-import "github.com/elcn233/go-scdo/p2p"
+import (
+	"errors"
+	"time"
+
+	"github.com/elcn233/go-scdo/common"
+	"github.com/elcn233/go-scdo/p2p"
+	"github.com/elcn233/go-scdo/p2p/discovery"
+)
+
+const defaultBanReason = "banned by admin"
+
+var errEmptyNodeID = errors.New("node id is empty")
 
 // PrivateNetworkAPI provides an API to access network information.
 type PrivateNetworkAPI struct {
@@ -68,3 +79,83 @@ func (n *PrivateNetworkAPI) GetBlockListCount() int {
 	UDPB := n.s.GetP2pServer().GetUDP()
 	return UDPB.GetBlockListCount()
 }
+
+// BanPeer bans the node for the given minutes and disconnects it if connected
+func (n *PrivateNetworkAPI) BanPeer(id common.Address, minutes uint64, reason string) (bool, error) {
+	if id.IsEmpty() {
+		return false, errEmptyNodeID
+	}
+
+	if minutes == 0 {
+		return false, errors.New("ban duration must be greater than 0")
+	}
+
+	if len(reason) == 0 {
+		reason = defaultBanReason
+	}
+
+	n.s.GetP2pServer().BanPeer(id, time.Duration(minutes)*time.Minute, reason)
+	return true, nil
+}
+
+// UnbanPeer lifts the ban of the node, returns false if the node is not banned
+func (n *PrivateNetworkAPI) UnbanPeer(id common.Address) (bool, error) {
+	if id.IsEmpty() {
+		return false, errEmptyNodeID
+	}
+
+	return n.s.GetP2pServer().UnbanPeer(id), nil
+}
+
+// ListBans returns the banned nodes
+func (n *PrivateNetworkAPI) ListBans() []p2p.BanInfo {
+	return n.s.GetP2pServer().Bans()
+}
+
+// AddPeer adds the node as a trusted peer and connects to it, the node is in
+// the format snode://<node id>@<ip>:<port>[<shard>]
+func (n *PrivateNetworkAPI) AddPeer(node string) (bool, error) {
+	peer, err := discovery.NewNodeFromString(node)
+	if err != nil {
+		return false, err
+	}
+
+	if err = n.s.GetP2pServer().AddPeer(peer); err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
+
+// RemovePeer removes the node from the trusted peers and disconnects it if connected
+func (n *PrivateNetworkAPI) RemovePeer(id common.Address) (bool, error) {
+	if id.IsEmpty() {
+		return false, errEmptyNodeID
+	}
+
+	if err := n.s.GetP2pServer().RemovePeer(id); err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
+
+// SetMaxPeers sets the max number of peers
+func (n *PrivateNetworkAPI) SetMaxPeers(maxPeers int) (bool, error) {
+	if err := n.s.GetP2pServer().SetMaxPeers(maxPeers); err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
+
+// ListStaticNodes returns the static nodes and the trusted peers
+func (n *PrivateNetworkAPI) ListStaticNodes() []string {
+	nodes := n.s.GetP2pServer().StaticNodes()
+	result := make([]string, 0, len(nodes))
+	for _, node := range nodes {
+		result = append(result, node.String())
+	}
+
+	return result
+}
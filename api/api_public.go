@@ -14,8 +14,10 @@ import (
 	"github.com/elcn233/go-scdo/common"
 	"github.com/elcn233/go-scdo/common/errors"
 	"github.com/elcn233/go-scdo/common/hexutil"
+	"github.com/elcn233/go-scdo/contract/system"
 	"github.com/elcn233/go-scdo/core/state"
 	"github.com/elcn233/go-scdo/core/types"
+	"github.com/elcn233/go-scdo/trie"
 )
 
 // ErrInvalidAccount the account is invalid
@@ -68,6 +70,21 @@ func (api *PublicScdoAPI) GetBalance(account common.Address, hexHash string, hei
 
 // getStatedb gets the statedb of a block given the block hash or block height
 func (api *PublicScdoAPI) getStatedb(hexHash string, height int64) (*state.Statedb, error) {
+	if len(hexHash) == 0 && height < 0 {
+		return api.s.ChainBackend().GetCurrentState()
+	}
+
+	header, err := api.getBlockHeader(hexHash, height)
+	if err != nil {
+		return nil, err
+	}
+
+	return api.s.ChainBackend().GetState(header.StateHash)
+}
+
+// getBlockHeader gets the block header given the block hash or block height,
+// returns the current block header if hash is empty and height is negative.
+func (api *PublicScdoAPI) getBlockHeader(hexHash string, height int64) (*types.BlockHeader, error) {
 	var blockHash common.Hash
 	var err error
 
@@ -76,7 +93,7 @@ func (api *PublicScdoAPI) getStatedb(hexHash string, height int64) (*state.State
 			return nil, errors.NewStackedError(err, "failed to convert HEX to hash")
 		}
 	} else if height < 0 {
-		return api.s.ChainBackend().GetCurrentState()
+		return api.s.ChainBackend().CurrentHeader(), nil
 	} else if blockHash, err = api.s.ChainBackend().GetStore().GetBlockHash(uint64(height)); err != nil {
 		return nil, errors.NewStackedErrorf(err, "failed to get block hash by height %v", height)
 	}
@@ -86,7 +103,50 @@ func (api *PublicScdoAPI) getStatedb(hexHash string, height int64) (*state.State
 		return nil, errors.NewStackedErrorf(err, "failed to get block header by hash %v", blockHash)
 	}
 
-	return api.s.ChainBackend().GetState(header.StateHash)
+	return header, nil
+}
+
+// GetAccountProof gets the merkle proof of account in the state trie of a block given
+// the block hash or block height, which is used to prove the account balance to others,
+// e.g. withdraw the funds exited from sub-chain on the main chain.
+func (api *PublicScdoAPI) GetAccountProof(account common.Address, hexHash string, height int64) (map[string]interface{}, error) {
+	if account.IsEmpty() {
+		return nil, ErrInvalidAccount
+	}
+
+	header, err := api.getBlockHeader(hexHash, height)
+	if err != nil {
+		return nil, err
+	}
+
+	statedb, err := api.s.ChainBackend().GetState(header.StateHash)
+	if err != nil {
+		return nil, errors.NewStackedError(err, "failed to get statedb")
+	}
+
+	proof, err := statedb.GetAccountProof(account)
+	if err != nil {
+		return nil, errors.NewStackedError(err, "failed to get account proof")
+	}
+
+	output := map[string]interface{}{
+		"Account":   account.Hex(),
+		"BlockHash": header.Hash().Hex(),
+		"Height":    header.Height,
+		"StateRoot": header.StateHash.Hex(),
+		"Proof":     trie.ProofToNodes(proof),
+	}
+	return output, nil
+}
+
+// GetSystemContracts gets the schema of system contracts activated at the block height,
+// or the current block height for negative value, which is used by clients to generate calls.
+func (api *PublicScdoAPI) GetSystemContracts(height int64) ([]*system.NativeContractSchema, error) {
+	if height < 0 {
+		return system.GetNativeContractSchemas(api.s.ChainBackend().CurrentHeader().Height), nil
+	}
+
+	return system.GetNativeContractSchemas(uint64(height)), nil
 }
 
 // GetChangedAccounts gets the updated accounts of a certain block given the block hash or block height
@@ -6,18 +6,29 @@
 package cmd
 
 import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/elcn233/go-scdo/common"
+	"github.com/elcn233/go-scdo/common/hexutil"
 	"github.com/elcn233/go-scdo/contract/system"
 	"github.com/elcn233/go-scdo/rpc"
 )
 
+// domainNameSuffix is the suffix of the domain name accepted as an address
+const domainNameSuffix = ".scdo"
+
 // createDomainName create a domain name
 func createDomainName(client *rpc.Client) (interface{}, interface{}, error) {
 	amountValue = "0"
-	if err := system.ValidateDomainName([]byte(nameValue)); err != nil {
+	name := trimDomainName(nameValue)
+	if err := system.ValidateDomainName([]byte(name)); err != nil {
 		return nil, nil, err
 	}
 
-	tx, err := sendSystemContractTx(client, system.DomainNameContractAddress, system.CmdCreateDomainName, []byte(nameValue))
+	tx, err := sendSystemContractTx(client, system.DomainNameContractAddress, system.CmdCreateDomainName, []byte(name))
 	if err != nil {
 		return nil, nil, err
 	}
@@ -27,16 +38,177 @@ func createDomainName(client *rpc.Client) (interface{}, interface{}, error) {
 
 // getDomainNameOwner get domain name owner
 func getDomainNameOwner(client *rpc.Client) (interface{}, interface{}, error) {
+	return sendDomainNameTx(client, system.CmdGetDomainNameOwner)
+}
+
+// renewDomainName extend the expiry of the domain name
+func renewDomainName(client *rpc.Client) (interface{}, interface{}, error) {
+	return sendDomainNameTx(client, system.CmdRenewDomainName)
+}
+
+// setReverseName set the domain name of the sender for the reverse lookup
+func setReverseName(client *rpc.Client) (interface{}, interface{}, error) {
+	return sendDomainNameTx(client, system.CmdSetReverseName)
+}
+
+// getDomainRecord get the owner, the expiry and the records of the domain name
+func getDomainRecord(client *rpc.Client) (interface{}, interface{}, error) {
+	priceValue = "1"
+	return sendDomainNameTx(client, system.CmdGetDomainRecord)
+}
+
+// transferDomainName transfer the domain name to the new owner
+func transferDomainName(client *rpc.Client) (interface{}, interface{}, error) {
+	owner, err := resolveAddress(client, toValue)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return sendDomainNameRequest(client, system.CmdTransferDomainName, &system.DomainTransferRequest{
+		Name:  trimDomainName(nameValue),
+		Owner: owner,
+	})
+}
+
+// setDomainAddress set the address of the domain name in the shard of the address
+func setDomainAddress(client *rpc.Client) (interface{}, interface{}, error) {
+	addr, err := resolveAddress(client, accountValue)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return sendDomainNameRequest(client, system.CmdSetDomainAddress, &system.DomainAddressRequest{
+		Name:    trimDomainName(nameValue),
+		Address: addr,
+		Shard:   domainShardValue,
+	})
+}
+
+// setDomainContentHash set the content hash of the domain name
+func setDomainContentHash(client *rpc.Client) (interface{}, interface{}, error) {
+	var contentHash []byte
+	if len(hashValue) > 0 {
+		var err error
+		if contentHash, err = hexutil.HexToBytes(hashValue); err != nil {
+			return nil, nil, fmt.Errorf("invalid content hash, %s", err)
+		}
+	}
+
+	return sendDomainNameRequest(client, system.CmdSetDomainContentHash, &system.DomainContentHashRequest{
+		Name:        trimDomainName(nameValue),
+		ContentHash: contentHash,
+	})
+}
+
+// setDomainText set the text record of the domain name
+func setDomainText(client *rpc.Client) (interface{}, interface{}, error) {
+	return sendDomainNameRequest(client, system.CmdSetDomainText, &system.DomainTextRequest{
+		Name:  trimDomainName(nameValue),
+		Key:   textKeyValue,
+		Value: textValue,
+	})
+}
+
+// resolveDomainName resolve the domain name to the address of the shard
+func resolveDomainName(client *rpc.Client) (interface{}, interface{}, error) {
+	priceValue = "1"
+	return sendDomainNameRequest(client, system.CmdResolveDomainName, &system.DomainResolveRequest{
+		Name:  trimDomainName(nameValue),
+		Shard: domainShardValue,
+	})
+}
+
+// getDomainName reverse lookup the domain name of the account
+func getDomainName(client *rpc.Client) (interface{}, interface{}, error) {
 	amountValue = "0"
+	priceValue = "1"
+	addr, err := common.HexToAddress(accountValue)
+	if err != nil {
+		return nil, nil, err
+	}
 
-	if err := system.ValidateDomainName([]byte(nameValue)); err != nil {
+	tx, err := sendSystemContractTx(client, system.DomainNameContractAddress, system.CmdGetDomainName, addr.Bytes())
+	if err != nil {
 		return nil, nil, err
 	}
 
-	tx, err := sendSystemContractTx(client, system.DomainNameContractAddress, system.CmdGetDomainNameOwner, []byte(nameValue))
+	return tx, tx, err
+}
+
+func sendDomainNameTx(client *rpc.Client, method byte) (interface{}, interface{}, error) {
+	amountValue = "0"
+	name := trimDomainName(nameValue)
+	if err := system.ValidateDomainName([]byte(name)); err != nil {
+		return nil, nil, err
+	}
+
+	tx, err := sendSystemContractTx(client, system.DomainNameContractAddress, method, []byte(name))
 	if err != nil {
 		return nil, nil, err
 	}
 
 	return tx, tx, err
 }
+
+func sendDomainNameRequest(client *rpc.Client, method byte, request interface{}) (interface{}, interface{}, error) {
+	amountValue = "0"
+	payload, err := json.Marshal(request)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	tx, err := sendSystemContractTx(client, system.DomainNameContractAddress, method, payload)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return tx, tx, err
+}
+
+// trimDomainName removes the domain name suffix if any
+func trimDomainName(name string) string {
+	if strings.HasSuffix(strings.ToLower(name), domainNameSuffix) {
+		return name[:len(name)-len(domainNameSuffix)]
+	}
+
+	return name
+}
+
+// resolveAddress converts the hex address, or resolves the domain name with the
+// suffix to the address of its lowest shard by the domain name contract.
+func resolveAddress(client *rpc.Client, value string) (common.Address, error) {
+	if !strings.HasSuffix(strings.ToLower(value), domainNameSuffix) {
+		return common.HexToAddress(value)
+	}
+
+	if client == nil {
+		return common.EmptyAddress, fmt.Errorf("failed to resolve %s, rpc client is required", value)
+	}
+
+	request, err := json.Marshal(&system.DomainResolveRequest{Name: trimDomainName(value)})
+	if err != nil {
+		return common.EmptyAddress, err
+	}
+
+	payload := append([]byte{system.CmdResolveDomainName}, request...)
+	var receipt map[string]interface{}
+	if err = client.Call(&receipt, "scdo_call", system.DomainNameContractAddress.Hex(), hexutil.BytesToHex(payload), -1); err != nil {
+		return common.EmptyAddress, fmt.Errorf("failed to resolve %s, %s", value, err)
+	}
+
+	result, _ := receipt["result"].(string)
+	if failed, _ := receipt["failed"].(bool); failed {
+		return common.EmptyAddress, fmt.Errorf("failed to resolve %s, %s", value, result)
+	}
+
+	addr, err := hexutil.HexToBytes(result)
+	if err != nil {
+		return common.EmptyAddress, fmt.Errorf("failed to resolve %s, %s", value, err)
+	}
+
+	if len(addr) != len(common.EmptyAddress) {
+		return common.EmptyAddress, errors.New("invalid resolved address")
+	}
+
+	return common.BytesToAddress(addr), nil
+}
@@ -9,20 +9,22 @@ import (
 	"fmt"
 
 	"github.com/elcn233/go-scdo/common"
+	"github.com/elcn233/go-scdo/common/keystore"
+	"github.com/elcn233/go-scdo/rpc"
 	"github.com/urfave/cli"
 )
 
 type rpcFlag interface {
-	getValue() (interface{}, error)
+	getValue(client *rpc.Client) (interface{}, error)
 }
 
 type scdoAddressFlag struct {
 	cli.StringFlag
 }
 
-func (flag scdoAddressFlag) getValue() (interface{}, error) {
+func (flag scdoAddressFlag) getValue(client *rpc.Client) (interface{}, error) {
 	if val := *flag.Destination; len(val) > 0 {
-		return common.HexToAddress(val)
+		return resolveAddress(client, val)
 	}
 
 	return common.EmptyAddress, nil
@@ -32,7 +34,7 @@ type abiFlag struct {
 	cli.StringFlag
 }
 
-func (flag abiFlag) getValue() (interface{}, error) {
+func (flag abiFlag) getValue(client *rpc.Client) (interface{}, error) {
 	if val := *flag.Destination; len(val) > 0 {
 		abiJSON, err := readABIFile(val)
 		if err != nil {
@@ -65,7 +67,7 @@ var (
 		StringFlag: cli.StringFlag{
 			Name:        "account",
 			Value:       "",
-			Usage:       "account address",
+			Usage:       "account address or domain name, e.g. name.scdo",
 			Destination: &accountValue,
 		},
 	}
@@ -118,7 +120,7 @@ var (
 	toValue string
 	toFlag  = cli.StringFlag{
 		Name:        "to",
-		Usage:       "to address",
+		Usage:       "to address or domain name, e.g. name.scdo",
 		Destination: &toValue,
 	}
 
@@ -250,6 +252,43 @@ var (
 		Destination: &timeLockValue,
 	}
 
+	lockHeightValue uint64
+	lockHeightFlag  = cli.Uint64Flag{
+		Name:        "lockheight",
+		Usage:       "block height lock in the HTLC, the time lock is ignored if not zero",
+		Destination: &lockHeightValue,
+	}
+
+	htlcFileValue string
+	htlcFileFlag  = cli.StringFlag{
+		Name:        "file",
+		Usage:       "json file of the HTLCs, e.g. [{\"HashLock\":\"0x..\",\"TimeLock\":0,\"LockHeight\":0,\"To\":\"0x..\",\"Amount\":100}]",
+		Destination: &htlcFileValue,
+	}
+
+	receiverValue bool
+	receiverFlag  = cli.BoolFlag{
+		Name:        "receiver",
+		Usage:       "list the HTLCs locked to the account instead of created by the account",
+		Destination: &receiverValue,
+	}
+
+	offsetValue uint64
+	offsetFlag  = cli.Uint64Flag{
+		Name:        "offset",
+		Value:       0,
+		Usage:       "offset of the list",
+		Destination: &offsetValue,
+	}
+
+	countValue uint64
+	countFlag  = cli.Uint64Flag{
+		Name:        "count",
+		Value:       100,
+		Usage:       "max number of the list",
+		Destination: &countValue,
+	}
+
 	preimageValue string
 	preimageFlag  = cli.StringFlag{
 		Name:        "preimage",
@@ -285,6 +324,49 @@ var (
 		Value: &staticNodesValue,
 	}
 
+	validatorsValue cli.StringSlice
+	validatorsFlag  = cli.StringSliceFlag{
+		Name:  "validator",
+		Usage: "subchain validator account to sign the anchored state root, for example:--validator account1 --validator account2",
+		Value: &validatorsValue,
+	}
+
+	nativeContractValue string
+	nativeContractFlag  = cli.StringFlag{
+		Name:        "contract",
+		Usage:       "system contract name, e.g. domain",
+		Destination: &nativeContractValue,
+	}
+
+	nativeCommandValue string
+	nativeCommandFlag  = cli.StringFlag{
+		Name:        "command",
+		Usage:       "system contract command name, e.g. create",
+		Destination: &nativeCommandValue,
+	}
+
+	nativeInputValue string
+	nativeInputFlag  = cli.StringFlag{
+		Name:        "input",
+		Usage:       "command input encoded according to the input type of command schema, e.g. string, address, hash, json or bytes in hex",
+		Destination: &nativeInputValue,
+	}
+
+	subChainAddressValue string
+	subChainAddressFlag  = cli.StringFlag{
+		Name:        "subchain",
+		Value:       "127.0.0.1:8027",
+		Usage:       "address of the sub chain node to request",
+		Destination: &subChainAddressValue,
+	}
+
+	versionValue string
+	versionFlag  = cli.StringFlag{
+		Name:        "version",
+		Usage:       "subchain version",
+		Destination: &versionValue,
+	}
+
 	algorithmValue string
 	algorithmFlag  = cli.StringFlag{
 		Name:        "algorithm",
@@ -292,6 +374,107 @@ var (
 		Value:       "sha256",
 		Destination: &algorithmValue,
 	}
+
+	nodeIDValue string
+	nodeIDFlag  = scdoAddressFlag{
+		StringFlag: cli.StringFlag{
+			Name:        "id",
+			Value:       "",
+			Usage:       "node id",
+			Destination: &nodeIDValue,
+		},
+	}
+
+	banMinutesValue uint64
+	banMinutesFlag  = cli.Uint64Flag{
+		Name:        "minutes",
+		Value:       60,
+		Usage:       "ban duration in minutes",
+		Destination: &banMinutesValue,
+	}
+
+	banReasonValue string
+	banReasonFlag  = cli.StringFlag{
+		Name:        "reason",
+		Value:       "",
+		Usage:       "ban reason",
+		Destination: &banReasonValue,
+	}
+
+	peerNodeValue string
+	peerNodeFlag  = cli.StringFlag{
+		Name:        "node",
+		Value:       "",
+		Usage:       "node to add, e.g. snode://<node id>@<ip>:<port>[<shard>]",
+		Destination: &peerNodeValue,
+	}
+
+	maxPeersValue int
+	maxPeersFlag  = cli.IntFlag{
+		Name:        "max",
+		Value:       0,
+		Usage:       "max number of peers",
+		Destination: &maxPeersValue,
+	}
+
+	domainShardValue uint
+	domainShardFlag  = cli.UintFlag{
+		Name:        "addrshard",
+		Value:       0,
+		Usage:       "shard of the domain name address, 0 for the lowest shard",
+		Destination: &domainShardValue,
+	}
+
+	textKeyValue string
+	textKeyFlag  = cli.StringFlag{
+		Name:        "key",
+		Usage:       "key of the domain name text record, e.g. email",
+		Destination: &textKeyValue,
+	}
+
+	textValue     string
+	textValueFlag = cli.StringFlag{
+		Name:        "value",
+		Usage:       "value of the domain name text record, empty to remove it",
+		Destination: &textValue,
+	}
+
+	evidenceFileValue string
+	evidenceFileFlag  = cli.StringFlag{
+		Name:        "file",
+		Usage:       "json file of the double signing evidence, e.g. from client istanbul evidences",
+		Destination: &evidenceFileValue,
+	}
+
+	walletFileValue string
+	walletFileFlag  = cli.StringFlag{
+		Name:        "file",
+		Usage:       "HD wallet file name",
+		Destination: &walletFileValue,
+	}
+
+	mnemonicValue string
+	mnemonicFlag  = cli.StringFlag{
+		Name:        "mnemonic",
+		Usage:       "BIP-39 mnemonic words of the HD wallet, separated by space",
+		Destination: &mnemonicValue,
+	}
+
+	mnemonicWordsValue int
+	mnemonicWordsFlag  = cli.IntFlag{
+		Name:        "words",
+		Value:       keystore.MnemonicWords12,
+		Usage:       "number of the mnemonic words, 12, 15, 18, 21 or 24",
+		Destination: &mnemonicWordsValue,
+	}
+
+	mnemonicPassphraseValue string
+	mnemonicPassphraseFlag  = cli.StringFlag{
+		Name:        "passphrase",
+		Value:       "",
+		Usage:       "optional BIP-39 passphrase of the mnemonic",
+		Destination: &mnemonicPassphraseValue,
+	}
 )
 
 // GeneratePayload
@@ -0,0 +1,127 @@
+/**
+*  @file
+*  @copyright defined in scdo/LICENSE
+ */
+
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/elcn233/go-scdo/common"
+	"github.com/elcn233/go-scdo/common/hexutil"
+	"github.com/elcn233/go-scdo/common/keystore"
+	"github.com/elcn233/go-scdo/crypto"
+	"github.com/urfave/cli"
+)
+
+// CreateHDWalletAction creates a HD wallet with a new mnemonic and stores it to the file
+func CreateHDWalletAction(c *cli.Context) error {
+	mnemonic, err := keystore.NewMnemonic(mnemonicWordsValue)
+	if err != nil {
+		return err
+	}
+
+	if err = storeNewHDWallet(mnemonic); err != nil {
+		return err
+	}
+
+	fmt.Printf("Mnemonic: %s\n", mnemonic)
+	fmt.Println("Please write down the mnemonic, it is the only way to restore the wallet")
+	return nil
+}
+
+// RestoreHDWalletAction restores the HD wallet from the mnemonic and stores it to the file
+func RestoreHDWalletAction(c *cli.Context) error {
+	if mnemonicValue == "" {
+		return fmt.Errorf("please specify the mnemonic")
+	}
+
+	return storeNewHDWallet(mnemonicValue)
+}
+
+func storeNewHDWallet(mnemonic string) error {
+	if walletFileValue == "" {
+		return fmt.Errorf("please specify the wallet file path")
+	}
+
+	if common.FileOrFolderExists(walletFileValue) {
+		return fmt.Errorf("the wallet file %s already exists", walletFileValue)
+	}
+
+	w, err := keystore.NewHDWalletFromMnemonic(mnemonic, mnemonicPassphraseValue)
+	if err != nil {
+		return fmt.Errorf("invalid mnemonic: %s", err)
+	}
+
+	pass, err := common.SetPassword()
+	if err != nil {
+		return fmt.Errorf("get password err %s", err)
+	}
+
+	if err = keystore.StoreHDWallet(walletFileValue, pass, w); err != nil {
+		return fmt.Errorf("failed to store the wallet file %s, %s", walletFileValue, err)
+	}
+
+	fmt.Printf("store wallet successfully, the wallet file path is %s\n", walletFileValue)
+	return nil
+}
+
+// NewHDAccountAction derives the next account of the shard from the HD wallet
+func NewHDAccountAction(c *cli.Context) error {
+	w, pass, err := loadHDWallet()
+	if err != nil {
+		return err
+	}
+
+	key, account, err := w.NextKey(shardValue)
+	if err != nil {
+		return err
+	}
+
+	if err = keystore.StoreHDWallet(walletFileValue, pass, w); err != nil {
+		return fmt.Errorf("failed to update the wallet file %s, %s", walletFileValue, err)
+	}
+
+	fmt.Printf("Account:  %s\n", account.Address.Hex())
+	fmt.Printf("Path: %s\n", account.Path)
+	fmt.Printf("Private key: %s\n", hexutil.BytesToHex(crypto.FromECDSA(key.PrivateKey)))
+	return nil
+}
+
+// ListHDAccountsAction lists the accounts derived from the HD wallet
+func ListHDAccountsAction(c *cli.Context) error {
+	w, _, err := loadHDWallet()
+	if err != nil {
+		return err
+	}
+
+	accounts, err := w.ListAccounts()
+	if err != nil {
+		return err
+	}
+
+	for _, account := range accounts {
+		fmt.Printf("shard: %d, path: %s, account: %s\n", account.Shard, account.Path, account.Address.Hex())
+	}
+
+	return nil
+}
+
+func loadHDWallet() (*keystore.HDWallet, string, error) {
+	if walletFileValue == "" {
+		return nil, "", fmt.Errorf("please specify the wallet file path")
+	}
+
+	pass, err := common.GetPassword()
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to get password %s", err)
+	}
+
+	w, err := keystore.GetHDWallet(walletFileValue, pass)
+	if err != nil {
+		return nil, "", fmt.Errorf("invalid wallet file: %s", err)
+	}
+
+	return w, pass, nil
+}
@@ -9,6 +9,8 @@ import (
 	"crypto/rand"
 	"encoding/json"
 	"fmt"
+	"io/ioutil"
+	"math/big"
 	"time"
 
 	"github.com/elcn233/go-scdo/common"
@@ -28,8 +30,9 @@ func createHTLC(client *rpc.Client) (interface{}, interface{}, error) {
 	var data system.HashTimeLock
 	data.HashLock = hashLockBytes
 	data.TimeLock = timeLockValue
+	data.LockHeight = lockHeightValue
 	fmt.Println("s.createHTLC:", toValue)
-	toAddr, err := common.HexToAddress(toValue)
+	toAddr, err := resolveAddress(client, toValue)
 	if err != nil {
 		return nil, nil, err
 	}
@@ -49,6 +52,44 @@ func createHTLC(client *rpc.Client) (interface{}, interface{}, error) {
 	output["Tx"] = *tx
 	output["HashLock"] = hashValue
 	output["TimeLock"] = timeLockValue
+	output["LockHeight"] = lockHeightValue
+	return output, tx, err
+}
+
+// createHTLCs create HTLCs in a batch from the json file, the tx amount is the sum of the HTLC amounts
+func createHTLCs(client *rpc.Client) (interface{}, interface{}, error) {
+	content, err := ioutil.ReadFile(htlcFileValue)
+	if err != nil {
+		return nil, nil, fmt.Errorf("Failed to read the HTLC file, %s", err)
+	}
+
+	var locks []system.HashTimeLock
+	if err = json.Unmarshal(content, &locks); err != nil {
+		return nil, nil, fmt.Errorf("Failed to unmarshal the HTLC file, %s", err)
+	}
+
+	amount := big.NewInt(0)
+	for _, lock := range locks {
+		if lock.Amount == nil {
+			return nil, nil, fmt.Errorf("amount of the HTLC to %s is required", lock.To.Hex())
+		}
+		amount.Add(amount, lock.Amount)
+	}
+
+	dataBytes, err := json.Marshal(locks)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	amountValue = amount.String()
+	tx, err := sendSystemContractTx(client, system.HashTimeLockContractAddress, system.CmdNewContracts, dataBytes)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	output := make(map[string]interface{})
+	output["Tx"] = *tx
+	output["HTLCs"] = locks
 	return output, tx, err
 }
 
@@ -124,6 +165,39 @@ func getHTLC(client *rpc.Client) (interface{}, interface{}, error) {
 	return output, tx, err
 }
 
+// listHTLCs get the ids of the HTLCs created by the account, or locked to the account
+func listHTLCs(client *rpc.Client) (interface{}, interface{}, error) {
+	amountValue = "0"
+	priceValue = "1"
+	addr, err := resolveAddress(client, accountValue)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	dataBytes, err := json.Marshal(&system.HTLCListRequest{Address: addr, Offset: offsetValue, Count: countValue})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	method := system.CmdGetContractsBySender
+	if receiverValue {
+		method = system.CmdGetContractsByReceiver
+	}
+
+	tx, err := sendSystemContractTx(client, system.HashTimeLockContractAddress, method, dataBytes)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return tx, tx, err
+}
+
+// printHTLCEventsABI print the abi of the HTLC logs, which could be saved to filter the logs
+func printHTLCEventsABI(c *cli.Context) error {
+	fmt.Println(system.HTLCEventsABI)
+	return nil
+}
+
 // generateHTLCKey generate HTLC preimage and preimage hash
 func generateHTLCKey(c *cli.Context) error {
 	secret := make([]byte, 32)
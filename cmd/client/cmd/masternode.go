@@ -0,0 +1,116 @@
+/**
+*  @file
+*  @copyright defined in scdo/LICENSE
+ */
+
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+
+	"github.com/elcn233/go-scdo/cmd/util"
+	"github.com/elcn233/go-scdo/contract/system"
+	"github.com/elcn233/go-scdo/core/types"
+	"github.com/elcn233/go-scdo/rpc"
+)
+
+// depositMasternode deposit scdo and register as masternode, the amount is the deposit if not specified
+func depositMasternode(client *rpc.Client) (interface{}, interface{}, error) {
+	if amountValue == "" {
+		amountValue = system.MasternodeDeposit().String()
+	}
+
+	tx, err := sendSystemContractTx(client, system.MasternodeContractAddress, system.CmdDeposit, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return tx, tx, err
+}
+
+// quitMasternode quit the masternode of the sender, the deposit can be recalled later
+func quitMasternode(client *rpc.Client) (interface{}, interface{}, error) {
+	return sendMasternodeOwnerTx(client, system.CmdQuit)
+}
+
+// recallMasternode recall the deposit and reward of the quit masternode
+func recallMasternode(client *rpc.Client) (interface{}, interface{}, error) {
+	return sendMasternodeOwnerTx(client, system.CmdRecall)
+}
+
+// sendMasternodeOwnerTx send the masternode tx with the sender address as the payload
+func sendMasternodeOwnerTx(client *rpc.Client, method byte) (interface{}, interface{}, error) {
+	amountValue = "0"
+	key, txd, err := makeTransactionData(client)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	txd.To = system.MasternodeContractAddress
+	txd.Payload = append([]byte{method}, txd.From.Bytes()...)
+
+	tx, err := util.GenerateTx(key.PrivateKey, &txd.From, txd.To, txd.Amount, txd.GasPrice, txd.GasLimit, txd.AccountNonce, txd.Payload)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return tx, tx, err
+}
+
+// slashMasternode slash the masternode with the evidence of double signing in the file
+func slashMasternode(client *rpc.Client) (interface{}, interface{}, error) {
+	amountValue = "0"
+	content, err := ioutil.ReadFile(evidenceFileValue)
+	if err != nil {
+		return nil, nil, fmt.Errorf("Failed to read the evidence file, %s", err)
+	}
+
+	var evidence types.IstanbulEvidence
+	if err = json.Unmarshal(content, &evidence); err != nil {
+		return nil, nil, fmt.Errorf("Failed to unmarshal the evidence file, %s", err)
+	}
+
+	dataBytes, err := json.Marshal(&evidence)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	tx, err := sendSystemContractTx(client, system.MasternodeContractAddress, system.CmdSlash, dataBytes)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return tx, tx, err
+}
+
+// getMasternode get the status of the masternode
+func getMasternode(client *rpc.Client) (interface{}, interface{}, error) {
+	amountValue = "0"
+	priceValue = "1"
+	masternode, err := resolveAddress(client, accountValue)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	tx, err := sendSystemContractTx(client, system.MasternodeContractAddress, system.CmdGetMasternode, masternode.Bytes())
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return tx, tx, err
+}
+
+// getMasternodes get the status of all the masternodes
+func getMasternodes(client *rpc.Client) (interface{}, interface{}, error) {
+	amountValue = "0"
+	priceValue = "1"
+
+	tx, err := sendSystemContractTx(client, system.MasternodeContractAddress, system.CmdGetMasternodes, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return tx, tx, err
+}
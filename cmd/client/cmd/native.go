@@ -0,0 +1,144 @@
+/**
+*  @file
+*  @copyright defined in scdo/LICENSE
+ */
+
+package cmd
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/elcn233/go-scdo/common/hexutil"
+	"github.com/elcn233/go-scdo/contract/system"
+	"github.com/elcn233/go-scdo/rpc"
+	"github.com/urfave/cli"
+)
+
+// callNativeContract generates the system contract call from the schema of the node,
+// the read-only command is called and the others are sent in transaction.
+func callNativeContract(c *cli.Context) error {
+	client, err := rpc.DialTCP(context.Background(), addressValue)
+	if err != nil {
+		return err
+	}
+
+	var schemas []*system.NativeContractSchema
+	if err = client.Call(&schemas, "scdo_getSystemContracts", -1); err != nil {
+		return err
+	}
+
+	schema, command, err := findNativeCommand(schemas, nativeContractValue, nativeCommandValue)
+	if err != nil {
+		return err
+	}
+
+	input, err := system.EncodeCommandInput(command.Input, nativeInputValue)
+	if err != nil {
+		return err
+	}
+
+	if command.ReadOnly {
+		amountValue = "0"
+		priceValue = "1"
+	}
+
+	tx, err := sendSystemContractTx(client, schema.Address, command.ID, input)
+	if err != nil {
+		return err
+	}
+
+	if !command.ReadOnly {
+		if err = sendTx(client, tx); err != nil {
+			return err
+		}
+
+		return handleCallResult(nil, tx)
+	}
+
+	receipt, err := callTx(client, tx)
+	if err != nil {
+		return err
+	}
+
+	result, err := decodeNativeResult(command, receipt)
+	if err != nil {
+		return err
+	}
+
+	return handleCallResult(nil, result)
+}
+
+// printNativeContractABI prints the Solidity ABI of the system contract, which
+// is callable at its address in EVM contracts with the ABI encoded input.
+func printNativeContractABI(c *cli.Context) error {
+	client, err := rpc.DialTCP(context.Background(), addressValue)
+	if err != nil {
+		return err
+	}
+
+	var schemas []*system.NativeContractSchema
+	if err = client.Call(&schemas, "scdo_getSystemContracts", -1); err != nil {
+		return err
+	}
+
+	for _, schema := range schemas {
+		if schema.Name != nativeContractValue {
+			continue
+		}
+
+		abiJSON, err := schema.ABI()
+		if err != nil {
+			return err
+		}
+
+		fmt.Printf("address: %s\n", schema.Address.Hex())
+		fmt.Println(abiJSON)
+		return nil
+	}
+
+	return fmt.Errorf("system contract %s not found", nativeContractValue)
+}
+
+// findNativeCommand find the contract and command schema by names
+func findNativeCommand(schemas []*system.NativeContractSchema, contract, command string) (*system.NativeContractSchema, *system.CommandSchema, error) {
+	for _, schema := range schemas {
+		if schema.Name != contract {
+			continue
+		}
+
+		for i := range schema.Commands {
+			if schema.Commands[i].Name == command {
+				return schema, &schema.Commands[i], nil
+			}
+		}
+
+		return nil, nil, fmt.Errorf("command %s not found in system contract %s", command, contract)
+	}
+
+	return nil, nil, fmt.Errorf("system contract %s not found", contract)
+}
+
+// decodeNativeResult decode the result in receipt according to the output type of command
+func decodeNativeResult(command *system.CommandSchema, receipt interface{}) (interface{}, error) {
+	mapReceipt, ok := receipt.(map[string]interface{})
+	if !ok {
+		return receipt, nil
+	}
+
+	result, _ := mapReceipt["result"].(string)
+	if failed, _ := mapReceipt["failed"].(bool); failed {
+		return nil, fmt.Errorf("failed to call %s, %s", command.Name, result)
+	}
+
+	if command.Output == system.TypeNone || len(result) == 0 {
+		return nil, nil
+	}
+
+	output, err := hexutil.HexToBytes(result)
+	if err != nil {
+		return nil, err
+	}
+
+	return system.DecodeCommandOutput(command.Output, output)
+}
@@ -35,7 +35,7 @@ func parseCallArgs(context *cli.Context, client *rpc.Client) ([]interface{}, err
 		}
 
 		if rf, ok := flag.(rpcFlag); ok {
-			v, err := rf.getValue()
+			v, err := rf.getValue(client)
 			if err != nil {
 				return nil, err
 			}
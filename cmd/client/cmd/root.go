@@ -200,6 +200,37 @@ func AddCommands(app *cli.App, isFullNode bool) {
 		},
 	}
 
+	hdWalletCommands := cli.Command{
+		Name:  "hdwallet",
+		Usage: "hierarchical deterministic wallet commands",
+		Subcommands: []cli.Command{
+			{
+				Name:   "create",
+				Usage:  "create a HD wallet with a new mnemonic",
+				Flags:  []cli.Flag{walletFileFlag, mnemonicWordsFlag, mnemonicPassphraseFlag},
+				Action: CreateHDWalletAction,
+			},
+			{
+				Name:   "restore",
+				Usage:  "restore a HD wallet from the mnemonic",
+				Flags:  []cli.Flag{walletFileFlag, mnemonicFlag, mnemonicPassphraseFlag},
+				Action: RestoreHDWalletAction,
+			},
+			{
+				Name:   "newaccount",
+				Usage:  "derive the next account of the shard",
+				Flags:  []cli.Flag{walletFileFlag, shardFlag},
+				Action: NewHDAccountAction,
+			},
+			{
+				Name:   "accounts",
+				Usage:  "list the derived accounts",
+				Flags:  []cli.Flag{walletFileFlag},
+				Action: ListHDAccountsAction,
+			},
+		},
+	}
+
 	htlcCommands := cli.Command{
 		Name:  "htlc",
 		Usage: "Hash time lock contract commands",
@@ -207,9 +238,15 @@ func AddCommands(app *cli.App, isFullNode bool) {
 			{
 				Name:   "create",
 				Usage:  "create HTLC",
-				Flags:  rpcFlags(fromFlag, toFlag, amountFlag, priceFlag, gasLimitFlag, nonceFlag, hashFlag, timeLockFlag),
+				Flags:  rpcFlags(fromFlag, toFlag, amountFlag, priceFlag, gasLimitFlag, nonceFlag, hashFlag, timeLockFlag, lockHeightFlag),
 				Action: rpcActionSystemContract("htlc", "create", handleCallResult),
 			},
+			{
+				Name:   "batch",
+				Usage:  "create HTLCs in a batch from the json file",
+				Flags:  rpcFlags(fromFlag, priceFlag, gasLimitFlag, nonceFlag, htlcFileFlag),
+				Action: rpcActionSystemContract("htlc", "batch", handleCallResult),
+			},
 			{
 				Name:   "withdraw",
 				Usage:  "withdraw from HTLC",
@@ -228,6 +265,17 @@ func AddCommands(app *cli.App, isFullNode bool) {
 				Flags:  rpcFlags(fromFlag, hashFlag),
 				Action: rpcActionSystemContract("htlc", "get", handleCallResult),
 			},
+			{
+				Name:   "list",
+				Usage:  "list the HTLC ids created by the account, or locked to the account with --receiver",
+				Flags:  rpcFlags(fromFlag, accountFlag, receiverFlag, offsetFlag, countFlag),
+				Action: rpcActionSystemContract("htlc", "list", handleCallResult),
+			},
+			{
+				Name:   "abi",
+				Usage:  "print the abi of the HTLC logs, e.g. to filter the logs by getlogs",
+				Action: printHTLCEventsABI,
+			},
 			{
 				Name:  "decode",
 				Usage: "decode HTLC contract information",
@@ -268,6 +316,60 @@ func AddCommands(app *cli.App, isFullNode bool) {
 				Flags:  rpcFlags(fromFlag, priceFlag, gasLimitFlag, nameFlag, nonceFlag),
 				Action: rpcActionSystemContract("domain", "getOwner", handleCallResult),
 			},
+			{
+				Name:   "transfer",
+				Usage:  "transfer the domain name to a new owner",
+				Flags:  rpcFlags(fromFlag, priceFlag, gasLimitFlag, nameFlag, toFlag, nonceFlag),
+				Action: rpcActionSystemContract("domain", "transfer", handleCallResult),
+			},
+			{
+				Name:   "setaddr",
+				Usage:  "set the address of the domain name in the shard of the address, or remove the address of the addrshard if empty",
+				Flags:  rpcFlags(fromFlag, priceFlag, gasLimitFlag, nameFlag, accountFlag, domainShardFlag, nonceFlag),
+				Action: rpcActionSystemContract("domain", "setAddress", handleCallResult),
+			},
+			{
+				Name:   "setcontent",
+				Usage:  "set the content hash of the domain name, or remove it if empty",
+				Flags:  rpcFlags(fromFlag, priceFlag, gasLimitFlag, nameFlag, hashFlag, nonceFlag),
+				Action: rpcActionSystemContract("domain", "setContent", handleCallResult),
+			},
+			{
+				Name:   "settext",
+				Usage:  "set a text record of the domain name, or remove it if the value is empty",
+				Flags:  rpcFlags(fromFlag, priceFlag, gasLimitFlag, nameFlag, textKeyFlag, textValueFlag, nonceFlag),
+				Action: rpcActionSystemContract("domain", "setText", handleCallResult),
+			},
+			{
+				Name:   "renew",
+				Usage:  "extend the expiry of the domain name by one year, allowed within a year before the expiry",
+				Flags:  rpcFlags(fromFlag, priceFlag, gasLimitFlag, nameFlag, nonceFlag),
+				Action: rpcActionSystemContract("domain", "renew", handleCallResult),
+			},
+			{
+				Name:   "setreverse",
+				Usage:  "set the domain name of the sender for the reverse lookup, or remove it if empty",
+				Flags:  rpcFlags(fromFlag, priceFlag, gasLimitFlag, nameFlag, nonceFlag),
+				Action: rpcActionSystemContract("domain", "setReverse", handleCallResult),
+			},
+			{
+				Name:   "resolve",
+				Usage:  "resolve the domain name to the address of the addrshard",
+				Flags:  rpcFlags(fromFlag, nameFlag, domainShardFlag),
+				Action: rpcActionSystemContract("domain", "resolve", handleCallResult),
+			},
+			{
+				Name:   "lookup",
+				Usage:  "reverse lookup the domain name of the account",
+				Flags:  rpcFlags(fromFlag, accountFlag),
+				Action: rpcActionSystemContract("domain", "getName", handleCallResult),
+			},
+			{
+				Name:   "info",
+				Usage:  "get the owner, the expiry and the records of the domain name",
+				Flags:  rpcFlags(fromFlag, nameFlag),
+				Action: rpcActionSystemContract("domain", "getRecord", handleCallResult),
+			},
 		},
 	}
 
@@ -287,6 +389,66 @@ func AddCommands(app *cli.App, isFullNode bool) {
 				Flags:  rpcFlags(fromFlag, priceFlag, gasLimitFlag, nonceFlag, nameFlag),
 				Action: rpcActionSystemContract("subchain", "query", handleCallResult),
 			},
+			{
+				Name:   "update",
+				Usage:  "update the version, static nodes and initial validators of the sub chain by the owner",
+				Flags:  rpcFlags(fromFlag, priceFlag, gasLimitFlag, nonceFlag, nameFlag, versionFlag, staticNodesFlag, validatorsFlag),
+				Action: rpcActionSystemContract("subchain", "update", handleCallResult),
+			},
+			{
+				Name:   "deprecate",
+				Usage:  "deprecate the sub chain by the owner",
+				Flags:  rpcFlags(fromFlag, priceFlag, gasLimitFlag, nonceFlag, nameFlag),
+				Action: rpcActionSystemContract("subchain", "deprecate", handleCallResult),
+			},
+			{
+				Name:   "sign",
+				Usage:  "sign the state root of sub chain block by validator and save the signature into anchor file",
+				Flags:  rpcFlags(fromFlag, nameFlag, heightFlag, subChainJSONFileFlag),
+				Action: signSubChainAnchor,
+			},
+			{
+				Name:   "anchor",
+				Usage:  "anchor the sub chain state root with the signatures of validators in anchor file",
+				Flags:  rpcFlags(fromFlag, priceFlag, gasLimitFlag, nonceFlag, subChainJSONFileFlag),
+				Action: rpcActionSystemContract("subchain", "anchor", handleCallResult),
+			},
+			{
+				Name:   "signvalidators",
+				Usage:  "sign the validators rotation of sub chain by validator and save the signature into rotation file",
+				Flags:  rpcFlags(fromFlag, nameFlag, validatorsFlag, subChainJSONFileFlag),
+				Action: signSubChainValidators,
+			},
+			{
+				Name:   "rotate",
+				Usage:  "rotate the sub chain validators with the signatures of validators in rotation file, which sign the anchors after a week",
+				Flags:  rpcFlags(fromFlag, priceFlag, gasLimitFlag, nonceFlag, subChainJSONFileFlag),
+				Action: rpcActionSystemContract("subchain", "rotate", handleCallResult),
+			},
+			{
+				Name:   "deposit",
+				Usage:  "deposit amount on the main chain for the withdrawals of sub chain",
+				Flags:  rpcFlags(fromFlag, amountFlag, priceFlag, gasLimitFlag, nonceFlag, nameFlag),
+				Action: rpcActionSystemContract("subchain", "deposit", handleCallResult),
+			},
+			{
+				Name:   "withdraw",
+				Usage:  "withdraw the funds transferred to the exit address on sub chain with the anchored state root",
+				Flags:  rpcFlags(fromFlag, priceFlag, gasLimitFlag, nonceFlag, nameFlag, heightPosFlag, subChainAddressFlag),
+				Action: rpcActionSystemContract("subchain", "withdraw", handleCallResult),
+			},
+			{
+				Name:   "getAnchor",
+				Usage:  "get the anchored sub chain state root at height, or the latest one if height is 0",
+				Flags:  rpcFlags(fromFlag, nameFlag, heightPosFlag),
+				Action: rpcActionSystemContract("subchain", "getAnchor", handleCallResult),
+			},
+			{
+				Name:   "exitaddress",
+				Usage:  "get the exit address on sub chain of the main chain account",
+				Flags:  []cli.Flag{accountFlag},
+				Action: printSubChainExitAddress,
+			},
 			cli.Command{
 				Name:   "config",
 				Usage:  "generate sub chain config file",
@@ -302,6 +464,142 @@ func AddCommands(app *cli.App, isFullNode bool) {
 		},
 	}
 
+	validatorCommands := cli.Command{
+		Name:  "validator",
+		Usage: "system istanbul validator commands",
+		Subcommands: []cli.Command{
+			{
+				Name:   "register",
+				Usage:  "stake and register as validator candidate",
+				Flags:  rpcFlags(fromFlag, amountFlag, priceFlag, gasLimitFlag, nonceFlag),
+				Action: rpcActionSystemContract("validator", "register", handleCallResult),
+			},
+			{
+				Name:   "unregister",
+				Usage:  "unregister the candidate and start unbonding the stake",
+				Flags:  rpcFlags(fromFlag, priceFlag, gasLimitFlag, nonceFlag),
+				Action: rpcActionSystemContract("validator", "unregister", handleCallResult),
+			},
+			{
+				Name:   "withdraw",
+				Usage:  "withdraw the stake after the unbonding period",
+				Flags:  rpcFlags(fromFlag, priceFlag, gasLimitFlag, nonceFlag),
+				Action: rpcActionSystemContract("validator", "withdraw", handleCallResult),
+			},
+			{
+				Name:   "vote",
+				Usage:  "vote for a candidate with the amount",
+				Flags:  rpcFlags(fromFlag, accountFlag, amountFlag, priceFlag, gasLimitFlag, nonceFlag),
+				Action: rpcActionSystemContract("validator", "vote", handleCallResult),
+			},
+			{
+				Name:   "cancelvote",
+				Usage:  "cancel the vote for a candidate and refund",
+				Flags:  rpcFlags(fromFlag, accountFlag, priceFlag, gasLimitFlag, nonceFlag),
+				Action: rpcActionSystemContract("validator", "cancelvote", handleCallResult),
+			},
+			{
+				Name:   "candidate",
+				Usage:  "get the candidate information",
+				Flags:  rpcFlags(fromFlag, accountFlag),
+				Action: rpcActionSystemContract("validator", "candidate", handleCallResult),
+			},
+			{
+				Name:   "validators",
+				Usage:  "get the validators elected by stake",
+				Flags:  rpcFlags(fromFlag),
+				Action: rpcActionSystemContract("validator", "validators", handleCallResult),
+			},
+		},
+	}
+
+	nativeCommands := cli.Command{
+		Name:  "system",
+		Usage: "system contract commands generated from the contract schema",
+		Subcommands: []cli.Command{
+			{
+				Name:   "list",
+				Usage:  "list the schema of system contracts activated at height",
+				Flags:  rpcFlags(heightFlag),
+				Action: rpcAction("scdo", "getSystemContracts"),
+			},
+			{
+				Name:   "call",
+				Usage:  "call the system contract command, read-only command is called without sending transaction",
+				Flags:  rpcFlags(fromFlag, amountFlag, priceFlag, gasLimitFlag, nonceFlag, nativeContractFlag, nativeCommandFlag, nativeInputFlag),
+				Action: callNativeContract,
+			},
+			{
+				Name:   "abi",
+				Usage:  "print the Solidity ABI of the system contract to call it in EVM contracts",
+				Flags:  rpcFlags(nativeContractFlag),
+				Action: printNativeContractABI,
+			},
+		},
+	}
+
+	masternodeCommands := cli.Command{
+		Name:  "masternode",
+		Usage: "system masternode commands",
+		Subcommands: []cli.Command{
+			{
+				Name:   "deposit",
+				Usage:  "deposit scdo and register as masternode, the amount is the deposit by default",
+				Flags:  rpcFlags(fromFlag, amountFlag, priceFlag, gasLimitFlag, nonceFlag),
+				Action: rpcActionSystemContract("masternode", "deposit", handleCallResult),
+			},
+			{
+				Name:   "quit",
+				Usage:  "quit the masternode, the deposit and reward can be recalled after about one day",
+				Flags:  rpcFlags(fromFlag, priceFlag, gasLimitFlag, nonceFlag),
+				Action: rpcActionSystemContract("masternode", "quit", handleCallResult),
+			},
+			{
+				Name:   "recall",
+				Usage:  "recall the deposit and reward of the quit masternode",
+				Flags:  rpcFlags(fromFlag, priceFlag, gasLimitFlag, nonceFlag),
+				Action: rpcActionSystemContract("masternode", "recall", handleCallResult),
+			},
+			{
+				Name:   "slash",
+				Usage:  "slash the masternode with the evidence of double signing",
+				Flags:  rpcFlags(fromFlag, evidenceFileFlag, priceFlag, gasLimitFlag, nonceFlag),
+				Action: rpcActionSystemContract("masternode", "slash", handleCallResult),
+			},
+			{
+				Name:   "status",
+				Usage:  "get the status and reward of the masternode",
+				Flags:  rpcFlags(fromFlag, accountFlag),
+				Action: rpcActionSystemContract("masternode", "status", handleCallResult),
+			},
+			{
+				Name:   "list",
+				Usage:  "list all the masternodes",
+				Flags:  rpcFlags(fromFlag),
+				Action: rpcActionSystemContract("masternode", "list", handleCallResult),
+			},
+		},
+	}
+
+	istanbulCommands := cli.Command{
+		Name:  "istanbul",
+		Usage: "istanbul consensus commands",
+		Subcommands: []cli.Command{
+			{
+				Name:   "status",
+				Usage:  "get the consensus state, message statistics and phase latency",
+				Flags:  rpcFlags(),
+				Action: rpcAction("istanbul", "getStatus"),
+			},
+			{
+				Name:   "evidences",
+				Usage:  "get the pending evidences of double signing validators",
+				Flags:  rpcFlags(),
+				Action: rpcAction("istanbul", "getEvidences"),
+			},
+		},
+	}
+
 	p2pCommands := cli.Command{
 		Name:  "p2p",
 		Usage: "p2p commands",
@@ -360,6 +658,48 @@ func AddCommands(app *cli.App, isFullNode bool) {
 				Flags:  rpcFlags(),
 				Action: rpcAction("network", "getBlockListCount"),
 			},
+			{
+				Name:   "ban",
+				Usage:  "ban the node and disconnect it",
+				Flags:  rpcFlags(nodeIDFlag, banMinutesFlag, banReasonFlag),
+				Action: rpcAction("network", "banPeer"),
+			},
+			{
+				Name:   "unban",
+				Usage:  "lift the ban of the node",
+				Flags:  rpcFlags(nodeIDFlag),
+				Action: rpcAction("network", "unbanPeer"),
+			},
+			{
+				Name:   "listbans",
+				Usage:  "list the banned nodes",
+				Flags:  rpcFlags(),
+				Action: rpcAction("network", "listBans"),
+			},
+			{
+				Name:   "addpeer",
+				Usage:  "add the node as a trusted peer and connect to it",
+				Flags:  rpcFlags(peerNodeFlag),
+				Action: rpcAction("network", "addPeer"),
+			},
+			{
+				Name:   "removepeer",
+				Usage:  "remove the node from the trusted peers and disconnect it",
+				Flags:  rpcFlags(nodeIDFlag),
+				Action: rpcAction("network", "removePeer"),
+			},
+			{
+				Name:   "setmaxpeers",
+				Usage:  "set the max number of peers",
+				Flags:  rpcFlags(maxPeersFlag),
+				Action: rpcAction("network", "setMaxPeers"),
+			},
+			{
+				Name:   "liststaticnodes",
+				Usage:  "list the static nodes and the trusted peers",
+				Flags:  rpcFlags(),
+				Action: rpcAction("network", "listStaticNodes"),
+			},
 		},
 	}
 
@@ -481,10 +821,14 @@ func AddCommands(app *cli.App, isFullNode bool) {
 			htlcCommands,
 			domainCommands,
 			subChainCommands,
+			validatorCommands,
+			masternodeCommands,
+			nativeCommands,
+			istanbulCommands,
 			minerCommands)
 	}
 
-	baseCommands = append(baseCommands, p2pCommands)
+	baseCommands = append(baseCommands, p2pCommands, hdWalletCommands)
 
 	app.Commands = baseCommands
 
@@ -14,12 +14,14 @@ import (
 	"io/ioutil"
 	"math/big"
 	"path/filepath"
+	"reflect"
 	"strings"
 	"time"
 
 	"github.com/elcn233/go-scdo/cmd/util"
 	"github.com/elcn233/go-scdo/common"
 	"github.com/elcn233/go-scdo/common/hexutil"
+	"github.com/elcn233/go-scdo/common/keystore"
 	"github.com/elcn233/go-scdo/contract/system"
 	"github.com/elcn233/go-scdo/core"
 	"github.com/elcn233/go-scdo/crypto"
@@ -37,7 +39,6 @@ var (
 	errInvalidTokenFullName  = errors.New("invalid subchain token full name")
 	errInvalidTokenShortName = errors.New("invalid subchain token short name")
 	errInvalidTokenAmount    = errors.New("invalid subchain token amount")
-	errSubChainInfo          = errors.New("failed to get sub-chain information")
 
 	defaultTokenFullName  = "scdocoin"
 	defaultTokenShortName = "scdo"
@@ -105,6 +106,313 @@ func querySubChain(client *rpc.Client) (interface{}, interface{}, error) {
 	return tx, tx, err
 }
 
+// updateSubChain update the version, static nodes and validators of the sub-chain by the owner
+func updateSubChain(client *rpc.Client) (interface{}, interface{}, error) {
+	amountValue = "0"
+
+	if len(versionValue) == 0 {
+		return nil, nil, errInvalidVersion
+	}
+
+	staticNodes, err := getStaticNodes()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	validators, err := getValidators()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	request, err := json.Marshal(&system.SubChainUpdateRequest{Name: nameValue, Version: versionValue, StaticNodes: staticNodes, Validators: validators})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	tx, err := sendSystemContractTx(client, system.SubChainContractAddress, system.CmdSubChainUpdate, request)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return tx, tx, err
+}
+
+// deprecateSubChain deprecate the sub-chain by the owner
+func deprecateSubChain(client *rpc.Client) (interface{}, interface{}, error) {
+	amountValue = "0"
+
+	if err := system.ValidateDomainName([]byte(nameValue)); err != nil {
+		return nil, nil, err
+	}
+
+	tx, err := sendSystemContractTx(client, system.SubChainContractAddress, system.CmdSubChainDeprecate, []byte(nameValue))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return tx, tx, err
+}
+
+// signSubChainAnchor sign the state root of sub-chain block by validator, and save
+// the signature into the anchor file to collect the signatures of other validators.
+func signSubChainAnchor(c *cli.Context) error {
+	client, err := rpc.DialTCP(context.Background(), addressValue)
+	if err != nil {
+		return err
+	}
+
+	var block struct {
+		Hash   common.Hash `json:"hash"`
+		Header struct {
+			Height    uint64
+			StateHash common.Hash
+		} `json:"header"`
+	}
+	if err = client.Call(&block, "scdo_getBlockByHeight", heightValue, false); err != nil {
+		return err
+	}
+
+	var request system.SubChainAnchorRequest
+	if buff, err := ioutil.ReadFile(subChainJSONFileVale); err == nil {
+		if err = json.Unmarshal(buff, &request); err != nil {
+			return err
+		}
+
+		if request.Name != nameValue || request.Height != block.Header.Height || request.BlockHash != block.Hash || request.StateRoot != block.Header.StateHash {
+			return fmt.Errorf("the anchor in file %s mismatch with the block", subChainJSONFileVale)
+		}
+	} else {
+		request = system.SubChainAnchorRequest{
+			Name:      nameValue,
+			Height:    block.Header.Height,
+			BlockHash: block.Hash,
+			StateRoot: block.Header.StateHash,
+		}
+	}
+
+	pass, err := common.GetPassword()
+	if err != nil {
+		return fmt.Errorf("failed to get password %s", err)
+	}
+
+	key, err := keystore.GetKey(fromValue, pass)
+	if err != nil {
+		return fmt.Errorf("invalid validator key file. it should be a private key: %s", err)
+	}
+
+	hash := system.SubChainAnchorHash(request.Name, request.Height, request.BlockHash, request.StateRoot)
+	signature, err := crypto.Sign(key.PrivateKey, hash.Bytes())
+	if err != nil {
+		return err
+	}
+	request.Signatures = append(request.Signatures, signature.Sig)
+
+	buff, err := json.MarshalIndent(&request, "", "\t")
+	if err != nil {
+		return err
+	}
+	if err = common.SaveFile(subChainJSONFileVale, buff); err != nil {
+		return err
+	}
+
+	fmt.Printf("sign the state root of sub chain %s at height %d successfully, %d signatures collected\n", request.Name, request.Height, len(request.Signatures))
+	return nil
+}
+
+// anchorSubChain anchor the sub-chain state root with signatures of validators in the anchor file
+func anchorSubChain(client *rpc.Client) (interface{}, interface{}, error) {
+	amountValue = "0"
+
+	buff, err := ioutil.ReadFile(subChainJSONFileVale)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var request system.SubChainAnchorRequest
+	if err = json.Unmarshal(buff, &request); err != nil {
+		return nil, nil, err
+	}
+
+	tx, err := sendSystemContractTx(client, system.SubChainContractAddress, system.CmdSubChainAnchor, buff)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return tx, tx, err
+}
+
+// signSubChainValidators sign the validators rotation of sub-chain by validator, and save
+// the signature into the rotation file to collect the signatures of other validators.
+func signSubChainValidators(c *cli.Context) error {
+	validators, err := getValidators()
+	if err != nil {
+		return err
+	}
+
+	client, err := rpc.DialTCP(context.Background(), addressValue)
+	if err != nil {
+		return err
+	}
+
+	info, err := util.GetSubChain(client, nameValue)
+	if err != nil {
+		return err
+	}
+
+	var request system.SubChainValidatorsRequest
+	if buff, err := ioutil.ReadFile(subChainJSONFileVale); err == nil {
+		if err = json.Unmarshal(buff, &request); err != nil {
+			return err
+		}
+
+		if request.Name != nameValue || request.Nonce != info.ValidatorsNonce || !reflect.DeepEqual(request.Validators, validators) {
+			return fmt.Errorf("the rotation in file %s mismatch with the sub chain validators", subChainJSONFileVale)
+		}
+	} else {
+		request = system.SubChainValidatorsRequest{
+			Name:       nameValue,
+			Nonce:      info.ValidatorsNonce,
+			Validators: validators,
+		}
+	}
+
+	pass, err := common.GetPassword()
+	if err != nil {
+		return fmt.Errorf("failed to get password %s", err)
+	}
+
+	key, err := keystore.GetKey(fromValue, pass)
+	if err != nil {
+		return fmt.Errorf("invalid validator key file. it should be a private key: %s", err)
+	}
+
+	hash := system.SubChainValidatorsHash(request.Name, request.Nonce, request.Validators)
+	signature, err := crypto.Sign(key.PrivateKey, hash.Bytes())
+	if err != nil {
+		return err
+	}
+	request.Signatures = append(request.Signatures, signature.Sig)
+
+	buff, err := json.MarshalIndent(&request, "", "\t")
+	if err != nil {
+		return err
+	}
+	if err = common.SaveFile(subChainJSONFileVale, buff); err != nil {
+		return err
+	}
+
+	fmt.Printf("sign the validators of sub chain %s successfully, %d signatures collected\n", request.Name, len(request.Signatures))
+	return nil
+}
+
+// rotateSubChainValidators rotate the sub-chain validators with signatures of validators in the rotation file
+func rotateSubChainValidators(client *rpc.Client) (interface{}, interface{}, error) {
+	amountValue = "0"
+
+	buff, err := ioutil.ReadFile(subChainJSONFileVale)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var request system.SubChainValidatorsRequest
+	if err = json.Unmarshal(buff, &request); err != nil {
+		return nil, nil, err
+	}
+
+	tx, err := sendSystemContractTx(client, system.SubChainContractAddress, system.CmdSubChainRotateValidators, buff)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return tx, tx, err
+}
+
+// depositSubChain lock the amount on the main chain for the withdrawals of sub-chain
+func depositSubChain(client *rpc.Client) (interface{}, interface{}, error) {
+	if err := system.ValidateDomainName([]byte(nameValue)); err != nil {
+		return nil, nil, err
+	}
+
+	tx, err := sendSystemContractTx(client, system.SubChainContractAddress, system.CmdSubChainDeposit, []byte(nameValue))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return tx, tx, err
+}
+
+// withdrawSubChain withdraw the funds transferred to the exit address of sender on sub-chain
+// with the account proof against the anchored state root.
+func withdrawSubChain(client *rpc.Client) (interface{}, interface{}, error) {
+	amountValue = "0"
+
+	anchor, err := util.GetSubChainAnchor(client, nameValue, heightPosValue)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	key, txd, err := makeTransactionData(client)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	subChainClient, err := rpc.DialTCP(context.Background(), subChainAddressValue)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	proof, err := util.GetAccountProof(subChainClient, system.SubChainExitAddress(key.Address), anchor.BlockHash.Hex(), int64(anchor.Height))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if proof.StateRoot != anchor.StateRoot {
+		return nil, nil, fmt.Errorf("the state root of sub chain block %d mismatch with the anchored one", anchor.Height)
+	}
+
+	request, err := json.Marshal(&system.SubChainWithdrawRequest{Name: nameValue, Height: anchor.Height, Proof: proof.Proof})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	payload := append([]byte{system.CmdSubChainWithdraw}, request...)
+	tx, err := util.GenerateTx(key.PrivateKey, &txd.From, system.SubChainContractAddress, txd.Amount, txd.GasPrice, txd.GasLimit, txd.AccountNonce, payload)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return tx, tx, err
+}
+
+// getSubChainAnchor get the anchored sub-chain state root, the latest one is returned if height is 0
+func getSubChainAnchor(client *rpc.Client) (interface{}, interface{}, error) {
+	amountValue = "0"
+	priceValue = "1"
+
+	request, err := json.Marshal(&system.SubChainAnchorQuery{Name: nameValue, Height: heightPosValue})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	tx, err := sendSystemContractTx(client, system.SubChainContractAddress, system.CmdSubChainGetAnchor, request)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return tx, tx, err
+}
+
+// printSubChainExitAddress print the exit address on sub-chain of the main chain account
+func printSubChainExitAddress(c *cli.Context) error {
+	account, err := common.HexToAddress(accountValue)
+	if err != nil {
+		return err
+	}
+
+	fmt.Println("exit address:", system.SubChainExitAddress(account).Hex())
+	return nil
+}
+
 func createSubChainConfigFile(c *cli.Context) error {
 	client, err := rpc.DialTCP(context.Background(), addressValue)
 	if err != nil {
@@ -160,42 +468,7 @@ func getSubChainFromFile(filepath string) (*system.SubChainInfo, error) {
 }
 
 func getSubChainFromReceipt(client *rpc.Client) (*system.SubChainInfo, error) {
-	if err := system.ValidateDomainName([]byte(nameValue)); err != nil {
-		return nil, err
-	}
-	payloadBytes := append([]byte{system.CmdSubChainQuery}, []byte(nameValue)...)
-	mapReceipt, err := util.CallContract(client, system.SubChainContractAddress.Hex(), hexutil.BytesToHex(payloadBytes), -1)
-	if err != nil {
-		return nil, err
-	}
-
-	resultFlag, ok := mapReceipt["failed"].(bool)
-	if !ok {
-		return nil, errSubChainInfo
-	}
-	result, ok := mapReceipt["result"].(string)
-	if !ok {
-		return nil, errSubChainInfo
-	}
-	if resultFlag {
-		return nil, fmt.Errorf("failed to get sub-chain information, %s", result)
-	}
-
-	bytesSubChainInfo, err := hexutil.HexToBytes(result)
-	if err != nil {
-		return nil, err
-	}
-
-	if len(bytesSubChainInfo) == 0 {
-		return nil, fmt.Errorf("sub-chain %s does not exist", nameValue)
-	}
-
-	var subChainInfo system.SubChainInfo
-	if err = json.Unmarshal(bytesSubChainInfo, &subChainInfo); err != nil {
-		return nil, err
-	}
-
-	return &subChainInfo, nil
+	return util.GetSubChain(client, nameValue)
 }
 
 // getPrivateKey get private key and validate shard
@@ -229,6 +502,19 @@ func getStaticNodes() ([]*discovery.Node, error) {
 	return arrayNode, nil
 }
 
+func getValidators() ([]common.Address, error) {
+	var validators []common.Address
+	for _, value := range validatorsValue.Value() {
+		validator, err := common.HexToAddress(value)
+		if err != nil {
+			return nil, err
+		}
+
+		validators = append(validators, validator)
+	}
+	return validators, nil
+}
+
 func getConfigFromSubChain(networkID string, subChainInfo *system.SubChainInfo) (*util.Config, error) {
 	addr, err := common.HexToAddress(coinbaseValue)
 	if err != nil {
@@ -28,21 +28,73 @@ var (
 			"withdraw": withdraw,
 			"refund":   refund,
 			"get":      getHTLC,
+			"batch":    createHTLCs,
+			"list":     listHTLCs,
 		},
 		"domain": map[string]handler{
-			"create":   createDomainName,
-			"getOwner": getDomainNameOwner,
+			"create":     createDomainName,
+			"getOwner":   getDomainNameOwner,
+			"transfer":   transferDomainName,
+			"setAddress": setDomainAddress,
+			"setContent": setDomainContentHash,
+			"setText":    setDomainText,
+			"renew":      renewDomainName,
+			"setReverse": setReverseName,
+			"resolve":    resolveDomainName,
+			"getName":    getDomainName,
+			"getRecord":  getDomainRecord,
 		},
 		"subchain": map[string]handler{
-			"register": registerSubChain,
-			"query":    querySubChain,
+			"register":  registerSubChain,
+			"query":     querySubChain,
+			"update":    updateSubChain,
+			"deprecate": deprecateSubChain,
+			"anchor":    anchorSubChain,
+			"deposit":   depositSubChain,
+			"withdraw":  withdrawSubChain,
+			"getAnchor": getSubChainAnchor,
+			"rotate":    rotateSubChainValidators,
+		},
+		"validator": map[string]handler{
+			"register":   registerCandidate,
+			"unregister": unregisterCandidate,
+			"withdraw":   withdrawStake,
+			"vote":       voteCandidate,
+			"cancelvote": cancelVote,
+			"candidate":  getCandidate,
+			"validators": getElectedValidators,
+		},
+		"masternode": map[string]handler{
+			"deposit": depositMasternode,
+			"quit":    quitMasternode,
+			"recall":  recallMasternode,
+			"slash":   slashMasternode,
+			"status":  getMasternode,
+			"list":    getMasternodes,
 		},
 	}
 
 	// if the method have key-value, use the call method to get receipt
 	callFlags = map[string]map[string]string{
 		"htlc": map[string]string{
-			"get": "1",
+			"get":  "1",
+			"list": "1",
+		},
+		"domain": map[string]string{
+			"resolve":   "1",
+			"getName":   "1",
+			"getRecord": "1",
+		},
+		"subchain": map[string]string{
+			"getAnchor": "1",
+		},
+		"validator": map[string]string{
+			"candidate":  "1",
+			"validators": "1",
+		},
+		"masternode": map[string]string{
+			"status": "1",
+			"list":   "1",
 		},
 	}
 )
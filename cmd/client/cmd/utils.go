@@ -29,7 +29,7 @@ func checkParameter(publicKey *ecdsa.PublicKey, client *rpc.Client, keyaddress c
 	info := &types.TransactionData{}
 	var err error
 	if len(toValue) > 0 {
-		toAddr, err := common.HexToAddress(toValue)
+		toAddr, err := resolveAddress(client, toValue)
 		if err != nil {
 			return info, fmt.Errorf("invalid receiver address: %s", err)
 		}
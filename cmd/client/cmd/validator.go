@@ -0,0 +1,106 @@
+/**
+*  @file
+*  @copyright defined in scdo/LICENSE
+ */
+
+package cmd
+
+import (
+	"github.com/elcn233/go-scdo/contract/system"
+	"github.com/elcn233/go-scdo/rpc"
+)
+
+// registerCandidate stake scdo and register as validator candidate
+func registerCandidate(client *rpc.Client) (interface{}, interface{}, error) {
+	tx, err := sendSystemContractTx(client, system.ValidatorContractAddress, system.CmdRegisterCandidate, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return tx, tx, err
+}
+
+// unregisterCandidate unregister the candidate and start unbonding the stake
+func unregisterCandidate(client *rpc.Client) (interface{}, interface{}, error) {
+	amountValue = "0"
+
+	tx, err := sendSystemContractTx(client, system.ValidatorContractAddress, system.CmdUnregisterCandidate, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return tx, tx, err
+}
+
+// withdrawStake refund the stake after the unbonding period
+func withdrawStake(client *rpc.Client) (interface{}, interface{}, error) {
+	amountValue = "0"
+
+	tx, err := sendSystemContractTx(client, system.ValidatorContractAddress, system.CmdWithdrawStake, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return tx, tx, err
+}
+
+// voteCandidate delegate scdo to the candidate
+func voteCandidate(client *rpc.Client) (interface{}, interface{}, error) {
+	candidate, err := resolveAddress(client, accountValue)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	tx, err := sendSystemContractTx(client, system.ValidatorContractAddress, system.CmdVote, candidate.Bytes())
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return tx, tx, err
+}
+
+// cancelVote cancel the delegation to the candidate
+func cancelVote(client *rpc.Client) (interface{}, interface{}, error) {
+	amountValue = "0"
+	candidate, err := resolveAddress(client, accountValue)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	tx, err := sendSystemContractTx(client, system.ValidatorContractAddress, system.CmdCancelVote, candidate.Bytes())
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return tx, tx, err
+}
+
+// getCandidate get the candidate information
+func getCandidate(client *rpc.Client) (interface{}, interface{}, error) {
+	amountValue = "0"
+	priceValue = "1"
+	candidate, err := resolveAddress(client, accountValue)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	tx, err := sendSystemContractTx(client, system.ValidatorContractAddress, system.CmdQueryCandidate, candidate.Bytes())
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return tx, tx, err
+}
+
+// getElectedValidators get the validators elected by stake
+func getElectedValidators(client *rpc.Client) (interface{}, interface{}, error) {
+	amountValue = "0"
+	priceValue = "1"
+
+	tx, err := sendSystemContractTx(client, system.ValidatorContractAddress, system.CmdQueryValidators, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return tx, tx, err
+}
@@ -0,0 +1,356 @@
+/**
+*  @file
+*  @copyright defined in scdo/LICENSE
+ */
+
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/elcn233/go-scdo/common"
+	"github.com/elcn233/go-scdo/core/types"
+	"github.com/elcn233/go-scdo/crypto"
+	"github.com/elcn233/go-scdo/p2p"
+	"github.com/elcn233/go-scdo/p2p/discovery"
+	"github.com/elcn233/go-scdo/p2p/probe"
+	"github.com/spf13/cobra"
+)
+
+// crawlProbeConcurrency is the number of nodes probed at the same time
+const crawlProbeConcurrency = 16
+
+var (
+	crawlConfigFile    *string
+	crawlAccountsFile  *string
+	crawlAddr          *string
+	crawlBootstrap     *[]string
+	crawlShard         *uint
+	crawlRounds        *int
+	crawlRoundInterval *time.Duration
+	crawlProbeTimeout  *time.Duration
+	crawlOutputDir     *string
+	crawlOnce          *bool
+)
+
+// crawlConfig is the part of the node config used by the crawler, the node config
+// is not used as is, so that the discovery binary doesn't depend on the full node.
+type crawlConfig struct {
+	// The configuration of p2p network
+	P2PConfig p2p.Config `json:"p2p"`
+
+	// genesis config info
+	GenesisConfig types.GenesisInfo `json:"genesis"`
+}
+
+// nodeReport is the crawl result of a node
+type nodeReport struct {
+	ID         string   `json:"id"`
+	Addr       string   `json:"addr"`
+	Responded  bool     `json:"responded"` // answered the FIND_NODE query
+	Reachable  bool     `json:"reachable"` // accepted the tcp handshake
+	Error      string   `json:"error,omitempty"`
+	Version    uint     `json:"version,omitempty"`
+	Caps       []string `json:"caps,omitempty"`
+	ClientType string   `json:"clientType"`
+	Head       string   `json:"head,omitempty"`
+	Height     uint64   `json:"height,omitempty"`
+	TD         string   `json:"td,omitempty"`
+	LastSeen   string   `json:"lastSeen"`
+
+	shard uint
+}
+
+// shardReport is the crawl report of a shard
+type shardReport struct {
+	Shard       uint           `json:"shard"`
+	Time        string         `json:"time"`
+	Nodes       int            `json:"nodes"`
+	Responded   int            `json:"responded"`
+	Reachable   int            `json:"reachable"`
+	Versions    map[uint]int   `json:"versions"`
+	ClientTypes map[string]int `json:"clientTypes"`
+	HeadHeights map[uint64]int `json:"headHeights"`
+	MaxHeight   uint64         `json:"maxHeight"`
+	NodeList    []*nodeReport  `json:"nodeList"`
+}
+
+// crawlCmd represents the crawl command
+var crawlCmd = &cobra.Command{
+	Use:   "crawl",
+	Short: "crawl the network and write the report of each shard",
+	Long: `usage example:
+    discovery crawl -c node.json -b snode://<node id>@127.0.0.1:8057[1] -o reports
+        crawl the network of the node config with FIND_NODE queries, dial the nodes found to read
+        their capabilities and chain heads, and write the report of each shard to reports/shard-<n>.json.
+        The crawl is repeated until the command is stopped, use --once to crawl only once.`,
+	Run: func(_ *cobra.Command, args []string) {
+		config, err := loadCrawlConfig(*crawlConfigFile)
+		if err != nil {
+			fmt.Printf("failed to load node config: %s\n", err)
+			return
+		}
+
+		// the genesis accounts are part of the genesis hash checked in the handshake
+		config.GenesisConfig.Accounts, err = loadGenesisAccounts(*crawlAccountsFile)
+		if err != nil {
+			fmt.Printf("failed to load accounts: %s\n", err)
+			return
+		}
+
+		bootstrap := make([]*discovery.Node, 0, len(*crawlBootstrap))
+		for _, str := range *crawlBootstrap {
+			n, err := discovery.NewNodeFromIP(str)
+			if err != nil {
+				fmt.Printf("invalid bootstrap node %s: %s\n", str, err)
+				return
+			}
+			bootstrap = append(bootstrap, n)
+		}
+		bootstrap = append(bootstrap, config.P2PConfig.StaticNodes...)
+
+		myAddr, err := net.ResolveUDPAddr("udp", *crawlAddr)
+		if err != nil {
+			fmt.Printf("invalid address: %s\n", err)
+			return
+		}
+
+		key, err := crypto.GenerateKey()
+		if err != nil {
+			fmt.Println(err.Error())
+			return
+		}
+
+		myID, err := crypto.GetAddress(&key.PublicKey, *crawlShard)
+		if err != nil {
+			fmt.Println(err.Error())
+			return
+		}
+
+		networkID := config.P2PConfig.NetworkID
+		_, udp := discovery.StartService(common.GetTempFolder(), *myID, myAddr, bootstrap, *crawlShard)
+		if err = udp.SetLocalRecord(key, networkID, nil); err != nil {
+			fmt.Println(err.Error())
+			return
+		}
+
+		srv := p2p.NewServer(config.GenesisConfig, p2p.Config{NetworkID: networkID, PrivateKey: key}, []p2p.Protocol{probe.Protocol()})
+		srv.SelfNode = discovery.NewNodeWithAddr(*myID, myAddr, *crawlShard)
+
+		if err = os.MkdirAll(*crawlOutputDir, os.ModePerm); err != nil {
+			fmt.Println(err.Error())
+			return
+		}
+
+		crawler := discovery.NewCrawler(udp)
+		for {
+			for i := 0; i < *crawlRounds; i++ {
+				count := crawler.Round()
+				fmt.Printf("crawl round %d, queried %d nodes\n", i+1, count)
+				time.Sleep(*crawlRoundInterval)
+			}
+
+			reports := probeNodes(srv, crawler.Nodes(), *crawlProbeTimeout)
+			if err = writeShardReports(*crawlOutputDir, reports); err != nil {
+				fmt.Printf("failed to write reports: %s\n", err)
+				return
+			}
+
+			fmt.Printf("crawled %d nodes, reports are written to %s\n", len(reports), *crawlOutputDir)
+			if *crawlOnce {
+				return
+			}
+		}
+	},
+}
+
+// loadCrawlConfig unmarshals the p2p and genesis config from the given node config file
+func loadCrawlConfig(file string) (*crawlConfig, error) {
+	var config crawlConfig
+	buff, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+
+	err = json.Unmarshal(buff, &config)
+	return &config, err
+}
+
+// loadGenesisAccounts gets the genesis accounts and balances from the given file
+func loadGenesisAccounts(file string) (map[common.Address]*big.Int, error) {
+	result := make(map[common.Address]*big.Int)
+	if file == "" {
+		return result, nil
+	}
+
+	buff, err := ioutil.ReadFile(file)
+	if err != nil {
+		return result, err
+	}
+
+	err = json.Unmarshal(buff, &result)
+	return result, err
+}
+
+// probeNodes dials the nodes to read their capabilities and chain heads
+func probeNodes(srv *p2p.Server, nodes []*discovery.CrawledNode, timeout time.Duration) []*nodeReport {
+	reports := make([]*nodeReport, len(nodes))
+	jobs := make(chan int)
+	wg := sync.WaitGroup{}
+	for i := 0; i < crawlProbeConcurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for index := range jobs {
+				reports[index] = probeNode(srv, nodes[index], timeout)
+			}
+		}()
+	}
+
+	for i := range nodes {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+
+	return reports
+}
+
+func probeNode(srv *p2p.Server, cn *discovery.CrawledNode, timeout time.Duration) *nodeReport {
+	report := &nodeReport{
+		ID:         cn.Node.ID.Hex(),
+		Addr:       cn.Node.GetUDPAddr().String(),
+		Responded:  cn.Responded,
+		ClientType: "unknown",
+		LastSeen:   cn.LastSeen.Format(time.RFC3339),
+		shard:      cn.Node.Shard,
+	}
+
+	var head *probe.ChainHead
+	handshake, err := srv.Probe(cn.Node, timeout, func(rw p2p.MsgReadWriter) (err error) {
+		head, err = probe.ReadChainHead(rw)
+		return err
+	})
+
+	if handshake != nil {
+		report.Reachable = true
+		report.Version = handshake.Version
+		report.ClientType = clientType(handshake.Caps)
+		for _, cap := range handshake.Caps {
+			report.Caps = append(report.Caps, cap.String())
+		}
+	}
+
+	if head != nil {
+		report.shard = head.Shard
+		report.Head = head.Hash.Hex()
+		report.Height = head.Height
+		if head.TD != nil {
+			report.TD = head.TD.String()
+		}
+	}
+
+	if err != nil {
+		report.Error = err.Error()
+	}
+
+	return report
+}
+
+// clientType returns the type of the node by its capabilities
+func clientType(caps []p2p.Cap) string {
+	light := false
+	for _, cap := range caps {
+		if cap.Name == common.ScdoProtoName {
+			return "full"
+		}
+
+		if strings.HasPrefix(cap.Name, "lightScdo") {
+			light = true
+		}
+	}
+
+	if light {
+		return "light"
+	}
+
+	return "unknown"
+}
+
+// writeShardReports groups the node reports by shard and writes shard-<n>.json for each shard
+func writeShardReports(dir string, reports []*nodeReport) error {
+	now := time.Now().Format(time.RFC3339)
+	shards := make(map[uint]*shardReport)
+	for _, r := range reports {
+		sr, ok := shards[r.shard]
+		if !ok {
+			sr = &shardReport{
+				Shard:       r.shard,
+				Time:        now,
+				Versions:    make(map[uint]int),
+				ClientTypes: make(map[string]int),
+				HeadHeights: make(map[uint64]int),
+			}
+			shards[r.shard] = sr
+		}
+
+		sr.Nodes++
+		sr.NodeList = append(sr.NodeList, r)
+		sr.ClientTypes[r.ClientType]++
+		if r.Responded {
+			sr.Responded++
+		}
+
+		if !r.Reachable {
+			continue
+		}
+
+		sr.Reachable++
+		sr.Versions[r.Version]++
+		if r.Head != "" {
+			sr.HeadHeights[r.Height]++
+			if r.Height > sr.MaxHeight {
+				sr.MaxHeight = r.Height
+			}
+		}
+	}
+
+	for shard, sr := range shards {
+		data, err := json.MarshalIndent(sr, "", "\t")
+		if err != nil {
+			return err
+		}
+
+		if err = ioutil.WriteFile(filepath.Join(dir, fmt.Sprintf("shard-%d.json", shard)), data, 0644); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func init() {
+	rootCmd.AddCommand(crawlCmd)
+
+	crawlConfigFile = crawlCmd.Flags().StringP("config", "c", "", "node config file of the network to crawl")
+	crawlAccountsFile = crawlCmd.Flags().StringP("accounts", "", "", "genesis accounts file of the network")
+	crawlAddr = crawlCmd.Flags().StringP("addr", "a", "0.0.0.0:9100", "crawler udp address")
+	crawlBootstrap = crawlCmd.Flags().StringSliceP("bootstrapNode", "b", nil, "bootstrap node ids, the static nodes of the config are also used")
+	crawlShard = crawlCmd.Flags().UintP("shard", "s", 1, "shard number of the crawler")
+	crawlRounds = crawlCmd.Flags().IntP("rounds", "r", 10, "FIND_NODE rounds before probing the nodes")
+	crawlRoundInterval = crawlCmd.Flags().DurationP("interval", "", 10*time.Second, "interval between the FIND_NODE rounds")
+	crawlProbeTimeout = crawlCmd.Flags().DurationP("timeout", "", 10*time.Second, "dial timeout to probe a node")
+	crawlOutputDir = crawlCmd.Flags().StringP("output", "o", "crawl", "output directory of the reports")
+	crawlOnce = crawlCmd.Flags().BoolP("once", "", false, "crawl only once")
+
+	crawlCmd.MarkFlagRequired("config")
+}
@@ -0,0 +1,92 @@
+/**
+*  @file
+*  @copyright defined in scdo/LICENSE
+ */
+
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+
+	"github.com/elcn233/go-scdo/crypto"
+	"github.com/elcn233/go-scdo/p2p/discovery"
+	"github.com/elcn233/go-scdo/p2p/dnsdisc"
+	"github.com/spf13/cobra"
+)
+
+var (
+	nodesFile  *string // json file of the node records
+	domain     *string // domain the tree is published at
+	signKey    *string // private key to sign the tree
+	treeSeq    *uint   // sequence number of the tree
+	outputFile *string // output json file of the TXT records
+)
+
+// dnsSignCmd represents the dns-sign command
+var dnsSignCmd = &cobra.Command{
+	Use:   "dns-sign",
+	Short: "build and sign the DNS node tree of the node records",
+	Long: `usage example:
+    discovery dns-sign -n nodes.json -d nodes.example.org -k 0x<private key> -q 1 -o txt.json
+        build the tree of the records in nodes.json, which is a json array of snr:<record> strings,
+        and write the TXT records to publish in txt.json. The tree url is printed.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		data, err := ioutil.ReadFile(*nodesFile)
+		if err != nil {
+			fmt.Printf("failed to read nodes file: %s\n", err)
+			return
+		}
+
+		var records []*discovery.Record
+		if err = json.Unmarshal(data, &records); err != nil {
+			fmt.Printf("invalid nodes file: %s\n", err)
+			return
+		}
+
+		key, err := crypto.LoadECDSAFromString(*signKey)
+		if err != nil {
+			fmt.Printf("invalid private key: %s\n", err)
+			return
+		}
+
+		tree, err := dnsdisc.MakeTree(*treeSeq, records)
+		if err != nil {
+			fmt.Printf("failed to make tree: %s\n", err)
+			return
+		}
+
+		url, err := tree.Sign(key, *domain)
+		if err != nil {
+			fmt.Printf("failed to sign tree: %s\n", err)
+			return
+		}
+
+		txt, err := json.MarshalIndent(tree.ToTXT(*domain), "", "\t")
+		if err != nil {
+			fmt.Println(err.Error())
+			return
+		}
+
+		if err = ioutil.WriteFile(*outputFile, txt, 0644); err != nil {
+			fmt.Printf("failed to write TXT records: %s\n", err)
+			return
+		}
+
+		fmt.Printf("tree url: %s\n", url)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(dnsSignCmd)
+
+	nodesFile = dnsSignCmd.Flags().StringP("nodes", "n", "nodes.json", "json file of the node records")
+	domain = dnsSignCmd.Flags().StringP("domain", "d", "", "domain the tree is published at")
+	signKey = dnsSignCmd.Flags().StringP("key", "k", "", "private key to sign the tree")
+	treeSeq = dnsSignCmd.Flags().UintP("seq", "q", 1, "sequence number of the tree, increase it on every update")
+	outputFile = dnsSignCmd.Flags().StringP("output", "o", "txt.json", "output json file of the TXT records")
+
+	dnsSignCmd.MarkFlagRequired("domain")
+	dnsSignCmd.MarkFlagRequired("key")
+}
@@ -16,6 +16,8 @@ import (
 
 	"github.com/elcn233/go-scdo/cmd/util"
 	"github.com/elcn233/go-scdo/common"
+	"github.com/elcn233/go-scdo/consensus/clique"
+	"github.com/elcn233/go-scdo/consensus/istanbul"
 	"github.com/elcn233/go-scdo/core"
 	"github.com/elcn233/go-scdo/crypto"
 	"github.com/elcn233/go-scdo/log/comm"
@@ -25,7 +27,10 @@ import (
 
 // GetConfigFromFile unmarshals the config from the given file
 func GetConfigFromFile(filepath string) (*util.Config, error) {
-	var config util.Config
+	// fields not in the file keep the default istanbul and clique config
+	istanbulConfig := *istanbul.DefaultConfig
+	cliqueConfig := *clique.DefaultConfig
+	config := util.Config{IstanbulConfig: &istanbulConfig, CliqueConfig: &cliqueConfig}
 	buff, err := ioutil.ReadFile(filepath)
 	if err != nil {
 		return &config, err
@@ -60,6 +65,12 @@ func LoadConfigFromFile(configFile string, accounts string, poolAccounts string)
 		return nil, err
 	}
 
+	return convertConfig(cmdConfig, poolAccounts)
+}
+
+// convertConfig converts the config of the file to the node config
+func convertConfig(cmdConfig *util.Config, poolAccounts string) (*node.Config, error) {
+	var err error
 	config := CopyConfig(cmdConfig)
 	convertIPCServerPath(cmdConfig, config)
 
@@ -116,6 +127,9 @@ func CopyConfig(cmdConfig *util.Config) *node.Config {
 		P2PConfig:      cmdConfig.P2PConfig,
 		ScdoConfig:     node.ScdoConfig{},
 		MetricsConfig:  cmdConfig.MetricsConfig,
+		IstanbulConfig: cmdConfig.IstanbulConfig,
+		CliqueConfig:   cmdConfig.CliqueConfig,
+		DevConfig:      cmdConfig.DevConfig,
 	}
 	return config
 }
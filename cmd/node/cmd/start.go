@@ -7,6 +7,7 @@ package cmd
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"net/http"
 	"os"
@@ -27,6 +28,7 @@ import (
 	miner2 "github.com/elcn233/go-scdo/miner"
 	"github.com/elcn233/go-scdo/monitor"
 	"github.com/elcn233/go-scdo/node"
+	"github.com/elcn233/go-scdo/p2p/nat"
 	"github.com/elcn233/go-scdo/scdo"
 	"github.com/elcn233/go-scdo/scdo/lightclients"
 	"github.com/spf13/cobra"
@@ -54,6 +56,9 @@ var (
 
 	maxConns       = int(0)
 	maxActiveConns = int(0)
+
+	// natSpec overrides the nat of the p2p config if set
+	natSpec string
 )
 
 // startCmd represents the start command
@@ -72,145 +77,16 @@ var startCmd = &cobra.Command{
 			return
 		}
 		Cast(nCfg)
-		if !comm.LogConfiguration.PrintLog {
-			fmt.Printf("log folder: %s\n", filepath.Join(log.LogFolder, comm.LogConfiguration.DataDir))
-		}
-
-		scdoNode, err := node.New(nCfg)
-		if err != nil {
-			fmt.Println(err.Error())
-			return
-		}
-
-		// Create scdo service and register the service
-		scdolog := log.GetLogger("scdo")
-		lightLog := log.GetLogger("scdo-light")
-		serviceContext := scdo.ServiceContext{
-			DataDir: nCfg.BasicConfig.DataDir,
-		}
-		ctx := context.WithValue(context.Background(), "ServiceContext", serviceContext)
-
-		var engine consensus.Engine
-		if nCfg.BasicConfig.MinerAlgorithm == common.BFTEngine {
-			engine, err = factory.GetBFTEngine(nCfg.ScdoConfig.CoinbasePrivateKey, nCfg.BasicConfig.DataDir)
-		} else {
-			engine, err = factory.GetConsensusEngine(nCfg.BasicConfig.MinerAlgorithm)
-		}
-
-		if err != nil {
-			fmt.Println(err)
-			return
-		}
-
-		// start pprof http server
-		if pprofPort > 0 {
-			go func() {
-				if err := http.ListenAndServe(fmt.Sprintf("0.0.0.0:%d", pprofPort), nil); err != nil {
-					fmt.Println("Failed to start pprof http server,", err)
-					return
-				}
-			}()
-		}
-
-		if comm.LogConfiguration.IsDebug {
-			go monitorPC()
-		}
-
-		if lightNode {
-			lightService, err := light.NewServiceClient(ctx, nCfg, lightLog, common.LightChainDir, scdoNode.GetShardNumber(), engine)
-			if err != nil {
-				fmt.Println("Create light service error.", err.Error())
-				return
-			}
-
-			if err := scdoNode.Register(lightService); err != nil {
-				fmt.Println(err.Error())
-				return
-			}
-
-			err = scdoNode.Start()
-			if err != nil {
-				fmt.Printf("got error when start node: %s\n", err)
-				return
-			}
-		} else {
-			// light client manager
-			manager, err := lightclients.NewLightClientManager(scdoNode.GetShardNumber(), ctx, nCfg, engine)
-			if err != nil {
-				fmt.Printf("create light client manager failed. %s", err)
-				return
-			}
-
-			// fullnode mode
-			scdoService, err := scdo.NewScdoService(ctx, nCfg, scdolog, engine, manager, startHeight, isPoolMode)
-			if err != nil {
-				fmt.Println(err.Error())
-				return
-			}
-
-			scdoService.Miner().SetThreads(threads)
-
-			scdoService.Miner().SetGpuBlocksThreads(threadblocks, blockthreads)
-
-			lightServerService, err := light.NewServiceServer(scdoService, nCfg, lightLog, scdoNode.GetShardNumber())
-			if err != nil {
-				fmt.Println("Create light server err. ", err.Error())
-				return
-			}
-
-			// monitor service
-			monitorService, err := monitor.NewMonitorService(scdoService, scdoNode, nCfg, scdolog, "Test monitor")
-			if err != nil {
-				fmt.Println(err.Error())
-				return
-			}
-
-			services := manager.GetServices()
-			services = append(services, scdoService, monitorService, lightServerService)
-			for _, service := range services {
-				if err := scdoNode.Register(service); err != nil {
-					fmt.Println(err.Error())
-					return
-				}
-			}
-
-			err = scdoNode.Start()
-			if maxConns > 0 {
-				scdoService.P2PServer().SetMaxConnections(maxConns)
-			}
-			if maxActiveConns > 0 {
-				scdoService.P2PServer().SetMaxActiveConnections(maxActiveConns)
-			}
-			if err != nil {
-				fmt.Printf("got error when start node: %s\n", err)
-				return
-			}
-
-			minerInfo := strings.ToLower(miner)
-			if minerInfo == "start" {
-				err = scdoService.Miner().Start()
-				if err != nil && err != miner2.ErrMinerIsRunning {
-					fmt.Println("failed to start the miner : ", err)
-					return
-				}
-			} else if minerInfo == "stop" {
-				scdoService.Miner().SetStopper(1)
-				scdoService.Miner().Stop()
-			} else {
-				fmt.Println("invalid miner command, must be start or stop")
+		if len(natSpec) > 0 {
+			if _, err = nat.Parse(natSpec); err != nil {
+				fmt.Printf("invalid nat: %s\n", err)
 				return
 			}
+			nCfg.P2PConfig.NAT = natSpec
 		}
-
-		if metricsEnableFlag {
-			metrics.StartMetricsWithConfig(
-				nCfg.MetricsConfig,
-				scdolog,
-				nCfg.BasicConfig.Name,
-				nCfg.BasicConfig.Version,
-				nCfg.P2PConfig.NetworkID,
-				nCfg.ScdoConfig.Coinbase,
-			)
+		if _, err = startNode(nCfg); err != nil {
+			fmt.Println(err)
+			return
 		}
 
 		wg.Add(1)
@@ -234,12 +110,152 @@ func init() {
 	startCmd.Flags().IntVarP(&startHeight, "startheight", "", -1, "the block height to start from")
 	startCmd.Flags().IntVarP(&maxConns, "maxConns", "", 0, "node max connections")
 	startCmd.Flags().IntVarP(&maxActiveConns, "maxActiveConns", "", 0, "node max active connections")
+	startCmd.Flags().StringVarP(&natSpec, "nat", "", "", "port mapping mechanism (none|any|upnp|pmp|pmp:<gateway ip>|extip:<ip>)")
 	startCmd.Flags().BoolVarP(&isPoolMode, "pool", "", false, "pool mode")
 	startCmd.Flags().IntVarP(&threadblocks, "threadblocks", "", 0, "number of thread blocks in a gpu device")
 	startCmd.Flags().IntVarP(&blockthreads, "blockthreads", "", 1, "number of threads per block in a gpu device")
 
 }
 
+// startNode starts the node with the config, the scdo service is nil in light mode
+func startNode(nCfg *node.Config) (*scdo.ScdoService, error) {
+	if !comm.LogConfiguration.PrintLog {
+		fmt.Printf("log folder: %s\n", filepath.Join(log.LogFolder, comm.LogConfiguration.DataDir))
+	}
+
+	scdoNode, err := node.New(nCfg)
+	if err != nil {
+		return nil, err
+	}
+
+	// Create scdo service and register the service
+	scdolog := log.GetLogger("scdo")
+	lightLog := log.GetLogger("scdo-light")
+	serviceContext := scdo.ServiceContext{
+		DataDir: nCfg.BasicConfig.DataDir,
+	}
+	ctx := context.WithValue(context.Background(), "ServiceContext", serviceContext)
+
+	var engine consensus.Engine
+	switch nCfg.BasicConfig.MinerAlgorithm {
+	case common.BFTEngine:
+		engine, err = factory.GetBFTEngine(nCfg.ScdoConfig.CoinbasePrivateKey, nCfg.BasicConfig.DataDir, nCfg.IstanbulConfig)
+	case common.DevEngine:
+		engine = factory.GetDevEngine(nCfg.DevConfig)
+	case common.CliqueEngine:
+		engine, err = factory.GetCliqueEngine(nCfg.ScdoConfig.CoinbasePrivateKey, nCfg.BasicConfig.DataDir, nCfg.CliqueConfig)
+	default:
+		engine, err = factory.GetConsensusEngine(nCfg.BasicConfig.MinerAlgorithm)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	// start pprof http server
+	if pprofPort > 0 {
+		go func() {
+			if err := http.ListenAndServe(fmt.Sprintf("0.0.0.0:%d", pprofPort), nil); err != nil {
+				fmt.Println("Failed to start pprof http server,", err)
+				return
+			}
+		}()
+	}
+
+	if comm.LogConfiguration.IsDebug {
+		go monitorPC()
+	}
+
+	var scdoService *scdo.ScdoService
+	if lightNode {
+		lightService, err := light.NewServiceClient(ctx, nCfg, lightLog, common.LightChainDir, scdoNode.GetShardNumber(), engine)
+		if err != nil {
+			return nil, fmt.Errorf("Create light service error. %s", err)
+		}
+
+		if err := scdoNode.Register(lightService); err != nil {
+			return nil, err
+		}
+
+		err = scdoNode.Start()
+		if err != nil {
+			return nil, fmt.Errorf("got error when start node: %s", err)
+		}
+	} else {
+		// light client manager
+		manager, err := lightclients.NewLightClientManager(scdoNode.GetShardNumber(), ctx, nCfg, engine)
+		if err != nil {
+			return nil, fmt.Errorf("create light client manager failed. %s", err)
+		}
+
+		// fullnode mode
+		scdoService, err = scdo.NewScdoService(ctx, nCfg, scdolog, engine, manager, startHeight, isPoolMode)
+		if err != nil {
+			return nil, err
+		}
+
+		scdoService.Miner().SetThreads(threads)
+
+		scdoService.Miner().SetGpuBlocksThreads(threadblocks, blockthreads)
+
+		lightServerService, err := light.NewServiceServer(scdoService, nCfg, lightLog, scdoNode.GetShardNumber())
+		if err != nil {
+			return nil, fmt.Errorf("Create light server err. %s", err)
+		}
+
+		// monitor service
+		monitorService, err := monitor.NewMonitorService(scdoService, scdoNode, nCfg, scdolog, "Test monitor")
+		if err != nil {
+			return nil, err
+		}
+
+		services := manager.GetServices()
+		services = append(services, scdoService, monitorService, lightServerService)
+		for _, service := range services {
+			if err := scdoNode.Register(service); err != nil {
+				return nil, err
+			}
+		}
+
+		err = scdoNode.Start()
+		if maxConns > 0 {
+			scdoService.P2PServer().SetMaxConnections(maxConns)
+		}
+		if maxActiveConns > 0 {
+			scdoService.P2PServer().SetMaxActiveConnections(maxActiveConns)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("got error when start node: %s", err)
+		}
+
+		minerInfo := strings.ToLower(miner)
+		if minerInfo == "start" {
+			err = scdoService.Miner().Start()
+			if err != nil && err != miner2.ErrMinerIsRunning {
+				return nil, fmt.Errorf("failed to start the miner : %s", err)
+			}
+		} else if minerInfo == "stop" {
+			scdoService.Miner().SetStopper(1)
+			scdoService.Miner().Stop()
+		} else {
+			return nil, errors.New("invalid miner command, must be start or stop")
+		}
+	}
+
+	if metricsEnableFlag {
+		metrics.StartMetricsWithConfig(
+			nCfg.MetricsConfig,
+			scdolog,
+			nCfg.BasicConfig.Name,
+			nCfg.BasicConfig.Version,
+			nCfg.P2PConfig.NetworkID,
+			nCfg.ScdoConfig.Coinbase,
+		)
+	}
+
+	return scdoService, nil
+}
+
 func monitorPC() {
 	var info runtime.MemStats
 	heapDir := filepath.Join(common.GetTempFolder(), "heapProfile")
@@ -0,0 +1,286 @@
+/**
+*  @file
+*  @copyright defined in scdo/LICENSE
+ */
+
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/elcn233/go-scdo/cmd/util"
+	"github.com/elcn233/go-scdo/common"
+	"github.com/elcn233/go-scdo/common/hexutil"
+	"github.com/elcn233/go-scdo/contract/system"
+	"github.com/elcn233/go-scdo/core"
+	"github.com/elcn233/go-scdo/core/types"
+	"github.com/elcn233/go-scdo/listener"
+	"github.com/elcn233/go-scdo/node"
+	"github.com/elcn233/go-scdo/p2p/nat"
+	"github.com/elcn233/go-scdo/rpc"
+	"github.com/elcn233/go-scdo/scdo"
+	"github.com/spf13/cobra"
+)
+
+// subChainEventPoolCapacity is the capacity of the event pool of the sub-chain
+const subChainEventPoolCapacity = 10000
+
+var (
+	subChainConfigFile string
+	mainChainAddr      string
+
+	errSubChainDeprecated = errors.New("the sub-chain is deprecated")
+
+	// subChainEventNames are the events of the sub-chain contract the sub-chain node listens to
+	subChainEventNames = []string{"SubChainUpdated", "SubChainDeprecated"}
+)
+
+// subChainCmd represents the subchain command
+var subChainCmd = &cobra.Command{
+	Use:   "subchain",
+	Short: "sub-chain commands",
+	Long:  `use "node subchain help [<command>]" for detailed usage`,
+}
+
+// subChainStartCmd represents the subchain start command
+var subChainStartCmd = &cobra.Command{
+	Use:   "start <name>",
+	Short: "start a node of the sub-chain registered on the main chain",
+	Long: `usage example:
+		node.exe subchain start mychain -c cmd\subchain.json -m 127.0.0.1:8027
+		start a node of the sub-chain mychain, the genesis and network id are derived
+		from the registration on the main chain, the node config file provides the rest.`,
+	Args: cobra.ExactArgs(1),
+
+	Run: func(cmd *cobra.Command, args []string) {
+		client, err := rpc.DialTCP(context.Background(), mainChainAddr)
+		if err != nil {
+			fmt.Printf("failed to connect to the main chain: %s\n", err)
+			return
+		}
+
+		info, err := util.GetSubChain(client, args[0])
+		if err != nil {
+			fmt.Println(err)
+			return
+		}
+
+		if info.Deprecated {
+			fmt.Println(errSubChainDeprecated)
+			return
+		}
+
+		networkID, err := util.GetNetworkID(client)
+		if err != nil {
+			fmt.Printf("failed to get the network id of the main chain: %s\n", err)
+			return
+		}
+
+		nCfg, err := loadSubChainConfig(subChainConfigFile, info, networkID)
+		if err != nil {
+			fmt.Printf("failed to reading the config file: %s\n", err)
+			return
+		}
+		Cast(nCfg)
+		if len(natSpec) > 0 {
+			if _, err = nat.Parse(natSpec); err != nil {
+				fmt.Printf("invalid nat: %s\n", err)
+				return
+			}
+			nCfg.P2PConfig.NAT = natSpec
+		}
+
+		scdoService, err := startNode(nCfg)
+		if err != nil {
+			fmt.Println(err)
+			return
+		}
+
+		abi, err := listener.NewContractEventABIFromJSON(system.SubChainEventsABI, system.SubChainContractAddress, subChainEventNames...)
+		if err != nil {
+			fmt.Println(err)
+			return
+		}
+
+		mainChain := &rpcMainChain{client, system.SubChainContractAddress, system.SubChainEventsABI, subChainEventNames}
+		pool, err := core.NewEventPoolWithMainChain(subChainEventPoolCapacity, mainChain, scdoService.BlockChain(), abi)
+		if err != nil {
+			fmt.Println(err)
+			return
+		}
+		defer pool.Stop()
+
+		watchSubChain(client, info, pool, scdoService)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(subChainCmd)
+	subChainCmd.AddCommand(subChainStartCmd)
+
+	subChainStartCmd.Flags().StringVarP(&subChainConfigFile, "config", "c", "", "sub-chain node config file (required)")
+	subChainStartCmd.MarkFlagRequired("config")
+
+	subChainStartCmd.Flags().StringVarP(&mainChainAddr, "mainchain", "", "127.0.0.1:8027", "rpc address of a main chain node")
+	subChainStartCmd.Flags().StringVarP(&miner, "miner", "m", "start", "miner start or not, [start, stop]")
+	subChainStartCmd.Flags().BoolVarP(&metricsEnableFlag, "metrics", "t", false, "start metrics")
+	subChainStartCmd.Flags().IntVarP(&threads, "threads", "", 1, "miner thread value")
+	subChainStartCmd.Flags().StringVarP(&natSpec, "nat", "", "", "port mapping mechanism (none|any|upnp|pmp|pmp:<gateway ip>|extip:<ip>)")
+}
+
+// loadSubChainConfig loads the node config from the file, the name, version, genesis,
+// network id and static nodes are replaced by the registration of the sub-chain.
+func loadSubChainConfig(configFile string, info *system.SubChainInfo, mainNetworkID string) (*node.Config, error) {
+	cmdConfig, err := GetConfigFromFile(configFile)
+	if err != nil {
+		return nil, err
+	}
+
+	coinbase, err := common.HexToAddress(cmdConfig.BasicConfig.Coinbase)
+	if err != nil {
+		return nil, fmt.Errorf("invalid coinbase, %s", err)
+	}
+
+	cmdConfig.BasicConfig.Name = info.Name
+	cmdConfig.BasicConfig.Version = info.Version
+	if len(cmdConfig.BasicConfig.DataDir) == 0 {
+		cmdConfig.BasicConfig.DataDir = info.Name
+	}
+
+	cmdConfig.GenesisConfig = *core.NewGenesisInfo(info.GenesisAccounts, int64(info.GenesisDifficulty), coinbase.Shard(),
+		info.CreateTimestamp, types.PowConsensus, nil)
+
+	// the same network id as the config generated by the client
+	cmdConfig.P2PConfig.NetworkID = fmt.Sprintf("%s.%d.%s", info.Name, info.Owner.Shard(), mainNetworkID)
+	cmdConfig.P2PConfig.StaticNodes = append(info.StaticNodes, cmdConfig.P2PConfig.StaticNodes...)
+
+	return convertConfig(cmdConfig, "")
+}
+
+// watchSubChain handles the events of the sub-chain on the main chain until the sub-chain
+// is deprecated. The updated static nodes are connected, and the miner is stopped when deprecated.
+func watchSubChain(client *rpc.Client, info *system.SubChainInfo, pool *core.EventPool, scdoService *scdo.ScdoService) {
+	for events := range pool.Events() {
+		for _, event := range events {
+			if len(event.Arguments) == 0 || event.Arguments[0] != info.Name {
+				continue
+			}
+
+			switch event.EventName {
+			case "SubChainUpdated":
+				updated, err := util.GetSubChain(client, info.Name)
+				if err != nil {
+					fmt.Printf("failed to get the updated sub-chain: %s\n", err)
+					continue
+				}
+
+				if updated.Version != info.Version {
+					fmt.Printf("sub-chain %s is updated to version %s, current version %s\n", info.Name, updated.Version, info.Version)
+				}
+
+				for _, n := range updated.StaticNodes {
+					if err = scdoService.P2PServer().AddPeer(n); err != nil {
+						fmt.Printf("failed to add the static node %s: %s\n", n, err)
+					}
+				}
+			case "SubChainDeprecated":
+				fmt.Printf("sub-chain %s is deprecated, stop the node\n", info.Name)
+				scdoService.Miner().Stop()
+				return
+			}
+		}
+	}
+}
+
+// rpcMainChain is the main chain of a remote node, the receipts only contain the
+// logs of the events of the contract, grouped by the transaction index.
+type rpcMainChain struct {
+	client     *rpc.Client
+	contract   common.Address
+	abiJSON    string
+	eventNames []string
+}
+
+// rpcLog is the log returned by the scdo_getLogs rpc
+type rpcLog struct {
+	Address string   `json:"address"`
+	Topics  []string `json:"topics"`
+	Data    string   `json:"data"`
+	TxIndex uint     `json:"transactionIndex"`
+}
+
+// Height returns the height of the main chain head
+func (c *rpcMainChain) Height() (uint64, error) {
+	var height uint64
+	err := c.client.Call(&height, "scdo_getBlockHeight")
+
+	return height, err
+}
+
+// Receipts returns the receipts of the main chain block of the height
+func (c *rpcMainChain) Receipts(height uint64) ([]*types.Receipt, error) {
+	receipts := make(map[uint]*types.Receipt)
+	for _, name := range c.eventNames {
+		var logs []rpcLog
+		if err := c.client.Call(&logs, "scdo_getLogs", int64(height), c.contract, c.abiJSON, name); err != nil {
+			return nil, fmt.Errorf("failed to get the logs of %s at height %d, %s", name, height, err)
+		}
+
+		for _, l := range logs {
+			log, err := l.toLog(height)
+			if err != nil {
+				return nil, err
+			}
+
+			receipt, ok := receipts[l.TxIndex]
+			if !ok {
+				receipt = &types.Receipt{}
+				receipts[l.TxIndex] = receipt
+			}
+			receipt.Logs = append(receipt.Logs, log)
+		}
+	}
+
+	indexes := make([]uint, 0, len(receipts))
+	for index := range receipts {
+		indexes = append(indexes, index)
+	}
+	sort.Slice(indexes, func(i, j int) bool { return indexes[i] < indexes[j] })
+
+	result := make([]*types.Receipt, len(indexes))
+	for i, index := range indexes {
+		result[i] = receipts[index]
+	}
+
+	return result, nil
+}
+
+func (l *rpcLog) toLog(height uint64) (*types.Log, error) {
+	address, err := common.HexToAddress(l.Address)
+	if err != nil {
+		return nil, err
+	}
+
+	topics := make([]common.Hash, len(l.Topics))
+	for i, topic := range l.Topics {
+		if topics[i], err = common.HexToHash(topic); err != nil {
+			return nil, err
+		}
+	}
+
+	data, err := hexutil.HexToBytes(l.Data)
+	if err != nil {
+		return nil, err
+	}
+
+	return &types.Log{
+		Address:     address,
+		Topics:      topics,
+		Data:        data,
+		BlockNumber: height,
+		TxIndex:     l.TxIndex,
+	}, nil
+}
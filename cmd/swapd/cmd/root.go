@@ -0,0 +1,153 @@
+/**
+*  @file
+*  @copyright defined in scdo/LICENSE
+ */
+
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/elcn233/go-scdo/swap"
+	"github.com/spf13/cobra"
+)
+
+var configFile *string
+
+// rootCmd represents the base command called without any subcommands
+var rootCmd = &cobra.Command{
+	Use:   "swapd",
+	Short: "atomic cross-chain swap daemon built on the HTLC contracts",
+	Long:  `use "swapd help [<command>]" for detailed usage`,
+}
+
+// Execute adds all child commands to the root command and sets flags appropriately.
+// This is called by main.main(). It only needs to happen once to the rootCmd.
+func Execute() {
+	if err := rootCmd.Execute(); err != nil {
+		fmt.Println(err.Error())
+		os.Exit(1)
+	}
+}
+
+func init() {
+	configFile = rootCmd.PersistentFlags().StringP("config", "c", "swapd.json", "swapd config file")
+}
+
+// config is the swapd config file, e.g.
+//
+//	{
+//		"dataDir": "swaps",
+//		"lockDuration": "48h",
+//		"chains": {
+//			"scdo": {"type": "scdo", "rpc": "127.0.0.1:8027", "keyfile": "scdo.key"},
+//			"scdo2": {"type": "scdo", "rpc": "127.0.0.1:8028", "keyfile": "scdo2.key"}
+//		}
+//	}
+//
+// The chains are created by the adapters registered by type, see swap.RegisterAdapter.
+type config struct {
+	DataDir       string                       `json:"dataDir"`
+	LockDuration  string                       `json:"lockDuration"`
+	SafetyMargin  string                       `json:"safetyMargin"`
+	RetryInterval string                       `json:"retryInterval"`
+	Chains        map[string]map[string]string `json:"chains"`
+}
+
+// unlocker is the chain adapter with a key to unlock before sending txs
+type unlocker interface {
+	Unlock(password string) error
+}
+
+func loadConfig() (*config, error) {
+	content, err := ioutil.ReadFile(*configFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the config file, %s", err)
+	}
+
+	var c config
+	if err = json.Unmarshal(content, &c); err != nil {
+		return nil, fmt.Errorf("invalid config file, %s", err)
+	}
+
+	// the relative data dir is relative to the config file
+	if c.DataDir == "" {
+		c.DataDir = "swaps"
+	}
+
+	if !filepath.IsAbs(c.DataDir) {
+		c.DataDir = filepath.Join(filepath.Dir(*configFile), c.DataDir)
+	}
+
+	return &c, nil
+}
+
+func (c *config) swapConfig() (swap.Config, error) {
+	result := swap.DefaultConfig
+	durations := []struct {
+		value string
+		field *time.Duration
+	}{
+		{c.LockDuration, &result.LockDuration},
+		{c.SafetyMargin, &result.SafetyMargin},
+		{c.RetryInterval, &result.RetryInterval},
+	}
+
+	for _, d := range durations {
+		if d.value == "" {
+			continue
+		}
+
+		parsed, err := time.ParseDuration(d.value)
+		if err != nil {
+			return result, fmt.Errorf("invalid duration %s, %s", d.value, err)
+		}
+		*d.field = parsed
+	}
+
+	if result.SafetyMargin*4 >= result.LockDuration {
+		return result, fmt.Errorf("the safety margin must be less than a quarter of the lock duration")
+	}
+
+	return result, nil
+}
+
+// newManager creates the swap manager with the chains of the config
+func newManager(c *config) (*swap.Manager, map[string]swap.ChainAdapter, error) {
+	swapConfig, err := c.swapConfig()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	adapters := make(map[string]swap.ChainAdapter)
+	for name, chain := range c.Chains {
+		adapter, err := swap.NewAdapter(chain["type"], chain)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to create the chain %s, %s", name, err)
+		}
+
+		adapters[name] = adapter
+	}
+
+	store, err := swap.NewStore(c.DataDir)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return swap.NewManager(swapConfig, store, adapters), adapters, nil
+}
+
+func printJSON(v interface{}) error {
+	encoded, err := json.MarshalIndent(v, "", "\t")
+	if err != nil {
+		return err
+	}
+
+	fmt.Println(string(encoded))
+	return nil
+}
@@ -0,0 +1,332 @@
+/**
+*  @file
+*  @copyright defined in scdo/LICENSE
+ */
+
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"strconv"
+	"sync"
+
+	"github.com/elcn233/go-scdo/cmd/util"
+	"github.com/elcn233/go-scdo/common"
+	"github.com/elcn233/go-scdo/common/hexutil"
+	"github.com/elcn233/go-scdo/common/keystore"
+	"github.com/elcn233/go-scdo/contract/system"
+	"github.com/elcn233/go-scdo/core/types"
+	"github.com/elcn233/go-scdo/rpc"
+	"github.com/elcn233/go-scdo/swap"
+)
+
+const (
+	defaultScdoGasPrice = 10
+	defaultScdoGasLimit = 200000
+	// the page size to list the HTLCs of the receiver
+	scdoListCount = 100
+)
+
+var errScdoLocked = errors.New("the key of the scdo chain is not unlocked")
+
+// scdoCallError is the error returned by the contract
+type scdoCallError string
+
+func (e scdoCallError) Error() string {
+	return string(e)
+}
+
+// scdoHTLC is the HTLC stored in the scdo HTLC contract, only the sender and amount of
+// its tx are decoded, as the tx is sent to the reserved contract address which is not a
+// valid account in JSON.
+type scdoHTLC struct {
+	Tx struct {
+		Data struct {
+			From   common.Address
+			Amount *big.Int
+		}
+	}
+	ID common.Hash
+	system.HashTimeLock
+	Refunded   bool
+	Withdrawed bool
+	Preimage   common.Bytes
+}
+
+func (h *scdoHTLC) lock() *swap.Lock {
+	amount := h.Amount
+	if amount == nil {
+		amount = h.Tx.Data.Amount
+	}
+
+	return &swap.Lock{
+		ID:       h.ID.Hex(),
+		HashLock: h.HashLock,
+		Sender:   h.Tx.Data.From.Hex(),
+		Receiver: h.To.Hex(),
+		Amount:   amount,
+		Expiry:   h.TimeLock,
+		Redeemed: h.Withdrawed,
+		Refunded: h.Refunded,
+		Preimage: h.Preimage,
+	}
+}
+
+// scdoAdapter is the chain adapter of the scdo HTLC system contract by rpc
+type scdoAdapter struct {
+	rpcAddr  string
+	keyFile  string
+	gasPrice *big.Int
+	gasLimit uint64
+
+	client    *rpc.Client
+	key       *keystore.Key
+	nextNonce uint64
+	lock      sync.Mutex
+}
+
+// newScdoAdapter creates the scdo adapter with the config of the keys:
+// rpc, keyfile, gasprice and gaslimit. The key is unlocked before sending txs.
+func newScdoAdapter(config map[string]string) (swap.ChainAdapter, error) {
+	a := &scdoAdapter{
+		rpcAddr:  config["rpc"],
+		keyFile:  config["keyfile"],
+		gasPrice: big.NewInt(defaultScdoGasPrice),
+		gasLimit: defaultScdoGasLimit,
+	}
+
+	if a.rpcAddr == "" || a.keyFile == "" {
+		return nil, errors.New("rpc and keyfile are required for the scdo chain")
+	}
+
+	if price, ok := config["gasprice"]; ok {
+		if _, ok = a.gasPrice.SetString(price, 10); !ok {
+			return nil, fmt.Errorf("invalid gas price %s", price)
+		}
+	}
+
+	if limit, ok := config["gaslimit"]; ok {
+		var err error
+		if a.gasLimit, err = strconv.ParseUint(limit, 10, 64); err != nil {
+			return nil, fmt.Errorf("invalid gas limit %s", limit)
+		}
+	}
+
+	return a, nil
+}
+
+// Unlock decrypts the key file and connects to the node
+func (a *scdoAdapter) Unlock(password string) error {
+	key, err := keystore.GetKey(a.keyFile, password)
+	if err != nil {
+		return err
+	}
+
+	client, err := rpc.DialTCP(context.Background(), a.rpcAddr)
+	if err != nil {
+		return err
+	}
+
+	a.key, a.client = key, client
+	return nil
+}
+
+func (a *scdoAdapter) Address() string {
+	if a.key == nil {
+		return ""
+	}
+
+	return a.key.Address.Hex()
+}
+
+func (a *scdoAdapter) Lock(hashLock []byte, receiver string, amount *big.Int, expiry int64) (string, error) {
+	to, err := common.HexToAddress(receiver)
+	if err != nil {
+		return "", err
+	}
+
+	payload, err := json.Marshal(&system.HashTimeLock{HashLock: hashLock, TimeLock: expiry, To: to})
+	if err != nil {
+		return "", err
+	}
+
+	tx, err := a.sendTx(system.CmdNewContract, payload, amount)
+	if err != nil {
+		return "", err
+	}
+
+	return tx.Hash.Hex(), nil
+}
+
+func (a *scdoAdapter) FindLock(hashLock []byte) (*swap.Lock, error) {
+	return a.findLock(system.CmdGetContractsByReceiver, hashLock)
+}
+
+func (a *scdoAdapter) FindOwnLock(hashLock []byte) (*swap.Lock, error) {
+	return a.findLock(system.CmdGetContractsBySender, hashLock)
+}
+
+// findLock returns the unspent HTLC with the hash lock in the ids of the swap account listed by the method
+func (a *scdoAdapter) findLock(method byte, hashLock []byte) (*swap.Lock, error) {
+	if a.key == nil {
+		return nil, errScdoLocked
+	}
+
+	for offset := uint64(0); ; offset += scdoListCount {
+		request, err := json.Marshal(&system.HTLCListRequest{Address: a.key.Address, Offset: offset, Count: scdoListCount})
+		if err != nil {
+			return nil, err
+		}
+
+		result, err := a.call(method, request)
+		if err != nil {
+			return nil, err
+		}
+
+		var ids []common.Hash
+		if err = json.Unmarshal(result, &ids); err != nil {
+			return nil, err
+		}
+
+		for _, id := range ids {
+			data, err := a.getHTLC(id)
+			if err != nil {
+				return nil, err
+			}
+
+			// the swap only uses the time locks
+			if data != nil && data.LockHeight == 0 && bytes.Equal(data.HashLock, hashLock) && !data.Withdrawed && !data.Refunded {
+				return data.lock(), nil
+			}
+		}
+
+		if len(ids) < scdoListCount {
+			return nil, nil
+		}
+	}
+}
+
+func (a *scdoAdapter) GetLock(id string) (*swap.Lock, error) {
+	hash, err := common.HexToHash(id)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := a.getHTLC(hash)
+	if err != nil || data == nil {
+		return nil, err
+	}
+
+	if data.LockHeight > 0 {
+		return nil, fmt.Errorf("HTLC %s is locked by height", id)
+	}
+
+	return data.lock(), nil
+}
+
+// getHTLC returns the HTLC of the id, or nil if the tx of the HTLC is not packed yet
+func (a *scdoAdapter) getHTLC(id common.Hash) (*scdoHTLC, error) {
+	result, err := a.call(system.CmdGetContract, id.Bytes())
+	if _, ok := err.(scdoCallError); ok {
+		return nil, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	var data scdoHTLC
+	if err = json.Unmarshal(result, &data); err != nil {
+		return nil, fmt.Errorf("invalid HTLC %s, %s", id.Hex(), err)
+	}
+
+	data.ID = id
+	return &data, nil
+}
+
+func (a *scdoAdapter) Redeem(id string, preimage []byte) error {
+	hash, err := common.HexToHash(id)
+	if err != nil {
+		return err
+	}
+
+	payload, err := json.Marshal(&system.Withdrawing{Hash: hash, Preimage: preimage})
+	if err != nil {
+		return err
+	}
+
+	_, err = a.sendTx(system.CmdWithdraw, payload, big.NewInt(0))
+	return err
+}
+
+func (a *scdoAdapter) Refund(id string) error {
+	hash, err := common.HexToHash(id)
+	if err != nil {
+		return err
+	}
+
+	_, err = a.sendTx(system.CmdRefund, hash.Bytes(), big.NewInt(0))
+	return err
+}
+
+// call executes the read only command of the HTLC contract on the latest state
+func (a *scdoAdapter) call(method byte, input []byte) ([]byte, error) {
+	if a.client == nil {
+		return nil, errScdoLocked
+	}
+
+	payload := append([]byte{method}, input...)
+	receipt, err := util.CallContract(a.client, system.HashTimeLockContractAddress.Hex(), hexutil.BytesToHex(payload), -1)
+	if err != nil {
+		return nil, err
+	}
+
+	result, _ := receipt["result"].(string)
+	if failed, _ := receipt["failed"].(bool); failed {
+		return nil, scdoCallError(result)
+	}
+
+	return hexutil.HexToBytes(result)
+}
+
+// sendTx sends the tx of the HTLC contract, the nonce is tracked locally since
+// the txs in the pool are not counted by the account nonce of the chain.
+func (a *scdoAdapter) sendTx(method byte, input []byte, amount *big.Int) (*types.Transaction, error) {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+
+	if a.key == nil {
+		return nil, errScdoLocked
+	}
+
+	nonce, err := util.GetAccountNonce(a.client, a.key.Address, "", -1)
+	if err != nil {
+		return nil, err
+	}
+
+	if nonce < a.nextNonce {
+		nonce = a.nextNonce
+	}
+
+	payload := append([]byte{method}, input...)
+	tx, err := util.GenerateTx(a.key.PrivateKey, &a.key.Address, system.HashTimeLockContractAddress, amount, a.gasPrice, a.gasLimit, nonce, payload)
+	if err != nil {
+		return nil, err
+	}
+
+	if ok, err := util.SendTx(a.client, tx); err != nil || !ok {
+		return nil, fmt.Errorf("failed to send tx, %v", err)
+	}
+
+	a.nextNonce = nonce + 1
+	return tx, nil
+}
+
+func init() {
+	swap.RegisterAdapter("scdo", newScdoAdapter)
+}
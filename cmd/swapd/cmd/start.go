@@ -0,0 +1,83 @@
+/**
+*  @file
+*  @copyright defined in scdo/LICENSE
+ */
+
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/elcn233/go-scdo/common"
+	"github.com/spf13/cobra"
+)
+
+var startInterval *time.Duration
+
+// startCmd represents the start command
+var startCmd = &cobra.Command{
+	Use:   "start",
+	Short: "start the swap daemon",
+	Long: `start the swap daemon to process the swaps in the data dir until interrupted,
+the keys of the chains are unlocked with the passwords at the start`,
+	Run: func(cmd *cobra.Command, args []string) {
+		if err := startDaemon(); err != nil {
+			fmt.Println(err.Error())
+			os.Exit(1)
+		}
+	},
+}
+
+func startDaemon() error {
+	c, err := loadConfig()
+	if err != nil {
+		return err
+	}
+
+	manager, adapters, err := newManager(c)
+	if err != nil {
+		return err
+	}
+
+	for name, adapter := range adapters {
+		u, ok := adapter.(unlocker)
+		if !ok {
+			continue
+		}
+
+		fmt.Printf("unlock the key of chain %s\n", name)
+		pass, err := common.GetPassword()
+		if err != nil {
+			return fmt.Errorf("failed to get password, %s", err)
+		}
+
+		if err = u.Unlock(pass); err != nil {
+			return fmt.Errorf("failed to unlock chain %s, %s", name, err)
+		}
+
+		fmt.Printf("chain %s account: %s\n", name, adapter.Address())
+	}
+
+	quit := make(chan struct{})
+	interrupt := make(chan os.Signal, 1)
+	signal.Notify(interrupt, syscall.SIGINT, syscall.SIGTERM)
+	go func() {
+		<-interrupt
+		close(quit)
+	}()
+
+	fmt.Printf("swap daemon started, data dir %s\n", c.DataDir)
+	manager.Run(*startInterval, quit)
+	fmt.Println("swap daemon stopped")
+	return nil
+}
+
+func init() {
+	rootCmd.AddCommand(startCmd)
+
+	startInterval = startCmd.Flags().Duration("interval", 30*time.Second, "interval to process the swaps")
+}
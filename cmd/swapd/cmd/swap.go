@@ -0,0 +1,166 @@
+/**
+*  @file
+*  @copyright defined in scdo/LICENSE
+ */
+
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"time"
+
+	"github.com/elcn233/go-scdo/common/hexutil"
+	"github.com/elcn233/go-scdo/swap"
+	"github.com/spf13/cobra"
+)
+
+var (
+	ourChain     string
+	ourAmount    string
+	counterparty string
+	theirChain   string
+	theirAmount  string
+	hashLock     *string
+	deadline     *time.Duration
+	swapIDFlag   *string
+)
+
+// initiateCmd represents the initiate command
+var initiateCmd = &cobra.Command{
+	Use:   "initiate",
+	Short: "initiate a swap with a new preimage",
+	Long: `initiate a swap with a new preimage, the running daemon locks our amount
+on our chain to the counterparty, and redeems the lock of the counterparty on their chain.
+Send the printed hash lock to the counterparty to participate in the swap.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		exitOnError(addSwap(swap.RoleInitiator))
+	},
+}
+
+// participateCmd represents the participate command
+var participateCmd = &cobra.Command{
+	Use:   "participate",
+	Short: "participate in a swap with the hash lock of the initiator",
+	Long: `participate in a swap with the hash lock of the initiator, the running daemon
+waits for the lock of the initiator on their chain, locks our amount on our chain, and
+redeems the lock of the initiator once the preimage is revealed.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		exitOnError(addSwap(swap.RoleParticipant))
+	},
+}
+
+// listCmd represents the list command
+var listCmd = &cobra.Command{
+	Use:   "list",
+	Short: "list the swaps, or show a swap by id",
+	Run: func(cmd *cobra.Command, args []string) {
+		exitOnError(listSwaps())
+	},
+}
+
+func addSwap(role swap.Role) error {
+	c, err := loadConfig()
+	if err != nil {
+		return err
+	}
+
+	manager, _, err := newManager(c)
+	if err != nil {
+		return err
+	}
+
+	ours, ok := new(big.Int).SetString(ourAmount, 10)
+	if !ok {
+		return errors.New("invalid amount")
+	}
+
+	theirs, ok := new(big.Int).SetString(theirAmount, 10)
+	if !ok {
+		return errors.New("invalid their amount")
+	}
+
+	var s *swap.Swap
+	if role == swap.RoleInitiator {
+		s, err = manager.Initiate(ourChain, ours, counterparty, theirChain, theirs)
+	} else {
+		var hash []byte
+		if hash, err = hexutil.HexToBytes(*hashLock); err != nil {
+			return fmt.Errorf("invalid hash lock, %s", err)
+		}
+
+		s, err = manager.Participate(hash, ourChain, ours, counterparty, theirChain, theirs, time.Now().Add(*deadline))
+	}
+
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("swap added, hash lock: %s\n", hexutil.BytesToHex(s.HashLock))
+	return nil
+}
+
+func listSwaps() error {
+	c, err := loadConfig()
+	if err != nil {
+		return err
+	}
+
+	store, err := swap.NewStore(c.DataDir)
+	if err != nil {
+		return err
+	}
+
+	if *swapIDFlag != "" {
+		s, err := store.Get(*swapIDFlag)
+		if err != nil {
+			return err
+		}
+
+		return printJSON(s)
+	}
+
+	swaps, err := store.All()
+	if err != nil {
+		return err
+	}
+
+	for _, s := range swaps {
+		fmt.Println(s)
+	}
+
+	return nil
+}
+
+func exitOnError(err error) {
+	if err != nil {
+		fmt.Println(err.Error())
+		os.Exit(1)
+	}
+}
+
+func init() {
+	for _, cmd := range []*cobra.Command{initiateCmd, participateCmd} {
+		rootCmd.AddCommand(cmd)
+
+		// the flags of both commands share the values, only one command runs
+		cmd.Flags().StringVar(&ourChain, "our", "scdo", "name of our chain in the config, where our amount is locked")
+		cmd.Flags().StringVar(&ourAmount, "amount", "", "our amount to lock, in the smallest unit of our chain")
+		cmd.Flags().StringVar(&counterparty, "to", "", "address of the counterparty on our chain")
+		cmd.Flags().StringVar(&theirChain, "their", "", "name of their chain in the config, where the counterparty locks")
+		cmd.Flags().StringVar(&theirAmount, "their-amount", "", "min amount of the counterparty lock, in the smallest unit of their chain")
+		cmd.MarkFlagRequired("amount")
+		cmd.MarkFlagRequired("to")
+		cmd.MarkFlagRequired("their")
+		cmd.MarkFlagRequired("their-amount")
+	}
+
+	hashLock = participateCmd.Flags().String("hash", "", "hash lock of the initiator in hex")
+	deadline = participateCmd.Flags().Duration("deadline", 6*time.Hour, "abort if the initiator does not lock in the duration")
+	participateCmd.MarkFlagRequired("hash")
+
+	rootCmd.AddCommand(listCmd)
+	swapIDFlag = listCmd.Flags().String("id", "", "swap id, which is the hash lock in hex")
+}
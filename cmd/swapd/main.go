@@ -0,0 +1,12 @@
+/**
+*  @file
+*  @copyright defined in scdo/LICENSE
+ */
+
+package main
+
+import "github.com/elcn233/go-scdo/cmd/swapd/cmd"
+
+func main() {
+	cmd.Execute()
+}
@@ -43,7 +43,7 @@ var generateKeystoreCmd = &cobra.Command{
 	Long: `For example:
 	tool.exe genkeys`,
 	Run: func(cmd *cobra.Command, args []string) {
-		shardSet := set.New()
+		shardSet := set.New(set.ThreadSafe)
 		for i := 1; i <= shard; i++ {
 			shardSet.Add(uint(i))
 		}
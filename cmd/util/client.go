@@ -7,15 +7,22 @@ package util
 
 import (
 	"crypto/ecdsa"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"math/big"
 
 	"github.com/elcn233/go-scdo/api"
 	"github.com/elcn233/go-scdo/common"
+	"github.com/elcn233/go-scdo/common/hexutil"
+	"github.com/elcn233/go-scdo/contract/system"
 	"github.com/elcn233/go-scdo/core/types"
 	"github.com/elcn233/go-scdo/rpc"
+	"github.com/elcn233/go-scdo/trie"
 )
 
+var errSubChainInfo = errors.New("failed to get sub-chain information")
+
 // GetAccountNonce get account nonce by account
 func GetAccountNonce(client *rpc.Client, account common.Address, hexHash string, height int64) (uint64, error) {
 	var nonce uint64
@@ -86,3 +93,89 @@ func GetNetworkID(client *rpc.Client) (string, error) {
 
 	return networkID, err
 }
+
+// GetSubChain get the sub-chain registration by name from the sub-chain system contract
+func GetSubChain(client *rpc.Client, name string) (*system.SubChainInfo, error) {
+	if err := system.ValidateDomainName([]byte(name)); err != nil {
+		return nil, err
+	}
+
+	bytesSubChainInfo, err := callSubChainContract(client, system.CmdSubChainQuery, []byte(name))
+	if err != nil {
+		return nil, err
+	}
+
+	if len(bytesSubChainInfo) == 0 {
+		return nil, fmt.Errorf("sub-chain %s does not exist", name)
+	}
+
+	var subChainInfo system.SubChainInfo
+	if err = json.Unmarshal(bytesSubChainInfo, &subChainInfo); err != nil {
+		return nil, err
+	}
+
+	return &subChainInfo, nil
+}
+
+// GetSubChainAnchor get the anchored sub-chain state root at the specified height,
+// or the latest one if height is 0
+func GetSubChainAnchor(client *rpc.Client, name string, height uint64) (*system.SubChainAnchor, error) {
+	query, err := json.Marshal(&system.SubChainAnchorQuery{Name: name, Height: height})
+	if err != nil {
+		return nil, err
+	}
+
+	bytesAnchor, err := callSubChainContract(client, system.CmdSubChainGetAnchor, query)
+	if err != nil {
+		return nil, err
+	}
+
+	var anchor system.SubChainAnchor
+	if err = json.Unmarshal(bytesAnchor, &anchor); err != nil {
+		return nil, err
+	}
+
+	return &anchor, nil
+}
+
+// callSubChainContract call the sub-chain system contract and return the result
+func callSubChainContract(client *rpc.Client, cmd byte, payload []byte) ([]byte, error) {
+	payloadBytes := append([]byte{cmd}, payload...)
+	mapReceipt, err := CallContract(client, system.SubChainContractAddress.Hex(), hexutil.BytesToHex(payloadBytes), -1)
+	if err != nil {
+		return nil, err
+	}
+
+	resultFlag, ok := mapReceipt["failed"].(bool)
+	if !ok {
+		return nil, errSubChainInfo
+	}
+	result, ok := mapReceipt["result"].(string)
+	if !ok {
+		return nil, errSubChainInfo
+	}
+	if resultFlag {
+		return nil, fmt.Errorf("failed to get sub-chain information, %s", result)
+	}
+
+	return hexutil.HexToBytes(result)
+}
+
+// AccountProof is the merkle proof of account in the state trie of a block
+type AccountProof struct {
+	Account   string
+	BlockHash common.Hash
+	Height    uint64
+	StateRoot common.Hash
+	Proof     []trie.ProofNode
+}
+
+// GetAccountProof get the merkle proof of account by block hash or block height
+func GetAccountProof(client *rpc.Client, account common.Address, hexHash string, height int64) (*AccountProof, error) {
+	var proof AccountProof
+	if err := client.Call(&proof, "scdo_getAccountProof", account, hexHash, height); err != nil {
+		return nil, err
+	}
+
+	return &proof, nil
+}
@@ -6,6 +6,9 @@
 package util
 
 import (
+	"github.com/elcn233/go-scdo/consensus/clique"
+	"github.com/elcn233/go-scdo/consensus/dev"
+	"github.com/elcn233/go-scdo/consensus/istanbul"
 	"github.com/elcn233/go-scdo/core"
 	"github.com/elcn233/go-scdo/log/comm"
 	"github.com/elcn233/go-scdo/metrics"
@@ -38,4 +41,13 @@ type Config struct {
 
 	// genesis config info
 	GenesisConfig core.GenesisInfo `json:"genesis"`
+
+	// istanbul consensus config info
+	IstanbulConfig *istanbul.Config `json:"istanbul"`
+
+	// clique consensus config info
+	CliqueConfig *clique.Config `json:"clique"`
+
+	// dev consensus config info
+	DevConfig *dev.Config `json:"dev"`
 }
@@ -58,6 +58,48 @@ const (
 	// SmartContractNonceFixHeight fix smart contract nonce bug when user use setNonce
 	SmartContractNonceFixHeight = ScdoForkHeight
 
+	// SystemContractForkHeight after this height the upgraded system contracts are activated,
+	// the blocks before are executed with the original system contract commands
+	SystemContractForkHeight = 3600000
+
+	// ValidatorContractForkHeight after this height the istanbul validator governance contract is activated
+	ValidatorContractForkHeight = SystemContractForkHeight
+
+	// DomainNameForkHeight after this height the domain names have expiry, records and reverse lookup
+	DomainNameForkHeight = SystemContractForkHeight
+
+	// BTCRelayForkHeight after this height the btc relay verifies the headers and merkle proofs in the new storage format
+	BTCRelayForkHeight = SystemContractForkHeight
+
+	// BTCRelayCheckpointHash the hash of the btc block the btc relay starts from, the first header
+	// stored in the relay must be the checkpoint and the other headers must extend it
+	BTCRelayCheckpointHash = "0x000000000019d6689c085ae165831e934ff763ae46a2a6c172b3f1b60a8ce26f"
+
+	// BTCRelayCheckpointHeight the height of the btc relay checkpoint
+	BTCRelayCheckpointHeight = 0
+
+	// BTCRelayCheckpointBits the difficulty bits of the btc relay checkpoint
+	BTCRelayCheckpointBits = 0x1d00ffff
+
+	// BTCRelayCheckpointEpochStart the timestamp of the first block of the retarget interval of the btc relay checkpoint
+	BTCRelayCheckpointEpochStart = 1231006505
+
+	// HTLCForkHeight after this height the HTLCs are indexed, logged and could be locked by height
+	HTLCForkHeight = SystemContractForkHeight
+
+	// MasternodeForkHeight after this height the masternodes are listed, slashable and share the block reward
+	MasternodeForkHeight = SystemContractForkHeight
+
+	// SubChainForkHeight after this height the sub-chains have validators, logs, anchors and funds
+	SubChainForkHeight = SystemContractForkHeight
+
+	// SystemContractPrecompileForkHeight after this height system contracts are callable in EVM
+	SystemContractPrecompileForkHeight = SystemContractForkHeight
+
+	// IstanbulMissedProposalLimit the max number of proposals an istanbul validator could miss in an epoch
+	// before being removed on the checkpoint block, which is enforced after ValidatorContractForkHeight
+	IstanbulMissedProposalLimit = 1000
+
 	// LightChainDir lightchain data directory based on config.DataRoot
 	LightChainDir = "/db/lightchain"
 
@@ -73,6 +115,15 @@ const (
 	// BFT data folder
 	BFTDataFolder = "bftdata"
 
+	// DevEngine instant-seal miner algorithm for development
+	DevEngine = "dev"
+
+	// CliqueEngine proof-of-authority miner algorithm
+	CliqueEngine = "clique"
+
+	// Clique data folder
+	CliqueDataFolder = "cliquedata"
+
 	// EVMStackLimit increase evm stack limit to 8192
 	EVMStackLimit = 8192
 
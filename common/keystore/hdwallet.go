@@ -0,0 +1,258 @@
+/**
+*  @file
+*  @copyright defined in scdo/LICENSE
+ */
+
+package keystore
+
+import (
+	"crypto/ecdsa"
+	"crypto/hmac"
+	"crypto/sha512"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math/big"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/elcn233/go-scdo/common"
+	"github.com/elcn233/go-scdo/crypto"
+	"github.com/elcn233/go-scdo/crypto/secp256k1"
+	"github.com/ethereum/go-ethereum/common/math"
+)
+
+const (
+	// HardenedKeyStart is the index of the first hardened child key
+	HardenedKeyStart uint32 = 0x80000000
+
+	// ScdoCoinType is the BIP-44 coin type of the scdo accounts
+	ScdoCoinType uint32 = 2021
+
+	bip44Purpose = 44
+)
+
+var (
+	errInvalidSeed  = errors.New("seed length must be between 16 and 64 bytes")
+	errInvalidChild = errors.New("invalid child key, try the next index")
+	errInvalidPath  = errors.New("invalid derivation path")
+
+	masterKeyHMACKey = []byte("Bitcoin seed")
+)
+
+// ExtendedKey is a BIP-32 extended private key
+type ExtendedKey struct {
+	PrivateKey *ecdsa.PrivateKey
+	ChainCode  []byte
+	Depth      uint8
+	Index      uint32 // index of the key in its parent
+}
+
+// NewMasterKey creates the BIP-32 master key of the seed
+func NewMasterKey(seed []byte) (*ExtendedKey, error) {
+	if len(seed) < 16 || len(seed) > 64 {
+		return nil, errInvalidSeed
+	}
+
+	mac := hmac.New(sha512.New, masterKeyHMACKey)
+	mac.Write(seed)
+	sum := mac.Sum(nil)
+
+	key, err := crypto.ToECDSA(sum[:32])
+	if err != nil {
+		return nil, err
+	}
+
+	return &ExtendedKey{PrivateKey: key, ChainCode: sum[32:]}, nil
+}
+
+// Child derives the child key of the index, the index not less than
+// HardenedKeyStart derives a hardened key.
+func (k *ExtendedKey) Child(index uint32) (*ExtendedKey, error) {
+	data := make([]byte, 0, 37)
+	if index >= HardenedKeyStart {
+		data = append(data, 0)
+		data = append(data, math.PaddedBigBytes(k.PrivateKey.D, 32)...)
+	} else {
+		data = append(data, secp256k1.CompressPubkey(k.PrivateKey.X, k.PrivateKey.Y)...)
+	}
+	data = binary.BigEndian.AppendUint32(data, index)
+
+	mac := hmac.New(sha512.New, k.ChainCode)
+	mac.Write(data)
+	sum := mac.Sum(nil)
+
+	n := crypto.S256().Params().N
+	il := new(big.Int).SetBytes(sum[:32])
+	if il.Cmp(n) >= 0 {
+		return nil, errInvalidChild
+	}
+
+	d := il.Add(il, k.PrivateKey.D)
+	d.Mod(d, n)
+	if d.Sign() == 0 {
+		return nil, errInvalidChild
+	}
+
+	key, err := crypto.ToECDSA(math.PaddedBigBytes(d, 32))
+	if err != nil {
+		return nil, err
+	}
+
+	return &ExtendedKey{
+		PrivateKey: key,
+		ChainCode:  sum[32:],
+		Depth:      k.Depth + 1,
+		Index:      index,
+	}, nil
+}
+
+// Derive derives the key of the path relative to the key
+func (k *ExtendedKey) Derive(path []uint32) (*ExtendedKey, error) {
+	key := k
+	for _, index := range path {
+		child, err := key.Child(index)
+		if err != nil {
+			return nil, err
+		}
+		key = child
+	}
+
+	return key, nil
+}
+
+// ParseDerivationPath parses the path like m/44'/2021'/1'/0/0, the index with ' or h is hardened
+func ParseDerivationPath(path string) ([]uint32, error) {
+	parts := strings.Split(strings.TrimSpace(path), "/")
+	if len(parts) == 0 || parts[0] != "m" {
+		return nil, errInvalidPath
+	}
+
+	result := make([]uint32, 0, len(parts)-1)
+	for _, part := range parts[1:] {
+		hardened := strings.HasSuffix(part, "'") || strings.HasSuffix(part, "h")
+		if hardened {
+			part = part[:len(part)-1]
+		}
+
+		index, err := strconv.ParseUint(part, 10, 32)
+		if err != nil || uint32(index) >= HardenedKeyStart {
+			return nil, fmt.Errorf("%s, bad index %s", errInvalidPath, part)
+		}
+
+		if hardened {
+			index += uint64(HardenedKeyStart)
+		}
+		result = append(result, uint32(index))
+	}
+
+	return result, nil
+}
+
+// DerivationPath returns the BIP-44 path of the account in the shard, the
+// shard is the account level of the path: m/44'/2021'/<shard>'/0/<index>
+func DerivationPath(shard uint, index uint32) string {
+	return fmt.Sprintf("m/%d'/%d'/%d'/0/%d", bip44Purpose, ScdoCoinType, shard, index)
+}
+
+// HDAccount is an account derived by the HD wallet
+type HDAccount struct {
+	Address common.Address `json:"address"`
+	Shard   uint           `json:"shard"`
+	Index   uint32         `json:"index"`
+	Path    string         `json:"path"`
+}
+
+// HDWallet is a BIP-32/39/44 hierarchical deterministic wallet. The accounts of
+// each shard are derived in order, so that a wallet restored from the mnemonic
+// finds them again by deriving the same number of accounts.
+type HDWallet struct {
+	seed   []byte
+	master *ExtendedKey
+
+	// Accounts is the number of the derived accounts of each shard
+	Accounts map[uint]uint32
+}
+
+// NewHDWallet creates the HD wallet of the BIP-39 seed
+func NewHDWallet(seed []byte) (*HDWallet, error) {
+	master, err := NewMasterKey(seed)
+	if err != nil {
+		return nil, err
+	}
+
+	return &HDWallet{
+		seed:     common.CopyBytes(seed),
+		master:   master,
+		Accounts: make(map[uint]uint32),
+	}, nil
+}
+
+// NewHDWalletFromMnemonic creates the HD wallet of the mnemonic protected by the passphrase
+func NewHDWalletFromMnemonic(mnemonic, passphrase string) (*HDWallet, error) {
+	if err := ValidateMnemonic(mnemonic); err != nil {
+		return nil, err
+	}
+
+	return NewHDWallet(MnemonicToSeed(mnemonic, passphrase))
+}
+
+// Derive derives the key of the account with the index in the shard
+func (w *HDWallet) Derive(shard uint, index uint32) (*Key, error) {
+	if !common.ValidShard(shard) {
+		return nil, fmt.Errorf("invalid shard %d", shard)
+	}
+
+	path, err := ParseDerivationPath(DerivationPath(shard, index))
+	if err != nil {
+		return nil, err
+	}
+
+	key, err := w.master.Derive(path)
+	if err != nil {
+		return nil, err
+	}
+
+	addr, err := crypto.GetAddress(&key.PrivateKey.PublicKey, shard)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Key{Address: *addr, PrivateKey: key.PrivateKey}, nil
+}
+
+// NextKey derives the next account in the shard
+func (w *HDWallet) NextKey(shard uint) (*Key, *HDAccount, error) {
+	index := w.Accounts[shard]
+	key, err := w.Derive(shard, index)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	w.Accounts[shard] = index + 1
+	return key, &HDAccount{Address: key.Address, Shard: shard, Index: index, Path: DerivationPath(shard, index)}, nil
+}
+
+// ListAccounts returns the derived accounts ordered by shard and index
+func (w *HDWallet) ListAccounts() ([]*HDAccount, error) {
+	shards := make([]uint, 0, len(w.Accounts))
+	for shard := range w.Accounts {
+		shards = append(shards, shard)
+	}
+	sort.Slice(shards, func(i, j int) bool { return shards[i] < shards[j] })
+
+	var accounts []*HDAccount
+	for _, shard := range shards {
+		for index := uint32(0); index < w.Accounts[shard]; index++ {
+			key, err := w.Derive(shard, index)
+			if err != nil {
+				return nil, err
+			}
+
+			accounts = append(accounts, &HDAccount{Address: key.Address, Shard: shard, Index: index, Path: DerivationPath(shard, index)})
+		}
+	}
+
+	return accounts, nil
+}
@@ -0,0 +1,181 @@
+/**
+*  @file
+*  @copyright defined in scdo/LICENSE
+ */
+
+package keystore
+
+import (
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/elcn233/go-scdo/common"
+	"github.com/elcn233/go-scdo/crypto"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_Mnemonic_Vectors(t *testing.T) {
+	// BIP-39 test vectors with passphrase TREZOR
+	vectors := []struct {
+		entropy  string
+		mnemonic string
+		seed     string
+	}{
+		{
+			"00000000000000000000000000000000",
+			"abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon about",
+			"c55257c360c07c72029aebc1b53c05ed0362ada38ead3e3e9efa3708e53495531f09a6987599d18264c1e1c92f2cf141630c7a3c4ab7c81b2f001698e7463b04",
+		},
+		{
+			"7f7f7f7f7f7f7f7f7f7f7f7f7f7f7f7f",
+			"legal winner thank year wave sausage worth useful legal winner thank yellow",
+			"2e8905819b8723fe2c1d161860e5ee1830318dbf49a83bd451cfb8440c28bd6fa457fe1296106559a3c80937a1c1069be3a3a5bd381ee6260e8d9739fce1f607",
+		},
+		{
+			"ffffffffffffffffffffffffffffffff",
+			"zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo wrong",
+			"ac27495480225222079d7be181583751e86f571027b0497b5b5d11218e0a8a13332572917f0f8e5a589620c6f15b11c61dee327651a14c34e18231052e48c069",
+		},
+		{
+			"0000000000000000000000000000000000000000000000000000000000000000",
+			"abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon art",
+			"bda85446c68413707090a52022edd26a1c9462295029f2e60cd7c4f2bbd3097170af7a4d73245cafa9c3cca8d561a7c3de6f5d4a10be8ed2a5e608d68f92fcc8",
+		},
+	}
+
+	for _, v := range vectors {
+		entropy, _ := hex.DecodeString(v.entropy)
+		mnemonic, err := EntropyToMnemonic(entropy)
+		assert.Equal(t, err, nil)
+		assert.Equal(t, mnemonic, v.mnemonic)
+
+		decoded, err := MnemonicToEntropy(mnemonic)
+		assert.Equal(t, err, nil)
+		assert.Equal(t, decoded, entropy)
+
+		assert.Equal(t, hex.EncodeToString(MnemonicToSeed(mnemonic, "TREZOR")), v.seed)
+	}
+}
+
+func Test_Mnemonic_Invalid(t *testing.T) {
+	mnemonic, err := NewMnemonic(MnemonicWords24)
+	assert.Equal(t, err, nil)
+	assert.Equal(t, ValidateMnemonic(mnemonic), nil)
+
+	_, err = NewMnemonic(13)
+	assert.Equal(t, err, errInvalidMnemonicWords)
+
+	// the last word is the checksum
+	err = ValidateMnemonic("abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon")
+	assert.Equal(t, err, errMnemonicChecksum)
+
+	err = ValidateMnemonic("abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon scdo")
+	assert.Equal(t, err != nil, true)
+}
+
+func Test_ExtendedKey_Vector(t *testing.T) {
+	// BIP-32 test vector 1
+	seed, _ := hex.DecodeString("000102030405060708090a0b0c0d0e0f")
+	master, err := NewMasterKey(seed)
+	assert.Equal(t, err, nil)
+	assert.Equal(t, hex.EncodeToString(master.ChainCode), "873dff81c02f525623fd1fe5167eac3a55a049de3d314bb42ee227ffed37d508")
+
+	path, err := ParseDerivationPath("m/0'")
+	assert.Equal(t, err, nil)
+	key, err := master.Derive(path)
+	assert.Equal(t, err, nil)
+	assert.Equal(t, hex.EncodeToString(crypto.FromECDSA(key.PrivateKey)), "edb2e14f9ee77d26dd93b4ecede8d16ed408ce149b6cd80b0715a2d911a0afea")
+	assert.Equal(t, hex.EncodeToString(key.ChainCode), "47fdacbd0f1097043b78c63c20c34ef4ed9a111d980047ad16282c7ae6236141")
+
+	// non-hardened child
+	key, err = key.Child(1)
+	assert.Equal(t, err, nil)
+	assert.Equal(t, hex.EncodeToString(crypto.FromECDSA(key.PrivateKey)), "3c6cb8d0f6a264c91ea8b5030fadaa8e538b020f0a387421a12de9319dc93368")
+	assert.Equal(t, hex.EncodeToString(key.ChainCode), "2a7857631386ba23dacac34180dd1983734e444fdbf774041578e9b6adb37c19")
+	assert.Equal(t, key.Depth, uint8(2))
+}
+
+func Test_ParseDerivationPath(t *testing.T) {
+	path, err := ParseDerivationPath(DerivationPath(2, 5))
+	assert.Equal(t, err, nil)
+	assert.Equal(t, path, []uint32{HardenedKeyStart + 44, HardenedKeyStart + ScdoCoinType, HardenedKeyStart + 2, 0, 5})
+
+	_, err = ParseDerivationPath("44'/0")
+	assert.Equal(t, err, errInvalidPath)
+
+	_, err = ParseDerivationPath("m/x")
+	assert.Equal(t, err != nil, true)
+}
+
+func Test_HDWallet_NextKey(t *testing.T) {
+	mnemonic := "abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon about"
+	w, err := NewHDWalletFromMnemonic(mnemonic, "")
+	assert.Equal(t, err, nil)
+
+	key1, account1, err := w.NextKey(1)
+	assert.Equal(t, err, nil)
+	assert.Equal(t, key1.Address.Shard(), uint(1))
+	assert.Equal(t, account1.Path, "m/44'/2021'/1'/0/0")
+
+	key2, account2, err := w.NextKey(2)
+	assert.Equal(t, err, nil)
+	assert.Equal(t, key2.Address.Shard(), uint(2))
+	assert.Equal(t, account2.Index, uint32(0))
+
+	key3, account3, err := w.NextKey(1)
+	assert.Equal(t, err, nil)
+	assert.Equal(t, account3.Index, uint32(1))
+	assert.Equal(t, key3.Address == key1.Address, false)
+
+	_, _, err = w.NextKey(0)
+	assert.Equal(t, err != nil, true)
+
+	accounts, err := w.ListAccounts()
+	assert.Equal(t, err, nil)
+	assert.Equal(t, len(accounts), 3)
+	assert.Equal(t, accounts[0].Address, key1.Address)
+	assert.Equal(t, accounts[1].Address, key3.Address)
+	assert.Equal(t, accounts[2].Address, key2.Address)
+
+	// the restored wallet derives the same accounts
+	restored, err := NewHDWalletFromMnemonic(mnemonic, "")
+	assert.Equal(t, err, nil)
+	key, err := restored.Derive(1, 1)
+	assert.Equal(t, err, nil)
+	assert.Equal(t, key.Address, key3.Address)
+	assert.Equal(t, key.PrivateKey, key3.PrivateKey)
+
+	// the passphrase changes the seed
+	other, err := NewHDWalletFromMnemonic(mnemonic, "pass")
+	assert.Equal(t, err, nil)
+	key, err = other.Derive(1, 0)
+	assert.Equal(t, err, nil)
+	assert.Equal(t, key.Address == key1.Address, false)
+}
+
+func Test_HDWallet_Store(t *testing.T) {
+	mnemonic, err := NewMnemonic(MnemonicWords12)
+	assert.Equal(t, err, nil)
+	w, err := NewHDWalletFromMnemonic(mnemonic, "")
+	assert.Equal(t, err, nil)
+	_, account, err := w.NextKey(3)
+	assert.Equal(t, err, nil)
+
+	fileName := filepath.Join(common.GetTempFolder(), "hdwallet_test.json")
+	defer os.Remove(fileName)
+	err = StoreHDWallet(fileName, "test", w)
+	assert.Equal(t, err, nil)
+
+	loaded, err := GetHDWallet(fileName, "test")
+	assert.Equal(t, err, nil)
+	assert.Equal(t, loaded.Accounts, map[uint]uint32{3: 1})
+
+	accounts, err := loaded.ListAccounts()
+	assert.Equal(t, err, nil)
+	assert.Equal(t, accounts, []*HDAccount{account})
+
+	_, err = GetHDWallet(fileName, "badpass")
+	assert.Equal(t, err != nil, true)
+}
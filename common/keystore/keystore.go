@@ -30,3 +30,23 @@ func StoreKey(fileName, password string, key *Key) error {
 
 	return common.SaveFile(fileName, content)
 }
+
+// GetHDWallet gets the HD wallet from a file
+func GetHDWallet(fileName, password string) (*HDWallet, error) {
+	content, err := ioutil.ReadFile(fileName)
+	if err != nil {
+		return nil, err
+	}
+
+	return DecryptHDWallet(content, password)
+}
+
+// StoreHDWallet stores the HD wallet in a file encrypted with the password
+func StoreHDWallet(fileName, password string, w *HDWallet) error {
+	content, err := EncryptHDWallet(w, password)
+	if err != nil {
+		return err
+	}
+
+	return common.SaveFile(fileName, content)
+}
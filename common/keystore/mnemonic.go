@@ -0,0 +1,126 @@
+/**
+*  @file
+*  @copyright defined in scdo/LICENSE
+ */
+
+package keystore
+
+import (
+	"crypto/sha256"
+	"crypto/sha512"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+
+	"golang.org/x/crypto/pbkdf2"
+)
+
+const (
+	// MnemonicWords12 is the word count of the mnemonic with 128 bits entropy
+	MnemonicWords12 = 12
+	// MnemonicWords24 is the word count of the mnemonic with 256 bits entropy
+	MnemonicWords24 = 24
+
+	mnemonicSeedIterations = 2048
+	mnemonicSeedLen        = 64
+)
+
+var (
+	errInvalidEntropy       = errors.New("entropy length must be a multiple of 32 bits between 128 and 256")
+	errInvalidMnemonic      = errors.New("invalid mnemonic")
+	errMnemonicChecksum     = errors.New("mnemonic checksum mismatch")
+	errInvalidMnemonicWords = errors.New("mnemonic words must be one of 12, 15, 18, 21 and 24")
+
+	wordIndexes = func() map[string]int {
+		indexes := make(map[string]int, len(englishWords))
+		for i, w := range englishWords {
+			indexes[w] = i
+		}
+		return indexes
+	}()
+)
+
+// NewMnemonic generates a BIP-39 mnemonic with the number of words
+func NewMnemonic(words int) (string, error) {
+	if words < 12 || words > 24 || words%3 != 0 {
+		return "", errInvalidMnemonicWords
+	}
+
+	// every 3 words are 32 bits entropy and 1 bit checksum
+	return EntropyToMnemonic(getRandBuff(words / 3 * 4))
+}
+
+// EntropyToMnemonic converts the entropy to the BIP-39 mnemonic, the last
+// len(entropy)/4 bits of the mnemonic are the checksum of the entropy.
+func EntropyToMnemonic(entropy []byte) (string, error) {
+	bits := len(entropy) * 8
+	if bits < 128 || bits > 256 || bits%32 != 0 {
+		return "", errInvalidEntropy
+	}
+
+	checksumBits := uint(bits / 32)
+	hash := sha256.Sum256(entropy)
+	value := new(big.Int).SetBytes(entropy)
+	value.Lsh(value, checksumBits)
+	value.Or(value, big.NewInt(int64(hash[0]>>(8-checksumBits))))
+
+	count := (bits + int(checksumBits)) / 11
+	words := make([]string, count)
+	mask := big.NewInt(2047)
+	index := new(big.Int)
+	for i := count - 1; i >= 0; i-- {
+		index.And(value, mask)
+		words[i] = englishWords[index.Int64()]
+		value.Rsh(value, 11)
+	}
+
+	return strings.Join(words, " "), nil
+}
+
+// MnemonicToEntropy returns the entropy of the mnemonic, it fails if any word
+// is not in the word list or the checksum does not match.
+func MnemonicToEntropy(mnemonic string) ([]byte, error) {
+	words := strings.Fields(mnemonic)
+	if len(words) < 12 || len(words) > 24 || len(words)%3 != 0 {
+		return nil, errInvalidMnemonicWords
+	}
+
+	value := new(big.Int)
+	for _, w := range words {
+		index, ok := wordIndexes[w]
+		if !ok {
+			return nil, fmt.Errorf("%s, unknown word %s", errInvalidMnemonic, w)
+		}
+
+		value.Lsh(value, 11)
+		value.Or(value, big.NewInt(int64(index)))
+	}
+
+	checksumBits := uint(len(words) / 3)
+	checksum := new(big.Int).And(value, big.NewInt(int64(1)<<checksumBits-1))
+	value.Rsh(value, checksumBits)
+
+	entropy := make([]byte, len(words)/3*4)
+	value.FillBytes(entropy)
+
+	hash := sha256.Sum256(entropy)
+	if checksum.Int64() != int64(hash[0]>>(8-checksumBits)) {
+		return nil, errMnemonicChecksum
+	}
+
+	return entropy, nil
+}
+
+// ValidateMnemonic returns nil if the mnemonic is a valid BIP-39 mnemonic
+func ValidateMnemonic(mnemonic string) error {
+	_, err := MnemonicToEntropy(mnemonic)
+	return err
+}
+
+// MnemonicToSeed returns the BIP-39 seed of the mnemonic protected by the passphrase,
+// the passphrase could be empty.
+func MnemonicToSeed(mnemonic, passphrase string) []byte {
+	normalized := strings.Join(strings.Fields(mnemonic), " ")
+	return pbkdf2.Key([]byte(normalized), []byte("mnemonic"+passphrase), mnemonicSeedIterations, mnemonicSeedLen, sha512.New)
+}
@@ -32,36 +32,88 @@ const (
 // passphrase -> script function -> decryption key
 // decryption key + private key ->  aes-128-ctr algorithm -> encrypted private key
 func EncryptKey(key *Key, auth string) ([]byte, error) {
+	info, err := encryptData(math.PaddedBigBytes(key.PrivateKey.D, 32), auth)
+	if err != nil {
+		return nil, err
+	}
+
+	encryptedKey := encryptedKey{
+		Version: Version,
+		Address: key.Address.Hex(),
+		Crypto:  *info,
+	}
+
+	return json.MarshalIndent(encryptedKey, "", "\t")
+}
+
+// EncryptHDWallet encrypts the seed of the HD wallet in the same way as EncryptKey,
+// the numbers of the derived accounts are stored in plain text.
+func EncryptHDWallet(w *HDWallet, auth string) ([]byte, error) {
+	info, err := encryptData(w.seed, auth)
+	if err != nil {
+		return nil, err
+	}
+
+	encryptedWallet := encryptedHDWallet{
+		Version:  Version,
+		Accounts: w.Accounts,
+		Crypto:   *info,
+	}
+
+	return json.MarshalIndent(encryptedWallet, "", "\t")
+}
+
+// DecryptHDWallet decrypts the HD wallet from a json blob
+func DecryptHDWallet(walletjson []byte, auth string) (*HDWallet, error) {
+	encryptedWallet := new(encryptedHDWallet)
+	if err := json.Unmarshal(walletjson, encryptedWallet); err != nil {
+		return nil, err
+	}
+
+	if encryptedWallet.Version != Version {
+		return nil, errors.Create(errors.ErrKeyVersionMismatch, encryptedWallet.Version)
+	}
+
+	seed, err := decryptData(&encryptedWallet.Crypto, auth)
+	if err != nil {
+		return nil, err
+	}
+
+	w, err := NewHDWallet(seed)
+	if err != nil {
+		return nil, err
+	}
+
+	for shard, count := range encryptedWallet.Accounts {
+		w.Accounts[shard] = count
+	}
+
+	return w, nil
+}
+
+// encryptData encrypts the data with the auth
+// passphrase -> script function -> decryption key
+// decryption key + data ->  aes-128-ctr algorithm -> encrypted data
+func encryptData(data []byte, auth string) (*cryptoInfo, error) {
 	salt := getRandBuff(32)
 	scryptKey, err := getScryptKey(salt, auth)
 	if err != nil {
 		return nil, err
 	}
 
-	encryptKey := scryptKey[:16]
-	keyBytes := math.PaddedBigBytes(key.PrivateKey.D, 32)
-
 	iv := getRandBuff(aes.BlockSize) // 16
-	cipherText, err := aesCTRXOR(encryptKey, keyBytes, iv)
+	cipherText, err := aesCTRXOR(scryptKey[:16], data, iv)
 	if err != nil {
 		return nil, err
 	}
 
 	mac := crypto.HashBytes(scryptKey[16:32], cipherText)
-	info := cryptoInfo{
+	return &cryptoInfo{
 		CipherText: hex.EncodeToString(cipherText),
 		CipherIV:   hex.EncodeToString(iv),
 		Salt:       hex.EncodeToString(salt),
 		MAC:        mac.Hex(),
-	}
-
-	encryptedKey := encryptedKey{
-		Version: Version,
-		Address: key.Address.Hex(),
-		Crypto:  info,
-	}
-
-	return json.MarshalIndent(encryptedKey, "", "\t")
+	}, nil
 }
 
 // DecryptKey decrypts a key from a json blob, returning the private key itself.
@@ -94,22 +146,26 @@ func doDecrypt(keyProtected *encryptedKey, auth string) ([]byte, error) {
 		return nil, errors.Create(errors.ErrKeyVersionMismatch, keyProtected.Version)
 	}
 
-	mac, err := common.HexToHash(keyProtected.Crypto.MAC)
+	return decryptData(&keyProtected.Crypto, auth)
+}
+
+func decryptData(info *cryptoInfo, auth string) ([]byte, error) {
+	mac, err := common.HexToHash(info.MAC)
 	if err != nil {
 		return nil, err
 	}
 
-	iv, err := hex.DecodeString(keyProtected.Crypto.CipherIV)
+	iv, err := hex.DecodeString(info.CipherIV)
 	if err != nil {
 		return nil, err
 	}
 
-	cipherText, err := hex.DecodeString(keyProtected.Crypto.CipherText)
+	cipherText, err := hex.DecodeString(info.CipherText)
 	if err != nil {
 		return nil, err
 	}
 
-	salt, err := hex.DecodeString(keyProtected.Crypto.Salt)
+	salt, err := hex.DecodeString(info.Salt)
 	if err != nil {
 		return nil, err
 	}
@@ -30,6 +30,12 @@ type encryptedKey struct {
 	Crypto  cryptoInfo `json:"crypto"`
 }
 
+type encryptedHDWallet struct {
+	Version  int             `json:"version"`
+	Accounts map[uint]uint32 `json:"accounts"`
+	Crypto   cryptoInfo      `json:"crypto"`
+}
+
 type cryptoInfo struct {
 	CipherText string `json:"ciphertext"`
 	CipherIV   string `json:"iv"`
@@ -0,0 +1,95 @@
+/**
+*  @file
+*  @copyright defined in scdo/LICENSE
+ */
+
+package clique
+
+import (
+	"github.com/elcn233/go-scdo/common"
+	"github.com/elcn233/go-scdo/consensus"
+	"github.com/elcn233/go-scdo/core/types"
+	"github.com/elcn233/go-scdo/rpc"
+)
+
+// API is a user facing RPC API to allow controlling the signer and voting
+// mechanisms of the proof-of-authority scheme.
+type API struct {
+	chain  consensus.ChainReader
+	clique *Clique
+}
+
+// GetSnapshot retrieves the state snapshot at a given block.
+func (api *API) GetSnapshot(number *rpc.BlockNumber) (*Snapshot, error) {
+	header := api.headerByNumber(number)
+	if header == nil {
+		return nil, errUnknownBlock
+	}
+	return api.clique.snapshot(api.chain, header.Height, header.Hash(), nil)
+}
+
+// GetSnapshotAtHash retrieves the state snapshot at a given block.
+func (api *API) GetSnapshotAtHash(hash common.Hash) (*Snapshot, error) {
+	header := api.chain.GetHeaderByHash(hash)
+	if header == nil {
+		return nil, errUnknownBlock
+	}
+	return api.clique.snapshot(api.chain, header.Height, header.Hash(), nil)
+}
+
+// GetSigners retrieves the list of authorized signers at the specified block.
+func (api *API) GetSigners(number *rpc.BlockNumber) ([]common.Address, error) {
+	snap, err := api.GetSnapshot(number)
+	if err != nil {
+		return nil, err
+	}
+	return snap.signers(), nil
+}
+
+// GetSignersAtHash retrieves the list of authorized signers at the specified block.
+func (api *API) GetSignersAtHash(hash common.Hash) ([]common.Address, error) {
+	snap, err := api.GetSnapshotAtHash(hash)
+	if err != nil {
+		return nil, err
+	}
+	return snap.signers(), nil
+}
+
+// Proposals returns the current proposals the node tries to uphold and vote on.
+func (api *API) Proposals() map[common.Address]bool {
+	api.clique.lock.RLock()
+	defer api.clique.lock.RUnlock()
+
+	proposals := make(map[common.Address]bool)
+	for address, auth := range api.clique.proposals {
+		proposals[address] = auth
+	}
+	return proposals
+}
+
+// Propose injects a new authorization proposal that the signer will attempt to
+// push through.
+func (api *API) Propose(address common.Address, auth bool) {
+	api.clique.lock.Lock()
+	defer api.clique.lock.Unlock()
+
+	api.clique.proposals[address] = auth
+}
+
+// Discard drops a currently running proposal, stopping the signer from casting
+// further votes (either for or against).
+func (api *API) Discard(address common.Address) {
+	api.clique.lock.Lock()
+	defer api.clique.lock.Unlock()
+
+	delete(api.clique.proposals, address)
+}
+
+// headerByNumber returns the header of the given height, or the current header if
+// the number is nil or latest.
+func (api *API) headerByNumber(number *rpc.BlockNumber) *types.BlockHeader {
+	if number == nil || *number == rpc.LatestBlockNumber {
+		return api.chain.CurrentHeader()
+	}
+	return api.chain.GetHeaderByHeight(uint64(number.Int64()))
+}
@@ -0,0 +1,601 @@
+/**
+*  @file
+*  @copyright defined in scdo/LICENSE
+ */
+
+// Package clique implements the proof-of-authority consensus engine, where the
+// blocks are signed by a list of authorized signers in turn.
+package clique
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"errors"
+	"math/big"
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/elcn233/go-scdo/common"
+	"github.com/elcn233/go-scdo/common/hexutil"
+	"github.com/elcn233/go-scdo/consensus"
+	"github.com/elcn233/go-scdo/core/types"
+	"github.com/elcn233/go-scdo/crypto"
+	"github.com/elcn233/go-scdo/database"
+	"github.com/elcn233/go-scdo/log"
+	"github.com/elcn233/go-scdo/rpc"
+	lru "github.com/hashicorp/golang-lru"
+)
+
+const (
+	checkpointInterval = 1024 // Number of blocks after which to save the vote snapshot to the database
+	inmemorySnapshots  = 128  // Number of recent vote snapshots to keep in memory
+	inmemorySignatures = 4096 // Number of recent block signatures to keep in memory
+
+	wiggleTime = 500 * time.Millisecond // Random delay (per signer) to allow concurrent signers
+)
+
+var (
+	diffInTurn = big.NewInt(2) // Block difficulty for in-turn signatures
+	diffNoTurn = big.NewInt(1) // Block difficulty for out-of-turn signatures
+
+	nonceAuthVote = hexutil.MustHexToBytes("0xffffffffffffffff") // Magic nonce number to vote on adding a new signer
+	nonceDropVote = hexutil.MustHexToBytes("0x0000000000000000") // Magic nonce number to vote on removing a signer.
+
+	now = time.Now
+)
+
+var (
+	// errUnknownBlock is returned when the list of signers is requested for a block
+	// that is not part of the local blockchain.
+	errUnknownBlock = errors.New("unknown block")
+	// errCliqueConsensus is returned if a block's consensus mismatch clique
+	errCliqueConsensus = errors.New("mismatch clique consensus")
+	// errFutureBlock is returned when a block's timestamp is in the future.
+	errFutureBlock = errors.New("block in the future")
+	// errInvalidCheckpointBeneficiary is returned if a checkpoint block contains a
+	// vote for a candidate.
+	errInvalidCheckpointBeneficiary = errors.New("vote candidate in checkpoint block non-zero")
+	// errInvalidVote is returned if a nonce value is something else that the two
+	// allowed constants of 0x00..0 or 0xff..f, or the witness is not a nonce optionally
+	// followed by a candidate address.
+	errInvalidVote = errors.New("vote nonce not 0x00..0 or 0xff..f")
+	// errInvalidCheckpointVote is returned if a checkpoint block has a vote nonce set
+	// to a value other than 0x00..0.
+	errInvalidCheckpointVote = errors.New("vote nonce in checkpoint block non-zero")
+	// errExtraSigners is returned if non-checkpoint block contain signer data in
+	// their extra-data fields.
+	errExtraSigners = errors.New("non-checkpoint block contains extra signer list")
+	// errMismatchingCheckpointSigners is returned if a checkpoint block contains a
+	// list of signers different than the one the local node calculated.
+	errMismatchingCheckpointSigners = errors.New("mismatching signer list on checkpoint block")
+	// errInvalidDifficulty is returned if the difficulty of a block neither 1 or 2.
+	errInvalidDifficulty = errors.New("invalid difficulty")
+	// errWrongDifficulty is returned if the difficulty of a block doesn't match the
+	// turn of the signer.
+	errWrongDifficulty = errors.New("wrong difficulty")
+	// errInvalidTimestamp is returned if the timestamp of a block is lower than
+	// the previous block's timestamp + the minimum block period.
+	errInvalidTimestamp = errors.New("invalid timestamp")
+	// errInvalidVotingChain is returned if an authorization list is attempted to
+	// be modified via out-of-range or non-contiguous headers.
+	errInvalidVotingChain = errors.New("invalid voting chain")
+	// errUnauthorizedSigner is returned if a header is signed by a non-authorized entity.
+	errUnauthorizedSigner = errors.New("unauthorized signer")
+	// errRecentlySigned is returned if a header is signed by an authorized entity
+	// that already signed a header recently, thus is temporarily not allowed to.
+	errRecentlySigned = errors.New("recently signed")
+	// errMissingSigningKey is returned if the engine is asked to seal a block
+	// without a private key.
+	errMissingSigningKey = errors.New("missing signing key")
+)
+
+// Clique is the proof-of-authority consensus engine proposed to support the
+// private shards.
+type Clique struct {
+	config *Config           // Consensus engine configuration parameters
+	db     database.Database // Database to store and retrieve snapshot checkpoints
+	log    *log.ScdoLog      // Logger of the engine
+	key    *ecdsa.PrivateKey // Private key of the signer
+	signer common.Address    // Address of the signer
+
+	recents    *lru.ARCCache // Snapshots for recent block to speed up reorgs
+	signatures *lru.ARCCache // Signatures of recent blocks to speed up mining
+
+	proposals map[common.Address]bool // Current list of proposals we are pushing
+	lock      sync.RWMutex            // Protects the proposals
+}
+
+// New creates a clique proof-of-authority consensus engine. The engine could only
+// verify the blocks if the private key is nil, and the snapshots are only cached
+// in memory if the db is nil.
+func New(config *Config, privateKey *ecdsa.PrivateKey, db database.Database) *Clique {
+	// Set any missing consensus parameters to their defaults
+	conf := *config
+	if conf.Epoch == 0 {
+		conf.Epoch = DefaultConfig.Epoch
+	}
+
+	recents, _ := lru.NewARC(inmemorySnapshots)
+	signatures, _ := lru.NewARC(inmemorySignatures)
+	engine := &Clique{
+		config:     &conf,
+		db:         db,
+		log:        log.GetLogger("clique"),
+		key:        privateKey,
+		recents:    recents,
+		signatures: signatures,
+		proposals:  make(map[common.Address]bool),
+	}
+
+	if privateKey != nil {
+		engine.signer = *crypto.PubkeyToAddress(privateKey.PublicKey)
+	}
+
+	return engine
+}
+
+// Author retrieves the address of the account that signed the given block.
+func (c *Clique) Author(header *types.BlockHeader) (common.Address, error) {
+	return ecrecover(header, c.signatures)
+}
+
+// VerifyHeader checks whether a header conforms to the consensus rules.
+func (c *Clique) VerifyHeader(chain consensus.ChainReader, header *types.BlockHeader) error {
+	return c.verifyHeader(chain, header, nil)
+}
+
+// verifyHeader checks whether a header conforms to the consensus rules. The
+// caller may optionally pass in a batch of parents (ascending order) to avoid
+// looking those up from the database.
+func (c *Clique) verifyHeader(chain consensus.ChainReader, header *types.BlockHeader, parents []*types.BlockHeader) error {
+	if header.Consensus != types.CliqueConsensus {
+		return errCliqueConsensus
+	}
+
+	// Don't waste time checking blocks from the future
+	if header.CreateTimestamp == nil || header.CreateTimestamp.Cmp(big.NewInt(now().Unix())) > 0 {
+		return errFutureBlock
+	}
+
+	// Ensure that the extra-data contains a signer list on checkpoint, but none otherwise
+	signers, err := types.ExtractCliqueSigners(header)
+	if err != nil {
+		return err
+	}
+
+	number := header.Height
+	checkpoint := c.config.isCheckpoint(number)
+	if !checkpoint && len(signers) != 0 {
+		return errExtraSigners
+	}
+
+	// The genesis block keeps the shard info in witness and the genesis difficulty
+	if number == common.ScdoForkHeight {
+		return nil
+	}
+
+	// Checkpoint blocks need to enforce zero candidate and drop vote
+	candidate, _, err := headerVote(header)
+	if err != nil {
+		return err
+	}
+	if checkpoint && candidate != (common.Address{}) {
+		return errInvalidCheckpointBeneficiary
+	}
+	if checkpoint && !bytes.Equal(header.Witness, nonceDropVote) {
+		return errInvalidCheckpointVote
+	}
+
+	// Ensure that the block's difficulty is meaningful (may not be correct at this point)
+	if header.Difficulty == nil || (header.Difficulty.Cmp(diffInTurn) != 0 && header.Difficulty.Cmp(diffNoTurn) != 0) {
+		return errInvalidDifficulty
+	}
+
+	return c.verifyCascadingFields(chain, header, parents)
+}
+
+// verifyCascadingFields verifies all the header fields that are not standalone,
+// rather depend on a batch of previous headers.
+func (c *Clique) verifyCascadingFields(chain consensus.ChainReader, header *types.BlockHeader, parents []*types.BlockHeader) error {
+	number := header.Height
+
+	// Ensure that the block's timestamp isn't too close to it's parent
+	var parent *types.BlockHeader
+	if len(parents) > 0 {
+		parent = parents[len(parents)-1]
+	} else {
+		parent = chain.GetHeaderByHash(header.PreviousBlockHash)
+	}
+	if parent == nil || parent.Height != number-1 || parent.Hash() != header.PreviousBlockHash {
+		return consensus.ErrBlockInvalidParentHash
+	}
+	if parent.CreateTimestamp.Uint64()+c.config.Period > header.CreateTimestamp.Uint64() {
+		return errInvalidTimestamp
+	}
+
+	// Retrieve the snapshot needed to verify this header and cache it
+	snap, err := c.snapshot(chain, number-1, header.PreviousBlockHash, parents)
+	if err != nil {
+		return err
+	}
+
+	// If the block is a checkpoint block, verify the signer list
+	if c.config.isCheckpoint(number) {
+		signers, err := types.ExtractCliqueSigners(header)
+		if err != nil {
+			return err
+		}
+
+		expected := snap.signers()
+		if len(signers) != len(expected) {
+			return errMismatchingCheckpointSigners
+		}
+		for i, signer := range expected {
+			if signers[i] != signer {
+				return errMismatchingCheckpointSigners
+			}
+		}
+	}
+
+	return c.verifySeal(header, snap)
+}
+
+// verifySeal checks whether the signature contained in the header satisfies the
+// consensus protocol requirements against the snapshot of the parent block.
+func (c *Clique) verifySeal(header *types.BlockHeader, snap *Snapshot) error {
+	number := header.Height
+
+	// Resolve the authorization key and check against signers
+	signer, err := ecrecover(header, c.signatures)
+	if err != nil {
+		return err
+	}
+	if _, ok := snap.Signers[signer]; !ok {
+		return errUnauthorizedSigner
+	}
+	for seen, recent := range snap.Recents {
+		if recent == signer {
+			// Signer is among recents, only fail if the current block doesn't shift it out
+			if limit := uint64(len(snap.Signers)/2 + 1); seen > number-limit {
+				return errRecentlySigned
+			}
+		}
+	}
+
+	// Ensure that the difficulty corresponds to the turn-ness of the signer
+	if header.Difficulty.Cmp(calcDifficulty(snap, number, signer)) != 0 {
+		return errWrongDifficulty
+	}
+
+	return nil
+}
+
+// Prepare implements consensus.Engine, preparing all the consensus fields of the
+// header for running the transactions on top. The creator of the header is kept as
+// the beneficiary of the block reward, and the vote is cast in the witness.
+func (c *Clique) Prepare(chain consensus.ChainReader, header *types.BlockHeader) error {
+	// If the block isn't a checkpoint, cast a random vote (good enough for now)
+	header.Witness = common.CopyBytes(nonceDropVote)
+	header.Consensus = types.CliqueConsensus
+
+	number := header.Height
+	parent := chain.GetHeaderByHash(header.PreviousBlockHash)
+	if parent == nil {
+		return consensus.ErrBlockInvalidParentHash
+	}
+
+	// Assemble the voting snapshot to check which votes make sense
+	snap, err := c.snapshot(chain, number-1, header.PreviousBlockHash, nil)
+	if err != nil {
+		return err
+	}
+
+	checkpoint := c.config.isCheckpoint(number)
+	if !checkpoint {
+		c.lock.RLock()
+		addresses := make([]common.Address, 0, len(c.proposals))
+		for address, authorize := range c.proposals {
+			if snap.validVote(address, authorize) {
+				addresses = append(addresses, address)
+			}
+		}
+
+		// If there's pending proposals, cast a vote on them
+		if len(addresses) > 0 {
+			candidate := addresses[rand.Intn(len(addresses))]
+			if c.proposals[candidate] {
+				copy(header.Witness, nonceAuthVote)
+			}
+			header.Witness = append(header.Witness, candidate.Bytes()...)
+		}
+		c.lock.RUnlock()
+	}
+
+	// Set the correct difficulty
+	header.Difficulty = calcDifficulty(snap, number, c.signer)
+
+	// Ensure the extra data has all its components
+	if len(header.ExtraData) < types.CliqueExtraVanity {
+		header.ExtraData = append(header.ExtraData, bytes.Repeat([]byte{0x00}, types.CliqueExtraVanity-len(header.ExtraData))...)
+	}
+	header.ExtraData = header.ExtraData[:types.CliqueExtraVanity]
+
+	if checkpoint {
+		for _, signer := range snap.signers() {
+			header.ExtraData = append(header.ExtraData, signer[:]...)
+		}
+	}
+	header.ExtraData = append(header.ExtraData, make([]byte, types.CliqueExtraSeal)...)
+
+	// Set the timestamp of the header
+	header.CreateTimestamp = new(big.Int).Add(parent.CreateTimestamp, new(big.Int).SetUint64(c.config.Period))
+	if header.CreateTimestamp.Int64() < now().Unix() {
+		header.CreateTimestamp = big.NewInt(now().Unix())
+	}
+
+	return nil
+}
+
+// Seal implements consensus.Engine, attempting to create a sealed block using
+// the local signing credentials. The result is sent when the block is sealed,
+// or a nil block is sent if the signer is not allowed to seal it for now, so
+// that the miner could prepare the block again on the new chain head.
+func (c *Clique) Seal(chain consensus.ChainReader, block *types.Block, stop <-chan struct{}, results chan<- *types.Block) error {
+	header := block.Header.Clone()
+
+	delay, err := c.sealDelay(chain, header)
+	if err != nil {
+		c.log.Debug("failed to seal block %d, %s", header.Height, err)
+
+		// wait a block period before retrying
+		go func() {
+			select {
+			case <-stop:
+			case <-time.After(time.Duration(c.config.Period)*time.Second + wiggleTime):
+				select {
+				case results <- nil:
+				case <-stop:
+				}
+			}
+		}()
+
+		return err
+	}
+
+	// Sign all the things!
+	sig, err := crypto.Sign(c.key, sigHash(header).Bytes())
+	if err != nil {
+		return err
+	}
+	copy(header.ExtraData[len(header.ExtraData)-types.CliqueExtraSeal:], sig.Sig)
+
+	// Wait until sealing is terminated or delay timeout.
+	c.log.Debug("waiting for slot to sign and propagate, delay %s", delay)
+	go func() {
+		select {
+		case <-stop:
+			return
+		case <-time.After(delay):
+		}
+
+		select {
+		case results <- block.WithSeal(header):
+		case <-stop:
+		}
+	}()
+
+	return nil
+}
+
+// sealDelay checks whether the local signer is allowed to seal the given header,
+// and returns how long to wait before propagating the block.
+func (c *Clique) sealDelay(chain consensus.ChainReader, header *types.BlockHeader) (time.Duration, error) {
+	// Sealing the genesis block is not supported
+	number := header.Height
+	if number <= common.ScdoForkHeight {
+		return 0, errUnknownBlock
+	}
+	if c.key == nil {
+		return 0, errMissingSigningKey
+	}
+
+	// Bail out if we're unauthorized to sign a block
+	snap, err := c.snapshot(chain, number-1, header.PreviousBlockHash, nil)
+	if err != nil {
+		return 0, err
+	}
+	if _, authorized := snap.Signers[c.signer]; !authorized {
+		return 0, errUnauthorizedSigner
+	}
+
+	// If we're amongst the recent signers, wait for the next block
+	for seen, recent := range snap.Recents {
+		if recent == c.signer {
+			// Signer is among recents, only wait if the current block doesn't shift it out
+			if limit := uint64(len(snap.Signers)/2 + 1); number < limit || seen > number-limit {
+				return 0, errRecentlySigned
+			}
+		}
+	}
+
+	// Sweet, the protocol permits us to sign the block, wait for our time
+	delay := time.Unix(header.CreateTimestamp.Int64(), 0).Sub(now())
+	if header.Difficulty.Cmp(diffNoTurn) == 0 {
+		// It's not our turn explicitly to sign, delay it a bit
+		wiggle := time.Duration(len(snap.Signers)/2+1) * wiggleTime
+		delay += time.Duration(rand.Int63n(int64(wiggle)))
+	}
+
+	return delay, nil
+}
+
+// APIs implements consensus.Engine, returning the user facing RPC API to allow
+// controlling the signer voting.
+func (c *Clique) APIs(chain consensus.ChainReader) []rpc.API {
+	return []rpc.API{{
+		Namespace: "clique",
+		Version:   "1.0",
+		Service:   &API{chain: chain, clique: c},
+		Public:    true,
+	}}
+}
+
+// SetThreads implements consensus.Engine, clique does not need mining threads
+func (c *Clique) SetThreads(thread int) {
+	// do nothing
+}
+
+// SetGpuBlocksThreads implements consensus.Engine, clique does not use gpu
+func (c *Clique) SetGpuBlocksThreads(blocks int, threads int) {
+	// do nothing
+}
+
+// snapshot retrieves the authorization snapshot at a given point in time.
+func (c *Clique) snapshot(chain consensus.ChainReader, height uint64, hash common.Hash, parents []*types.BlockHeader) (*Snapshot, error) {
+	// Search for a snapshot in memory or on disk for checkpoints
+	var (
+		headers []*types.BlockHeader
+		snap    *Snapshot
+	)
+	for snap == nil {
+		// If an in-memory snapshot was found, use that
+		if s, ok := c.recents.Get(hash); ok {
+			snap = s.(*Snapshot)
+			break
+		}
+		// If an on-disk checkpoint snapshot can be found, use that
+		if height%checkpointInterval == 0 && c.db != nil {
+			if s, err := loadSnapshot(c.config, c.signatures, c.db, hash); err == nil {
+				c.log.Debug("Loaded voting snapshot form disk. height: %d. hash %s", height, hash)
+				snap = s
+				break
+			}
+		}
+		// If we're at the genesis block, make a snapshot
+		if height == common.ScdoForkHeight {
+			genesis := chain.GetHeaderByHeight(height)
+			if genesis == nil {
+				return nil, errUnknownBlock
+			}
+			if err := c.VerifyHeader(chain, genesis); err != nil {
+				return nil, err
+			}
+			signers, err := types.ExtractCliqueSigners(genesis)
+			if err != nil {
+				return nil, err
+			}
+			snap = newSnapshot(c.config, c.signatures, height, genesis.Hash(), signers)
+			if c.db != nil {
+				if err := snap.store(c.db); err != nil {
+					return nil, err
+				}
+				c.log.Debug("Stored genesis voting snapshot to disk")
+			}
+			break
+		}
+		// No snapshot for this header, gather the header and move backward
+		var header *types.BlockHeader
+		if len(parents) > 0 {
+			// If we have explicit parents, pick from there (enforced)
+			header = parents[len(parents)-1]
+			if header.Hash() != hash || header.Height != height {
+				return nil, consensus.ErrBlockInvalidParentHash
+			}
+			parents = parents[:len(parents)-1]
+		} else {
+			// No explicit parents (or no more left), reach out to the database
+			header = chain.GetHeaderByHash(hash)
+			if header == nil {
+				return nil, consensus.ErrBlockInvalidParentHash
+			}
+		}
+		headers = append(headers, header)
+		height, hash = height-1, header.PreviousBlockHash
+	}
+	// Previous snapshot found, apply any pending headers on top of it
+	for i := 0; i < len(headers)/2; i++ {
+		headers[i], headers[len(headers)-1-i] = headers[len(headers)-1-i], headers[i]
+	}
+	snap, err := snap.apply(headers)
+	if err != nil {
+		return nil, err
+	}
+	c.recents.Add(snap.Hash, snap)
+
+	// If we've generated a new checkpoint snapshot, save to disk
+	if snap.Height%checkpointInterval == 0 && len(headers) > 0 && c.db != nil {
+		if err = snap.store(c.db); err != nil {
+			return nil, err
+		}
+		c.log.Debug("Stored voting snapshot to disk. height %d. hash %s", snap.Height, snap.Hash)
+	}
+	return snap, err
+}
+
+// calcDifficulty returns the difficulty of the block signed by the given signer
+// on top of the snapshot, which is 2 if the signer is in-turn, otherwise 1.
+func calcDifficulty(snap *Snapshot, number uint64, signer common.Address) *big.Int {
+	if snap.inturn(number, signer) {
+		return new(big.Int).Set(diffInTurn)
+	}
+	return new(big.Int).Set(diffNoTurn)
+}
+
+// headerVote returns the candidate voted in the header and whether to authorize it. The
+// witness of the header is the vote nonce followed by the candidate address if any, and
+// the candidate is the zero address if the header casts no vote.
+func headerVote(header *types.BlockHeader) (common.Address, bool, error) {
+	size := len(nonceDropVote)
+	if len(header.Witness) != size && len(header.Witness) != size+common.AddressLen {
+		return common.Address{}, false, errInvalidVote
+	}
+
+	var authorize bool
+	switch nonce := header.Witness[:size]; {
+	case bytes.Equal(nonce, nonceAuthVote):
+		authorize = true
+	case bytes.Equal(nonce, nonceDropVote):
+		authorize = false
+	default:
+		return common.Address{}, false, errInvalidVote
+	}
+
+	return common.BytesToAddress(header.Witness[size:]), authorize, nil
+}
+
+// sigHash returns the hash which is used as input for the clique signing.
+// It is the hash of the entire header apart from the 65 byte signature
+// contained at the end of the extra data.
+func sigHash(header *types.BlockHeader) common.Hash {
+	return crypto.MustHash(types.CliqueSigHeader(header))
+}
+
+// ecrecover extracts the account address from a signed header.
+func ecrecover(header *types.BlockHeader, sigcache *lru.ARCCache) (common.Address, error) {
+	// If the signature's already cached, return that
+	hash := header.Hash()
+	if sigcache != nil {
+		if address, known := sigcache.Get(hash); known {
+			return address.(common.Address), nil
+		}
+	}
+
+	// Retrieve the signature from the header extra-data
+	if len(header.ExtraData) < types.CliqueExtraSeal {
+		return common.Address{}, types.ErrInvalidCliqueHeaderExtra
+	}
+	signature := header.ExtraData[len(header.ExtraData)-types.CliqueExtraSeal:]
+
+	// Recover the public key and the address
+	pubkey, err := crypto.SigToPub(sigHash(header).Bytes(), signature)
+	if err != nil {
+		return common.Address{}, err
+	}
+	signer := *crypto.PubkeyToAddress(*pubkey)
+
+	if sigcache != nil {
+		sigcache.Add(hash, signer)
+	}
+	return signer, nil
+}
@@ -0,0 +1,133 @@
+/**
+*  @file
+*  @copyright defined in scdo/LICENSE
+ */
+
+package clique
+
+import (
+	"bytes"
+	"math/big"
+	"testing"
+
+	"github.com/elcn233/go-scdo/common"
+	"github.com/elcn233/go-scdo/consensus"
+	"github.com/elcn233/go-scdo/core"
+	"github.com/elcn233/go-scdo/core/state"
+	"github.com/elcn233/go-scdo/core/store"
+	"github.com/elcn233/go-scdo/core/txs"
+	"github.com/elcn233/go-scdo/core/types"
+	"github.com/elcn233/go-scdo/crypto"
+	"github.com/elcn233/go-scdo/database/leveldb"
+)
+
+func TestHeaderVote(t *testing.T) {
+	candidate := common.BytesToAddress([]byte{1})
+
+	tests := []struct {
+		witness   []byte
+		candidate common.Address
+		authorize bool
+		err       error
+	}{
+		{nonceDropVote, common.Address{}, false, nil},
+		{append(common.CopyBytes(nonceAuthVote), candidate.Bytes()...), candidate, true, nil},
+		{append(common.CopyBytes(nonceDropVote), candidate.Bytes()...), candidate, false, nil},
+		{[]byte{1, 2, 3, 4, 5, 6, 7, 8}, common.Address{}, false, errInvalidVote},
+		{append(common.CopyBytes(nonceAuthVote), 1), common.Address{}, false, errInvalidVote},
+	}
+
+	for i, test := range tests {
+		candidate, authorize, err := headerVote(&types.BlockHeader{Witness: test.witness})
+		if err != test.err || candidate != test.candidate || authorize != test.authorize {
+			t.Errorf("test %d: vote mismatch: have (%v, %v, %v), want (%v, %v, %v)", i, candidate, authorize, err, test.candidate, test.authorize, test.err)
+		}
+	}
+}
+
+// Tests that the sealed blocks, in which the reward goes to the creator, are
+// accepted by the blockchain, with or without a vote cast.
+func TestInsertSealedBlocks(t *testing.T) {
+	key, _ := crypto.GenerateKey()
+	signer := *crypto.PubkeyToAddress(key.PublicKey)
+	candidateKey, _ := crypto.GenerateKey()
+	candidate := *crypto.PubkeyToAddress(candidateKey.PublicKey)
+	coinbase := *crypto.MustGenerateShardAddress(1)
+
+	db, dispose := leveldb.NewTestDatabase()
+	defer dispose()
+
+	bcStore := store.NewCachedStore(store.NewBlockchainDatabase(db))
+	genesis := core.GetGenesis(core.NewGenesisInfo(nil, 1, 0, big.NewInt(0), types.CliqueConsensus, []common.Address{signer}))
+	if err := genesis.InitializeAndValidate(bcStore, db); err != nil {
+		t.Fatalf("failed to initialize genesis: %v", err)
+	}
+
+	// the second block is a checkpoint block, which is sealed by the candidate
+	// authorized by the vote of the only signer in the first block
+	engine := New(&Config{Epoch: 2}, key, db)
+	engine.proposals[candidate] = true
+	engines := []*Clique{engine, New(&Config{Epoch: 2}, candidateKey, db)}
+
+	chain, err := core.NewBlockchain(bcStore, db, "", engine, nil, -1)
+	if err != nil {
+		t.Fatalf("failed to create blockchain: %v", err)
+	}
+
+	// the blocks are built on the genesis block at common.ScdoForkHeight
+	for i := uint64(1); i <= 2; i++ {
+		engine := engines[i-1]
+		parent := chain.CurrentBlock()
+		height := parent.Header.Height + 1
+		header := &types.BlockHeader{
+			PreviousBlockHash: parent.HeaderHash,
+			Creator:           coinbase,
+			Height:            height,
+		}
+		if err := engine.Prepare(chain, header); err != nil {
+			t.Fatalf("block %d: failed to prepare header: %v", height, err)
+		}
+
+		// the vote is cast in the witness without changing the creator
+		want := append(common.CopyBytes(nonceAuthVote), candidate.Bytes()...)
+		if i%2 == 0 {
+			want = nonceDropVote
+		}
+		if header.Creator != coinbase || !bytes.Equal(header.Witness, want) {
+			t.Fatalf("block %d: vote mismatch: creator %v, witness %x", height, header.Creator, header.Witness)
+		}
+
+		rewardTx, err := txs.NewRewardTx(coinbase, consensus.GetReward(height), header.CreateTimestamp.Uint64())
+		if err != nil {
+			t.Fatalf("block %d: failed to create reward tx: %v", height, err)
+		}
+
+		statedb, err := state.NewStatedb(parent.Header.StateHash, db)
+		if err != nil {
+			t.Fatalf("block %d: failed to create statedb: %v", height, err)
+		}
+
+		receipt, err := txs.ApplyRewardTx(rewardTx, statedb, header)
+		if err != nil {
+			t.Fatalf("block %d: failed to apply reward tx: %v", height, err)
+		}
+
+		if header.StateHash, err = statedb.Hash(); err != nil {
+			t.Fatalf("block %d: failed to get state hash: %v", height, err)
+		}
+
+		results := make(chan *types.Block, 1)
+		block := types.NewBlock(header, []*types.Transaction{rewardTx}, []*types.Receipt{receipt}, nil)
+		if err := engine.Seal(chain, block, nil, results); err != nil {
+			t.Fatalf("block %d: failed to seal block: %v", height, err)
+		}
+
+		if err := chain.WriteBlock(<-results, nil); err != nil {
+			t.Fatalf("block %d: failed to write block: %v", height, err)
+		}
+	}
+
+	if height := chain.CurrentBlock().Header.Height; height != common.ScdoForkHeight+2 {
+		t.Errorf("chain height mismatch: have %d, want %d", height, common.ScdoForkHeight+2)
+	}
+}
@@ -0,0 +1,26 @@
+/**
+*  @file
+*  @copyright defined in scdo/LICENSE
+ */
+
+package clique
+
+import "github.com/elcn233/go-scdo/common"
+
+// Config is the configuration of the clique proof-of-authority engine
+type Config struct {
+	Period uint64 `json:"period"` // Number of seconds between blocks to enforce
+	Epoch  uint64 `json:"epoch"`  // Epoch length to reset votes and checkpoint
+}
+
+// DefaultConfig is the default clique config
+var DefaultConfig = &Config{
+	Period: 5,
+	Epoch:  30000,
+}
+
+// isCheckpoint returns whether the block of the given height is a checkpoint,
+// the epochs are counted from the genesis block at common.ScdoForkHeight.
+func (config *Config) isCheckpoint(number uint64) bool {
+	return (number-common.ScdoForkHeight)%config.Epoch == 0
+}
@@ -0,0 +1,272 @@
+/**
+*  @file
+*  @copyright defined in scdo/LICENSE
+ */
+
+package clique
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"math/big"
+	"reflect"
+	"sort"
+	"testing"
+
+	"github.com/elcn233/go-scdo/common"
+	"github.com/elcn233/go-scdo/core/types"
+	"github.com/elcn233/go-scdo/crypto"
+)
+
+type testerVote struct {
+	signer string
+	voted  string
+	auth   bool
+}
+
+// testerAccountPool is a pool to maintain currently active tester accounts,
+// mapped from textual names used in the tests below to actual private keys
+// capable of signing headers.
+type testerAccountPool struct {
+	accounts map[string]*ecdsa.PrivateKey
+}
+
+func newTesterAccountPool() *testerAccountPool {
+	return &testerAccountPool{
+		accounts: make(map[string]*ecdsa.PrivateKey),
+	}
+}
+
+func (ap *testerAccountPool) sign(header *types.BlockHeader, signer string) {
+	// Ensure we have a persistent key for the signer
+	if ap.accounts[signer] == nil {
+		ap.accounts[signer], _ = crypto.GenerateKey()
+	}
+	// Sign the header and embed the signature in extra data
+	sig, _ := crypto.Sign(ap.accounts[signer], sigHash(header).Bytes())
+	copy(header.ExtraData[len(header.ExtraData)-types.CliqueExtraSeal:], sig.Sig)
+}
+
+func (ap *testerAccountPool) address(account string) common.Address {
+	// Return the zero account for non-addresses
+	if account == "" {
+		return common.Address{}
+	}
+	// Ensure we have a persistent key for the account
+	if ap.accounts[account] == nil {
+		ap.accounts[account], _ = crypto.GenerateKey()
+	}
+	// Resolve and return the address
+	return *crypto.PubkeyToAddress(ap.accounts[account].PublicKey)
+}
+
+// newTesterHeader creates a header of the given height with an empty seal, which
+// votes for the candidate if it is not empty.
+func newTesterHeader(height uint64, candidate common.Address, auth bool) *types.BlockHeader {
+	header := &types.BlockHeader{
+		Height:          height,
+		Witness:         common.CopyBytes(nonceDropVote),
+		Difficulty:      big.NewInt(1),
+		CreateTimestamp: big.NewInt(int64(height)),
+		Consensus:       types.CliqueConsensus,
+		ExtraData:       make([]byte, types.CliqueExtraVanity+types.CliqueExtraSeal),
+	}
+	if auth {
+		copy(header.Witness, nonceAuthVote)
+	}
+	if candidate != (common.Address{}) {
+		header.Witness = append(header.Witness, candidate.Bytes()...)
+	}
+	return header
+}
+
+// Tests that voting is evaluated correctly for various simple and complex scenarios.
+func TestVoting(t *testing.T) {
+	// Define the various voting scenarios to test
+	tests := []struct {
+		epoch   uint64
+		signers []string
+		votes   []testerVote
+		results []string
+		failure error
+	}{
+		{
+			// Single signer, no votes cast
+			signers: []string{"A"},
+			votes:   []testerVote{{signer: "A"}},
+			results: []string{"A"},
+		}, {
+			// Single signer, voting to add two others (only accept first, second needs 2 votes)
+			signers: []string{"A"},
+			votes: []testerVote{
+				{signer: "A", voted: "B", auth: true},
+				{signer: "B"},
+				{signer: "A", voted: "C", auth: true},
+			},
+			results: []string{"A", "B"},
+		}, {
+			// Two signers, voting to add three others (only accept first two, third needs 3 votes already)
+			signers: []string{"A", "B"},
+			votes: []testerVote{
+				{signer: "A", voted: "C", auth: true},
+				{signer: "B", voted: "C", auth: true},
+				{signer: "A", voted: "D", auth: true},
+				{signer: "B", voted: "D", auth: true},
+				{signer: "C"},
+				{signer: "A", voted: "E", auth: true},
+				{signer: "B", voted: "E", auth: true},
+			},
+			results: []string{"A", "B", "C", "D"},
+		}, {
+			// Single signer, dropping itself (weird, but one less cornercase by explicitly allowing this)
+			signers: []string{"A"},
+			votes: []testerVote{
+				{signer: "A", voted: "A", auth: false},
+			},
+			results: []string{},
+		}, {
+			// Two signers, actually needing mutual consent to drop either of them
+			signers: []string{"A", "B"},
+			votes: []testerVote{
+				{signer: "A", voted: "B", auth: false},
+			},
+			results: []string{"A", "B"},
+		}, {
+			// Two signers, actually needing mutual consent to drop either of them (successful)
+			signers: []string{"A", "B"},
+			votes: []testerVote{
+				{signer: "A", voted: "B", auth: false},
+				{signer: "B", voted: "B", auth: false},
+			},
+			results: []string{"A"},
+		}, {
+			// Deauthorizing multiple accounts concurrently is permitted
+			signers: []string{"A", "B", "C", "D"},
+			votes: []testerVote{
+				{signer: "A", voted: "C", auth: false},
+				{signer: "B"},
+				{signer: "C"},
+				{signer: "A", voted: "D", auth: false},
+				{signer: "B", voted: "C", auth: false},
+				{signer: "C"},
+				{signer: "A"},
+				{signer: "B", voted: "D", auth: false},
+				{signer: "C", voted: "D", auth: false},
+			},
+			results: []string{"A", "B", "C"},
+		}, {
+			// Votes from deauthorized signers are discarded immediately (deauth votes)
+			signers: []string{"A", "B", "C"},
+			votes: []testerVote{
+				{signer: "C", voted: "B", auth: false},
+				{signer: "A", voted: "C", auth: false},
+				{signer: "B", voted: "C", auth: false},
+				{signer: "A", voted: "B", auth: false},
+			},
+			results: []string{"A", "B"},
+		}, {
+			// Three signers, two of them deciding to drop the third
+			signers: []string{"A", "B", "C"},
+			votes: []testerVote{
+				{signer: "A", voted: "C", auth: false},
+				{signer: "B", voted: "C", auth: false},
+			},
+			results: []string{"A", "B"},
+		}, {
+			// Ensure that pending votes don't survive authorization status changes
+			epoch:   3,
+			signers: []string{"A", "B"},
+			votes: []testerVote{
+				{signer: "A", voted: "C", auth: true},
+				{signer: "B"},
+				{signer: "A", voted: "C", auth: true}, // checkpoint block, votes are reset
+				{signer: "B"},
+			},
+			results: []string{"A", "B"},
+		}, {
+			// An unauthorized signer should not be able to sign blocks
+			signers: []string{"A"},
+			votes: []testerVote{
+				{signer: "B"},
+			},
+			failure: errUnauthorizedSigner,
+		}, {
+			// An authorized signer that signed recently should not be able to sign again
+			signers: []string{"A", "B"},
+			votes: []testerVote{
+				{signer: "A"},
+				{signer: "A"},
+			},
+			failure: errRecentlySigned,
+		},
+	}
+	// Run through the scenarios and test them
+	for i, tt := range tests {
+		// Create the account pool and generate the initial set of signers
+		accounts := newTesterAccountPool()
+
+		signers := make([]common.Address, len(tt.signers))
+		for j, signer := range tt.signers {
+			signers[j] = accounts.address(signer)
+		}
+
+		epoch := tt.epoch
+		if epoch == 0 {
+			epoch = DefaultConfig.Epoch
+		}
+
+		// Assemble a chain of headers from the cast votes
+		headers := make([]*types.BlockHeader, len(tt.votes))
+		for j, vote := range tt.votes {
+			headers[j] = newTesterHeader(uint64(j)+1, accounts.address(vote.voted), vote.auth)
+			if j > 0 {
+				headers[j].PreviousBlockHash = headers[j-1].Hash()
+			}
+			accounts.sign(headers[j], vote.signer)
+		}
+
+		snap := newSnapshot(&Config{Period: 1, Epoch: epoch}, nil, 0, common.Hash{}, signers)
+		snap, err := snap.apply(headers)
+		if err != tt.failure {
+			t.Errorf("test %d: failure mismatch: have %v, want %v", i, err, tt.failure)
+			continue
+		}
+		if tt.failure != nil {
+			continue
+		}
+
+		// Verify the final list of signers against the expected ones
+		expected := make([]common.Address, len(tt.results))
+		for j, signer := range tt.results {
+			expected[j] = accounts.address(signer)
+		}
+		sort.Slice(expected, func(a, b int) bool {
+			return bytes.Compare(expected[a][:], expected[b][:]) < 0
+		})
+
+		if result := snap.signers(); !reflect.DeepEqual(result, expected) {
+			t.Errorf("test %d: signers mismatch: have %x, want %x", i, result, expected)
+		}
+	}
+}
+
+func TestInturn(t *testing.T) {
+	accounts := newTesterAccountPool()
+	snap := newSnapshot(DefaultConfig, nil, 0, common.Hash{}, []common.Address{accounts.address("A"), accounts.address("B")})
+
+	signers := snap.signers()
+	for number := uint64(0); number < 4; number++ {
+		signer := signers[number%2]
+		if !snap.inturn(number, signer) {
+			t.Errorf("block %d: signer %s should be in-turn", number, signer.Hex())
+		}
+		if diff := calcDifficulty(snap, number, signer); diff.Cmp(diffInTurn) != 0 {
+			t.Errorf("block %d: difficulty mismatch: have %v, want %v", number, diff, diffInTurn)
+		}
+
+		other := signers[(number+1)%2]
+		if diff := calcDifficulty(snap, number, other); diff.Cmp(diffNoTurn) != 0 {
+			t.Errorf("block %d: difficulty mismatch: have %v, want %v", number, diff, diffNoTurn)
+		}
+	}
+}
@@ -41,6 +41,16 @@ type Istanbul interface {
 	Stop() error
 }
 
+// InstantSealer is implemented by the engines which seal blocks without any work.
+// The miner only commits the blocks with transactions or debts to them, unless
+// empty blocks are allowed.
+type InstantSealer interface {
+	Engine
+
+	// AllowEmptyBlocks returns whether to seal the blocks without transactions and debts
+	AllowEmptyBlocks() bool
+}
+
 // Broadcaster defines the interface to enqueue blocks to fetcher and find peer
 type Broadcaster interface {
 	// Enqueue add a block into fetcher queue
@@ -0,0 +1,143 @@
+/**
+*  @file
+*  @copyright defined in scdo/LICENSE
+ */
+
+// Package dev implements the instant-seal consensus engine for development and CI,
+// which seals blocks without any work.
+package dev
+
+import (
+	"errors"
+	"math/big"
+	"time"
+
+	"github.com/elcn233/go-scdo/consensus"
+	"github.com/elcn233/go-scdo/core/types"
+	"github.com/elcn233/go-scdo/log"
+	"github.com/elcn233/go-scdo/rpc"
+)
+
+var (
+	// errInvalidDifficulty is returned if the difficulty of a block is not 1
+	errInvalidDifficulty = errors.New("invalid difficulty")
+
+	// errInvalidConsensus is returned if the consensus type of a block is not pow
+	errInvalidConsensus = errors.New("invalid consensus type")
+
+	defaultDifficulty = big.NewInt(1)
+)
+
+// Config is the configuration of the dev engine
+type Config struct {
+	// Period is the interval in seconds to seal blocks, including the empty ones.
+	// If it is 0, a block is sealed immediately when there are new transactions or debts.
+	Period uint64 `json:"period"`
+}
+
+// Engine is the instant-seal consensus engine
+type Engine struct {
+	period uint64
+	log    *log.ScdoLog
+}
+
+// NewEngine creates the dev engine, which seals blocks every period seconds,
+// or for every batch of transactions if period is 0.
+func NewEngine(period uint64) *Engine {
+	return &Engine{
+		period: period,
+		log:    log.GetLogger("dev_engine"),
+	}
+}
+
+// AllowEmptyBlocks implements consensus.InstantSealer, empty blocks are only
+// sealed on the fixed interval.
+func (engine *Engine) AllowEmptyBlocks() bool {
+	return engine.period > 0
+}
+
+// Prepare sets the difficulty and the timestamp of the header
+func (engine *Engine) Prepare(reader consensus.ChainReader, header *types.BlockHeader) error {
+	parent := reader.GetHeaderByHash(header.PreviousBlockHash)
+	if parent == nil {
+		return consensus.ErrBlockInvalidParentHash
+	}
+
+	header.Difficulty = new(big.Int).Set(defaultDifficulty)
+	header.Consensus = types.PowConsensus
+
+	if engine.period > 0 {
+		timestamp := new(big.Int).Add(parent.CreateTimestamp, new(big.Int).SetUint64(engine.period))
+		if timestamp.Cmp(header.CreateTimestamp) > 0 {
+			header.CreateTimestamp = timestamp
+		}
+	}
+
+	return nil
+}
+
+// VerifyHeader only verifies the structure of the header, the block is not proven by any work.
+func (engine *Engine) VerifyHeader(reader consensus.ChainReader, header *types.BlockHeader) error {
+	if header.Consensus != types.PowConsensus {
+		return errInvalidConsensus
+	}
+
+	parent := reader.GetHeaderByHash(header.PreviousBlockHash)
+	if parent == nil {
+		return consensus.ErrBlockInvalidParentHash
+	}
+
+	if header.Height != parent.Height+1 {
+		return consensus.ErrBlockInvalidHeight
+	}
+
+	if header.CreateTimestamp == nil || header.CreateTimestamp.Cmp(parent.CreateTimestamp) < 0 {
+		return consensus.ErrBlockCreateTimeOld
+	}
+
+	if header.Difficulty == nil || header.Difficulty.Cmp(defaultDifficulty) != 0 {
+		return errInvalidDifficulty
+	}
+
+	return nil
+}
+
+// Seal returns the block immediately, or at the timestamp of the header if the period is set.
+func (engine *Engine) Seal(reader consensus.ChainReader, block *types.Block, stop <-chan struct{}, results chan<- *types.Block) error {
+	header := block.Header.Clone()
+	delay := time.Unix(header.CreateTimestamp.Int64(), 0).Sub(time.Now())
+
+	go func() {
+		if engine.period > 0 && delay > 0 {
+			select {
+			case <-time.After(delay):
+			case <-stop:
+				return
+			}
+		}
+
+		engine.log.Debug("sealed block %d with %d transactions", header.Height, len(block.Transactions))
+
+		select {
+		case results <- block.WithSeal(header):
+		case <-stop:
+		}
+	}()
+
+	return nil
+}
+
+// APIs returns nothing, the dev engine provides no RPC APIs.
+func (engine *Engine) APIs(chain consensus.ChainReader) []rpc.API {
+	return nil
+}
+
+// SetThreads does nothing, the dev engine needs no mining threads
+func (engine *Engine) SetThreads(threads int) {
+	// do nothing
+}
+
+// SetGpuBlocksThreads does nothing, the dev engine needs no gpu
+func (engine *Engine) SetGpuBlocksThreads(blocks int, threads int) {
+	// do nothing
+}
@@ -0,0 +1,104 @@
+/**
+*  @file
+*  @copyright defined in scdo/LICENSE
+ */
+
+package dev
+
+import (
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/elcn233/go-scdo/common"
+	"github.com/elcn233/go-scdo/consensus"
+	"github.com/elcn233/go-scdo/core/types"
+	"github.com/stretchr/testify/assert"
+)
+
+type testChainReader struct {
+	headers map[common.Hash]*types.BlockHeader
+}
+
+func newTestChainReader(headers ...*types.BlockHeader) *testChainReader {
+	reader := &testChainReader{headers: make(map[common.Hash]*types.BlockHeader)}
+	for _, header := range headers {
+		reader.headers[header.Hash()] = header
+	}
+	return reader
+}
+
+func (reader *testChainReader) CurrentHeader() *types.BlockHeader           { return nil }
+func (reader *testChainReader) GetHeaderByHeight(uint64) *types.BlockHeader { return nil }
+func (reader *testChainReader) GetBlockByHash(common.Hash) *types.Block     { return nil }
+func (reader *testChainReader) GetHeaderByHash(hash common.Hash) *types.BlockHeader {
+	return reader.headers[hash]
+}
+
+func newTestHeaders() (*types.BlockHeader, *types.BlockHeader) {
+	parent := &types.BlockHeader{
+		Difficulty:      big.NewInt(1),
+		Height:          1,
+		CreateTimestamp: big.NewInt(time.Now().Unix() - 10),
+	}
+	header := &types.BlockHeader{
+		PreviousBlockHash: parent.Hash(),
+		Height:            2,
+		CreateTimestamp:   big.NewInt(time.Now().Unix()),
+	}
+	return parent, header
+}
+
+func Test_PrepareAndVerifyHeader(t *testing.T) {
+	parent, header := newTestHeaders()
+	reader := newTestChainReader(parent)
+	engine := NewEngine(0)
+
+	assert.Equal(t, engine.Prepare(reader, header), nil)
+	assert.Equal(t, header.Difficulty, big.NewInt(1))
+	assert.Equal(t, engine.VerifyHeader(reader, header), nil)
+
+	header.Height = 3
+	assert.Equal(t, engine.VerifyHeader(reader, header), consensus.ErrBlockInvalidHeight)
+
+	header.Height = 2
+	header.Difficulty = big.NewInt(2)
+	assert.Equal(t, engine.VerifyHeader(reader, header), errInvalidDifficulty)
+
+	header.PreviousBlockHash = common.EmptyHash
+	assert.Equal(t, engine.VerifyHeader(reader, header), consensus.ErrBlockInvalidParentHash)
+}
+
+func Test_PrepareWithPeriod(t *testing.T) {
+	parent, header := newTestHeaders()
+	parent.CreateTimestamp = big.NewInt(time.Now().Unix())
+	reader := newTestChainReader(parent)
+
+	engine := NewEngine(5)
+	assert.Equal(t, engine.AllowEmptyBlocks(), true)
+	assert.Equal(t, NewEngine(0).AllowEmptyBlocks(), false)
+
+	// the block can not be sealed before the interval
+	header.PreviousBlockHash = parent.Hash()
+	assert.Equal(t, engine.Prepare(reader, header), nil)
+	assert.Equal(t, header.CreateTimestamp, new(big.Int).Add(parent.CreateTimestamp, big.NewInt(5)))
+}
+
+func Test_Seal(t *testing.T) {
+	parent, header := newTestHeaders()
+	reader := newTestChainReader(parent)
+	engine := NewEngine(0)
+	assert.Equal(t, engine.Prepare(reader, header), nil)
+
+	block := types.NewBlock(header, nil, nil, nil)
+	results := make(chan *types.Block, 1)
+	assert.Equal(t, engine.Seal(reader, block, make(chan struct{}), results), nil)
+
+	select {
+	case result := <-results:
+		assert.Equal(t, result.HeaderHash, block.HeaderHash)
+		assert.Equal(t, result.Header.Hash(), block.HeaderHash)
+	case <-time.After(time.Second):
+		t.Fatal("block is not sealed immediately")
+	}
+}
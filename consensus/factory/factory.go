@@ -13,6 +13,8 @@ import (
 	"github.com/elcn233/go-scdo/common"
 	"github.com/elcn233/go-scdo/common/errors"
 	"github.com/elcn233/go-scdo/consensus"
+	"github.com/elcn233/go-scdo/consensus/clique"
+	"github.com/elcn233/go-scdo/consensus/dev"
 	"github.com/elcn233/go-scdo/consensus/istanbul"
 	"github.com/elcn233/go-scdo/consensus/istanbul/backend"
 	"github.com/elcn233/go-scdo/consensus/pow"
@@ -28,6 +30,12 @@ func GetConsensusEngine(minerAlgorithm string) (consensus.Engine, error) {
 		minerEngine = pow.NewEngine(1)
 	} else if minerAlgorithm == common.ZpowAlgorithm {
 		minerEngine = zpow.NewZpowEngine(1)
+	} else if minerAlgorithm == common.DevEngine {
+		minerEngine = dev.NewEngine(0)
+	} else if minerAlgorithm == common.CliqueEngine {
+		// the engine without signer key and database could only verify blocks,
+		// use GetCliqueEngine to sign blocks.
+		minerEngine = clique.New(clique.DefaultConfig, nil, nil)
 	} else {
 		return nil, fmt.Errorf("unknown miner algorithm")
 	}
@@ -35,13 +43,43 @@ func GetConsensusEngine(minerAlgorithm string) (consensus.Engine, error) {
 	return minerEngine, nil
 }
 
-// GetBFTEngine returns the BFT engine
-func GetBFTEngine(privateKey *ecdsa.PrivateKey, folder string) (consensus.Engine, error) {
+// GetDevEngine returns the instant-seal dev engine, blocks are sealed for every batch
+// of transactions if config is nil
+func GetDevEngine(config *dev.Config) consensus.Engine {
+	if config == nil {
+		return dev.NewEngine(0)
+	}
+
+	return dev.NewEngine(config.Period)
+}
+
+// GetBFTEngine returns the BFT engine, the default config is used if config is nil
+func GetBFTEngine(privateKey *ecdsa.PrivateKey, folder string, config *istanbul.Config) (consensus.Engine, error) {
 	path := filepath.Join(folder, common.BFTDataFolder)
 	db, err := leveldb.NewLevelDB(path)
 	if err != nil {
 		return nil, errors.NewStackedError(err, "create bft folder failed")
 	}
 
-	return backend.New(istanbul.DefaultConfig, privateKey, db), nil
+	if config == nil {
+		config = istanbul.DefaultConfig
+	}
+
+	return backend.New(config, privateKey, db), nil
+}
+
+// GetCliqueEngine returns the clique engine signing blocks with the given private key,
+// the default config is used if config is nil
+func GetCliqueEngine(privateKey *ecdsa.PrivateKey, folder string, config *clique.Config) (consensus.Engine, error) {
+	path := filepath.Join(folder, common.CliqueDataFolder)
+	db, err := leveldb.NewLevelDB(path)
+	if err != nil {
+		return nil, errors.NewStackedError(err, "create clique folder failed")
+	}
+
+	if config == nil {
+		config = clique.DefaultConfig
+	}
+
+	return clique.New(config, privateKey, db), nil
 }
@@ -9,6 +9,7 @@ import (
 	"time"
 
 	"github.com/elcn233/go-scdo/common"
+	"github.com/elcn233/go-scdo/core/types"
 	"github.com/ethereum/go-ethereum/event"
 )
 
@@ -58,4 +59,7 @@ type Backend interface {
 
 	// HasBadBlock returns whether the block with the hash is a bad block
 	HasBadProposal(hash common.Hash) bool
+
+	// ReportEvidence delivers the evidence of a double signing validator to backend
+	ReportEvidence(evidence *types.IstanbulEvidence)
 }
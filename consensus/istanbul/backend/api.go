@@ -8,6 +8,7 @@ package backend
 import (
 	"github.com/elcn233/go-scdo/common"
 	"github.com/elcn233/go-scdo/consensus"
+	istanbulCore "github.com/elcn233/go-scdo/consensus/istanbul/core"
 	"github.com/elcn233/go-scdo/core/types"
 	"github.com/elcn233/go-scdo/rpc"
 )
@@ -105,3 +106,21 @@ func (api *API) Discard(address common.Address) {
 
 	delete(api.istanbul.candidates, address)
 }
+
+// GetEvidences returns the pending evidences of the validators who signed conflicting messages.
+func (api *API) GetEvidences() []*types.IstanbulEvidence {
+	api.istanbul.evidencesLock.RLock()
+	defer api.istanbul.evidencesLock.RUnlock()
+
+	evidences := make([]*types.IstanbulEvidence, 0, len(api.istanbul.evidences))
+	for _, evidence := range api.istanbul.evidences {
+		evidences = append(evidences, evidence)
+	}
+	return evidences
+}
+
+// GetStatus returns the current consensus state and the statistics of the received messages,
+// round changes and the time spent in each consensus phase.
+func (api *API) GetStatus() *istanbulCore.Status {
+	return api.istanbul.core.Status()
+}
@@ -6,7 +6,9 @@
 package backend
 
 import (
+	"bytes"
 	"crypto/ecdsa"
+	"sort"
 	"sync"
 	"time"
 
@@ -44,6 +46,7 @@ func New(config *istanbul.Config, privateKey *ecdsa.PrivateKey, db database.Data
 		commitCh:         make(chan *types.Block, 1),
 		recents:          recents,
 		candidates:       make(map[common.Address]bool),
+		evidences:        make(map[common.Address]*types.IstanbulEvidence),
 		coreStarted:      false,
 		recentMessages:   recentMessages,
 		knownMessages:    knownMessages,
@@ -77,6 +80,10 @@ type backend struct {
 	candidates map[common.Address]bool
 	// Protects the signer fields
 	candidatesLock sync.RWMutex
+	// Evidences of double signing validators to be included in blocks
+	evidences map[common.Address]*types.IstanbulEvidence
+	// Protects the evidences
+	evidencesLock sync.RWMutex
 	// Snapshots for recent block to speed up reorgs
 	recents *lru.ARCCache
 
@@ -275,11 +282,11 @@ func (sb *backend) LastProposal() (istanbul.Proposal, common.Address) {
 	block := sb.currentBlock()
 
 	var proposer common.Address
-	if block.Height() > 0 {
+	if block.Height() > common.ScdoForkHeight {
 		var err error
 		proposer, err = sb.Author(block.Header)
 		if err != nil {
-			sb.logger.Error("Failed to get block proposer", "err", err)
+			sb.logger.Error("Failed to get block proposer. err %s", err)
 			return nil, common.Address{}
 		}
 	}
@@ -294,3 +301,49 @@ func (sb *backend) HasBadProposal(hash common.Hash) bool {
 	}
 	return sb.hasBadBlock(hash)
 }
+
+// ReportEvidence implements istanbul.Backend.ReportEvidence
+func (sb *backend) ReportEvidence(evidence *types.IstanbulEvidence) {
+	sb.evidencesLock.Lock()
+	defer sb.evidencesLock.Unlock()
+
+	if _, ok := sb.evidences[evidence.Validator]; !ok {
+		sb.evidences[evidence.Validator] = evidence
+	}
+}
+
+// pendingEvidences returns the evidences of the validators in the given snapshot to be
+// included in the block of the given height, and drops the ones whose validator has already
+// been removed, or which are expired or handled.
+func (sb *backend) pendingEvidences(snap *Snapshot, height uint64) []*types.IstanbulEvidence {
+	sb.evidencesLock.Lock()
+	defer sb.evidencesLock.Unlock()
+
+	var evidences []*types.IstanbulEvidence
+	for address, evidence := range sb.evidences {
+		if _, v := snap.ValSet.GetByAddress(address); v == nil {
+			delete(sb.evidences, address)
+			continue
+		}
+
+		// the evidence of the sequence in consensus is kept for the following blocks
+		sequence, err := istanbul.VerifyEvidence(evidence, height)
+		if err == istanbul.ErrEvidenceExpired && sequence >= height {
+			continue
+		}
+
+		if err != nil || snap.isEvidenceHandled(address, sequence) {
+			delete(sb.evidences, address)
+			continue
+		}
+
+		evidences = append(evidences, evidence)
+	}
+
+	// sort the evidences to make the block content deterministic
+	sort.Slice(evidences, func(i, j int) bool {
+		return bytes.Compare(evidences[i].Validator.Bytes(), evidences[j].Validator.Bytes()) < 0
+	})
+
+	return evidences
+}
@@ -30,7 +30,7 @@ func TestSign(t *testing.T) {
 	//Check signature recover
 	hashData := crypto.Keccak256([]byte(data))
 	pubkey, _ := crypto.SigToPub(hashData, sig)
-	signer := *crypto.GetAddress(pubkey)
+	signer := *crypto.PubkeyToAddress(*pubkey)
 	if signer != getAddress() {
 		t.Errorf("address mismatch: have %v, want %s", signer.Hex(), getAddress().Hex())
 	}
@@ -167,12 +167,12 @@ func TestCommit(t *testing.T) {
 func TestGetProposer(t *testing.T) {
 	chain, engine := newBlockChain(1)
 	block := makeBlock(chain, engine, chain.Genesis())
-	err := chain.WriteBlock(block)
+	err := chain.WriteBlock(block, nil)
 	if err != nil {
 		panic(err)
 	}
 
-	expected := engine.GetProposer(1)
+	expected := engine.GetProposer(block.Height())
 	actual := engine.Address()
 	if actual != expected {
 		t.Errorf("proposer mismatch: have %v, want %v", actual.Hex(), expected.Hex())
@@ -183,14 +183,13 @@ func TestGetProposer(t *testing.T) {
  * SimpleBackend
  * Private key: 0x6396d917f75c3f50bd845bd7fc32e9c1c73f7542b4dde25b6616adf41159a540
  * Public key: 0x04d85058c8d0b689f7b5ecc92728c4ed5dbdd60c01d3277dc6fe453761ce74dc78566c8088d22f8b05855fc26046f6b1e8cad03aa9f533953836ffa2e386567b98
- * Address: 0xb15bf0941562ee58256d1da3f6a6beffaa1b1791
  */
 func getAddress() common.Address {
-	return common.HexMustToAddres("0xb15bf0941562ee58256d1da3f6a6beffaa1b1791")
+	return *crypto.PubkeyToAddress(generatePrivateKey().PublicKey)
 }
 
 func getInvalidAddress() common.Address {
-	return common.HexMustToAddres("0x99ea94bba74858c2ca43fec0050d3da8bc944141")
+	return *crypto.MustGenerateShardAddress(1)
 }
 
 func generatePrivateKey() *ecdsa.PrivateKey {
@@ -210,7 +209,7 @@ func newTestValidatorSet(n int) (istanbul.ValidatorSet, []*ecdsa.PrivateKey) {
 	for i := 0; i < n; i++ {
 		privateKey, _ := crypto.GenerateKey()
 		keys[i] = privateKey
-		addrs[i] = crypto.PubkeyToAddress(privateKey.PublicKey)
+		addrs[i] = *crypto.PubkeyToAddress(privateKey.PublicKey)
 	}
 	vset := validator.NewSet(addrs, istanbul.RoundRobin)
 	sort.Sort(keys) //Keys need to be sorted by its public key address
@@ -18,6 +18,8 @@ import (
 	"github.com/elcn233/go-scdo/consensus/istanbul"
 	istanbulCore "github.com/elcn233/go-scdo/consensus/istanbul/core"
 	"github.com/elcn233/go-scdo/consensus/istanbul/validator"
+	"github.com/elcn233/go-scdo/contract/system"
+	"github.com/elcn233/go-scdo/core/state"
 	"github.com/elcn233/go-scdo/core/types"
 	"github.com/elcn233/go-scdo/crypto"
 	"github.com/elcn233/go-scdo/rpc"
@@ -69,6 +71,12 @@ var (
 	errEmptyCommittedSeals = errors.New("zero committed seals")
 	// errMismatchTxhashes is returned if the TxHash in header is mismatch.
 	errMismatchTxhashes = errors.New("mismatch transcations hashes")
+	// errInvalidEvidence is returned if the evidence in header is invalid or the accused
+	// validator is not in the parent's validator set.
+	errInvalidEvidence = errors.New("invalid evidence")
+	// errStateUnavailable is returned if the validators are loaded from contract but
+	// the chain could not provide the state.
+	errStateUnavailable = errors.New("state unavailable to load validators")
 )
 var (
 	defaultDifficulty = big.NewInt(1)
@@ -81,6 +89,11 @@ var (
 	recentAddresses, _ = lru.NewARC(inmemoryAddresses)
 )
 
+// stateReader is implemented by the chains which could provide the state of a block.
+type stateReader interface {
+	GetState(root common.Hash) (*state.Statedb, error)
+}
+
 // Author retrieves the Ethereum address of the account that minted the given
 // block, which may be different from the header's coinbase if a consensus
 // engine is based on signatures.
@@ -116,7 +129,7 @@ func (sb *backend) verifyHeader(chain consensus.ChainReader, header *types.Block
 	}
 
 	// Ensure that the coinbase is valid
-	if header.Height != 0 && !bytes.Equal(header.Witness[:], nonceAuthVote) && !bytes.Equal(header.Witness[:], nonceDropVote) {
+	if header.Height != common.ScdoForkHeight && !bytes.Equal(header.Witness[:], nonceAuthVote) && !bytes.Equal(header.Witness[:], nonceDropVote) {
 		return errInvalidNonce
 	}
 
@@ -135,7 +148,7 @@ func (sb *backend) verifyHeader(chain consensus.ChainReader, header *types.Block
 func (sb *backend) verifyCascadingFields(chain consensus.ChainReader, header *types.BlockHeader, parents []*types.BlockHeader) error {
 	// The genesis block is the always valid dead-end
 	number := header.Height
-	if number == 0 {
+	if number == common.ScdoForkHeight {
 		return nil
 	}
 	// Ensure that the block's timestamp isn't too close to it's parent
@@ -164,9 +177,43 @@ func (sb *backend) verifyCascadingFields(chain consensus.ChainReader, header *ty
 		return err
 	}
 
+	if err := sb.verifyEvidences(header, snap); err != nil {
+		return err
+	}
+
 	return sb.verifyCommittedSeals(chain, header, parents)
 }
 
+// verifyEvidences checks whether every evidence in the header proves that a validator
+// in parent's validator set signed conflicting messages recently, and the evidence is
+// neither handled in previous blocks nor duplicated in the header
+func (sb *backend) verifyEvidences(header *types.BlockHeader, snap *Snapshot) error {
+	extra, err := types.ExtractIstanbulExtra(header)
+	if err != nil {
+		return err
+	}
+
+	accused := make(map[common.Address]bool)
+	for _, evidence := range extra.Evidences {
+		if _, v := snap.ValSet.GetByAddress(evidence.Validator); v == nil || accused[evidence.Validator] {
+			return errInvalidEvidence
+		}
+		accused[evidence.Validator] = true
+
+		sequence, err := istanbul.VerifyEvidence(evidence, header.Height)
+		if err != nil {
+			sb.logger.Warn("invalid evidence of validator %s, err %s", evidence.Validator.Hex(), err)
+			return errInvalidEvidence
+		}
+
+		if snap.isEvidenceHandled(evidence.Validator, sequence) {
+			return errInvalidEvidence
+		}
+	}
+
+	return nil
+}
+
 // VerifyHeaders is similar to VerifyHeader, but verifies a batch of headers
 // concurrently. The method returns a quit channel to abort the operations and
 // a results channel to retrieve the async verifications (the order is that of
@@ -192,7 +239,7 @@ func (sb *backend) VerifyHeaders(chain consensus.ChainReader, headers []*types.B
 func (sb *backend) verifySigner(chain consensus.ChainReader, header *types.BlockHeader, parents []*types.BlockHeader) error {
 	// Verifying the genesis block is not supported
 	number := header.Height
-	if number == 0 {
+	if number <= common.ScdoForkHeight {
 		return errUnknownBlock
 	}
 
@@ -219,7 +266,7 @@ func (sb *backend) verifySigner(chain consensus.ChainReader, header *types.Block
 func (sb *backend) verifyCommittedSeals(chain consensus.ChainReader, header *types.BlockHeader, parents []*types.BlockHeader) error {
 	number := header.Height
 	// We don't need to verify committed seals in the genesis block
-	if number == 0 {
+	if number == common.ScdoForkHeight {
 		return nil
 	}
 
@@ -247,7 +294,7 @@ func (sb *backend) verifyCommittedSeals(chain consensus.ChainReader, header *typ
 		// 2. Get the original address by seal and parent block hash
 		addr, err := istanbul.GetSignatureAddress(proposalSeal, seal)
 		if err != nil {
-			sb.logger.Error("not a valid address", "err", err)
+			sb.logger.Error("not a valid address. err %s", err)
 			return errInvalidSignature
 		}
 		// Every validator can have only one seal. If more than one seals are signed by a
@@ -272,7 +319,7 @@ func (sb *backend) verifyCommittedSeals(chain consensus.ChainReader, header *typ
 func (sb *backend) VerifySeal(chain consensus.ChainReader, header *types.BlockHeader) error {
 	// get parent header and ensure the signer is in parent's validator set
 	number := header.Height
-	if number == 0 {
+	if number <= common.ScdoForkHeight {
 		return errUnknownBlock
 	}
 
@@ -337,6 +384,13 @@ func (sb *backend) Prepare(chain consensus.ChainReader, header *types.BlockHeade
 	}
 	header.ExtraData = extra
 
+	// add the evidences of double signing validators
+	if evidences := sb.pendingEvidences(snap, number); len(evidences) > 0 {
+		if err = writeEvidences(header, evidences); err != nil {
+			return err
+		}
+	}
+
 	// set header's timestamp
 	header.CreateTimestamp = new(big.Int).Add(parent.CreateTimestamp, new(big.Int).SetUint64(sb.config.BlockPeriod))
 	if header.CreateTimestamp.Int64() < time.Now().Unix() {
@@ -511,9 +565,9 @@ func (sb *backend) snapshot(chain consensus.ChainReader, height uint64, hash com
 				break
 			}
 		}
-		// If we're at block zero, make a snapshot
-		if height == 0 {
-			genesis := chain.GetHeaderByHeight(0)
+		// If we're at the genesis block, make a snapshot
+		if height == common.ScdoForkHeight {
+			genesis := chain.GetHeaderByHeight(height)
 			if err := sb.VerifyHeader(chain, genesis); err != nil {
 				return nil, err
 			}
@@ -521,7 +575,7 @@ func (sb *backend) snapshot(chain consensus.ChainReader, height uint64, hash com
 			if err != nil {
 				return nil, err
 			}
-			snap = newSnapshot(sb.config.Epoch, 0, genesis.Hash(), validator.NewSet(istanbulExtra.Validators, sb.config.ProposerPolicy))
+			snap = newSnapshot(sb.config.Epoch, height, genesis.Hash(), validator.NewSet(istanbulExtra.Validators, sb.config.ProposerPolicy))
 			if err := snap.store(sb.db); err != nil {
 				return nil, err
 			}
@@ -551,7 +605,7 @@ func (sb *backend) snapshot(chain consensus.ChainReader, height uint64, hash com
 	for i := 0; i < len(headers)/2; i++ {
 		headers[i], headers[len(headers)-1-i] = headers[len(headers)-1-i], headers[i]
 	}
-	snap, err := snap.apply(headers)
+	snap, err := snap.apply(headers, sb.validatorLoader(chain))
 	if err != nil {
 		return nil, err
 	}
@@ -567,6 +621,52 @@ func (sb *backend) snapshot(chain consensus.ChainReader, height uint64, hash com
 	return snap, err
 }
 
+// validatorLoader returns the loader of the validators elected in the validator system contract
+// and their bonded stakes as the weights of weighted random policy. It returns nil if the
+// validator set is only changed by votes and the proposer is not picked by weight.
+func (sb *backend) validatorLoader(chain consensus.ChainReader) validatorLoader {
+	weighted := sb.config.ProposerPolicy == istanbul.WeightedRandom
+	if !sb.config.ContractValidators && !weighted {
+		return nil
+	}
+
+	return func(header *types.BlockHeader, current []common.Address) ([]common.Address, map[common.Address]*big.Int, error) {
+		reader, ok := chain.(stateReader)
+		if !ok {
+			return nil, nil, errStateUnavailable
+		}
+
+		statedb, err := reader.GetState(header.StateHash)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		var validators []common.Address
+		if sb.config.ContractValidators {
+			if validators, err = system.GetElectedValidators(statedb); err != nil {
+				return nil, nil, err
+			}
+
+			current = validators
+		}
+
+		if !weighted {
+			return validators, nil, nil
+		}
+
+		weights, err := system.GetValidatorWeights(statedb, current)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		if len(weights) == 0 {
+			sb.logger.Warn("no bonded stake of validators at height %d, the proposer is picked uniformly", header.Height)
+		}
+
+		return validators, weights, nil
+	}
+}
+
 // FIXME: Need to update this for Istanbul
 // sigHash returns the hash which is used as input for the Istanbul
 // signing. It is the hash of the entire header apart from the 65 byte signature
@@ -647,6 +747,24 @@ func writeSeal(h *types.BlockHeader, seal []byte) error {
 	return nil
 }
 
+// writeEvidences writes the extra-data field of a block header with given evidences.
+func writeEvidences(h *types.BlockHeader, evidences []*types.IstanbulEvidence) error {
+	istanbulExtra, err := types.ExtractIstanbulExtra(h)
+	if err != nil {
+		return err
+	}
+
+	istanbulExtra.Evidences = evidences
+
+	payload, err := rlp.EncodeToBytes(&istanbulExtra)
+	if err != nil {
+		return err
+	}
+
+	h.ExtraData = append(h.ExtraData[:types.IstanbulExtraVanity], payload...)
+	return nil
+}
+
 // writeCommittedSeals writes the extra-data field of a block header with given committed seals.
 func writeCommittedSeals(h *types.BlockHeader, committedSeals [][]byte) error {
 	if len(committedSeals) == 0 {
@@ -38,7 +38,7 @@ func newTestGenesis(n int) (*core.Genesis, []*ecdsa.PrivateKey) {
 	var addrs = make([]common.Address, n)
 	for i := 0; i < n; i++ {
 		nodeKeys[i], _ = crypto.GenerateKey()
-		addrs[i] = crypto.PubkeyToAddress(nodeKeys[i].PublicKey)
+		addrs[i] = *crypto.PubkeyToAddress(nodeKeys[i].PublicKey)
 	}
 
 	genesis := core.GetGenesis(core.NewGenesisInfo(accounts, 1, 1, big.NewInt(0), types.IstanbulConsensus, addrs))
@@ -60,7 +60,7 @@ func newBlockChain(n int) (*core.Blockchain, *backend) {
 	config := istanbul.DefaultConfig
 	b, _ := New(config, nodeKeys[0], db).(*backend)
 
-	bc, err := core.NewBlockchain(bcStore, db, "", b, nil)
+	bc, err := core.NewBlockchain(bcStore, db, "", b, nil, -1)
 	if err != nil {
 		panic(err)
 	}
@@ -69,7 +69,7 @@ func newBlockChain(n int) (*core.Blockchain, *backend) {
 		return false
 	})
 
-	snap, err := b.snapshot(bc, 0, common.Hash{}, nil)
+	snap, err := b.snapshot(bc, bc.Genesis().Header.Height, bc.Genesis().HeaderHash, nil)
 	if err != nil {
 		panic(err)
 	}
@@ -83,7 +83,7 @@ func newBlockChain(n int) (*core.Blockchain, *backend) {
 		addr := crypto.PubkeyToAddress(key.PublicKey)
 		if addr.String() == proposerAddr.String() {
 			b.privateKey = key
-			b.address = addr
+			b.address = *addr
 		}
 	}
 
@@ -116,6 +116,10 @@ func makeBlock(chain *core.Blockchain, engine *backend, parent *types.Block) *ty
 func makeBlockWithoutSeal(chain *core.Blockchain, engine *backend, parent *types.Block) *types.Block {
 	header := makeHeader(parent, engine.config)
 	engine.Prepare(chain, header)
+	// the creator is left empty without a vote, reward the validator like the miner in pool mode
+	if header.Creator.IsEmpty() {
+		header.Creator = engine.Address()
+	}
 	reward := consensus.GetReward(header.Height)
 
 	rewardTx, err := txs.NewRewardTx(header.Creator, reward, header.CreateTimestamp.Uint64())
@@ -128,7 +132,7 @@ func makeBlockWithoutSeal(chain *core.Blockchain, engine *backend, parent *types
 		panic(err)
 	}
 
-	rewardTxReceipt, err := core.ApplyRewardTx(rewardTx, state)
+	rewardTxReceipt, err := txs.ApplyRewardTx(rewardTx, state, header)
 	header.StateHash, err = state.Hash()
 	if err != nil {
 		panic(err)
@@ -198,7 +202,7 @@ func TestSealCommittedOtherHash(t *testing.T) {
 	// update block's header
 	b = b.WithSeal(b.Header)
 
-	err = chain.WriteBlock(b)
+	err = chain.WriteBlock(b, nil)
 	if err != nil {
 		panic(err)
 	}
@@ -309,7 +313,7 @@ func TestVerifyHeader(t *testing.T) {
 	block = makeBlockWithoutSeal(chain, engine, chain.Genesis())
 	header = block.Header
 	copy(header.Witness[:], hexutil.MustHexToBytes("0x111111111111"))
-	header.Height = engine.config.Epoch
+	header.Height = common.ScdoForkHeight + engine.config.Epoch
 	err = engine.VerifyHeader(chain, header)
 	if err != errInvalidNonce {
 		t.Errorf("error mismatch: have %v, want %v", err, errInvalidNonce)
@@ -328,7 +332,7 @@ func TestVerifySeal(t *testing.T) {
 	block := makeBlock(chain, engine, genesis)
 	// change block content
 	header := block.Header.Clone()
-	header.Height = 4
+	header.Height = block.Height() + 3
 	block1 := block.WithSeal(header)
 	err = engine.VerifySeal(chain, block1.Header)
 	if err != errUnauthorized {
@@ -373,7 +377,7 @@ func TestVerifySeal(t *testing.T) {
 //			// update block's header
 //			b = b.WithSeal(b.Header)
 //
-//			err = chain.WriteBlock(b)
+//			err = chain.WriteBlock(b, nil)
 //			if err != nil {
 //				panic(err)
 //			}
@@ -398,7 +402,7 @@ func TestVerifySeal(t *testing.T) {
 //			// update block's header
 //			b = b.WithSeal(b.Header)
 //
-//			err = chain.WriteBlock(b)
+//			err = chain.WriteBlock(b, nil)
 //			if err != nil {
 //				panic(err)
 //			}
@@ -8,6 +8,7 @@ package backend
 import (
 	"bytes"
 	"encoding/json"
+	"math/big"
 
 	"github.com/elcn233/go-scdo/common"
 	"github.com/elcn233/go-scdo/consensus/istanbul"
@@ -45,18 +46,27 @@ type Snapshot struct {
 	Votes  []*Vote                  // List of votes cast in chronological order
 	Tally  map[common.Address]Tally // Current vote tally to avoid recalculating
 	ValSet istanbul.ValidatorSet    // Set of authorized validators at this moment
+
+	Proposer common.Address            // Validator who proposed the block of the snapshot
+	Missed   map[common.Address]uint64 // Number of proposals missed by each validator in the current epoch
+
+	Evidences map[common.Address]uint64 // Sequence of the last handled evidence of each validator
 }
 
 // newSnapshot create a new snapshot with the specified startup parameters. This
 // method does not initialize the set of recent validators, so only ever use if for
 // the genesis block.
 func newSnapshot(epoch uint64, number uint64, hash common.Hash, valSet istanbul.ValidatorSet) *Snapshot {
+	valSet.SetSeed(hash)
 	snap := &Snapshot{
 		Epoch:  epoch,
 		Height: number,
 		Hash:   hash,
 		ValSet: valSet,
 		Tally:  make(map[common.Address]Tally),
+		Missed: make(map[common.Address]uint64),
+
+		Evidences: make(map[common.Address]uint64),
 	}
 	return snap
 }
@@ -85,20 +95,36 @@ func (s *Snapshot) store(db database.Database) error {
 	return db.Put(append([]byte(dbKeySnapshotPrefix), s.Hash[:]...), blob)
 }
 
+// isCheckpoint returns whether the block of the given height is a checkpoint,
+// the epochs are counted from the genesis block at common.ScdoForkHeight.
+func (s *Snapshot) isCheckpoint(number uint64) bool {
+	return (number-common.ScdoForkHeight)%s.Epoch == 0
+}
+
 // copy creates a deep copy of the snapshot, though not the individual votes.
 func (s *Snapshot) copy() *Snapshot {
 	cpy := &Snapshot{
-		Epoch:  s.Epoch,
-		Height: s.Height,
-		Hash:   s.Hash,
-		ValSet: s.ValSet.Copy(),
-		Votes:  make([]*Vote, len(s.Votes)),
-		Tally:  make(map[common.Address]Tally),
+		Epoch:    s.Epoch,
+		Height:   s.Height,
+		Hash:     s.Hash,
+		ValSet:   s.ValSet.Copy(),
+		Votes:    make([]*Vote, len(s.Votes)),
+		Tally:    make(map[common.Address]Tally),
+		Proposer: s.Proposer,
+		Missed:   make(map[common.Address]uint64),
+
+		Evidences: make(map[common.Address]uint64),
 	}
 
 	for address, tally := range s.Tally {
 		cpy.Tally[address] = tally
 	}
+	for address, missed := range s.Missed {
+		cpy.Missed[address] = missed
+	}
+	for address, sequence := range s.Evidences {
+		cpy.Evidences[address] = sequence
+	}
 	copy(cpy.Votes, s.Votes)
 
 	return cpy
@@ -147,9 +173,16 @@ func (s *Snapshot) uncast(address common.Address, authorize bool) bool {
 	return true
 }
 
+// validatorLoader returns the validators recorded in the state of the given header and the weights
+// of validators. The validators are nil if the validator set is only changed by votes, in which case
+// the weights are of the given current validators.
+type validatorLoader func(header *types.BlockHeader, current []common.Address) ([]common.Address, map[common.Address]*big.Int, error)
+
 // apply creates a new authorization snapshot by applying the given headers to
-// the original one.
-func (s *Snapshot) apply(headers []*types.BlockHeader) (*Snapshot, error) {
+// the original one. If loader is not nil, the validator set is replaced by the
+// loaded one on every checkpoint block. After the validator contract fork, the validators
+// which missed more proposals than the limit in an epoch are removed on the checkpoint block.
+func (s *Snapshot) apply(headers []*types.BlockHeader, loader validatorLoader) (*Snapshot, error) {
 	// Allow passing in no headers for cleaner code
 	if len(headers) == 0 {
 		return s, nil
@@ -169,7 +202,7 @@ func (s *Snapshot) apply(headers []*types.BlockHeader) (*Snapshot, error) {
 	for _, header := range headers {
 		// Remove any votes on checkpoint blocks
 		number := header.Height
-		if number%s.Epoch == 0 {
+		if s.isCheckpoint(number) {
 			snap.Votes = nil
 			snap.Tally = make(map[common.Address]Tally)
 		}
@@ -182,6 +215,21 @@ func (s *Snapshot) apply(headers []*types.BlockHeader) (*Snapshot, error) {
 			return nil, errUnauthorized
 		}
 
+		// Record the missed proposal if the block is not proposed in the first round. The
+		// proposer of the snapshot stored before is unknown, in which case nothing is missed.
+		snap.ValSet.SetSeed(header.PreviousBlockHash)
+		if snap.Proposer != (common.Address{}) {
+			if expected := snap.expectedProposer(); expected != (common.Address{}) && expected != validator {
+				snap.Missed[expected]++
+			}
+		}
+		snap.Proposer = validator
+
+		// Remove the validators who signed conflicting messages
+		if err := snap.applyEvidences(header); err != nil {
+			return nil, err
+		}
+
 		// Header authorized, discard any previous votes from the validator
 		for i, vote := range snap.Votes {
 			if vote.Validator == validator && vote.Address == header.Creator {
@@ -240,13 +288,126 @@ func (s *Snapshot) apply(headers []*types.BlockHeader) (*Snapshot, error) {
 			}
 			delete(snap.Tally, header.Creator)
 		}
+
+		if s.isCheckpoint(number) {
+			// Load the validators elected in the contract and their weights on checkpoint blocks
+			if loader != nil {
+				if err := snap.loadValidators(header, loader); err != nil {
+					return nil, err
+				}
+			}
+
+			var missedLimit uint64
+			if number >= common.ValidatorContractForkHeight {
+				missedLimit = common.IstanbulMissedProposalLimit
+			}
+			snap.removeOfflineValidators(missedLimit)
+		}
 	}
 	snap.Height += uint64(len(headers))
 	snap.Hash = headers[len(headers)-1].Hash()
+	snap.ValSet.SetSeed(snap.Hash)
 
 	return snap, nil
 }
 
+// loadValidators replaces the validator set with the one loaded from the given header,
+// and discards all the pending votes. The validator set and votes are kept if no validator
+// is loaded, and only the weights of validators are updated if loaded.
+func (s *Snapshot) loadValidators(header *types.BlockHeader, loader validatorLoader) error {
+	validators, weights, err := loader(header, s.validators())
+	if err != nil {
+		return err
+	}
+
+	if len(validators) > 0 {
+		s.ValSet = validator.NewWeightedSet(validators, weights, s.ValSet.Policy())
+		s.Votes = nil
+		s.Tally = make(map[common.Address]Tally)
+	} else if weights != nil {
+		s.ValSet = validator.NewWeightedSet(s.validators(), weights, s.ValSet.Policy())
+	}
+
+	return nil
+}
+
+// expectedProposer returns the proposer of the first round following the snapshot,
+// or an empty address if the validator set is empty.
+func (s *Snapshot) expectedProposer() common.Address {
+	valSet := s.ValSet.Copy()
+	valSet.CalcProposer(s.Proposer, 0)
+	if proposer := valSet.GetProposer(); proposer != nil {
+		return proposer.Address()
+	}
+
+	return common.Address{}
+}
+
+// applyEvidences removes the validators accused by the evidences in the given header,
+// and discards the votes they cast. The last validator is never removed, but the evidences
+// are recorded as handled in any case, and forgotten once they expire.
+func (s *Snapshot) applyEvidences(header *types.BlockHeader) error {
+	extra, err := types.ExtractIstanbulExtra(header)
+	if err != nil {
+		return err
+	}
+
+	for address, sequence := range s.Evidences {
+		if header.Height-sequence > istanbul.MaxEvidenceAge {
+			delete(s.Evidences, address)
+		}
+	}
+
+	for _, evidence := range extra.Evidences {
+		sequence, err := istanbul.VerifyEvidence(evidence, header.Height)
+		if err != nil {
+			return err
+		}
+		s.Evidences[evidence.Validator] = sequence
+
+		if s.ValSet.Size() > 1 && s.ValSet.RemoveValidator(evidence.Validator) {
+			s.discardVotes(evidence.Validator)
+			delete(s.Missed, evidence.Validator)
+		}
+	}
+
+	return nil
+}
+
+// isEvidenceHandled returns whether the evidence of the validator at the given
+// sequence is handled in previous blocks.
+func (s *Snapshot) isEvidenceHandled(address common.Address, sequence uint64) bool {
+	handled, ok := s.Evidences[address]
+	return ok && handled >= sequence
+}
+
+// removeOfflineValidators removes the validators which missed more proposals than
+// the limit in the epoch, and resets the missed proposals for the next epoch.
+// The last validator is never removed.
+func (s *Snapshot) removeOfflineValidators(limit uint64) {
+	if limit > 0 {
+		for _, address := range s.validators() {
+			if s.Missed[address] > limit && s.ValSet.Size() > 1 {
+				s.ValSet.RemoveValidator(address)
+				s.discardVotes(address)
+			}
+		}
+	}
+
+	s.Missed = make(map[common.Address]uint64)
+}
+
+// discardVotes removes the votes cast by the given validator.
+func (s *Snapshot) discardVotes(address common.Address) {
+	for i := 0; i < len(s.Votes); i++ {
+		if s.Votes[i].Validator == address {
+			s.uncast(s.Votes[i].Address, s.Votes[i].Authorize)
+			s.Votes = append(s.Votes[:i], s.Votes[i+1:]...)
+			i--
+		}
+	}
+}
+
 // validators retrieves the list of authorized validators in ascending order.
 func (s *Snapshot) validators() []common.Address {
 	validators := make([]common.Address, 0, s.ValSet.Size())
@@ -270,9 +431,17 @@ type snapshotJSON struct {
 	Votes  []*Vote                  `json:"votes"`
 	Tally  map[common.Address]Tally `json:"tally"`
 
+	// for missed proposals
+	Proposer common.Address            `json:"proposer"`
+	Missed   map[common.Address]uint64 `json:"missed"`
+
+	// for handled evidences
+	Evidences map[common.Address]uint64 `json:"evidences,omitempty"`
+
 	// for validator set
-	Validators []common.Address        `json:"validators"`
-	Policy     istanbul.ProposerPolicy `json:"policy"`
+	Validators []common.Address            `json:"validators"`
+	Policy     istanbul.ProposerPolicy     `json:"policy"`
+	Weights    map[common.Address]*big.Int `json:"weights,omitempty"`
 }
 
 func (s *Snapshot) toJSONStruct() *snapshotJSON {
@@ -282,8 +451,12 @@ func (s *Snapshot) toJSONStruct() *snapshotJSON {
 		Hash:       s.Hash,
 		Votes:      s.Votes,
 		Tally:      s.Tally,
+		Proposer:   s.Proposer,
+		Missed:     s.Missed,
+		Evidences:  s.Evidences,
 		Validators: s.validators(),
 		Policy:     s.ValSet.Policy(),
+		Weights:    s.ValSet.Weights(),
 	}
 }
 
@@ -299,7 +472,17 @@ func (s *Snapshot) UnmarshalJSON(b []byte) error {
 	s.Hash = j.Hash
 	s.Votes = j.Votes
 	s.Tally = j.Tally
-	s.ValSet = validator.NewSet(j.Validators, j.Policy)
+	s.Proposer = j.Proposer
+	s.Missed = j.Missed
+	if s.Missed == nil {
+		s.Missed = make(map[common.Address]uint64)
+	}
+	s.Evidences = j.Evidences
+	if s.Evidences == nil {
+		s.Evidences = make(map[common.Address]uint64)
+	}
+	s.ValSet = validator.NewWeightedSet(j.Validators, j.Weights, j.Policy)
+	s.ValSet.SetSeed(s.Hash)
 	return nil
 }
 
@@ -20,6 +20,7 @@ import (
 	"github.com/elcn233/go-scdo/core/types"
 	"github.com/elcn233/go-scdo/crypto"
 	"github.com/elcn233/go-scdo/database/leveldb"
+	"github.com/ethereum/go-ethereum/rlp"
 )
 
 type testerVote struct {
@@ -59,7 +60,7 @@ func (ap *testerAccountPool) address(account string) common.Address {
 		ap.accounts[account], _ = crypto.GenerateKey()
 	}
 	// Resolve and return the Ethereum address
-	return crypto.PubkeyToAddress(ap.accounts[account].PublicKey)
+	return *crypto.PubkeyToAddress(ap.accounts[account].PublicKey)
 }
 
 // Tests that voting is evaluated correctly for various simple and complex scenarios.
@@ -328,18 +329,19 @@ func TestVoting(t *testing.T) {
 			panic(err)
 		}
 
-		config := istanbul.DefaultConfig
+		// copy the default config, the epoch must not leak into the other cases
+		config := *istanbul.DefaultConfig
 		if tt.epoch != 0 {
 			config.Epoch = tt.epoch
 		}
-		engine := New(config, accounts.accounts[tt.validators[0]], db).(*backend)
-		chain, err := core2.NewBlockchain(bcStore, db, "", engine, nil)
+		engine := New(&config, accounts.accounts[tt.validators[0]], db).(*backend)
+		chain, err := core2.NewBlockchain(bcStore, db, "", engine, nil, -1)
 
 		// Assemble a chain of headers from the cast votes
 		headers := make([]*types.BlockHeader, len(tt.votes))
 		for j, vote := range tt.votes {
 			headers[j] = &types.BlockHeader{
-				Height:          uint64(j) + 1,
+				Height:          chain.Genesis().Header.Height + uint64(j) + 1,
 				CreateTimestamp: big.NewInt(int64(j) * int64(config.BlockPeriod)),
 				Creator:         accounts.address(vote.voted),
 				Difficulty:      defaultDifficulty,
@@ -350,6 +352,8 @@ func TestVoting(t *testing.T) {
 			headers[j].ExtraData = extra
 			if j > 0 {
 				headers[j].PreviousBlockHash = headers[j-1].Hash()
+			} else {
+				headers[j].PreviousBlockHash = chain.Genesis().HeaderHash
 			}
 			if vote.auth {
 				copy(headers[j].Witness[:], nonceAuthVote)
@@ -392,27 +396,32 @@ func TestVoting(t *testing.T) {
 }
 
 func TestSaveAndLoad(t *testing.T) {
+	// the snapshot is stored as json, which only accepts valid account addresses
+	addrs := make([]common.Address, 5)
+	for i := range addrs {
+		addrs[i] = *crypto.MustGenerateShardAddress(1)
+	}
 	snap := &Snapshot{
 		Epoch:  5,
 		Height: 10,
 		Hash:   common.EmptyHash,
 		Votes: []*Vote{
 			{
-				Validator: common.BytesToAddress([]byte{1}),
+				Validator: addrs[0],
 				Block:     15,
-				Address:   common.BytesToAddress([]byte{2}),
+				Address:   addrs[1],
 				Authorize: false,
 			},
 		},
 		Tally: map[common.Address]Tally{
-			common.BytesToAddress([]byte{3}): Tally{
+			addrs[2]: Tally{
 				Authorize: false,
 				Votes:     20,
 			},
 		},
 		ValSet: validator.NewSet([]common.Address{
-			common.BytesToAddress([]byte{4}),
-			common.BytesToAddress([]byte{5}),
+			addrs[3],
+			addrs[4],
 		}, istanbul.RoundRobin),
 	}
 	db, _ := leveldb.NewTestDatabase()
@@ -441,3 +450,260 @@ func TestSaveAndLoad(t *testing.T) {
 		t.Errorf("validator set mismatch: have %v, want %v", snap1.ValSet, snap.ValSet)
 	}
 }
+
+func TestLoadValidators(t *testing.T) {
+	snap := newSnapshot(5, 10, common.EmptyHash, validator.NewSet([]common.Address{
+		common.BytesToAddress([]byte{1}),
+		common.BytesToAddress([]byte{2}),
+	}, istanbul.RoundRobin))
+	snap.Votes = []*Vote{{Validator: common.BytesToAddress([]byte{1}), Block: 9, Address: common.BytesToAddress([]byte{3}), Authorize: true}}
+	snap.Tally[common.BytesToAddress([]byte{3})] = Tally{Authorize: true, Votes: 1}
+
+	// nothing elected, keep the validators and votes
+	empty := func(header *types.BlockHeader, current []common.Address) ([]common.Address, map[common.Address]*big.Int, error) {
+		return nil, nil, nil
+	}
+	if err := snap.loadValidators(&types.BlockHeader{Height: 10}, empty); err != nil {
+		t.Errorf("load validators failed: %v", err)
+	}
+	if snap.ValSet.Size() != 2 || len(snap.Votes) != 1 || len(snap.Tally) != 1 {
+		t.Errorf("snapshot changed by empty election: %v", snap.validators())
+	}
+
+	// replace the validators and discard the votes
+	elected := []common.Address{common.BytesToAddress([]byte{4}), common.BytesToAddress([]byte{5}), common.BytesToAddress([]byte{6})}
+	weights := map[common.Address]*big.Int{elected[0]: big.NewInt(1), elected[1]: big.NewInt(2), elected[2]: big.NewInt(3)}
+	loader := func(header *types.BlockHeader, current []common.Address) ([]common.Address, map[common.Address]*big.Int, error) {
+		return elected, weights, nil
+	}
+	if err := snap.loadValidators(&types.BlockHeader{Height: 10}, loader); err != nil {
+		t.Errorf("load validators failed: %v", err)
+	}
+	if !reflect.DeepEqual(snap.validators(), elected) {
+		t.Errorf("validators mismatch: have %v, want %v", snap.validators(), elected)
+	}
+	if snap.ValSet.Policy() != istanbul.RoundRobin {
+		t.Errorf("policy mismatch: have %v, want %v", snap.ValSet.Policy(), istanbul.RoundRobin)
+	}
+	if !reflect.DeepEqual(snap.ValSet.Weights(), weights) {
+		t.Errorf("weights mismatch: have %v, want %v", snap.ValSet.Weights(), weights)
+	}
+	if len(snap.Votes) != 0 || len(snap.Tally) != 0 {
+		t.Errorf("votes not discarded: %v, %v", snap.Votes, snap.Tally)
+	}
+
+	// only update the weights of the validators changed by votes
+	snap.Votes = []*Vote{{Validator: elected[0], Block: 19, Address: common.BytesToAddress([]byte{7}), Authorize: true}}
+	deposits := map[common.Address]*big.Int{elected[1]: big.NewInt(10)}
+	weighter := func(header *types.BlockHeader, current []common.Address) ([]common.Address, map[common.Address]*big.Int, error) {
+		return nil, deposits, nil
+	}
+	if err := snap.loadValidators(&types.BlockHeader{Height: 20}, weighter); err != nil {
+		t.Errorf("load validators failed: %v", err)
+	}
+	if !reflect.DeepEqual(snap.validators(), elected) || len(snap.Votes) != 1 {
+		t.Errorf("validators or votes changed: %v, %v", snap.validators(), snap.Votes)
+	}
+	if !reflect.DeepEqual(snap.ValSet.Weights(), deposits) {
+		t.Errorf("weights mismatch: have %v, want %v", snap.ValSet.Weights(), deposits)
+	}
+}
+
+func TestRemoveOfflineValidators(t *testing.T) {
+	addrs := []common.Address{
+		common.BytesToAddress([]byte{1}),
+		common.BytesToAddress([]byte{2}),
+		common.BytesToAddress([]byte{3}),
+	}
+	snap := newSnapshot(5, 10, common.EmptyHash, validator.NewSet(addrs, istanbul.RoundRobin))
+	snap.Votes = []*Vote{{Validator: addrs[0], Block: 9, Address: common.BytesToAddress([]byte{4}), Authorize: true}}
+	snap.Tally[common.BytesToAddress([]byte{4})] = Tally{Authorize: true, Votes: 1}
+	snap.Missed[addrs[0]] = 3
+	snap.Missed[addrs[1]] = 2
+
+	// disabled, keep all the validators
+	snap.copy().removeOfflineValidators(0)
+	if snap.ValSet.Size() != 3 {
+		t.Errorf("validators removed when disabled: %v", snap.validators())
+	}
+
+	snap.removeOfflineValidators(2)
+	if !reflect.DeepEqual(snap.validators(), addrs[1:]) {
+		t.Errorf("validators mismatch: have %v, want %v", snap.validators(), addrs[1:])
+	}
+	if len(snap.Votes) != 0 || len(snap.Tally) != 0 {
+		t.Errorf("votes of removed validator not discarded: %v, %v", snap.Votes, snap.Tally)
+	}
+	if len(snap.Missed) != 0 {
+		t.Errorf("missed proposals not reset: %v", snap.Missed)
+	}
+
+	// never remove the last validator
+	snap = newSnapshot(5, 10, common.EmptyHash, validator.NewSet(addrs[:1], istanbul.RoundRobin))
+	snap.Missed[addrs[0]] = 3
+	snap.removeOfflineValidators(2)
+	if snap.ValSet.Size() != 1 {
+		t.Errorf("the last validator removed: %v", snap.validators())
+	}
+}
+
+func TestExpectedProposer(t *testing.T) {
+	addrs := []common.Address{
+		common.BytesToAddress([]byte{1}),
+		common.BytesToAddress([]byte{2}),
+		common.BytesToAddress([]byte{3}),
+	}
+
+	testCases := []struct {
+		policy   istanbul.ProposerPolicy
+		proposer common.Address
+		expected common.Address
+	}{
+		{istanbul.RoundRobin, common.Address{}, addrs[0]},
+		{istanbul.RoundRobin, addrs[0], addrs[1]},
+		{istanbul.RoundRobin, addrs[2], addrs[0]},
+		{istanbul.Sticky, addrs[1], addrs[1]},
+	}
+
+	for i, test := range testCases {
+		snap := newSnapshot(5, 10, common.EmptyHash, validator.NewSet(addrs, test.policy))
+		snap.Proposer = test.proposer
+		if proposer := snap.expectedProposer(); proposer != test.expected {
+			t.Errorf("test %d: proposer mismatch: have %v, want %v", i, proposer, test.expected)
+		}
+	}
+}
+
+func TestApplyMissedProposal(t *testing.T) {
+	accounts := newTesterAccountPool()
+	names := map[common.Address]string{accounts.address("A"): "A", accounts.address("B"): "B"}
+	addrs := []common.Address{accounts.address("A"), accounts.address("B")}
+
+	for i, proposer := range []common.Address{{}, addrs[0]} {
+		snap := newSnapshot(5, 10, common.EmptyHash, validator.NewSet(addrs, istanbul.RoundRobin))
+		snap.Proposer = proposer
+
+		header := &types.BlockHeader{
+			Height:            11,
+			PreviousBlockHash: snap.Hash,
+			Witness:           make([]byte, istanbul.WitnessSize),
+			Consensus:         types.IstanbulConsensus,
+		}
+		header.ExtraData, _ = prepareExtra(header, addrs)
+
+		// the block is proposed by the validator not expected in the first round
+		snap.ValSet.SetSeed(header.PreviousBlockHash)
+		expected := snap.expectedProposer()
+		for address, name := range names {
+			if address != expected {
+				accounts.sign(header, name)
+			}
+		}
+
+		result, err := snap.apply([]*types.BlockHeader{header}, nil)
+		if err != nil {
+			t.Fatalf("test %d: failed to apply header: %v", i, err)
+		}
+
+		// the proposer of the snapshot is unknown, so nothing is missed
+		want := map[common.Address]uint64{}
+		if proposer != (common.Address{}) {
+			want[expected] = 1
+		}
+		if !reflect.DeepEqual(result.Missed, want) {
+			t.Errorf("test %d: missed proposals mismatch: have %v, want %v", i, result.Missed, want)
+		}
+	}
+}
+
+// newTestEvidence returns the evidence that the key signed conflicting COMMIT messages at the sequence.
+func newTestEvidence(t *testing.T, key *ecdsa.PrivateKey, sequence int64) *types.IstanbulEvidence {
+	address := *crypto.PubkeyToAddress(key.PublicKey)
+	commit := func(digest common.Hash) []byte {
+		subject, err := rlp.EncodeToBytes(&istanbul.Subject{
+			View:   &istanbul.View{Round: big.NewInt(0), Sequence: big.NewInt(sequence)},
+			Digest: digest,
+		})
+		if err != nil {
+			t.Fatalf("failed to encode subject: %v", err)
+		}
+
+		unsigned, _ := rlp.EncodeToBytes([]interface{}{istanbul.MsgCommitCode, subject, address, []byte{}, []byte{}})
+		sig, err := crypto.Sign(key, crypto.Keccak256(unsigned))
+		if err != nil {
+			t.Fatalf("failed to sign message: %v", err)
+		}
+
+		payload, _ := rlp.EncodeToBytes([]interface{}{istanbul.MsgCommitCode, subject, address, sig.Sig, []byte{}})
+		return payload
+	}
+
+	return &types.IstanbulEvidence{
+		Validator: address,
+		First:     commit(common.StringToHash("first")),
+		Second:    commit(common.StringToHash("second")),
+	}
+}
+
+// newEvidenceHeader returns the header of the height which includes the evidences.
+func newEvidenceHeader(t *testing.T, height uint64, validators []common.Address, evidences []*types.IstanbulEvidence) *types.BlockHeader {
+	header := &types.BlockHeader{Height: height}
+	extra, err := prepareExtra(header, validators)
+	if err != nil {
+		t.Fatalf("prepare extra failed: %v", err)
+	}
+	header.ExtraData = extra
+
+	if err := writeEvidences(header, evidences); err != nil {
+		t.Fatalf("write evidences failed: %v", err)
+	}
+
+	return header
+}
+
+func TestApplyEvidences(t *testing.T) {
+	keys := make([]*ecdsa.PrivateKey, 2)
+	addrs := make([]common.Address, 2)
+	evidences := make([]*types.IstanbulEvidence, 2)
+	for i := range keys {
+		keys[i], _ = crypto.GenerateKey()
+		evidences[i] = newTestEvidence(t, keys[i], 10)
+		addrs[i] = evidences[i].Validator
+	}
+	if bytes.Compare(addrs[0].Bytes(), addrs[1].Bytes()) > 0 {
+		addrs[0], addrs[1] = addrs[1], addrs[0]
+		evidences[0], evidences[1] = evidences[1], evidences[0]
+	}
+	snap := newSnapshot(5, 10, common.EmptyHash, validator.NewSet(addrs, istanbul.RoundRobin))
+
+	if err := snap.applyEvidences(newEvidenceHeader(t, 11, addrs, evidences)); err != nil {
+		t.Errorf("apply evidences failed: %v", err)
+	}
+
+	// the last validator is kept, but its evidence is handled
+	if !reflect.DeepEqual(snap.validators(), addrs[1:]) {
+		t.Errorf("validators mismatch: have %v, want %v", snap.validators(), addrs[1:])
+	}
+	for _, address := range addrs {
+		if !snap.isEvidenceHandled(address, 10) {
+			t.Errorf("evidence of %v is not handled", address)
+		}
+	}
+	if snap.isEvidenceHandled(addrs[1], 11) {
+		t.Errorf("evidence of later sequence should not be handled")
+	}
+
+	// the evidence expired is forgotten
+	if err := snap.applyEvidences(newEvidenceHeader(t, 11+istanbul.MaxEvidenceAge, addrs[1:], nil)); err != nil {
+		t.Errorf("apply evidences failed: %v", err)
+	}
+	if len(snap.Evidences) != 0 {
+		t.Errorf("expired evidences are not forgotten: %v", snap.Evidences)
+	}
+
+	// the invalid evidence is rejected
+	invalid := []*types.IstanbulEvidence{newTestEvidence(t, keys[0], 20)}
+	if err := snap.applyEvidences(newEvidenceHeader(t, 20, addrs[1:], invalid)); err != istanbul.ErrEvidenceExpired {
+		t.Errorf("error mismatch: have %v, want %v", err, istanbul.ErrEvidenceExpired)
+	}
+}
@@ -10,6 +10,7 @@ type ProposerPolicy uint64
 const (
 	RoundRobin ProposerPolicy = iota
 	Sticky
+	WeightedRandom // pseudo-randomly picks the proposer by bonded stake in the validator contract, seeded from the last block hash
 )
 
 type Config struct {
@@ -17,6 +18,8 @@ type Config struct {
 	BlockPeriod    uint64         `toml:",omitempty"` // Default minimum difference between two consecutive block's timestamps in second
 	ProposerPolicy ProposerPolicy `toml:",omitempty"` // The policy for proposer selection
 	Epoch          uint64         `toml:",omitempty"` // The number of blocks after which to checkpoint and reset the pending votes
+
+	ContractValidators bool `toml:",omitempty"` // Whether to load the validator set from the validator system contract at every epoch
 }
 
 var DefaultConfig = &Config{
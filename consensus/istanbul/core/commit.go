@@ -49,6 +49,8 @@ func (c *core) handleCommit(msg *message, src istanbul.Validator) error {
 		return err
 	}
 
+	c.checkDoubleCommit(msg, commit)
+
 	if err := c.verifyCommit(commit, src); err != nil {
 		return err
 	}
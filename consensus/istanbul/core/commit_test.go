@@ -157,12 +157,13 @@ OUTER:
 		for i, v := range test.system.backends {
 			validator := r0.valSet.GetByIndex(uint64(i))
 			m, _ := Encode(v.engine.(*core).current.Subject())
+			addr := validator.Address()
 			if err := r0.handleCommit(&message{
 				Code:          msgCommit,
 				Msg:           m,
 				Address:       validator.Address(),
 				Signature:     []byte{},
-				CommittedSeal: validator.Address().Bytes(), // small hack
+				CommittedSeal: addr.Bytes(), // small hack
 			}, validator); err != nil {
 				if err != test.expectedErr {
 					t.Errorf("error mismatch: have %v, want %v", err, test.expectedErr)
@@ -198,8 +199,9 @@ OUTER:
 		signedCount := 0
 		committedSeals := v0.committedMsgs[0].committedSeals
 		for _, validator := range r0.valSet.List() {
+			addr := validator.Address()
 			for _, seal := range committedSeals {
-				if bytes.Compare(validator.Address().Bytes(), seal[:common.AddressLen]) == 0 {
+				if bytes.Compare(addr.Bytes(), seal[:common.AddressLen]) == 0 {
 					signedCount++
 					break
 				}
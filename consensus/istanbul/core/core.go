@@ -38,6 +38,10 @@ func New(backend istanbul.Backend, config *istanbul.Config) Engine {
 		roundMeter:         metrics.GetOrRegisterMeter("consensus/istanbul/core/round", nil),
 		sequenceMeter:      metrics.GetOrRegisterMeter("consensus/istanbul/core/sequence", nil),
 		consensusTimer:     metrics.GetOrRegisterTimer("consensus/istanbul/core/consensus", nil),
+		phaseHistograms:    newPhaseHistograms(),
+		received:           make(map[common.Address]*MessageCounts),
+		statsMu:            new(sync.RWMutex),
+		statusRequests:     make(chan chan *Status),
 	}
 	c.validateFn = c.checkValidatorSignature
 	return c
@@ -80,6 +84,19 @@ type core struct {
 	sequenceMeter metrics.Meter
 	// the timer to record consensus duration (from accepting a preprepare to final committed stage)
 	consensusTimer metrics.Timer
+	// the histograms to record the time spent in each state
+	phaseHistograms map[State]metrics.Histogram
+	// the time when current state is entered
+	stateTimestamp time.Time
+
+	// the statistics of received messages and round changes
+	received     map[common.Address]*MessageCounts
+	roundChanges []*RoundChange
+	statsMu      *sync.RWMutex
+
+	// the requests of status served in the event loop, which is running if not 0
+	statusRequests chan chan *Status
+	running        int32
 }
 
 func (c *core) finalizeMessage(msg *message) ([]byte, error) {
@@ -213,6 +230,9 @@ func (c *core) startNewRound(round *big.Int) {
 	c.roundChangeSet = newRoundChangeSet(c.valSet)
 	// New snapshot for new round
 	c.updateRoundState(newView, c.valSet, roundChange)
+	if roundChange {
+		c.recordRoundChange(newView.Sequence, newView.Round)
+	}
 	// Calculate new proposer
 	c.valSet.CalcProposer(lastProposer, newView.Round.Uint64())
 	c.waitingForRoundChange = false
@@ -231,7 +251,7 @@ func (c *core) startNewRound(round *big.Int) {
 	}
 	c.newRoundChangeTimer()
 
-	c.logger.Debug("New round", "new_round", newView.Round, "new_seq", newView.Sequence, "new_proposer", c.valSet.GetProposer(), "valSet", c.valSet.List(), "size", c.valSet.Size(), "isProposer", c.isProposer())
+	c.logger.Debug("New round. new_round %d. new_seq %d. new_proposer %s. valSet %v. size %d. isProposer %t", newView.Round, newView.Sequence, c.valSet.GetProposer(), c.valSet.List(), c.valSet.Size(), c.isProposer())
 }
 
 func (c *core) catchUpRound(view *istanbul.View) {
@@ -264,6 +284,7 @@ func (c *core) updateRoundState(view *istanbul.View, validatorSet istanbul.Valid
 
 func (c *core) setState(state State) {
 	if c.state != state {
+		c.observePhase(c.state)
 		c.state = state
 	}
 	if state == StateAcceptRequest {
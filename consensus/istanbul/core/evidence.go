@@ -0,0 +1,45 @@
+/**
+*  @file
+*  @copyright defined in scdo/LICENSE
+ */
+
+package core
+
+import (
+	"github.com/elcn233/go-scdo/consensus/istanbul"
+	"github.com/elcn233/go-scdo/core/types"
+)
+
+// checkDoubleCommit checks whether the sender of the COMMIT message has sent another
+// COMMIT message with a different digest in the current round, and reports the evidence
+// to backend if it has.
+func (c *core) checkDoubleCommit(msg *message, commit *istanbul.Subject) {
+	seen := c.current.RecordCommit(msg)
+	if seen == nil {
+		return
+	}
+
+	var seenCommit *istanbul.Subject
+	if err := seen.Decode(&seenCommit); err != nil || seenCommit.Digest == commit.Digest {
+		return
+	}
+
+	first, err := seen.Payload()
+	if err != nil {
+		return
+	}
+
+	second, err := msg.Payload()
+	if err != nil {
+		return
+	}
+
+	evidence := &types.IstanbulEvidence{
+		Validator: msg.Address,
+		First:     first,
+		Second:    second,
+	}
+
+	c.logger.Warn("Validator %s signed conflicting COMMIT messages. view %v", msg.Address.Hex(), commit.View)
+	c.backend.ReportEvidence(evidence)
+}
@@ -0,0 +1,58 @@
+/**
+*  @file
+*  @copyright defined in scdo/LICENSE
+ */
+
+package core
+
+import (
+	"bytes"
+	"math/big"
+	"testing"
+
+	"github.com/elcn233/go-scdo/common"
+	"github.com/elcn233/go-scdo/consensus/istanbul"
+)
+
+func TestCheckDoubleCommit(t *testing.T) {
+	if msgCommit != istanbul.MsgCommitCode {
+		t.Fatalf("commit code mismatch: have %v, want %v", istanbul.MsgCommitCode, msgCommit)
+	}
+
+	sys := NewTestSystemWithBackend(4, 1)
+	backend := sys.backends[0]
+	c := backend.engine.(*core)
+	c.valSet = backend.peers
+
+	view := &istanbul.View{Round: big.NewInt(0), Sequence: big.NewInt(1)}
+	c.current = newTestRoundState(view, c.valSet)
+
+	src := c.valSet.GetByIndex(1)
+	newCommit := func(digest common.Hash) (*message, *istanbul.Subject) {
+		subject := &istanbul.Subject{View: view, Digest: digest}
+		encoded, _ := Encode(subject)
+		return &message{Code: msgCommit, Msg: encoded, Address: src.Address()}, subject
+	}
+
+	// the same COMMIT message twice is not a double signing
+	msg, subject := newCommit(common.StringToHash("first"))
+	c.checkDoubleCommit(msg, subject)
+	c.checkDoubleCommit(msg, subject)
+	if len(backend.evidences) != 0 {
+		t.Errorf("unexpected evidence: %v", backend.evidences)
+	}
+
+	msg, subject = newCommit(common.StringToHash("second"))
+	c.checkDoubleCommit(msg, subject)
+	if len(backend.evidences) != 1 {
+		t.Fatalf("the number of evidences mismatch: have %v, want 1", len(backend.evidences))
+	}
+	if backend.evidences[0].Validator != src.Address() {
+		t.Errorf("validator mismatch: have %v, want %v", backend.evidences[0].Validator, src.Address())
+	}
+
+	second, _ := msg.Payload()
+	if !bytes.Equal(backend.evidences[0].Second, second) {
+		t.Errorf("second payload mismatch: have %v, want %v", backend.evidences[0].Second, second)
+	}
+}
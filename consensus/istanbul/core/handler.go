@@ -6,6 +6,8 @@
 package core
 
 import (
+	"sync/atomic"
+
 	"github.com/elcn233/go-scdo/common"
 	"github.com/elcn233/go-scdo/consensus/istanbul"
 )
@@ -17,8 +19,7 @@ func (c *core) Start() error {
 
 	// Tests will handle events itself, so we have to make subscribeEvents()
 	// be able to call in test.
-	c.subscribeEvents()
-	go c.handleEvents()
+	c.startHandler()
 
 	return nil
 }
@@ -33,6 +34,13 @@ func (c *core) Stop() error {
 	return nil
 }
 
+// startHandler subscribes the events and starts the event loop
+func (c *core) startHandler() {
+	c.subscribeEvents()
+	atomic.StoreInt32(&c.running, 1)
+	go c.handleEvents()
+}
+
 // ----------------------------------------------------------------------------
 
 // Subscribe both internal and external events
@@ -62,6 +70,7 @@ func (c *core) unsubscribeEvents() {
 func (c *core) handleEvents() {
 	// Clear state
 	defer func() {
+		atomic.StoreInt32(&c.running, 0)
 		c.current = nil
 		c.handlerWg.Done()
 	}()
@@ -93,7 +102,7 @@ func (c *core) handleEvents() {
 				if err := c.handleCheckedMsg(ev.msg, ev.src); err == nil {
 					p, err := ev.msg.Payload()
 					if err != nil {
-						c.logger.Warn("Get message payload failed", "err", err)
+						c.logger.Warn("Get message payload failed. err %s", err)
 						continue
 					}
 					c.backend.Gossip(c.valSet, p)
@@ -112,6 +121,8 @@ func (c *core) handleEvents() {
 			case istanbul.FinalCommittedEvent:
 				c.handleFinalCommitted()
 			}
+		case result := <-c.statusRequests:
+			result <- c.status(true)
 		}
 	}
 }
@@ -137,6 +148,7 @@ func (c *core) handleMsg(payload []byte) error {
 		return istanbul.ErrUnauthorizedAddress
 	}
 
+	c.countMessage(msg)
 	return c.handleCheckedMsg(msg, src)
 }
 
@@ -44,6 +44,9 @@ type roundState struct {
 
 	mu             *sync.RWMutex
 	hasBadProposal func(hash common.Hash) bool
+
+	// the first COMMIT message of each validator in this round, used to detect double signing
+	seenCommits map[common.Address]*message
 }
 
 func (s *roundState) GetPrepareOrCommitSize() int {
@@ -61,6 +64,24 @@ func (s *roundState) GetPrepareOrCommitSize() int {
 	return result
 }
 
+// RecordCommit records the first COMMIT message of the message sender in this round,
+// and returns the recorded one if the sender has already sent a COMMIT message.
+func (s *roundState) RecordCommit(msg *message) *message {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.seenCommits == nil {
+		s.seenCommits = make(map[common.Address]*message)
+	}
+
+	if seen, ok := s.seenCommits[msg.Address]; ok {
+		return seen
+	}
+
+	s.seenCommits[msg.Address] = msg
+	return nil
+}
+
 func (s *roundState) Subject() *istanbul.Subject {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
@@ -0,0 +1,198 @@
+/**
+*  @file
+*  @copyright defined in scdo/LICENSE
+ */
+
+package core
+
+import (
+	"math/big"
+	"sync/atomic"
+	"time"
+
+	"github.com/elcn233/go-scdo/common"
+	"github.com/rcrowley/go-metrics"
+)
+
+const (
+	// maxRoundChangeHistory is the max number of round changes kept in the history
+	maxRoundChangeHistory = 64
+	// statusTimeout is the max time to wait for the event loop to take the status
+	statusTimeout = time.Second
+)
+
+// Status is the consensus state of istanbul core with the message statistics
+type Status struct {
+	Sequence              *big.Int                          `json:"sequence"`
+	Round                 *big.Int                          `json:"round"`
+	State                 string                            `json:"state"`
+	Proposer              common.Address                    `json:"proposer"`
+	IsProposer            bool                              `json:"isProposer"`
+	WaitingForRoundChange bool                              `json:"waitingForRoundChange"`
+	Prepares              []common.Address                  `json:"prepares"` // validators whose PREPARE are accepted in current round
+	Commits               []common.Address                  `json:"commits"`  // validators whose COMMIT are accepted in current round
+	Backlogs              map[common.Address]int            `json:"backlogs"` // number of future messages of each validator
+	Received              map[common.Address]*MessageCounts `json:"received"` // number of messages received from each validator
+	RoundChanges          []*RoundChange                    `json:"roundChanges"`
+	PhaseLatency          map[string]*Latency               `json:"phaseLatency"`
+}
+
+// MessageCounts is the number of messages received from a validator
+type MessageCounts struct {
+	Preprepare  uint64 `json:"preprepare"`
+	Prepare     uint64 `json:"prepare"`
+	Commit      uint64 `json:"commit"`
+	RoundChange uint64 `json:"roundChange"`
+}
+
+// RoundChange is a record of the round change
+type RoundChange struct {
+	Sequence  *big.Int `json:"sequence"`
+	Round     *big.Int `json:"round"`
+	Timestamp int64    `json:"timestamp"`
+}
+
+// Latency is the statistics of the time spent in a consensus phase in milliseconds
+type Latency struct {
+	Count int64   `json:"count"`
+	Mean  float64 `json:"mean"`
+	P50   float64 `json:"p50"`
+	P95   float64 `json:"p95"`
+	Max   int64   `json:"max"`
+}
+
+// newPhaseHistograms creates the histograms to record the time spent in each state
+func newPhaseHistograms() map[State]metrics.Histogram {
+	histograms := make(map[State]metrics.Histogram)
+	for _, state := range []State{StateAcceptRequest, StatePreprepared, StatePrepared, StateCommitted} {
+		name := "consensus/istanbul/core/phase/" + state.String()
+		histograms[state] = metrics.GetOrRegisterHistogram(name, nil, metrics.NewExpDecaySample(1028, 0.015))
+	}
+
+	return histograms
+}
+
+// countMessage records the message received from the validator
+func (c *core) countMessage(msg *message) {
+	c.statsMu.Lock()
+	defer c.statsMu.Unlock()
+
+	counts := c.received[msg.Address]
+	if counts == nil {
+		counts = &MessageCounts{}
+		c.received[msg.Address] = counts
+	}
+
+	switch msg.Code {
+	case msgPreprepare:
+		counts.Preprepare++
+	case msgPrepare:
+		counts.Prepare++
+	case msgCommit:
+		counts.Commit++
+	case msgRoundChange:
+		counts.RoundChange++
+	}
+}
+
+// recordRoundChange appends the round change to the history
+func (c *core) recordRoundChange(sequence, round *big.Int) {
+	c.statsMu.Lock()
+	defer c.statsMu.Unlock()
+
+	c.roundChanges = append(c.roundChanges, &RoundChange{
+		Sequence:  new(big.Int).Set(sequence),
+		Round:     new(big.Int).Set(round),
+		Timestamp: time.Now().Unix(),
+	})
+
+	if len(c.roundChanges) > maxRoundChangeHistory {
+		c.roundChanges = c.roundChanges[len(c.roundChanges)-maxRoundChangeHistory:]
+	}
+}
+
+// observePhase records the time spent in the given state, which is left now
+func (c *core) observePhase(state State) {
+	now := time.Now()
+	if !c.stateTimestamp.IsZero() {
+		if histogram, ok := c.phaseHistograms[state]; ok {
+			histogram.Update(int64(now.Sub(c.stateTimestamp) / time.Millisecond))
+		}
+	}
+
+	c.stateTimestamp = now
+}
+
+// Status returns the current consensus state and the message statistics. The state is taken
+// in the event loop to avoid racing with it, only the statistics are returned if not running.
+func (c *core) Status() *Status {
+	if atomic.LoadInt32(&c.running) == 0 {
+		return c.status(false)
+	}
+
+	result := make(chan *Status, 1)
+	select {
+	case c.statusRequests <- result:
+		return <-result
+	case <-time.After(statusTimeout):
+		return c.status(false)
+	}
+}
+
+// status returns the message statistics, and the consensus state if withState is true,
+// which should only be accessed in the event loop.
+func (c *core) status(withState bool) *Status {
+	status := &Status{
+		Backlogs:     make(map[common.Address]int),
+		Received:     make(map[common.Address]*MessageCounts),
+		PhaseLatency: make(map[string]*Latency),
+	}
+
+	if withState {
+		status.State = c.state.String()
+		status.WaitingForRoundChange = c.waitingForRoundChange
+		status.IsProposer = c.isProposer()
+
+		if c.current != nil {
+			status.Sequence = c.current.Sequence()
+			status.Round = c.current.Round()
+			for _, msg := range c.current.Prepares.Values() {
+				status.Prepares = append(status.Prepares, msg.Address)
+			}
+			for _, msg := range c.current.Commits.Values() {
+				status.Commits = append(status.Commits, msg.Address)
+			}
+		}
+
+		if c.valSet != nil && c.valSet.GetProposer() != nil {
+			status.Proposer = c.valSet.GetProposer().Address()
+		}
+	}
+
+	c.backlogsMu.Lock()
+	for address, backlog := range c.backlogs {
+		status.Backlogs[address] = backlog.Size()
+	}
+	c.backlogsMu.Unlock()
+
+	c.statsMu.RLock()
+	for address, counts := range c.received {
+		cpy := *counts
+		status.Received[address] = &cpy
+	}
+	status.RoundChanges = append(status.RoundChanges, c.roundChanges...)
+	c.statsMu.RUnlock()
+
+	for state, histogram := range c.phaseHistograms {
+		snapshot := histogram.Snapshot()
+		status.PhaseLatency[state.String()] = &Latency{
+			Count: snapshot.Count(),
+			Mean:  snapshot.Mean(),
+			P50:   snapshot.Percentile(0.5),
+			P95:   snapshot.Percentile(0.95),
+			Max:   snapshot.Max(),
+		}
+	}
+
+	return status
+}
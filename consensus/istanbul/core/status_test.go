@@ -0,0 +1,71 @@
+/**
+*  @file
+*  @copyright defined in scdo/LICENSE
+ */
+
+package core
+
+import (
+	"math/big"
+	"reflect"
+	"testing"
+
+	"github.com/elcn233/go-scdo/consensus/istanbul"
+)
+
+func TestStatus(t *testing.T) {
+	sys := NewTestSystemWithBackend(4, 1)
+	backend := sys.backends[0]
+	c := backend.engine.(*core)
+	c.valSet = backend.peers
+	c.current = newTestRoundState(&istanbul.View{Round: big.NewInt(1), Sequence: big.NewInt(5)}, c.valSet)
+
+	src := c.valSet.GetByIndex(1)
+	for _, code := range []uint64{msgPreprepare, msgPrepare, msgPrepare, msgCommit, msgRoundChange} {
+		c.countMessage(&message{Code: code, Address: src.Address()})
+	}
+
+	c.recordRoundChange(big.NewInt(5), big.NewInt(1))
+	c.setState(StatePreprepared)
+	c.setState(StatePrepared)
+
+	// only the statistics if the event loop is not running
+	status := c.Status()
+	if status.State != "" || status.Sequence != nil || len(status.Received) != 1 {
+		t.Errorf("unexpected status when not running: %v", status)
+	}
+
+	// the state is taken in the event loop
+	c.startHandler()
+	defer c.Stop()
+
+	status = c.Status()
+	if status.Sequence.Cmp(big.NewInt(5)) != 0 || status.Round.Cmp(big.NewInt(1)) != 0 {
+		t.Errorf("view mismatch: have %v/%v, want 5/1", status.Sequence, status.Round)
+	}
+	if status.State != StatePrepared.String() {
+		t.Errorf("state mismatch: have %v, want %v", status.State, StatePrepared)
+	}
+
+	expectedCounts := &MessageCounts{Preprepare: 1, Prepare: 2, Commit: 1, RoundChange: 1}
+	if counts := status.Received[src.Address()]; !reflect.DeepEqual(counts, expectedCounts) {
+		t.Errorf("message counts mismatch: have %v, want %v", counts, expectedCounts)
+	}
+
+	if len(status.RoundChanges) != 1 || status.RoundChanges[0].Round.Cmp(big.NewInt(1)) != 0 {
+		t.Errorf("round changes mismatch: have %v", status.RoundChanges)
+	}
+
+	// the time spent in StatePreprepared is recorded
+	if latency := status.PhaseLatency[StatePreprepared.String()]; latency == nil || latency.Count == 0 {
+		t.Errorf("phase latency is not recorded: %v", latency)
+	}
+
+	// the history is limited
+	for i := 0; i < maxRoundChangeHistory+1; i++ {
+		c.recordRoundChange(big.NewInt(6), big.NewInt(int64(i)))
+	}
+	if status = c.Status(); len(status.RoundChanges) != maxRoundChangeHistory {
+		t.Errorf("the size of round change history mismatch: have %v, want %v", len(status.RoundChanges), maxRoundChangeHistory)
+	}
+}
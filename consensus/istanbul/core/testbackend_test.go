@@ -15,6 +15,7 @@ import (
 	"github.com/elcn233/go-scdo/common"
 	"github.com/elcn233/go-scdo/consensus/istanbul"
 	"github.com/elcn233/go-scdo/consensus/istanbul/validator"
+	"github.com/elcn233/go-scdo/core/types"
 	"github.com/elcn233/go-scdo/crypto"
 	"github.com/elcn233/go-scdo/database"
 	"github.com/elcn233/go-scdo/log"
@@ -33,6 +34,7 @@ type testSystemBackend struct {
 
 	committedMsgs []testCommittedMsgs
 	sentMsgs      [][]byte // store the message when Send is called by core
+	evidences     []*types.IstanbulEvidence
 
 	address common.Address
 	db      database.Database
@@ -61,7 +63,7 @@ func (self *testSystemBackend) EventMux() *event.TypeMux {
 }
 
 func (self *testSystemBackend) Send(message []byte, target common.Address) error {
-	testLogger.Info("enqueuing a message...", "address", self.Address())
+	testLogger.Info("enqueuing a message. address %s", self.Address().Hex())
 	self.sentMsgs = append(self.sentMsgs, message)
 	self.sys.queuedMessage <- istanbul.MessageEvent{
 		Payload: message,
@@ -70,7 +72,7 @@ func (self *testSystemBackend) Send(message []byte, target common.Address) error
 }
 
 func (self *testSystemBackend) Broadcast(valSet istanbul.ValidatorSet, message []byte) error {
-	testLogger.Info("enqueuing a message...", "address", self.Address())
+	testLogger.Info("enqueuing a message. address %s", self.Address().Hex())
 	self.sentMsgs = append(self.sentMsgs, message)
 	self.sys.queuedMessage <- istanbul.MessageEvent{
 		Payload: message,
@@ -84,7 +86,7 @@ func (self *testSystemBackend) Gossip(valSet istanbul.ValidatorSet, message []by
 }
 
 func (self *testSystemBackend) Commit(proposal istanbul.Proposal, seals [][]byte) error {
-	testLogger.Info("commit message", "address", self.Address())
+	testLogger.Info("commit message. address %s", self.Address().Hex())
 	self.committedMsgs = append(self.committedMsgs, testCommittedMsgs{
 		commitProposal: proposal,
 		committedSeals: seals,
@@ -126,6 +128,10 @@ func (self *testSystemBackend) HasBadProposal(hash common.Hash) bool {
 	return false
 }
 
+func (self *testSystemBackend) ReportEvidence(evidence *types.IstanbulEvidence) {
+	self.evidences = append(self.evidences, evidence)
+}
+
 func (self *testSystemBackend) LastProposal() (istanbul.Proposal, common.Address) {
 	l := len(self.committedMsgs)
 	if l > 0 {
@@ -172,7 +178,7 @@ func generateValidators(n int) []common.Address {
 	vals := make([]common.Address, 0)
 	for i := 0; i < n; i++ {
 		privateKey, _ := crypto.GenerateKey()
-		vals = append(vals, crypto.PubkeyToAddress(privateKey.PublicKey))
+		vals = append(vals, *crypto.PubkeyToAddress(privateKey.PublicKey))
 	}
 	return vals
 }
@@ -271,5 +277,5 @@ func (t *testSystem) NewBackend(id uint64) *testSystemBackend {
 // helper functions.
 
 func getPublicKeyAddress(privateKey *ecdsa.PrivateKey) common.Address {
-	return crypto.PubkeyToAddress(privateKey.PublicKey)
+	return *crypto.PubkeyToAddress(privateKey.PublicKey)
 }
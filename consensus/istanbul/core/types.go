@@ -16,6 +16,8 @@ import (
 type Engine interface {
 	Start() error
 	Stop() error
+	// Status returns the consensus state and message statistics
+	Status() *Status
 }
 
 type State uint64
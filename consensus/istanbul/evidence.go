@@ -0,0 +1,109 @@
+/**
+*  @file
+*  @copyright defined in scdo/LICENSE
+ */
+
+package istanbul
+
+import (
+	"bytes"
+	"errors"
+
+	"github.com/elcn233/go-scdo/common"
+	"github.com/elcn233/go-scdo/core/types"
+	"github.com/ethereum/go-ethereum/rlp"
+)
+
+const (
+	// MsgCommitCode is the code of the COMMIT message in istanbul core.
+	MsgCommitCode uint64 = 2
+
+	// MaxEvidenceAge is the max number of blocks between the sequence of the evidence
+	// and the block to include it, so that the handled evidences need not be kept forever.
+	MaxEvidenceAge uint64 = 8640
+)
+
+var (
+	// ErrInvalidEvidence is returned when the evidence can not be decoded or is not a COMMIT message.
+	ErrInvalidEvidence = errors.New("invalid evidence")
+	// ErrEvidenceSigner is returned when the evidence messages are not signed by the accused validator.
+	ErrEvidenceSigner = errors.New("evidence is not signed by the validator")
+	// ErrEvidenceNotConflict is returned when the evidence messages are not in the same view
+	// or have the same digest.
+	ErrEvidenceNotConflict = errors.New("evidence messages do not conflict")
+	// ErrEvidenceExpired is returned when the sequence of the evidence is not before the block
+	// height or older than MaxEvidenceAge.
+	ErrEvidenceExpired = errors.New("evidence is expired")
+)
+
+// evidenceMessage is the signed message of istanbul core carried in the evidence.
+type evidenceMessage struct {
+	Code          uint64
+	Msg           []byte
+	Address       common.Address
+	Signature     []byte
+	CommittedSeal []byte
+}
+
+// VerifyEvidence verifies that the evidence contains two COMMIT messages signed by the
+// validator in the same view with different digests, and the view is within MaxEvidenceAge
+// blocks before the given height. It returns the sequence of the conflicting messages, which
+// is also returned with ErrEvidenceExpired.
+func VerifyEvidence(evidence *types.IstanbulEvidence, height uint64) (uint64, error) {
+	if evidence == nil || bytes.Equal(evidence.First, evidence.Second) {
+		return 0, ErrInvalidEvidence
+	}
+
+	first, err := decodeEvidenceCommit(evidence.Validator, evidence.First)
+	if err != nil {
+		return 0, err
+	}
+
+	second, err := decodeEvidenceCommit(evidence.Validator, evidence.Second)
+	if err != nil {
+		return 0, err
+	}
+
+	if first.View.Cmp(second.View) != 0 || first.Digest == second.Digest {
+		return 0, ErrEvidenceNotConflict
+	}
+
+	if !first.View.Sequence.IsUint64() {
+		return 0, ErrEvidenceExpired
+	}
+
+	sequence := first.View.Sequence.Uint64()
+	if sequence == 0 || sequence >= height || height-sequence > MaxEvidenceAge {
+		return sequence, ErrEvidenceExpired
+	}
+
+	return sequence, nil
+}
+
+// decodeEvidenceCommit decodes the COMMIT subject from the signed payload,
+// and checks it is signed by the validator.
+func decodeEvidenceCommit(validator common.Address, payload []byte) (*Subject, error) {
+	var msg evidenceMessage
+	if err := rlp.DecodeBytes(payload, &msg); err != nil || msg.Code != MsgCommitCode {
+		return nil, ErrInvalidEvidence
+	}
+
+	sig := msg.Signature
+	msg.Signature = []byte{}
+	data, err := rlp.EncodeToBytes(&msg)
+	if err != nil {
+		return nil, ErrInvalidEvidence
+	}
+
+	signer, err := GetSignatureAddress(data, sig)
+	if err != nil || signer != validator || msg.Address != validator {
+		return nil, ErrEvidenceSigner
+	}
+
+	var commit *Subject
+	if err := rlp.DecodeBytes(msg.Msg, &commit); err != nil || commit.View == nil || commit.View.Round == nil || commit.View.Sequence == nil {
+		return nil, ErrInvalidEvidence
+	}
+
+	return commit, nil
+}
@@ -0,0 +1,109 @@
+/**
+*  @file
+*  @copyright defined in scdo/LICENSE
+ */
+
+package istanbul
+
+import (
+	"crypto/ecdsa"
+	"math/big"
+	"testing"
+
+	"github.com/elcn233/go-scdo/common"
+	"github.com/elcn233/go-scdo/core/types"
+	"github.com/elcn233/go-scdo/crypto"
+	"github.com/ethereum/go-ethereum/rlp"
+)
+
+func newSignedCommit(t *testing.T, key *ecdsa.PrivateKey, view *View, digest common.Hash) []byte {
+	subject, err := rlp.EncodeToBytes(&Subject{View: view, Digest: digest})
+	if err != nil {
+		t.Fatalf("failed to encode subject: %v", err)
+	}
+
+	msg := &evidenceMessage{
+		Code:      MsgCommitCode,
+		Msg:       subject,
+		Address:   *crypto.PubkeyToAddress(key.PublicKey),
+		Signature: []byte{},
+	}
+
+	data, err := rlp.EncodeToBytes(msg)
+	if err != nil {
+		t.Fatalf("failed to encode message: %v", err)
+	}
+
+	sig, err := crypto.Sign(key, crypto.Keccak256(data))
+	if err != nil {
+		t.Fatalf("failed to sign message: %v", err)
+	}
+	msg.Signature = sig.Sig
+
+	payload, err := rlp.EncodeToBytes(msg)
+	if err != nil {
+		t.Fatalf("failed to encode message: %v", err)
+	}
+	return payload
+}
+
+func TestVerifyEvidence(t *testing.T) {
+	key, _ := crypto.GenerateKey()
+	otherKey, _ := crypto.GenerateKey()
+	addr := *crypto.PubkeyToAddress(key.PublicKey)
+
+	view := &View{Round: big.NewInt(0), Sequence: big.NewInt(10)}
+	nextView := &View{Round: big.NewInt(1), Sequence: big.NewInt(10)}
+	first := newSignedCommit(t, key, view, common.StringToHash("first"))
+
+	testCases := []struct {
+		second      []byte
+		expectedErr error
+	}{
+		{newSignedCommit(t, key, view, common.StringToHash("second")), nil},
+		{first, ErrInvalidEvidence},
+		{newSignedCommit(t, key, nextView, common.StringToHash("second")), ErrEvidenceNotConflict},
+		{newSignedCommit(t, otherKey, view, common.StringToHash("second")), ErrEvidenceSigner},
+		{[]byte{1, 2, 3}, ErrInvalidEvidence},
+	}
+
+	for i, test := range testCases {
+		_, err := VerifyEvidence(&types.IstanbulEvidence{Validator: addr, First: first, Second: test.second}, 11)
+		if err != test.expectedErr {
+			t.Errorf("test %d: error mismatch: have %v, want %v", i, err, test.expectedErr)
+		}
+	}
+}
+
+func TestVerifyEvidenceHeight(t *testing.T) {
+	key, _ := crypto.GenerateKey()
+	addr := *crypto.PubkeyToAddress(key.PublicKey)
+
+	view := &View{Round: big.NewInt(0), Sequence: big.NewInt(10)}
+	evidence := &types.IstanbulEvidence{
+		Validator: addr,
+		First:     newSignedCommit(t, key, view, common.StringToHash("first")),
+		Second:    newSignedCommit(t, key, view, common.StringToHash("second")),
+	}
+
+	testCases := []struct {
+		height      uint64
+		expectedErr error
+	}{
+		{9, ErrEvidenceExpired},
+		{10, ErrEvidenceExpired},
+		{11, nil},
+		{10 + MaxEvidenceAge, nil},
+		{11 + MaxEvidenceAge, ErrEvidenceExpired},
+	}
+
+	for i, test := range testCases {
+		sequence, err := VerifyEvidence(evidence, test.height)
+		if err != test.expectedErr {
+			t.Errorf("test %d: error mismatch: have %v, want %v", i, err, test.expectedErr)
+		}
+		if err == nil && sequence != 10 {
+			t.Errorf("test %d: sequence mismatch: have %d, want 10", i, sequence)
+		}
+	}
+}
@@ -6,6 +6,7 @@
 package istanbul
 
 import (
+	"math/big"
 	"strings"
 
 	"github.com/elcn233/go-scdo/common"
@@ -62,6 +63,10 @@ type ValidatorSet interface {
 	F() int
 	// Get proposer policy
 	Policy() ProposerPolicy
+	// Get the weights of validators used by the weighted random policy, nil if not weighted
+	Weights() map[common.Address]*big.Int
+	// Set the seed used by the weighted random policy, which is the hash of the last block
+	SetSeed(seed common.Hash)
 }
 
 // ----------------------------------------------------------------------------
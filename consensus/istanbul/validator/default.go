@@ -6,13 +6,16 @@
 package validator
 
 import (
+	"encoding/binary"
 	"math"
+	"math/big"
 	"reflect"
 	"sort"
 	"sync"
 
 	"github.com/elcn233/go-scdo/common"
 	"github.com/elcn233/go-scdo/consensus/istanbul"
+	"github.com/elcn233/go-scdo/crypto"
 )
 
 type defaultValidator struct {
@@ -36,6 +39,10 @@ type defaultSet struct {
 	proposer    istanbul.Validator
 	validatorMu sync.RWMutex
 	selector    istanbul.ProposalSelector
+
+	// for weighted random policy
+	weights map[common.Address]*big.Int
+	seed    common.Hash
 }
 
 func newDefaultSet(addrs []common.Address, policy istanbul.ProposerPolicy) *defaultSet {
@@ -56,6 +63,8 @@ func newDefaultSet(addrs []common.Address, policy istanbul.ProposerPolicy) *defa
 	valSet.selector = roundRobinProposer
 	if policy == istanbul.Sticky {
 		valSet.selector = stickyProposer
+	} else if policy == istanbul.WeightedRandom {
+		valSet.selector = weightedRandomProposer
 	}
 
 	return valSet
@@ -146,6 +155,46 @@ func stickyProposer(valSet istanbul.ValidatorSet, proposer common.Address, round
 	return valSet.GetByIndex(pick)
 }
 
+// weightedRandomProposer picks the proposer pseudo-randomly with the probability proportional
+// to the validator weight. The pick only depends on the seed and round, so that all the nodes
+// pick the same proposer. Validators without positive weight, e.g. the ones without bonded
+// stake, are counted with weight 1 wen, so they are hardly picked beside the staked ones.
+func weightedRandomProposer(valSet istanbul.ValidatorSet, proposer common.Address, round uint64) istanbul.Validator {
+	set, ok := valSet.(*defaultSet)
+	if !ok || len(set.validators) == 0 {
+		return nil
+	}
+
+	weights := make([]*big.Int, len(set.validators))
+	total := new(big.Int)
+	for i, val := range set.validators {
+		weights[i] = validatorWeight(set.weights, val.Address())
+		total.Add(total, weights[i])
+	}
+
+	var buf [8]byte
+	binary.BigEndian.PutUint64(buf[:], round)
+	random := new(big.Int).SetBytes(crypto.Keccak256(set.seed.Bytes(), buf[:]))
+	random.Mod(random, total)
+
+	for i, weight := range weights {
+		if random.Cmp(weight) < 0 {
+			return set.validators[i]
+		}
+		random.Sub(random, weight)
+	}
+
+	return set.validators[len(set.validators)-1]
+}
+
+func validatorWeight(weights map[common.Address]*big.Int, address common.Address) *big.Int {
+	if weight, ok := weights[address]; ok && weight != nil && weight.Sign() > 0 {
+		return weight
+	}
+
+	return big.NewInt(1)
+}
+
 func (valSet *defaultSet) AddValidator(address common.Address) bool {
 	valSet.validatorMu.Lock()
 	defer valSet.validatorMu.Unlock()
@@ -182,9 +231,51 @@ func (valSet *defaultSet) Copy() istanbul.ValidatorSet {
 	for _, v := range valSet.validators {
 		addresses = append(addresses, v.Address())
 	}
-	return NewSet(addresses, valSet.policy)
+
+	cpy := newDefaultSet(addresses, valSet.policy)
+	cpy.setWeights(valSet.weights)
+	cpy.seed = valSet.seed
+	return cpy
 }
 
 func (valSet *defaultSet) F() int { return int(math.Ceil(float64(valSet.Size())/3)) - 1 }
 
 func (valSet *defaultSet) Policy() istanbul.ProposerPolicy { return valSet.policy }
+
+func (valSet *defaultSet) Weights() map[common.Address]*big.Int {
+	valSet.validatorMu.RLock()
+	defer valSet.validatorMu.RUnlock()
+
+	if valSet.weights == nil {
+		return nil
+	}
+
+	weights := make(map[common.Address]*big.Int, len(valSet.weights))
+	for address, weight := range valSet.weights {
+		weights[address] = new(big.Int).Set(weight)
+	}
+	return weights
+}
+
+func (valSet *defaultSet) SetSeed(seed common.Hash) {
+	valSet.validatorMu.Lock()
+	defer valSet.validatorMu.Unlock()
+	valSet.seed = seed
+}
+
+func (valSet *defaultSet) setWeights(weights map[common.Address]*big.Int) {
+	valSet.validatorMu.Lock()
+	defer valSet.validatorMu.Unlock()
+
+	if weights == nil {
+		valSet.weights = nil
+		return
+	}
+
+	valSet.weights = make(map[common.Address]*big.Int, len(weights))
+	for address, weight := range weights {
+		if weight != nil {
+			valSet.weights[address] = new(big.Int).Set(weight)
+		}
+	}
+}
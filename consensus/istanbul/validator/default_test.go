@@ -20,8 +20,8 @@ import (
 )
 
 var (
-	testAddress  = "0x70524d664ffe731100208a0154e556f9bb679ae6"
-	testAddress2 = "0xb37866a925bccd69cfa98d43b510f1d23d78a851"
+	testAddress  = "0xb37866a925bccd69cfa98d43b510f1d23d78a851"
+	testAddress2 = "0x70524d664ffe731100208a0154e556f9bb679ae6"
 )
 
 func TestValidatorSet(t *testing.T) {
@@ -29,6 +29,7 @@ func TestValidatorSet(t *testing.T) {
 	testNormalValSet(t)
 	testEmptyValSet(t)
 	testStickyProposer(t)
+	testWeightedRandomProposer(t)
 	testAddAndRemoveValidator(t)
 }
 
@@ -41,9 +42,9 @@ func testNewValidatorSet(t *testing.T) {
 	for i := 0; i < ValCnt; i++ {
 		key, _ := crypto.GenerateKey()
 		addr := crypto.PubkeyToAddress(key.PublicKey)
-		val := New(addr)
+		val := New(*addr)
 		validators = append(validators, val)
-		b = append(b, val.Address().Bytes()...)
+		b = append(b, addr.Bytes()...)
 	}
 
 	// Create ValidatorSet
@@ -201,3 +202,74 @@ func testStickyProposer(t *testing.T) {
 		t.Errorf("proposer mismatch: have %v, want %v", val, val2)
 	}
 }
+
+func testWeightedRandomProposer(t *testing.T) {
+	addrs := []common.Address{
+		common.BigToAddress(big.NewInt(1)),
+		common.BigToAddress(big.NewInt(2)),
+		common.BigToAddress(big.NewInt(3)),
+	}
+	weights := map[common.Address]*big.Int{
+		addrs[0]: big.NewInt(100),
+		addrs[1]: big.NewInt(300),
+		addrs[2]: big.NewInt(0), // counted as 1
+	}
+	seed := common.StringToHash("last block")
+
+	valSet := NewWeightedSet(addrs, weights, istanbul.WeightedRandom)
+	valSet.SetSeed(seed)
+
+	// the proposer only depends on the seed and round
+	cpy := valSet.Copy()
+	for round := uint64(0); round < 10; round++ {
+		valSet.CalcProposer(addrs[0], round)
+		cpy.CalcProposer(addrs[1], round)
+		if !reflect.DeepEqual(valSet.GetProposer(), cpy.GetProposer()) {
+			t.Errorf("round %d: proposer mismatch: have %v, want %v", round, cpy.GetProposer(), valSet.GetProposer())
+		}
+	}
+
+	// the validators propose proportionally to their weights
+	const rounds = 4000
+	counts := make(map[common.Address]int)
+	for round := uint64(0); round < rounds; round++ {
+		valSet.CalcProposer(common.Address{}, round)
+		counts[valSet.GetProposer().Address()]++
+	}
+
+	if counts[addrs[0]] < rounds/4-200 || counts[addrs[0]] > rounds/4+200 {
+		t.Errorf("unexpected proposal count of weight 100: %v", counts[addrs[0]])
+	}
+	if counts[addrs[1]] < rounds*3/4-200 || counts[addrs[1]] > rounds*3/4+200 {
+		t.Errorf("unexpected proposal count of weight 300: %v", counts[addrs[1]])
+	}
+	if counts[addrs[2]] > 20 {
+		t.Errorf("unexpected proposal count of weight 0: %v", counts[addrs[2]])
+	}
+
+	// the proposer changes with the seed
+	changed := false
+	for i := 0; i < 10 && !changed; i++ {
+		cpy.SetSeed(common.BigToHash(big.NewInt(int64(i))))
+		cpy.CalcProposer(common.Address{}, 0)
+		valSet.CalcProposer(common.Address{}, 0)
+		changed = !reflect.DeepEqual(valSet.GetProposer(), cpy.GetProposer())
+	}
+	if !changed {
+		t.Errorf("proposer is not changed by seed")
+	}
+
+	// without weights, all validators have the same chance
+	valSet = NewSet(addrs, istanbul.WeightedRandom)
+	valSet.SetSeed(seed)
+	counts = make(map[common.Address]int)
+	for round := uint64(0); round < rounds; round++ {
+		valSet.CalcProposer(common.Address{}, round)
+		counts[valSet.GetProposer().Address()]++
+	}
+	for _, addr := range addrs {
+		if counts[addr] < rounds/3-200 || counts[addr] > rounds/3+200 {
+			t.Errorf("unexpected proposal count without weights: %v", counts[addr])
+		}
+	}
+}
@@ -6,6 +6,8 @@
 package validator
 
 import (
+	"math/big"
+
 	"github.com/elcn233/go-scdo/common"
 	"github.com/elcn233/go-scdo/consensus/istanbul"
 )
@@ -20,6 +22,13 @@ func NewSet(addrs []common.Address, policy istanbul.ProposerPolicy) istanbul.Val
 	return newDefaultSet(addrs, policy)
 }
 
+// NewWeightedSet creates a validator set with the weights used by the weighted random policy.
+func NewWeightedSet(addrs []common.Address, weights map[common.Address]*big.Int, policy istanbul.ProposerPolicy) istanbul.ValidatorSet {
+	valSet := newDefaultSet(addrs, policy)
+	valSet.setWeights(weights)
+	return valSet
+}
+
 func ExtractValidators(extraData []byte) []common.Address {
 	// get the validator addresses
 	addrs := make([]common.Address, (len(extraData) / common.AddressLen))
@@ -20,6 +20,9 @@ var (
 
 	// blockNumberPerEra block number per reward era. It is approximation of block number generated per year.
 	blockNumberPerEra uint64 = 3150000
+
+	// masternodeRewardPercent the percent of the block reward shared by the active masternodes.
+	masternodeRewardPercent int64 = 20
 )
 
 // init initialize the rewardTable
@@ -61,3 +64,16 @@ func GetReward(blockHeight uint64) *big.Int {
 
 	return big.NewInt(0).Set(result)
 }
+
+// SplitReward splits the block reward into the coinbase part and the masternode part,
+// the whole reward goes to the coinbase before the masternode fork height.
+func SplitReward(reward *big.Int, height uint64) (coinbase *big.Int, masternode *big.Int) {
+	if height < common.MasternodeForkHeight {
+		return new(big.Int).Set(reward), big.NewInt(0)
+	}
+
+	masternode = new(big.Int).Mul(reward, big.NewInt(masternodeRewardPercent))
+	masternode.Div(masternode, big.NewInt(100))
+
+	return new(big.Int).Sub(reward, masternode), masternode
+}
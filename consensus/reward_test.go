@@ -40,3 +40,20 @@ func Test_RewardTotal(t *testing.T) {
 	assert.True(t, sum.Cmp(new(big.Int).Add(targetReward, duration)) < 0)
 	assert.True(t, sum.Cmp(new(big.Int).Sub(targetReward, duration)) > 0)
 }
+
+func Test_SplitReward(t *testing.T) {
+	reward := GetReward(common.MasternodeForkHeight)
+	coinbase, masternode := SplitReward(reward, common.MasternodeForkHeight)
+
+	assert.Equal(t, masternode, new(big.Int).Div(new(big.Int).Mul(reward, big.NewInt(masternodeRewardPercent)), big.NewInt(100)))
+	assert.Equal(t, new(big.Int).Add(coinbase, masternode), reward)
+
+	coinbase, masternode = SplitReward(big.NewInt(0), common.MasternodeForkHeight)
+	assert.Equal(t, coinbase.Sign(), 0)
+	assert.Equal(t, masternode.Sign(), 0)
+
+	// not shared before the fork height
+	coinbase, masternode = SplitReward(reward, common.MasternodeForkHeight-1)
+	assert.Equal(t, coinbase, reward)
+	assert.Equal(t, masternode.Sign(), 0)
+}
@@ -0,0 +1,210 @@
+/**
+* @file
+* @copyright defined in scdo/LICENSE
+ */
+
+package system
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math/big"
+
+	"github.com/elcn233/go-scdo/common"
+)
+
+const (
+	btcHeaderLen        = 80
+	btcRetargetInterval = 2016                  // blocks between the difficulty adjustments
+	btcTargetTimespan   = int64(14 * 24 * 3600) // expected time of a retarget interval
+)
+
+var (
+	// btcPowLimit is the highest target of the bitcoin mainnet
+	btcPowLimit = compactToBig(0x1d00ffff)
+
+	errBTCHeaderLength  = fmt.Errorf("btc block header must be %d bytes", btcHeaderLen)
+	errBTCTargetInvalid = errors.New("btc block target is out of range")
+	errBTCPowInvalid    = errors.New("btc block hash is above the target")
+	errBTCTxLength      = errors.New("btc transaction of 64 bytes is ambiguous with an inner merkle node")
+)
+
+// BTCHeader is the bitcoin block header. The hashes are in the reversed byte
+// order as shown by the bitcoin clients and explorers.
+type BTCHeader struct {
+	Version    uint32
+	PrevBlock  common.Hash
+	MerkleRoot common.Hash
+	Timestamp  uint32
+	Bits       uint32
+	Nonce      uint32
+}
+
+// ParseBTCHeader parses the 80 bytes serialized bitcoin block header
+func ParseBTCHeader(raw []byte) (*BTCHeader, error) {
+	if len(raw) != btcHeaderLen {
+		return nil, errBTCHeaderLength
+	}
+
+	return &BTCHeader{
+		Version:    binary.LittleEndian.Uint32(raw[0:4]),
+		PrevBlock:  reversedHash(raw[4:36]),
+		MerkleRoot: reversedHash(raw[36:68]),
+		Timestamp:  binary.LittleEndian.Uint32(raw[68:72]),
+		Bits:       binary.LittleEndian.Uint32(raw[72:76]),
+		Nonce:      binary.LittleEndian.Uint32(raw[76:80]),
+	}, nil
+}
+
+// Bytes returns the 80 bytes serialized header
+func (h *BTCHeader) Bytes() []byte {
+	raw := make([]byte, 0, btcHeaderLen)
+	raw = binary.LittleEndian.AppendUint32(raw, h.Version)
+	raw = append(raw, reversedHash(h.PrevBlock.Bytes()).Bytes()...)
+	raw = append(raw, reversedHash(h.MerkleRoot.Bytes()).Bytes()...)
+	raw = binary.LittleEndian.AppendUint32(raw, h.Timestamp)
+	raw = binary.LittleEndian.AppendUint32(raw, h.Bits)
+	return binary.LittleEndian.AppendUint32(raw, h.Nonce)
+}
+
+// Hash returns the block hash
+func (h *BTCHeader) Hash() common.Hash {
+	return reversedHash(doubleSHA256(h.Bytes()))
+}
+
+// CheckPoW checks the target of the header is valid and the hash meets the target
+func (h *BTCHeader) CheckPoW() error {
+	target := compactToBig(h.Bits)
+	if target.Sign() <= 0 || target.Cmp(btcPowLimit) > 0 {
+		return errBTCTargetInvalid
+	}
+
+	if h.Hash().Big().Cmp(target) > 0 {
+		return errBTCPowInvalid
+	}
+
+	return nil
+}
+
+// Work returns the expected number of hashes to find the block, 2^256 / (target+1)
+func (h *BTCHeader) Work() *big.Int {
+	target := compactToBig(h.Bits)
+	if target.Sign() <= 0 {
+		return big.NewInt(0)
+	}
+
+	work := new(big.Int).Lsh(big.NewInt(1), 256)
+	return work.Div(work, target.Add(target, big.NewInt(1)))
+}
+
+// nextWorkRequired returns the bits of the first block of a retarget interval, the
+// timespan is the time between the first and the last block of the previous interval.
+func nextWorkRequired(lastBits uint32, timespan int64) uint32 {
+	if timespan < btcTargetTimespan/4 {
+		timespan = btcTargetTimespan / 4
+	}
+
+	if timespan > btcTargetTimespan*4 {
+		timespan = btcTargetTimespan * 4
+	}
+
+	target := compactToBig(lastBits)
+	target.Mul(target, big.NewInt(timespan))
+	target.Div(target, big.NewInt(btcTargetTimespan))
+	if target.Cmp(btcPowLimit) > 0 {
+		target.Set(btcPowLimit)
+	}
+
+	return bigToCompact(target)
+}
+
+// compactToBig converts the compact bits of the header to the target
+func compactToBig(bits uint32) *big.Int {
+	mantissa := int64(bits & 0x007fffff)
+	exponent := uint(bits >> 24)
+
+	var target *big.Int
+	if exponent <= 3 {
+		target = big.NewInt(mantissa >> (8 * (3 - exponent)))
+	} else {
+		target = new(big.Int).Lsh(big.NewInt(mantissa), 8*(exponent-3))
+	}
+
+	if bits&0x00800000 != 0 {
+		target.Neg(target)
+	}
+
+	return target
+}
+
+// bigToCompact converts the target to the compact bits
+func bigToCompact(target *big.Int) uint32 {
+	if target.Sign() == 0 {
+		return 0
+	}
+
+	var mantissa uint32
+	exponent := uint(len(target.Bytes()))
+	if exponent <= 3 {
+		mantissa = uint32(target.Uint64()) << (8 * (3 - exponent))
+	} else {
+		mantissa = uint32(new(big.Int).Rsh(target, 8*(exponent-3)).Uint64())
+	}
+
+	// the sign bit is set, move the mantissa a byte right
+	if mantissa&0x00800000 != 0 {
+		mantissa >>= 8
+		exponent++
+	}
+
+	bits := uint32(exponent<<24) | mantissa
+	if target.Sign() < 0 {
+		bits |= 0x00800000
+	}
+
+	return bits
+}
+
+// btcTxID returns the id of the serialized bitcoin transaction
+func btcTxID(rawTx []byte) (common.Hash, error) {
+	if len(rawTx) == 64 {
+		return common.EmptyHash, errBTCTxLength
+	}
+
+	return reversedHash(doubleSHA256(rawTx)), nil
+}
+
+// merkleRootFromProof computes the merkle root with the sibling hashes from the
+// transaction up to the root, the index is the position of the transaction in the block.
+func merkleRootFromProof(txID common.Hash, index uint32, proof []common.Hash) common.Hash {
+	node := reversedHash(txID.Bytes()).Bytes()
+	for _, sibling := range proof {
+		s := reversedHash(sibling.Bytes()).Bytes()
+		if index&1 == 0 {
+			node = doubleSHA256(append(append([]byte{}, node...), s...))
+		} else {
+			node = doubleSHA256(append(append([]byte{}, s...), node...))
+		}
+		index >>= 1
+	}
+
+	return reversedHash(node)
+}
+
+func doubleSHA256(data []byte) []byte {
+	first := sha256.Sum256(data)
+	second := sha256.Sum256(first[:])
+	return second[:]
+}
+
+func reversedHash(b []byte) common.Hash {
+	reversed := bytes.Clone(b)
+	for i, j := 0, len(reversed)-1; i < j; i, j = i+1, j-1 {
+		reversed[i], reversed[j] = reversed[j], reversed[i]
+	}
+
+	return common.BytesToHash(reversed)
+}
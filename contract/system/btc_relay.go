@@ -6,12 +6,15 @@
 package system
 
 import (
+	"encoding/binary"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"math/big"
 
 	"github.com/elcn233/go-scdo/common"
-	"github.com/elcn233/go-scdo/common/hexutil"
 	"github.com/elcn233/go-scdo/core/state"
+	"github.com/elcn233/go-scdo/crypto"
 )
 
 const (
@@ -23,52 +26,101 @@ const (
 	CmdStoreBlockHeader
 	// CmdGetBlockHeader is the command byte to get btc block header
 	CmdGetBlockHeader
+	// CmdGetBestBlockHeader is the command byte to get the head of the heaviest btc chain
+	CmdGetBestBlockHeader
 )
 
-const btcConfirm = 6
+const (
+	btcConfirm        = 6
+	btcMaxMerkleDepth = 32
+	btcMaxReorgDepth  = 144 // about one day of btc blocks
+)
 
 var (
 	brCommands = map[byte]*cmdInfo{
-		CmdVerifyTx:         &cmdInfo{1000, verifyTx},
-		CmdRelayTx:          &cmdInfo{3000, relayTx},
-		CmdStoreBlockHeader: &cmdInfo{10000, storeBlockHeader},
-		CmdGetBlockHeader:   &cmdInfo{200, getBlockHeader},
+		CmdVerifyTx:           &cmdInfo{1000, verifyTx},
+		CmdRelayTx:            &cmdInfo{3000, relayTx},
+		CmdStoreBlockHeader:   &cmdInfo{10000, storeBlockHeader},
+		CmdGetBlockHeader:     &cmdInfo{200, getBlockHeader},
+		CmdGetBestBlockHeader: &cmdInfo{200, getBestBlockHeader},
+	}
+
+	// brSchema is the command schema of brCommands for clients to generate calls
+	brSchema = []CommandSchema{
+		{ID: CmdVerifyTx, Name: "verifyTx", Input: TypeJSON, Output: TypeBytes},
+		{ID: CmdRelayTx, Name: "relayTx", Input: TypeJSON, Output: TypeBytes},
+		{ID: CmdStoreBlockHeader, Name: "storeBlockHeader", Input: TypeJSON, Output: TypeBytes},
+		{ID: CmdGetBlockHeader, Name: "getBlockHeader", Input: TypeHash, Output: TypeBytes},
+		{ID: CmdGetBestBlockHeader, Name: "getBestBlockHeader", Input: TypeNone, Output: TypeJSON, ReadOnly: true},
 	}
 
 	// function result
 	failure = []byte{0}
 	success = []byte{1}
 
-	// temp storage
-	preBlockHeight uint64
-	keyBlocksHash  = common.BytesToHash([]byte("BTC-Blocks-Hash"))
+	keyBestBlock = common.BytesToHash([]byte("BTC-Best-Block"))
 	// @todo will change after the relay chain is successfully established.
 	fee uint64 = 1
+
+	errBTCBlockExists    = errors.New("Block header already exists")
+	errBTCPrevNotFound   = errors.New("Previous block header not found")
+	errBTCNotCheckpoint  = errors.New("Block is not the checkpoint of the relay")
+	errBTCNotInMainChain = errors.New("Block is not in the heaviest chain")
+	errBTCInvalidTxIndex = errors.New("Transaction index is out of the merkle proof")
+	errBTCNoBestBlock    = errors.New("No block header is stored")
+	errBTCBitsMismatch   = errors.New("Block difficulty bits mismatch")
+	errBTCReorgTooDeep   = errors.New("Block reorganizes too many blocks of the heaviest chain")
+
+	// btcCheckpoint is the block the relay starts from, which is set in the chain constants
+	// so that the first relayer could not choose the chain of the relay
+	btcCheckpoint = BTCCheckpoint{
+		Hash:       common.MustHexToHash(common.BTCRelayCheckpointHash),
+		Height:     common.BTCRelayCheckpointHeight,
+		Bits:       common.BTCRelayCheckpointBits,
+		EpochStart: common.BTCRelayCheckpointEpochStart,
+	}
 )
 
-// BTCBlock btc block structure
+// BTCCheckpoint is the btc block the relay starts from
+type BTCCheckpoint struct {
+	Hash       common.Hash
+	Height     uint64
+	Bits       uint32
+	EpochStart uint32 // timestamp of the first block of the retarget interval
+}
+
+// BTCBlock is a btc block header stored in the contract
 type BTCBlock struct {
-	BlockHeaderHex   string
-	Height           uint64
-	PreviousBlockHex string
-	TxHexs           []string
-	Relayer          common.Address
+	Hash       common.Hash
+	Header     common.Bytes // 80 bytes serialized header
+	Height     uint64
+	TotalWork  *big.Int // chain work from the checkpoint to the block
+	EpochStart uint32   // timestamp of the first block of the retarget interval
+	Relayer    common.Address
 }
 
 func (b *BTCBlock) String() string {
-	return fmt.Sprintf("Block[BlockHeaderHex=%v, Height=%v, PreviousBlockHex=%v, TxHexs=%v]", b.BlockHeaderHex, b.Height, b.PreviousBlockHex, b.TxHexs)
+	return fmt.Sprintf("Block[Hash=%v, Height=%v, TotalWork=%v, Relayer=%v]", b.Hash.Hex(), b.Height, b.TotalWork, b.Relayer.Hex())
+}
+
+// StoreHeaderRequest is a request to store a btc block header. The first header must
+// be the checkpoint of the relay, the other headers must extend a stored header.
+type StoreHeaderRequest struct {
+	Header common.Bytes
 }
 
-// RelayRequest is a request structure using btc-relay
+// RelayRequest is a request to verify the inclusion of a btc tx in a block with a merkle proof
 type RelayRequest struct {
-	BTCBlock
-	TxHex string
+	BlockHash common.Hash
+	TxHex     common.Bytes // serialized transaction
+	TxIndex   uint32       // position of the tx in the block
+	Proof     []common.Hash
 	// This is used to relay the verification of successful tx to the contract address
 	RelayAddress common.Address
 }
 
 func (r *RelayRequest) String() string {
-	return fmt.Sprintf("RelayRequest[BTCBlock=%v, TxHex=%v, RelayAddress=%v]", r.BTCBlock.String(), r.TxHex, r.RelayAddress.Hex())
+	return fmt.Sprintf("RelayRequest[BlockHash=%v, TxHex=%v, TxIndex=%v, RelayAddress=%v]", r.BlockHash.Hex(), r.TxHex, r.TxIndex, r.RelayAddress.Hex())
 }
 
 // verify that tx exists based on request, the amount will be transferred
@@ -87,24 +139,41 @@ func verifyTx(request []byte, ctx *Context) ([]byte, error) {
 	}
 
 	// Match block
-	blocks := getBTCBlocks(ctx.statedb)
-	btcBlock, ok := blocks[relayRequest.BlockHeaderHex]
-	if !ok {
+	btcBlock := getBTCBlock(ctx.statedb, relayRequest.BlockHash)
+	if btcBlock == nil {
 		return failure, nil
 	}
 
-	if preBlockHeight < btcConfirm || btcBlock.Height < preBlockHeight-btcConfirm {
-		return failure, fmt.Errorf("Confirmation need more than 6, latestHeight[%d], queryHeight[%d]", preBlockHeight, btcBlock.Height)
+	// the height index above the best block is stale after a reorg to a lower but heavier chain
+	best := getBTCBlock(ctx.statedb, getBestBlockHash(ctx.statedb))
+	if btcBlock.Height > best.Height || getMainChainHash(ctx.statedb, btcBlock.Height) != btcBlock.Hash {
+		return failure, errBTCNotInMainChain
+	}
+
+	if confirms := best.Height - btcBlock.Height + 1; confirms < btcConfirm {
+		return failure, fmt.Errorf("Confirmation need at least %d, latestHeight[%d], queryHeight[%d]", btcConfirm, best.Height, btcBlock.Height)
+	}
+
+	if len(relayRequest.Proof) > btcMaxMerkleDepth || relayRequest.TxIndex>>uint(len(relayRequest.Proof)) != 0 {
+		return failure, errBTCInvalidTxIndex
 	}
 
 	// Tranfer amount to Relayer
 	ctx.statedb.AddBalance(btcBlock.Relayer, amount)
 	ctx.statedb.SubBalance(BTCRelayContractAddress, amount)
 
-	for _, txHex := range btcBlock.TxHexs {
-		if relayRequest.TxHex == txHex {
-			return success, nil
-		}
+	txID, err := btcTxID(relayRequest.TxHex)
+	if err != nil {
+		return failure, err
+	}
+
+	header, err := ParseBTCHeader(btcBlock.Header)
+	if err != nil {
+		return failure, err
+	}
+
+	if merkleRootFromProof(txID, relayRequest.TxIndex, relayRequest.Proof) == header.MerkleRoot {
+		return success, nil
 	}
 
 	return failure, nil
@@ -125,45 +194,103 @@ func relayTx(request []byte, ctx *Context) ([]byte, error) {
 	return failure, nil
 }
 
-// storage of Bitcoin block headers
+// storage of Bitcoin block headers. The header must meet its target and extend a
+// stored header with the expected difficulty, the heaviest chain is tracked by
+// the total work, the first header must be the checkpoint.
 func storeBlockHeader(request []byte, ctx *Context) ([]byte, error) {
-	if !isRelayer(ctx.tx.Data.From) {
+	if !isRelayer(ctx.statedb, ctx.tx.Data.From) {
 		return failure, fmt.Errorf("Invaild block relayer[%s]", ctx.tx.Data.From.Hex())
 	}
 
-	var relayRequest RelayRequest
-	if err := json.Unmarshal(request, &relayRequest); err != nil {
+	var storeRequest StoreHeaderRequest
+	if err := json.Unmarshal(request, &storeRequest); err != nil {
 		return failure, fmt.Errorf("Invalid request parameter, %s", err)
 	}
 
-	blocks := getBTCBlocks(ctx.statedb)
-	if _, ok := blocks[relayRequest.BlockHeaderHex]; ok {
-		return failure, fmt.Errorf("Block header already exists")
+	header, err := ParseBTCHeader(storeRequest.Header)
+	if err != nil {
+		return failure, err
 	}
 
-	relayRequest.BTCBlock.Relayer = ctx.tx.Data.From
-	blocks[relayRequest.BlockHeaderHex] = relayRequest.BTCBlock
-	preBlockHeight = relayRequest.Height
+	if err = header.CheckPoW(); err != nil {
+		return failure, err
+	}
 
-	bytes, err := json.Marshal(blocks)
-	if err != nil {
-		return failure, fmt.Errorf("Failed to marshal blocks, %s", err)
+	hash := header.Hash()
+	if getBTCBlock(ctx.statedb, hash) != nil {
+		return failure, errBTCBlockExists
+	}
+
+	block := &BTCBlock{
+		Hash:    hash,
+		Header:  storeRequest.Header,
+		Relayer: ctx.tx.Data.From,
 	}
 
-	ctx.statedb.SetData(BTCRelayContractAddress, keyBlocksHash, bytes)
+	bestHash := getBestBlockHash(ctx.statedb)
+	if bestHash.IsEmpty() {
+		if !hash.Equal(btcCheckpoint.Hash) || header.Bits != btcCheckpoint.Bits {
+			return failure, errBTCNotCheckpoint
+		}
+
+		block.Height = btcCheckpoint.Height
+		block.TotalWork = header.Work()
+		block.EpochStart = btcCheckpoint.EpochStart
+	} else {
+		parent := getBTCBlock(ctx.statedb, header.PrevBlock)
+		if parent == nil {
+			return failure, errBTCPrevNotFound
+		}
+
+		if err = connectBTCBlock(block, header, parent); err != nil {
+			return failure, err
+		}
+	}
+
+	if bestHash.IsEmpty() || block.TotalWork.Cmp(getBTCBlock(ctx.statedb, bestHash).TotalWork) > 0 {
+		if err = setBestBlock(ctx.statedb, block); err != nil {
+			return failure, err
+		}
+	}
+
+	putBTCBlock(ctx.statedb, block)
 	return success, nil
 }
 
-// check if there is a stored bitcoin block header in the contract
+// connectBTCBlock checks the difficulty of the header against its parent and
+// fills the height, the total work and the epoch start of the block
+func connectBTCBlock(block *BTCBlock, header *BTCHeader, parent *BTCBlock) error {
+	parentHeader, err := ParseBTCHeader(parent.Header)
+	if err != nil {
+		return err
+	}
+
+	block.Height = parent.Height + 1
+	expectedBits := parentHeader.Bits
+	block.EpochStart = parent.EpochStart
+	if block.Height%btcRetargetInterval == 0 {
+		timespan := int64(parentHeader.Timestamp) - int64(parent.EpochStart)
+		expectedBits = nextWorkRequired(parentHeader.Bits, timespan)
+		block.EpochStart = header.Timestamp
+	}
+
+	if header.Bits != expectedBits {
+		return fmt.Errorf("%s, expected[%x], actual[%x]", errBTCBitsMismatch, expectedBits, header.Bits)
+	}
+
+	block.TotalWork = new(big.Int).Add(parent.TotalWork, header.Work())
+	return nil
+}
+
+// check if there is a stored bitcoin block header in the contract, the request is the block hash
 func getBlockHeader(request []byte, ctx *Context) ([]byte, error) {
 	amount := ctx.tx.Data.Amount
 	if amount.Uint64() < fee {
 		return failure, fmt.Errorf("getBlockHeader fee is not enough, expected[%d], actual[%d]", fee, amount)
 	}
 
-	blocks, blockHeaderHex := getBTCBlocks(ctx.statedb), hexutil.BytesToHex(request)
-	btcBlock, ok := blocks[blockHeaderHex]
-	if !ok {
+	btcBlock := getBTCBlock(ctx.statedb, common.BytesToHash(request))
+	if btcBlock == nil {
 		return failure, nil
 	}
 
@@ -174,17 +301,80 @@ func getBlockHeader(request []byte, ctx *Context) ([]byte, error) {
 	return success, nil
 }
 
-// @todo
-func isRelayer(addr common.Address) bool {
-	return true
+// get the head of the heaviest chain in json
+func getBestBlockHeader(request []byte, ctx *Context) ([]byte, error) {
+	best := getBTCBlock(ctx.statedb, getBestBlockHash(ctx.statedb))
+	if best == nil {
+		return nil, errBTCNoBestBlock
+	}
+
+	return json.Marshal(best)
+}
+
+// isRelayer returns true if the address is an active masternode which is not slashed
+func isRelayer(statedb *state.Statedb, addr common.Address) bool {
+	info, err := QueryAddress(addr, statedb)
+	return err == nil && info != nil && !info.IsQuit && !info.Slashed
+}
+
+func btcBlockKey(hash common.Hash) common.Hash {
+	return crypto.HashBytes([]byte("BTC-Block"), hash.Bytes())
 }
 
-func getBTCBlocks(statedb *state.Statedb) map[string]BTCBlock {
-	var blocks map[string]BTCBlock
-	data := statedb.GetData(BTCRelayContractAddress, keyBlocksHash)
-	if err := json.Unmarshal(data, &blocks); err != nil {
-		return make(map[string]BTCBlock)
+func btcHeightKey(height uint64) common.Hash {
+	var buf [8]byte
+	binary.BigEndian.PutUint64(buf[:], height)
+	return crypto.HashBytes([]byte("BTC-Height"), buf[:])
+}
+
+func getBTCBlock(statedb *state.Statedb, hash common.Hash) *BTCBlock {
+	data := statedb.GetData(BTCRelayContractAddress, btcBlockKey(hash))
+	if len(data) == 0 {
+		return nil
+	}
+
+	var block BTCBlock
+	if err := common.Deserialize(data, &block); err != nil {
+		return nil
+	}
+
+	return &block
+}
+
+func putBTCBlock(statedb *state.Statedb, block *BTCBlock) {
+	statedb.SetData(BTCRelayContractAddress, btcBlockKey(block.Hash), common.SerializePanic(block))
+}
+
+func getBestBlockHash(statedb *state.Statedb) common.Hash {
+	return common.BytesToHash(statedb.GetData(BTCRelayContractAddress, keyBestBlock))
+}
+
+func getMainChainHash(statedb *state.Statedb, height uint64) common.Hash {
+	return common.BytesToHash(statedb.GetData(BTCRelayContractAddress, btcHeightKey(height)))
+}
+
+// setBestBlock sets the head of the heaviest chain and updates the height index
+// of the main chain back to the fork point, which is at most btcMaxReorgDepth
+// blocks away so that the gas of the command is bounded.
+func setBestBlock(statedb *state.Statedb, block *BTCBlock) error {
+	var branch []*BTCBlock
+	for b := block; b != nil && getMainChainHash(statedb, b.Height) != b.Hash; {
+		if len(branch) == btcMaxReorgDepth {
+			return errBTCReorgTooDeep
+		}
+		branch = append(branch, b)
+
+		header, err := ParseBTCHeader(b.Header)
+		if err != nil {
+			return err
+		}
+		b = getBTCBlock(statedb, header.PrevBlock)
+	}
+
+	statedb.SetData(BTCRelayContractAddress, keyBestBlock, block.Hash.Bytes())
+	for _, b := range branch {
+		statedb.SetData(BTCRelayContractAddress, btcHeightKey(b.Height), b.Hash.Bytes())
 	}
 
-	return blocks
+	return nil
 }
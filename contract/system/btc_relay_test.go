@@ -19,21 +19,121 @@ import (
 	"github.com/stretchr/testify/assert"
 )
 
+// the first 10 block headers of the bitcoin mainnet
+var testBTCHeaders = []string{
+	"0x0100000000000000000000000000000000000000000000000000000000000000000000003ba3edfd7a7b12b27ac72c3e67768f617fc81bc3888a51323a9fb8aa4b1e5e4a29ab5f49ffff001d1dac2b7c",
+	"0x010000006fe28c0ab6f1b372c1a6a246ae63f74f931e8365e15a089c68d6190000000000982051fd1e4ba744bbbe680e1fee14677ba1a3c3540bf7b1cdb606e857233e0e61bc6649ffff001d01e36299",
+	"0x010000004860eb18bf1b1620e37e9490fc8a427514416fd75159ab86688e9a8300000000d5fdcc541e25de1c7a5addedf24858b8bb665c9f36ef744ee42c316022c90f9bb0bc6649ffff001d08d2bd61",
+	"0x01000000bddd99ccfda39da1b108ce1a5d70038d0a967bacb68b6b63065f626a0000000044f672226090d85db9a9f2fbfe5f0f9609b387af7be5b7fbb7a1767c831c9e995dbe6649ffff001d05e0ed6d",
+	"0x010000004944469562ae1c2c74d9a535e00b6f3e40ffbad4f2fda3895501b582000000007a06ea98cd40ba2e3288262b28638cec5337c1456aaf5eedc8e9e5a20f062bdf8cc16649ffff001d2bfee0a9",
+	"0x0100000085144a84488ea88d221c8bd6c059da090e88f8a2c99690ee55dbba4e00000000e11c48fecdd9e72510ca84f023370c9a38bf91ac5cae88019bee94d24528526344c36649ffff001d1d03e477",
+	"0x01000000fc33f596f822a0a1951ffdbf2a897b095636ad871707bf5d3162729b00000000379dfb96a5ea8c81700ea4ac6b97ae9a9312b2d4301a29580e924ee6761a2520adc46649ffff001d189c4c97",
+	"0x010000008d778fdc15a2d3fb76b7122a3b5582bea4f21f5a0c693537e7a03130000000003f674005103b42f984169c7d008370967e91920a6a5d64fd51282f75bc73a68af1c66649ffff001d39a59c86",
+	"0x010000004494c8cf4154bdcc0720cd4a59d9c9b285e4b146d45f061d2b6c967100000000e3855ed886605b6d4a99d5fa2ef2e9b0b164e63df3c4136bebf2d0dac0f1f7a667c86649ffff001d1c4b5666",
+	"0x01000000c60ddef1b7618ca2348a46e868afc26e3efc68226c78aa47f8488c4000000000c997a5e56e104102fa209c6a852dd90660a20b2d9c352423edce25857fcd37047fca6649ffff001d28404f53",
+}
+
+var testBTCHashes = []string{
+	"0x000000000019d6689c085ae165831e934ff763ae46a2a6c172b3f1b60a8ce26f",
+	"0x00000000839a8e6886ab5951d76f411475428afc90947ee320161bbf18eb6048",
+	"0x000000006a625f06636b8bb6ac7b960a8d03705d1ace08b1a19da3fdcc99ddbd",
+	"0x0000000082b5015589a3fdf2d4baff403e6f0be035a5d9742c1cae6295464449",
+	"0x000000004ebadb55ee9096c9a2f8880e09da59c0d68b1c228da88e48844a1485",
+	"0x000000009b7262315dbf071787ad3656097b892abffd1f95a1a022f896f533fc",
+	"0x000000003031a0e73735690c5a1ff2a4be82553b2a12b776fbd3a215dc8f778d",
+	"0x0000000071966c2b1d065fd446b1e485b2c9d9594acd2007ccbd5441cfc89444",
+	"0x00000000408c48f847aa786c2268fc3e6ec2af68e8468a34a28c61b7f1de0dc6",
+	"0x000000008d9dc510f23c2657fc4f67bea30078cc05a90eb89e84cc475c080805",
+}
+
+// the coinbase transaction of the genesis block
+const testBTCGenesisTx = "0x01000000010000000000000000000000000000000000000000000000000000000000000000ffffffff4d04ffff001d0104455468652054696d65732030332f4a616e2f32303039204368616e63656c6c6f72206f6e206272696e6b206f66207365636f6e64206261696c6f757420666f722062616e6b73ffffffff0100f2052a01000000434104678afdb0fe5548271967f1a67130b7105cd6a828e03909a67962e0ea1f61deb649f6bc3f4cef38c4f35504e51ec112de5c384df7ba0b8d578a4c702b6bf11d5fac00000000"
+
+func Test_BTCHeader(t *testing.T) {
+	for i, headerHex := range testBTCHeaders {
+		raw := hexutil.MustHexToBytes(headerHex)
+		header, err := ParseBTCHeader(raw)
+		assert.NoError(t, err)
+		assert.Equal(t, header.Bytes(), raw)
+		assert.Equal(t, header.Hash(), common.MustHexToHash(testBTCHashes[i]))
+		assert.NoError(t, header.CheckPoW())
+
+		if i > 0 {
+			assert.Equal(t, header.PrevBlock, common.MustHexToHash(testBTCHashes[i-1]))
+		}
+	}
+
+	header, err := ParseBTCHeader(hexutil.MustHexToBytes(testBTCHeaders[0]))
+	assert.NoError(t, err)
+	assert.Equal(t, header.MerkleRoot, common.MustHexToHash("0x4a5e1e4baab89f3a32518a88c31bc87f618f76673e2cc77ab2127b7afdeda33b"))
+	assert.Equal(t, header.Work(), big.NewInt(0x100010001))
+
+	// tampered nonce
+	header.Nonce++
+	assert.Equal(t, header.CheckPoW(), errBTCPowInvalid)
+
+	// target above the pow limit
+	header.Bits = 0x1d01ffff
+	assert.Equal(t, header.CheckPoW(), errBTCTargetInvalid)
+
+	_, err = ParseBTCHeader([]byte{1, 2, 3})
+	assert.Equal(t, err, errBTCHeaderLength)
+}
+
+func Test_CompactBits(t *testing.T) {
+	for _, bits := range []uint32{0x1d00ffff, 0x1b0404cb, 0x170da8a1, 0x01120000, 0x05009234} {
+		assert.Equal(t, bigToCompact(compactToBig(bits)), bits)
+	}
+
+	assert.Equal(t, compactToBig(0x1d00ffff).Text(16), "ffff0000000000000000000000000000000000000000000000000000")
+	assert.Equal(t, compactToBig(0x04923456).Sign(), -1)
+}
+
+func Test_nextWorkRequired(t *testing.T) {
+	// on time
+	assert.Equal(t, nextWorkRequired(0x1b0404cb, btcTargetTimespan), uint32(0x1b0404cb))
+	// twice as fast, the target halves
+	assert.Equal(t, nextWorkRequired(0x1d00ffff, btcTargetTimespan/2), uint32(0x1c7fff80))
+	// the adjustment is limited to 4 times
+	assert.Equal(t, nextWorkRequired(0x1d00ffff, btcTargetTimespan/10), uint32(0x1c3fffc0))
+	// the target never exceeds the pow limit
+	assert.Equal(t, nextWorkRequired(0x1d00ffff, btcTargetTimespan*10), uint32(0x1d00ffff))
+}
+
+func Test_merkleRootFromProof(t *testing.T) {
+	// block 170 with the first bitcoin transfer
+	coinbase := common.MustHexToHash("0xb1fea52486ce0c62bb442b530a3f0132b826c74e473d1f2c220bfa78111c5082")
+	transfer := common.MustHexToHash("0xf4184fc596403b9d638783cf57adfe4c75c605f6356fbc91338530e9831e9e16")
+	root := common.MustHexToHash("0x7dac2c5666815c17a3b36427de37bb9d2e2c5ccec3f8633eb91a4205cb4c10ff")
+
+	assert.Equal(t, merkleRootFromProof(coinbase, 0, []common.Hash{transfer}), root)
+	assert.Equal(t, merkleRootFromProof(transfer, 1, []common.Hash{coinbase}), root)
+	assert.Equal(t, merkleRootFromProof(transfer, 0, []common.Hash{coinbase}) == root, false)
+
+	// single transaction block
+	txID, err := btcTxID(hexutil.MustHexToBytes(testBTCGenesisTx))
+	assert.NoError(t, err)
+	assert.Equal(t, merkleRootFromProof(txID, 0, nil), common.MustHexToHash("0x4a5e1e4baab89f3a32518a88c31bc87f618f76673e2cc77ab2127b7afdeda33b"))
+
+	_, err = btcTxID(make([]byte, 64))
+	assert.Equal(t, err, errBTCTxLength)
+}
+
 func Test_verifyTx(t *testing.T) {
 	req, reqBytes := newTestRelayRequest(t)
 	ctx := newTestRelayContext(t)
 
 	// simulate svm transfer amount to contract address
 	ctx.statedb.AddBalance(BTCRelayContractAddress, ctx.tx.Data.Amount)
-	blocks := getBTCBlocks(ctx.statedb)
-	oriRelayerBalance := ctx.statedb.GetBalance(blocks[req.BlockHeaderHex].Relayer)
+	relayer := getBTCBlock(ctx.statedb, req.BlockHash).Relayer
+	oriRelayerBalance := ctx.statedb.GetBalance(relayer)
 	oriContractBalance := ctx.statedb.GetBalance(BTCRelayContractAddress)
 
 	ok1, err1 := verifyTx(reqBytes, ctx)
 	assert.NoError(t, err1)
 	assert.Equal(t, success, ok1)
 
-	curRelayerBalance := ctx.statedb.GetBalance(blocks[req.BlockHeaderHex].Relayer)
+	curRelayerBalance := ctx.statedb.GetBalance(relayer)
 	curContractBalance := ctx.statedb.GetBalance(BTCRelayContractAddress)
 	assert.Equal(t, curContractBalance.Add(curContractBalance, ctx.tx.Data.Amount), oriContractBalance)
 	assert.Equal(t, curRelayerBalance.Sub(curRelayerBalance, ctx.tx.Data.Amount), oriRelayerBalance)
@@ -44,19 +144,11 @@ func Test_verifyTx(t *testing.T) {
 	assert.Equal(t, failure, ok2)
 
 	// confirmation is less than 6
-	for _, block := range blocks {
-		if block.Height < preBlockHeight-6 {
-			req1, _ := newTestRelayRequest(t)
-			req1.BlockHeaderHex = block.BlockHeaderHex
-			reqBytes1, err1 := json.Marshal(req1)
-			assert.Equal(t, nil, err1)
-
-			ok2, err2 := verifyTx(reqBytes1, ctx)
-			assert.Error(t, err2)
-			assert.Equal(t, failure, ok2)
-			break
-		}
-	}
+	req1, _ := newTestRelayRequest(t)
+	req1.BlockHash = common.MustHexToHash(testBTCHashes[5])
+	ok3, err3 := verifyTx(mustMarshal(t, req1), ctx)
+	assert.Error(t, err3)
+	assert.Equal(t, failure, ok3)
 
 	// fee is not enough
 	ctx.tx.Data.Amount = big.NewInt(0)
@@ -65,21 +157,24 @@ func Test_verifyTx(t *testing.T) {
 	assert.Equal(t, failure, ok4)
 	ctx.tx.Data.Amount = big.NewInt(1)
 
+	// index out of the proof
+	req.TxIndex = 1
+	ok5, err5 := verifyTx(mustMarshal(t, req), ctx)
+	assert.Equal(t, err5, errBTCInvalidTxIndex)
+	assert.Equal(t, failure, ok5)
+	req.TxIndex = 0
+
 	// tx doesn't exist
-	req.TxHex = "asdf"
-	reqBytes2, err21 := json.Marshal(req)
-	assert.NoError(t, err21)
-	ok6, err6 := verifyTx(reqBytes2, ctx)
+	req.TxHex = common.Bytes{1, 2, 3, 4}
+	ok6, err6 := verifyTx(mustMarshal(t, req), ctx)
 	assert.NoError(t, err6)
 	assert.Equal(t, failure, ok6)
 
 	// blockheader doesn't exist
-	req.BlockHeaderHex = "asdf"
-	reqBytes1, err11 := json.Marshal(req)
-	assert.NoError(t, err11)
-	ok5, err5 := verifyTx(reqBytes1, ctx)
-	assert.NoError(t, err5)
-	assert.Equal(t, failure, ok5)
+	req.BlockHash = common.MustHexToHash("0x1234000000000000000000000000000000000000000000000000000000000000")
+	ok7, err7 := verifyTx(mustMarshal(t, req), ctx)
+	assert.NoError(t, err7)
+	assert.Equal(t, failure, ok7)
 }
 
 func Test_relayTx(t *testing.T) {
@@ -88,15 +183,15 @@ func Test_relayTx(t *testing.T) {
 
 	// simulate svm transfer amount to contract address
 	ctx.statedb.AddBalance(BTCRelayContractAddress, ctx.tx.Data.Amount)
-	blocks := getBTCBlocks(ctx.statedb)
-	oriRelayerBalance := ctx.statedb.GetBalance(blocks[req.BlockHeaderHex].Relayer)
+	relayer := getBTCBlock(ctx.statedb, req.BlockHash).Relayer
+	oriRelayerBalance := ctx.statedb.GetBalance(relayer)
 	oriContractBalance := ctx.statedb.GetBalance(BTCRelayContractAddress)
 
 	ok1, err1 := relayTx(reqBytes, ctx)
 	assert.NoError(t, err1)
 	assert.Equal(t, success, ok1)
 
-	curRelayerBalance := ctx.statedb.GetBalance(blocks[req.BlockHeaderHex].Relayer)
+	curRelayerBalance := ctx.statedb.GetBalance(relayer)
 	curContractBalance := ctx.statedb.GetBalance(BTCRelayContractAddress)
 	assert.Equal(t, curContractBalance.Add(curContractBalance, ctx.tx.Data.Amount), oriContractBalance)
 	assert.Equal(t, curRelayerBalance.Sub(curRelayerBalance, ctx.tx.Data.Amount), oriRelayerBalance)
@@ -108,61 +203,223 @@ func Test_relayTx(t *testing.T) {
 }
 
 func Test_storeBlockHeader(t *testing.T) {
-	req, _ := newTestRelayRequest(t)
-	req.PreviousBlockHex = req.BlockHeaderHex
-	req.BlockHeaderHex = crypto.MustGenerateRandomAddress().Hex()
-	req.TxHexs = []string{crypto.MustGenerateRandomAddress().Hex()}
-	req.Height++
-	reqBytes, err := json.Marshal(req)
-	assert.NoError(t, err)
-
 	ctx := newTestRelayContext(t)
-	ok1, err1 := storeBlockHeader(reqBytes, ctx)
-	assert.NoError(t, err1)
-	assert.Equal(t, success, ok1)
-	assert.Equal(t, req.Height, preBlockHeight)
 
-	blocks := getBTCBlocks(ctx.statedb)
-	block := blocks[req.BlockHeaderHex]
-	assert.Equal(t, req.BlockHeaderHex, block.BlockHeaderHex)
-	assert.Equal(t, req.Height, block.Height)
-	assert.Equal(t, req.PreviousBlockHex, block.PreviousBlockHex)
-	assert.Equal(t, req.TxHexs, block.TxHexs)
+	best := getBTCBlock(ctx.statedb, getBestBlockHash(ctx.statedb))
+	assert.Equal(t, best.Hash, common.MustHexToHash(testBTCHashes[9]))
+	assert.Equal(t, best.Height, uint64(9))
+	assert.Equal(t, best.TotalWork, new(big.Int).Mul(big.NewInt(0x100010001), big.NewInt(10)))
+	assert.Equal(t, best.Relayer, ctx.tx.Data.From)
+	for height, hash := range testBTCHashes {
+		assert.Equal(t, getMainChainHash(ctx.statedb, uint64(height)), common.MustHexToHash(hash))
+	}
 
 	// invalid request
-	ok2, err2 := storeBlockHeader([]byte{0, 1, 2, 3}, ctx)
-	assert.Error(t, err2)
-	assert.Equal(t, failure, ok2)
+	ok1, err1 := storeBlockHeader([]byte{0, 1, 2, 3}, ctx)
+	assert.Error(t, err1)
+	assert.Equal(t, failure, ok1)
 
 	// repeat store
-	ok3, err3 := storeBlockHeader(reqBytes, ctx)
-	assert.Error(t, err3)
+	ok2, err2 := storeBlockHeader(newTestStoreRequest(t, 3), ctx)
+	assert.Equal(t, err2, errBTCBlockExists)
+	assert.Equal(t, failure, ok2)
+
+	// invalid pow
+	header, _ := ParseBTCHeader(hexutil.MustHexToBytes(testBTCHeaders[9]))
+	header.Nonce++
+	ok3, err3 := storeBlockHeader(mustMarshal(t, &StoreHeaderRequest{Header: header.Bytes()}), ctx)
+	assert.Equal(t, err3, errBTCPowInvalid)
 	assert.Equal(t, failure, ok3)
+
+	// previous block doesn't exist
+	ctx = newTestRelayContextWithHeaders(t, 2)
+	ok4, err4 := storeBlockHeader(newTestStoreRequest(t, 3), ctx)
+	assert.Equal(t, err4, errBTCPrevNotFound)
+	assert.Equal(t, failure, ok4)
+}
+
+func Test_storeBlockHeader_Checkpoint(t *testing.T) {
+	ctx := newTestRelayContextWithHeaders(t, 0)
+
+	// the first header must be the checkpoint
+	ok, err := storeBlockHeader(newTestStoreRequest(t, 3), ctx)
+	assert.Equal(t, err, errBTCNotCheckpoint)
+	assert.Equal(t, failure, ok)
+
+	defer func(checkpoint BTCCheckpoint) { btcCheckpoint = checkpoint }(btcCheckpoint)
+	btcCheckpoint = BTCCheckpoint{
+		Hash:       common.MustHexToHash(testBTCHashes[3]),
+		Height:     3,
+		Bits:       0x1d00ffff,
+		EpochStart: 1231006505,
+	}
+
+	ok, err = storeBlockHeader(newTestStoreRequest(t, 2), ctx)
+	assert.Equal(t, err, errBTCNotCheckpoint)
+	assert.Equal(t, failure, ok)
+
+	ok, err = storeBlockHeader(newTestStoreRequest(t, 3), ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, success, ok)
+
+	ok, err = storeBlockHeader(newTestStoreRequest(t, 4), ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, success, ok)
+
+	best := getBTCBlock(ctx.statedb, getBestBlockHash(ctx.statedb))
+	assert.Equal(t, best.Height, uint64(4))
+	assert.Equal(t, best.EpochStart, uint32(1231006505))
+}
+
+func Test_connectBTCBlock(t *testing.T) {
+	parentHeader, _ := ParseBTCHeader(hexutil.MustHexToBytes(testBTCHeaders[8]))
+	header, _ := ParseBTCHeader(hexutil.MustHexToBytes(testBTCHeaders[9]))
+	parent := &BTCBlock{Header: parentHeader.Bytes(), Height: 8, TotalWork: big.NewInt(1), EpochStart: 1231006505}
+
+	block := &BTCBlock{}
+	assert.NoError(t, connectBTCBlock(block, header, parent))
+	assert.Equal(t, block.Height, uint64(9))
+	assert.Equal(t, block.EpochStart, parent.EpochStart)
+	assert.Equal(t, block.TotalWork, big.NewInt(0x100010002))
+
+	// the difficulty changes out of the retarget height
+	parentHeader.Bits = 0x1c7fff80
+	parent.Header = parentHeader.Bytes()
+	assert.Error(t, connectBTCBlock(block, header, parent))
+
+	// the difficulty is adjusted at the retarget height, the blocks came on time
+	parent.Height = btcRetargetInterval - 1
+	parent.EpochStart = parentHeader.Timestamp - uint32(btcTargetTimespan)
+	assert.Error(t, connectBTCBlock(block, header, parent))
+	parentHeader.Bits = 0x1d00ffff
+	parent.Header = parentHeader.Bytes()
+	assert.NoError(t, connectBTCBlock(block, header, parent))
+	assert.Equal(t, block.EpochStart, header.Timestamp)
+}
+
+func Test_setBestBlock_Reorg(t *testing.T) {
+	ctx := newTestRelayContext(t)
+
+	// a heavier fork from the block 2
+	fork := &BTCHeader{Version: 1, PrevBlock: common.MustHexToHash(testBTCHashes[2]), Bits: 0x1d00ffff}
+	forkBlock := &BTCBlock{
+		Hash:      fork.Hash(),
+		Header:    fork.Bytes(),
+		Height:    3,
+		TotalWork: new(big.Int).Mul(big.NewInt(0x100010001), big.NewInt(100)),
+	}
+	putBTCBlock(ctx.statedb, forkBlock)
+	assert.NoError(t, setBestBlock(ctx.statedb, forkBlock))
+
+	assert.Equal(t, getBestBlockHash(ctx.statedb), forkBlock.Hash)
+	assert.Equal(t, getMainChainHash(ctx.statedb, 3), forkBlock.Hash)
+	assert.Equal(t, getMainChainHash(ctx.statedb, 2), common.MustHexToHash(testBTCHashes[2]))
+
+	// the old chain is not confirmed any more
+	req, reqBytes := newTestRelayRequest(t)
+	ok, err := verifyTx(reqBytes, ctx)
+	assert.Error(t, err)
+	assert.Equal(t, failure, ok)
+
+	req.BlockHash = common.MustHexToHash(testBTCHashes[5])
+	ok, err = verifyTx(mustMarshal(t, req), ctx)
+	assert.Equal(t, err, errBTCNotInMainChain)
+	assert.Equal(t, failure, ok)
+}
+
+func Test_setBestBlock_ReorgTooDeep(t *testing.T) {
+	ctx := newTestRelayContext(t)
+	best := getBestBlockHash(ctx.statedb)
+
+	// a heavier fork from the block 2 which is longer than the max reorg depth
+	prev := common.MustHexToHash(testBTCHashes[2])
+	var forkBlock *BTCBlock
+	for height := uint64(3); height < 3+btcMaxReorgDepth+1; height++ {
+		fork := &BTCHeader{Version: 1, PrevBlock: prev, Bits: 0x1d00ffff}
+		forkBlock = &BTCBlock{Hash: fork.Hash(), Header: fork.Bytes(), Height: height, TotalWork: big.NewInt(int64(height))}
+		putBTCBlock(ctx.statedb, forkBlock)
+		prev = forkBlock.Hash
+	}
+
+	assert.Equal(t, setBestBlock(ctx.statedb, forkBlock), errBTCReorgTooDeep)
+	assert.Equal(t, getBestBlockHash(ctx.statedb), best)
+	assert.Equal(t, getMainChainHash(ctx.statedb, 3), common.MustHexToHash(testBTCHashes[3]))
+
+	// reorg at the max depth
+	parent, _ := ParseBTCHeader(forkBlock.Header)
+	assert.NoError(t, setBestBlock(ctx.statedb, getBTCBlock(ctx.statedb, parent.PrevBlock)))
+	assert.Equal(t, getMainChainHash(ctx.statedb, 3) == common.MustHexToHash(testBTCHashes[3]), false)
+}
+
+func Test_storeBlockHeader_Relayer(t *testing.T) {
+	ctx := newTestRelayContextWithHeaders(t, 0)
+
+	// only the active masternodes could relay headers
+	ctx.tx.Data.From = *crypto.MustGenerateShardAddress(1)
+	ok, err := storeBlockHeader(newTestStoreRequest(t, 0), ctx)
+	assert.Error(t, err)
+	assert.Equal(t, failure, ok)
+
+	assert.NoError(t, saveInfo(ctx.tx.Data.From.Bytes(), ctx.statedb, &masternodeInfo{IsQuit: true}))
+	ok, err = storeBlockHeader(newTestStoreRequest(t, 0), ctx)
+	assert.Error(t, err)
+	assert.Equal(t, failure, ok)
+
+	assert.NoError(t, saveInfo(ctx.tx.Data.From.Bytes(), ctx.statedb, &masternodeInfo{}))
+	ok, err = storeBlockHeader(newTestStoreRequest(t, 0), ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, success, ok)
+}
+
+func Test_BTCRelayV0(t *testing.T) {
+	db, dispose := leveldb.NewTestDatabase()
+	defer dispose()
+
+	ctx := newTestContext(db, BTCRelayContractAddress)
+	ctx.statedb.CreateAccount(ctx.tx.Data.From)
+	ctx.statedb.AddBalance(BTCRelayContractAddress, big.NewInt(10))
+	v0 := GetContractByHeight(BTCRelayContractAddress, common.BTCRelayForkHeight-1)
+	assert.Equal(t, v0.RequiredGas([]byte{CmdGetBestBlockHeader}), gasInvalidCommand)
+
+	// the blocks are stored in the JSON map by any relayer
+	for height := 0; height < 8; height++ {
+		block := relayRequestV0{btcBlockV0: btcBlockV0{BlockHeaderHex: testBTCHeaders[height], Height: uint64(height), TxHexs: []string{testBTCHashes[height]}}}
+		ok, err := v0.Run(append([]byte{CmdStoreBlockHeader}, mustMarshal(t, &block)...), ctx)
+		assert.NoError(t, err)
+		assert.Equal(t, success, ok)
+	}
+
+	assert.Equal(t, len(getBTCBlocksV0(ctx.statedb)), 8)
+	assert.Equal(t, getBestBlockHash(ctx.statedb), common.EmptyHash)
+
+	request := relayRequestV0{btcBlockV0: btcBlockV0{BlockHeaderHex: testBTCHeaders[2]}, TxHex: testBTCHashes[2]}
+	ok, err := v0.Run(append([]byte{CmdVerifyTx}, mustMarshal(t, &request)...), ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, success, ok)
+	assert.Equal(t, ctx.statedb.GetBalance(ctx.tx.Data.From), ctx.tx.Data.Amount)
 }
 
 func Test_getBlockHeader(t *testing.T) {
 	req, _ := newTestRelayRequest(t)
-	headerBytes, err := hexutil.HexToBytes(req.BlockHeaderHex)
-	assert.NoError(t, err)
 	// simulate svm transfer amount to contract address
 	ctx := newTestRelayContext(t)
 	ctx.statedb.AddBalance(BTCRelayContractAddress, ctx.tx.Data.Amount)
-	blocks := getBTCBlocks(ctx.statedb)
-	oriRelayerBalance := ctx.statedb.GetBalance(blocks[req.BlockHeaderHex].Relayer)
+	relayer := getBTCBlock(ctx.statedb, req.BlockHash).Relayer
+	oriRelayerBalance := ctx.statedb.GetBalance(relayer)
 	oriContractBalance := ctx.statedb.GetBalance(BTCRelayContractAddress)
 
-	ok, err1 := getBlockHeader(headerBytes, ctx)
+	ok, err1 := getBlockHeader(req.BlockHash.Bytes(), ctx)
 	assert.NoError(t, err1)
 	assert.Equal(t, success, ok)
 
-	curRelayerBalance := ctx.statedb.GetBalance(blocks[req.BlockHeaderHex].Relayer)
+	curRelayerBalance := ctx.statedb.GetBalance(relayer)
 	curContractBalance := ctx.statedb.GetBalance(BTCRelayContractAddress)
 	assert.Equal(t, curContractBalance.Add(curContractBalance, ctx.tx.Data.Amount), oriContractBalance)
 	assert.Equal(t, curRelayerBalance.Sub(curRelayerBalance, ctx.tx.Data.Amount), oriRelayerBalance)
 
 	// fee is not enough
 	ctx.tx.Data.Amount = big.NewInt(0)
-	ok2, err2 := getBlockHeader(headerBytes, ctx)
+	ok2, err2 := getBlockHeader(req.BlockHash.Bytes(), ctx)
 	assert.Error(t, err2)
 	assert.Equal(t, failure, ok2)
 	ctx.tx.Data.Amount = big.NewInt(1)
@@ -173,7 +430,28 @@ func Test_getBlockHeader(t *testing.T) {
 	assert.Equal(t, failure, ok3)
 }
 
+func Test_getBestBlockHeader(t *testing.T) {
+	ctx := newTestRelayContextWithHeaders(t, 0)
+	_, err := getBestBlockHeader(nil, ctx)
+	assert.Equal(t, err, errBTCNoBestBlock)
+
+	ctx = newTestRelayContext(t)
+	result, err := getBestBlockHeader(nil, ctx)
+	assert.NoError(t, err)
+
+	var best BTCBlock
+	assert.NoError(t, json.Unmarshal(result, &best))
+	assert.Equal(t, best.Hash, common.MustHexToHash(testBTCHashes[9]))
+	assert.Equal(t, best.Height, uint64(9))
+	assert.NotEmpty(t, best.String())
+}
+
 func newTestRelayContext(t *testing.T) *Context {
+	return newTestRelayContextWithHeaders(t, len(testBTCHeaders))
+}
+
+// newTestRelayContextWithHeaders creates the context with the first n mainnet headers stored
+func newTestRelayContextWithHeaders(t *testing.T, n int) *Context {
 	dbPath := filepath.Join(common.GetTempFolder(), ".newTestRelayContext")
 	db, err := leveldb.NewLevelDB(dbPath)
 	if err != nil {
@@ -185,35 +463,40 @@ func newTestRelayContext(t *testing.T) *Context {
 		os.RemoveAll(dbPath)
 	}()
 
+	// the relayer is an active masternode
 	ctx := newTestContext(db, BTCRelayContractAddress)
-	req, reqBytes := newTestRelayRequest(t)
-	storeBlockHeader(reqBytes, ctx)
-	for height := uint64(0); height < 10; height++ {
-		req.PreviousBlockHex = req.BlockHeaderHex
-		req.BlockHeaderHex = crypto.MustGenerateRandomAddress().Hex()
-		req.TxHexs = []string{crypto.MustGenerateRandomAddress().Hex()}
-		req.Height = height
-		reqBytes, _ := json.Marshal(req)
-		storeBlockHeader(reqBytes, ctx)
+	ctx.statedb.CreateAccount(MasternodeContractAddress)
+	if err = saveInfo(ctx.tx.Data.From.Bytes(), ctx.statedb, &masternodeInfo{}); err != nil {
+		panic(err)
+	}
+
+	for height := 0; height < n; height++ {
+		ok, err := storeBlockHeader(newTestStoreRequest(t, height), ctx)
+		assert.NoError(t, err)
+		assert.Equal(t, success, ok)
 	}
 
 	return ctx
 }
 
+func newTestStoreRequest(t *testing.T, height int) []byte {
+	return mustMarshal(t, &StoreHeaderRequest{Header: hexutil.MustHexToBytes(testBTCHeaders[height])})
+}
+
 func newTestRelayRequest(t *testing.T) (*RelayRequest, []byte) {
 	req := &RelayRequest{
-		BTCBlock: BTCBlock{
-			BlockHeaderHex:   "0x0f9188f13cb7b2c71f2a335e3a4fc328bf5beb436012afca590b1a11466e2206",
-			Height:           10,
-			PreviousBlockHex: "0x2a53daccb2587168ee58e385e7ba274de1ae37c5d21b6b709a81d019fa2a65b4",
-			TxHexs:           []string{"0x4a5e1e4baab89f3a32518a88c31bc87f618f76673e2cc77ab2127b7afdeda33b"},
-		},
-		TxHex:        "0x4a5e1e4baab89f3a32518a88c31bc87f618f76673e2cc77ab2127b7afdeda33b",
+		BlockHash:    common.MustHexToHash(testBTCHashes[0]),
+		TxHex:        hexutil.MustHexToBytes(testBTCGenesisTx),
+		TxIndex:      0,
 		RelayAddress: common.EmptyAddress,
 	}
-	reqBytes, err := json.Marshal(req)
-	assert.NoError(t, err)
 	assert.NotEmpty(t, req.String())
 
-	return req, reqBytes
+	return req, mustMarshal(t, req)
+}
+
+func mustMarshal(t *testing.T, v interface{}) []byte {
+	bytes, err := json.Marshal(v)
+	assert.NoError(t, err)
+	return bytes
 }
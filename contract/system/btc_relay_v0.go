@@ -0,0 +1,155 @@
+/**
+* @file
+* @copyright defined in scdo/LICENSE
+ */
+
+package system
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/elcn233/go-scdo/common"
+	"github.com/elcn233/go-scdo/common/hexutil"
+	"github.com/elcn233/go-scdo/core/state"
+)
+
+var (
+	// brCommandsV0 are the original commands before BTCRelayForkHeight, which store
+	// the relayed blocks in a JSON map without verification
+	brCommandsV0 = map[byte]*cmdInfo{
+		CmdVerifyTx:         &cmdInfo{1000, verifyTxV0},
+		CmdRelayTx:          &cmdInfo{3000, relayTxV0},
+		CmdStoreBlockHeader: &cmdInfo{10000, storeBlockHeaderV0},
+		CmdGetBlockHeader:   &cmdInfo{200, getBlockHeaderV0},
+	}
+
+	brSchemaV0 = []CommandSchema{
+		{ID: CmdVerifyTx, Name: "verifyTx", Input: TypeJSON, Output: TypeBytes},
+		{ID: CmdRelayTx, Name: "relayTx", Input: TypeJSON, Output: TypeBytes},
+		{ID: CmdStoreBlockHeader, Name: "storeBlockHeader", Input: TypeJSON, Output: TypeBytes},
+		{ID: CmdGetBlockHeader, Name: "getBlockHeader", Input: TypeBytes, Output: TypeBytes},
+	}
+
+	// temp storage
+	preBlockHeight uint64
+	keyBlocksHash  = common.BytesToHash([]byte("BTC-Blocks-Hash"))
+)
+
+// btcBlockV0 is the btc block structure stored before BTCRelayForkHeight
+type btcBlockV0 struct {
+	BlockHeaderHex   string
+	Height           uint64
+	PreviousBlockHex string
+	TxHexs           []string
+	Relayer          common.Address
+}
+
+// relayRequestV0 is the request structure using btc-relay before BTCRelayForkHeight
+type relayRequestV0 struct {
+	btcBlockV0
+	TxHex string
+	// This is used to relay the verification of successful tx to the contract address
+	RelayAddress common.Address
+}
+
+func verifyTxV0(request []byte, ctx *Context) ([]byte, error) {
+	var relayRequest relayRequestV0
+	if err := json.Unmarshal(request, &relayRequest); err != nil {
+		return failure, fmt.Errorf("Invalid request parameter, %s", err)
+	}
+
+	amount := ctx.tx.Data.Amount
+	if amount.Uint64() < fee {
+		return failure, fmt.Errorf("Verify tx fee is not enough, expected[%d], actual[%d]", fee, amount)
+	}
+
+	// Match block
+	blocks := getBTCBlocksV0(ctx.statedb)
+	btcBlock, ok := blocks[relayRequest.BlockHeaderHex]
+	if !ok {
+		return failure, nil
+	}
+
+	if preBlockHeight < btcConfirm || btcBlock.Height < preBlockHeight-btcConfirm {
+		return failure, fmt.Errorf("Confirmation need more than 6, latestHeight[%d], queryHeight[%d]", preBlockHeight, btcBlock.Height)
+	}
+
+	// Tranfer amount to Relayer
+	ctx.statedb.AddBalance(btcBlock.Relayer, amount)
+	ctx.statedb.SubBalance(BTCRelayContractAddress, amount)
+
+	for _, txHex := range btcBlock.TxHexs {
+		if relayRequest.TxHex == txHex {
+			return success, nil
+		}
+	}
+
+	return failure, nil
+}
+
+func relayTxV0(request []byte, ctx *Context) ([]byte, error) {
+	ok, err := verifyTxV0(request, ctx)
+	if err != nil {
+		return failure, err
+	}
+
+	if len(ok) == len(success) && success[0] == ok[0] {
+		return success, nil
+	}
+
+	return failure, nil
+}
+
+func storeBlockHeaderV0(request []byte, ctx *Context) ([]byte, error) {
+	var relayRequest relayRequestV0
+	if err := json.Unmarshal(request, &relayRequest); err != nil {
+		return failure, fmt.Errorf("Invalid request parameter, %s", err)
+	}
+
+	blocks := getBTCBlocksV0(ctx.statedb)
+	if _, ok := blocks[relayRequest.BlockHeaderHex]; ok {
+		return failure, fmt.Errorf("Block header already exists")
+	}
+
+	relayRequest.btcBlockV0.Relayer = ctx.tx.Data.From
+	blocks[relayRequest.BlockHeaderHex] = relayRequest.btcBlockV0
+	preBlockHeight = relayRequest.Height
+
+	bytes, err := json.Marshal(blocks)
+	if err != nil {
+		return failure, fmt.Errorf("Failed to marshal blocks, %s", err)
+	}
+
+	ctx.statedb.SetData(BTCRelayContractAddress, keyBlocksHash, bytes)
+	return success, nil
+}
+
+func getBlockHeaderV0(request []byte, ctx *Context) ([]byte, error) {
+	amount := ctx.tx.Data.Amount
+	if amount.Uint64() < fee {
+		return failure, fmt.Errorf("getBlockHeader fee is not enough, expected[%d], actual[%d]", fee, amount)
+	}
+
+	blocks, blockHeaderHex := getBTCBlocksV0(ctx.statedb), hexutil.BytesToHex(request)
+	btcBlock, ok := blocks[blockHeaderHex]
+	if !ok {
+		return failure, nil
+	}
+
+	// Tranfer amount to Relayer
+	ctx.statedb.AddBalance(btcBlock.Relayer, amount)
+	ctx.statedb.SubBalance(BTCRelayContractAddress, amount)
+
+	return success, nil
+}
+
+func getBTCBlocksV0(statedb *state.Statedb) map[string]btcBlockV0 {
+	var blocks map[string]btcBlockV0
+	data := statedb.GetData(BTCRelayContractAddress, keyBlocksHash)
+	if err := json.Unmarshal(data, &blocks); err != nil {
+		return make(map[string]btcBlockV0)
+	}
+
+	return blocks
+}
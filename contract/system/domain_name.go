@@ -6,11 +6,15 @@
 package system
 
 import (
+	"encoding/json"
 	"errors"
 	"regexp"
+	"sort"
 	"strings"
 
 	"github.com/elcn233/go-scdo/common"
+	"github.com/elcn233/go-scdo/core/state"
+	"github.com/elcn233/go-scdo/crypto"
 )
 
 const (
@@ -18,6 +22,24 @@ const (
 	CmdCreateDomainName byte = iota
 	// CmdGetDomainNameOwner query the registrar of specified domain name
 	CmdGetDomainNameOwner
+	// CmdTransferDomainName transfer the domain name to a new owner
+	CmdTransferDomainName
+	// CmdSetDomainAddress set or remove the resolved address of a shard
+	CmdSetDomainAddress
+	// CmdSetDomainContentHash set the content hash of the domain name
+	CmdSetDomainContentHash
+	// CmdSetDomainText set or remove a text record of the domain name
+	CmdSetDomainText
+	// CmdRenewDomainName extend the expiry of the domain name by a period
+	CmdRenewDomainName
+	// CmdSetReverseName set the name returned by the reverse lookup of the sender
+	CmdSetReverseName
+	// CmdResolveDomainName resolve the domain name to an address
+	CmdResolveDomainName
+	// CmdGetDomainName reverse lookup the domain name of an address
+	CmdGetDomainName
+	// CmdGetDomainRecord query the owner, expiry and records of the domain name
+	CmdGetDomainRecord
 )
 
 const (
@@ -25,6 +47,22 @@ const (
 	gasCreateDomainName = uint64(50000)
 	// gas used to get the owner of given domain
 	gasGetDomainNameOwner = uint64(100000)
+	// gas used to update the owner or the records of a domain
+	gasUpdateDomainName = uint64(30000)
+	// gas used to resolve or query a domain
+	gasQueryDomainName = uint64(5000)
+)
+
+const (
+	// DomainNamePeriod is the registration period of a domain name in seconds, one year
+	DomainNamePeriod = uint64(365 * 24 * 3600)
+	// DomainNameGracePeriod is the period after the expiry that only the owner could renew the name
+	DomainNameGracePeriod = uint64(30 * 24 * 3600)
+
+	maxDomainContentHashLength = 64
+	maxDomainTextKeyLength     = 32
+	maxDomainTextValueLength   = 256
+	maxDomainTexts             = 16
 )
 
 var (
@@ -32,16 +70,117 @@ var (
 	errNameTooLong = errors.New("name too long")
 	errInvalidName = errors.New("invalid name, only numbers, letters, and dash lines are allowed")
 
+	errDomainNotOwner        = errors.New("only the owner is allowed to update the domain name")
+	errDomainExpired         = errors.New("domain name is expired, renew it first")
+	errDomainRenewTooEarly   = errors.New("domain name could only be renewed within a period before the expiry")
+	errDomainEmptyOwner      = errors.New("new owner of the domain name is empty")
+	errDomainContentTooLong  = errors.New("content hash too long")
+	errDomainTextKeyInvalid  = errors.New("text key is empty or too long")
+	errDomainTextTooLong     = errors.New("text value too long")
+	errDomainTooManyTexts    = errors.New("too many text records")
+	errDomainReverseMismatch = errors.New("domain name is not resolved to the sender")
+
 	maxDomainNameLength = len(common.EmptyHash)
 
-	domainNameCommands = map[byte]*cmdInfo{
-		CmdCreateDomainName:   &cmdInfo{gasCreateDomainName, createDomainName},
+	// domainNameCommandsV0 are the original commands before DomainNameForkHeight
+	domainNameCommandsV0 = map[byte]*cmdInfo{
+		CmdCreateDomainName:   &cmdInfo{gasCreateDomainName, createDomainNameV0},
 		CmdGetDomainNameOwner: &cmdInfo{gasGetDomainNameOwner, getDomainNameOwner},
 	}
+
+	domainNameSchemaV0 = []CommandSchema{
+		{ID: CmdCreateDomainName, Name: "create", Input: TypeString, Output: TypeAddress},
+		{ID: CmdGetDomainNameOwner, Name: "getOwner", Input: TypeString, Output: TypeAddress, ReadOnly: true},
+	}
+
+	domainNameCommands = map[byte]*cmdInfo{
+		CmdCreateDomainName:     &cmdInfo{gasCreateDomainName, createDomainName},
+		CmdGetDomainNameOwner:   &cmdInfo{gasGetDomainNameOwner, getDomainNameOwner},
+		CmdTransferDomainName:   &cmdInfo{gasUpdateDomainName, transferDomainName},
+		CmdSetDomainAddress:     &cmdInfo{gasUpdateDomainName, setDomainAddress},
+		CmdSetDomainContentHash: &cmdInfo{gasUpdateDomainName, setDomainContentHash},
+		CmdSetDomainText:        &cmdInfo{gasUpdateDomainName, setDomainText},
+		CmdRenewDomainName:      &cmdInfo{gasUpdateDomainName, renewDomainName},
+		CmdSetReverseName:       &cmdInfo{gasUpdateDomainName, setReverseName},
+		CmdResolveDomainName:    &cmdInfo{gasQueryDomainName, resolveDomainName},
+		CmdGetDomainName:        &cmdInfo{gasQueryDomainName, getDomainName},
+		CmdGetDomainRecord:      &cmdInfo{gasQueryDomainName, getDomainRecord},
+	}
+
+	// domainNameSchema is the command schema of domainNameCommands for clients to generate calls
+	domainNameSchema = []CommandSchema{
+		{ID: CmdCreateDomainName, Name: "create", Input: TypeString, Output: TypeAddress},
+		{ID: CmdGetDomainNameOwner, Name: "getOwner", Input: TypeString, Output: TypeAddress, ReadOnly: true},
+		{ID: CmdTransferDomainName, Name: "transfer", Input: TypeJSON, Output: TypeJSON},
+		{ID: CmdSetDomainAddress, Name: "setAddress", Input: TypeJSON, Output: TypeJSON},
+		{ID: CmdSetDomainContentHash, Name: "setContent", Input: TypeJSON, Output: TypeJSON},
+		{ID: CmdSetDomainText, Name: "setText", Input: TypeJSON, Output: TypeJSON},
+		{ID: CmdRenewDomainName, Name: "renew", Input: TypeString, Output: TypeJSON},
+		{ID: CmdSetReverseName, Name: "setReverse", Input: TypeString, Output: TypeString},
+		{ID: CmdResolveDomainName, Name: "resolve", Input: TypeJSON, Output: TypeAddress, ReadOnly: true},
+		{ID: CmdGetDomainName, Name: "getName", Input: TypeAddress, Output: TypeString, ReadOnly: true},
+		{ID: CmdGetDomainRecord, Name: "getRecord", Input: TypeString, Output: TypeJSON, ReadOnly: true},
+	}
 )
 
-// createDomainName create a domain name
-func createDomainName(domainName []byte, context *Context) ([]byte, error) {
+// DomainRecord is the expiry and the resolution records of a domain name. The names
+// registered before the expiry was introduced have a zero expiry and never expire.
+type DomainRecord struct {
+	Expiry      uint64           // unix time in seconds
+	Addresses   []common.Address // at most one address per shard, sorted by shard
+	ContentHash common.Bytes
+	Texts       []DomainText
+}
+
+// DomainText is a text record of a domain name, e.g. email or url
+type DomainText struct {
+	Key   string
+	Value string
+}
+
+// DomainNameInfo is the result of the domain record query
+type DomainNameInfo struct {
+	Name  string
+	Owner common.Address
+	DomainRecord
+}
+
+// DomainTransferRequest is the request to transfer a domain name
+type DomainTransferRequest struct {
+	Name  string
+	Owner common.Address
+}
+
+// DomainAddressRequest is the request to set the address of a shard, the address
+// is set in its own shard, an empty address removes the address of the Shard.
+type DomainAddressRequest struct {
+	Name    string
+	Address common.Address
+	Shard   uint
+}
+
+// DomainContentHashRequest is the request to set the content hash, empty to remove
+type DomainContentHashRequest struct {
+	Name        string
+	ContentHash common.Bytes
+}
+
+// DomainTextRequest is the request to set a text record, an empty value removes the record
+type DomainTextRequest struct {
+	Name  string
+	Key   string
+	Value string
+}
+
+// DomainResolveRequest is the request to resolve the address of a shard, a zero
+// shard resolves the address of the lowest shard.
+type DomainResolveRequest struct {
+	Name  string
+	Shard uint
+}
+
+// createDomainNameV0 create a domain name without expiry and records
+func createDomainNameV0(domainName []byte, context *Context) ([]byte, error) {
 	key, err := domainNameToKey(domainName)
 	if err != nil {
 		return nil, err
@@ -62,6 +201,35 @@ func createDomainName(domainName []byte, context *Context) ([]byte, error) {
 	return value, nil
 }
 
+// createDomainName create a domain name
+func createDomainName(domainName []byte, context *Context) ([]byte, error) {
+	key, err := domainNameToKey(domainName)
+	if err != nil {
+		return nil, err
+	}
+
+	// create account in statedb for the first time.
+	context.statedb.CreateAccount(DomainNameContractAddress)
+
+	// ensure not exist, the name is released after the grace period
+	if value := context.statedb.GetData(DomainNameContractAddress, key); len(value) > 0 {
+		if !isDomainReleased(getDomainRecordByKey(context.statedb, key), context) {
+			return nil, errExists
+		}
+	}
+
+	// save in statedb, the name is resolved to the owner by default
+	owner := context.tx.Data.From
+	value := owner.Bytes()
+	context.statedb.SetData(DomainNameContractAddress, key, value)
+	putDomainRecord(context.statedb, key, &DomainRecord{
+		Expiry:    blockTime(context) + DomainNamePeriod,
+		Addresses: []common.Address{owner},
+	})
+
+	return value, nil
+}
+
 // getDomainNameOwner get domain name owner
 func getDomainNameOwner(domainName []byte, context *Context) ([]byte, error) {
 	key, err := domainNameToKey(domainName)
@@ -70,13 +238,236 @@ func getDomainNameOwner(domainName []byte, context *Context) ([]byte, error) {
 	}
 
 	owner := context.statedb.GetData(DomainNameContractAddress, key)
-	if len(owner) == 0 {
+	if len(owner) == 0 || isDomainReleased(getDomainRecordByKey(context.statedb, key), context) {
 		return nil, errNotFound
 	}
 
 	return owner, nil
 }
 
+// transferDomainName transfer the domain name to the new owner, the records are kept
+func transferDomainName(request []byte, context *Context) ([]byte, error) {
+	var req DomainTransferRequest
+	if err := json.Unmarshal(request, &req); err != nil {
+		return nil, err
+	}
+
+	if req.Owner.IsEmpty() {
+		return nil, errDomainEmptyOwner
+	}
+
+	key, record, err := getOwnedDomain([]byte(req.Name), context)
+	if err != nil {
+		return nil, err
+	}
+
+	context.statedb.SetData(DomainNameContractAddress, key, req.Owner.Bytes())
+	return domainNameInfo(req.Name, req.Owner, record)
+}
+
+// setDomainAddress set or remove the address of a shard
+func setDomainAddress(request []byte, context *Context) ([]byte, error) {
+	var req DomainAddressRequest
+	if err := json.Unmarshal(request, &req); err != nil {
+		return nil, err
+	}
+
+	key, record, err := getOwnedDomain([]byte(req.Name), context)
+	if err != nil {
+		return nil, err
+	}
+
+	shard := req.Shard
+	if !req.Address.IsEmpty() {
+		shard = req.Address.Shard()
+	}
+
+	addresses := make([]common.Address, 0, len(record.Addresses)+1)
+	for _, addr := range record.Addresses {
+		if addr.Shard() != shard {
+			addresses = append(addresses, addr)
+		}
+	}
+
+	if !req.Address.IsEmpty() {
+		addresses = append(addresses, req.Address)
+		sort.SliceStable(addresses, func(i, j int) bool { return addresses[i].Shard() < addresses[j].Shard() })
+	}
+
+	record.Addresses = addresses
+	putDomainRecord(context.statedb, key, record)
+
+	return domainNameInfo(req.Name, context.tx.Data.From, record)
+}
+
+// setDomainContentHash set the content hash, e.g. the hash of a website
+func setDomainContentHash(request []byte, context *Context) ([]byte, error) {
+	var req DomainContentHashRequest
+	if err := json.Unmarshal(request, &req); err != nil {
+		return nil, err
+	}
+
+	if len(req.ContentHash) > maxDomainContentHashLength {
+		return nil, errDomainContentTooLong
+	}
+
+	key, record, err := getOwnedDomain([]byte(req.Name), context)
+	if err != nil {
+		return nil, err
+	}
+
+	record.ContentHash = req.ContentHash
+	putDomainRecord(context.statedb, key, record)
+
+	return domainNameInfo(req.Name, context.tx.Data.From, record)
+}
+
+// setDomainText set or remove a text record
+func setDomainText(request []byte, context *Context) ([]byte, error) {
+	var req DomainTextRequest
+	if err := json.Unmarshal(request, &req); err != nil {
+		return nil, err
+	}
+
+	if len(req.Key) == 0 || len(req.Key) > maxDomainTextKeyLength {
+		return nil, errDomainTextKeyInvalid
+	}
+
+	if len(req.Value) > maxDomainTextValueLength {
+		return nil, errDomainTextTooLong
+	}
+
+	key, record, err := getOwnedDomain([]byte(req.Name), context)
+	if err != nil {
+		return nil, err
+	}
+
+	texts := make([]DomainText, 0, len(record.Texts)+1)
+	for _, text := range record.Texts {
+		if text.Key != req.Key {
+			texts = append(texts, text)
+		}
+	}
+
+	if len(req.Value) > 0 {
+		if len(texts) >= maxDomainTexts {
+			return nil, errDomainTooManyTexts
+		}
+
+		texts = append(texts, DomainText{req.Key, req.Value})
+	}
+
+	record.Texts = texts
+	putDomainRecord(context.statedb, key, record)
+
+	return domainNameInfo(req.Name, context.tx.Data.From, record)
+}
+
+// renewDomainName extend the expiry by a period, the owner could renew the name
+// within a period before the expiry until the end of the grace period, so that the
+// name is never held for more than two periods ahead. The names without expiry are unchanged.
+func renewDomainName(domainName []byte, context *Context) ([]byte, error) {
+	key, err := domainNameToKey(domainName)
+	if err != nil {
+		return nil, err
+	}
+
+	owner, record, err := getDomainOwnerAndRecord(key, context)
+	if err != nil {
+		return nil, err
+	}
+
+	if !owner.Equal(context.tx.Data.From) {
+		return nil, errDomainNotOwner
+	}
+
+	if record.Expiry > 0 {
+		if record.Expiry >= blockTime(context)+DomainNamePeriod {
+			return nil, errDomainRenewTooEarly
+		}
+
+		record.Expiry += DomainNamePeriod
+		putDomainRecord(context.statedb, key, record)
+	}
+
+	return domainNameInfo(string(domainName), owner, record)
+}
+
+// setReverseName set the name of the sender for the reverse lookup, the name must
+// be resolved to the sender in its shard. An empty name removes the reverse record.
+func setReverseName(domainName []byte, context *Context) ([]byte, error) {
+	from := context.tx.Data.From
+	context.statedb.CreateAccount(DomainNameContractAddress)
+
+	if len(domainName) == 0 {
+		context.statedb.SetData(DomainNameContractAddress, domainReverseKey(from), nil)
+		return nil, nil
+	}
+
+	addr, err := resolveDomainAddress(domainName, from.Shard(), context)
+	if err != nil {
+		return nil, err
+	}
+
+	if !addr.Equal(from) {
+		return nil, errDomainReverseMismatch
+	}
+
+	context.statedb.SetData(DomainNameContractAddress, domainReverseKey(from), domainName)
+	return domainName, nil
+}
+
+// resolveDomainName resolve the domain name to the address of the requested shard
+func resolveDomainName(request []byte, context *Context) ([]byte, error) {
+	var req DomainResolveRequest
+	if err := json.Unmarshal(request, &req); err != nil {
+		return nil, err
+	}
+
+	addr, err := resolveDomainAddress([]byte(req.Name), req.Shard, context)
+	if err != nil {
+		return nil, err
+	}
+
+	return addr.Bytes(), nil
+}
+
+// getDomainName reverse lookup the name of the address, the name is returned only
+// if it is still resolved to the address
+func getDomainName(address []byte, context *Context) ([]byte, error) {
+	addr, err := common.NewAddress(address)
+	if err != nil {
+		return nil, err
+	}
+
+	name := context.statedb.GetData(DomainNameContractAddress, domainReverseKey(addr))
+	if len(name) == 0 {
+		return nil, errNotFound
+	}
+
+	resolved, err := resolveDomainAddress(name, addr.Shard(), context)
+	if err != nil || !resolved.Equal(addr) {
+		return nil, errNotFound
+	}
+
+	return name, nil
+}
+
+// getDomainRecord get the owner, the expiry and the records of the domain name in json
+func getDomainRecord(domainName []byte, context *Context) ([]byte, error) {
+	key, err := domainNameToKey(domainName)
+	if err != nil {
+		return nil, err
+	}
+
+	owner, record, err := getDomainOwnerAndRecord(key, context)
+	if err != nil {
+		return nil, err
+	}
+
+	return domainNameInfo(string(domainName), owner, record)
+}
+
 // ValidateDomainName validate domain name
 func ValidateDomainName(domainName []byte) error {
 	nameLen := len(domainName)
@@ -110,3 +501,108 @@ func domainNameToKey(domainName []byte) (common.Hash, error) {
 
 	return common.BytesToHash(domainName), nil
 }
+
+// resolveDomainAddress resolve the domain name to the address of the shard, the
+// expired name is not resolved
+func resolveDomainAddress(domainName []byte, shard uint, context *Context) (common.Address, error) {
+	key, err := domainNameToKey(domainName)
+	if err != nil {
+		return common.EmptyAddress, err
+	}
+
+	_, record, err := getDomainOwnerAndRecord(key, context)
+	if err != nil {
+		return common.EmptyAddress, err
+	}
+
+	if isDomainExpired(record, context) {
+		return common.EmptyAddress, errDomainExpired
+	}
+
+	for _, addr := range record.Addresses {
+		if shard == 0 || addr.Shard() == shard {
+			return addr, nil
+		}
+	}
+
+	return common.EmptyAddress, errNotFound
+}
+
+// getOwnedDomain get the record of the domain name which is owned by the sender and not expired
+func getOwnedDomain(domainName []byte, context *Context) (common.Hash, *DomainRecord, error) {
+	key, err := domainNameToKey(domainName)
+	if err != nil {
+		return common.EmptyHash, nil, err
+	}
+
+	owner, record, err := getDomainOwnerAndRecord(key, context)
+	if err != nil {
+		return common.EmptyHash, nil, err
+	}
+
+	if !owner.Equal(context.tx.Data.From) {
+		return common.EmptyHash, nil, errDomainNotOwner
+	}
+
+	if isDomainExpired(record, context) {
+		return common.EmptyHash, nil, errDomainExpired
+	}
+
+	return key, record, nil
+}
+
+// getDomainOwnerAndRecord get the owner and the record of a registered domain name
+func getDomainOwnerAndRecord(key common.Hash, context *Context) (common.Address, *DomainRecord, error) {
+	value := context.statedb.GetData(DomainNameContractAddress, key)
+	if len(value) == 0 {
+		return common.EmptyAddress, nil, errNotFound
+	}
+
+	record := getDomainRecordByKey(context.statedb, key)
+	if isDomainReleased(record, context) {
+		return common.EmptyAddress, nil, errNotFound
+	}
+
+	return common.BytesToAddress(value), record, nil
+}
+
+func getDomainRecordByKey(statedb *state.Statedb, key common.Hash) *DomainRecord {
+	var record DomainRecord
+	if data := statedb.GetData(DomainNameContractAddress, domainRecordKey(key)); len(data) > 0 {
+		if err := common.Deserialize(data, &record); err != nil {
+			return &DomainRecord{}
+		}
+	}
+
+	return &record
+}
+
+func putDomainRecord(statedb *state.Statedb, key common.Hash, record *DomainRecord) {
+	statedb.SetData(DomainNameContractAddress, domainRecordKey(key), common.SerializePanic(record))
+}
+
+func domainNameInfo(name string, owner common.Address, record *DomainRecord) ([]byte, error) {
+	return json.Marshal(&DomainNameInfo{name, owner, *record})
+}
+
+func domainRecordKey(key common.Hash) common.Hash {
+	return crypto.HashBytes([]byte("Domain-Record"), key.Bytes())
+}
+
+func domainReverseKey(addr common.Address) common.Hash {
+	return crypto.HashBytes([]byte("Domain-Reverse"), addr.Bytes())
+}
+
+// isDomainExpired returns true if the domain name is in the grace period or released
+func isDomainExpired(record *DomainRecord, context *Context) bool {
+	return record.Expiry > 0 && blockTime(context) >= record.Expiry
+}
+
+// isDomainReleased returns true if the grace period is over, anyone could register it again
+func isDomainReleased(record *DomainRecord, context *Context) bool {
+	return record.Expiry > 0 && blockTime(context) >= record.Expiry+DomainNameGracePeriod
+}
+
+func blockTime(context *Context) uint64 {
+	return context.BlockHeader.CreateTimestamp.Uint64()
+}
@@ -6,9 +6,12 @@
 package system
 
 import (
+	"encoding/json"
+	"math/big"
 	"testing"
 
 	"github.com/elcn233/go-scdo/common"
+	"github.com/elcn233/go-scdo/crypto"
 	"github.com/elcn233/go-scdo/database/leveldb"
 	"github.com/stretchr/testify/assert"
 )
@@ -75,3 +78,276 @@ func Test_CreateDomainName(t *testing.T) {
 	assert.Equal(t, result, []byte(nil))
 	assert.Equal(t, err, errNotFound)
 }
+
+func mustJSON(v interface{}) []byte {
+	data, err := json.Marshal(v)
+	if err != nil {
+		panic(err)
+	}
+
+	return data
+}
+
+func Test_TransferDomainName(t *testing.T) {
+	db, dispose := leveldb.NewTestDatabase()
+	defer dispose()
+
+	context := newTestContext(db, DomainNameContractAddress)
+	owner := context.tx.Data.From
+	_, err := createDomainName([]byte("abc"), context)
+	assert.Equal(t, err, nil)
+
+	newOwner := *crypto.MustGenerateShardAddress(2)
+	request := mustJSON(&DomainTransferRequest{"abc", newOwner})
+	_, err = transferDomainName(request, context)
+	assert.Equal(t, err, nil)
+
+	result, err := getDomainNameOwner([]byte("abc"), context)
+	assert.Equal(t, err, nil)
+	assert.Equal(t, result, newOwner.Bytes())
+
+	// the previous owner is not allowed any more
+	_, err = transferDomainName(mustJSON(&DomainTransferRequest{"abc", owner}), context)
+	assert.Equal(t, err, errDomainNotOwner)
+
+	// empty owner
+	context.tx.Data.From = newOwner
+	_, err = transferDomainName(mustJSON(&DomainTransferRequest{"abc", common.EmptyAddress}), context)
+	assert.Equal(t, err, errDomainEmptyOwner)
+
+	// not registered
+	_, err = transferDomainName(mustJSON(&DomainTransferRequest{"xyz", owner}), context)
+	assert.Equal(t, err, errNotFound)
+}
+
+func Test_DomainRecords(t *testing.T) {
+	db, dispose := leveldb.NewTestDatabase()
+	defer dispose()
+
+	context := newTestContext(db, DomainNameContractAddress)
+	owner := context.tx.Data.From
+	_, err := createDomainName([]byte("abc"), context)
+	assert.Equal(t, err, nil)
+
+	// resolved to the owner by default
+	result, err := resolveDomainName(mustJSON(&DomainResolveRequest{"abc", 0}), context)
+	assert.Equal(t, err, nil)
+	assert.Equal(t, result, owner.Bytes())
+
+	// address of another shard
+	addr2 := *crypto.MustGenerateShardAddress(2)
+	_, err = setDomainAddress(mustJSON(&DomainAddressRequest{Name: "abc", Address: addr2}), context)
+	assert.Equal(t, err, nil)
+
+	result, err = resolveDomainName(mustJSON(&DomainResolveRequest{"abc", 2}), context)
+	assert.Equal(t, err, nil)
+	assert.Equal(t, result, addr2.Bytes())
+
+	// replace the address of shard 1
+	addr1 := *crypto.MustGenerateShardAddress(1)
+	_, err = setDomainAddress(mustJSON(&DomainAddressRequest{Name: "abc", Address: addr1}), context)
+	assert.Equal(t, err, nil)
+
+	result, err = resolveDomainName(mustJSON(&DomainResolveRequest{"abc", 1}), context)
+	assert.Equal(t, err, nil)
+	assert.Equal(t, result, addr1.Bytes())
+
+	// remove the address of shard 2
+	_, err = setDomainAddress(mustJSON(&DomainAddressRequest{Name: "abc", Shard: 2}), context)
+	assert.Equal(t, err, nil)
+
+	_, err = resolveDomainName(mustJSON(&DomainResolveRequest{"abc", 2}), context)
+	assert.Equal(t, err, errNotFound)
+
+	// content hash and text records
+	contentHash := crypto.MustHash("website").Bytes()
+	_, err = setDomainContentHash(mustJSON(&DomainContentHashRequest{"abc", contentHash}), context)
+	assert.Equal(t, err, nil)
+
+	_, err = setDomainContentHash(mustJSON(&DomainContentHashRequest{"abc", make([]byte, maxDomainContentHashLength+1)}), context)
+	assert.Equal(t, err, errDomainContentTooLong)
+
+	_, err = setDomainText(mustJSON(&DomainTextRequest{"abc", "email", "abc@scdo.org"}), context)
+	assert.Equal(t, err, nil)
+	_, err = setDomainText(mustJSON(&DomainTextRequest{"abc", "url", "https://scdo.org"}), context)
+	assert.Equal(t, err, nil)
+	_, err = setDomainText(mustJSON(&DomainTextRequest{"abc", "url", ""}), context)
+	assert.Equal(t, err, nil)
+	_, err = setDomainText(mustJSON(&DomainTextRequest{"abc", "", "value"}), context)
+	assert.Equal(t, err, errDomainTextKeyInvalid)
+
+	result, err = getDomainRecord([]byte("abc"), context)
+	assert.Equal(t, err, nil)
+
+	var info DomainNameInfo
+	assert.Equal(t, json.Unmarshal(result, &info), nil)
+	assert.Equal(t, info.Owner, owner)
+	assert.Equal(t, info.Addresses, []common.Address{addr1})
+	assert.Equal(t, info.ContentHash, common.Bytes(contentHash))
+	assert.Equal(t, info.Texts, []DomainText{{"email", "abc@scdo.org"}})
+	assert.Equal(t, info.Expiry, blockTime(context)+DomainNamePeriod)
+
+	// only the owner is allowed
+	context.tx.Data.From = addr2
+	_, err = setDomainText(mustJSON(&DomainTextRequest{"abc", "email", "hacker@scdo.org"}), context)
+	assert.Equal(t, err, errDomainNotOwner)
+}
+
+func Test_DomainNameExpiry(t *testing.T) {
+	db, dispose := leveldb.NewTestDatabase()
+	defer dispose()
+
+	context := newTestContext(db, DomainNameContractAddress)
+	owner := context.tx.Data.From
+	now := blockTime(context)
+	_, err := createDomainName([]byte("abc"), context)
+	assert.Equal(t, err, nil)
+
+	// renew is allowed only within a period before the expiry
+	_, err = renewDomainName([]byte("abc"), context)
+	assert.Equal(t, err, errDomainRenewTooEarly)
+
+	context.BlockHeader.CreateTimestamp = new(big.Int).SetUint64(now + 1)
+	_, err = renewDomainName([]byte("abc"), context)
+	assert.Equal(t, err, nil)
+	assert.Equal(t, getDomainRecordByKey(context.statedb, common.BytesToHash([]byte("abc"))).Expiry, now+2*DomainNamePeriod)
+
+	_, err = renewDomainName([]byte("abc"), context)
+	assert.Equal(t, err, errDomainRenewTooEarly)
+	now += DomainNamePeriod
+
+	// in the grace period, the name is not resolved and only renew is allowed
+	context.BlockHeader.CreateTimestamp = new(big.Int).SetUint64(now + DomainNamePeriod)
+	_, err = resolveDomainName(mustJSON(&DomainResolveRequest{"abc", 0}), context)
+	assert.Equal(t, err, errDomainExpired)
+
+	_, err = setDomainText(mustJSON(&DomainTextRequest{"abc", "email", "abc@scdo.org"}), context)
+	assert.Equal(t, err, errDomainExpired)
+
+	_, err = createDomainName([]byte("abc"), context)
+	assert.Equal(t, err, errExists)
+
+	_, err = renewDomainName([]byte("abc"), context)
+	assert.Equal(t, err, nil)
+	assert.Equal(t, getDomainRecordByKey(context.statedb, common.BytesToHash([]byte("abc"))).Expiry, now+2*DomainNamePeriod)
+
+	result, err := resolveDomainName(mustJSON(&DomainResolveRequest{"abc", 0}), context)
+	assert.Equal(t, err, nil)
+	assert.Equal(t, result, owner.Bytes())
+
+	// released after the grace period, anyone could register it again
+	context.BlockHeader.CreateTimestamp = new(big.Int).SetUint64(now + 2*DomainNamePeriod + DomainNameGracePeriod)
+	_, err = getDomainNameOwner([]byte("abc"), context)
+	assert.Equal(t, err, errNotFound)
+
+	_, err = renewDomainName([]byte("abc"), context)
+	assert.Equal(t, err, errNotFound)
+
+	other := *crypto.MustGenerateShardAddress(1)
+	context.tx.Data.From = other
+	result, err = createDomainName([]byte("abc"), context)
+	assert.Equal(t, err, nil)
+	assert.Equal(t, result, other.Bytes())
+
+	result, err = resolveDomainName(mustJSON(&DomainResolveRequest{"abc", 1}), context)
+	assert.Equal(t, err, nil)
+	assert.Equal(t, result, other.Bytes())
+}
+
+func Test_DomainNameWithoutExpiry(t *testing.T) {
+	db, dispose := leveldb.NewTestDatabase()
+	defer dispose()
+
+	// registered before the expiry was introduced
+	context := newTestContext(db, DomainNameContractAddress)
+	owner := context.tx.Data.From
+	context.statedb.SetData(DomainNameContractAddress, common.BytesToHash([]byte("abc")), owner.Bytes())
+
+	context.BlockHeader.CreateTimestamp = new(big.Int).SetUint64(blockTime(context) + 10*DomainNamePeriod)
+	_, err := resolveDomainName(mustJSON(&DomainResolveRequest{"abc", 0}), context)
+	assert.Equal(t, err, errNotFound)
+
+	_, err = setDomainAddress(mustJSON(&DomainAddressRequest{Name: "abc", Address: owner}), context)
+	assert.Equal(t, err, nil)
+
+	result, err := resolveDomainName(mustJSON(&DomainResolveRequest{"abc", 0}), context)
+	assert.Equal(t, err, nil)
+	assert.Equal(t, result, owner.Bytes())
+
+	_, err = renewDomainName([]byte("abc"), context)
+	assert.Equal(t, err, nil)
+	assert.Equal(t, getDomainRecordByKey(context.statedb, common.BytesToHash([]byte("abc"))).Expiry, uint64(0))
+}
+
+func Test_DomainNameV0(t *testing.T) {
+	db, dispose := leveldb.NewTestDatabase()
+	defer dispose()
+
+	context := newTestContext(db, DomainNameContractAddress)
+	owner := context.tx.Data.From
+
+	// only the original commands before the fork height
+	v0 := GetContractByHeight(DomainNameContractAddress, common.DomainNameForkHeight-1)
+	assert.Equal(t, v0.RequiredGas([]byte{CmdTransferDomainName}), gasInvalidCommand)
+
+	result, err := v0.Run(append([]byte{CmdCreateDomainName}, "abc"...), context)
+	assert.Equal(t, err, nil)
+	assert.Equal(t, result, owner.Bytes())
+
+	_, err = v0.Run(append([]byte{CmdCreateDomainName}, "abc"...), context)
+	assert.Equal(t, err, errExists)
+
+	// no record is created
+	key := common.BytesToHash([]byte("abc"))
+	assert.Equal(t, len(context.statedb.GetData(DomainNameContractAddress, domainRecordKey(key))), 0)
+
+	result, err = v0.Run(append([]byte{CmdGetDomainNameOwner}, "abc"...), context)
+	assert.Equal(t, err, nil)
+	assert.Equal(t, result, owner.Bytes())
+
+	// the name never expires after the fork height
+	v1 := GetContractByHeight(DomainNameContractAddress, common.DomainNameForkHeight)
+	context.BlockHeader.CreateTimestamp = new(big.Int).SetUint64(blockTime(context) + 10*DomainNamePeriod)
+	result, err = v1.Run(append([]byte{CmdGetDomainNameOwner}, "abc"...), context)
+	assert.Equal(t, err, nil)
+	assert.Equal(t, result, owner.Bytes())
+}
+
+func Test_DomainReverseLookup(t *testing.T) {
+	db, dispose := leveldb.NewTestDatabase()
+	defer dispose()
+
+	context := newTestContext(db, DomainNameContractAddress)
+	owner := context.tx.Data.From
+	_, err := createDomainName([]byte("abc"), context)
+	assert.Equal(t, err, nil)
+
+	_, err = getDomainName(owner.Bytes(), context)
+	assert.Equal(t, err, errNotFound)
+
+	_, err = setReverseName([]byte("abc"), context)
+	assert.Equal(t, err, nil)
+
+	result, err := getDomainName(owner.Bytes(), context)
+	assert.Equal(t, err, nil)
+	assert.Equal(t, result, []byte("abc"))
+
+	// the name is not resolved to the sender
+	context.tx.Data.From = *crypto.MustGenerateShardAddress(1)
+	_, err = setReverseName([]byte("abc"), context)
+	assert.Equal(t, err, errDomainReverseMismatch)
+
+	// the reverse record is ignored once the name is resolved to another address
+	context.tx.Data.From = owner
+	other := *crypto.MustGenerateShardAddress(1)
+	_, err = setDomainAddress(mustJSON(&DomainAddressRequest{Name: "abc", Address: other}), context)
+	assert.Equal(t, err, nil)
+
+	_, err = getDomainName(owner.Bytes(), context)
+	assert.Equal(t, err, errNotFound)
+
+	// remove the reverse record
+	_, err = setReverseName(nil, context)
+	assert.Equal(t, err, nil)
+	assert.Equal(t, len(context.statedb.GetData(DomainNameContractAddress, domainReverseKey(owner))), 0)
+}
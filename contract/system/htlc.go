@@ -8,23 +8,56 @@ package system
 import (
 	"bytes"
 	"crypto/sha256"
+	"encoding/binary"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"math/big"
+	"strings"
 
+	"github.com/elcn233/go-scdo/accounts/abi"
 	"github.com/elcn233/go-scdo/common"
 	"github.com/elcn233/go-scdo/common/hexutil"
+	"github.com/elcn233/go-scdo/core/state"
 	"github.com/elcn233/go-scdo/core/types"
+	"github.com/elcn233/go-scdo/crypto"
 )
 
 const (
-	gasNewContract = uint64(100000)
-	gasWithdraw    = uint64(5000)
-	gasRefund      = uint64(5000)
-	gasGetContract = uint64(5000)
+	gasNewContract   = uint64(100000)
+	gasWithdraw      = uint64(5000)
+	gasRefund        = uint64(5000)
+	gasGetContract   = uint64(5000)
+	gasListContracts = uint64(10000)
 )
 
+const (
+	// maxBatchContracts is the max number of HTLCs created in a batch
+	maxBatchContracts = 16
+	// maxListContracts is the max number of HTLC ids returned in a list
+	maxListContracts = 100
+)
+
+// HTLCEventsABI is the abi of the logs of the HTLC contract, which could be
+// used to filter the logs of the contract address, e.g. by scdo_getLogs.
+const HTLCEventsABI = `[
+	{"type":"event","name":"HTLCCreated","inputs":[
+		{"name":"id","type":"bytes32","indexed":true},
+		{"name":"sender","type":"address","indexed":true},
+		{"name":"receiver","type":"address","indexed":true},
+		{"name":"amount","type":"uint256","indexed":false},
+		{"name":"hashLock","type":"bytes","indexed":false},
+		{"name":"timeLock","type":"uint256","indexed":false},
+		{"name":"lockHeight","type":"uint256","indexed":false}]},
+	{"type":"event","name":"HTLCWithdrawn","inputs":[
+		{"name":"id","type":"bytes32","indexed":true},
+		{"name":"receiver","type":"address","indexed":true},
+		{"name":"preimage","type":"bytes","indexed":false}]},
+	{"type":"event","name":"HTLCRefunded","inputs":[
+		{"name":"id","type":"bytes32","indexed":true},
+		{"name":"sender","type":"address","indexed":true}]}
+]`
+
 const (
 	// CmdNewContract create HTLC
 	CmdNewContract byte = iota
@@ -34,15 +67,40 @@ const (
 	CmdRefund
 	// CmdGetContract get HTLC
 	CmdGetContract
+	// CmdNewContracts create HTLCs in a batch
+	CmdNewContracts
+	// CmdGetContractsBySender get the ids of HTLCs created by the sender
+	CmdGetContractsBySender
+	// CmdGetContractsByReceiver get the ids of HTLCs locked to the receiver
+	CmdGetContractsByReceiver
 )
 
 var (
 	htlcCommands = map[byte]*cmdInfo{
-		CmdNewContract: &cmdInfo{gasNewContract, newHTLC},
-		CmdWithdraw:    &cmdInfo{gasWithdraw, withdraw},
-		CmdRefund:      &cmdInfo{gasRefund, refund},
-		CmdGetContract: &cmdInfo{gasGetContract, getContract},
+		CmdNewContract:            &cmdInfo{gasNewContract, newHTLC},
+		CmdWithdraw:               &cmdInfo{gasWithdraw, withdraw},
+		CmdRefund:                 &cmdInfo{gasRefund, refund},
+		CmdGetContract:            &cmdInfo{gasGetContract, getContract},
+		CmdNewContracts:           &cmdInfo{gasNewContract * maxBatchContracts, newHTLCs},
+		CmdGetContractsBySender:   &cmdInfo{gasListContracts, getContractsBySender},
+		CmdGetContractsByReceiver: &cmdInfo{gasListContracts, getContractsByReceiver},
 	}
+
+	// htlcSchema is the command schema of htlcCommands for clients to generate calls
+	htlcSchema = []CommandSchema{
+		{ID: CmdNewContract, Name: "create", Input: TypeJSON, Output: TypeJSON},
+		{ID: CmdWithdraw, Name: "withdraw", Input: TypeJSON, Output: TypeJSON},
+		{ID: CmdRefund, Name: "refund", Input: TypeHash, Output: TypeJSON},
+		{ID: CmdGetContract, Name: "get", Input: TypeHash, Output: TypeJSON, ReadOnly: true},
+		{ID: CmdNewContracts, Name: "batch", Input: TypeJSON, Output: TypeJSON},
+		{ID: CmdGetContractsBySender, Name: "listBySender", Input: TypeJSON, Output: TypeJSON, ReadOnly: true},
+		{ID: CmdGetContractsByReceiver, Name: "listByReceiver", Input: TypeJSON, Output: TypeJSON, ReadOnly: true},
+	}
+
+	htlcEvents = mustParseABI(HTLCEventsABI)
+
+	htlcSenderIndex   = []byte("HTLC-Sender")
+	htlcReceiverIndex = []byte("HTLC-Receiver")
 )
 
 var (
@@ -56,10 +114,16 @@ var (
 	errReceiver                = errors.New("Failed to withdraw, only receiver is allowed")
 	errNotFound                = errors.New("Failed to get data with key")
 	errHashMismatch            = errors.New("Failed to use preimage to match hash")
+	errNotFutureHeight         = errors.New("Failed to lock, height is not in future")
+	errBatchSize               = fmt.Errorf("Failed to create HTLCs, the number must be between 1 and %d", maxBatchContracts)
+	errBatchAmount             = errors.New("Failed to create HTLCs, the sum of amounts mismatch the tx amount")
+	errHTLCExists              = errors.New("Failed to create HTLC, the id already exists")
 )
 
 type htlc struct {
 	Tx *types.Transaction
+	// ID is the key of data, it is the tx hash except the HTLCs created in a batch or in EVM
+	ID common.Hash
 	HashTimeLock
 	// Refunded if refunded ture, otherwise false
 	Refunded bool
@@ -75,8 +139,19 @@ type HashTimeLock struct {
 	HashLock common.Bytes
 	// TimeLock is used to lock amount a period
 	TimeLock int64
+	// LockHeight is used to lock amount until the block height instead of the TimeLock if not zero
+	LockHeight uint64
 	// receive address
 	To common.Address
+	// Amount is the locked amount, the tx amount is locked if nil
+	Amount *big.Int `json:",omitempty"`
+}
+
+// HTLCListRequest is the request to list the HTLC ids of an address
+type HTLCListRequest struct {
+	Address common.Address
+	Offset  uint64
+	Count   uint64
 }
 
 // Withdrawing used to withdraw from contract
@@ -98,15 +173,74 @@ func newHTLC(lockbytes []byte, context *Context) ([]byte, error) {
 		return nil, err
 	}
 
-	if !isFutureTimeLock(info.TimeLock, context.BlockHeader.CreateTimestamp.Int64()) {
-		return nil, errNotFutureTime
+	info.Amount = context.tx.Data.Amount
+	return createHTLC(&info, context.tx.Hash, context)
+}
+
+// create HTLCs in a batch, the sum of the amounts must equal the tx amount. The id
+// of each HTLC is the hash of the tx hash and its index in the batch.
+func newHTLCs(lockbytes []byte, context *Context) ([]byte, error) {
+	var infos []HashTimeLock
+	if err := json.Unmarshal(lockbytes, &infos); err != nil {
+		return nil, fmt.Errorf("Failed to unmarshal lockbytes, %s", err)
+	}
+
+	if len(infos) == 0 || len(infos) > maxBatchContracts {
+		return nil, errBatchSize
+	}
+
+	if err := validateAmount(context.tx); err != nil {
+		return nil, err
+	}
+
+	// validate all the HTLCs before any is created
+	sum := big.NewInt(0)
+	for i := range infos {
+		if infos[i].Amount == nil || infos[i].Amount.Sign() <= 0 {
+			return nil, errors.New("Failed to create HTLC, amount is less than or equal to 0")
+		}
+
+		if err := validateLock(&infos[i], context); err != nil {
+			return nil, err
+		}
+
+		sum.Add(sum, infos[i].Amount)
+	}
+
+	if sum.Cmp(context.tx.Data.Amount) != 0 {
+		return nil, errBatchAmount
+	}
+
+	values := make([]json.RawMessage, 0, len(infos))
+	for i := range infos {
+		var index [4]byte
+		binary.BigEndian.PutUint32(index[:], uint32(i))
+		value, err := createHTLC(&infos[i], crypto.HashBytes(context.tx.Hash.Bytes(), index[:]), context)
+		if err != nil {
+			return nil, err
+		}
+
+		values = append(values, value)
+	}
+
+	return json.Marshal(values)
+}
+
+// createHTLC stores the HTLC with the id, indexes it by the sender and the receiver
+// and emits the created log. The id should not be used by any HTLC before.
+func createHTLC(info *HashTimeLock, id common.Hash, context *Context) ([]byte, error) {
+	if err := validateLock(info, context); err != nil {
+		return nil, err
+	}
+
+	if len(context.statedb.GetData(HashTimeLockContractAddress, id)) > 0 {
+		return nil, errHTLCExists
 	}
 
 	var data htlc
 	data.Tx = context.tx
-	data.HashLock = info.HashLock
-	data.TimeLock = info.TimeLock
-	data.To = info.To
+	data.ID = id
+	data.HashTimeLock = *info
 	data.Preimage = common.Bytes{}
 	value, err := json.Marshal(data)
 	if err != nil {
@@ -114,7 +248,15 @@ func newHTLC(lockbytes []byte, context *Context) ([]byte, error) {
 	}
 
 	context.statedb.CreateAccount(HashTimeLockContractAddress)
-	context.statedb.SetData(HashTimeLockContractAddress, data.Tx.Hash, value)
+	context.statedb.SetData(HashTimeLockContractAddress, id, value)
+
+	addHTLCIndex(context.statedb, htlcSenderIndex, data.Tx.Data.From, id)
+	addHTLCIndex(context.statedb, htlcReceiverIndex, data.To, id)
+
+	if err = addHTLCLog(context, "HTLCCreated", []common.Hash{id, addressTopic(data.Tx.Data.From), addressTopic(data.To)},
+		data.amount(), []byte(data.HashLock), big.NewInt(data.TimeLock), new(big.Int).SetUint64(data.LockHeight)); err != nil {
+		return nil, err
+	}
 
 	return value, nil
 }
@@ -151,11 +293,15 @@ func withdraw(jsonWithdraw []byte, context *Context) ([]byte, error) {
 		return nil, fmt.Errorf("Failed to marshal data into json, %s", err)
 	}
 	// update value with key
-	context.statedb.SetData(HashTimeLockContractAddress, info.Tx.Hash, value)
+	context.statedb.SetData(HashTimeLockContractAddress, info.id(), value)
 	// subtract the amount from the HTLC address
-	context.statedb.SubBalance(context.tx.Data.To, info.Tx.Data.Amount)
+	context.statedb.SubBalance(context.tx.Data.To, info.amount())
 	// add the amount to the sender account
-	context.statedb.AddBalance(info.To, info.Tx.Data.Amount)
+	context.statedb.AddBalance(info.To, info.amount())
+
+	if err = addHTLCLog(context, "HTLCWithdrawn", []common.Hash{info.id(), addressTopic(info.To)}, []byte(info.Preimage)); err != nil {
+		return nil, err
+	}
 
 	return value, nil
 }
@@ -183,11 +329,16 @@ func refund(bytes []byte, context *Context) ([]byte, error) {
 	}
 
 	// update the value with key
-	context.statedb.SetData(HashTimeLockContractAddress, info.Tx.Hash, value)
+	context.statedb.SetData(HashTimeLockContractAddress, info.id(), value)
 	// subtract the amount from the HTLC address
-	context.statedb.SubBalance(context.tx.Data.To, info.Tx.Data.Amount)
+	context.statedb.SubBalance(context.tx.Data.To, info.amount())
 	// add the amount to sender account
-	context.statedb.AddBalance(info.Tx.Data.From, info.Tx.Data.Amount)
+	context.statedb.AddBalance(info.Tx.Data.From, info.amount())
+
+	if err = addHTLCLog(context, "HTLCRefunded", []common.Hash{info.id(), addressTopic(info.Tx.Data.From)}); err != nil {
+		return nil, err
+	}
+
 	return value, nil
 }
 
@@ -197,6 +348,124 @@ func getContract(bytes []byte, context *Context) ([]byte, error) {
 	return haveContract(context, hash)
 }
 
+// getContractsBySender return the ids of the HTLCs created by the address in json
+func getContractsBySender(request []byte, context *Context) ([]byte, error) {
+	return listHTLCIndex(request, htlcSenderIndex, context)
+}
+
+// getContractsByReceiver return the ids of the HTLCs locked to the address in json
+func getContractsByReceiver(request []byte, context *Context) ([]byte, error) {
+	return listHTLCIndex(request, htlcReceiverIndex, context)
+}
+
+func listHTLCIndex(request []byte, prefix []byte, context *Context) ([]byte, error) {
+	var req HTLCListRequest
+	if err := json.Unmarshal(request, &req); err != nil {
+		return nil, err
+	}
+
+	if req.Count == 0 || req.Count > maxListContracts {
+		req.Count = maxListContracts
+	}
+
+	total := getHTLCIndexCount(context.statedb, prefix, req.Address)
+	ids := make([]common.Hash, 0)
+	for i := req.Offset; i < total && uint64(len(ids)) < req.Count; i++ {
+		id := context.statedb.GetData(HashTimeLockContractAddress, htlcIndexKey(prefix, req.Address, i))
+		ids = append(ids, common.BytesToHash(id))
+	}
+
+	return json.Marshal(ids)
+}
+
+func htlcIndexKey(prefix []byte, addr common.Address, index uint64) common.Hash {
+	var buf [8]byte
+	binary.BigEndian.PutUint64(buf[:], index)
+	return crypto.HashBytes(prefix, addr.Bytes(), buf[:])
+}
+
+func htlcCountKey(prefix []byte, addr common.Address) common.Hash {
+	return crypto.HashBytes(prefix, addr.Bytes())
+}
+
+func getHTLCIndexCount(statedb *state.Statedb, prefix []byte, addr common.Address) uint64 {
+	count := statedb.GetData(HashTimeLockContractAddress, htlcCountKey(prefix, addr))
+	if len(count) != 8 {
+		return 0
+	}
+
+	return binary.BigEndian.Uint64(count)
+}
+
+// addHTLCIndex appends the HTLC id to the list of the address
+func addHTLCIndex(statedb *state.Statedb, prefix []byte, addr common.Address, id common.Hash) {
+	count := getHTLCIndexCount(statedb, prefix, addr)
+	statedb.SetData(HashTimeLockContractAddress, htlcIndexKey(prefix, addr, count), id.Bytes())
+
+	var buf [8]byte
+	binary.BigEndian.PutUint64(buf[:], count+1)
+	statedb.SetData(HashTimeLockContractAddress, htlcCountKey(prefix, addr), buf[:])
+}
+
+// addHTLCLog adds the log of the event, the topics are the event id followed by
+// the indexed arguments and the data is the abi encoded non-indexed arguments.
+func addHTLCLog(context *Context, name string, indexed []common.Hash, args ...interface{}) error {
+	event := htlcEvents.Events[name]
+	data, err := event.Inputs.NonIndexed().Pack(args...)
+	if err != nil {
+		return fmt.Errorf("Failed to pack the log of %s, %s", name, err)
+	}
+
+	context.statedb.AddLog(&types.Log{
+		Address:     HashTimeLockContractAddress,
+		Topics:      append([]common.Hash{event.Id()}, indexed...),
+		Data:        data,
+		BlockNumber: context.BlockHeader.Height,
+	})
+
+	return nil
+}
+
+func addressTopic(addr common.Address) common.Hash {
+	return common.BytesToHash(addr.Bytes())
+}
+
+func mustParseABI(abiJSON string) abi.ABI {
+	parsed, err := abi.JSON(strings.NewReader(abiJSON))
+	if err != nil {
+		panic(err)
+	}
+
+	return parsed
+}
+
+// id returns the key of the HTLC, the tx hash for the HTLCs created before the id
+func (data *htlc) id() common.Hash {
+	if data.ID.IsEmpty() {
+		return data.Tx.Hash
+	}
+
+	return data.ID
+}
+
+// amount returns the locked amount, the tx amount for the HTLCs created before the amount
+func (data *htlc) amount() *big.Int {
+	if data.Amount == nil {
+		return data.Tx.Data.Amount
+	}
+
+	return data.Amount
+}
+
+// isLocked returns true if the lock height or the time lock is not reached
+func (data *htlc) isLocked(context *Context) bool {
+	if data.LockHeight > 0 {
+		return context.BlockHeader.Height < data.LockHeight
+	}
+
+	return isFutureTimeLock(data.TimeLock, context.BlockHeader.CreateTimestamp.Int64())
+}
+
 // get the data
 func haveContract(context *Context, hash common.Hash) ([]byte, error) {
 	bytes := context.statedb.GetData(HashTimeLockContractAddress, hash)
@@ -216,6 +485,23 @@ func validateAmount(tx *types.Transaction) error {
 	return errors.New("Failed to create HTLC, amount is less than or equal to 0")
 }
 
+// check the lock height or the time lock is in the future
+func validateLock(info *HashTimeLock, context *Context) error {
+	if info.LockHeight > 0 {
+		if info.LockHeight <= context.BlockHeader.Height {
+			return errNotFutureHeight
+		}
+
+		return nil
+	}
+
+	if !isFutureTimeLock(info.TimeLock, context.BlockHeader.CreateTimestamp.Int64()) {
+		return errNotFutureTime
+	}
+
+	return nil
+}
+
 // check timelock is futhure for now
 func isFutureTimeLock(timelock, now int64) bool {
 	if now < timelock {
@@ -237,7 +523,7 @@ func withdrawable(data *htlc, context *Context) error {
 		return errReceiver
 	}
 
-	if !isFutureTimeLock(data.TimeLock, context.BlockHeader.CreateTimestamp.Int64()) {
+	if !data.isLocked(context) {
 		return errTimeExpired
 	}
 
@@ -254,7 +540,7 @@ func refundable(data *htlc, context *Context) error {
 		return errSender
 	}
 
-	if isFutureTimeLock(data.TimeLock, context.BlockHeader.CreateTimestamp.Int64()) {
+	if data.isLocked(context) {
 		return errTimeLocked
 	}
 
@@ -113,6 +113,65 @@ func Test_newHTLC(t *testing.T) {
 	amount := context.statedb.GetBalance(testGenesisAccounts[0].addr)
 	result := amount.Cmp(big.NewInt(50000))
 	assert.Equal(t, result, 0)
+
+	// the HTLC of the same id is not overwritten
+	_, err = newHTLC(databytes, context)
+	assert.Equal(t, err, errHTLCExists)
+}
+
+func Test_HTLCV0(t *testing.T) {
+	db, dispose := leveldb.NewTestDatabase()
+	defer dispose()
+
+	context := newContext(db, 0, 1)
+	context.statedb.CreateAccount(HashTimeLockContractAddress)
+	context.statedb.AddBalance(HashTimeLockContractAddress, context.tx.Data.Amount)
+	receiver := testGenesisAccounts[1].addr
+	context.statedb.CreateAccount(receiver)
+
+	v0 := GetContractByHeight(HashTimeLockContractAddress, common.HTLCForkHeight-1)
+	assert.Equal(t, v0.RequiredGas([]byte{CmdNewContracts}), gasInvalidCommand)
+
+	hashLock, err := hexutil.HexToBytes(secretehash)
+	assert.Equal(t, err, nil)
+	lock := mustJSON(&HashTimeLock{HashLock: hashLock, TimeLock: time.Now().Unix() + 48*3600, To: receiver})
+	value, err := v0.Run(append([]byte{CmdNewContract}, lock...), context)
+	assert.Equal(t, err, nil)
+
+	// stored in the original format without index and log
+	var stored map[string]interface{}
+	assert.Equal(t, json.Unmarshal(value, &stored), nil)
+	_, found := stored["ID"]
+	assert.Equal(t, found, false)
+	_, found = stored["LockHeight"]
+	assert.Equal(t, found, false)
+	assert.Equal(t, getHTLCIndexCount(context.statedb, htlcSenderIndex, context.tx.Data.From), uint64(0))
+	assert.Equal(t, len(context.statedb.GetCurrentLogs()), 0)
+
+	// overwritten by the same tx before the fork height
+	_, err = v0.Run(append([]byte{CmdNewContract}, lock...), context)
+	assert.Equal(t, err, nil)
+
+	// withdrawn by the receiver
+	creation := context.tx
+	context.tx = newTestTx(1, 0, 0, 1, 0)
+	context.tx.Data.To = HashTimeLockContractAddress
+	preimage, err := hexutil.HexToBytes(secret)
+	assert.Equal(t, err, nil)
+	value, err = v0.Run(append([]byte{CmdWithdraw}, mustJSON(&Withdrawing{creation.Hash, preimage})...), context)
+	assert.Equal(t, err, nil)
+	assert.Equal(t, context.statedb.GetBalance(receiver), creation.Data.Amount)
+
+	var info htlcV0
+	assert.Equal(t, json.Unmarshal(value, &info), nil)
+	assert.Equal(t, info.Withdrawed, true)
+	assert.Equal(t, info.To, receiver)
+
+	// the HTLC created before the fork height is refunded by the new commands
+	v1 := GetContractByHeight(HashTimeLockContractAddress, common.HTLCForkHeight)
+	context.tx = creation
+	_, err = v1.Run(append([]byte{CmdRefund}, creation.Hash.Bytes()...), context)
+	assert.Equal(t, err, errTimeLocked)
 }
 
 func Test_Withdraw(t *testing.T) {
@@ -339,3 +398,192 @@ func Test_GetContract(t *testing.T) {
 	_, err = getContract(common.EmptyHash.Bytes(), context)
 	assert.Equal(t, err, errNotFound)
 }
+
+func newTestHashTimeLock(to common.Address, amount int64) HashTimeLock {
+	hash, err := hexutil.HexToBytes(secretehash)
+	if err != nil {
+		panic(err)
+	}
+
+	return HashTimeLock{
+		HashLock: hash,
+		TimeLock: time.Now().Unix() + 48*3600,
+		To:       to,
+		Amount:   big.NewInt(amount),
+	}
+}
+
+func Test_newHTLCs(t *testing.T) {
+	db, dispose := leveldb.NewTestDatabase()
+	defer dispose()
+
+	context := newContext(db, 0, 1)
+	receiver1, receiver2 := testGenesisAccounts[1].addr, testGenesisAccounts[2].addr
+	infos := []HashTimeLock{newTestHashTimeLock(receiver1, 30), newTestHashTimeLock(receiver2, 70)}
+
+	// case 1: amount mismatch
+	infos[1].Amount = big.NewInt(60)
+	databytes, err := json.Marshal(infos)
+	assert.Equal(t, err, nil)
+
+	_, err = newHTLCs(databytes, context)
+	assert.Equal(t, err, errBatchAmount)
+
+	// case 2: empty batch
+	_, err = newHTLCs([]byte("[]"), context)
+	assert.Equal(t, err, errBatchSize)
+
+	// case 3: created with the ids of the index in the batch
+	infos[1].Amount = big.NewInt(70)
+	databytes, err = json.Marshal(infos)
+	assert.Equal(t, err, nil)
+
+	_, err = newHTLCs(databytes, context)
+	assert.Equal(t, err, nil)
+
+	id := crypto.HashBytes(context.tx.Hash.Bytes(), []byte{0, 0, 0, 1})
+	value, err := getContract(id.Bytes(), context)
+	assert.Equal(t, err, nil)
+
+	var info htlc
+	assert.Equal(t, json.Unmarshal(value, &info), nil)
+	assert.Equal(t, info.ID, id)
+	assert.Equal(t, info.To, receiver2)
+	assert.Equal(t, info.Amount, big.NewInt(70))
+
+	// case 4: withdraw the amount of the HTLC only
+	context.statedb.CreateAccount(receiver2)
+	context.tx = newTestTx(2, 0, 0, 1, 0)
+	preimage, err := hexutil.HexToBytes(secret)
+	assert.Equal(t, err, nil)
+
+	databytes, err = json.Marshal(&Withdrawing{id, preimage})
+	assert.Equal(t, err, nil)
+
+	_, err = withdraw(databytes, context)
+	assert.Equal(t, err, nil)
+	assert.Equal(t, context.statedb.GetBalance(receiver2), big.NewInt(70))
+}
+
+func Test_HTLCIndex(t *testing.T) {
+	db, dispose := leveldb.NewTestDatabase()
+	defer dispose()
+
+	context := newContext(db, 0, 1)
+	sender, receiver := testGenesisAccounts[0].addr, testGenesisAccounts[1].addr
+	info := newTestHashTimeLock(receiver, 0)
+	databytes, err := json.Marshal(info)
+	assert.Equal(t, err, nil)
+
+	_, err = newHTLC(databytes, context)
+	assert.Equal(t, err, nil)
+	first := context.tx.Hash
+
+	context.tx = newTestTx(0, 1, 100, 200, 2)
+	_, err = newHTLC(databytes, context)
+	assert.Equal(t, err, nil)
+	second := context.tx.Hash
+
+	list := func(cmd handler, req *HTLCListRequest) []common.Hash {
+		request, err := json.Marshal(req)
+		assert.Equal(t, err, nil)
+
+		result, err := cmd(request, context)
+		assert.Equal(t, err, nil)
+
+		var ids []common.Hash
+		assert.Equal(t, json.Unmarshal(result, &ids), nil)
+		return ids
+	}
+
+	assert.Equal(t, list(getContractsBySender, &HTLCListRequest{Address: sender}), []common.Hash{first, second})
+	assert.Equal(t, list(getContractsByReceiver, &HTLCListRequest{Address: receiver}), []common.Hash{first, second})
+	assert.Equal(t, list(getContractsByReceiver, &HTLCListRequest{Address: receiver, Offset: 1, Count: 1}), []common.Hash{second})
+	assert.Equal(t, list(getContractsByReceiver, &HTLCListRequest{Address: sender}), []common.Hash{})
+}
+
+func Test_HTLCLogs(t *testing.T) {
+	db, dispose := leveldb.NewTestDatabase()
+	defer dispose()
+
+	context := newContext(db, 0, 1)
+	context.statedb.CreateAccount(testGenesisAccounts[1].addr)
+	sender, receiver := testGenesisAccounts[0].addr, testGenesisAccounts[1].addr
+	info := newTestHashTimeLock(receiver, 0)
+	databytes, err := json.Marshal(info)
+	assert.Equal(t, err, nil)
+
+	_, err = newHTLC(databytes, context)
+	assert.Equal(t, err, nil)
+	id := context.tx.Hash
+
+	logs := context.statedb.GetCurrentLogs()
+	assert.Equal(t, len(logs), 1)
+
+	created := htlcEvents.Events["HTLCCreated"]
+	assert.Equal(t, logs[0].Address, HashTimeLockContractAddress)
+	assert.Equal(t, logs[0].Topics, []common.Hash{created.Id(), id, addressTopic(sender), addressTopic(receiver)})
+
+	args, err := created.Inputs.UnpackValues(logs[0].Data)
+	assert.Equal(t, err, nil)
+	assert.Equal(t, args[0], big.NewInt(100))
+	assert.Equal(t, args[1], []byte(info.HashLock))
+	assert.Equal(t, args[2], big.NewInt(info.TimeLock))
+
+	// withdrawn log with the preimage
+	context.tx = newTestTx(1, 0, 0, 1, 0)
+	preimage, err := hexutil.HexToBytes(secret)
+	assert.Equal(t, err, nil)
+
+	databytes, err = json.Marshal(&Withdrawing{id, preimage})
+	assert.Equal(t, err, nil)
+
+	_, err = withdraw(databytes, context)
+	assert.Equal(t, err, nil)
+
+	logs = context.statedb.GetCurrentLogs()
+	assert.Equal(t, len(logs), 2)
+
+	withdrawn := htlcEvents.Events["HTLCWithdrawn"]
+	assert.Equal(t, logs[1].Topics, []common.Hash{withdrawn.Id(), id, addressTopic(receiver)})
+
+	args, err = withdrawn.Inputs.UnpackValues(logs[1].Data)
+	assert.Equal(t, err, nil)
+	assert.Equal(t, args[0], preimage)
+}
+
+func Test_HTLCLockHeight(t *testing.T) {
+	db, dispose := leveldb.NewTestDatabase()
+	defer dispose()
+
+	context := newContext(db, 0, 1)
+	info := newTestHashTimeLock(testGenesisAccounts[1].addr, 0)
+	info.TimeLock = 0
+
+	// case 1: height is not in future
+	info.LockHeight = context.BlockHeader.Height
+	databytes, err := json.Marshal(info)
+	assert.Equal(t, err, nil)
+
+	_, err = newHTLC(databytes, context)
+	assert.Equal(t, err, errNotFutureHeight)
+
+	// case 2: refund after the lock height
+	info.LockHeight = context.BlockHeader.Height + 10
+	databytes, err = json.Marshal(info)
+	assert.Equal(t, err, nil)
+
+	_, err = newHTLC(databytes, context)
+	assert.Equal(t, err, nil)
+
+	key := context.tx.Hash
+	_, err = refund(key.Bytes(), context)
+	assert.Equal(t, err, errTimeLocked)
+
+	context.BlockHeader.Height += 10
+	_, err = refund(key.Bytes(), context)
+	assert.Equal(t, err, nil)
+
+	logs := context.statedb.GetCurrentLogs()
+	assert.Equal(t, logs[len(logs)-1].Topics[0], htlcEvents.Events["HTLCRefunded"].Id())
+}
@@ -0,0 +1,152 @@
+/**
+*  @file
+*  @copyright defined in scdo/LICENSE
+ */
+
+package system
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/elcn233/go-scdo/common"
+	"github.com/elcn233/go-scdo/core/types"
+)
+
+var (
+	// htlcCommandsV0 are the original commands before HTLCForkHeight, which store the
+	// HTLC by the tx hash without the indexes and logs
+	htlcCommandsV0 = map[byte]*cmdInfo{
+		CmdNewContract: &cmdInfo{gasNewContract, newHTLCV0},
+		CmdWithdraw:    &cmdInfo{gasWithdraw, withdrawV0},
+		CmdRefund:      &cmdInfo{gasRefund, refundV0},
+		CmdGetContract: &cmdInfo{gasGetContract, getContract},
+	}
+
+	htlcSchemaV0 = []CommandSchema{
+		{ID: CmdNewContract, Name: "create", Input: TypeJSON, Output: TypeJSON},
+		{ID: CmdWithdraw, Name: "withdraw", Input: TypeJSON, Output: TypeJSON},
+		{ID: CmdRefund, Name: "refund", Input: TypeHash, Output: TypeJSON},
+		{ID: CmdGetContract, Name: "get", Input: TypeHash, Output: TypeJSON, ReadOnly: true},
+	}
+)
+
+// htlcV0 is the HTLC stored before HTLCForkHeight
+type htlcV0 struct {
+	Tx *types.Transaction
+	hashTimeLockV0
+	Refunded   bool
+	Withdrawed bool
+	Preimage   common.Bytes
+}
+
+type hashTimeLockV0 struct {
+	HashLock common.Bytes
+	TimeLock int64
+	To       common.Address
+}
+
+// htlc returns the HTLC to check the withdrawal and refund, which has no id, amount and lock height
+func (data *htlcV0) htlc() *htlc {
+	return &htlc{
+		Tx:           data.Tx,
+		HashTimeLock: HashTimeLock{HashLock: data.HashLock, TimeLock: data.TimeLock, To: data.To},
+		Refunded:     data.Refunded,
+		Withdrawed:   data.Withdrawed,
+	}
+}
+
+func newHTLCV0(lockbytes []byte, context *Context) ([]byte, error) {
+	var info hashTimeLockV0
+	if err := json.Unmarshal(lockbytes, &info); err != nil {
+		return nil, fmt.Errorf("Failed to unmarshal lockbytes, %s", err)
+	}
+
+	if err := validateAmount(context.tx); err != nil {
+		return nil, err
+	}
+
+	if !isFutureTimeLock(info.TimeLock, context.BlockHeader.CreateTimestamp.Int64()) {
+		return nil, errNotFutureTime
+	}
+
+	var data htlcV0
+	data.Tx = context.tx
+	data.hashTimeLockV0 = info
+	data.Preimage = common.Bytes{}
+	value, err := json.Marshal(data)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to marshal data, %s", err)
+	}
+
+	context.statedb.CreateAccount(HashTimeLockContractAddress)
+	context.statedb.SetData(HashTimeLockContractAddress, data.Tx.Hash, value)
+
+	return value, nil
+}
+
+func withdrawV0(jsonWithdraw []byte, context *Context) ([]byte, error) {
+	var input Withdrawing
+	if err := json.Unmarshal(jsonWithdraw, &input); err != nil {
+		return nil, err
+	}
+
+	databytes, err := haveContract(context, input.Hash)
+	if err != nil {
+		return nil, err
+	}
+
+	var info htlcV0
+	if err = json.Unmarshal(databytes, &info); err != nil {
+		return nil, fmt.Errorf("Failed to unmarshal data, %s", err)
+	}
+
+	if !hashLockMatches(info.HashLock, input.Preimage) {
+		return nil, errHashMismatch
+	}
+
+	if err = withdrawable(info.htlc(), context); err != nil {
+		return nil, err
+	}
+
+	info.Preimage = input.Preimage
+	info.Withdrawed = true
+	value, err := json.Marshal(info)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to marshal data into json, %s", err)
+	}
+
+	context.statedb.SetData(HashTimeLockContractAddress, info.Tx.Hash, value)
+	context.statedb.SubBalance(context.tx.Data.To, info.Tx.Data.Amount)
+	context.statedb.AddBalance(info.To, info.Tx.Data.Amount)
+
+	return value, nil
+}
+
+func refundV0(bytes []byte, context *Context) ([]byte, error) {
+	databytes, err := haveContract(context, common.BytesToHash(bytes))
+	if err != nil {
+		return nil, err
+	}
+
+	var info htlcV0
+	if err := json.Unmarshal(databytes, &info); err != nil {
+		return nil, err
+	}
+
+	if err = refundable(info.htlc(), context); err != nil {
+		return nil, err
+	}
+
+	info.Refunded = true
+	value, err := json.Marshal(info)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to marshal data into json, %s", err)
+	}
+
+	context.statedb.SetData(HashTimeLockContractAddress, info.Tx.Hash, value)
+	context.statedb.SubBalance(context.tx.Data.To, info.Tx.Data.Amount)
+	context.statedb.AddBalance(info.Tx.Data.From, info.Tx.Data.Amount)
+
+	return value, nil
+}
@@ -18,11 +18,24 @@ type Context struct {
 	tx          *types.Transaction
 	statedb     *state.Statedb
 	BlockHeader *types.BlockHeader
+
+	// precompileCalls is the number of the system contracts called in EVM by the tx
+	precompileCalls uint32
 }
 
 // NewContext creates a system contract context.
 func NewContext(tx *types.Transaction, statedb *state.Statedb, BlockHeader *types.BlockHeader) *Context {
-	return &Context{tx, statedb, BlockHeader}
+	return &Context{tx: tx, statedb: statedb, BlockHeader: BlockHeader}
+}
+
+// Tx returns the transaction that calls the system contract.
+func (c *Context) Tx() *types.Transaction {
+	return c.tx
+}
+
+// Statedb returns the statedb that the system contract runs on.
+func (c *Context) Statedb() *state.Statedb {
+	return c.statedb
 }
 
 // Contract is the basic interface for native Go contracts in Scdo.
@@ -50,17 +63,32 @@ var (
 	MasternodeContractAddress = common.BytesToAddress([]byte{1, 4})
 	// BTCRelayContractAddress btc-relay contract address
 	BTCRelayContractAddress = common.BytesToAddress([]byte{1, 5})
+	// ValidatorContractAddress istanbul validator governance contract address
+	ValidatorContractAddress = common.BytesToAddress([]byte{1, 6})
 
-	// Contracts are system contracts
-	contracts = map[common.Address]Contract{
-		DomainNameContractAddress:   &contract{domainNameCommands},
-		SubChainContractAddress:     &contract{subChainCommands},
-		HashTimeLockContractAddress: &contract{htlcCommands},
-		MasternodeContractAddress:   &contract{masternodeCommands},
-		BTCRelayContractAddress:     &contract{brCommands},
-	}
+	// Contracts are the latest versions of registered system contracts
+	contracts = make(map[common.Address]Contract)
 )
 
+func init() {
+	mustRegisterNativeContract("domain", DomainNameContractAddress,
+		commandSet{0, domainNameCommandsV0, domainNameSchemaV0},
+		commandSet{common.DomainNameForkHeight, domainNameCommands, domainNameSchema})
+	mustRegisterNativeContract("subchain", SubChainContractAddress,
+		commandSet{0, subChainCommandsV0, subChainSchemaV0},
+		commandSet{common.SubChainForkHeight, subChainCommands, subChainSchema})
+	mustRegisterNativeContract("htlc", HashTimeLockContractAddress,
+		commandSet{0, htlcCommandsV0, htlcSchemaV0},
+		commandSet{common.HTLCForkHeight, htlcCommands, htlcSchema})
+	mustRegisterNativeContract("masternode", MasternodeContractAddress,
+		commandSet{0, masternodeCommandsV0, masternodeSchemaV0},
+		commandSet{common.MasternodeForkHeight, masternodeCommands, masternodeSchema})
+	mustRegisterNativeContract("btcrelay", BTCRelayContractAddress,
+		commandSet{0, brCommandsV0, brSchemaV0},
+		commandSet{common.BTCRelayForkHeight, brCommands, brSchema})
+	mustRegisterNativeContract("validator", ValidatorContractAddress, commandSet{common.ValidatorContractForkHeight, validatorCommands, validatorSchema})
+}
+
 type handler func([]byte, *Context) ([]byte, error)
 
 type cmdInfo struct {
@@ -96,7 +124,11 @@ func (c *contract) Run(input []byte, context *Context) ([]byte, error) {
 	return nil, errInvalidCommand
 }
 
-// GetContractByAddress get system contract by the address
+// GetContractByAddress get the latest version of system contract by the address,
+// regardless of the activation and deprecation heights.
 func GetContractByAddress(address common.Address) Contract {
+	registryLock.RLock()
+	defer registryLock.RUnlock()
+
 	return contracts[address]
 }
@@ -6,10 +6,13 @@
 package system
 
 import (
+	"bytes"
+	"encoding/json"
 	"math/big"
 
 	"github.com/elcn233/go-scdo/common"
 	"github.com/elcn233/go-scdo/core/state"
+	"github.com/elcn233/go-scdo/core/types"
 	"github.com/elcn233/go-scdo/crypto"
 	"github.com/pkg/errors"
 )
@@ -23,11 +26,20 @@ const (
 	CmdRecall
 	// CmdQuit quitCmd masternode
 	CmdQuit
-
-	gasCmdDeposit         = uint64(50000) // gas used to deposit
-	gasCmdQueryMasterNode = uint64(5000)  // gas used to query masternode
-	gasCmdRecall          = uint64(50000) // gas used to recallCmd
-	gasCmdQuit            = uint64(50000) // gas used to quitCmd
+	// CmdSlash slash the deposit and stake of a validator which signed conflicting consensus messages
+	CmdSlash
+	// CmdGetMasternode get the status of a masternode
+	CmdGetMasternode
+	// CmdGetMasternodes get the status of all the masternodes
+	CmdGetMasternodes
+
+	gasCmdDeposit         = uint64(50000)  // gas used to deposit
+	gasCmdQueryMasterNode = uint64(5000)   // gas used to query masternode
+	gasCmdRecall          = uint64(50000)  // gas used to recallCmd
+	gasCmdQuit            = uint64(50000)  // gas used to quitCmd
+	gasCmdSlash           = uint64(100000) // gas used to slash
+	gasCmdGetMasternode   = uint64(5000)   // gas used to get a masternode
+	gasCmdGetMasternodes  = uint64(20000)  // gas used to get all the masternodes
 )
 
 var (
@@ -36,6 +48,7 @@ var (
 
 	depositLimit        = big.NewInt(0).Mul(common.ScdoToWen, big.NewInt(20000))
 	recallDistanceLimit = uint64(8640) // generate blocks in about one day
+	slashRewardPercent  = int64(10)    // percent of the slashed deposit rewarded to the reporter, the rest is burned
 )
 
 var (
@@ -44,40 +57,112 @@ var (
 	ErrNotExist          = errors.New("this address is not masternode")
 	ErrNotQuit           = errors.New("address doesn't quit")
 	ErrNotEnoughDistance = errors.New("not enough distance")
+	ErrAlreadySlashed    = errors.New("this masternode is already slashed")
+	ErrAlreadyQuit       = errors.New("this masternode already quits")
+	ErrNotRecalled       = errors.New("the deposit of this address is not recalled")
+	ErrNotOwner          = errors.New("only the masternode itself is allowed")
 
 	masternodeCommands = map[byte]*cmdInfo{
 		CmdDeposit:         &cmdInfo{gasCmdDeposit, deposit},
 		CmdQueryMasternode: &cmdInfo{gasCmdQueryMasterNode, queryMasternodeCmd},
 		CmdRecall:          {gasCmdRecall, recallCmd},
 		CmdQuit:            {gasCmdQuit, quitCmd},
+		CmdSlash:           {gasCmdSlash, slashCmd},
+		CmdGetMasternode:   {gasCmdGetMasternode, getMasternodeCmd},
+		CmdGetMasternodes:  {gasCmdGetMasternodes, getMasternodesCmd},
+	}
+
+	// masternodeSchema is the command schema of masternodeCommands for clients to generate calls
+	masternodeSchema = []CommandSchema{
+		{ID: CmdDeposit, Name: "deposit", Input: TypeNone, Output: TypeNone},
+		{ID: CmdQueryMasternode, Name: "query", Input: TypeAddress, Output: TypeBytes, ReadOnly: true},
+		{ID: CmdRecall, Name: "recall", Input: TypeAddress, Output: TypeNone},
+		{ID: CmdQuit, Name: "quit", Input: TypeAddress, Output: TypeNone},
+		{ID: CmdSlash, Name: "slash", Input: TypeJSON, Output: TypeNone},
+		{ID: CmdGetMasternode, Name: "status", Input: TypeAddress, Output: TypeJSON, ReadOnly: true},
+		{ID: CmdGetMasternodes, Name: "list", Input: TypeNone, Output: TypeJSON, ReadOnly: true},
 	}
+
+	keyMasternodes         = common.BytesToHash([]byte("Masternode-Addresses"))
+	keyActiveMasternodes   = common.BytesToHash([]byte("Masternode-Active-Count"))
+	keyMasternodeRewardAcc = common.BytesToHash([]byte("Masternode-Reward-Acc"))
 )
 
+// masternodeInfo is the masternode stored in the contract. The reward of an active
+// masternode is the accumulated reward per masternode minus the RewardDebt, it is
+// settled to Reward when quits. The masternodes deposited before MasternodeForkHeight
+// have no RewardDebt, they are neither listed nor rewarded until joined by depositing
+// zero amount, or listed when quit or slashed.
 type masternodeInfo struct {
-	IsQuit    bool
-	QuitBlock uint64
+	IsQuit       bool
+	QuitBlock    uint64
+	Slashed      bool     `rlp:"optional"`
+	DepositBlock uint64   `rlp:"optional"`
+	RewardDebt   *big.Int `rlp:"optional"`
+	Reward       *big.Int `rlp:"optional"`
 }
 
-func deposit(input []byte, context *Context) ([]byte, error) {
-	if context.tx.Data.Amount.Cmp(depositLimit) != 0 {
-		return nil, ErrDepositNotRight
-	}
+// Masternode is the status of a masternode
+type Masternode struct {
+	Address       common.Address `json:"address"`
+	Active        bool           `json:"active"`
+	Slashed       bool           `json:"slashed"`
+	DepositHeight uint64         `json:"depositHeight"`
+	QuitHeight    uint64         `json:"quitHeight"`
+	RecallHeight  uint64         `json:"recallHeight"` // the deposit and reward can be recalled after the height
+	Reward        *big.Int       `json:"reward"`       // the reward paid when recalled
+}
 
+func deposit(input []byte, context *Context) ([]byte, error) {
 	sender := context.tx.Data.From
 	info, err := QueryAddress(sender, context.statedb)
 	if err != nil {
 		return nil, err
 	}
 
-	if info != nil && !info.IsQuit {
-		return nil, ErrAlreadyExist
+	// the masternode deposited before the fork height joins without deposit again
+	if info != nil && info.RewardDebt == nil && context.tx.Data.Amount.Sign() == 0 {
+		if err = joinMasternode(context.statedb, sender, info); err != nil {
+			return nil, err
+		}
+
+		return nil, saveInfo(sender.Bytes(), context.statedb, info)
+	}
+
+	if context.tx.Data.Amount.Cmp(depositLimit) != 0 {
+		return nil, ErrDepositNotRight
+	}
+
+	if info != nil {
+		switch {
+		case info.Slashed:
+			return nil, ErrAlreadySlashed
+		case info.IsQuit:
+			return nil, ErrNotRecalled
+		default:
+			return nil, ErrAlreadyExist
+		}
 	}
 
 	info = &masternodeInfo{
-		IsQuit: false,
+		IsQuit:       false,
+		DepositBlock: context.BlockHeader.Height,
+		RewardDebt:   getMasternodeRewardAcc(context.statedb),
 	}
 
-	context.statedb.SetData(MasternodeContractAddress, crypto.MustHash(sender), common.SerializePanic(info))
+	addresses, err := getMasternodeAddresses(context.statedb)
+	if err != nil {
+		return nil, err
+	}
+
+	if err = saveMasternodeAddresses(context.statedb, append(addresses, sender)); err != nil {
+		return nil, err
+	}
+
+	setActiveMasternodes(context.statedb, getActiveMasternodes(context.statedb)+1)
+	if err = saveInfo(sender.Bytes(), context.statedb, info); err != nil {
+		return nil, err
+	}
 
 	return nil, nil
 }
@@ -88,7 +173,7 @@ func queryMasternodeCmd(address []byte, context *Context) ([]byte, error) {
 		return nil, err
 	}
 
-	if info == nil && !info.IsQuit {
+	if info != nil && !info.IsQuit {
 		return ByteTrue, nil
 	}
 
@@ -105,19 +190,39 @@ func recallCmd(address []byte, context *Context) ([]byte, error) {
 		return nil, err
 	}
 
-	if info == nil && !info.IsQuit {
+	if info == nil {
+		return nil, ErrNotExist
+	}
+
+	if info.Slashed {
+		return nil, ErrAlreadySlashed
+	}
+
+	if !info.IsQuit {
 		return nil, ErrNotQuit
 	}
 
-	distance := context.BlockHeader.Height - info.QuitBlock
-	if info.IsQuit && distance > recallDistanceLimit {
-		context.statedb.SetData(MasternodeContractAddress, crypto.MustHash(address), nil)
-		context.statedb.SubBalance(MasternodeContractAddress, depositLimit)
-		context.statedb.AddBalance(context.tx.Data.From, depositLimit)
-	} else {
+	if !bytes.Equal(address, context.tx.Data.From.Bytes()) {
+		return nil, ErrNotOwner
+	}
+
+	if context.BlockHeader.Height-info.QuitBlock <= recallDistanceLimit {
 		return nil, ErrNotEnoughDistance
 	}
 
+	if err = removeMasternodeAddress(context.statedb, common.BytesToAddress(address)); err != nil {
+		return nil, err
+	}
+
+	amount := new(big.Int).Set(depositLimit)
+	if info.Reward != nil {
+		amount.Add(amount, info.Reward)
+	}
+
+	context.statedb.SetData(MasternodeContractAddress, crypto.MustHash(address), nil)
+	context.statedb.SubBalance(MasternodeContractAddress, amount)
+	context.statedb.AddBalance(context.tx.Data.From, amount)
+
 	return nil, nil
 }
 
@@ -152,13 +257,292 @@ func quitCmd(address []byte, context *Context) ([]byte, error) {
 		return nil, err
 	}
 
-	if info != nil && !info.IsQuit {
-		info.IsQuit = true
-		info.QuitBlock = context.BlockHeader.Height
-		if err := saveInfo(address, context.statedb, info); err != nil {
+	if info == nil {
+		return nil, ErrNotExist
+	}
+
+	if info.IsQuit {
+		return nil, ErrAlreadyQuit
+	}
+
+	if !bytes.Equal(address, context.tx.Data.From.Bytes()) {
+		return nil, ErrNotOwner
+	}
+
+	if info.RewardDebt == nil {
+		if err = joinMasternode(context.statedb, context.tx.Data.From, info); err != nil {
 			return nil, err
 		}
 	}
 
+	quitMasternode(context.statedb, info, context.BlockHeader.Height)
+	if err := saveInfo(address, context.statedb, info); err != nil {
+		return nil, err
+	}
+
+	return nil, nil
+}
+
+// joinMasternode adds the masternode deposited before the fork height to the list,
+// and the active one starts to share the rewards since then
+func joinMasternode(statedb *state.Statedb, address common.Address, info *masternodeInfo) error {
+	addresses, err := getMasternodeAddresses(statedb)
+	if err != nil {
+		return err
+	}
+
+	if err = saveMasternodeAddresses(statedb, append(addresses, address)); err != nil {
+		return err
+	}
+
+	info.RewardDebt = getMasternodeRewardAcc(statedb)
+	if !info.IsQuit && !info.Slashed {
+		setActiveMasternodes(statedb, getActiveMasternodes(statedb)+1)
+	}
+
+	return nil
+}
+
+// quitMasternode stops the masternode from earning rewards and settles its reward
+func quitMasternode(statedb *state.Statedb, info *masternodeInfo, height uint64) {
+	info.IsQuit = true
+	info.QuitBlock = height
+
+	// the masternodes not joined are not counted
+	if info.RewardDebt == nil {
+		return
+	}
+
+	info.Reward = new(big.Int).Sub(getMasternodeRewardAcc(statedb), info.RewardDebt)
+	if count := getActiveMasternodes(statedb); count > 0 {
+		setActiveMasternodes(statedb, count-1)
+	}
+}
+
+// slashCmd burns the deposit of the masternode and the stake of the candidate accused by the
+// evidence of double signing, and rewards the reporter with a part of it. The masternode is
+// forced to quit.
+func slashCmd(input []byte, context *Context) ([]byte, error) {
+	var evidence types.IstanbulEvidence
+	if err := json.Unmarshal(input, &evidence); err != nil {
+		return nil, err
+	}
+
+	burned, err := slashValidator(context.statedb, &evidence, context.BlockHeader.Height)
+	if err != nil {
+		return nil, err
+	}
+
+	if burned.Sign() == 0 {
+		return nil, ErrNotExist
+	}
+
+	reward := new(big.Int).Mul(burned, big.NewInt(slashRewardPercent))
+	reward.Div(reward, big.NewInt(100))
+	context.statedb.AddBalance(context.tx.Data.From, reward)
+
 	return nil, nil
 }
+
+// slashMasternode burns the deposit and the reward of the masternode, and returns the
+// slashed deposit, which is 0 if the address is not a masternode or slashed already.
+func slashMasternode(statedb *state.Statedb, address common.Address, height uint64) (*big.Int, error) {
+	info, err := getInfo(address.Bytes(), statedb)
+	if err != nil {
+		return nil, err
+	}
+
+	if info == nil || info.Slashed {
+		return big.NewInt(0), nil
+	}
+
+	if info.RewardDebt == nil {
+		if err = joinMasternode(statedb, address, info); err != nil {
+			return nil, err
+		}
+	}
+
+	info.Slashed = true
+	if !info.IsQuit {
+		quitMasternode(statedb, info, height)
+	}
+
+	// the reward of the masternode is burned with the deposit
+	burned := new(big.Int).Set(depositLimit)
+	if info.Reward != nil {
+		burned.Add(burned, info.Reward)
+		info.Reward = big.NewInt(0)
+	}
+
+	if err = saveInfo(address.Bytes(), statedb, info); err != nil {
+		return nil, err
+	}
+
+	statedb.SubBalance(MasternodeContractAddress, burned)
+
+	return new(big.Int).Set(depositLimit), nil
+}
+
+// MasternodeDeposit returns the amount to deposit as a masternode.
+func MasternodeDeposit() *big.Int {
+	return new(big.Int).Set(depositLimit)
+}
+
+// IsSlashed returns whether the masternode of the address is slashed.
+func IsSlashed(address common.Address, statedb *state.Statedb) (bool, error) {
+	info, err := QueryAddress(address, statedb)
+	if err != nil || info == nil {
+		return false, err
+	}
+
+	return info.Slashed, nil
+}
+
+func getMasternodeCmd(address []byte, context *Context) ([]byte, error) {
+	masternode, err := GetMasternode(common.BytesToAddress(address), context.statedb)
+	if err != nil {
+		return nil, err
+	}
+
+	if masternode == nil {
+		return nil, ErrNotExist
+	}
+
+	return json.Marshal(masternode)
+}
+
+func getMasternodesCmd(input []byte, context *Context) ([]byte, error) {
+	masternodes, err := GetMasternodes(context.statedb)
+	if err != nil {
+		return nil, err
+	}
+
+	return json.Marshal(masternodes)
+}
+
+// GetMasternode returns the status of the masternode of the address, or nil if not exist.
+func GetMasternode(address common.Address, statedb *state.Statedb) (*Masternode, error) {
+	info, err := QueryAddress(address, statedb)
+	if err != nil || info == nil {
+		return nil, err
+	}
+
+	masternode := &Masternode{
+		Address:       address,
+		Active:        !info.IsQuit,
+		Slashed:       info.Slashed,
+		DepositHeight: info.DepositBlock,
+		Reward:        big.NewInt(0),
+	}
+
+	switch {
+	case info.IsQuit:
+		masternode.QuitHeight = info.QuitBlock
+		masternode.RecallHeight = info.QuitBlock + recallDistanceLimit + 1
+		if info.Reward != nil {
+			masternode.Reward.Set(info.Reward)
+		}
+	case info.RewardDebt != nil:
+		masternode.Reward.Sub(getMasternodeRewardAcc(statedb), info.RewardDebt)
+	}
+
+	return masternode, nil
+}
+
+// GetMasternodes returns the status of all the masternodes which are not recalled.
+func GetMasternodes(statedb *state.Statedb) ([]*Masternode, error) {
+	addresses, err := getMasternodeAddresses(statedb)
+	if err != nil {
+		return nil, err
+	}
+
+	masternodes := make([]*Masternode, 0, len(addresses))
+	for _, addr := range addresses {
+		masternode, err := GetMasternode(addr, statedb)
+		if err != nil {
+			return nil, err
+		}
+
+		if masternode != nil {
+			masternodes = append(masternodes, masternode)
+		}
+	}
+
+	return masternodes, nil
+}
+
+// DistributeMasternodeReward shares the amount equally among the active masternodes,
+// the amount is kept in the masternode contract until recalled. It returns the amount
+// not distributed, which is all of it if there is no active masternode.
+func DistributeMasternodeReward(statedb *state.Statedb, amount *big.Int) *big.Int {
+	count := getActiveMasternodes(statedb)
+	if count == 0 || amount.Sign() <= 0 {
+		return new(big.Int).Set(amount)
+	}
+
+	share := new(big.Int).Div(amount, new(big.Int).SetUint64(count))
+	if share.Sign() == 0 {
+		return new(big.Int).Set(amount)
+	}
+
+	acc := getMasternodeRewardAcc(statedb)
+	statedb.SetData(MasternodeContractAddress, keyMasternodeRewardAcc, acc.Add(acc, share).Bytes())
+
+	distributed := new(big.Int).Mul(share, new(big.Int).SetUint64(count))
+	statedb.CreateAccount(MasternodeContractAddress)
+	statedb.AddBalance(MasternodeContractAddress, distributed)
+
+	return new(big.Int).Sub(amount, distributed)
+}
+
+// getMasternodeRewardAcc returns the accumulated reward per active masternode
+func getMasternodeRewardAcc(statedb *state.Statedb) *big.Int {
+	return new(big.Int).SetBytes(statedb.GetData(MasternodeContractAddress, keyMasternodeRewardAcc))
+}
+
+func getActiveMasternodes(statedb *state.Statedb) uint64 {
+	return new(big.Int).SetBytes(statedb.GetData(MasternodeContractAddress, keyActiveMasternodes)).Uint64()
+}
+
+func setActiveMasternodes(statedb *state.Statedb, count uint64) {
+	statedb.SetData(MasternodeContractAddress, keyActiveMasternodes, new(big.Int).SetUint64(count).Bytes())
+}
+
+func getMasternodeAddresses(statedb *state.Statedb) ([]common.Address, error) {
+	value := statedb.GetData(MasternodeContractAddress, keyMasternodes)
+	if len(value) == 0 {
+		return nil, nil
+	}
+
+	var addresses []common.Address
+	if err := common.Deserialize(value, &addresses); err != nil {
+		return nil, err
+	}
+
+	return addresses, nil
+}
+
+func saveMasternodeAddresses(statedb *state.Statedb, addresses []common.Address) error {
+	value, err := common.Serialize(addresses)
+	if err != nil {
+		return err
+	}
+
+	statedb.SetData(MasternodeContractAddress, keyMasternodes, value)
+	return nil
+}
+
+func removeMasternodeAddress(statedb *state.Statedb, address common.Address) error {
+	addresses, err := getMasternodeAddresses(statedb)
+	if err != nil {
+		return err
+	}
+
+	for i, addr := range addresses {
+		if addr == address {
+			return saveMasternodeAddresses(statedb, append(addresses[:i], addresses[i+1:]...))
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,316 @@
+/**
+* @file
+* @copyright defined in scdo/LICENSE
+ */
+
+package system
+
+import (
+	"crypto/ecdsa"
+	"encoding/json"
+	"math/big"
+	"testing"
+
+	"github.com/elcn233/go-scdo/common"
+	"github.com/elcn233/go-scdo/consensus/istanbul"
+	"github.com/elcn233/go-scdo/core/types"
+	"github.com/elcn233/go-scdo/crypto"
+	"github.com/elcn233/go-scdo/database/leveldb"
+	"github.com/ethereum/go-ethereum/rlp"
+	"github.com/stretchr/testify/assert"
+)
+
+// newTestCommit returns a signed istanbul COMMIT message payload of the digest at height 10.
+func newTestCommit(key *ecdsa.PrivateKey, digest common.Hash) []byte {
+	subject, _ := rlp.EncodeToBytes(&istanbul.Subject{
+		View:   &istanbul.View{Round: big.NewInt(0), Sequence: big.NewInt(10)},
+		Digest: digest,
+	})
+
+	address := *crypto.PubkeyToAddress(key.PublicKey)
+	unsigned, _ := rlp.EncodeToBytes([]interface{}{istanbul.MsgCommitCode, subject, address, []byte{}, []byte{}})
+	sig, _ := crypto.Sign(key, crypto.Keccak256(unsigned))
+
+	payload, _ := rlp.EncodeToBytes([]interface{}{istanbul.MsgCommitCode, subject, address, sig.Sig, []byte{}})
+	return payload
+}
+
+func Test_Slash(t *testing.T) {
+	db, dispose := leveldb.NewTestDatabase()
+	defer dispose()
+
+	key, _ := crypto.GenerateKey()
+	validator := *crypto.PubkeyToAddress(key.PublicKey)
+	evidence := &types.IstanbulEvidence{
+		Validator: validator,
+		First:     newTestCommit(key, common.StringToHash("first")),
+		Second:    newTestCommit(key, common.StringToHash("second")),
+	}
+	input, err := json.Marshal(evidence)
+	assert.Equal(t, err, nil)
+
+	context := newTestContext(db, MasternodeContractAddress)
+	reporter := context.tx.Data.From
+	context.statedb.CreateAccount(reporter)
+
+	// not a masternode
+	_, err = slashCmd(input, context)
+	assert.Equal(t, err, ErrNotExist)
+
+	context.tx.Data.From = validator
+	context.tx.Data.Amount = new(big.Int).Set(depositLimit)
+	context.statedb.AddBalance(MasternodeContractAddress, depositLimit)
+	_, err = deposit(nil, context)
+	assert.Equal(t, err, nil)
+
+	// invalid evidence
+	context.tx.Data.From = reporter
+	invalid, _ := json.Marshal(&types.IstanbulEvidence{Validator: validator, First: evidence.First, Second: evidence.First})
+	_, err = slashCmd(invalid, context)
+	assert.Equal(t, err != nil, true)
+
+	_, err = slashCmd(input, context)
+	assert.Equal(t, err, nil)
+
+	reward := new(big.Int).Div(new(big.Int).Mul(depositLimit, big.NewInt(slashRewardPercent)), big.NewInt(100))
+	assert.Equal(t, context.statedb.GetBalance(reporter), reward)
+	assert.Equal(t, context.statedb.GetBalance(MasternodeContractAddress).Sign(), 0)
+
+	slashed, err := IsSlashed(validator, context.statedb)
+	assert.Equal(t, err, nil)
+	assert.Equal(t, slashed, true)
+
+	// slash again
+	_, err = slashCmd(input, context)
+	assert.Equal(t, err, ErrEvidenceHandled)
+
+	// the slashed deposit can not be recalled
+	context.BlockHeader.Height += recallDistanceLimit + 1
+	_, err = recallCmd(validator.Bytes(), context)
+	assert.Equal(t, err, ErrAlreadySlashed)
+}
+
+func Test_MasternodeLifecycle(t *testing.T) {
+	db, dispose := leveldb.NewTestDatabase()
+	defer dispose()
+
+	context := newTestContext(db, MasternodeContractAddress)
+	owner := context.tx.Data.From
+	context.statedb.CreateAccount(owner)
+
+	// not a masternode
+	result, err := queryMasternodeCmd(owner.Bytes(), context)
+	assert.Equal(t, err, nil)
+	assert.Equal(t, result, ByteFalse)
+
+	_, err = quitCmd(owner.Bytes(), context)
+	assert.Equal(t, err, ErrNotExist)
+
+	_, err = recallCmd(owner.Bytes(), context)
+	assert.Equal(t, err, ErrNotExist)
+
+	// deposit
+	_, err = deposit(nil, context)
+	assert.Equal(t, err, ErrDepositNotRight)
+
+	context.tx.Data.Amount = new(big.Int).Set(depositLimit)
+	context.statedb.AddBalance(MasternodeContractAddress, depositLimit)
+	_, err = deposit(nil, context)
+	assert.Equal(t, err, nil)
+
+	_, err = deposit(nil, context)
+	assert.Equal(t, err, ErrAlreadyExist)
+
+	result, err = queryMasternodeCmd(owner.Bytes(), context)
+	assert.Equal(t, err, nil)
+	assert.Equal(t, result, ByteTrue)
+
+	masternodes, err := GetMasternodes(context.statedb)
+	assert.Equal(t, err, nil)
+	assert.Equal(t, len(masternodes), 1)
+	assert.Equal(t, masternodes[0].Address, owner)
+	assert.Equal(t, masternodes[0].Active, true)
+
+	// quit by others
+	other := *crypto.MustGenerateShardAddress(1)
+	_, err = quitCmd(other.Bytes(), context)
+	assert.Equal(t, err, ErrNotExist)
+
+	context.tx.Data.From = other
+	_, err = quitCmd(owner.Bytes(), context)
+	assert.Equal(t, err, ErrNotOwner)
+	context.tx.Data.From = owner
+
+	// recall before quit
+	_, err = recallCmd(owner.Bytes(), context)
+	assert.Equal(t, err, ErrNotQuit)
+
+	_, err = quitCmd(owner.Bytes(), context)
+	assert.Equal(t, err, nil)
+
+	_, err = quitCmd(owner.Bytes(), context)
+	assert.Equal(t, err, ErrAlreadyQuit)
+
+	result, err = queryMasternodeCmd(owner.Bytes(), context)
+	assert.Equal(t, err, nil)
+	assert.Equal(t, result, ByteFalse)
+
+	// deposit again before recall
+	_, err = deposit(nil, context)
+	assert.Equal(t, err, ErrNotRecalled)
+
+	// recall
+	_, err = recallCmd(owner.Bytes(), context)
+	assert.Equal(t, err, ErrNotEnoughDistance)
+
+	context.BlockHeader.Height += recallDistanceLimit + 1
+	context.tx.Data.From = other
+	_, err = recallCmd(owner.Bytes(), context)
+	assert.Equal(t, err, ErrNotOwner)
+
+	context.tx.Data.From = owner
+	_, err = recallCmd(owner.Bytes(), context)
+	assert.Equal(t, err, nil)
+	assert.Equal(t, context.statedb.GetBalance(owner), depositLimit)
+	assert.Equal(t, context.statedb.GetBalance(MasternodeContractAddress).Sign(), 0)
+
+	masternodes, err = GetMasternodes(context.statedb)
+	assert.Equal(t, err, nil)
+	assert.Equal(t, len(masternodes), 0)
+}
+
+func Test_MasternodeReward(t *testing.T) {
+	db, dispose := leveldb.NewTestDatabase()
+	defer dispose()
+
+	context := newTestContext(db, MasternodeContractAddress)
+	context.tx.Data.Amount = new(big.Int).Set(depositLimit)
+
+	// no masternode
+	assert.Equal(t, DistributeMasternodeReward(context.statedb, big.NewInt(100)), big.NewInt(100))
+
+	first := context.tx.Data.From
+	second := *crypto.MustGenerateShardAddress(1)
+	for _, addr := range []common.Address{first, second} {
+		context.tx.Data.From = addr
+		context.statedb.CreateAccount(addr)
+		context.statedb.AddBalance(MasternodeContractAddress, depositLimit)
+		_, err := deposit(nil, context)
+		assert.Equal(t, err, nil)
+	}
+
+	// the remainder goes back
+	assert.Equal(t, DistributeMasternodeReward(context.statedb, big.NewInt(101)), big.NewInt(1))
+
+	masternode, err := GetMasternode(first, context.statedb)
+	assert.Equal(t, err, nil)
+	assert.Equal(t, masternode.Reward, big.NewInt(50))
+
+	// the first quits and stops earning
+	context.tx.Data.From = first
+	_, err = quitCmd(first.Bytes(), context)
+	assert.Equal(t, err, nil)
+
+	assert.Equal(t, DistributeMasternodeReward(context.statedb, big.NewInt(100)).Sign(), 0)
+
+	masternode, err = GetMasternode(first, context.statedb)
+	assert.Equal(t, err, nil)
+	assert.Equal(t, masternode.Reward, big.NewInt(50))
+	assert.Equal(t, masternode.RecallHeight, context.BlockHeader.Height+recallDistanceLimit+1)
+
+	masternode, err = GetMasternode(second, context.statedb)
+	assert.Equal(t, err, nil)
+	assert.Equal(t, masternode.Reward, big.NewInt(150))
+
+	// the deposit and reward are paid when recalled
+	context.BlockHeader.Height += recallDistanceLimit + 1
+	_, err = recallCmd(first.Bytes(), context)
+	assert.Equal(t, err, nil)
+	assert.Equal(t, context.statedb.GetBalance(first), new(big.Int).Add(depositLimit, big.NewInt(50)))
+	assert.Equal(t, context.statedb.GetBalance(MasternodeContractAddress), new(big.Int).Add(depositLimit, big.NewInt(150)))
+
+	masternodes, err := GetMasternodes(context.statedb)
+	assert.Equal(t, err, nil)
+	assert.Equal(t, len(masternodes), 1)
+	assert.Equal(t, masternodes[0].Address, second)
+}
+
+func Test_MasternodeV0(t *testing.T) {
+	db, dispose := leveldb.NewTestDatabase()
+	defer dispose()
+
+	context := newTestContext(db, MasternodeContractAddress)
+	owner := context.tx.Data.From
+	context.statedb.CreateAccount(owner)
+	context.statedb.AddBalance(MasternodeContractAddress, depositLimit)
+
+	v0 := GetContractByHeight(MasternodeContractAddress, common.MasternodeForkHeight-1)
+	assert.Equal(t, v0.RequiredGas([]byte{CmdGetMasternodes}), gasInvalidCommand)
+
+	context.tx.Data.Amount = new(big.Int).Set(depositLimit)
+	_, err := v0.Run([]byte{CmdDeposit}, context)
+	assert.Equal(t, err, nil)
+
+	// neither listed nor rewarded
+	masternodes, err := GetMasternodes(context.statedb)
+	assert.Equal(t, err, nil)
+	assert.Equal(t, len(masternodes), 0)
+	assert.Equal(t, DistributeMasternodeReward(context.statedb, big.NewInt(100)), big.NewInt(100))
+
+	info, err := QueryAddress(owner, context.statedb)
+	assert.Equal(t, err, nil)
+	assert.Equal(t, info, &masternodeInfo{})
+
+	_, err = v0.Run(append([]byte{CmdQuit}, owner.Bytes()...), context)
+	assert.Equal(t, err, nil)
+
+	context.BlockHeader.Height += recallDistanceLimit + 1
+	_, err = v0.Run(append([]byte{CmdRecall}, owner.Bytes()...), context)
+	assert.Equal(t, err, nil)
+	assert.Equal(t, context.statedb.GetBalance(owner), depositLimit)
+}
+
+func Test_MasternodeJoin(t *testing.T) {
+	db, dispose := leveldb.NewTestDatabase()
+	defer dispose()
+
+	context := newTestContext(db, MasternodeContractAddress)
+	first := context.tx.Data.From
+	second := *crypto.MustGenerateShardAddress(1)
+
+	// deposited before the fork height
+	for _, addr := range []common.Address{first, second} {
+		assert.Equal(t, saveInfo(addr.Bytes(), context.statedb, &masternodeInfo{}), nil)
+	}
+
+	// join without deposit again
+	context.tx.Data.Amount = big.NewInt(0)
+	_, err := deposit(nil, context)
+	assert.Equal(t, err, nil)
+
+	_, err = deposit(nil, context)
+	assert.Equal(t, err, ErrDepositNotRight)
+
+	masternodes, err := GetMasternodes(context.statedb)
+	assert.Equal(t, err, nil)
+	assert.Equal(t, len(masternodes), 1)
+	assert.Equal(t, masternodes[0].Address, first)
+	assert.Equal(t, DistributeMasternodeReward(context.statedb, big.NewInt(100)).Sign(), 0)
+
+	masternode, err := GetMasternode(first, context.statedb)
+	assert.Equal(t, err, nil)
+	assert.Equal(t, masternode.Reward, big.NewInt(100))
+
+	// listed when quits without joining, and earns nothing
+	context.tx.Data.From = second
+	_, err = quitCmd(second.Bytes(), context)
+	assert.Equal(t, err, nil)
+
+	masternodes, err = GetMasternodes(context.statedb)
+	assert.Equal(t, err, nil)
+	assert.Equal(t, len(masternodes), 2)
+	assert.Equal(t, masternodes[1].Address, second)
+	assert.Equal(t, masternodes[1].Active, false)
+	assert.Equal(t, masternodes[1].Reward.Sign(), 0)
+	assert.Equal(t, getActiveMasternodes(context.statedb), uint64(1))
+}
@@ -0,0 +1,107 @@
+/**
+* @file
+* @copyright defined in scdo/LICENSE
+ */
+
+package system
+
+import (
+	"github.com/elcn233/go-scdo/common"
+	"github.com/elcn233/go-scdo/crypto"
+)
+
+var (
+	// masternodeCommandsV0 are the original commands before MasternodeForkHeight, the
+	// masternodes are neither listed nor rewarded
+	masternodeCommandsV0 = map[byte]*cmdInfo{
+		CmdDeposit:         &cmdInfo{gasCmdDeposit, depositV0},
+		CmdQueryMasternode: &cmdInfo{gasCmdQueryMasterNode, queryMasternodeV0},
+		CmdRecall:          {gasCmdRecall, recallV0},
+		CmdQuit:            {gasCmdQuit, quitV0},
+	}
+
+	masternodeSchemaV0 = []CommandSchema{
+		{ID: CmdDeposit, Name: "deposit", Input: TypeNone, Output: TypeNone},
+		{ID: CmdQueryMasternode, Name: "query", Input: TypeAddress, Output: TypeBytes, ReadOnly: true},
+		{ID: CmdRecall, Name: "recall", Input: TypeAddress, Output: TypeNone},
+		{ID: CmdQuit, Name: "quit", Input: TypeAddress, Output: TypeNone},
+	}
+)
+
+func depositV0(input []byte, context *Context) ([]byte, error) {
+	if context.tx.Data.Amount.Cmp(depositLimit) != 0 {
+		return nil, ErrDepositNotRight
+	}
+
+	sender := context.tx.Data.From
+	info, err := QueryAddress(sender, context.statedb)
+	if err != nil {
+		return nil, err
+	}
+
+	if info != nil && !info.IsQuit {
+		return nil, ErrAlreadyExist
+	}
+
+	info = &masternodeInfo{
+		IsQuit: false,
+	}
+
+	context.statedb.SetData(MasternodeContractAddress, crypto.MustHash(sender), common.SerializePanic(info))
+
+	return nil, nil
+}
+
+// queryMasternodeV0 returns false for all the existing masternodes as before, the
+// address without masternode is not queryable.
+func queryMasternodeV0(address []byte, context *Context) ([]byte, error) {
+	info, err := getInfo(address, context.statedb)
+	if err != nil {
+		return nil, err
+	}
+
+	if info == nil {
+		return nil, ErrNotExist
+	}
+
+	return ByteFalse, nil
+}
+
+func recallV0(address []byte, context *Context) ([]byte, error) {
+	info, err := getInfo(address, context.statedb)
+	if err != nil {
+		return nil, err
+	}
+
+	if info == nil {
+		return nil, ErrNotQuit
+	}
+
+	distance := context.BlockHeader.Height - info.QuitBlock
+	if info.IsQuit && distance > recallDistanceLimit {
+		context.statedb.SetData(MasternodeContractAddress, crypto.MustHash(address), nil)
+		context.statedb.SubBalance(MasternodeContractAddress, depositLimit)
+		context.statedb.AddBalance(context.tx.Data.From, depositLimit)
+	} else {
+		return nil, ErrNotEnoughDistance
+	}
+
+	return nil, nil
+}
+
+func quitV0(address []byte, context *Context) ([]byte, error) {
+	info, err := getInfo(address, context.statedb)
+	if err != nil {
+		return nil, err
+	}
+
+	if info != nil && !info.IsQuit {
+		info.IsQuit = true
+		info.QuitBlock = context.BlockHeader.Height
+		if err := saveInfo(address, context.statedb, info); err != nil {
+			return nil, err
+		}
+	}
+
+	return nil, nil
+}
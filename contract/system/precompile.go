@@ -0,0 +1,257 @@
+/**
+* @file
+* @copyright defined in scdo/LICENSE
+ */
+
+package system
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"strings"
+
+	"github.com/elcn233/go-scdo/accounts/abi"
+	"github.com/elcn233/go-scdo/common"
+	"github.com/elcn233/go-scdo/core/types"
+	"github.com/elcn233/go-scdo/crypto"
+)
+
+var (
+	errPrecompileReadOnly = errors.New("system contract command is not read-only")
+	errPrecompileInput    = errors.New("invalid system contract input")
+
+	// solidityTypes are the Solidity types of the command input and output types
+	solidityTypes = map[string]string{
+		TypeString:  "string",
+		TypeAddress: "address",
+		TypeHash:    "bytes32",
+		TypeJSON:    "string",
+		TypeBytes:   "bytes",
+	}
+)
+
+// abiArgument is the argument of function in Solidity ABI JSON.
+type abiArgument struct {
+	Name string `json:"name"`
+	Type string `json:"type"`
+}
+
+// abiFunction is the function in Solidity ABI JSON.
+type abiFunction struct {
+	Type            string        `json:"type"`
+	Name            string        `json:"name"`
+	Inputs          []abiArgument `json:"inputs"`
+	Outputs         []abiArgument `json:"outputs"`
+	Constant        bool          `json:"constant"`
+	Payable         bool          `json:"payable"`
+	StateMutability string        `json:"stateMutability"`
+}
+
+// ABI returns the Solidity ABI of the native contract in JSON. Each command is a function
+// of the same name, whose parameter and return value are the command input and output.
+func (s *NativeContractSchema) ABI() (string, error) {
+	functions := make([]abiFunction, 0, len(s.Commands))
+	for _, cmd := range s.Commands {
+		function := abiFunction{
+			Type:            "function",
+			Name:            cmd.Name,
+			Inputs:          abiArguments("input", cmd.Input),
+			Outputs:         abiArguments("output", cmd.Output),
+			Constant:        cmd.ReadOnly,
+			Payable:         !cmd.ReadOnly,
+			StateMutability: "payable",
+		}
+
+		if cmd.ReadOnly {
+			function.StateMutability = "view"
+		}
+
+		functions = append(functions, function)
+	}
+
+	encoded, err := json.Marshal(functions)
+	if err != nil {
+		return "", err
+	}
+
+	return string(encoded), nil
+}
+
+func abiArguments(name, t string) []abiArgument {
+	if t == TypeNone {
+		return []abiArgument{}
+	}
+
+	return []abiArgument{{name, solidityTypes[t]}}
+}
+
+// Precompile is the system contract callable in EVM, whose input and output
+// are encoded in Solidity ABI.
+type Precompile struct {
+	address  common.Address
+	contract Contract
+	abi      abi.ABI
+	commands map[string]CommandSchema // commands by function name
+}
+
+// newPrecompile creates the precompile of the native contract version.
+func newPrecompile(address common.Address, contract Contract, schema *NativeContractSchema) (*Precompile, error) {
+	abiJSON, err := schema.ABI()
+	if err != nil {
+		return nil, err
+	}
+
+	parsed, err := abi.JSON(strings.NewReader(abiJSON))
+	if err != nil {
+		return nil, err
+	}
+
+	commands := make(map[string]CommandSchema)
+	for _, cmd := range schema.Commands {
+		commands[cmd.Name] = cmd
+	}
+
+	return &Precompile{address, contract, parsed, commands}, nil
+}
+
+// GetPrecompile get the system contract activated at the height by the address for EVM.
+// It returns nil if no contract registered or activated, and a precompile that fails
+// all calls if deprecated.
+func GetPrecompile(address common.Address, height uint64) *Precompile {
+	c, found := getNativeContract(address)
+	if !found {
+		return nil
+	}
+
+	v := c.version(height)
+	if v == nil {
+		return nil
+	}
+
+	if c.deprecated(height) {
+		p := *v.precompile
+		p.contract = inactiveContract{}
+		return &p
+	}
+
+	return v.precompile
+}
+
+// command returns the command schema and the method of the ABI encoded input.
+func (p *Precompile) command(input []byte) (*CommandSchema, *abi.Method, error) {
+	if len(input) < 4 {
+		return nil, nil, errInvalidCommand
+	}
+
+	method, err := p.abi.MethodById(input[:4])
+	if err != nil {
+		return nil, nil, errInvalidCommand
+	}
+
+	cmd := p.commands[method.Name]
+	return &cmd, method, nil
+}
+
+// RequiredGas returns the gas of the command called by the ABI encoded input.
+func (p *Precompile) RequiredGas(input []byte) uint64 {
+	cmd, _, err := p.command(input)
+	if err != nil {
+		return gasInvalidCommand
+	}
+
+	return p.contract.RequiredGas([]byte{cmd.ID})
+}
+
+// Run runs the command called by the ABI encoded input in the context of the tx, the
+// caller is the sender and the value is the amount of the command. Only the read-only
+// commands are allowed if readOnly is true, e.g. called by STATICCALL.
+func (p *Precompile) Run(context *Context, caller common.Address, value *big.Int, input []byte, readOnly bool) ([]byte, error) {
+	cmd, method, err := p.command(input)
+	if err != nil {
+		return nil, err
+	}
+
+	if readOnly && !cmd.ReadOnly {
+		return nil, errPrecompileReadOnly
+	}
+
+	args, err := method.Inputs.UnpackValues(input[4:])
+	if err != nil {
+		return nil, err
+	}
+
+	cmdInput, err := fromABIValue(cmd.Input, args)
+	if err != nil {
+		return nil, err
+	}
+
+	// the command is called by the caller in the tx with the value, whose hash is unique
+	// for each call, e.g. as the id of the HTLC created in the call.
+	tx := &types.Transaction{Hash: context.precompileCallHash(caller), Data: context.tx.Data, Signature: context.tx.Signature}
+	tx.Data.From = caller
+	tx.Data.To = p.address
+	tx.Data.Amount = new(big.Int).Set(value)
+	tx.Data.Payload = append([]byte{cmd.ID}, cmdInput...)
+
+	output, err := p.contract.Run(tx.Data.Payload, NewContext(tx, context.statedb, context.BlockHeader))
+	if err != nil {
+		return nil, err
+	}
+
+	if cmd.Output == TypeNone {
+		return nil, nil
+	}
+
+	return method.Outputs.Pack(toABIValue(cmd.Output, output))
+}
+
+// precompileCallHash returns the hash of the next system contract call in EVM by the caller
+func (c *Context) precompileCallHash(caller common.Address) common.Hash {
+	var index [4]byte
+	binary.BigEndian.PutUint32(index[:], c.precompileCalls)
+	c.precompileCalls++
+
+	return crypto.HashBytes(c.tx.Hash.Bytes(), caller.Bytes(), index[:])
+}
+
+// fromABIValue converts the unpacked ABI argument to the command input
+func fromABIValue(t string, args []interface{}) ([]byte, error) {
+	if t == TypeNone {
+		return nil, nil
+	}
+
+	if len(args) != 1 {
+		return nil, errPrecompileInput
+	}
+
+	switch value := args[0].(type) {
+	case string:
+		return []byte(value), nil
+	case common.Address:
+		return value.Bytes(), nil
+	case [common.HashLength]byte:
+		return value[:], nil
+	case []byte:
+		return value, nil
+	default:
+		return nil, errPrecompileInput
+	}
+}
+
+// toABIValue converts the command output to the value to pack in ABI
+func toABIValue(t string, output []byte) interface{} {
+	switch t {
+	case TypeString, TypeJSON:
+		return string(output)
+	case TypeAddress:
+		return common.BytesToAddress(output)
+	case TypeHash:
+		var hash [common.HashLength]byte
+		copy(hash[:], common.BytesToHash(output).Bytes())
+		return hash
+	default:
+		return output
+	}
+}
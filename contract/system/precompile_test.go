@@ -0,0 +1,117 @@
+/**
+* @file
+* @copyright defined in scdo/LICENSE
+ */
+
+package system
+
+import (
+	"encoding/json"
+	"math/big"
+	"testing"
+
+	"github.com/elcn233/go-scdo/common"
+	"github.com/elcn233/go-scdo/crypto"
+	"github.com/elcn233/go-scdo/database/leveldb"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_NativeContractABI(t *testing.T) {
+	schema := &NativeContractSchema{
+		Name: "test",
+		Commands: []CommandSchema{
+			{ID: 0, Name: "set", Input: TypeHash, Output: TypeNone},
+			{ID: 1, Name: "get", Input: TypeNone, Output: TypeBytes, ReadOnly: true},
+		},
+	}
+
+	abiJSON, err := schema.ABI()
+	assert.Equal(t, err, nil)
+	assert.Equal(t, abiJSON, `[{"type":"function","name":"set","inputs":[{"name":"input","type":"bytes32"}],"outputs":[],"constant":false,"payable":true,"stateMutability":"payable"},`+
+		`{"type":"function","name":"get","inputs":[],"outputs":[{"name":"output","type":"bytes"}],"constant":true,"payable":false,"stateMutability":"view"}]`)
+
+	// all the built-in contracts are callable in EVM
+	for _, schema := range GetNativeContractSchemas(0) {
+		assert.Equal(t, GetPrecompile(schema.Address, 0) != nil, true)
+	}
+
+	assert.Equal(t, GetPrecompile(common.BytesToAddress([]byte{1, 0x7f}), 0) == nil, true)
+}
+
+func Test_Precompile(t *testing.T) {
+	db, dispose := leveldb.NewTestDatabase()
+	defer dispose()
+
+	context := newTestContext(db, DomainNameContractAddress)
+	caller := *crypto.MustGenerateShardAddress(1)
+	p := GetPrecompile(DomainNameContractAddress, 0)
+
+	// create domain name by the calling contract
+	input, err := p.abi.Pack("create", "scdo")
+	assert.Equal(t, err, nil)
+	assert.Equal(t, p.RequiredGas(input), gasCreateDomainName)
+
+	output, err := p.Run(context, caller, big.NewInt(0), input, false)
+	assert.Equal(t, err, nil)
+	owner, err := p.abi.Methods["create"].Outputs.UnpackValues(output)
+	assert.Equal(t, err, nil)
+	assert.Equal(t, owner, []interface{}{caller})
+
+	// the origin tx is not changed
+	assert.Equal(t, context.tx.Data.To, DomainNameContractAddress)
+	assert.Equal(t, context.tx.Data.Amount, big.NewInt(1))
+
+	// read-only command
+	input, err = p.abi.Pack("getOwner", "scdo")
+	assert.Equal(t, err, nil)
+	output, err = p.Run(context, *crypto.MustGenerateShardAddress(1), big.NewInt(0), input, true)
+	assert.Equal(t, err, nil)
+	owner, err = p.abi.Methods["getOwner"].Outputs.UnpackValues(output)
+	assert.Equal(t, err, nil)
+	assert.Equal(t, owner, []interface{}{caller})
+
+	// only read-only commands are allowed in static call
+	input, _ = p.abi.Pack("create", "scdo2")
+	_, err = p.Run(context, caller, big.NewInt(0), input, true)
+	assert.Equal(t, err, errPrecompileReadOnly)
+
+	// unknown function
+	assert.Equal(t, p.RequiredGas([]byte{1, 2, 3, 4}), gasInvalidCommand)
+	_, err = p.Run(context, caller, big.NewInt(0), []byte{1, 2, 3, 4}, false)
+	assert.Equal(t, err, errInvalidCommand)
+}
+
+func Test_PrecompileCallHash(t *testing.T) {
+	db, dispose := leveldb.NewTestDatabase()
+	defer dispose()
+
+	context := newContext(db, 0, 1)
+	caller := *crypto.MustGenerateShardAddress(1)
+	p := GetPrecompile(HashTimeLockContractAddress, common.HTLCForkHeight)
+
+	// the HTLCs created by the calls in the same tx have different ids
+	lock := newTestHashTimeLock(testGenesisAccounts[1].addr, 10)
+	input, err := p.abi.Pack("create", string(mustJSON(&lock)))
+	assert.Equal(t, err, nil)
+
+	ids := make(map[common.Hash]bool)
+	for i := 0; i < 2; i++ {
+		output, err := p.Run(context, caller, big.NewInt(10), input, false)
+		assert.Equal(t, err, nil)
+
+		values, err := p.abi.Methods["create"].Outputs.UnpackValues(output)
+		assert.Equal(t, err, nil)
+
+		var info struct{ ID common.Hash }
+		assert.Equal(t, json.Unmarshal([]byte(values[0].(string)), &info), nil)
+		assert.Equal(t, info.ID != context.tx.Hash, true)
+		ids[info.ID] = true
+	}
+
+	assert.Equal(t, len(ids), 2)
+
+	// the hash is derived from the tx, caller and call index
+	context, context2 := newContext(db, 0, 1), newContext(db, 0, 1)
+	assert.Equal(t, context.precompileCallHash(caller), context2.precompileCallHash(caller))
+	assert.Equal(t, context.precompileCallHash(caller) == context2.precompileCallHash(*crypto.MustGenerateShardAddress(1)), false)
+}
@@ -0,0 +1,382 @@
+/**
+* @file
+* @copyright defined in scdo/LICENSE
+ */
+
+package system
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/elcn233/go-scdo/common"
+	"github.com/elcn233/go-scdo/common/hexutil"
+)
+
+// Types of the input and output of native contract commands, clients
+// encode the command input and decode the output according to the type.
+const (
+	// TypeNone no input or output.
+	TypeNone = "none"
+	// TypeString UTF-8 string, e.g. domain name.
+	TypeString = "string"
+	// TypeAddress account address in 20 bytes.
+	TypeAddress = "address"
+	// TypeHash hash in 32 bytes.
+	TypeHash = "hash"
+	// TypeJSON JSON encoded object.
+	TypeJSON = "json"
+	// TypeBytes raw bytes in HEX.
+	TypeBytes = "bytes"
+)
+
+var (
+	errContractNotActive     = errors.New("system contract is not active")
+	errNotReservedAddress    = errors.New("native contract address should be reserved")
+	errContractRegistered    = errors.New("native contract already registered")
+	errInvalidContractName   = errors.New("invalid native contract name")
+	errNoContractVersion     = errors.New("native contract has no version")
+	errVersionHeight         = errors.New("version height should be greater than the previous one")
+	errDeprecateHeight       = errors.New("deprecate height should be greater than the version heights")
+	errInvalidCommandType    = errors.New("invalid command input or output type")
+	errDuplicateCommand      = errors.New("duplicate native contract command")
+	errCommandSchemaMismatch = errors.New("command schema mismatch with the command table")
+
+	// registry is the registered native contracts, guarded by registryLock together with contracts
+	registry     = make(map[common.Address]*NativeContract)
+	registryLock sync.RWMutex
+)
+
+// CommandSchema describes a command of native contract for clients to generate calls.
+type CommandSchema struct {
+	ID       byte   `json:"id"`
+	Name     string `json:"name"`
+	Gas      uint64 `json:"gas"`
+	Input    string `json:"input"`
+	Output   string `json:"output"`
+	ReadOnly bool   `json:"readOnly,omitempty"`
+}
+
+// NativeCommand is a command of user-defined native contract.
+type NativeCommand struct {
+	CommandSchema
+	Handler func(input []byte, context *Context) ([]byte, error)
+}
+
+// nativeVersion is the commands of native contract activated at the height
+type nativeVersion struct {
+	height     uint64
+	contract   *contract
+	schema     []CommandSchema
+	precompile *Precompile
+}
+
+// NativeContract is a native contract at a reserved address, which has versions of commands
+// activated at fork heights and could be deprecated at a height.
+type NativeContract struct {
+	Name            string
+	Address         common.Address
+	DeprecateHeight uint64 // not deprecated if 0
+
+	versions []*nativeVersion
+}
+
+// NativeContractSchema is the schema of a native contract version for clients to generate calls.
+type NativeContractSchema struct {
+	Name             string          `json:"name"`
+	Address          common.Address  `json:"address"`
+	ActivationHeight uint64          `json:"activationHeight"`
+	DeprecateHeight  uint64          `json:"deprecateHeight,omitempty"`
+	Commands         []CommandSchema `json:"commands"`
+}
+
+// NewNativeContract creates a native contract of the specified name and reserved address.
+func NewNativeContract(name string, address common.Address) *NativeContract {
+	return &NativeContract{Name: name, Address: address}
+}
+
+// AddVersion adds a version of commands activated at the height, which replaces all the
+// commands of previous version. Versions should be added in ascending order of height.
+func (c *NativeContract) AddVersion(height uint64, commands ...*NativeCommand) error {
+	cmds := make(map[byte]*cmdInfo)
+	var schema []CommandSchema
+	for _, cmd := range commands {
+		if _, found := cmds[cmd.ID]; found || cmd.Handler == nil {
+			return errDuplicateCommand
+		}
+
+		cmds[cmd.ID] = &cmdInfo{cmd.Gas, cmd.Handler}
+		schema = append(schema, cmd.CommandSchema)
+	}
+
+	return c.addVersion(height, cmds, schema)
+}
+
+// addVersion adds a version with the command table and the schema of commands,
+// the gas of commands in schema is filled with the command table.
+func (c *NativeContract) addVersion(height uint64, cmds map[byte]*cmdInfo, schema []CommandSchema) error {
+	if n := len(c.versions); n > 0 && height <= c.versions[n-1].height {
+		return errVersionHeight
+	}
+
+	if len(schema) != len(cmds) {
+		return errCommandSchemaMismatch
+	}
+
+	commands := make([]CommandSchema, len(schema))
+	names := make(map[string]bool)
+	for i, cmd := range schema {
+		info, found := cmds[cmd.ID]
+		if !found || len(cmd.Name) == 0 || names[cmd.Name] {
+			return errCommandSchemaMismatch
+		}
+
+		if !validCommandType(cmd.Input) || !validCommandType(cmd.Output) {
+			return errInvalidCommandType
+		}
+
+		names[cmd.Name] = true
+		commands[i] = cmd
+		commands[i].Gas = info.cmdUsedGas
+	}
+
+	sort.Slice(commands, func(i, j int) bool {
+		return commands[i].ID < commands[j].ID
+	})
+
+	v := &nativeVersion{height: height, contract: &contract{cmds}, schema: commands}
+	precompile, err := newPrecompile(c.Address, v.contract, &NativeContractSchema{Name: c.Name, Address: c.Address, Commands: commands})
+	if err != nil {
+		return err
+	}
+
+	v.precompile = precompile
+	c.versions = append(c.versions, v)
+	return nil
+}
+
+// Deprecate deprecates the native contract at the height, all commands fail since then.
+func (c *NativeContract) Deprecate(height uint64) *NativeContract {
+	c.DeprecateHeight = height
+	return c
+}
+
+// version returns the version activated at the height, or nil if not activated.
+func (c *NativeContract) version(height uint64) *nativeVersion {
+	for i := len(c.versions) - 1; i >= 0; i-- {
+		if c.versions[i].height <= height {
+			return c.versions[i]
+		}
+	}
+
+	return nil
+}
+
+// deprecated returns true if the native contract is deprecated at the height.
+func (c *NativeContract) deprecated(height uint64) bool {
+	return c.DeprecateHeight > 0 && height >= c.DeprecateHeight
+}
+
+// Schema returns the schema of the version activated at the height, or nil if not activated.
+func (c *NativeContract) Schema(height uint64) *NativeContractSchema {
+	v := c.version(height)
+	if v == nil {
+		return nil
+	}
+
+	return &NativeContractSchema{
+		Name:             c.Name,
+		Address:          c.Address,
+		ActivationHeight: v.height,
+		DeprecateHeight:  c.DeprecateHeight,
+		Commands:         v.schema,
+	}
+}
+
+// RegisterNativeContract registers the native contract, the contract address should be
+// reserved and both the address and name should not be registered before.
+func RegisterNativeContract(c *NativeContract) error {
+	if !c.Address.IsReserved() {
+		return errNotReservedAddress
+	}
+
+	if len(c.Name) == 0 {
+		return errInvalidContractName
+	}
+
+	if len(c.versions) == 0 {
+		return errNoContractVersion
+	}
+
+	if c.DeprecateHeight > 0 && c.DeprecateHeight <= c.versions[len(c.versions)-1].height {
+		return errDeprecateHeight
+	}
+
+	registryLock.Lock()
+	defer registryLock.Unlock()
+
+	for _, registered := range registry {
+		if registered.Address == c.Address || registered.Name == c.Name {
+			return errContractRegistered
+		}
+	}
+
+	registry[c.Address] = c
+	contracts[c.Address] = c.versions[len(c.versions)-1].contract
+
+	return nil
+}
+
+// commandSet is the command table and the schema of a built-in native contract version
+type commandSet struct {
+	height uint64
+	cmds   map[byte]*cmdInfo
+	schema []CommandSchema
+}
+
+// mustRegisterNativeContract registers the built-in native contract with the versions of
+// command sets activated at their heights, panic on any error.
+func mustRegisterNativeContract(name string, address common.Address, versions ...commandSet) {
+	c := NewNativeContract(name, address)
+	for _, v := range versions {
+		if err := c.addVersion(v.height, v.cmds, v.schema); err != nil {
+			panic(fmt.Sprintf("invalid native contract %s, %s", name, err))
+		}
+	}
+
+	if err := RegisterNativeContract(c); err != nil {
+		panic(fmt.Sprintf("failed to register native contract %s, %s", name, err))
+	}
+}
+
+// GetContractByHeight get the system contract activated at the height by the address. It
+// returns nil if no contract registered or activated, and a contract that fails all
+// commands if deprecated.
+func GetContractByHeight(address common.Address, height uint64) Contract {
+	c, found := getNativeContract(address)
+	if !found {
+		return nil
+	}
+
+	v := c.version(height)
+	if v == nil {
+		return nil
+	}
+
+	if c.deprecated(height) {
+		return inactiveContract{}
+	}
+
+	return v.contract
+}
+
+// GetNativeContractSchemas returns the schema of native contracts activated and not
+// deprecated at the height, which are ordered by address.
+func GetNativeContractSchemas(height uint64) []*NativeContractSchema {
+	registryLock.RLock()
+	defer registryLock.RUnlock()
+
+	var schemas []*NativeContractSchema
+	for _, c := range registry {
+		if schema := c.Schema(height); schema != nil && !c.deprecated(height) {
+			schemas = append(schemas, schema)
+		}
+	}
+
+	sort.Slice(schemas, func(i, j int) bool {
+		return schemas[i].Address.Big().Cmp(schemas[j].Address.Big()) < 0
+	})
+
+	return schemas
+}
+
+// getNativeContract returns the registered native contract of the address.
+func getNativeContract(address common.Address) (*NativeContract, bool) {
+	registryLock.RLock()
+	defer registryLock.RUnlock()
+
+	c, found := registry[address]
+	return c, found
+}
+
+// inactiveContract is a deprecated native contract that fails all commands.
+type inactiveContract struct{}
+
+func (inactiveContract) RequiredGas(input []byte) uint64 {
+	return gasInvalidCommand
+}
+
+func (inactiveContract) Run(input []byte, context *Context) ([]byte, error) {
+	return nil, errContractNotActive
+}
+
+func validCommandType(t string) bool {
+	switch t {
+	case TypeNone, TypeString, TypeAddress, TypeHash, TypeJSON, TypeBytes:
+		return true
+	default:
+		return false
+	}
+}
+
+// EncodeCommandInput encodes the command input from the text value according to the type.
+func EncodeCommandInput(t string, value string) ([]byte, error) {
+	switch t {
+	case TypeNone:
+		return nil, nil
+	case TypeString:
+		return []byte(value), nil
+	case TypeAddress:
+		addr, err := common.HexToAddress(value)
+		if err != nil {
+			return nil, err
+		}
+
+		return addr.Bytes(), nil
+	case TypeHash:
+		hash, err := common.HexToHash(value)
+		if err != nil {
+			return nil, err
+		}
+
+		return hash.Bytes(), nil
+	case TypeJSON:
+		if !json.Valid([]byte(value)) {
+			return nil, fmt.Errorf("invalid JSON input %s", value)
+		}
+
+		return []byte(value), nil
+	case TypeBytes:
+		return hexutil.HexToBytes(value)
+	default:
+		return nil, errInvalidCommandType
+	}
+}
+
+// DecodeCommandOutput decodes the command output according to the type for display.
+func DecodeCommandOutput(t string, output []byte) (interface{}, error) {
+	switch t {
+	case TypeNone:
+		return nil, nil
+	case TypeString:
+		return string(output), nil
+	case TypeAddress:
+		return common.BytesToAddress(output).Hex(), nil
+	case TypeHash:
+		return common.BytesToHash(output).Hex(), nil
+	case TypeJSON:
+		var result interface{}
+		if err := json.Unmarshal(output, &result); err != nil {
+			return nil, err
+		}
+
+		return result, nil
+	case TypeBytes:
+		return hexutil.BytesToHex(output), nil
+	default:
+		return nil, errInvalidCommandType
+	}
+}
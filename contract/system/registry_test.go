@@ -0,0 +1,183 @@
+/**
+* @file
+* @copyright defined in scdo/LICENSE
+ */
+
+package system
+
+import (
+	"testing"
+
+	"github.com/elcn233/go-scdo/common"
+	"github.com/elcn233/go-scdo/crypto"
+	"github.com/elcn233/go-scdo/database/leveldb"
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestNativeCommand(id byte, name string, gas uint64, result string) *NativeCommand {
+	return &NativeCommand{
+		CommandSchema: CommandSchema{ID: id, Name: name, Gas: gas, Input: TypeString, Output: TypeString},
+		Handler: func(input []byte, context *Context) ([]byte, error) {
+			return append([]byte(result), input...), nil
+		},
+	}
+}
+
+func Test_BuiltinContracts(t *testing.T) {
+	// the validator contract is activated at the fork height
+	assert.Equal(t, len(GetNativeContractSchemas(0)), 5)
+	assert.Equal(t, GetContractByHeight(ValidatorContractAddress, common.ValidatorContractForkHeight-1), nil)
+
+	schemas := GetNativeContractSchemas(common.SystemContractForkHeight)
+	assert.Equal(t, len(schemas), 6)
+	assert.Equal(t, schemas[0].Name, "domain")
+	assert.Equal(t, schemas[0].Address, DomainNameContractAddress)
+	assert.Equal(t, schemas[5].Address, ValidatorContractAddress)
+
+	for _, schema := range schemas {
+		assert.Equal(t, GetContractByHeight(schema.Address, common.SystemContractForkHeight), GetContractByAddress(schema.Address))
+	}
+
+	// gas is filled with the command table
+	assert.Equal(t, schemas[0].Commands[CmdCreateDomainName].Name, "create")
+	assert.Equal(t, schemas[0].Commands[CmdCreateDomainName].Gas, gasCreateDomainName)
+
+	// verifyTx charges the fee, which is not callable in static call
+	relay := GetPrecompile(BTCRelayContractAddress, 0)
+	input, err := relay.abi.Pack("verifyTx", "{}")
+	assert.Equal(t, err, nil)
+	_, err = relay.Run(nil, common.EmptyAddress, nil, input, true)
+	assert.Equal(t, err, errPrecompileReadOnly)
+}
+
+func Test_MustRegisterNativeContract(t *testing.T) {
+	address := common.BytesToAddress([]byte{1, 0x7e})
+	defer func() {
+		delete(registry, address)
+		delete(contracts, address)
+	}()
+
+	v1 := map[byte]*cmdInfo{0: {100, nil}}
+	v2 := map[byte]*cmdInfo{0: {100, nil}, 1: {200, nil}}
+	mustRegisterNativeContract("test", address,
+		commandSet{0, v1, []CommandSchema{{ID: 0, Name: "get", Input: TypeNone, Output: TypeBytes}}},
+		commandSet{10, v2, []CommandSchema{{ID: 0, Name: "get", Input: TypeNone, Output: TypeBytes}, {ID: 1, Name: "set", Input: TypeBytes, Output: TypeNone}}},
+	)
+
+	assert.Equal(t, GetContractByHeight(address, 9).RequiredGas([]byte{1}), gasInvalidCommand)
+	assert.Equal(t, GetContractByHeight(address, 10).RequiredGas([]byte{1}), uint64(200))
+	assert.Equal(t, GetContractByAddress(address), GetContractByHeight(address, 10))
+
+	// versions in wrong order
+	defer func() {
+		assert.Equal(t, recover() != nil, true)
+	}()
+	mustRegisterNativeContract("test2", common.BytesToAddress([]byte{1, 0x7d}),
+		commandSet{10, v1, []CommandSchema{{ID: 0, Name: "get", Input: TypeNone, Output: TypeBytes}}},
+		commandSet{0, v1, []CommandSchema{{ID: 0, Name: "get", Input: TypeNone, Output: TypeBytes}}},
+	)
+}
+
+func Test_RegisterNativeContract(t *testing.T) {
+	address := common.BytesToAddress([]byte{1, 0x7f})
+	defer func() {
+		delete(registry, address)
+		delete(contracts, address)
+	}()
+
+	// not reserved address
+	c := NewNativeContract("test", common.BytesToAddress([]byte{1, 2, 3, 4, 5, 6, 7, 8, 9, 0}))
+	assert.Equal(t, c.AddVersion(10, newTestNativeCommand(0, "echo", 100, "v1:")), nil)
+	assert.Equal(t, RegisterNativeContract(c), errNotReservedAddress)
+
+	// address or name already registered
+	c = NewNativeContract("domain", address)
+	assert.Equal(t, c.AddVersion(10, newTestNativeCommand(0, "echo", 100, "v1:")), nil)
+	assert.Equal(t, RegisterNativeContract(c), errContractRegistered)
+
+	c = NewNativeContract("test", DomainNameContractAddress)
+	assert.Equal(t, c.AddVersion(10, newTestNativeCommand(0, "echo", 100, "v1:")), nil)
+	assert.Equal(t, RegisterNativeContract(c), errContractRegistered)
+
+	// no version
+	assert.Equal(t, RegisterNativeContract(NewNativeContract("test", address)), errNoContractVersion)
+
+	// invalid versions
+	c = NewNativeContract("test", address)
+	assert.Equal(t, c.AddVersion(10, newTestNativeCommand(0, "echo", 100, "v1:"), newTestNativeCommand(0, "echo2", 100, "v1:")), errDuplicateCommand)
+	assert.Equal(t, c.AddVersion(10, newTestNativeCommand(0, "echo", 100, "v1:")), nil)
+	assert.Equal(t, c.AddVersion(10, newTestNativeCommand(0, "echo", 100, "v2:")), errVersionHeight)
+
+	invalid := newTestNativeCommand(1, "echo", 100, "v2:")
+	invalid.Input = "int"
+	assert.Equal(t, c.AddVersion(20, invalid), errInvalidCommandType)
+
+	assert.Equal(t, c.AddVersion(20, newTestNativeCommand(0, "echo", 200, "v2:"), newTestNativeCommand(1, "echo2", 100, "v2:")), nil)
+	assert.Equal(t, RegisterNativeContract(c.Deprecate(20)), errDeprecateHeight)
+	assert.Equal(t, RegisterNativeContract(c.Deprecate(30)), nil)
+
+	// not activated
+	assert.Equal(t, GetContractByHeight(address, 9), nil)
+	assert.Equal(t, c.Schema(9) == nil, true)
+
+	db, dispose := leveldb.NewTestDatabase()
+	defer dispose()
+	context := newTestContext(db, address)
+
+	// version 1
+	v1 := GetContractByHeight(address, 10)
+	assert.Equal(t, v1.RequiredGas([]byte{0}), uint64(100))
+	assert.Equal(t, v1.RequiredGas([]byte{1}), gasInvalidCommand)
+	result, err := v1.Run([]byte{0, 'a'}, context)
+	assert.Equal(t, err, nil)
+	assert.Equal(t, result, []byte("v1:a"))
+
+	// version 2
+	v2 := GetContractByHeight(address, 29)
+	assert.Equal(t, v2.RequiredGas([]byte{0}), uint64(200))
+	result, err = v2.Run([]byte{1, 'b'}, context)
+	assert.Equal(t, err, nil)
+	assert.Equal(t, result, []byte("v2:b"))
+	assert.Equal(t, GetContractByAddress(address), v2)
+
+	schema := c.Schema(29)
+	assert.Equal(t, schema.ActivationHeight, uint64(20))
+	assert.Equal(t, schema.DeprecateHeight, uint64(30))
+	assert.Equal(t, len(schema.Commands), 2)
+	assert.Equal(t, len(GetNativeContractSchemas(29)), 6)
+
+	// deprecated
+	deprecated := GetContractByHeight(address, 30)
+	result, err = deprecated.Run([]byte{0, 'c'}, context)
+	assert.Equal(t, err, errContractNotActive)
+	assert.Equal(t, result, []byte(nil))
+	assert.Equal(t, len(GetNativeContractSchemas(30)), 5)
+}
+
+func Test_EncodeCommandInput(t *testing.T) {
+	addr := *crypto.MustGenerateShardAddress(1)
+
+	encoded, err := EncodeCommandInput(TypeAddress, addr.Hex())
+	assert.Equal(t, err, nil)
+	assert.Equal(t, encoded, addr.Bytes())
+
+	decoded, err := DecodeCommandOutput(TypeAddress, encoded)
+	assert.Equal(t, err, nil)
+	assert.Equal(t, decoded, addr.Hex())
+
+	encoded, err = EncodeCommandInput(TypeJSON, `{"name":"test"}`)
+	assert.Equal(t, err, nil)
+	decoded, err = DecodeCommandOutput(TypeJSON, encoded)
+	assert.Equal(t, err, nil)
+	assert.Equal(t, decoded, map[string]interface{}{"name": "test"})
+
+	_, err = EncodeCommandInput(TypeJSON, "{")
+	assert.NotEqual(t, err, nil)
+
+	encoded, err = EncodeCommandInput(TypeBytes, "0x0102")
+	assert.Equal(t, err, nil)
+	assert.Equal(t, encoded, []byte{1, 2})
+
+	_, err = EncodeCommandInput("int", "1")
+	assert.Equal(t, err, errInvalidCommandType)
+}
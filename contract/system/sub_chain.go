@@ -7,9 +7,12 @@ package system
 
 import (
 	"encoding/json"
+	"errors"
+	"fmt"
 	"math/big"
 
 	"github.com/elcn233/go-scdo/common"
+	"github.com/elcn233/go-scdo/core/types"
 	"github.com/elcn233/go-scdo/p2p/discovery"
 )
 
@@ -18,16 +21,88 @@ const (
 	CmdSubChainRegister byte = iota
 	// CmdSubChainQuery query a sub-chain.
 	CmdSubChainQuery
+	// CmdSubChainUpdate update the version, static nodes and initial validators of a sub-chain by the owner.
+	CmdSubChainUpdate
+	// CmdSubChainDeprecate deprecate a sub-chain by the owner.
+	CmdSubChainDeprecate
+	// CmdSubChainAnchor anchor a sub-chain state root signed by its validators.
+	CmdSubChainAnchor
+	// CmdSubChainDeposit lock the tx amount for the withdrawals of a sub-chain.
+	CmdSubChainDeposit
+	// CmdSubChainWithdraw withdraw the funds exited from a sub-chain with account proof.
+	CmdSubChainWithdraw
+	// CmdSubChainGetAnchor query an anchored sub-chain state root.
+	CmdSubChainGetAnchor
+	// CmdSubChainRotateValidators rotate the validators of a sub-chain signed by its validators.
+	CmdSubChainRotateValidators
 
-	gasSubChainRegister = uint64(100000) // gas to register a sub-chain.
-	gasSubChainQuery    = uint64(200000) // gas to query sub-chain information.
+	gasSubChainRegister  = uint64(100000) // gas to register a sub-chain.
+	gasSubChainQuery     = uint64(200000) // gas to query sub-chain information.
+	gasSubChainUpdate    = uint64(50000)  // gas to update a sub-chain.
+	gasSubChainDeprecate = uint64(50000)  // gas to deprecate a sub-chain.
+	gasSubChainAnchor    = uint64(100000) // gas to anchor a sub-chain state root.
+	gasSubChainDeposit   = uint64(50000)  // gas to deposit for a sub-chain.
+	gasSubChainWithdraw  = uint64(100000) // gas to withdraw from a sub-chain.
+	gasSubChainGetAnchor = uint64(50000)  // gas to query an anchored state root.
+	gasSubChainRotate    = uint64(100000) // gas to rotate the validators of a sub-chain.
 )
 
+// SubChainEventsABI is the ABI of the events logged by the sub-chain contract,
+// the sub-chain nodes listen to them on the main chain.
+const SubChainEventsABI = `[
+	{"anonymous":false,"inputs":[{"indexed":false,"name":"name","type":"string"}],"name":"SubChainRegistered","type":"event"},
+	{"anonymous":false,"inputs":[{"indexed":false,"name":"name","type":"string"},{"indexed":false,"name":"version","type":"string"}],"name":"SubChainUpdated","type":"event"},
+	{"anonymous":false,"inputs":[{"indexed":false,"name":"name","type":"string"}],"name":"SubChainDeprecated","type":"event"},
+	{"anonymous":false,"inputs":[{"indexed":false,"name":"name","type":"string"},{"indexed":false,"name":"height","type":"uint64"}],"name":"SubChainAnchored","type":"event"},
+	{"anonymous":false,"inputs":[{"indexed":false,"name":"name","type":"string"},{"indexed":false,"name":"account","type":"string"},{"indexed":false,"name":"amount","type":"uint256"}],"name":"SubChainDeposited","type":"event"},
+	{"anonymous":false,"inputs":[{"indexed":false,"name":"name","type":"string"},{"indexed":false,"name":"account","type":"string"},{"indexed":false,"name":"amount","type":"uint256"}],"name":"SubChainWithdrawn","type":"event"},
+	{"anonymous":false,"inputs":[{"indexed":false,"name":"name","type":"string"},{"indexed":false,"name":"activeTime","type":"uint64"}],"name":"SubChainValidatorsRotated","type":"event"}
+]`
+
 var (
-	subChainCommands = map[byte]*cmdInfo{
-		CmdSubChainRegister: &cmdInfo{gasSubChainRegister, registerSubChain},
+	// subChainCommandsV0 are the original commands before SubChainForkHeight, which
+	// only register and query the sub-chains without logs
+	subChainCommandsV0 = map[byte]*cmdInfo{
+		CmdSubChainRegister: &cmdInfo{gasSubChainRegister, registerSubChainV0},
 		CmdSubChainQuery:    &cmdInfo{gasSubChainQuery, querySubChain},
 	}
+
+	subChainSchemaV0 = []CommandSchema{
+		{ID: CmdSubChainRegister, Name: "register", Input: TypeJSON, Output: TypeNone},
+		{ID: CmdSubChainQuery, Name: "query", Input: TypeString, Output: TypeJSON, ReadOnly: true},
+	}
+
+	subChainCommands = map[byte]*cmdInfo{
+		CmdSubChainRegister:         &cmdInfo{gasSubChainRegister, registerSubChain},
+		CmdSubChainQuery:            &cmdInfo{gasSubChainQuery, querySubChain},
+		CmdSubChainUpdate:           &cmdInfo{gasSubChainUpdate, updateSubChain},
+		CmdSubChainDeprecate:        &cmdInfo{gasSubChainDeprecate, deprecateSubChain},
+		CmdSubChainAnchor:           &cmdInfo{gasSubChainAnchor, anchorSubChain},
+		CmdSubChainDeposit:          &cmdInfo{gasSubChainDeposit, depositSubChain},
+		CmdSubChainWithdraw:         &cmdInfo{gasSubChainWithdraw, withdrawSubChain},
+		CmdSubChainGetAnchor:        &cmdInfo{gasSubChainGetAnchor, getSubChainAnchor},
+		CmdSubChainRotateValidators: &cmdInfo{gasSubChainRotate, rotateSubChainValidators},
+	}
+
+	// subChainSchema is the command schema of subChainCommands for clients to generate calls
+	subChainSchema = []CommandSchema{
+		{ID: CmdSubChainRegister, Name: "register", Input: TypeJSON, Output: TypeNone},
+		{ID: CmdSubChainQuery, Name: "query", Input: TypeString, Output: TypeJSON, ReadOnly: true},
+		{ID: CmdSubChainUpdate, Name: "update", Input: TypeJSON, Output: TypeNone},
+		{ID: CmdSubChainDeprecate, Name: "deprecate", Input: TypeString, Output: TypeNone},
+		{ID: CmdSubChainAnchor, Name: "anchor", Input: TypeJSON, Output: TypeNone},
+		{ID: CmdSubChainDeposit, Name: "deposit", Input: TypeString, Output: TypeNone},
+		{ID: CmdSubChainWithdraw, Name: "withdraw", Input: TypeJSON, Output: TypeNone},
+		{ID: CmdSubChainGetAnchor, Name: "getAnchor", Input: TypeJSON, Output: TypeJSON, ReadOnly: true},
+		{ID: CmdSubChainRotateValidators, Name: "rotateValidators", Input: TypeJSON, Output: TypeNone},
+	}
+
+	subChainEvents = mustParseABI(SubChainEventsABI)
+
+	errSubChainNotFound   = errors.New("sub-chain not found")
+	errSubChainNotOwner   = errors.New("only the owner is allowed to update the sub-chain")
+	errSubChainDeprecated = errors.New("sub-chain is deprecated")
+	errSubChainValidators = errors.New("validators of the sub-chain could only be rotated by the validators")
 )
 
 // SubChainInfo represents the sub-chain registration information.
@@ -46,6 +121,31 @@ type SubChainInfo struct {
 
 	// SubChain owner publick key
 	Owner common.Address `json:"owner,omitempty"`
+
+	// Validators the accounts to sign the sub-chain state roots anchored on the main chain
+	Validators []common.Address `json:"validators,omitempty"`
+
+	// PendingValidators the validators rotated by the validators, which replace the
+	// validators at PendingValidatorsTime
+	PendingValidators     []common.Address `json:"pendingValidators,omitempty"`
+	PendingValidatorsTime uint64           `json:"pendingValidatorsTime,omitempty"`
+
+	// ValidatorsNonce the number of validator rotations, which is signed with the rotation
+	ValidatorsNonce uint64 `json:"validatorsNonce,omitempty"`
+
+	// Deprecated the sub-chain is deprecated by the owner, its name is not reusable
+	Deprecated         bool     `json:"deprecated,omitempty"`
+	DeprecateTimestamp *big.Int `json:"deprecateTimestamp,omitempty"`
+}
+
+// SubChainUpdateRequest is the request to update the version, static nodes and validators
+// of a sub-chain, the static nodes and validators are replaced if not empty. The validators
+// are only set if the sub-chain has none, then they are rotated by the validators.
+type SubChainUpdateRequest struct {
+	Name        string            `json:"name"`
+	Version     string            `json:"version"`
+	StaticNodes []*discovery.Node `json:"staticNodes"`
+	Validators  []common.Address  `json:"validators,omitempty"`
 }
 
 func registerSubChain(jsonRegInfo []byte, context *Context) ([]byte, error) {
@@ -54,35 +154,195 @@ func registerSubChain(jsonRegInfo []byte, context *Context) ([]byte, error) {
 		return nil, err
 	}
 
+	if err := createSubChain(&info, context); err != nil {
+		return nil, err
+	}
+
+	if err := addSubChainLog(context, "SubChainRegistered", info.Name); err != nil {
+		return nil, err
+	}
+
+	return nil, nil
+}
+
+// registerSubChainV0 registers a sub-chain without validators and log as before SubChainForkHeight
+func registerSubChainV0(jsonRegInfo []byte, context *Context) ([]byte, error) {
+	var info SubChainInfo
+	if err := json.Unmarshal(jsonRegInfo, &info); err != nil {
+		return nil, err
+	}
+
+	info.Validators = nil
+	if err := createSubChain(&info, context); err != nil {
+		return nil, err
+	}
+
+	return nil, nil
+}
+
+// createSubChain validates and saves the registration information of a new sub-chain
+func createSubChain(info *SubChainInfo, context *Context) error {
 	key, err := domainNameToKey([]byte(info.Name))
 	if err != nil {
-		return nil, err
+		return err
 	}
 
 	if value := context.statedb.GetData(SubChainContractAddress, key); len(value) > 0 {
-		return nil, errExists
+		return errExists
 	}
 
 	// validate the reg info
 	if len(info.Version) == 0 || len(info.TokenFullName) == 0 || len(info.TokenShortName) == 0 || info.TokenAmount == 0 {
-		return nil, errInvalidSubChainInfo
+		return errInvalidSubChainInfo
 	}
 
 	// set transaction sender to subchain owner
 	info.Owner = context.tx.Data.From
 	info.CreateTimestamp = context.BlockHeader.CreateTimestamp
+	info.Deprecated, info.DeprecateTimestamp = false, nil
+	info.PendingValidators, info.PendingValidatorsTime, info.ValidatorsNonce = nil, 0, 0
 
 	value, err := json.MarshalIndent(info, "", "\t")
 	if err != nil {
-		return nil, err
+		return err
 	}
 
 	context.statedb.CreateAccount(SubChainContractAddress)
 	context.statedb.SetData(SubChainContractAddress, key, value)
 
+	return nil
+}
+
+func updateSubChain(input []byte, context *Context) ([]byte, error) {
+	var request SubChainUpdateRequest
+	if err := json.Unmarshal(input, &request); err != nil {
+		return nil, err
+	}
+
+	if len(request.Version) == 0 {
+		return nil, errInvalidSubChainInfo
+	}
+
+	info, err := getOwnedSubChain(request.Name, context)
+	if err != nil {
+		return nil, err
+	}
+
+	info.Version = request.Version
+	if len(request.StaticNodes) > 0 {
+		info.StaticNodes = request.StaticNodes
+	}
+
+	if len(request.Validators) > 0 {
+		// the deposits are withdrawn with the anchors signed by the validators,
+		// so that the owner is not allowed to replace them
+		if len(info.Validators) > 0 {
+			return nil, errSubChainValidators
+		}
+
+		info.Validators = request.Validators
+	}
+
+	if err = saveSubChain(info, context); err != nil {
+		return nil, err
+	}
+
+	if err = addSubChainLog(context, "SubChainUpdated", info.Name, info.Version); err != nil {
+		return nil, err
+	}
+
+	return nil, nil
+}
+
+func deprecateSubChain(name []byte, context *Context) ([]byte, error) {
+	info, err := getOwnedSubChain(string(name), context)
+	if err != nil {
+		return nil, err
+	}
+
+	info.Deprecated = true
+	info.DeprecateTimestamp = context.BlockHeader.CreateTimestamp
+	if err = saveSubChain(info, context); err != nil {
+		return nil, err
+	}
+
+	if err = addSubChainLog(context, "SubChainDeprecated", info.Name); err != nil {
+		return nil, err
+	}
+
 	return nil, nil
 }
 
+// getSubChain returns the registered sub-chain of the specified name
+func getSubChain(name string, context *Context) (*SubChainInfo, error) {
+	key, err := domainNameToKey([]byte(name))
+	if err != nil {
+		return nil, err
+	}
+
+	value := context.statedb.GetData(SubChainContractAddress, key)
+	if len(value) == 0 {
+		return nil, errSubChainNotFound
+	}
+
+	var info SubChainInfo
+	if err = json.Unmarshal(value, &info); err != nil {
+		return nil, err
+	}
+
+	return &info, nil
+}
+
+// getOwnedSubChain returns the sub-chain which is owned by the tx sender and not deprecated
+func getOwnedSubChain(name string, context *Context) (*SubChainInfo, error) {
+	info, err := getSubChain(name, context)
+	if err != nil {
+		return nil, err
+	}
+
+	if !info.Owner.Equal(context.tx.Data.From) {
+		return nil, errSubChainNotOwner
+	}
+
+	if info.Deprecated {
+		return nil, errSubChainDeprecated
+	}
+
+	return info, nil
+}
+
+func saveSubChain(info *SubChainInfo, context *Context) error {
+	key, err := domainNameToKey([]byte(info.Name))
+	if err != nil {
+		return err
+	}
+
+	value, err := json.MarshalIndent(info, "", "\t")
+	if err != nil {
+		return err
+	}
+
+	context.statedb.SetData(SubChainContractAddress, key, value)
+	return nil
+}
+
+func addSubChainLog(context *Context, name string, args ...interface{}) error {
+	event := subChainEvents.Events[name]
+	data, err := event.Inputs.Pack(args...)
+	if err != nil {
+		return fmt.Errorf("Failed to pack the log of %s, %s", name, err)
+	}
+
+	context.statedb.AddLog(&types.Log{
+		Address:     SubChainContractAddress,
+		Topics:      []common.Hash{event.Id()},
+		Data:        data,
+		BlockNumber: context.BlockHeader.Height,
+	})
+
+	return nil
+}
+
 func querySubChain(subChainName []byte, context *Context) ([]byte, error) {
 	key, err := domainNameToKey(subChainName)
 	if err != nil {
@@ -0,0 +1,373 @@
+/**
+* @file
+* @copyright defined in scdo/LICENSE
+ */
+
+package system
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"math/big"
+
+	"github.com/elcn233/go-scdo/common"
+	"github.com/elcn233/go-scdo/core/state"
+	"github.com/elcn233/go-scdo/crypto"
+	"github.com/elcn233/go-scdo/trie"
+)
+
+var (
+	keySubChainAnchor       = []byte("SubChain-Anchor")
+	keySubChainAnchorHeight = []byte("SubChain-AnchorHeight")
+	keySubChainPool         = []byte("SubChain-Pool")
+	keySubChainWithdrawn    = []byte("SubChain-Withdrawn")
+	keySubChainExit         = []byte("SubChain-Exit")
+	keySubChainValidators   = []byte("SubChain-Validators")
+
+	errSubChainNoValidators      = errors.New("sub-chain has no validators")
+	errSubChainNotEnoughSigs     = errors.New("not enough validator signatures")
+	errSubChainAnchorHeight      = errors.New("anchor height is not greater than the last anchored height")
+	errSubChainAnchorNotFound    = errors.New("sub-chain anchor not found")
+	errSubChainInvalidDeposit    = errors.New("deposit amount should be greater than 0")
+	errSubChainNothingToWithdraw = errors.New("nothing to withdraw")
+	errSubChainInsufficientPool  = errors.New("insufficient deposits of sub-chain to withdraw")
+	errSubChainValidatorsNonce   = errors.New("validators nonce mismatch")
+)
+
+// SubChainValidatorsDelay is the delay in seconds before the rotated validators of a
+// sub-chain sign the anchors, so that the depositors could withdraw before.
+const SubChainValidatorsDelay = uint64(7 * 24 * 3600)
+
+// SubChainAnchor is the sub-chain block state root anchored on the main chain.
+type SubChainAnchor struct {
+	Height    uint64      `json:"height"`
+	BlockHash common.Hash `json:"blockHash"`
+	StateRoot common.Hash `json:"stateRoot"`
+	Timestamp *big.Int    `json:"timestamp,omitempty"`
+}
+
+// SubChainAnchorRequest is the request to anchor a sub-chain state root, which
+// should be signed by more than 2/3 validators of the sub-chain.
+type SubChainAnchorRequest struct {
+	Name       string         `json:"name"`
+	Height     uint64         `json:"height"`
+	BlockHash  common.Hash    `json:"blockHash"`
+	StateRoot  common.Hash    `json:"stateRoot"`
+	Signatures []common.Bytes `json:"signatures"`
+}
+
+// SubChainValidatorsRequest is the request to rotate the validators of a sub-chain, which
+// should be signed by more than 2/3 validators of the sub-chain. The nonce is the current
+// ValidatorsNonce of the sub-chain, so that a rotation could not be replayed.
+type SubChainValidatorsRequest struct {
+	Name       string           `json:"name"`
+	Nonce      uint64           `json:"nonce"`
+	Validators []common.Address `json:"validators"`
+	Signatures []common.Bytes   `json:"signatures"`
+}
+
+// SubChainAnchorQuery is the request to query an anchor of sub-chain,
+// the latest anchor is returned if height is 0.
+type SubChainAnchorQuery struct {
+	Name   string `json:"name"`
+	Height uint64 `json:"height"`
+}
+
+// SubChainWithdrawRequest is the request to withdraw the funds exited from a sub-chain.
+// The proof is the account proof of the exit address of tx sender against the state root
+// anchored at the specified height.
+type SubChainWithdrawRequest struct {
+	Name   string           `json:"name"`
+	Height uint64           `json:"height"`
+	Proof  []trie.ProofNode `json:"proof"`
+}
+
+// SubChainAnchorHash returns the hash of anchor for the validators to sign.
+func SubChainAnchorHash(name string, height uint64, blockHash, stateRoot common.Hash) common.Hash {
+	return crypto.HashBytes([]byte(name), uint64Bytes(height), blockHash.Bytes(), stateRoot.Bytes())
+}
+
+// SubChainValidatorsHash returns the hash of the validators rotation for the validators to sign.
+func SubChainValidatorsHash(name string, nonce uint64, validators []common.Address) common.Hash {
+	data := [][]byte{keySubChainValidators, []byte(name), uint64Bytes(nonce)}
+	for _, validator := range validators {
+		data = append(data, validator.Bytes())
+	}
+
+	return crypto.HashBytes(data...)
+}
+
+// SubChainExitAddress returns the exit address on sub-chain of the specified main chain account.
+// The funds transferred to the exit address on sub-chain could be withdrawn by the account
+// on the main chain once the state root is anchored. Nobody owns the private key of the exit
+// address, so the funds are locked on sub-chain forever.
+func SubChainExitAddress(account common.Address) common.Address {
+	hash := crypto.HashBytes(keySubChainExit, account.Bytes())
+
+	var addr common.Address
+	copy(addr[:], hash[common.HashLength-common.AddressLen:])
+	copy(addr[:common.ShardByte], account[:common.ShardByte])
+	addr[common.AddressLen-1] &= 0xF0
+	addr[common.AddressLen-1] |= byte(common.AddressTypeExternal)
+
+	return addr
+}
+
+func anchorSubChain(input []byte, context *Context) ([]byte, error) {
+	var request SubChainAnchorRequest
+	if err := json.Unmarshal(input, &request); err != nil {
+		return nil, err
+	}
+
+	info, err := getSubChainWithValidators(request.Name, context)
+	if err != nil {
+		return nil, err
+	}
+
+	if request.Height <= getSubChainAnchorHeight(request.Name, context) {
+		return nil, errSubChainAnchorHeight
+	}
+
+	hash := SubChainAnchorHash(request.Name, request.Height, request.BlockHash, request.StateRoot)
+	if !hasSubChainQuorum(info.Validators, hash, request.Signatures) {
+		return nil, errSubChainNotEnoughSigs
+	}
+
+	anchor := SubChainAnchor{
+		Height:    request.Height,
+		BlockHash: request.BlockHash,
+		StateRoot: request.StateRoot,
+		Timestamp: context.BlockHeader.CreateTimestamp,
+	}
+
+	value, err := json.Marshal(&anchor)
+	if err != nil {
+		return nil, err
+	}
+
+	heightKey := crypto.HashBytes(keySubChainAnchorHeight, []byte(request.Name))
+	context.statedb.SetData(SubChainContractAddress, subChainAnchorKey(request.Name, request.Height), value)
+	context.statedb.SetData(SubChainContractAddress, heightKey, uint64Bytes(request.Height))
+
+	if err = addSubChainLog(context, "SubChainAnchored", request.Name, request.Height); err != nil {
+		return nil, err
+	}
+
+	return nil, nil
+}
+
+// rotateSubChainValidators replaces the validators of the sub-chain after SubChainValidatorsDelay,
+// the anchors are signed by the current validators until then. A rotation replaces the pending one.
+func rotateSubChainValidators(input []byte, context *Context) ([]byte, error) {
+	var request SubChainValidatorsRequest
+	if err := json.Unmarshal(input, &request); err != nil {
+		return nil, err
+	}
+
+	if len(request.Validators) == 0 {
+		return nil, errInvalidSubChainInfo
+	}
+
+	info, err := getSubChainWithValidators(request.Name, context)
+	if err != nil {
+		return nil, err
+	}
+
+	if request.Nonce != info.ValidatorsNonce {
+		return nil, errSubChainValidatorsNonce
+	}
+
+	hash := SubChainValidatorsHash(request.Name, request.Nonce, request.Validators)
+	if !hasSubChainQuorum(info.Validators, hash, request.Signatures) {
+		return nil, errSubChainNotEnoughSigs
+	}
+
+	info.PendingValidators = request.Validators
+	info.PendingValidatorsTime = blockTime(context) + SubChainValidatorsDelay
+	info.ValidatorsNonce++
+	if err = saveSubChain(info, context); err != nil {
+		return nil, err
+	}
+
+	if err = addSubChainLog(context, "SubChainValidatorsRotated", info.Name, info.PendingValidatorsTime); err != nil {
+		return nil, err
+	}
+
+	return nil, nil
+}
+
+// getSubChainWithValidators returns the sub-chain with the current validators, the
+// pending validators replace the validators once their time is reached.
+func getSubChainWithValidators(name string, context *Context) (*SubChainInfo, error) {
+	info, err := getSubChain(name, context)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(info.PendingValidators) > 0 && blockTime(context) >= info.PendingValidatorsTime {
+		info.Validators = info.PendingValidators
+		info.PendingValidators, info.PendingValidatorsTime = nil, 0
+		if err = saveSubChain(info, context); err != nil {
+			return nil, err
+		}
+	}
+
+	if len(info.Validators) == 0 {
+		return nil, errSubChainNoValidators
+	}
+
+	return info, nil
+}
+
+// hasSubChainQuorum returns true if more than 2/3 distinct validators signed the hash
+func hasSubChainQuorum(validators []common.Address, hash common.Hash, signatures []common.Bytes) bool {
+	signed := make(map[common.Address]bool)
+	for _, sig := range signatures {
+		signature := crypto.Signature{Sig: sig}
+		for _, validator := range validators {
+			if !signed[validator] && signature.Verify(validator, hash.Bytes()) {
+				signed[validator] = true
+				break
+			}
+		}
+	}
+
+	return len(signed)*3 > len(validators)*2
+}
+
+func depositSubChain(name []byte, context *Context) ([]byte, error) {
+	info, err := getSubChain(string(name), context)
+	if err != nil {
+		return nil, err
+	}
+
+	// the deposits are only withdrawn with the anchors signed by the validators
+	if len(info.Validators) == 0 {
+		return nil, errSubChainNoValidators
+	}
+
+	if info.Deprecated {
+		return nil, errSubChainDeprecated
+	}
+
+	amount := context.tx.Data.Amount
+	if amount == nil || amount.Sign() <= 0 {
+		return nil, errSubChainInvalidDeposit
+	}
+
+	pool := getSubChainBigInt(context, keySubChainPool, name)
+	setSubChainBigInt(context, new(big.Int).Add(pool, amount), keySubChainPool, name)
+
+	from := context.tx.Data.From
+	if err = addSubChainLog(context, "SubChainDeposited", info.Name, from.Hex(), amount); err != nil {
+		return nil, err
+	}
+
+	return nil, nil
+}
+
+func withdrawSubChain(input []byte, context *Context) ([]byte, error) {
+	var request SubChainWithdrawRequest
+	if err := json.Unmarshal(input, &request); err != nil {
+		return nil, err
+	}
+
+	anchor, err := getAnchor(request.Name, request.Height, context)
+	if err != nil {
+		return nil, err
+	}
+
+	// the exited amount is the balance of exit address on sub-chain
+	from := context.tx.Data.From
+	_, exited, err := state.VerifyAccountProof(anchor.StateRoot, SubChainExitAddress(from), trie.NodesToProof(request.Proof))
+	if err != nil {
+		return nil, err
+	}
+
+	name, account := []byte(request.Name), from.Bytes()
+	withdrawn := getSubChainBigInt(context, keySubChainWithdrawn, name, account)
+	amount := new(big.Int).Sub(exited, withdrawn)
+	if amount.Sign() <= 0 {
+		return nil, errSubChainNothingToWithdraw
+	}
+
+	pool := getSubChainBigInt(context, keySubChainPool, name)
+	if pool.Cmp(amount) < 0 {
+		return nil, errSubChainInsufficientPool
+	}
+
+	setSubChainBigInt(context, exited, keySubChainWithdrawn, name, account)
+	setSubChainBigInt(context, new(big.Int).Sub(pool, amount), keySubChainPool, name)
+
+	context.statedb.SubBalance(SubChainContractAddress, amount)
+	context.statedb.AddBalance(from, amount)
+
+	if err = addSubChainLog(context, "SubChainWithdrawn", request.Name, from.Hex(), amount); err != nil {
+		return nil, err
+	}
+
+	return nil, nil
+}
+
+func getSubChainAnchor(input []byte, context *Context) ([]byte, error) {
+	var query SubChainAnchorQuery
+	if err := json.Unmarshal(input, &query); err != nil {
+		return nil, err
+	}
+
+	anchor, err := getAnchor(query.Name, query.Height, context)
+	if err != nil {
+		return nil, err
+	}
+
+	return json.Marshal(anchor)
+}
+
+// getAnchor returns the anchor of sub-chain at the specified height, or the latest one if height is 0
+func getAnchor(name string, height uint64, context *Context) (*SubChainAnchor, error) {
+	if height == 0 {
+		height = getSubChainAnchorHeight(name, context)
+	}
+
+	value := context.statedb.GetData(SubChainContractAddress, subChainAnchorKey(name, height))
+	if len(value) == 0 {
+		return nil, errSubChainAnchorNotFound
+	}
+
+	var anchor SubChainAnchor
+	if err := json.Unmarshal(value, &anchor); err != nil {
+		return nil, err
+	}
+
+	return &anchor, nil
+}
+
+func getSubChainAnchorHeight(name string, context *Context) uint64 {
+	value := context.statedb.GetData(SubChainContractAddress, crypto.HashBytes(keySubChainAnchorHeight, []byte(name)))
+	if len(value) != 8 {
+		return 0
+	}
+
+	return binary.BigEndian.Uint64(value)
+}
+
+func subChainAnchorKey(name string, height uint64) common.Hash {
+	return crypto.HashBytes(keySubChainAnchor, []byte(name), uint64Bytes(height))
+}
+
+func getSubChainBigInt(context *Context, prefix []byte, data ...[]byte) *big.Int {
+	key := crypto.HashBytes(append([][]byte{prefix}, data...)...)
+	return new(big.Int).SetBytes(context.statedb.GetData(SubChainContractAddress, key))
+}
+
+func setSubChainBigInt(context *Context, value *big.Int, prefix []byte, data ...[]byte) {
+	key := crypto.HashBytes(append([][]byte{prefix}, data...)...)
+	context.statedb.SetData(SubChainContractAddress, key, value.Bytes())
+}
+
+func uint64Bytes(v uint64) []byte {
+	buf := make([]byte, 8)
+	binary.BigEndian.PutUint64(buf, v)
+	return buf
+}
@@ -6,13 +6,16 @@
 package system
 
 import (
+	"crypto/ecdsa"
 	"encoding/json"
 	"math/big"
 	"testing"
 
 	"github.com/elcn233/go-scdo/common"
+	"github.com/elcn233/go-scdo/core/state"
 	"github.com/elcn233/go-scdo/crypto"
 	"github.com/elcn233/go-scdo/database/leveldb"
+	"github.com/elcn233/go-scdo/trie"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -65,3 +68,307 @@ func Test_RegisterSubChain(t *testing.T) {
 	assert.Equal(t, result, []byte(nil))
 	assert.Equal(t, err, errExists)
 }
+
+func Test_SubChainV0(t *testing.T) {
+	db, dispose := leveldb.NewTestDatabase()
+	defer dispose()
+
+	context := newTestContext(db, SubChainContractAddress)
+
+	// only the original commands before the fork height
+	v0 := GetContractByHeight(SubChainContractAddress, common.SubChainForkHeight-1)
+	assert.Equal(t, v0.RequiredGas([]byte{CmdSubChainUpdate}), gasInvalidCommand)
+
+	validator := *crypto.MustGenerateRandomAddress()
+	encoded, _ := json.Marshal(&SubChainInfo{Name: "test", Version: "1.0", TokenFullName: "TestCoin", TokenShortName: "TC", TokenAmount: 1000, Validators: []common.Address{validator}, Deprecated: true})
+	_, err := v0.Run(append([]byte{CmdSubChainRegister}, encoded...), context)
+	assert.Equal(t, err, nil)
+
+	// no log and the validators are not stored
+	assert.Equal(t, len(context.statedb.GetCurrentLogs()), 0)
+
+	result, err := v0.Run(append([]byte{CmdSubChainQuery}, "test"...), context)
+	assert.Equal(t, err, nil)
+	var info SubChainInfo
+	assert.Equal(t, json.Unmarshal(result, &info), nil)
+	assert.Equal(t, len(info.Validators), 0)
+	assert.Equal(t, info.Deprecated, false)
+
+	// registered with log after the fork height
+	v1 := GetContractByHeight(SubChainContractAddress, common.SubChainForkHeight)
+	encoded, _ = json.Marshal(&SubChainInfo{Name: "test2", Version: "1.0", TokenFullName: "TestCoin", TokenShortName: "TC", TokenAmount: 1000, Validators: []common.Address{validator}})
+	_, err = v1.Run(append([]byte{CmdSubChainRegister}, encoded...), context)
+	assert.Equal(t, err, nil)
+	assert.Equal(t, len(context.statedb.GetCurrentLogs()), 1)
+
+	info2, err := getSubChain("test2", context)
+	assert.Equal(t, err, nil)
+	assert.Equal(t, info2.Validators, []common.Address{validator})
+}
+
+func Test_UpdateSubChain(t *testing.T) {
+	db, dispose := leveldb.NewTestDatabase()
+	defer dispose()
+
+	context := newTestContext(db, SubChainContractAddress)
+	owner := context.tx.Data.From
+
+	encoded, _ := json.Marshal(&SubChainInfo{Name: "test", Version: "1.0", TokenFullName: "TestCoin", TokenShortName: "TC", TokenAmount: 1000})
+	_, err := registerSubChain(encoded, context)
+	assert.Equal(t, err, nil)
+
+	// update by others
+	request, _ := json.Marshal(&SubChainUpdateRequest{Name: "test", Version: "1.1"})
+	context.tx.Data.From = *crypto.MustGenerateShardAddress(1)
+	_, err = updateSubChain(request, context)
+	assert.Equal(t, err, errSubChainNotOwner)
+
+	context.tx.Data.From = owner
+	missing, _ := json.Marshal(&SubChainUpdateRequest{Name: "test2", Version: "1.1"})
+	_, err = updateSubChain(missing, context)
+	assert.Equal(t, err, errSubChainNotFound)
+
+	_, err = updateSubChain(request, context)
+	assert.Equal(t, err, nil)
+
+	result, err := querySubChain([]byte("test"), context)
+	assert.Equal(t, err, nil)
+	var info SubChainInfo
+	assert.Equal(t, json.Unmarshal(result, &info), nil)
+	assert.Equal(t, info.Version, "1.1")
+	assert.Equal(t, info.TokenAmount, uint64(1000))
+
+	// deprecate
+	context.tx.Data.From = *crypto.MustGenerateShardAddress(1)
+	_, err = deprecateSubChain([]byte("test"), context)
+	assert.Equal(t, err, errSubChainNotOwner)
+
+	context.tx.Data.From = owner
+	_, err = deprecateSubChain([]byte("test"), context)
+	assert.Equal(t, err, nil)
+
+	_, err = updateSubChain(request, context)
+	assert.Equal(t, err, errSubChainDeprecated)
+
+	_, err = deprecateSubChain([]byte("test"), context)
+	assert.Equal(t, err, errSubChainDeprecated)
+
+	// the name is not reusable
+	_, err = registerSubChain(encoded, context)
+	assert.Equal(t, err, errExists)
+
+	result, err = querySubChain([]byte("test"), context)
+	assert.Equal(t, err, nil)
+	assert.Equal(t, json.Unmarshal(result, &info), nil)
+	assert.Equal(t, info.Deprecated, true)
+	assert.Equal(t, info.DeprecateTimestamp, context.BlockHeader.CreateTimestamp)
+
+	// the events of register, update and deprecate
+	logs := context.statedb.GetCurrentLogs()
+	assert.Equal(t, len(logs), 3)
+	assert.Equal(t, logs[2].Topics[0], subChainEvents.Events["SubChainDeprecated"].Id())
+
+	args, err := subChainEvents.Events["SubChainUpdated"].Inputs.UnpackValues(logs[1].Data)
+	assert.Equal(t, err, nil)
+	assert.Equal(t, args, []interface{}{"test", "1.1"})
+}
+
+func Test_SubChainAnchorAndWithdraw(t *testing.T) {
+	db, dispose := leveldb.NewTestDatabase()
+	defer dispose()
+
+	context := newTestContext(db, SubChainContractAddress)
+	owner := context.tx.Data.From
+
+	var validators []common.Address
+	var keys []*ecdsa.PrivateKey
+	for i := 0; i < 4; i++ {
+		addr, key := crypto.MustGenerateShardKeyPair(1)
+		validators = append(validators, *addr)
+		keys = append(keys, key)
+	}
+
+	encoded, _ := json.Marshal(&SubChainInfo{Name: "test", Version: "1.0", TokenFullName: "TestCoin", TokenShortName: "TC", TokenAmount: 1000, Validators: validators})
+	_, err := registerSubChain(encoded, context)
+	assert.Equal(t, err, nil)
+
+	// the sub-chain state that the recipient exits 30 to main chain
+	subDB, subDispose := leveldb.NewTestDatabase()
+	defer subDispose()
+
+	recipient := *crypto.MustGenerateShardAddress(1)
+	subState := state.NewEmptyStatedb(subDB)
+	subState.CreateAccount(SubChainExitAddress(recipient))
+	subState.SetBalance(SubChainExitAddress(recipient), big.NewInt(30))
+	root, err := subState.Commit(subDB.NewBatch())
+	assert.Equal(t, err, nil)
+
+	proof, err := subState.GetAccountProof(SubChainExitAddress(recipient))
+	assert.Equal(t, err, nil)
+
+	// anchor with signatures of 2/4 validators, and a duplicated one
+	blockHash := crypto.HashBytes([]byte("block"))
+	hash := SubChainAnchorHash("test", 10, blockHash, root).Bytes()
+	request := SubChainAnchorRequest{Name: "test", Height: 10, BlockHash: blockHash, StateRoot: root}
+	for _, key := range keys[:2] {
+		request.Signatures = append(request.Signatures, crypto.MustSign(key, hash).Sig)
+	}
+	request.Signatures = append(request.Signatures, request.Signatures[0])
+
+	input, _ := json.Marshal(&request)
+	_, err = anchorSubChain(input, context)
+	assert.Equal(t, err, errSubChainNotEnoughSigs)
+
+	request.Signatures = append(request.Signatures, crypto.MustSign(keys[3], hash).Sig)
+	input, _ = json.Marshal(&request)
+	_, err = anchorSubChain(input, context)
+	assert.Equal(t, err, nil)
+
+	_, err = anchorSubChain(input, context)
+	assert.Equal(t, err, errSubChainAnchorHeight)
+
+	query, _ := json.Marshal(&SubChainAnchorQuery{Name: "test"})
+	result, err := getSubChainAnchor(query, context)
+	assert.Equal(t, err, nil)
+	var anchor SubChainAnchor
+	assert.Equal(t, json.Unmarshal(result, &anchor), nil)
+	assert.Equal(t, anchor.Height, uint64(10))
+	assert.Equal(t, anchor.StateRoot, root)
+
+	// withdraw without enough deposits
+	withdraw, _ := json.Marshal(&SubChainWithdrawRequest{Name: "test", Height: 10, Proof: trie.ProofToNodes(proof)})
+	context.tx.Data.From = recipient
+	_, err = withdrawSubChain(withdraw, context)
+	assert.Equal(t, err, errSubChainInsufficientPool)
+
+	context.tx.Data.From = owner
+	context.tx.Data.Amount = big.NewInt(100)
+	context.statedb.AddBalance(SubChainContractAddress, big.NewInt(100))
+	_, err = depositSubChain([]byte("test"), context)
+	assert.Equal(t, err, nil)
+
+	// withdraw once only
+	context.statedb.CreateAccount(recipient)
+	context.tx.Data.From = recipient
+	_, err = withdrawSubChain(withdraw, context)
+	assert.Equal(t, err, nil)
+	assert.Equal(t, context.statedb.GetBalance(recipient), big.NewInt(30))
+	assert.Equal(t, context.statedb.GetBalance(SubChainContractAddress), big.NewInt(70))
+
+	_, err = withdrawSubChain(withdraw, context)
+	assert.Equal(t, err, errSubChainNothingToWithdraw)
+
+	// others could not withdraw with the proof
+	context.tx.Data.From = owner
+	_, err = withdrawSubChain(withdraw, context)
+	assert.Equal(t, err, errSubChainNothingToWithdraw)
+
+	logs := context.statedb.GetCurrentLogs()
+	assert.Equal(t, logs[len(logs)-1].Topics[0], subChainEvents.Events["SubChainWithdrawn"].Id())
+}
+
+func Test_RotateSubChainValidators(t *testing.T) {
+	db, dispose := leveldb.NewTestDatabase()
+	defer dispose()
+
+	context := newTestContext(db, SubChainContractAddress)
+
+	var validators, rotated []common.Address
+	var keys, rotatedKeys []*ecdsa.PrivateKey
+	for i := 0; i < 4; i++ {
+		addr, key := crypto.MustGenerateShardKeyPair(1)
+		validators, keys = append(validators, *addr), append(keys, key)
+
+		addr, key = crypto.MustGenerateShardKeyPair(1)
+		rotated, rotatedKeys = append(rotated, *addr), append(rotatedKeys, key)
+	}
+
+	// no deposits without validators
+	encoded, _ := json.Marshal(&SubChainInfo{Name: "test", Version: "1.0", TokenFullName: "TestCoin", TokenShortName: "TC", TokenAmount: 1000})
+	_, err := registerSubChain(encoded, context)
+	assert.Equal(t, err, nil)
+
+	_, err = depositSubChain([]byte("test"), context)
+	assert.Equal(t, err, errSubChainNoValidators)
+
+	// the owner sets the validators only once
+	request, _ := json.Marshal(&SubChainUpdateRequest{Name: "test", Version: "1.1", Validators: validators})
+	_, err = updateSubChain(request, context)
+	assert.Equal(t, err, nil)
+
+	request, _ = json.Marshal(&SubChainUpdateRequest{Name: "test", Version: "1.2", Validators: rotated})
+	_, err = updateSubChain(request, context)
+	assert.Equal(t, err, errSubChainValidators)
+
+	_, err = depositSubChain([]byte("test"), context)
+	assert.Equal(t, err, nil)
+
+	// rotate with signatures of 2/4 validators, and with a wrong nonce
+	rotation := SubChainValidatorsRequest{Name: "test", Validators: rotated}
+	hash := SubChainValidatorsHash("test", 0, rotated).Bytes()
+	for _, key := range keys[:2] {
+		rotation.Signatures = append(rotation.Signatures, crypto.MustSign(key, hash).Sig)
+	}
+
+	input, _ := json.Marshal(&rotation)
+	_, err = rotateSubChainValidators(input, context)
+	assert.Equal(t, err, errSubChainNotEnoughSigs)
+
+	rotation.Signatures = append(rotation.Signatures, crypto.MustSign(keys[2], hash).Sig)
+	rotation.Nonce = 1
+	input, _ = json.Marshal(&rotation)
+	_, err = rotateSubChainValidators(input, context)
+	assert.Equal(t, err, errSubChainValidatorsNonce)
+
+	rotation.Nonce = 0
+	input, _ = json.Marshal(&rotation)
+	_, err = rotateSubChainValidators(input, context)
+	assert.Equal(t, err, nil)
+
+	// the rotation could not be replayed
+	_, err = rotateSubChainValidators(input, context)
+	assert.Equal(t, err, errSubChainValidatorsNonce)
+
+	info, err := getSubChain("test", context)
+	assert.Equal(t, err, nil)
+	assert.Equal(t, info.Validators, validators)
+	assert.Equal(t, info.PendingValidators, rotated)
+	assert.Equal(t, info.PendingValidatorsTime, blockTime(context)+SubChainValidatorsDelay)
+	assert.Equal(t, info.ValidatorsNonce, uint64(1))
+
+	logs := context.statedb.GetCurrentLogs()
+	args, err := subChainEvents.Events["SubChainValidatorsRotated"].Inputs.UnpackValues(logs[len(logs)-1].Data)
+	assert.Equal(t, err, nil)
+	assert.Equal(t, args, []interface{}{"test", info.PendingValidatorsTime})
+
+	// the anchors are signed by the current validators before the delay
+	newAnchor := func(height uint64, keys []*ecdsa.PrivateKey) []byte {
+		anchor := SubChainAnchorRequest{Name: "test", Height: height}
+		hash := SubChainAnchorHash("test", height, common.EmptyHash, common.EmptyHash).Bytes()
+		for _, key := range keys[:3] {
+			anchor.Signatures = append(anchor.Signatures, crypto.MustSign(key, hash).Sig)
+		}
+
+		input, _ := json.Marshal(&anchor)
+		return input
+	}
+
+	_, err = anchorSubChain(newAnchor(10, rotatedKeys), context)
+	assert.Equal(t, err, errSubChainNotEnoughSigs)
+
+	_, err = anchorSubChain(newAnchor(10, keys), context)
+	assert.Equal(t, err, nil)
+
+	// the rotated validators sign the anchors after the delay
+	context.BlockHeader.CreateTimestamp = new(big.Int).SetUint64(info.PendingValidatorsTime)
+	_, err = anchorSubChain(newAnchor(20, keys), context)
+	assert.Equal(t, err, errSubChainNotEnoughSigs)
+
+	_, err = anchorSubChain(newAnchor(20, rotatedKeys), context)
+	assert.Equal(t, err, nil)
+
+	info, err = getSubChain("test", context)
+	assert.Equal(t, err, nil)
+	assert.Equal(t, info.Validators, rotated)
+	assert.Equal(t, len(info.PendingValidators), 0)
+}
@@ -0,0 +1,590 @@
+/**
+* @file
+* @copyright defined in scdo/LICENSE
+ */
+
+package system
+
+import (
+	"bytes"
+	"encoding/json"
+	"math/big"
+	"sort"
+
+	"github.com/elcn233/go-scdo/common"
+	"github.com/elcn233/go-scdo/consensus/istanbul"
+	"github.com/elcn233/go-scdo/core/state"
+	"github.com/elcn233/go-scdo/core/types"
+	"github.com/elcn233/go-scdo/crypto"
+	"github.com/pkg/errors"
+)
+
+const (
+	// CmdRegisterCandidate stake scdo and register as validator candidate
+	CmdRegisterCandidate byte = iota
+	// CmdUnregisterCandidate unregister the candidate and start unbonding the stake
+	CmdUnregisterCandidate
+	// CmdVote delegate scdo to a candidate
+	CmdVote
+	// CmdCancelVote cancel the delegation to a candidate and refund it
+	CmdCancelVote
+	// CmdQueryCandidate query a candidate
+	CmdQueryCandidate
+	// CmdQueryValidators query the validators elected by stake
+	CmdQueryValidators
+	// CmdWithdrawStake refund the stake of the unregistered candidate after the unbonding period
+	CmdWithdrawStake
+
+	gasCmdRegisterCandidate   = uint64(50000) // gas used to register a candidate
+	gasCmdUnregisterCandidate = uint64(50000) // gas used to unregister a candidate
+	gasCmdVote                = uint64(20000) // gas used to vote for a candidate
+	gasCmdCancelVote          = uint64(20000) // gas used to cancel a vote
+	gasCmdQueryCandidate      = uint64(5000)  // gas used to query a candidate
+	gasCmdQueryValidators     = uint64(20000) // gas used to query the elected validators
+	gasCmdWithdrawStake       = uint64(20000) // gas used to withdraw the stake
+)
+
+var (
+	// MaxElectedValidators is the max number of validators elected from the candidates
+	MaxElectedValidators = 21
+
+	// MaxCandidates is the max number of registered candidates, which bounds the cost to elect validators
+	MaxCandidates = 100
+
+	// candidateUnbondingPeriod is the number of blocks to wait before the stake of an unregistered
+	// candidate is withdrawable, in which the candidate could still be slashed
+	candidateUnbondingPeriod = uint64(8640 * 7)
+
+	candidateStakeLimit = big.NewInt(0).Mul(common.ScdoToWen, big.NewInt(20000))
+	keyCandidates       = common.BytesToHash([]byte("Istanbul-Candidates"))
+	keyRegistrations    = []byte("Istanbul-Registrations")
+	keyEvidences        = []byte("Istanbul-Evidences")
+)
+
+var (
+	ErrStakeNotEnough     = errors.New("stake amount is not enough")
+	ErrVoteAmountNotRight = errors.New("vote amount must be greater than 0")
+	ErrCandidateExist     = errors.New("this address is already a candidate")
+	ErrCandidateNotExist  = errors.New("this address is not a candidate")
+	ErrVoteNotExist       = errors.New("no vote for this candidate")
+	ErrStaleVote          = errors.New("cancel the vote for the previous registration of candidate first")
+	ErrTooManyCandidates  = errors.New("the number of candidates reaches the limit")
+	ErrCandidateUnbonding = errors.New("the candidate is unbonding")
+	ErrStakeLocked        = errors.New("the stake is locked in the unbonding period")
+	ErrEvidenceHandled    = errors.New("the evidence is handled already")
+
+	validatorCommands = map[byte]*cmdInfo{
+		CmdRegisterCandidate:   {gasCmdRegisterCandidate, registerCandidate},
+		CmdUnregisterCandidate: {gasCmdUnregisterCandidate, unregisterCandidate},
+		CmdVote:                {gasCmdVote, vote},
+		CmdCancelVote:          {gasCmdCancelVote, cancelVote},
+		CmdQueryCandidate:      {gasCmdQueryCandidate, queryCandidate},
+		CmdQueryValidators:     {gasCmdQueryValidators, queryValidators},
+		CmdWithdrawStake:       {gasCmdWithdrawStake, withdrawStake},
+	}
+
+	// validatorSchema is the command schema of validatorCommands for clients to generate calls
+	validatorSchema = []CommandSchema{
+		{ID: CmdRegisterCandidate, Name: "register", Input: TypeNone, Output: TypeNone},
+		{ID: CmdUnregisterCandidate, Name: "unregister", Input: TypeNone, Output: TypeNone},
+		{ID: CmdVote, Name: "vote", Input: TypeAddress, Output: TypeNone},
+		{ID: CmdCancelVote, Name: "cancelvote", Input: TypeAddress, Output: TypeNone},
+		{ID: CmdQueryCandidate, Name: "candidate", Input: TypeAddress, Output: TypeJSON, ReadOnly: true},
+		{ID: CmdQueryValidators, Name: "validators", Input: TypeNone, Output: TypeJSON, ReadOnly: true},
+		{ID: CmdWithdrawStake, Name: "withdraw", Input: TypeNone, Output: TypeNone},
+	}
+)
+
+// Candidate is a validator candidate registered in the validator contract.
+type Candidate struct {
+	Address        common.Address `json:"address"`
+	Stake          *big.Int       `json:"stake"` // amount staked by the candidate itself
+	Votes          *big.Int       `json:"votes"` // amount delegated by voters
+	RegisterHeight uint64         `json:"registerHeight"`
+	Registration   uint64         `json:"registration"` // sequence number of the registrations of the address
+	UnbondHeight   uint64         `json:"unbondHeight"` // height after which the stake is withdrawable, 0 if registered
+}
+
+// candidateVote is the amount voted by a voter for a registration of candidate.
+type candidateVote struct {
+	Amount       *big.Int
+	Registration uint64
+}
+
+// Weight returns the total amount backing the candidate.
+func (c *Candidate) Weight() *big.Int {
+	return new(big.Int).Add(c.Stake, c.Votes)
+}
+
+func registerCandidate(input []byte, context *Context) ([]byte, error) {
+	if context.tx.Data.Amount.Cmp(candidateStakeLimit) < 0 {
+		return nil, ErrStakeNotEnough
+	}
+
+	sender := context.tx.Data.From
+	candidate, err := getCandidate(sender, context.statedb)
+	if err != nil {
+		return nil, err
+	}
+
+	if candidate != nil && candidate.UnbondHeight > 0 {
+		return nil, ErrCandidateUnbonding
+	}
+
+	if candidate != nil {
+		return nil, ErrCandidateExist
+	}
+
+	// the votes for the previous registrations are not counted in the new one
+	registrationKey := crypto.HashBytes(keyRegistrations, sender.Bytes())
+	registration := new(big.Int).SetBytes(context.statedb.GetData(ValidatorContractAddress, registrationKey)).Uint64() + 1
+
+	candidate = &Candidate{
+		Address:        sender,
+		Stake:          new(big.Int).Set(context.tx.Data.Amount),
+		Votes:          big.NewInt(0),
+		RegisterHeight: context.BlockHeader.Height,
+		Registration:   registration,
+	}
+
+	addresses, err := getCandidateAddresses(context.statedb)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(addresses) >= MaxCandidates {
+		return nil, ErrTooManyCandidates
+	}
+
+	context.statedb.CreateAccount(ValidatorContractAddress)
+	context.statedb.SetData(ValidatorContractAddress, registrationKey, new(big.Int).SetUint64(registration).Bytes())
+	if err = saveCandidateAddresses(context.statedb, append(addresses, sender)); err != nil {
+		return nil, err
+	}
+
+	if err = saveCandidate(context.statedb, candidate); err != nil {
+		return nil, err
+	}
+
+	return nil, nil
+}
+
+// unregisterCandidate removes the candidate from election, and its stake is locked
+// for the unbonding period, so that the candidate is still slashable.
+func unregisterCandidate(input []byte, context *Context) ([]byte, error) {
+	sender := context.tx.Data.From
+	candidate, err := getCandidate(sender, context.statedb)
+	if err != nil {
+		return nil, err
+	}
+
+	if candidate == nil {
+		return nil, ErrCandidateNotExist
+	}
+
+	if candidate.UnbondHeight > 0 {
+		return nil, ErrCandidateUnbonding
+	}
+
+	if err = removeCandidateAddress(context.statedb, sender); err != nil {
+		return nil, err
+	}
+
+	// the votes are kept, delegators cancel them to get the refund.
+	candidate.UnbondHeight = context.BlockHeader.Height + candidateUnbondingPeriod
+	if err = saveCandidate(context.statedb, candidate); err != nil {
+		return nil, err
+	}
+
+	return nil, nil
+}
+
+// withdrawStake refunds the stake of the unregistered candidate after the unbonding period
+func withdrawStake(input []byte, context *Context) ([]byte, error) {
+	sender := context.tx.Data.From
+	candidate, err := getCandidate(sender, context.statedb)
+	if err != nil {
+		return nil, err
+	}
+
+	if candidate == nil {
+		return nil, ErrCandidateNotExist
+	}
+
+	if candidate.UnbondHeight == 0 || context.BlockHeader.Height < candidate.UnbondHeight {
+		return nil, ErrStakeLocked
+	}
+
+	context.statedb.SetData(ValidatorContractAddress, candidateKey(sender), nil)
+	context.statedb.SubBalance(ValidatorContractAddress, candidate.Stake)
+	context.statedb.AddBalance(sender, candidate.Stake)
+
+	return nil, nil
+}
+
+func vote(address []byte, context *Context) ([]byte, error) {
+	amount := context.tx.Data.Amount
+	if amount.Sign() <= 0 {
+		return nil, ErrVoteAmountNotRight
+	}
+
+	candidateAddr := common.BytesToAddress(address)
+	candidate, err := getCandidate(candidateAddr, context.statedb)
+	if err != nil {
+		return nil, err
+	}
+
+	if candidate == nil {
+		return nil, ErrCandidateNotExist
+	}
+
+	if candidate.UnbondHeight > 0 {
+		return nil, ErrCandidateUnbonding
+	}
+
+	key := voteKey(context.tx.Data.From, candidateAddr)
+	voted, err := getVote(context.statedb, key)
+	if err != nil {
+		return nil, err
+	}
+
+	if voted == nil {
+		voted = &candidateVote{big.NewInt(0), candidate.Registration}
+	} else if voted.Registration != candidate.Registration {
+		return nil, ErrStaleVote
+	}
+
+	candidate.Votes.Add(candidate.Votes, amount)
+	if err = saveCandidate(context.statedb, candidate); err != nil {
+		return nil, err
+	}
+
+	voted.Amount.Add(voted.Amount, amount)
+	if err = saveVote(context.statedb, key, voted); err != nil {
+		return nil, err
+	}
+
+	return nil, nil
+}
+
+func cancelVote(address []byte, context *Context) ([]byte, error) {
+	sender := context.tx.Data.From
+	candidateAddr := common.BytesToAddress(address)
+
+	key := voteKey(sender, candidateAddr)
+	voted, err := getVote(context.statedb, key)
+	if err != nil {
+		return nil, err
+	}
+
+	if voted == nil || voted.Amount.Sign() == 0 {
+		return nil, ErrVoteNotExist
+	}
+
+	candidate, err := getCandidate(candidateAddr, context.statedb)
+	if err != nil {
+		return nil, err
+	}
+
+	// the candidate may have withdrawn the stake already, or registered again
+	// that the vote is not counted in.
+	if candidate != nil && candidate.Registration == voted.Registration {
+		candidate.Votes.Sub(candidate.Votes, voted.Amount)
+		if candidate.Votes.Sign() < 0 {
+			candidate.Votes.SetInt64(0)
+		}
+
+		if err = saveCandidate(context.statedb, candidate); err != nil {
+			return nil, err
+		}
+	}
+
+	context.statedb.SetData(ValidatorContractAddress, key, nil)
+	context.statedb.SubBalance(ValidatorContractAddress, voted.Amount)
+	context.statedb.AddBalance(sender, voted.Amount)
+
+	return nil, nil
+}
+
+func queryCandidate(address []byte, context *Context) ([]byte, error) {
+	candidate, err := getCandidate(common.BytesToAddress(address), context.statedb)
+	if err != nil {
+		return nil, err
+	}
+
+	if candidate == nil {
+		return nil, ErrCandidateNotExist
+	}
+
+	return json.Marshal(candidate)
+}
+
+func queryValidators(input []byte, context *Context) ([]byte, error) {
+	validators, err := GetElectedValidators(context.statedb)
+	if err != nil {
+		return nil, err
+	}
+
+	return json.Marshal(validators)
+}
+
+// SlashEvidences slashes the validators accused by the evidences included in the block of
+// the given height, and the slashed stakes and deposits are burned. The evidences handled
+// already are skipped.
+func SlashEvidences(statedb *state.Statedb, evidences []*types.IstanbulEvidence, height uint64) error {
+	for _, evidence := range evidences {
+		if _, err := slashValidator(statedb, evidence, height); err != nil && err != ErrEvidenceHandled {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// slashValidator burns the stake of the candidate and the deposit of the masternode accused
+// by the evidence, and records the sequence of the evidence to prevent it from being replayed.
+// It returns the slashed amount, which is 0 if the validator has nothing to slash.
+func slashValidator(statedb *state.Statedb, evidence *types.IstanbulEvidence, height uint64) (*big.Int, error) {
+	sequence, err := istanbul.VerifyEvidence(evidence, height)
+	if err != nil {
+		return nil, err
+	}
+
+	key := crypto.HashBytes(keyEvidences, evidence.Validator.Bytes())
+	if handled := statedb.GetData(ValidatorContractAddress, key); new(big.Int).SetBytes(handled).Uint64() >= sequence {
+		return nil, ErrEvidenceHandled
+	}
+
+	slashed, err := slashMasternode(statedb, evidence.Validator, height)
+	if err != nil {
+		return nil, err
+	}
+
+	// the unbonding candidate is slashed too
+	candidate, err := getCandidate(evidence.Validator, statedb)
+	if err != nil {
+		return nil, err
+	}
+
+	if candidate != nil {
+		if candidate.UnbondHeight == 0 {
+			if err = removeCandidateAddress(statedb, evidence.Validator); err != nil {
+				return nil, err
+			}
+		}
+
+		statedb.SetData(ValidatorContractAddress, candidateKey(evidence.Validator), nil)
+		statedb.SubBalance(ValidatorContractAddress, candidate.Stake)
+		slashed.Add(slashed, candidate.Stake)
+	}
+
+	if slashed.Sign() > 0 {
+		statedb.CreateAccount(ValidatorContractAddress)
+		statedb.SetData(ValidatorContractAddress, key, new(big.Int).SetUint64(sequence).Bytes())
+	}
+
+	return slashed, nil
+}
+
+// GetCandidates returns all the registered candidates in the given statedb.
+func GetCandidates(statedb *state.Statedb) ([]*Candidate, error) {
+	addresses, err := getCandidateAddresses(statedb)
+	if err != nil {
+		return nil, err
+	}
+
+	candidates := make([]*Candidate, 0, len(addresses))
+	for _, addr := range addresses {
+		candidate, err := getCandidate(addr, statedb)
+		if err != nil {
+			return nil, err
+		}
+
+		if candidate != nil {
+			candidates = append(candidates, candidate)
+		}
+	}
+
+	return candidates, nil
+}
+
+// GetElectedValidators returns at most MaxElectedValidators candidates with the
+// highest weight in the given statedb. Candidates with the same weight are ordered
+// by address, so that all nodes elect the same validators. The candidates whose
+// masternode deposit is slashed are not elected.
+func GetElectedValidators(statedb *state.Statedb) ([]common.Address, error) {
+	candidates, err := electCandidates(statedb)
+	if err != nil {
+		return nil, err
+	}
+
+	validators := make([]common.Address, len(candidates))
+	for i, candidate := range candidates {
+		validators[i] = candidate.Address
+	}
+
+	return validators, nil
+}
+
+// GetElectedWeights returns the weights of the validators elected in the given statedb.
+func GetElectedWeights(statedb *state.Statedb) (map[common.Address]*big.Int, error) {
+	candidates, err := electCandidates(statedb)
+	if err != nil {
+		return nil, err
+	}
+
+	weights := make(map[common.Address]*big.Int, len(candidates))
+	for _, candidate := range candidates {
+		weights[candidate.Address] = candidate.Weight()
+	}
+
+	return weights, nil
+}
+
+// GetValidatorWeights returns the bonded stakes of the given validators, which are the
+// weights of validators to propose blocks. The stake of a candidate is the sum of its own
+// stake and the votes. The validators which are not registered or unbonding are not included.
+func GetValidatorWeights(statedb *state.Statedb, validators []common.Address) (map[common.Address]*big.Int, error) {
+	weights := make(map[common.Address]*big.Int)
+	for _, address := range validators {
+		candidate, err := getCandidate(address, statedb)
+		if err != nil {
+			return nil, err
+		}
+
+		if candidate != nil && candidate.UnbondHeight == 0 {
+			weights[address] = candidate.Weight()
+		}
+	}
+
+	return weights, nil
+}
+
+func electCandidates(statedb *state.Statedb) ([]*Candidate, error) {
+	registered, err := GetCandidates(statedb)
+	if err != nil {
+		return nil, err
+	}
+
+	candidates := make([]*Candidate, 0, len(registered))
+	for _, candidate := range registered {
+		slashed, err := IsSlashed(candidate.Address, statedb)
+		if err != nil {
+			return nil, err
+		}
+
+		if !slashed {
+			candidates = append(candidates, candidate)
+		}
+	}
+
+	sort.Slice(candidates, func(i, j int) bool {
+		if cmp := candidates[i].Weight().Cmp(candidates[j].Weight()); cmp != 0 {
+			return cmp > 0
+		}
+
+		return bytes.Compare(candidates[i].Address.Bytes(), candidates[j].Address.Bytes()) < 0
+	})
+
+	if len(candidates) > MaxElectedValidators {
+		candidates = candidates[:MaxElectedValidators]
+	}
+
+	return candidates, nil
+}
+
+func getCandidate(address common.Address, statedb *state.Statedb) (*Candidate, error) {
+	value := statedb.GetData(ValidatorContractAddress, candidateKey(address))
+	if len(value) == 0 {
+		return nil, nil
+	}
+
+	var candidate Candidate
+	if err := common.Deserialize(value, &candidate); err != nil {
+		return nil, err
+	}
+
+	return &candidate, nil
+}
+
+func saveCandidate(statedb *state.Statedb, candidate *Candidate) error {
+	value, err := common.Serialize(candidate)
+	if err != nil {
+		return err
+	}
+
+	statedb.SetData(ValidatorContractAddress, candidateKey(candidate.Address), value)
+	return nil
+}
+
+func getCandidateAddresses(statedb *state.Statedb) ([]common.Address, error) {
+	value := statedb.GetData(ValidatorContractAddress, keyCandidates)
+	if len(value) == 0 {
+		return nil, nil
+	}
+
+	var addresses []common.Address
+	if err := common.Deserialize(value, &addresses); err != nil {
+		return nil, err
+	}
+
+	return addresses, nil
+}
+
+// removeCandidateAddress removes the address from the registered candidates
+func removeCandidateAddress(statedb *state.Statedb, address common.Address) error {
+	addresses, err := getCandidateAddresses(statedb)
+	if err != nil {
+		return err
+	}
+
+	for i, addr := range addresses {
+		if addr == address {
+			return saveCandidateAddresses(statedb, append(addresses[:i], addresses[i+1:]...))
+		}
+	}
+
+	return nil
+}
+
+func saveCandidateAddresses(statedb *state.Statedb, addresses []common.Address) error {
+	value, err := common.Serialize(addresses)
+	if err != nil {
+		return err
+	}
+
+	statedb.SetData(ValidatorContractAddress, keyCandidates, value)
+	return nil
+}
+
+func candidateKey(address common.Address) common.Hash {
+	return crypto.MustHash(address)
+}
+
+func getVote(statedb *state.Statedb, key common.Hash) (*candidateVote, error) {
+	value := statedb.GetData(ValidatorContractAddress, key)
+	if len(value) == 0 {
+		return nil, nil
+	}
+
+	var voted candidateVote
+	if err := common.Deserialize(value, &voted); err != nil {
+		return nil, err
+	}
+
+	return &voted, nil
+}
+
+func saveVote(statedb *state.Statedb, key common.Hash, voted *candidateVote) error {
+	value, err := common.Serialize(voted)
+	if err != nil {
+		return err
+	}
+
+	statedb.SetData(ValidatorContractAddress, key, value)
+	return nil
+}
+
+func voteKey(voter, candidate common.Address) common.Hash {
+	return crypto.MustHash([]common.Address{voter, candidate})
+}
@@ -0,0 +1,378 @@
+/**
+* @file
+* @copyright defined in scdo/LICENSE
+ */
+
+package system
+
+import (
+	"encoding/json"
+	"math/big"
+	"testing"
+
+	"github.com/elcn233/go-scdo/common"
+	"github.com/elcn233/go-scdo/consensus/istanbul"
+	"github.com/elcn233/go-scdo/core/types"
+	"github.com/elcn233/go-scdo/crypto"
+	"github.com/elcn233/go-scdo/database/leveldb"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_RegisterCandidate(t *testing.T) {
+	db, dispose := leveldb.NewTestDatabase()
+	defer dispose()
+
+	context := newTestContext(db, ValidatorContractAddress)
+
+	// stake is not enough
+	context.tx.Data.Amount = big.NewInt(1)
+	_, err := registerCandidate(nil, context)
+	assert.Equal(t, err, ErrStakeNotEnough)
+
+	context.tx.Data.Amount = new(big.Int).Set(candidateStakeLimit)
+	_, err = registerCandidate(nil, context)
+	assert.Equal(t, err, nil)
+
+	// register again
+	_, err = registerCandidate(nil, context)
+	assert.Equal(t, err, ErrCandidateExist)
+
+	result, err := queryCandidate(context.tx.Data.From.Bytes(), context)
+	assert.Equal(t, err, nil)
+
+	var candidate Candidate
+	assert.Equal(t, json.Unmarshal(result, &candidate), nil)
+	assert.Equal(t, candidate.Address, context.tx.Data.From)
+	assert.Equal(t, candidate.Stake, candidateStakeLimit)
+	assert.Equal(t, candidate.Votes.Sign(), 0)
+	assert.Equal(t, candidate.RegisterHeight, context.BlockHeader.Height)
+}
+
+func Test_UnregisterCandidate(t *testing.T) {
+	db, dispose := leveldb.NewTestDatabase()
+	defer dispose()
+
+	context := newTestContext(db, ValidatorContractAddress)
+	sender := context.tx.Data.From
+	context.statedb.CreateAccount(sender)
+
+	_, err := unregisterCandidate(nil, context)
+	assert.Equal(t, err, ErrCandidateNotExist)
+
+	context.tx.Data.Amount = new(big.Int).Set(candidateStakeLimit)
+	context.statedb.AddBalance(ValidatorContractAddress, candidateStakeLimit)
+	_, err = registerCandidate(nil, context)
+	assert.Equal(t, err, nil)
+
+	_, err = withdrawStake(nil, context)
+	assert.Equal(t, err, ErrStakeLocked)
+
+	_, err = unregisterCandidate(nil, context)
+	assert.Equal(t, err, nil)
+	assert.Equal(t, context.statedb.GetBalance(sender).Sign(), 0)
+
+	candidates, err := GetCandidates(context.statedb)
+	assert.Equal(t, err, nil)
+	assert.Equal(t, len(candidates), 0)
+
+	// the stake is locked in the unbonding period
+	_, err = unregisterCandidate(nil, context)
+	assert.Equal(t, err, ErrCandidateUnbonding)
+	_, err = registerCandidate(nil, context)
+	assert.Equal(t, err, ErrCandidateUnbonding)
+	_, err = withdrawStake(nil, context)
+	assert.Equal(t, err, ErrStakeLocked)
+
+	context.BlockHeader.Height += candidateUnbondingPeriod
+	_, err = withdrawStake(nil, context)
+	assert.Equal(t, err, nil)
+	assert.Equal(t, context.statedb.GetBalance(sender), candidateStakeLimit)
+	assert.Equal(t, context.statedb.GetBalance(ValidatorContractAddress).Sign(), 0)
+
+	_, err = withdrawStake(nil, context)
+	assert.Equal(t, err, ErrCandidateNotExist)
+}
+
+func Test_RegisterCandidateLimit(t *testing.T) {
+	db, dispose := leveldb.NewTestDatabase()
+	defer dispose()
+
+	context := newTestContext(db, ValidatorContractAddress)
+	context.tx.Data.Amount = new(big.Int).Set(candidateStakeLimit)
+	for i := 0; i < MaxCandidates; i++ {
+		context.tx.Data.From = *crypto.MustGenerateShardAddress(1)
+		_, err := registerCandidate(nil, context)
+		assert.Equal(t, err, nil)
+	}
+
+	context.tx.Data.From = *crypto.MustGenerateShardAddress(1)
+	_, err := registerCandidate(nil, context)
+	assert.Equal(t, err, ErrTooManyCandidates)
+}
+
+func Test_Vote(t *testing.T) {
+	db, dispose := leveldb.NewTestDatabase()
+	defer dispose()
+
+	context := newTestContext(db, ValidatorContractAddress)
+	candidateAddr := context.tx.Data.From
+	context.tx.Data.Amount = new(big.Int).Set(candidateStakeLimit)
+	_, err := registerCandidate(nil, context)
+	assert.Equal(t, err, nil)
+
+	voter := *crypto.MustGenerateShardAddress(1)
+	context.statedb.CreateAccount(voter)
+	context.tx.Data.From = voter
+
+	// invalid amount
+	context.tx.Data.Amount = big.NewInt(0)
+	_, err = vote(candidateAddr.Bytes(), context)
+	assert.Equal(t, err, ErrVoteAmountNotRight)
+
+	// not a candidate
+	context.tx.Data.Amount = big.NewInt(100)
+	_, err = vote(voter.Bytes(), context)
+	assert.Equal(t, err, ErrCandidateNotExist)
+
+	// vote twice
+	context.statedb.AddBalance(ValidatorContractAddress, big.NewInt(200))
+	_, err = vote(candidateAddr.Bytes(), context)
+	assert.Equal(t, err, nil)
+	_, err = vote(candidateAddr.Bytes(), context)
+	assert.Equal(t, err, nil)
+
+	candidate, err := getCandidate(candidateAddr, context.statedb)
+	assert.Equal(t, err, nil)
+	assert.Equal(t, candidate.Votes, big.NewInt(200))
+
+	// cancel the vote
+	_, err = cancelVote(candidateAddr.Bytes(), context)
+	assert.Equal(t, err, nil)
+	assert.Equal(t, context.statedb.GetBalance(voter), big.NewInt(200))
+
+	candidate, err = getCandidate(candidateAddr, context.statedb)
+	assert.Equal(t, err, nil)
+	assert.Equal(t, candidate.Votes.Sign(), 0)
+
+	_, err = cancelVote(candidateAddr.Bytes(), context)
+	assert.Equal(t, err, ErrVoteNotExist)
+}
+
+func Test_CancelVoteAfterReregister(t *testing.T) {
+	db, dispose := leveldb.NewTestDatabase()
+	defer dispose()
+
+	context := newTestContext(db, ValidatorContractAddress)
+	candidateAddr := context.tx.Data.From
+	context.statedb.CreateAccount(candidateAddr)
+	context.statedb.AddBalance(ValidatorContractAddress, new(big.Int).Mul(candidateStakeLimit, big.NewInt(2)))
+	context.tx.Data.Amount = new(big.Int).Set(candidateStakeLimit)
+	_, err := registerCandidate(nil, context)
+	assert.Equal(t, err, nil)
+
+	voter := *crypto.MustGenerateShardAddress(1)
+	context.statedb.CreateAccount(voter)
+	context.statedb.AddBalance(ValidatorContractAddress, big.NewInt(100))
+	context.tx.Data.From = voter
+	context.tx.Data.Amount = big.NewInt(100)
+	_, err = vote(candidateAddr.Bytes(), context)
+	assert.Equal(t, err, nil)
+
+	// unregister and register again
+	context.tx.Data.From = candidateAddr
+	_, err = unregisterCandidate(nil, context)
+	assert.Equal(t, err, nil)
+
+	// voting for the unbonding candidate is not allowed
+	context.tx.Data.From = voter
+	_, err = vote(candidateAddr.Bytes(), context)
+	assert.Equal(t, err, ErrCandidateUnbonding)
+
+	context.tx.Data.From = candidateAddr
+	context.BlockHeader.Height += candidateUnbondingPeriod
+	_, err = withdrawStake(nil, context)
+	assert.Equal(t, err, nil)
+	context.tx.Data.Amount = new(big.Int).Set(candidateStakeLimit)
+	_, err = registerCandidate(nil, context)
+	assert.Equal(t, err, nil)
+
+	candidate, err := getCandidate(candidateAddr, context.statedb)
+	assert.Equal(t, err, nil)
+	assert.Equal(t, candidate.Registration, uint64(2))
+	assert.Equal(t, candidate.Votes.Sign(), 0)
+
+	// the vote for previous registration should be cancelled before voting again
+	context.tx.Data.From = voter
+	context.tx.Data.Amount = big.NewInt(50)
+	_, err = vote(candidateAddr.Bytes(), context)
+	assert.Equal(t, err, ErrStaleVote)
+
+	// the stale vote is refunded without changing the votes of new registration
+	_, err = cancelVote(candidateAddr.Bytes(), context)
+	assert.Equal(t, err, nil)
+	assert.Equal(t, context.statedb.GetBalance(voter), big.NewInt(100))
+
+	candidate, err = getCandidate(candidateAddr, context.statedb)
+	assert.Equal(t, err, nil)
+	assert.Equal(t, candidate.Votes.Sign(), 0)
+}
+
+func Test_GetElectedValidators(t *testing.T) {
+	db, dispose := leveldb.NewTestDatabase()
+	defer dispose()
+
+	context := newTestContext(db, ValidatorContractAddress)
+
+	validators, err := GetElectedValidators(context.statedb)
+	assert.Equal(t, err, nil)
+	assert.Equal(t, len(validators), 0)
+
+	// register candidates with increasing stakes
+	count := MaxElectedValidators + 2
+	addresses := make([]common.Address, count)
+	for i := 0; i < count; i++ {
+		addresses[i] = *crypto.MustGenerateShardAddress(1)
+		context.tx.Data.From = addresses[i]
+		context.tx.Data.Amount = new(big.Int).Add(candidateStakeLimit, big.NewInt(int64(i)))
+		_, err = registerCandidate(nil, context)
+		assert.Equal(t, err, nil)
+	}
+
+	// votes make the first candidate the heaviest one
+	context.tx.Data.From = *crypto.MustGenerateShardAddress(1)
+	context.tx.Data.Amount = big.NewInt(int64(count))
+	_, err = vote(addresses[0].Bytes(), context)
+	assert.Equal(t, err, nil)
+
+	validators, err = GetElectedValidators(context.statedb)
+	assert.Equal(t, err, nil)
+	assert.Equal(t, len(validators), MaxElectedValidators)
+	assert.Equal(t, validators[0], addresses[0])
+	for i := 1; i < MaxElectedValidators; i++ {
+		assert.Equal(t, validators[i], addresses[count-i])
+	}
+
+	weights, err := GetElectedWeights(context.statedb)
+	assert.Equal(t, err, nil)
+	assert.Equal(t, len(weights), MaxElectedValidators)
+	assert.Equal(t, weights[addresses[0]], new(big.Int).Add(candidateStakeLimit, big.NewInt(int64(count))))
+
+	result, err := queryValidators(nil, context)
+	assert.Equal(t, err, nil)
+
+	var queried []common.Address
+	assert.Equal(t, json.Unmarshal(result, &queried), nil)
+	assert.Equal(t, queried, validators)
+}
+
+func Test_GetElectedValidatorsSkipSlashed(t *testing.T) {
+	db, dispose := leveldb.NewTestDatabase()
+	defer dispose()
+
+	context := newTestContext(db, ValidatorContractAddress)
+	context.tx.Data.Amount = new(big.Int).Set(candidateStakeLimit)
+	slashed := context.tx.Data.From
+	_, err := registerCandidate(nil, context)
+	assert.Equal(t, err, nil)
+
+	context.tx.Data.From = *crypto.MustGenerateShardAddress(1)
+	_, err = registerCandidate(nil, context)
+	assert.Equal(t, err, nil)
+
+	context.statedb.CreateAccount(MasternodeContractAddress)
+	err = saveInfo(slashed.Bytes(), context.statedb, &masternodeInfo{IsQuit: true, Slashed: true})
+	assert.Equal(t, err, nil)
+
+	validators, err := GetElectedValidators(context.statedb)
+	assert.Equal(t, err, nil)
+	assert.Equal(t, validators, []common.Address{context.tx.Data.From})
+}
+
+func Test_SlashEvidences(t *testing.T) {
+	db, dispose := leveldb.NewTestDatabase()
+	defer dispose()
+
+	context := newTestContext(db, ValidatorContractAddress)
+	context.tx.Data.Amount = new(big.Int).Set(candidateStakeLimit)
+	context.statedb.AddBalance(ValidatorContractAddress, new(big.Int).Mul(candidateStakeLimit, big.NewInt(2)))
+
+	// an active candidate and an unbonding one
+	var evidences []*types.IstanbulEvidence
+	for i := 0; i < 2; i++ {
+		key, _ := crypto.GenerateKey()
+		context.tx.Data.From = *crypto.PubkeyToAddress(key.PublicKey)
+		_, err := registerCandidate(nil, context)
+		assert.Equal(t, err, nil)
+
+		evidences = append(evidences, &types.IstanbulEvidence{
+			Validator: context.tx.Data.From,
+			First:     newTestCommit(key, common.StringToHash("first")),
+			Second:    newTestCommit(key, common.StringToHash("second")),
+		})
+	}
+
+	_, err := unregisterCandidate(nil, context)
+	assert.Equal(t, err, nil)
+
+	// the evidence is expired
+	height := context.BlockHeader.Height
+	assert.Equal(t, SlashEvidences(context.statedb, evidences, 10+istanbul.MaxEvidenceAge+1), istanbul.ErrEvidenceExpired)
+
+	assert.Equal(t, SlashEvidences(context.statedb, evidences, height), nil)
+	assert.Equal(t, context.statedb.GetBalance(ValidatorContractAddress).Sign(), 0)
+
+	candidates, err := GetCandidates(context.statedb)
+	assert.Equal(t, err, nil)
+	assert.Equal(t, len(candidates), 0)
+
+	for _, evidence := range evidences {
+		candidate, err := getCandidate(evidence.Validator, context.statedb)
+		assert.Equal(t, err, nil)
+		assert.Equal(t, candidate == nil, true)
+
+		// the handled evidence can not be replayed
+		_, err = slashValidator(context.statedb, evidence, height)
+		assert.Equal(t, err, ErrEvidenceHandled)
+	}
+
+	// the slashed stake can not be withdrawn
+	context.BlockHeader.Height += candidateUnbondingPeriod
+	_, err = withdrawStake(nil, context)
+	assert.Equal(t, err, ErrCandidateNotExist)
+
+	// skip the handled evidences
+	assert.Equal(t, SlashEvidences(context.statedb, evidences, height), nil)
+}
+
+func Test_GetValidatorWeights(t *testing.T) {
+	db, dispose := leveldb.NewTestDatabase()
+	defer dispose()
+
+	context := newTestContext(db, ValidatorContractAddress)
+	addresses := make([]common.Address, 3)
+	for i := range addresses {
+		addresses[i] = *crypto.MustGenerateShardAddress(1)
+		context.tx.Data.From = addresses[i]
+		context.tx.Data.Amount = new(big.Int).Mul(candidateStakeLimit, big.NewInt(int64(i+1)))
+		_, err := registerCandidate(nil, context)
+		assert.Equal(t, err, nil)
+	}
+
+	context.tx.Data.From = *crypto.MustGenerateShardAddress(1)
+	context.tx.Data.Amount = big.NewInt(100)
+	_, err := vote(addresses[0].Bytes(), context)
+	assert.Equal(t, err, nil)
+
+	// the unbonding candidate has no weight
+	context.tx.Data.From = addresses[2]
+	_, err = unregisterCandidate(nil, context)
+	assert.Equal(t, err, nil)
+
+	unstaked := *crypto.MustGenerateShardAddress(1)
+	weights, err := GetValidatorWeights(context.statedb, append(addresses, unstaked))
+	assert.Equal(t, err, nil)
+	assert.Equal(t, weights, map[common.Address]*big.Int{
+		addresses[0]: new(big.Int).Add(candidateStakeLimit, big.NewInt(100)),
+		addresses[1]: new(big.Int).Mul(candidateStakeLimit, big.NewInt(2)),
+	})
+}
@@ -336,7 +336,7 @@ func (bc *Blockchain) doWriteBlock(block *types.Block, pool *Pool) error {
 	}
 	copy(currentBlock.Transactions, block.Transactions)
 	for i, tx := range block.Transactions { // for 1st tx is reward tx, no need to check the duplicate
-		if i == 0 {
+		if i == 0 || pool == nil {
 			continue
 		}
 		if !pool.cachedTxs.has(tx.Hash) {
@@ -480,10 +480,17 @@ func ValidateBlockHeader(header *types.BlockHeader, engine consensus.Engine, bcS
 	}
 
 	// Now, the extra data in block header should be empty except the genesis block.
-	if header.Consensus != types.IstanbulConsensus && len(header.ExtraData) > 0 {
+	if header.Consensus == types.PowConsensus && len(header.ExtraData) > 0 {
 		return ErrBlockExtraDataNotEmpty
 	}
 
+	// Validate that the block does not exist
+	if exist, err := bcStore.HasBlock(header.Hash()); err != nil {
+		return errors.NewStackedError(err, "failed to check block existence in store")
+	} else if exist {
+		return ErrBlockAlreadyExists
+	}
+
 	if err := engine.VerifyHeader(chainReader, header); err != nil {
 		return errors.NewStackedError(err, "failed to verify header by consensus engine")
 	}
@@ -551,7 +558,7 @@ func (bc *Blockchain) applyRewardAndRegularTxs(statedb *state.Statedb, rewardTx
 		return nil, errors.NewStackedError(err, "failed to validate reward tx")
 	}
 
-	rewardReceipt, err := txs.ApplyRewardTx(rewardTx, statedb)
+	rewardReceipt, err := txs.ApplyRewardTx(rewardTx, statedb, blockHeader)
 	if err != nil {
 		return nil, errors.NewStackedError(err, "failed to apply reward tx")
 	}
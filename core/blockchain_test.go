@@ -129,57 +129,57 @@ func newBlockWithDebtAndTxs(bc *Blockchain, parentHash common.Hash, blockHeight
 func Test_Blockchain_WriteBlock_HeaderHashChanged(t *testing.T) {
 	bc := NewTestBlockchain()
 
-	newBlock := newTestBlock(bc, bc.genesisBlock.HeaderHash, 1, 3, 0)
+	newBlock := newTestBlock(bc, bc.genesisBlock.HeaderHash, genesisBlockHeight+1, 3, 0)
 	newBlock.HeaderHash = common.EmptyHash
-	assert.True(t, errors.IsOrContains(bc.WriteBlock(newBlock), types.ErrBlockHashMismatch))
+	assert.True(t, errors.IsOrContains(bc.WriteBlock(newBlock, nil), types.ErrBlockHashMismatch))
 }
 
 func Test_Blockchain_WriteBlock_TxRootHashChanged(t *testing.T) {
 	bc := NewTestBlockchain()
 
-	newBlock := newTestBlock(bc, bc.genesisBlock.HeaderHash, 1, 3, 0)
+	newBlock := newTestBlock(bc, bc.genesisBlock.HeaderHash, genesisBlockHeight+1, 3, 0)
 	newBlock.Header.TxHash = common.EmptyHash
 	newBlock.HeaderHash = newBlock.Header.Hash()
 
-	assert.True(t, errors.IsOrContains(bc.WriteBlock(newBlock), types.ErrBlockTxsHashMismatch))
+	assert.True(t, errors.IsOrContains(bc.WriteBlock(newBlock, nil), types.ErrBlockTxsHashMismatch))
 }
 
 func Test_Blockchain_WriteBlock_InvalidHeight(t *testing.T) {
 	bc := NewTestBlockchain()
 
-	newBlock := newTestBlock(bc, bc.genesisBlock.HeaderHash, 1, 3, 0)
+	newBlock := newTestBlock(bc, bc.genesisBlock.HeaderHash, genesisBlockHeight+1, 3, 0)
 	newBlock.Header.Height = 10
 	newBlock.HeaderHash = newBlock.Header.Hash()
 
-	assert.True(t, errors.IsOrContains(bc.WriteBlock(newBlock), consensus.ErrBlockInvalidHeight))
+	assert.True(t, errors.IsOrContains(bc.WriteBlock(newBlock, nil), consensus.ErrBlockInvalidHeight))
 }
 
 func Test_Blockchain_WriteBlock_InvalidExtraData(t *testing.T) {
 	bc := NewTestBlockchain()
 
-	newBlock := newTestBlock(bc, bc.genesisBlock.HeaderHash, 1, 3, 0)
+	newBlock := newTestBlock(bc, bc.genesisBlock.HeaderHash, genesisBlockHeight+1, 3, 0)
 	newBlock.Header.ExtraData = []byte("test extra data")
 	newBlock.HeaderHash = newBlock.Header.Hash()
 
-	assert.True(t, errors.IsOrContains(bc.WriteBlock(newBlock), ErrBlockExtraDataNotEmpty))
+	assert.True(t, errors.IsOrContains(bc.WriteBlock(newBlock, nil), ErrBlockExtraDataNotEmpty))
 }
 
 func Test_Blockchain_WriteBlock_EmptyTxs(t *testing.T) {
 	bc := NewTestBlockchain()
 
-	newBlock := newTestBlock(bc, bc.genesisBlock.HeaderHash, 1, 3, 0)
+	newBlock := newTestBlock(bc, bc.genesisBlock.HeaderHash, genesisBlockHeight+1, 3, 0)
 	newBlock.Transactions = nil
 	newBlock.Header.TxHash = types.MerkleRootHash(nil)
 	newBlock.HeaderHash = newBlock.Header.Hash()
 
-	assert.True(t, errors.IsOrContains(bc.WriteBlock(newBlock), ErrBlockEmptyTxs))
+	assert.True(t, errors.IsOrContains(bc.WriteBlock(newBlock, nil), ErrBlockEmptyTxs))
 }
 
 func Test_Blockchain_WriteBlock_ValidBlock(t *testing.T) {
 	bc := NewTestBlockchain()
 
-	newBlock := newTestBlock(bc, bc.genesisBlock.HeaderHash, 1, 3, 0)
-	assert.Equal(t, bc.WriteBlock(newBlock), error(nil))
+	newBlock := newTestBlock(bc, bc.genesisBlock.HeaderHash, genesisBlockHeight+1, 3, 0)
+	assert.Equal(t, bc.WriteBlock(newBlock, nil), error(nil))
 
 	currentBlock := bc.CurrentBlock()
 	assert.Equal(t, currentBlock, newBlock)
@@ -195,30 +195,30 @@ func Test_Blockchain_WriteBlock_ValidBlock(t *testing.T) {
 func Test_Blockchain_WriteBlock_DupBlocks(t *testing.T) {
 	bc := NewTestBlockchain()
 
-	newBlock := newTestBlock(bc, bc.genesisBlock.HeaderHash, 1, 3, 0)
+	newBlock := newTestBlock(bc, bc.genesisBlock.HeaderHash, genesisBlockHeight+1, 3, 0)
 
-	err := bc.WriteBlock(newBlock)
+	err := bc.WriteBlock(newBlock, nil)
 	assert.Equal(t, err, error(nil))
 
 	currentBlock := bc.CurrentBlock()
 	assert.Equal(t, currentBlock, newBlock)
 
-	err = bc.WriteBlock(newBlock)
+	err = bc.WriteBlock(newBlock, nil)
 	assert.True(t, errors.IsOrContains(err, ErrBlockAlreadyExists))
 }
 
 func Test_Blockchain_WriteBlock_InsertTwoBlocks(t *testing.T) {
 	bc := NewTestBlockchain()
 
-	block1 := newTestBlock(bc, bc.genesisBlock.HeaderHash, 1, 3, 0)
-	err := bc.WriteBlock(block1)
+	block1 := newTestBlock(bc, bc.genesisBlock.HeaderHash, genesisBlockHeight+1, 3, 0)
+	err := bc.WriteBlock(block1, nil)
 	assert.Equal(t, err, error(nil))
 
 	currentBlock := bc.CurrentBlock()
 	assert.Equal(t, currentBlock, block1)
 
-	block2 := newTestBlock(bc, block1.HeaderHash, 2, 3, 3)
-	err = bc.WriteBlock(block2)
+	block2 := newTestBlock(bc, block1.HeaderHash, genesisBlockHeight+2, 3, 3)
+	err = bc.WriteBlock(block2, nil)
 	assert.Equal(t, err, error(nil))
 
 	currentBlock = bc.CurrentBlock()
@@ -228,16 +228,16 @@ func Test_Blockchain_WriteBlock_InsertTwoBlocks(t *testing.T) {
 func Test_Blockchain_BlockFork(t *testing.T) {
 	bc := NewTestBlockchain()
 
-	block1 := newTestBlock(bc, bc.genesisBlock.HeaderHash, 1, 3, 0)
-	err := bc.WriteBlock(block1)
+	block1 := newTestBlock(bc, bc.genesisBlock.HeaderHash, genesisBlockHeight+1, 3, 0)
+	err := bc.WriteBlock(block1, nil)
 	assert.Equal(t, err, error(nil))
 
 	currentBlock := bc.CurrentBlock()
 	assert.Equal(t, currentBlock, block1)
 	assert.Equal(t, bc.blockLeaves.Count(), 1)
 
-	block2 := newTestBlock(bc, bc.genesisBlock.HeaderHash, 1, 3, 0)
-	err = bc.WriteBlock(block2)
+	block2 := newTestBlock(bc, bc.genesisBlock.HeaderHash, genesisBlockHeight+1, 3, 0)
+	err = bc.WriteBlock(block2, nil)
 	assert.Equal(t, err, error(nil))
 
 	assert.Equal(t, bc.blockLeaves.Count(), 2)
@@ -246,58 +246,58 @@ func Test_Blockchain_BlockFork(t *testing.T) {
 func Test_BlockChain_InvalidParent(t *testing.T) {
 	bc := NewTestBlockchain()
 
-	block := newTestBlockWithApply(bc, common.EmptyHash, 1, 3, 0, false)
-	assert.True(t, errors.IsOrContains(bc.WriteBlock(block), consensus.ErrBlockInvalidParentHash))
+	block := newTestBlockWithApply(bc, common.EmptyHash, genesisBlockHeight+1, 3, 0, false)
+	assert.True(t, errors.IsOrContains(bc.WriteBlock(block, nil), consensus.ErrBlockInvalidParentHash))
 }
 
 func Test_Blockchain_InvalidHeight(t *testing.T) {
 	bc := NewTestBlockchain()
 
 	block := newTestBlock(bc, bc.genesisBlock.HeaderHash, 0, 3, 0)
-	assert.True(t, errors.IsOrContains(bc.WriteBlock(block), consensus.ErrBlockInvalidHeight))
+	assert.True(t, errors.IsOrContains(bc.WriteBlock(block, nil), consensus.ErrBlockInvalidHeight))
 }
 
 func Test_Blockchain_UpdateCanocialHash(t *testing.T) {
 	bc := NewTestBlockchain()
-	assertCanonicalHash(t, bc, 0, bc.genesisBlock.HeaderHash)
+	assertCanonicalHash(t, bc, genesisBlockHeight, bc.genesisBlock.HeaderHash)
 
 	// genesis <- block11
-	block11 := newTestBlock(bc, bc.genesisBlock.HeaderHash, 1, 3, 0)
-	assert.Equal(t, bc.WriteBlock(block11), error(nil))
-	assertCanonicalHash(t, bc, 1, block11.HeaderHash)
+	block11 := newTestBlock(bc, bc.genesisBlock.HeaderHash, genesisBlockHeight+1, 3, 0)
+	assert.Equal(t, bc.WriteBlock(block11, nil), error(nil))
+	assertCanonicalHash(t, bc, genesisBlockHeight+1, block11.HeaderHash)
 	assertTxDebtIndex(t, bc, true, block11)
 
 	// genesis <- block11 <- block12
-	block12 := newTestBlock(bc, block11.HeaderHash, 2, 3, 3)
-	assert.Equal(t, bc.WriteBlock(block12), error(nil))
-	assertCanonicalHash(t, bc, 2, block12.HeaderHash)
+	block12 := newTestBlock(bc, block11.HeaderHash, genesisBlockHeight+2, 3, 3)
+	assert.Equal(t, bc.WriteBlock(block12, nil), error(nil))
+	assertCanonicalHash(t, bc, genesisBlockHeight+2, block12.HeaderHash)
 	assertTxDebtIndex(t, bc, true, block11, block12)
 
 	// genesis <- block11 <- block12 (canonical)
 	//         <- block21
-	block21 := newTestBlock(bc, bc.genesisBlock.HeaderHash, 1, 3, 0)
-	assert.Equal(t, bc.WriteBlock(block21), error(nil))
-	assertCanonicalHash(t, bc, 1, block11.HeaderHash)
-	assertCanonicalHash(t, bc, 2, block12.HeaderHash)
+	block21 := newTestBlock(bc, bc.genesisBlock.HeaderHash, genesisBlockHeight+1, 3, 0)
+	assert.Equal(t, bc.WriteBlock(block21, nil), error(nil))
+	assertCanonicalHash(t, bc, genesisBlockHeight+1, block11.HeaderHash)
+	assertCanonicalHash(t, bc, genesisBlockHeight+2, block12.HeaderHash)
 	assertTxDebtIndex(t, bc, true, block11, block12)
 	assertTxDebtIndex(t, bc, false, block21)
 
 	// genesis <- block11 <- block12 (canonical)
 	//         <- block21 <- block22
-	block22 := newTestBlock(bc, block21.HeaderHash, 2, 3, 3)
-	assert.Equal(t, bc.WriteBlock(block22), error(nil))
-	assertCanonicalHash(t, bc, 1, block11.HeaderHash)
-	assertCanonicalHash(t, bc, 2, block12.HeaderHash)
+	block22 := newTestBlock(bc, block21.HeaderHash, genesisBlockHeight+2, 3, 3)
+	assert.Equal(t, bc.WriteBlock(block22, nil), error(nil))
+	assertCanonicalHash(t, bc, genesisBlockHeight+1, block11.HeaderHash)
+	assertCanonicalHash(t, bc, genesisBlockHeight+2, block12.HeaderHash)
 	assertTxDebtIndex(t, bc, true, block11, block12)
 	assertTxDebtIndex(t, bc, false, block21, block22)
 
 	// genesis <- block11 <- block12
 	//         <- block21 <- block22 <- block23 (canonical)
-	block23 := newTestBlock(bc, block22.HeaderHash, 3, 3, 6)
-	assert.Equal(t, bc.WriteBlock(block23), error(nil))
-	assertCanonicalHash(t, bc, 1, block21.HeaderHash)
-	assertCanonicalHash(t, bc, 2, block22.HeaderHash)
-	assertCanonicalHash(t, bc, 3, block23.HeaderHash)
+	block23 := newTestBlock(bc, block22.HeaderHash, genesisBlockHeight+3, 3, 6)
+	assert.Equal(t, bc.WriteBlock(block23, nil), error(nil))
+	assertCanonicalHash(t, bc, genesisBlockHeight+1, block21.HeaderHash)
+	assertCanonicalHash(t, bc, genesisBlockHeight+2, block22.HeaderHash)
+	assertCanonicalHash(t, bc, genesisBlockHeight+3, block23.HeaderHash)
 	assertTxDebtIndex(t, bc, false, block11, block12)
 	assertTxDebtIndex(t, bc, true, block21, block22, block23)
 }
@@ -356,7 +356,7 @@ func Test_Blockchain_AppyDebt(t *testing.T) {
 	verifier := types.NewTestVerifier(true, true, nil)
 	bc := NewTestBlockchainWithVerifier(verifier)
 
-	block := newTestBlockWithDebt(bc, bc.genesisBlock.HeaderHash, 1, BlockByteLimit, false)
+	block := newTestBlockWithDebt(bc, bc.genesisBlock.HeaderHash, genesisBlockHeight+1, BlockByteLimit, false)
 
 	common.LocalShardNumber = 2
 	defer func() {
@@ -391,7 +391,7 @@ func testApplyDebt(packed, confirmed bool, err error) error {
 	verifier := types.NewTestVerifier(packed, confirmed, err)
 	bc := NewTestBlockchainWithVerifier(verifier)
 
-	block := newTestBlockWithDebt(bc, bc.genesisBlock.HeaderHash, 1, BlockByteLimit, false)
+	block := newTestBlockWithDebt(bc, bc.genesisBlock.HeaderHash, genesisBlockHeight+1, BlockByteLimit, false)
 
 	common.LocalShardNumber = 2
 	defer func() {
@@ -414,7 +414,7 @@ func Test_Blockchain_ApplyTransaction(t *testing.T) {
 	amount := uint64(3456)
 	price := uint64(2)
 	tx := types.NewTestTxDetail(amount, price, 0)
-	block := newTestBlock(bc, bc.genesisBlock.HeaderHash, 1, 1, 0)
+	block := newTestBlock(bc, bc.genesisBlock.HeaderHash, genesisBlockHeight+1, 1, 0)
 	coinbase := block.Header.Creator
 	statedb, err := bc.GetCurrentState()
 	assert.Equal(t, err, nil)
@@ -452,7 +452,7 @@ func Benchmark_Blockchain_WriteBlock(b *testing.B) {
 
 		block := newTestBlock(bc, preBlock.HeaderHash, preBlock.Header.Height+1, state.GetNonce(types.TestGenesisAccount.Addr), BlockByteLimit)
 		b.StartTimer()
-		if err := bc.WriteBlock(block); err != nil {
+		if err := bc.WriteBlock(block, nil); err != nil {
 			b.Fatalf("failed to write block, %v", err.Error())
 		}
 		preBlock = block
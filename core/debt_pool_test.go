@@ -18,8 +18,8 @@ func Test_DebtPool(t *testing.T) {
 	bc := NewTestBlockchain()
 	pool := NewDebtPool(bc, nil)
 
-	b1 := newTestBlockWithDebt(bc, bc.genesisBlock.HeaderHash, 1, 2*types.DebtSize, true)
-	b2 := newTestBlockWithDebt(bc, bc.genesisBlock.HeaderHash, 1, 2*types.DebtSize, true)
+	b1 := newTestBlockWithDebt(bc, bc.genesisBlock.HeaderHash, genesisBlockHeight+1, 2*types.DebtSize, true)
+	b2 := newTestBlockWithDebt(bc, bc.genesisBlock.HeaderHash, genesisBlockHeight+1, 2*types.DebtSize, true)
 	assert.Equal(t, 2, len(b1.Debts))
 	assert.Equal(t, 2, len(b2.Debts))
 
@@ -28,12 +28,12 @@ func Test_DebtPool(t *testing.T) {
 		common.LocalShardNumber = common.UndefinedShardNumber
 	}()
 
-	err := bc.WriteBlock(b1)
+	err := bc.WriteBlock(b1, nil)
 	if err != nil {
 		panic(err)
 	}
 
-	err = bc.WriteBlock(b2)
+	err = bc.WriteBlock(b2, nil)
 	if err != nil {
 		panic(err)
 	}
@@ -41,14 +41,15 @@ func Test_DebtPool(t *testing.T) {
 	// Test reinject
 	reinject := pool.getReinjectObject(b2.HeaderHash, b1.HeaderHash)
 	assert.Equal(t, len(reinject), 2)
-	expectedResult := set.New(b1.Debts[0].Hash, b1.Debts[1].Hash)
+	expectedResult := set.New(set.ThreadSafe)
+	expectedResult.Add(b1.Debts[0].Hash, b1.Debts[1].Hash)
 	assert.Equal(t, expectedResult.Has(reinject[0].GetHash()), true)
 	assert.Equal(t, expectedResult.Has(reinject[1].GetHash()), true)
 
 	// test remove
 	// make b2 be in the block index
-	b3 := newTestBlockWithDebt(bc, b2.HeaderHash, 2, 0, true)
-	bc.WriteBlock(b3)
+	b3 := newTestBlockWithDebt(bc, b2.HeaderHash, genesisBlockHeight+2, 0, true)
+	bc.WriteBlock(b3, nil)
 
 	common.LocalShardNumber = 2
 	defer func() {
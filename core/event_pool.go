@@ -12,12 +12,59 @@ import (
 	"github.com/elcn233/go-scdo/common"
 	"github.com/elcn233/go-scdo/common/errors"
 	"github.com/elcn233/go-scdo/core/store"
+	"github.com/elcn233/go-scdo/core/types"
 	"github.com/elcn233/go-scdo/listener"
 	"github.com/elcn233/go-scdo/log"
 )
 
 const MaxBlockHeightGap = 40
 
+// mainChainConfirms is the number of blocks on top of a main chain block before its events are handled
+const mainChainConfirms = common.ConfirmedBlockNumber + MaxBlockHeightGap
+
+// MainChain is the main chain to read the receipts of the confirmed blocks from
+type MainChain interface {
+	// Height returns the height of the main chain head
+	Height() (uint64, error)
+	// Receipts returns the receipts of the main chain block of the height
+	Receipts(height uint64) ([]*types.Receipt, error)
+}
+
+// storeMainChain is the main chain of a local blockchain store
+type storeMainChain struct {
+	store store.BlockchainStore
+}
+
+// Height returns the height of the main chain head
+func (c *storeMainChain) Height() (uint64, error) {
+	hash, err := c.store.GetHeadBlockHash()
+	if err != nil {
+		return 0, errors.NewStackedError(err, "failed to get HEAD block hash")
+	}
+
+	header, err := c.store.GetBlockHeader(hash)
+	if err != nil {
+		return 0, errors.NewStackedError(err, "failed to get block header")
+	}
+
+	return header.Height, nil
+}
+
+// Receipts returns the receipts of the main chain block of the height
+func (c *storeMainChain) Receipts(height uint64) ([]*types.Receipt, error) {
+	blockHash, err := c.store.GetBlockHash(height)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get confirmed block hash, %v", err)
+	}
+
+	receipts, err := c.store.GetReceiptsByBlockHash(blockHash)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get receipts by block hash, %v", err)
+	}
+
+	return receipts, nil
+}
+
 // EventPool event pool
 type EventPool struct {
 	capacity uint
@@ -28,24 +75,39 @@ type EventPool struct {
 	// this version use main chain store to get receipts,
 	// so use the main chain database path initialize the store.
 	mainChainStore store.BlockchainStore
+	mainChain      MainChain
 
+	// position is the height of the next main chain block to handle
 	position uint64
 
 	log   *log.ScdoLog
 	chain blockchain
+	quit  chan struct{}
 	// todo add deal pools
 }
 
 // NewEventPool creates and returns an event pool.
 func NewEventPool(capacity uint, mainChainStore store.BlockchainStore, chain blockchain, abi *listener.ContractEventABI) (*EventPool, error) {
+	pool, err := NewEventPoolWithMainChain(capacity, &storeMainChain{mainChainStore}, chain, abi)
+	if pool != nil {
+		pool.mainChainStore = mainChainStore
+	}
+
+	return pool, err
+}
+
+// NewEventPoolWithMainChain creates and returns an event pool of the main chain,
+// the events of the blocks confirmed from now on are sent to the events channel.
+func NewEventPoolWithMainChain(capacity uint, mainChain MainChain, chain blockchain, abi *listener.ContractEventABI) (*EventPool, error) {
 	log := log.GetLogger("eventpool")
 
 	pool := &EventPool{
-		capacity:       capacity,
-		eventsChan:     make(chan []*listener.Event, 100),
-		mainChainStore: mainChainStore,
-		log:            log,
-		chain:          chain,
+		capacity:   capacity,
+		eventsChan: make(chan []*listener.Event, 100),
+		mainChain:  mainChain,
+		log:        log,
+		chain:      chain,
+		quit:       make(chan struct{}),
 	}
 
 	startHeight, err := pool.getMainChainHeight()
@@ -53,28 +115,33 @@ func NewEventPool(capacity uint, mainChainStore store.BlockchainStore, chain blo
 		return pool, nil
 	}
 
-	pool.position = startHeight
+	// start from the current confirmed block
+	if startHeight > mainChainConfirms {
+		pool.position = startHeight - mainChainConfirms
+	}
 
-	// height - 1 to ensure deal the current header height
 	go pool.pollingEvents(abi)
 
 	return pool, nil
 }
 
-// getMainChainHeight returns the current height of the main chain
-func (pool *EventPool) getMainChainHeight() (uint64, error) {
-	store := pool.mainChainStore
-	hash, err := store.GetHeadBlockHash()
-	if err != nil {
-		return 0, errors.NewStackedError(err, "failed to get HEAD block hash")
-	}
+// Events returns the channel of the main chain events
+func (pool *EventPool) Events() <-chan []*listener.Event {
+	return pool.eventsChan
+}
 
-	header, err := store.GetBlockHeader(hash)
-	if err != nil {
-		return 0, errors.NewStackedError(err, "failed to get block header")
+// Stop stops polling the events from main chain
+func (pool *EventPool) Stop() {
+	select {
+	case <-pool.quit:
+	default:
+		close(pool.quit)
 	}
+}
 
-	return header.Height, nil
+// getMainChainHeight returns the current height of the main chain
+func (pool *EventPool) getMainChainHeight() (uint64, error) {
+	return pool.mainChain.Height()
 }
 
 // PollingEvents is used to poll for events from main chain.
@@ -94,50 +161,41 @@ func (pool *EventPool) pollingEvents(abi *listener.ContractEventABI) {
 				pool.log.Error("failed to get events from main chain, %v", err)
 				continue
 			}
+		case <-pool.quit:
+			return
 		}
 	}
 }
 
-// getEvents gets the events of the current block
+// getEvents gets the events of the main chain blocks confirmed since the last polling
 func (pool *EventPool) getEvents(abi *listener.ContractEventABI) error {
-	var (
-		store = pool.mainChainStore
-	)
-
 	// get current header height
 	headerHeight, err := pool.getMainChainHeight()
 	if err != nil {
 		return fmt.Errorf("failed to get current header height, %v", err)
 	}
 
-	// avoid duplicate blocks request
-	if pool.position >= headerHeight {
-		pool.position++
-		return nil
-	}
-	if pool.position <= common.ConfirmedBlockNumber+MaxBlockHeightGap {
-		return nil
-	}
-
-	blockHash, err := store.GetBlockHash(pool.position - (common.ConfirmedBlockNumber + MaxBlockHeightGap))
-	if err != nil {
-		return fmt.Errorf("failed to get confirmed block hash, %v", err)
-	}
+	for ; pool.position+mainChainConfirms <= headerHeight; pool.position++ {
+		receipts, err := pool.mainChain.Receipts(pool.position)
+		if err != nil {
+			return err
+		}
 
-	receipts, err := store.GetReceiptsByBlockHash(blockHash)
-	if err != nil {
-		return fmt.Errorf("failed to get receipts by block hash, %v", err)
-	}
+		events, err := abi.GetEvents(receipts)
+		if err != nil {
+			return fmt.Errorf("failed to get events from receipts, %v", err)
+		}
 
-	events, err := abi.GetEvents(receipts)
-	if err != nil {
-		return fmt.Errorf("failed to get events from receipts, %v", err)
-	}
+		if len(events) == 0 {
+			continue
+		}
 
-	if len(events) == 0 {
-		return nil
+		select {
+		case pool.eventsChan <- events:
+		case <-pool.quit:
+			return nil
+		}
 	}
 
-	pool.eventsChan <- events
 	return nil
 }
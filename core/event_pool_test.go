@@ -5,10 +5,12 @@ import (
 	"math/big"
 	"testing"
 
+	"github.com/elcn233/go-scdo/common"
 	"github.com/elcn233/go-scdo/core/store"
 	"github.com/elcn233/go-scdo/core/types"
 	"github.com/elcn233/go-scdo/crypto"
 	"github.com/elcn233/go-scdo/database/leveldb"
+	"github.com/elcn233/go-scdo/listener"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -23,7 +25,7 @@ func newEventPool() (*EventPool, error, func()) {
 }
 
 func getRandomTx() *types.Transaction {
-	fromAddress, fromPrivateKey, err := crypto.GenerateKeyPair()
+	fromAddress, fromPrivateKey, err := crypto.GenerateKeyPair(1)
 	if err != nil {
 		panic(err)
 	}
@@ -82,3 +84,43 @@ func Test_EventPool_getBeginHeight(t *testing.T) {
 	assert.Equal(t, height, uint64(1))
 
 }
+
+type mockMainChain struct {
+	height   uint64
+	requests []uint64
+}
+
+func (c *mockMainChain) Height() (uint64, error) {
+	return c.height, nil
+}
+
+func (c *mockMainChain) Receipts(height uint64) ([]*types.Receipt, error) {
+	c.requests = append(c.requests, height)
+	return nil, nil
+}
+
+func Test_EventPool_startPosition(t *testing.T) {
+	// starts from the confirmed block
+	mainChain := &mockMainChain{height: mainChainConfirms + 10}
+	pool, err := NewEventPoolWithMainChain(10000, mainChain, newMockBlockchain(), nil)
+	assert.NoError(t, err)
+	defer pool.Stop()
+	assert.Equal(t, pool.position, uint64(10))
+
+	// starts from genesis if no block confirmed
+	pool2, err := NewEventPoolWithMainChain(10000, &mockMainChain{height: mainChainConfirms - 1}, newMockBlockchain(), nil)
+	assert.NoError(t, err)
+	defer pool2.Stop()
+	assert.Equal(t, pool2.position, uint64(0))
+
+	// handles the blocks confirmed since the start position
+	abi, err := listener.NewContractEventABIFromJSON(testEventABI, common.BytesToAddress([]byte{1, 2}), "Registered")
+	assert.NoError(t, err)
+
+	mainChain.height += 2
+	assert.NoError(t, pool.getEvents(abi))
+	assert.Equal(t, mainChain.requests, []uint64{10, 11, 12})
+	assert.Equal(t, pool.position, uint64(13))
+}
+
+const testEventABI = `[{"anonymous":false,"inputs":[{"indexed":false,"name":"name","type":"string"}],"name":"Registered","type":"event"}]`
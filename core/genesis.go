@@ -7,7 +7,6 @@ package core
 
 import (
 	"bytes"
-	"encoding/json"
 	"fmt"
 	"math/big"
 
@@ -17,7 +16,6 @@ import (
 	"github.com/elcn233/go-scdo/core/state"
 	"github.com/elcn233/go-scdo/core/store"
 	"github.com/elcn233/go-scdo/core/types"
-	"github.com/elcn233/go-scdo/crypto"
 	"github.com/elcn233/go-scdo/database"
 	"github.com/ethereum/go-ethereum/rlp"
 	leveldbErrors "github.com/syndtr/goleveldb/leveldb/errors"
@@ -40,32 +38,7 @@ type Genesis struct {
 }
 
 // GenesisInfo genesis info for generating genesis block, it could be used for initializing account balance
-type GenesisInfo struct {
-	// Accounts accounts info for genesis block used for test
-	// map key is account address -> value is account balance
-	Accounts map[common.Address]*big.Int `json:"accounts,omitempty"`
-
-	// Difficult initial difficulty for mining. Use bigger difficulty as you can. Because block is chosen by total difficulty
-	Difficult int64 `json:"difficult"`
-
-	// ShardNumber is the shard number of genesis block.
-	ShardNumber uint `json:"shard"`
-
-	// CreateTimestamp is the initial time of genesis
-	CreateTimestamp *big.Int `json:"timestamp"`
-
-	// Consensus consensus type
-	Consensus types.ConsensusType `json:"consensus"`
-
-	// Validators istanbul consensus validators
-	Validators []common.Address `json:"validators"`
-
-	// master account
-	Masteraccount common.Address `json:"master"`
-
-	// balance of the master account
-	Balance *big.Int `json:"balance"`
-}
+type GenesisInfo = types.GenesisInfo
 
 func NewGenesisInfo(accounts map[common.Address]*big.Int, difficult int64, shard uint, timestamp *big.Int,
 	consensus types.ConsensusType, validator []common.Address) *GenesisInfo {
@@ -85,16 +58,6 @@ func NewGenesisInfo(accounts map[common.Address]*big.Int, difficult int64, shard
 	}
 }
 
-// Hash returns GenesisInfo hash
-func (info *GenesisInfo) Hash() common.Hash {
-	data, err := json.Marshal(info)
-	if err != nil {
-		panic(fmt.Sprintf("Failed to marshal err: %s", err))
-	}
-
-	return crypto.HashBytes(data)
-}
-
 // shardInfo represents the extra data that saved in the genesis block in the blockchain.
 type shardInfo struct {
 	ShardNumber uint
@@ -113,8 +76,8 @@ func GetGenesis(info *GenesisInfo) *Genesis {
 	}
 
 	extraData := []byte{}
-	if info.Consensus == types.IstanbulConsensus {
-		extraData = generateConsensusInfo(info.Validators)
+	if info.Consensus != types.PowConsensus {
+		extraData = generateConsensusInfo(info.Consensus, info.Validators)
 	}
 
 	shard := common.SerializePanic(shardInfo{
@@ -169,8 +132,12 @@ func GetGenesis(info *GenesisInfo) *Genesis {
 }
 
 // generateConsensusInfo generates the consensus info given addresses
-// only used by istanbul consensus
-func generateConsensusInfo(addrs []common.Address) []byte {
+// only used by istanbul and clique consensus
+func generateConsensusInfo(consensus types.ConsensusType, addrs []common.Address) []byte {
+	if consensus == types.CliqueConsensus {
+		return generateCliqueInfo(addrs)
+	}
+
 	var consensusInfo []byte
 	consensusInfo = append(consensusInfo, bytes.Repeat([]byte{0x00}, types.IstanbulExtraVanity)...)
 
@@ -189,6 +156,17 @@ func generateConsensusInfo(addrs []common.Address) []byte {
 	return consensusInfo
 }
 
+// generateCliqueInfo generates the clique extra-data, which is the vanity,
+// the initial signers and an empty seal
+func generateCliqueInfo(signers []common.Address) []byte {
+	extra := bytes.Repeat([]byte{0x00}, types.CliqueExtraVanity)
+	for _, signer := range signers {
+		extra = append(extra, signer[:]...)
+	}
+
+	return append(extra, bytes.Repeat([]byte{0x00}, types.CliqueExtraSeal)...)
+}
+
 // GetShardNumber gets the shard number of genesis
 func (genesis *Genesis) GetShardNumber() uint {
 	return genesis.info.ShardNumber
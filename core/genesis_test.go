@@ -23,8 +23,8 @@ func Test_Genesis_GetGenesis(t *testing.T) {
 	genesis1 := GetGenesis(&GenesisInfo{CreateTimestamp: big.NewInt(0)})
 	genesis2 := GetGenesis(&GenesisInfo{CreateTimestamp: big.NewInt(0)})
 	assert.Equal(t, genesis1.header, genesis2.header)
-	assert.Equal(t, genesis1.info, NewGenesisInfo(nil, 1, 0, big.NewInt(0), types.PowConsensus, nil))
-	assert.Equal(t, genesis2.info, NewGenesisInfo(nil, 1, 0, big.NewInt(0), types.PowConsensus, nil))
+	assert.Equal(t, genesis1.info, newTestGenesisInfo(nil, 1, 0))
+	assert.Equal(t, genesis2.info, newTestGenesisInfo(nil, 1, 0))
 	validateGenesisDefaultMembers(t, genesis1)
 	validateGenesisDefaultMembers(t, genesis2)
 
@@ -41,7 +41,7 @@ func Test_Genesis_GetGenesis(t *testing.T) {
 		panic("genesis3 should not equal to genesis2")
 	}
 
-	assert.Equal(t, genesis3.info, NewGenesisInfo(accounts, 1, 0, big.NewInt(0), types.PowConsensus, nil))
+	assert.Equal(t, genesis3.info, newTestGenesisInfo(accounts, 1, 0))
 	validateGenesisDefaultMembers(t, genesis3)
 
 	// case 3
@@ -49,14 +49,14 @@ func Test_Genesis_GetGenesis(t *testing.T) {
 	genesis4 := GetGenesis(NewGenesisInfo(accounts, difficult, 0, big.NewInt(0), types.PowConsensus, nil))
 	assert.Equal(t, genesis4.header.Difficulty, big.NewInt(1))
 	assert.Equal(t, genesis4.header.Witness, common.SerializePanic(shardInfo{ShardNumber: 0}))
-	assert.Equal(t, genesis4.info, NewGenesisInfo(accounts, 1, 0, big.NewInt(0), types.PowConsensus, nil))
+	assert.Equal(t, genesis4.info, newTestGenesisInfo(accounts, 1, 0))
 	validateGenesisDefaultMembers(t, genesis4)
 
 	difficult = 10
 	genesis4 = GetGenesis(NewGenesisInfo(nil, difficult, 0, big.NewInt(0), types.PowConsensus, nil))
 	assert.Equal(t, genesis4.header.Difficulty, big.NewInt(difficult))
 	assert.Equal(t, genesis4.header.Witness, common.SerializePanic(shardInfo{ShardNumber: 0}))
-	assert.Equal(t, genesis4.info, NewGenesisInfo(nil, difficult, 0, big.NewInt(0), types.PowConsensus, nil))
+	assert.Equal(t, genesis4.info, newTestGenesisInfo(nil, difficult, 0))
 	validateGenesisDefaultMembers(t, genesis4)
 
 	// case 4
@@ -64,8 +64,27 @@ func Test_Genesis_GetGenesis(t *testing.T) {
 	genesis5 := GetGenesis(NewGenesisInfo(nil, difficult, shardNumber, big.NewInt(0), types.PowConsensus, nil))
 	assert.Equal(t, genesis5.header.Difficulty, big.NewInt(difficult))
 	assert.Equal(t, genesis5.header.Witness, common.SerializePanic(shardInfo{ShardNumber: shardNumber}))
-	assert.Equal(t, genesis5.info, NewGenesisInfo(nil, difficult, shardNumber, big.NewInt(0), types.PowConsensus, nil))
-	validateGenesisDefaultMembers(t, genesis5)
+	assert.Equal(t, genesis5.info.Masteraccount.Shard(), shardNumber)
+	assert.Equal(t, genesis5.info.Balance.Sign(), 1)
+
+	// the genesis of the shard continues the seele chain before the fork height
+	assert.Equal(t, genesis5.header.Creator, common.EmptyAddress)
+	assert.Equal(t, genesis5.header.Height, uint64(genesisBlockHeight))
+	assert.NotEqual(t, genesis5.header.PreviousBlockHash, common.EmptyHash)
+}
+
+func Test_Genesis_CliqueSigners(t *testing.T) {
+	signers := []common.Address{
+		common.BytesToAddress([]byte{1, 1}),
+		common.BytesToAddress([]byte{2, 1}),
+	}
+
+	genesis := GetGenesis(NewGenesisInfo(nil, 1, 0, big.NewInt(0), types.CliqueConsensus, signers))
+	assert.Equal(t, len(genesis.header.ExtraData), types.CliqueExtraVanity+len(signers)*common.AddressLen+types.CliqueExtraSeal)
+
+	result, err := types.ExtractCliqueSigners(genesis.header)
+	assert.Equal(t, err, nil)
+	assert.Equal(t, result, signers)
 }
 
 func Test_Genesis_GetShardNumber(t *testing.T) {
@@ -117,10 +136,18 @@ func Test_Genesis_Init_GenesisMismatch(t *testing.T) {
 	assert.Equal(t, err, ErrGenesisHashMismatch)
 }
 
+// newTestGenesisInfo returns the info filled by GetGenesis, which only sets
+// the zero balance of the master account in shard 0.
+func newTestGenesisInfo(accounts map[common.Address]*big.Int, difficult int64, shard uint) *GenesisInfo {
+	info := NewGenesisInfo(accounts, difficult, shard, big.NewInt(0), types.PowConsensus, nil)
+	info.Balance = big.NewInt(0)
+	return info
+}
+
 func validateGenesisDefaultMembers(t *testing.T, genesis *Genesis) {
 	assert.Equal(t, genesis.header.PreviousBlockHash, common.EmptyHash)
 	assert.Equal(t, genesis.header.Creator, common.EmptyAddress)
 	assert.Equal(t, genesis.header.TxHash, types.MerkleRootHash(nil))
-	assert.Equal(t, genesis.header.Height, genesisBlockHeight)
+	assert.Equal(t, genesis.header.Height, uint64(genesisBlockHeight))
 	assert.Equal(t, genesis.header.CreateTimestamp, big.NewInt(0))
 }
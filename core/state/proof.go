@@ -0,0 +1,47 @@
+/**
+* @file
+* @copyright defined in scdo/LICENSE
+ */
+
+package state
+
+import (
+	"math/big"
+
+	"github.com/elcn233/go-scdo/common"
+	"github.com/elcn233/go-scdo/crypto"
+	"github.com/elcn233/go-scdo/trie"
+)
+
+// AccountKey returns the key of the specified account in the state trie.
+func AccountKey(addr common.Address) []byte {
+	return append(crypto.MustHash(addr).Bytes(), dataTypeAccount)
+}
+
+// GetAccountProof returns the merkle proof of the specified account in the state trie.
+// Note, the dirty data should be committed before, otherwise the proof is built
+// against the previous state root.
+func (s *Statedb) GetAccountProof(addr common.Address) (map[string][]byte, error) {
+	return s.trie.GetProof(AccountKey(addr))
+}
+
+// VerifyAccountProof verifies the merkle proof of the specified account against the
+// state root, and returns the nonce and balance of the account. The nonce and balance
+// are both zero if the proof shows the account does not exist.
+func VerifyAccountProof(root common.Hash, addr common.Address, proof map[string][]byte) (uint64, *big.Int, error) {
+	value, err := trie.VerifyProof(root, AccountKey(addr), proof)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	if len(value) == 0 {
+		return 0, new(big.Int), nil
+	}
+
+	var account account
+	if err = common.Deserialize(value, &account); err != nil {
+		return 0, nil, err
+	}
+
+	return account.Nonce, account.Amount, nil
+}
@@ -0,0 +1,60 @@
+/**
+* @file
+* @copyright defined in scdo/LICENSE
+ */
+
+package state
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/elcn233/go-scdo/common"
+	"github.com/elcn233/go-scdo/crypto"
+	"github.com/elcn233/go-scdo/database/leveldb"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_AccountProof(t *testing.T) {
+	db, remove := leveldb.NewTestDatabase()
+	defer remove()
+
+	statedb := NewEmptyStatedb(db)
+	addr := common.BytesToAddress([]byte{1, 0, 1})
+	statedb.CreateAccount(addr)
+	statedb.SetBalance(addr, big.NewInt(100))
+	statedb.SetNonce(addr, 3)
+
+	for i := 0; i < 10; i++ {
+		other := common.BytesToAddress([]byte{1, byte(i), 2})
+		statedb.CreateAccount(other)
+		statedb.SetBalance(other, big.NewInt(int64(i)))
+	}
+
+	root, err := statedb.Commit(db.NewBatch())
+	assert.Equal(t, err, nil)
+
+	proof, err := statedb.GetAccountProof(addr)
+	assert.Equal(t, err, nil)
+
+	nonce, balance, err := VerifyAccountProof(root, addr, proof)
+	assert.Equal(t, err, nil)
+	assert.Equal(t, nonce, uint64(3))
+	assert.Equal(t, balance, big.NewInt(100))
+
+	// proof of accounts that do not exist
+	for i := 0; i < 20; i++ {
+		missing := common.BytesToAddress([]byte{1, byte(i), 3})
+		missingProof, err := statedb.GetAccountProof(missing)
+		assert.Equal(t, err, nil)
+
+		nonce, balance, err = VerifyAccountProof(root, missing, missingProof)
+		assert.Equal(t, err, nil)
+		assert.Equal(t, nonce, uint64(0))
+		assert.Equal(t, balance, big.NewInt(0))
+	}
+
+	// proof against wrong root
+	_, _, err = VerifyAccountProof(crypto.HashBytes([]byte("root")), addr, proof)
+	assert.NotEqual(t, err, nil)
+}
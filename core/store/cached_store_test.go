@@ -70,7 +70,7 @@ func Test_cachedStore_PutBlockHash(t *testing.T) {
 }
 
 func getRandomTx() *types.Transaction {
-	fromAddress, fromPrivateKey, err := crypto.GenerateKeyPair()
+	fromAddress, fromPrivateKey, err := crypto.GenerateKeyPair(1)
 	if err != nil {
 		panic(err)
 	}
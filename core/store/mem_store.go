@@ -0,0 +1,400 @@
+/**
+* @file
+* @copyright defined in scdo/LICENSE
+ */
+
+package store
+
+import (
+	"fmt"
+	"math/big"
+	"sync"
+
+	"github.com/elcn233/go-scdo/common"
+	"github.com/elcn233/go-scdo/core/types"
+	"github.com/syndtr/goleveldb/leveldb/errors"
+)
+
+// ErrDBCorrupt is returned by the MemStore to mock a corrupted database.
+var ErrDBCorrupt = errors.New("database is corrupted")
+
+// memBlock is a block with its total difficulty in memory,
+// the body is nil if only the block header is stored.
+type memBlock struct {
+	header *types.BlockHeader
+	body   *blockBody
+	td     *big.Int
+}
+
+// MemStore is a blockchain store in memory, mainly used for tests.
+// Unlike the database store, the stored objects are not serialized.
+type MemStore struct {
+	lock sync.RWMutex
+
+	canonicalBlocks map[uint64]common.Hash
+	headBlockHash   common.Hash
+	blocks          map[common.Hash]*memBlock
+	receipts        map[common.Hash][]*types.Receipt
+	dirtyAccounts   map[common.Hash][]common.Address
+	txIndices       map[common.Hash]types.TxIndex
+	debtIndices     map[common.Hash]types.DebtIndex
+
+	// CorruptOnPutBlock mocks the database corruption after a block is put.
+	CorruptOnPutBlock bool
+}
+
+// NewMemStore returns an empty MemStore instance.
+func NewMemStore() *MemStore {
+	return &MemStore{
+		canonicalBlocks: make(map[uint64]common.Hash),
+		blocks:          make(map[common.Hash]*memBlock),
+		receipts:        make(map[common.Hash][]*types.Receipt),
+		dirtyAccounts:   make(map[common.Hash][]common.Address),
+		txIndices:       make(map[common.Hash]types.TxIndex),
+		debtIndices:     make(map[common.Hash]types.DebtIndex),
+	}
+}
+
+// GetBlockHash gets the hash of the canonical block with the specified height.
+func (store *MemStore) GetBlockHash(height uint64) (common.Hash, error) {
+	store.lock.RLock()
+	defer store.lock.RUnlock()
+
+	hash, found := store.canonicalBlocks[height]
+	if !found {
+		return common.EmptyHash, errors.ErrNotFound
+	}
+
+	return hash, nil
+}
+
+// PutBlockHash puts the hash of the canonical block with the specified height.
+func (store *MemStore) PutBlockHash(height uint64, hash common.Hash) error {
+	store.lock.Lock()
+	defer store.lock.Unlock()
+
+	store.canonicalBlocks[height] = hash
+	return nil
+}
+
+// DeleteBlockHash deletes the hash of the canonical block with the specified height.
+func (store *MemStore) DeleteBlockHash(height uint64) (bool, error) {
+	store.lock.Lock()
+	defer store.lock.Unlock()
+
+	if _, found := store.canonicalBlocks[height]; !found {
+		return false, nil
+	}
+
+	delete(store.canonicalBlocks, height)
+	return true, nil
+}
+
+// GetHeadBlockHash gets the HEAD block hash.
+func (store *MemStore) GetHeadBlockHash() (common.Hash, error) {
+	store.lock.RLock()
+	defer store.lock.RUnlock()
+
+	if store.headBlockHash.IsEmpty() {
+		return common.EmptyHash, errors.ErrNotFound
+	}
+
+	return store.headBlockHash, nil
+}
+
+// PutHeadBlockHash puts the HEAD block hash.
+func (store *MemStore) PutHeadBlockHash(hash common.Hash) error {
+	store.lock.Lock()
+	defer store.lock.Unlock()
+
+	store.headBlockHash = hash
+	return nil
+}
+
+// GetBlockHeader gets the header of the block with the specified hash.
+func (store *MemStore) GetBlockHeader(hash common.Hash) (*types.BlockHeader, error) {
+	store.lock.RLock()
+	defer store.lock.RUnlock()
+
+	block, found := store.blocks[hash]
+	if !found {
+		return nil, errors.ErrNotFound
+	}
+
+	return block.header, nil
+}
+
+// PutBlockHeader puts the block header with the specified hash and total difficulty.
+func (store *MemStore) PutBlockHeader(hash common.Hash, header *types.BlockHeader, td *big.Int, isHead bool) error {
+	return store.putBlockInternal(hash, header, nil, td, isHead)
+}
+
+func (store *MemStore) putBlockInternal(hash common.Hash, header *types.BlockHeader, body *blockBody, td *big.Int, isHead bool) error {
+	if header == nil {
+		panic("header is nil")
+	}
+
+	store.lock.Lock()
+	defer store.lock.Unlock()
+
+	store.blocks[hash] = &memBlock{header, body, td}
+
+	if isHead {
+		// delete old txs/debts indices in old canonical chain if exists
+		if oldHash, found := store.canonicalBlocks[header.Height]; found {
+			if oldBlock, found := store.blocks[oldHash]; found && oldBlock.body != nil {
+				store.deleteIndices(oldHash, oldBlock.body.Txs, oldBlock.body.Debts)
+			}
+		}
+
+		// add or update txs/debts indices of new HEAD block
+		if body != nil {
+			store.addIndices(hash, body.Txs, body.Debts)
+		}
+
+		store.canonicalBlocks[header.Height] = hash
+		store.headBlockHash = hash
+	}
+
+	return nil
+}
+
+// DeleteBlockHeader deletes the block header of the specified block hash.
+func (store *MemStore) DeleteBlockHeader(hash common.Hash) error {
+	store.lock.Lock()
+	defer store.lock.Unlock()
+
+	delete(store.blocks, hash)
+	delete(store.receipts, hash)
+	return nil
+}
+
+// GetBlockTotalDifficulty gets the total difficulty of the block with the specified hash.
+func (store *MemStore) GetBlockTotalDifficulty(hash common.Hash) (*big.Int, error) {
+	store.lock.RLock()
+	defer store.lock.RUnlock()
+
+	block, found := store.blocks[hash]
+	if !found {
+		return nil, errors.ErrNotFound
+	}
+
+	return block.td, nil
+}
+
+// PutBlock puts the given block with the specified total difficulty,
+// and returns ErrDBCorrupt after the block is put if CorruptOnPutBlock is set.
+func (store *MemStore) PutBlock(block *types.Block, td *big.Int, isHead bool) error {
+	if block == nil {
+		panic("block is nil")
+	}
+
+	if err := store.putBlockInternal(block.HeaderHash, block.Header, &blockBody{block.Transactions, block.Debts}, td, isHead); err != nil {
+		return err
+	}
+
+	if store.CorruptOnPutBlock {
+		return ErrDBCorrupt
+	}
+
+	return nil
+}
+
+// GetBlock gets the block with the specified hash.
+func (store *MemStore) GetBlock(hash common.Hash) (*types.Block, error) {
+	store.lock.RLock()
+	defer store.lock.RUnlock()
+
+	block, found := store.blocks[hash]
+	if !found {
+		return nil, errors.ErrNotFound
+	}
+
+	if block.body == nil {
+		return &types.Block{
+			HeaderHash: hash,
+			Header:     block.header,
+		}, nil
+	}
+
+	return &types.Block{
+		HeaderHash:   hash,
+		Header:       block.header,
+		Transactions: block.body.Txs,
+		Debts:        block.body.Debts,
+	}, nil
+}
+
+// HasBlock indicates if the block with the specified hash exists.
+func (store *MemStore) HasBlock(hash common.Hash) (bool, error) {
+	store.lock.RLock()
+	defer store.lock.RUnlock()
+
+	_, found := store.blocks[hash]
+	return found, nil
+}
+
+// DeleteBlock deletes the block of the specified block hash.
+func (store *MemStore) DeleteBlock(hash common.Hash) error {
+	store.lock.Lock()
+	defer store.lock.Unlock()
+
+	if block, found := store.blocks[hash]; found && block.body != nil {
+		store.deleteIndices(hash, block.body.Txs, block.body.Debts)
+	}
+
+	delete(store.blocks, hash)
+	delete(store.receipts, hash)
+	return nil
+}
+
+// GetBlockByHeight gets the canonical block with the specified height.
+func (store *MemStore) GetBlockByHeight(height uint64) (*types.Block, error) {
+	hash, err := store.GetBlockHash(height)
+	if err != nil {
+		return nil, err
+	}
+
+	return store.GetBlock(hash)
+}
+
+// RecoverHeightToBlockMap recovers the height-to-block map
+func (store *MemStore) RecoverHeightToBlockMap(block *types.Block) error {
+	store.lock.Lock()
+	defer store.lock.Unlock()
+
+	store.addIndices(block.HeaderHash, block.Transactions, block.Debts)
+	store.canonicalBlocks[block.Header.Height] = block.HeaderHash
+	return nil
+}
+
+// PutReceipts puts the receipts of the specified block hash.
+func (store *MemStore) PutReceipts(hash common.Hash, receipts []*types.Receipt) error {
+	store.lock.Lock()
+	defer store.lock.Unlock()
+
+	store.receipts[hash] = receipts
+	return nil
+}
+
+// GetReceiptsByBlockHash gets the receipts of the specified block hash.
+func (store *MemStore) GetReceiptsByBlockHash(hash common.Hash) ([]*types.Receipt, error) {
+	store.lock.RLock()
+	defer store.lock.RUnlock()
+
+	receipts, found := store.receipts[hash]
+	if !found {
+		return nil, errors.ErrNotFound
+	}
+
+	return receipts, nil
+}
+
+// GetReceiptByTxHash gets the receipt of the specified tx hash.
+func (store *MemStore) GetReceiptByTxHash(txHash common.Hash) (*types.Receipt, error) {
+	txIndex, err := store.GetTxIndex(txHash)
+	if err != nil {
+		return nil, err
+	}
+
+	receipts, err := store.GetReceiptsByBlockHash(txIndex.BlockHash)
+	if err != nil {
+		return nil, err
+	}
+
+	if uint(len(receipts)) <= txIndex.Index {
+		return nil, fmt.Errorf("invalid tx index, txIndex = %v, receiptsLen = %v", *txIndex, len(receipts))
+	}
+
+	return receipts[txIndex.Index], nil
+}
+
+// PutDirtyAccounts puts the dirty accounts of the specified block hash.
+func (store *MemStore) PutDirtyAccounts(hash common.Hash, accounts []common.Address) error {
+	store.lock.Lock()
+	defer store.lock.Unlock()
+
+	store.dirtyAccounts[hash] = accounts
+	return nil
+}
+
+// GetDirtyAccountsByBlockHash gets the dirty accounts of the specified block hash.
+func (store *MemStore) GetDirtyAccountsByBlockHash(hash common.Hash) ([]common.Address, error) {
+	store.lock.RLock()
+	defer store.lock.RUnlock()
+
+	accounts, found := store.dirtyAccounts[hash]
+	if !found {
+		return nil, errors.ErrNotFound
+	}
+
+	return accounts, nil
+}
+
+// AddIndices adds tx/debt indices for the specified block.
+func (store *MemStore) AddIndices(block *types.Block) error {
+	store.lock.Lock()
+	defer store.lock.Unlock()
+
+	store.addIndices(block.HeaderHash, block.Transactions, block.Debts)
+	return nil
+}
+
+func (store *MemStore) addIndices(blockHash common.Hash, txs []*types.Transaction, debts []*types.Debt) {
+	for i, tx := range txs {
+		store.txIndices[tx.Hash] = types.TxIndex{BlockHash: blockHash, Index: uint(i)}
+	}
+
+	for i, debt := range debts {
+		store.debtIndices[debt.Hash] = types.DebtIndex{BlockHash: blockHash, Index: uint(i)}
+	}
+}
+
+// GetTxIndex gets the tx index of the specified tx hash.
+func (store *MemStore) GetTxIndex(txHash common.Hash) (*types.TxIndex, error) {
+	store.lock.RLock()
+	defer store.lock.RUnlock()
+
+	index, found := store.txIndices[txHash]
+	if !found {
+		return nil, errors.ErrNotFound
+	}
+
+	return &index, nil
+}
+
+// GetDebtIndex gets the debt index of the specified debt hash.
+func (store *MemStore) GetDebtIndex(debtHash common.Hash) (*types.DebtIndex, error) {
+	store.lock.RLock()
+	defer store.lock.RUnlock()
+
+	index, found := store.debtIndices[debtHash]
+	if !found {
+		return nil, errors.ErrNotFound
+	}
+
+	return &index, nil
+}
+
+// DeleteIndices deletes tx/debt indices of the specified block.
+func (store *MemStore) DeleteIndices(block *types.Block) error {
+	store.lock.Lock()
+	defer store.lock.Unlock()
+
+	store.deleteIndices(block.HeaderHash, block.Transactions, block.Debts)
+	return nil
+}
+
+func (store *MemStore) deleteIndices(blockHash common.Hash, txs []*types.Transaction, debts []*types.Debt) {
+	for _, tx := range txs {
+		if idx, found := store.txIndices[tx.Hash]; found && idx.BlockHash.Equal(blockHash) {
+			delete(store.txIndices, tx.Hash)
+		}
+	}
+
+	for _, debt := range debts {
+		if idx, found := store.debtIndices[debt.Hash]; found && idx.BlockHash.Equal(blockHash) {
+			delete(store.debtIndices, debt.Hash)
+		}
+	}
+}
@@ -9,6 +9,7 @@ import (
 	"math/big"
 
 	"github.com/elcn233/go-scdo/common"
+	"github.com/elcn233/go-scdo/contract/system"
 	"github.com/elcn233/go-scdo/core/store"
 	"github.com/elcn233/go-scdo/core/types"
 	"github.com/elcn233/go-scdo/core/vm"
@@ -33,6 +34,9 @@ func NewEVMByDefaultConfig(tx *types.Transaction, statedb *StateDB, blockHeader
 		Ethash:              new(params.EthashConfig),
 	}
 	vmConfig := &vm.Config{}
+	if blockHeader.Height >= common.SystemContractPrecompileForkHeight {
+		vmConfig.StatefulPrecompiles = newSystemPrecompiles(tx, statedb, blockHeader)
+	}
 
 	return vm.NewEVM(*evmContext, statedb, chainConfig, *vmConfig)
 }
@@ -46,7 +50,9 @@ func newEVMContext(tx *types.Transaction, header *types.BlockHeader, minerAddres
 	transferFunc := func(db vm.StateDB, sender, recipient common.Address, amount *big.Int) {
 		db.SubBalance(sender, amount)
 
-		if sender.Shard() == recipient.Shard() {
+		// the system contracts called in EVM after fork run in the shard of the tx, other
+		// reserved addresses are not in any shard as before
+		if sender.Shard() == recipient.Shard() || isSystemPrecompile(recipient, header.Height) {
 			db.AddBalance(recipient, amount)
 		}
 	}
@@ -81,3 +87,32 @@ func newEVMContext(tx *types.Transaction, header *types.BlockHeader, minerAddres
 		Difficulty:  new(big.Int).Set(header.Difficulty),
 	}
 }
+
+// systemPrecompile is the system contract called in EVM during the tx execution.
+type systemPrecompile struct {
+	*system.Precompile
+	context *system.Context
+}
+
+func (p *systemPrecompile) Run(caller common.Address, value *big.Int, input []byte, readOnly bool) ([]byte, error) {
+	return p.Precompile.Run(p.context, caller, value, input, readOnly)
+}
+
+// isSystemPrecompile returns true if the address is a system contract callable in EVM at the height
+func isSystemPrecompile(addr common.Address, height uint64) bool {
+	return height >= common.SystemContractPrecompileForkHeight && system.GetPrecompile(addr, height) != nil
+}
+
+// newSystemPrecompiles returns the system contracts activated at the block height,
+// which run against the same statedb of the tx.
+func newSystemPrecompiles(tx *types.Transaction, statedb *StateDB, header *types.BlockHeader) func(common.Address) vm.StatefulPrecompiledContract {
+	context := system.NewContext(tx, statedb.Statedb, header)
+
+	return func(addr common.Address) vm.StatefulPrecompiledContract {
+		if p := system.GetPrecompile(addr, header.Height); p != nil {
+			return &systemPrecompile{p, context}
+		}
+
+		return nil
+	}
+}
@@ -48,7 +48,7 @@ func Process(ctx *Context, height uint64) (*types.Receipt, error) {
 	snapshot := ctx.Statedb.Prepare(ctx.TxIndex)
 
 	// create or execute contract
-	if contract := system.GetContractByAddress(ctx.Tx.Data.To); contract != nil { // system contract
+	if contract := system.GetContractByHeight(ctx.Tx.Data.To, height); contract != nil { // system contract
 		receipt, err = processSystemContract(ctx, contract, snapshot, leftOverGas)
 	} else if ctx.Tx.IsCrossShardTx() && !ctx.Tx.Data.To.IsEVMContract() { // cross shard tx
 		return processCrossShardTransaction(ctx, snapshot)
@@ -79,16 +79,16 @@ func Test_GetReinjectTransaction(t *testing.T) {
 		panic(err)
 	}
 
-	b1 := newTestBlock(bc, bc.genesisBlock.HeaderHash, 1, state.GetNonce(types.TestGenesisAccount.Addr), 4*types.TransactionPreSize)
-	bc.WriteBlock(b1)
+	b1 := newTestBlock(bc, bc.genesisBlock.HeaderHash, genesisBlockHeight+1, state.GetNonce(types.TestGenesisAccount.Addr), 4*types.TransactionPreSize)
+	bc.WriteBlock(b1, nil)
 
 	state, err = bc.GetCurrentState()
 	if err != nil {
 		panic(err)
 	}
 
-	b2 := newTestBlock(bc, bc.genesisBlock.HeaderHash, 1, state.GetNonce(types.TestGenesisAccount.Addr), 3*types.TransactionPreSize)
-	bc.WriteBlock(b2)
+	b2 := newTestBlock(bc, bc.genesisBlock.HeaderHash, genesisBlockHeight+1, state.GetNonce(types.TestGenesisAccount.Addr), 3*types.TransactionPreSize)
+	bc.WriteBlock(b2, nil)
 
 	reinject := pool.getReinjectObject(b1.HeaderHash, b2.HeaderHash)
 
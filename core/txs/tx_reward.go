@@ -12,6 +12,7 @@ import (
 	"github.com/elcn233/go-scdo/common"
 	"github.com/elcn233/go-scdo/common/errors"
 	"github.com/elcn233/go-scdo/consensus"
+	"github.com/elcn233/go-scdo/contract/system"
 	"github.com/elcn233/go-scdo/core/state"
 	"github.com/elcn233/go-scdo/core/types"
 	"github.com/elcn233/go-scdo/crypto"
@@ -101,10 +102,27 @@ func ValidateRewardTx(tx *types.Transaction, header *types.BlockHeader) error {
 	return nil
 }
 
-// ApplyRewardTx applies the reward tx with specified statedb.
-func ApplyRewardTx(tx *types.Transaction, statedb *state.Statedb) (*types.Receipt, error) {
+// ApplyRewardTx applies the reward tx of the block header with specified statedb. The
+// masternode part of the reward is shared by the active masternodes, and goes to the
+// coinbase if none. The validators accused by the evidences in the istanbul header are
+// slashed as well.
+func ApplyRewardTx(tx *types.Transaction, statedb *state.Statedb, header *types.BlockHeader) (*types.Receipt, error) {
+	if header.Consensus == types.IstanbulConsensus && header.Height >= common.ValidatorContractForkHeight {
+		extra, err := types.ExtractIstanbulExtra(header)
+		if err != nil {
+			return nil, err
+		}
+
+		if err = system.SlashEvidences(statedb, extra.Evidences, header.Height); err != nil {
+			return nil, errors.NewStackedError(err, "failed to slash the validators")
+		}
+	}
+
+	coinbase, masternode := consensus.SplitReward(tx.Data.Amount, header.Height)
+	coinbase.Add(coinbase, system.DistributeMasternodeReward(statedb, masternode))
+
 	statedb.CreateAccount(tx.Data.To)
-	statedb.AddBalance(tx.Data.To, tx.Data.Amount)
+	statedb.AddBalance(tx.Data.To, coinbase)
 
 	hash, err := statedb.Hash()
 	if err != nil {
@@ -38,6 +38,7 @@ type ConsensusType uint
 const (
 	PowConsensus ConsensusType = iota
 	IstanbulConsensus
+	CliqueConsensus
 )
 
 // BlockHeader represents the header of a block in the blockchain.
@@ -53,7 +54,7 @@ type BlockHeader struct {
 	Height            uint64         // Height is the number of the block
 	CreateTimestamp   *big.Int       // CreateTimestamp is the timestamp when the block is created
 	// in (z)pow consensus, witness is the nonce that proof whether the block is validate;
-	// in BFT consensus, witness is used to vote for validator candidates and Creator is the candidate address.
+	// in BFT and clique consensus, witness is used to vote for validator candidates and Creator is the candidate address.
 	Witness       []byte
 	SecondWitness []byte
 	Consensus     ConsensusType
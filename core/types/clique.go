@@ -0,0 +1,48 @@
+/**
+* @file
+* @copyright defined in scdo/LICENSE
+ */
+
+package types
+
+import (
+	"errors"
+
+	"github.com/elcn233/go-scdo/common"
+)
+
+var (
+	CliqueExtraVanity = 32 // Fixed number of extra-data bytes reserved for signer vanity
+	CliqueExtraSeal   = 65 // Fixed number of extra-data bytes reserved for signer seal
+
+	// ErrInvalidCliqueHeaderExtra is returned if the extra-data could not hold the vanity,
+	// the signer list and the seal
+	ErrInvalidCliqueHeaderExtra = errors.New("invalid clique header extra-data")
+)
+
+// ExtractCliqueSigners returns the signer list in the extra-data of the given header.
+// The signer list is only filled in the genesis block and the epoch transition blocks.
+func ExtractCliqueSigners(h *BlockHeader) ([]common.Address, error) {
+	length := len(h.ExtraData) - CliqueExtraVanity - CliqueExtraSeal
+	if length < 0 || length%common.AddressLen != 0 {
+		return nil, ErrInvalidCliqueHeaderExtra
+	}
+
+	signers := make([]common.Address, length/common.AddressLen)
+	for i := range signers {
+		copy(signers[i][:], h.ExtraData[CliqueExtraVanity+i*common.AddressLen:])
+	}
+
+	return signers, nil
+}
+
+// CliqueSigHeader returns a copy of the header without the seal, which is the
+// content signed by the clique signer.
+func CliqueSigHeader(h *BlockHeader) *BlockHeader {
+	newHeader := h.Clone()
+	if len(newHeader.ExtraData) >= CliqueExtraSeal {
+		newHeader.ExtraData = newHeader.ExtraData[:len(newHeader.ExtraData)-CliqueExtraSeal]
+	}
+
+	return newHeader
+}
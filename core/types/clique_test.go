@@ -0,0 +1,48 @@
+/**
+* @file
+* @copyright defined in scdo/LICENSE
+ */
+
+package types
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+
+	"github.com/elcn233/go-scdo/common"
+)
+
+func TestExtractCliqueSigners(t *testing.T) {
+	signers := []common.Address{
+		common.BytesToAddress([]byte{1, 1}),
+		common.BytesToAddress([]byte{2, 1}),
+	}
+
+	extra := bytes.Repeat([]byte{0x00}, CliqueExtraVanity)
+	for _, signer := range signers {
+		extra = append(extra, signer[:]...)
+	}
+	extra = append(extra, bytes.Repeat([]byte{0x00}, CliqueExtraSeal)...)
+
+	header := &BlockHeader{Consensus: CliqueConsensus, ExtraData: extra}
+	result, err := ExtractCliqueSigners(header)
+	if err != nil {
+		t.Fatalf("failed to extract signers: %v", err)
+	}
+	if !reflect.DeepEqual(result, signers) {
+		t.Errorf("signers mismatch: have %v, want %v", result, signers)
+	}
+
+	// the seal is stripped from the signed header
+	sigHeader := CliqueSigHeader(header)
+	if len(sigHeader.ExtraData) != len(extra)-CliqueExtraSeal {
+		t.Errorf("extra-data length mismatch: have %v, want %v", len(sigHeader.ExtraData), len(extra)-CliqueExtraSeal)
+	}
+
+	// extra-data without room for the seal
+	header.ExtraData = extra[:CliqueExtraVanity]
+	if _, err = ExtractCliqueSigners(header); err != ErrInvalidCliqueHeaderExtra {
+		t.Errorf("error mismatch: have %v, want %v", err, ErrInvalidCliqueHeaderExtra)
+	}
+}
@@ -0,0 +1,53 @@
+/**
+* @file
+* @copyright defined in scdo/LICENSE
+ */
+
+package types
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/big"
+
+	"github.com/elcn233/go-scdo/common"
+	"github.com/elcn233/go-scdo/crypto"
+)
+
+// GenesisInfo genesis info for generating genesis block, it could be used for initializing account balance
+type GenesisInfo struct {
+	// Accounts accounts info for genesis block used for test
+	// map key is account address -> value is account balance
+	Accounts map[common.Address]*big.Int `json:"accounts,omitempty"`
+
+	// Difficult initial difficulty for mining. Use bigger difficulty as you can. Because block is chosen by total difficulty
+	Difficult int64 `json:"difficult"`
+
+	// ShardNumber is the shard number of genesis block.
+	ShardNumber uint `json:"shard"`
+
+	// CreateTimestamp is the initial time of genesis
+	CreateTimestamp *big.Int `json:"timestamp"`
+
+	// Consensus consensus type
+	Consensus ConsensusType `json:"consensus"`
+
+	// Validators istanbul consensus validators or clique consensus signers
+	Validators []common.Address `json:"validators"`
+
+	// master account
+	Masteraccount common.Address `json:"master"`
+
+	// balance of the master account
+	Balance *big.Int `json:"balance"`
+}
+
+// Hash returns GenesisInfo hash
+func (info *GenesisInfo) Hash() common.Hash {
+	data, err := json.Marshal(info)
+	if err != nil {
+		panic(fmt.Sprintf("Failed to marshal err: %s", err))
+	}
+
+	return crypto.HashBytes(data)
+}
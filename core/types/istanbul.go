@@ -25,19 +25,36 @@ var (
 	ErrInvalidIstanbulHeaderExtra = errors.New("invalid istanbul header extra-data")
 )
 
+// IstanbulEvidence is the proof that a validator signed two conflicting COMMIT messages
+// in the same view. First and Second are the signed message payloads.
+type IstanbulEvidence struct {
+	Validator common.Address
+	First     []byte
+	Second    []byte
+}
+
 type IstanbulExtra struct {
 	Validators    []common.Address
 	Seal          []byte
 	CommittedSeal [][]byte
+	Evidences     []*IstanbulEvidence
 }
 
 // EncodeRLP serializes ist into the Ethereum RLP format.
 func (ist *IstanbulExtra) EncodeRLP(w io.Writer) error {
-	return rlp.Encode(w, []interface{}{
+	fields := []interface{}{
 		ist.Validators,
 		ist.Seal,
 		ist.CommittedSeal,
-	})
+	}
+
+	// evidences are only encoded when present, so that the extra data
+	// of blocks without evidence keeps the same format.
+	if len(ist.Evidences) > 0 {
+		fields = append(fields, ist.Evidences)
+	}
+
+	return rlp.Encode(w, fields)
 }
 
 // DecodeRLP implements rlp.Decoder, and load the istanbul fields from a RLP stream.
@@ -46,11 +63,13 @@ func (ist *IstanbulExtra) DecodeRLP(s *rlp.Stream) error {
 		Validators    []common.Address
 		Seal          []byte
 		CommittedSeal [][]byte
+		Evidences     []*IstanbulEvidence `rlp:"optional"`
 	}
 	if err := s.Decode(&istanbulExtra); err != nil {
 		return err
 	}
 	ist.Validators, ist.Seal, ist.CommittedSeal = istanbulExtra.Validators, istanbulExtra.Seal, istanbulExtra.CommittedSeal
+	ist.Evidences = istanbulExtra.Evidences
 	return nil
 }
 
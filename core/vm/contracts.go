@@ -38,6 +38,14 @@ type PrecompiledContract interface {
 	Run(input []byte) ([]byte, error) // Run runs the precompiled contract
 }
 
+// StatefulPrecompiledContract is the native Go contract that runs against the state,
+// e.g. the system contracts. The caller and value are of the contract call, and only
+// the calls that do not modify the state are allowed if readOnly is true.
+type StatefulPrecompiledContract interface {
+	RequiredGas(input []byte) uint64
+	Run(caller common.Address, value *big.Int, input []byte, readOnly bool) ([]byte, error)
+}
+
 // PrecompiledContractsHomestead contains the default set of pre-compiled Ethereum
 // contracts used in the Frontier and Homestead releases.
 var PrecompiledContractsHomestead = map[common.Address]PrecompiledContract{
@@ -69,6 +77,20 @@ func RunPrecompiledContract(p PrecompiledContract, input []byte, contract *Contr
 	return nil, ErrOutOfGas
 }
 
+// RunStatefulPrecompiledContract runs and evaluates the output of a stateful precompiled contract.
+// It could only be called in the context of its own address, so CALLCODE and DELEGATECALL fail.
+func RunStatefulPrecompiledContract(p StatefulPrecompiledContract, input []byte, contract *Contract, readOnly bool) (ret []byte, err error) {
+	if contract.Address() != *contract.CodeAddr {
+		return nil, ErrStatefulPrecompileContext
+	}
+
+	gas := p.RequiredGas(input)
+	if contract.UseGas(gas) {
+		return p.Run(contract.Caller(), contract.Value(), input, readOnly)
+	}
+	return nil, ErrOutOfGas
+}
+
 // ECRECOVER implemented as a native contract.
 type ecrecover struct{}
 
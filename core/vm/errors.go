@@ -20,11 +20,12 @@ import "errors"
 
 // List execution errors
 var (
-	ErrOutOfGas                 = errors.New("out of gas")
-	ErrCodeStoreOutOfGas        = errors.New("contract creation code storage out of gas")
-	ErrDepth                    = errors.New("max call depth exceeded")
-	ErrTraceLimitReached        = errors.New("the number of logs reached the specified limit")
-	ErrInsufficientBalance      = errors.New("insufficient balance for transfer")
-	ErrContractAddressCollision = errors.New("contract address collision")
-	ErrNoCompatibleInterpreter  = errors.New("no compatible interpreter")
+	ErrOutOfGas                  = errors.New("out of gas")
+	ErrCodeStoreOutOfGas         = errors.New("contract creation code storage out of gas")
+	ErrDepth                     = errors.New("max call depth exceeded")
+	ErrTraceLimitReached         = errors.New("the number of logs reached the specified limit")
+	ErrInsufficientBalance       = errors.New("insufficient balance for transfer")
+	ErrContractAddressCollision  = errors.New("contract address collision")
+	ErrNoCompatibleInterpreter   = errors.New("no compatible interpreter")
+	ErrStatefulPrecompileContext = errors.New("stateful precompiled contract called in the context of another address")
 )
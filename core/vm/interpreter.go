@@ -46,6 +46,10 @@ type Config struct {
 	EWASMInterpreter string
 	// Type of the EVM interpreter
 	EVMInterpreter string
+
+	// StatefulPrecompiles returns the stateful precompiled contract
+	// at the address, or nil if not exists.
+	StatefulPrecompiles func(addr common.Address) StatefulPrecompiledContract
 }
 
 // Interpreter is used to run Ethereum based contracts and will utilise the
@@ -56,12 +56,14 @@ func ToECDSAPub(pub []byte) *ecdsa.PublicKey {
 	return &ecdsa.PublicKey{Curve: S256(), X: x, Y: y}
 }
 
-// PubkeyToString returns the string of the given public key, with prefix 0x
-// func PubkeyToString(pub *ecdsa.PublicKey) string {
-// 	shard := randomshard()
-// 	addr, err := GetAddress(pub, shard)
-// 	return addr.
-// }
+// PubkeyToString returns the address string of the given public key in a random shard, with prefix 0x
+func PubkeyToString(pub *ecdsa.PublicKey) string {
+	addr, err := GetAddress(pub, RandomShard())
+	if err != nil {
+		panic(err)
+	}
+	return addr.Hex()
+}
 
 // Keccak512 calculates and returns the Keccak512 hash of the input data.
 func Keccak512(data ...[]byte) []byte {
@@ -202,26 +204,20 @@ func MustGenerateShardKeyPair(shard uint) (*common.Address, *ecdsa.PrivateKey) {
 	}
 }
 
-// MustGenerateKeyPairNotShard generates and returns a random address and key.
-// Panic on any error.
+// MustGenerateKeyPairNotShard generates and returns a random address and key
+// which is not in the specified shard. Panic on any error.
 func MustGenerateKeyPairNotShard(shard uint) (*common.Address, *ecdsa.PrivateKey) {
 	if shard == 0 || shard > common.ShardCount {
 		panic(fmt.Errorf("invalid shard number, should be between 1 and %v", common.ShardCount))
 	}
 
-	for i := 1; i < 100; i++ {
-		addr, privateKey, err := GenerateKeyPair(shard)
-		if err != nil {
-			panic(err)
-		}
-
-		if addr.Shard() != shard {
-			return addr, privateKey
-		}
+	// addresses are generated in the given shard, so take the next one
+	addr, privateKey, err := GenerateKeyPair(shard%common.ShardCount + 1)
+	if err != nil {
+		panic(err)
 	}
 
-	// panic if not generate shard after 100 times
-	panic("didn't generate key pair after 100 times trying")
+	return addr, privateKey
 }
 
 // CreateAddress creates a new address with the specified address and nonce.
@@ -42,14 +42,14 @@ func Test_ToECDSAPub(t *testing.T) {
 }
 
 func Test_PubkeyToString(t *testing.T) {
-	_, ecdsaPrivKey, err := GenerateKeyPair()
+	_, ecdsaPrivKey, err := GenerateKeyPair(1)
 
 	assert.Equal(t, err, nil)
 	assert.Equal(t, len(PubkeyToString(&ecdsaPrivKey.PublicKey)), 42)
 }
 
 func Test_FromECDSAPub(t *testing.T) {
-	_, ecdsaPrivKey, err := GenerateKeyPair()
+	_, ecdsaPrivKey, err := GenerateKeyPair(1)
 
 	assert.Equal(t, err, nil)
 	assert.Equal(t, len(FromECDSAPub(&ecdsaPrivKey.PublicKey)), 65)
@@ -99,7 +99,8 @@ func Test_GetAddress(t *testing.T) {
 	ecdsaPrivKey, err := ToECDSA([]byte("01234567890123456789012345678901"))
 	assert.Equal(t, err, nil)
 
-	addr := GetAddress(&ecdsaPrivKey.PublicKey)
+	addr, err := GetAddress(&ecdsaPrivKey.PublicKey, 1)
+	assert.Equal(t, err, nil)
 	assert.Equal(t, len(addr), 20)
 }
 
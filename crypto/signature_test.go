@@ -21,7 +21,8 @@ func Test_Sign(t *testing.T) {
 	assert.Equal(t, len(signature.Sig), 65)
 
 	// Succeed to verify signature.
-	signer := GetAddress(&privKey.PublicKey)
+	signer, err := GetAddress(&privKey.PublicKey, 1)
+	assert.Equal(t, err, nil)
 	assert.Equal(t, signature.Verify(*signer, hash.Bytes()), true)
 
 	// Failed to verify signature if msg changed.
@@ -30,6 +31,7 @@ func Test_Sign(t *testing.T) {
 
 	// Failed to verify signature if signer changed.
 	privKey2, _ := GenerateKey()
-	signer2 := GetAddress(&privKey2.PublicKey)
+	signer2, err := GetAddress(&privKey2.PublicKey, 1)
+	assert.Equal(t, err, nil)
 	assert.Equal(t, signature.Verify(*signer2, hash.Bytes()), false)
 }
@@ -127,9 +127,10 @@ func NewLightProtocol(networkID string, txPool TransactionPool, debtPool *core.D
 	log *log.ScdoLog, shard uint) (s *LightProtocol, err error) {
 	s = &LightProtocol{
 		Protocol: p2p.Protocol{
-			Name:    fmt.Sprintf("%s_%d", LightProtoName, shard),
-			Version: LightScdoVersion,
-			Length:  protocolMsgCodeLength,
+			Name:       fmt.Sprintf("%s_%d", LightProtoName, shard),
+			Version:    LightScdoVersion,
+			Length:     protocolMsgCodeLength,
+			ClientOnly: !serverMode,
 		},
 		bServerMode: serverMode,
 		networkID:   networkID,
@@ -333,6 +334,7 @@ handler:
 			err := common.Deserialize(msg.Payload, &query)
 			if err != nil {
 				lp.log.Error("failed to deserialize AnnounceQuery, quit! %s", err)
+				peer.ReportMisbehavior(p2p.PenaltyInvalidMessage, "invalid AnnounceQuery msg")
 				break handler
 			}
 			lp.log.Debug("handleMsg announceRequestCode sendAnnounce,peer:%s", peer.peerStrID)
@@ -345,11 +347,13 @@ handler:
 			var query AnnounceBody
 			if time.Now().Unix()-peer.lastAnnounceCodeTime < 60 {
 				lp.log.Warn("peer lastAnnounceCode less than 60s, peer:%s", peer.peerStrID)
+				peer.ReportMisbehavior(p2p.PenaltyInvalidMessage, "announce too frequently")
 				break handler
 			}
 			err := common.Deserialize(msg.Payload, &query)
 			if err != nil {
 				lp.log.Error("failed to deserialize Announce, quit! %s", err)
+				peer.ReportMisbehavior(p2p.PenaltyInvalidMessage, "invalid Announce msg")
 				break handler
 			}
 			lp.log.Debug("handle msg announce code, peer:%s", peer.peerStrID)
@@ -363,6 +367,7 @@ handler:
 			err := common.Deserialize(msg.Payload, &query)
 			if err != nil {
 				lp.log.Error("failed to deserialize HeaderHashSyncQuery, quit! %s", err)
+				peer.ReportMisbehavior(p2p.PenaltyInvalidMessage, "invalid HeaderHashSyncQuery msg")
 				break handler
 			}
 
@@ -376,6 +381,7 @@ handler:
 			err := common.Deserialize(msg.Payload, &query)
 			if err != nil {
 				lp.log.Error("failed to deserialize syncHashResponseCode, quit! %s", err)
+				peer.ReportMisbehavior(p2p.PenaltyInvalidMessage, "invalid HeaderHashSync msg")
 				break handler
 			}
 
@@ -389,6 +395,7 @@ handler:
 			err := common.Deserialize(msg.Payload, &query)
 			if err != nil {
 				lp.log.Error("failed to deserialize DownloadHeaderQuery, quit! %s", err)
+				peer.ReportMisbehavior(p2p.PenaltyInvalidMessage, "invalid DownloadHeaderQuery msg")
 				break handler
 			}
 
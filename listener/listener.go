@@ -40,21 +40,31 @@ func NewContractEventABI(abiPath string, contract common.Address, eventNames ...
 		return nil, ErrInvalidArguments
 	}
 
-	if contract.Equal(common.EmptyAddress) {
+	file, err := ioutil.ReadFile(abiPath)
+	if err != nil {
+		return nil, errors.NewStackedError(err, "failed to read abi file")
+	}
+
+	return NewContractEventABIFromJSON(string(file), contract, eventNames...)
+}
+
+// NewContractEventABIFromJSON returns a ContractEventABI instance of the abi json.
+// The contract is either an EVM contract or a system contract.
+func NewContractEventABIFromJSON(abiJSON string, contract common.Address, eventNames ...string) (*ContractEventABI, error) {
+	if len(abiJSON) == 0 || len(eventNames) == 0 {
 		return nil, ErrInvalidArguments
 	}
 
-	// ensure the contract address is EVM contract
-	if !contract.IsEVMContract() {
-		return nil, fmt.Errorf("the address is not EVM contract, %v", contract)
+	if contract.Equal(common.EmptyAddress) {
+		return nil, ErrInvalidArguments
 	}
 
-	file, err := ioutil.ReadFile(abiPath)
-	if err != nil {
-		return nil, errors.NewStackedError(err, "failed to read abi file")
+	// ensure the contract address is EVM contract or system contract
+	if !contract.IsEVMContract() && !contract.IsReserved() {
+		return nil, fmt.Errorf("the address is not EVM contract, %v", contract)
 	}
 
-	parser, err := abi.JSON(strings.NewReader(string(file)))
+	parser, err := abi.JSON(strings.NewReader(abiJSON))
 	if err != nil {
 		return nil, errors.NewStackedError(err, "failed to parse abi")
 	}
@@ -69,7 +79,7 @@ func NewContractEventABI(abiPath string, contract common.Address, eventNames ...
 	for _, eventName := range eventNames {
 		event, ok := parser.Events[eventName]
 		if !ok {
-			return nil, fmt.Errorf("event name %v not found in ABI file %v", eventName, abiPath)
+			return nil, fmt.Errorf("event name %v not found in ABI", eventName)
 		}
 		c.topicEventNames[event.Id()] = eventName
 	}
@@ -34,6 +34,9 @@ var (
 	// ErrNodeIsSyncing is returned when the node is syncing
 	ErrNodeIsSyncing = errors.New("can not start miner when syncing")
 
+	// errEmptyTask is returned when the task has no transactions or debts to seal
+	errEmptyTask = errors.New("no transactions or debts to seal")
+
 	minerCount = 0
 )
 
@@ -50,6 +53,7 @@ type Miner struct {
 	canStart int32
 	stopped  int32
 	stopper  int32 // manually stop miner
+	idle     int32 // started but waiting for transactions or debts to seal
 	poolMode bool
 	wg       sync.WaitGroup
 	stopChan chan struct{}
@@ -133,9 +137,9 @@ func (miner *Miner) SetStopper(stopper int32) {
 // CanStart is true when the miner is stopped and stopper == 0 and
 // canStart == 1
 func (miner *Miner) CanStart() bool {
+	stopped := atomic.LoadInt32(&miner.stopped) == 1 && atomic.LoadInt32(&miner.mining) == 0
 	if atomic.LoadInt32(&miner.stopper) == 0 &&
-		atomic.LoadInt32(&miner.stopped) == 1 &&
-		atomic.LoadInt32(&miner.mining) == 0 &&
+		(stopped || atomic.LoadInt32(&miner.idle) == 1) &&
 		atomic.LoadInt32(&miner.canStart) == 1 {
 		return true
 	} else {
@@ -179,8 +183,21 @@ func (miner *Miner) handleMsg() {
 
 // Start is used to start the miner
 func (miner *Miner) Start() error {
+	// an idle miner is restarted by the next transaction or debt
+	if atomic.LoadInt32(&miner.mining) == 1 && atomic.LoadInt32(&miner.idle) == 0 {
+		return ErrMinerIsRunning
+	}
+	if atomic.LoadInt32(&miner.canStart) == 0 {
+		return ErrNodeIsSyncing
+	}
+
 	miner.stopChan = make(chan struct{})
 
+	// the miner is running once started, even if waiting for transactions or debts
+	atomic.StoreInt32(&miner.idle, 0)
+	atomic.StoreInt32(&miner.mining, 1)
+	atomic.StoreInt32(&miner.stopped, 0)
+
 	if istanbul, ok := miner.engine.(consensus.Istanbul); ok {
 		if err := istanbul.Start(miner.scdo.BlockChain(), miner.scdo.BlockChain().CurrentBlock, nil); err != nil {
 			panic(fmt.Sprintf("failed to start istanbul engine: %v", err))
@@ -189,14 +206,21 @@ func (miner *Miner) Start() error {
 
 	// try to prepare the first block
 	if err := miner.prepareNewBlock(miner.recv); err != nil {
+		if err == errEmptyTask {
+			// restarted by the next inserted transaction or debt
+			atomic.StoreInt32(&miner.idle, 1)
+			miner.log.Debug("waiting for new transactions or debts to seal")
+			return nil
+		}
+
+		atomic.StoreInt32(&miner.mining, 0)
+		atomic.StoreInt32(&miner.stopped, 1)
 		miner.log.Warn(err.Error())
 		return err
 	}
 
 	go miner.waitBlock()
 	//minerCount++
-	atomic.StoreInt32(&miner.mining, 1)
-	atomic.StoreInt32(&miner.stopped, 0)
 	miner.log.Info("Miner started")
 
 	return nil
@@ -206,6 +230,7 @@ func (miner *Miner) Start() error {
 func (miner *Miner) Stop() {
 	// set stopped to 1 to prevent restart
 	atomic.StoreInt32(&miner.stopped, 1)
+	atomic.StoreInt32(&miner.idle, 0)
 	miner.stopMining()
 	atomic.StoreInt32(&miner.mining, 0)
 	if istanbul, ok := miner.engine.(consensus.Istanbul); ok {
@@ -350,6 +375,11 @@ func (miner *Miner) prepareNewBlock(recv chan *types.Block) error {
 		return fmt.Errorf("failed to apply transaction %s", err)
 	}
 
+	// instant sealing engines only seal a block for every batch of transactions or debts
+	if sealer, ok := miner.engine.(consensus.InstantSealer); ok && !sealer.AllowEmptyBlocks() && miner.current.isEmpty() {
+		return errEmptyTask
+	}
+
 	if miner.poolMode {
 		miner.log.Info("create a new task for the pool, height:%d, difficult:%d", header.Height, header.Difficulty)
 		preBlock := miner.current.generateBlock()
@@ -99,18 +99,25 @@ func (task *Task) chooseDebts(scdo ScdoBackend, statedb *state.Statedb, log *log
 		if err != nil {
 			return size
 		}
+		dropped := false
 		for _, d := range debts {
 			log.Debug("debt hash: %v", d.Hash)
 			err := scdo.BlockChain().ApplyDebtWithoutVerify(statedb, d, task.coinbase, preHeader, commonAncestor)
 			if err != nil {
 				log.Debug("apply debt error %s", err)
 				scdo.DebtPool().RemoveDebtByHash(d.Hash)
+				dropped = true
 				continue
 			}
 
 			size = size - d.Size()
 			task.debts = append(task.debts, d)
 		}
+
+		// the pool resets the processing debts on every fetch, so only fetch again to fill the dropped ones
+		if !dropped {
+			break
+		}
 	}
 
 	// exit
@@ -127,7 +134,7 @@ func (task *Task) handleMinerRewardTx(statedb *state.Statedb) (*big.Int, error)
 		return nil, err
 	}
 
-	rewardTxReceipt, err := txs.ApplyRewardTx(rewardTx, statedb)
+	rewardTxReceipt, err := txs.ApplyRewardTx(rewardTx, statedb, task.header)
 	if err != nil {
 		return nil, err
 	}
@@ -154,11 +161,13 @@ func (task *Task) chooseTransactions(scdo ScdoBackend, statedb *state.Statedb, l
 			break
 		}
 
+		dropped := false
 		for _, tx := range txs {
 			if err := tx.Validate(statedb, task.header.Height); err != nil {
 				scdo.TxPool().RemoveTransaction(tx.Hash)
 				log.Error("failed to validate tx %s, for %s", tx.Hash.Hex(), err)
 				txsSize = txsSize - tx.Size()
+				dropped = true
 				continue
 			}
 
@@ -167,6 +176,7 @@ func (task *Task) chooseTransactions(scdo ScdoBackend, statedb *state.Statedb, l
 				scdo.TxPool().RemoveTransaction(tx.Hash)
 				log.Error("failed to apply tx %s, %s", tx.Hash.Hex(), err)
 				txsSize = txsSize - tx.Size()
+				dropped = true
 				continue
 			}
 
@@ -176,12 +186,22 @@ func (task *Task) chooseTransactions(scdo ScdoBackend, statedb *state.Statedb, l
 		}
 
 		size -= txsSize
+
+		// the pool resets the processing txs on every fetch, so only fetch again to fill the dropped ones
+		if !dropped {
+			break
+		}
 	}
 
 	// exit
 	memory.Print(log, "task chooseTransactions exit", now, true)
 }
 
+// isEmpty returns whether the task has no transactions except the reward tx and no debts
+func (task *Task) isEmpty() bool {
+	return len(task.txs) <= 1 && len(task.debts) == 0
+}
+
 // generateBlock builds a block from task
 func (task *Task) generateBlock() *types.Block {
 	return types.NewBlock(task.header, task.txs, task.receipts, task.debts)
@@ -62,6 +62,28 @@ func Test_handleMinerRewardTx(t *testing.T) {
 	assert.Equal(t, reward, consensus.GetReward(task.header.Height))
 }
 
+func Test_TaskIsEmpty(t *testing.T) {
+	db, remove := leveldb.NewTestDatabase()
+	defer remove()
+
+	statedb, err := state.NewStatedb(common.EmptyHash, db)
+	if err != nil {
+		panic(err)
+	}
+
+	task := getTask(10)
+	task.header = newTestBlockHeader()
+	assert.Equal(t, task.isEmpty(), true)
+
+	// the reward tx only
+	_, err = task.handleMinerRewardTx(statedb)
+	assert.Equal(t, err, nil)
+	assert.Equal(t, task.isEmpty(), true)
+
+	task.debts = append(task.debts, types.NewTestDebtWithTargetShard(1))
+	assert.Equal(t, task.isEmpty(), false)
+}
+
 func Test_ChooseTransactionAndDebts(t *testing.T) {
 	verifier := types.NewTestVerifier(true, false, nil)
 	task, debtPool := testWithBackend(verifier, t)
@@ -108,7 +130,7 @@ func testWithBackend(verifier types.DebtVerifier, t *testing.T) (*types.Block, *
 	assert.Equal(t, err, nil)
 
 	log := log.GetLogger("test_task")
-	err = task.applyTransactionsAndDebts(backend, state, log)
+	err = task.applyTransactionsAndDebts(backend, state, bc.AccountDB(), log)
 	assert.Equal(t, err, nil)
 
 	block := task.generateBlock()
@@ -126,7 +148,7 @@ func testWithBackend(verifier types.DebtVerifier, t *testing.T) (*types.Block, *
 
 	wg.Wait()
 
-	err = bc.WriteBlock(resultBlock)
+	err = bc.WriteBlock(resultBlock, backend.TxPool().Pool)
 	assert.Equal(t, nil, err)
 
 	return resultBlock, debtPool
@@ -9,6 +9,9 @@ import (
 	"crypto/ecdsa"
 
 	"github.com/elcn233/go-scdo/common"
+	"github.com/elcn233/go-scdo/consensus/clique"
+	"github.com/elcn233/go-scdo/consensus/dev"
+	"github.com/elcn233/go-scdo/consensus/istanbul"
 	"github.com/elcn233/go-scdo/core"
 	"github.com/elcn233/go-scdo/log/comm"
 	"github.com/elcn233/go-scdo/metrics"
@@ -40,6 +43,15 @@ type Config struct {
 
 	// metrics config info
 	MetricsConfig *metrics.Config
+
+	// The configuration of istanbul consensus engine
+	IstanbulConfig *istanbul.Config
+
+	// The configuration of clique consensus engine
+	CliqueConfig *clique.Config
+
+	// The configuration of dev consensus engine
+	DevConfig *dev.Config
 }
 
 // IpcConfig config for ipc rpc service
@@ -0,0 +1,152 @@
+/**
+*  @file
+*  @copyright defined in scdo/LICENSE
+ */
+
+package p2p
+
+import (
+	"errors"
+
+	"github.com/golang/snappy"
+	"github.com/klauspost/compress/zstd"
+)
+
+const (
+	compressNone   = "none"
+	compressSnappy = "snappy"
+	compressZstd   = "zstd"
+
+	// payloads not larger than compressThreshold are sent as they are
+	compressThreshold = 256
+)
+
+const (
+	rawPayloadFlag byte = iota
+	compressedPayloadFlag
+)
+
+// supportedCompressions are the compression algorithms announced in the handshake, in preference order
+var supportedCompressions = []string{compressZstd, compressSnappy}
+
+var (
+	errPayloadFlag = errors.New("invalid payload compression flag")
+
+	zstdEncoder, _ = zstd.NewWriter(nil, zstd.WithEncoderLevel(zstd.SpeedFastest), zstd.WithEncoderConcurrency(1))
+	zstdDecoder, _ = zstd.NewReader(nil, zstd.WithDecoderMaxMemory(uint64(maxSize)), zstd.WithDecoderConcurrency(0))
+)
+
+// compressor compresses the message payloads of a connection
+type compressor interface {
+	name() string
+	compress(src []byte) []byte
+	decompress(src []byte) ([]byte, error)
+}
+
+type snappyCompressor struct{}
+
+func (snappyCompressor) name() string { return compressSnappy }
+
+func (snappyCompressor) compress(src []byte) []byte {
+	return snappy.Encode(nil, src)
+}
+
+func (snappyCompressor) decompress(src []byte) ([]byte, error) {
+	size, err := snappy.DecodedLen(src)
+	if err != nil {
+		return nil, err
+	}
+
+	if uint32(size) > maxSize {
+		return nil, errSize
+	}
+
+	return snappy.Decode(nil, src)
+}
+
+type zstdCompressor struct{}
+
+func (zstdCompressor) name() string { return compressZstd }
+
+func (zstdCompressor) compress(src []byte) []byte {
+	return zstdEncoder.EncodeAll(src, nil)
+}
+
+func (zstdCompressor) decompress(src []byte) ([]byte, error) {
+	return zstdDecoder.DecodeAll(src, nil)
+}
+
+func newCompressor(name string) compressor {
+	switch name {
+	case compressSnappy:
+		return snappyCompressor{}
+	case compressZstd:
+		return zstdCompressor{}
+	default:
+		return nil
+	}
+}
+
+// negotiateCompression selects the compression used by the connection.
+// The dialing side's preference order wins so that both sides pick the same algorithm.
+// Returns nil if the peers have no compression in common.
+func negotiateCompression(local, remote []string, initiator bool) compressor {
+	preferred, other := local, remote
+	if !initiator {
+		preferred, other = remote, local
+	}
+
+	for _, name := range preferred {
+		for _, o := range other {
+			if name == o {
+				if c := newCompressor(name); c != nil {
+					return c
+				}
+			}
+		}
+	}
+
+	return nil
+}
+
+// compressionName returns the name of the compressor, none if it is nil
+func compressionName(c compressor) string {
+	if c == nil {
+		return compressNone
+	}
+
+	return c.name()
+}
+
+// encodePayload compresses the payload if it is larger than compressThreshold
+// and prepends the payload flag. Compression is skipped if it doesn't reduce the size.
+func encodePayload(c compressor, payload []byte) []byte {
+	if len(payload) > compressThreshold {
+		compressed := c.compress(payload)
+		metricsCompressRawBytesMeter.Mark(int64(len(payload)))
+		metricsCompressCompressedBytesMeter.Mark(int64(len(compressed)))
+		metricsCompressRatioHistogram.Update(int64(len(compressed)) * 100 / int64(len(payload)))
+
+		if len(compressed) < len(payload) {
+			return append([]byte{compressedPayloadFlag}, compressed...)
+		}
+	}
+
+	return append([]byte{rawPayloadFlag}, payload...)
+}
+
+// decodePayload decompresses the payload according to its payload flag
+func decodePayload(c compressor, payload []byte) ([]byte, error) {
+	if len(payload) == 0 {
+		return nil, errPayloadFlag
+	}
+
+	switch payload[0] {
+	case rawPayloadFlag:
+		return payload[1:], nil
+	case compressedPayloadFlag:
+		return c.decompress(payload[1:])
+	default:
+		return nil, errPayloadFlag
+	}
+}
@@ -0,0 +1,90 @@
+/**
+*  @file
+*  @copyright defined in scdo/LICENSE
+ */
+
+package p2p
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/elcn233/go-scdo/log"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_NegotiateCompression(t *testing.T) {
+	assert.Equal(t, compressionName(negotiateCompression(supportedCompressions, supportedCompressions, true)), compressZstd)
+	assert.Equal(t, compressionName(negotiateCompression(supportedCompressions, []string{compressSnappy}, true)), compressSnappy)
+	assert.Equal(t, compressionName(negotiateCompression(supportedCompressions, nil, true)), compressNone)
+	assert.Equal(t, compressionName(negotiateCompression(supportedCompressions, []string{"lz4"}, false)), compressNone)
+
+	// the dialing side's preference wins
+	remote := []string{compressSnappy, compressZstd}
+	assert.Equal(t, compressionName(negotiateCompression(supportedCompressions, remote, true)), compressZstd)
+	assert.Equal(t, compressionName(negotiateCompression(supportedCompressions, remote, false)), compressSnappy)
+}
+
+func Test_EncodeAndDecodePayload(t *testing.T) {
+	small := []byte(getRandomString(compressThreshold))
+	large := bytes.Repeat([]byte("scdo"), compressThreshold)
+
+	for _, name := range supportedCompressions {
+		c := newCompressor(name)
+
+		encoded := encodePayload(c, small)
+		assert.Equal(t, encoded[0], rawPayloadFlag)
+		decoded, err := decodePayload(c, encoded)
+		assert.Equal(t, err, nil)
+		assert.Equal(t, decoded, small)
+
+		encoded = encodePayload(c, large)
+		assert.Equal(t, encoded[0], compressedPayloadFlag)
+		assert.Equal(t, len(encoded) < len(large), true)
+		decoded, err = decodePayload(c, encoded)
+		assert.Equal(t, err, nil)
+		assert.Equal(t, decoded, large)
+
+		encoded = encodePayload(c, nil)
+		decoded, err = decodePayload(c, encoded)
+		assert.Equal(t, err, nil)
+		assert.Equal(t, len(decoded), 0)
+
+		_, err = decodePayload(c, []byte{2, 1})
+		assert.Equal(t, err, errPayloadFlag)
+	}
+}
+
+func Test_Conn_CompressedMsg(t *testing.T) {
+	con, ln, err := newConnection()
+	defer ln.Close()
+	defer con.close()
+	assert.Equal(t, err, nil)
+
+	fd1, err := ln.Accept()
+	assert.Equal(t, err, nil)
+	con1 := &connection{fd: fd1, log: log.GetLogger("p2p")}
+	defer con1.close()
+
+	con.session, con1.session = newTestSessions(t)
+	con.compressor, con1.compressor = newCompressor(compressSnappy), newCompressor(compressSnappy)
+
+	payload := bytes.Repeat([]byte("scdo"), compressThreshold)
+	msg := &Message{Code: 5, Payload: payload}
+	err = con.WriteMsg(msg)
+	assert.Equal(t, err, nil)
+	assert.Equal(t, msg.Payload, payload)
+
+	recv, err := con1.ReadMsg()
+	assert.Equal(t, err, nil)
+	assert.Equal(t, recv.Code, uint16(5))
+	assert.Equal(t, recv.Payload, payload)
+
+	err = con1.WriteMsg(&Message{Code: ctlMsgPingCode})
+	assert.Equal(t, err, nil)
+
+	recv, err = con.ReadMsg()
+	assert.Equal(t, err, nil)
+	assert.Equal(t, recv.Code, ctlMsgPingCode)
+	assert.Equal(t, len(recv.Payload), 0)
+}
@@ -53,6 +53,12 @@ type connection struct {
 	// writeErr if error appeared, tcp connection needs to be closed
 	writeErr error
 
+	// session encrypts the frames once the handshake is done, nil before that
+	session *session
+
+	// compressor negotiated in the handshake, nil if the payloads are not compressed
+	compressor compressor
+
 	// log
 	log *log.ScdoLog
 }
@@ -133,10 +139,32 @@ func (c *connection) close() {
 }
 
 // ReadMsg read msg with a full Message block
-func (c *connection) ReadMsg() (msgRecv *Message, err error) {
+func (c *connection) ReadMsg() (*Message, error) {
 	c.rmutux.Lock()
 	defer c.rmutux.Unlock()
 
+	msgRecv, err := c.readFrame()
+	if err != nil || c.compressor == nil {
+		return msgRecv, err
+	}
+
+	payload, err := decodePayload(c.compressor, msgRecv.Payload)
+	if err != nil {
+		c.log.Debug("Failed to decode payload, sender is %s, err= %s", c.fd.RemoteAddr().String(), err)
+
+		return &Message{}, err
+	}
+
+	msgRecv.Payload = nil
+	if len(payload) > 0 {
+		msgRecv.Payload = payload
+	}
+
+	return msgRecv, nil
+}
+
+// readFrame reads a single frame from the connection
+func (c *connection) readFrame() (msgRecv *Message, err error) {
 	if c.writeErr != nil {
 		c.log.Debug("conn ReadMsg writeErr not nil. sender is %s, err= %s. ", c.fd.RemoteAddr().String(), c.writeErr)
 		err = c.writeErr
@@ -162,6 +190,10 @@ func (c *connection) ReadMsg() (msgRecv *Message, err error) {
 		return &Message{}, errMagic
 	}
 
+	if c.session != nil {
+		return c.readSealedMsg(headbuff, size)
+	}
+
 	if size > maxSize {
 		c.log.Debug("Failed to get data, payload size %d exceeds the limit %d, sender is %s", size, maxSize, c.fd.RemoteAddr().String())
 
@@ -174,11 +206,6 @@ func (c *connection) ReadMsg() (msgRecv *Message, err error) {
 
 			return &Message{}, err
 		}
-
-		/*todo disable zip
-		if err = msgRecv.UnZip(); err != nil {
-			return &Message{}, err
-		}*/
 	}
 
 	metricsReceiveMessageCountMeter.Mark(1)
@@ -192,11 +219,22 @@ func (c *connection) WriteMsg(msg *Message) error {
 	c.wmutux.Lock()
 	defer c.wmutux.Unlock()
 
-	/*	todo disable zip
-		if err := msg.Zip(); err != nil {
-				return err
-			}
-	*/
+	if c.compressor != nil {
+		// do not modify the message, it may be shared with other peers
+		msg = &Message{
+			Code:    msg.Code,
+			Payload: encodePayload(c.compressor, msg.Payload),
+		}
+	}
+
+	return c.writeFrame(msg)
+}
+
+// writeFrame writes the message as a single frame to the connection
+func (c *connection) writeFrame(msg *Message) error {
+	if c.session != nil {
+		return c.writeSealedMsg(msg)
+	}
 
 	b := make([]byte, headBuffLength)
 	binary.BigEndian.PutUint32(b[headBuffSizeStart:headBuffSizeEnd], uint32(len(msg.Payload)))
@@ -218,3 +256,58 @@ func (c *connection) WriteMsg(msg *Message) error {
 
 	return nil
 }
+
+// readSealedMsg reads the encrypted body of a frame whose header is already read.
+// The message code is carried inside the encrypted body, the header is authenticated.
+func (c *connection) readSealedMsg(headbuff []byte, size uint32) (*Message, error) {
+	if size > maxSize+uint32(c.session.overhead())+2 {
+		c.log.Debug("Failed to get data, frame size %d exceeds the limit %d, sender is %s", size, maxSize, c.fd.RemoteAddr().String())
+
+		return &Message{}, errSize
+	}
+
+	sealed := make([]byte, size)
+	if err := c.readFull(sealed); err != nil {
+		return &Message{}, err
+	}
+
+	code, payload, err := c.session.open(headbuff, sealed)
+	if err != nil {
+		c.log.Debug("Failed to open frame, sender is %s, err= %s", c.fd.RemoteAddr().String(), err)
+
+		return &Message{}, err
+	}
+
+	metricsReceiveMessageCountMeter.Mark(1)
+	metricsReceivePortSpeedMeter.Mark(headBuffLength + int64(size))
+
+	msgRecv := &Message{Code: code}
+	if len(payload) > 0 {
+		msgRecv.Payload = payload
+	}
+
+	return msgRecv, nil
+}
+
+// writeSealedMsg encrypts the message and writes it as a single frame.
+func (c *connection) writeSealedMsg(msg *Message) error {
+	size := 2 + len(msg.Payload) + c.session.overhead()
+
+	b := make([]byte, headBuffLength, headBuffLength+size)
+	binary.BigEndian.PutUint32(b[headBuffSizeStart:headBuffSizeEnd], uint32(size))
+	binary.BigEndian.PutUint16(b[headBuffMagicStart:headBuffMagicEnd], magicNumber)
+
+	sealed, err := c.session.seal(b, msg.Code, msg.Payload)
+	if err != nil {
+		return err
+	}
+
+	if err := c.writeFull(append(b, sealed...)); err != nil {
+		return err
+	}
+
+	metricsSendMessageCountMeter.Mark(1)
+	metricsSendPortSpeedMeter.Mark(headBuffLength + int64(size))
+
+	return nil
+}
@@ -0,0 +1,134 @@
+/**
+*  @file
+*  @copyright defined in scdo/LICENSE
+ */
+
+package discovery
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/elcn233/go-scdo/common"
+	"github.com/elcn233/go-scdo/crypto"
+)
+
+const (
+	crawlConcurrentNumber = 16
+	crawlInterval         = 500 * time.Millisecond // sleep between the batches of the crawl queries
+)
+
+// CrawledNode is a node found by the crawler
+type CrawledNode struct {
+	Node      *Node
+	Responded bool      // whether the node answered a FIND_NODE query
+	LastSeen  time.Time // the last time the node was found or answered
+}
+
+// Crawler walks the network with FIND_NODE queries. Unlike the database, which only
+// keeps the nodes accepted by the Kademlia table, the crawler keeps every node it
+// finds, so that it can be used to map the whole network.
+type Crawler struct {
+	u *udp
+
+	lock  sync.Mutex
+	nodes map[common.Address]*CrawledNode
+}
+
+// NewCrawler creates the crawler, it starts with the known nodes of the udp service
+func NewCrawler(u *UDP) *Crawler {
+	c := &Crawler{
+		u:     u.udp,
+		nodes: make(map[common.Address]*CrawledNode),
+	}
+
+	c.add(u.trustNodes)
+	for _, n := range u.db.GetCopy() {
+		c.add([]*Node{n})
+	}
+
+	return c
+}
+
+func (c *Crawler) add(nodes []*Node) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	now := time.Now()
+	for _, n := range nodes {
+		if n == nil || n.ID.Equal(c.u.self.ID) {
+			continue
+		}
+
+		if cn, ok := c.nodes[n.ID]; ok {
+			cn.LastSeen = now
+			continue
+		}
+
+		c.nodes[n.ID] = &CrawledNode{Node: n, LastSeen: now}
+	}
+}
+
+func (c *Crawler) responded(id common.Address) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if cn, ok := c.nodes[id]; ok {
+		cn.Responded = true
+		cn.LastSeen = time.Now()
+	}
+}
+
+// Round sends a FIND_NODE query with a random target to every known node and
+// returns the number of the queries. The answers are collected asynchronously,
+// the nodes found are queried in the next round. A node only answers with the
+// nodes closer to the target than itself, so that it takes several rounds to
+// learn all the neighbors of the node.
+func (c *Crawler) Round() int {
+	nodes := c.Nodes()
+	for i, cn := range nodes {
+		target, err := crypto.GenerateRandomAddress()
+		if err != nil {
+			c.u.log.Error(err.Error())
+			continue
+		}
+
+		to := cn.Node
+		f := &findNode{
+			Version: discoveryProtocolVersion,
+			SelfID:  c.u.self.ID,
+			QueryID: *target,
+			to:      to,
+			found: func(found []*Node) {
+				c.responded(to.ID)
+				c.add(found)
+			},
+		}
+		f.send(c.u)
+
+		if (i+1)%crawlConcurrentNumber == 0 {
+			time.Sleep(crawlInterval)
+		}
+	}
+
+	return len(nodes)
+}
+
+// Nodes returns the crawled nodes sorted by id
+func (c *Crawler) Nodes() []*CrawledNode {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	nodes := make([]*CrawledNode, 0, len(c.nodes))
+	for _, cn := range c.nodes {
+		copied := *cn
+		nodes = append(nodes, &copied)
+	}
+
+	sort.Slice(nodes, func(i, j int) bool {
+		return nodes[i].Node.ID.Hex() < nodes[j].Node.ID.Hex()
+	})
+
+	return nodes
+}
@@ -0,0 +1,52 @@
+/**
+*  @file
+*  @copyright defined in scdo/LICENSE
+ */
+
+package discovery
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/elcn233/go-scdo/crypto"
+	"github.com/stretchr/testify/assert"
+)
+
+func newServingUDP(port int) *udp {
+	addr := &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1), Port: port}
+	u := newUDP(*crypto.MustGenerateShardAddress(1), addr, 1)
+	go u.readLoop()
+	go u.loopReply()
+	go u.sendLoop()
+
+	return u
+}
+
+func Test_Crawler_Round(t *testing.T) {
+	crawler, remote := newServingUDP(9781), newServingUDP(9782)
+	defer crawler.conn.Close()
+	defer remote.conn.Close()
+
+	// the node only known by the remote node
+	hidden := MustNewNodeWithAddr(*crypto.MustGenerateShardAddress(1), "127.0.0.1:9783", 1)
+	remote.addNode(hidden, false)
+
+	crawler.trustNodes = []*Node{remote.self}
+	c := NewCrawler(&UDP{udp: crawler})
+	assert.Equal(t, len(c.Nodes()), 1)
+
+	// the remote node only answers with the nodes closer to the random target
+	for i := 0; i < 30 && len(c.Nodes()) < 2; i++ {
+		assert.Equal(t, c.Round(), 1)
+		time.Sleep(20 * time.Millisecond)
+	}
+
+	nodes := c.Nodes()
+	assert.Equal(t, len(nodes), 2)
+	for _, cn := range nodes {
+		// only the remote node is queried and answered
+		assert.Equal(t, cn.Responded, cn.Node.ID == remote.self.ID)
+	}
+}
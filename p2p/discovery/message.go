@@ -23,6 +23,8 @@ const (
 	neighborsMsgType     msgType = 4
 	findShardNodeMsgType msgType = 5
 	shardNodeMsgType     msgType = 6
+	findRecordMsgType    msgType = 7
+	recordMsgType        msgType = 8
 )
 
 func codeToStr(code msgType) string {
@@ -39,6 +41,10 @@ func codeToStr(code msgType) string {
 		return "findShardNodeMsgType"
 	case shardNodeMsgType:
 		return "shardNodeMsgType"
+	case findRecordMsgType:
+		return "findRecordMsgType"
+	case recordMsgType:
+		return "recordMsgType"
 	default:
 		return "unknown"
 	}
@@ -53,6 +59,13 @@ type ping struct {
 	SelfID    common.Address
 	SelfShard uint
 
+	// external endpoint of the sender behind NAT, empty if unknown
+	ExternalIP   net.IP `rlp:"optional"`
+	ExternalPort uint16 `rlp:"optional"`
+
+	// sequence number of the sender's node record, 0 if the sender has no record
+	RecordSeq uint64 `rlp:"optional"`
+
 	to *Node
 }
 
@@ -60,6 +73,13 @@ type pong struct {
 	Version   uint // check discoveryProtocolVersion
 	SelfID    common.Address
 	SelfShard uint
+
+	// external endpoint of the sender behind NAT, empty if unknown
+	ExternalIP   net.IP `rlp:"optional"`
+	ExternalPort uint16 `rlp:"optional"`
+
+	// sequence number of the sender's node record, 0 if the sender has no record
+	RecordSeq uint64 `rlp:"optional"`
 }
 
 type findNode struct {
@@ -67,7 +87,8 @@ type findNode struct {
 	SelfID  common.Address
 	QueryID common.Address // the ID we want to query in Kademlia
 
-	to *Node // the node that send request to
+	to    *Node               // the node that send request to
+	found func(nodes []*Node) // called with the nodes in the response if set
 }
 
 type neighbors struct {
@@ -89,6 +110,12 @@ type shardNode struct {
 	Nodes        []*rpcNode
 }
 
+// findRecord requests the node record of the receiver
+type findRecord struct {
+	Version uint
+	SelfID  common.Address
+}
+
 type rpcNode struct {
 	SelfID  common.Address
 	IP      net.IP
@@ -109,6 +136,17 @@ func convertToRPCNode(n *Node) *rpcNode {
 	}
 }
 
+// advertisedAddr returns the external endpoint advertised by the node. The advertised
+// endpoint is only accepted if its ip is the one the message comes from, so that
+// a node can't direct the traffic to other hosts.
+func advertisedAddr(from *net.UDPAddr, ip net.IP, port uint16) *net.UDPAddr {
+	if port == 0 || !ip.Equal(from.IP) {
+		return from
+	}
+
+	return &net.UDPAddr{IP: from.IP, Port: int(port)}
+}
+
 func byteToMsgType(data byte) msgType {
 	return msgType(data)
 }
@@ -133,7 +171,7 @@ func (m *ping) handle(t *udp, from *net.UDPAddr) {
 		return
 	}
 
-	node := NewNodeWithAddr(m.SelfID, from, m.SelfShard)
+	node := NewNodeWithAddr(m.SelfID, advertisedAddr(from, m.ExternalIP, m.ExternalPort), m.SelfShard)
 
 	// just allows valid shards to be added in table
 	if isShardValid(node.Shard) {
@@ -145,9 +183,12 @@ func (m *ping) handle(t *udp, from *net.UDPAddr) {
 			SelfID:    t.self.ID,
 			SelfShard: t.self.Shard,
 		}
+		resp.ExternalIP, resp.ExternalPort = t.externalEndpoint()
+		resp.RecordSeq = t.localRecordSeq()
 
 		t.log.Debug("received [pingMsg] and send [pongMsg] to: %s", node)
-		t.sendMsg(pongMsgType, resp, node.ID, node.GetUDPAddr())
+		t.sendMsg(pongMsgType, resp, node.ID, from)
+		t.checkRecordSeq(node.ID, m.RecordSeq, from)
 	}
 }
 
@@ -161,9 +202,10 @@ func (m *ping) send(t *udp) {
 
 		callback: func(resp interface{}, addr *net.UDPAddr) (done bool) {
 			r := resp.(*pong)
-			n := NewNodeWithAddr(r.SelfID, addr, r.SelfShard)
+			n := NewNodeWithAddr(r.SelfID, advertisedAddr(addr, r.ExternalIP, r.ExternalPort), r.SelfShard)
 			t.addNode(n, true)
 			t.timeoutNodesCount.Set(n.ID.Hex(), 0)
+			t.checkRecordSeq(n.ID, r.RecordSeq, addr)
 
 			t.log.Debug("received [pongMsg] from: %s", n)
 
@@ -217,15 +259,17 @@ func (m *findNode) send(t *udp) {
 			r := resp.(*neighbors)
 
 			t.log.Debug("received [neighborsMsg] from: %s with %d nodes", r.SelfID.Hex(), len(r.Nodes))
-			if r.Nodes == nil || len(r.Nodes) == 0 {
-				return true
-			}
-
+			nodes := make([]*Node, 0, len(r.Nodes))
 			for _, n := range r.Nodes {
 				t.log.Debug("received node: %s", n.SelfID.Hex())
 
 				node := n.ToNode()
 				t.addNode(node, false)
+				nodes = append(nodes, node)
+			}
+
+			if m.found != nil {
+				m.found(nodes)
 			}
 
 			return true
@@ -333,3 +377,18 @@ func (m *findShardNode) handle(t *udp, from *net.UDPAddr) {
 
 	t.sendMsg(shardNodeMsgType, response, m.SelfID, from)
 }
+
+// handle responses the local node record
+func (m *findRecord) handle(t *udp, from *net.UDPAddr) {
+	if err := m.SelfID.Validate(); err != nil {
+		return
+	}
+
+	record := t.LocalRecord()
+	if record == nil {
+		return
+	}
+
+	t.log.Debug("received [findRecordMsg] and send [recordMsg] to: %s", from)
+	t.sendMsg(recordMsgType, record, m.SelfID, from)
+}
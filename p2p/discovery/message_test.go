@@ -36,12 +36,18 @@ func Test_Message_CodeToStr(t *testing.T) {
 	code = shardNodeMsgType
 	assert.Equal(t, codeToStr(code), "shardNodeMsgType")
 
+	code = findRecordMsgType
+	assert.Equal(t, codeToStr(code), "findRecordMsgType")
+
+	code = recordMsgType
+	assert.Equal(t, codeToStr(code), "recordMsgType")
+
 	// invalid codes
 	code = pingMsgType - 1
-	assert.Equal(t, codeToStr(code), "unkwown")
+	assert.Equal(t, codeToStr(code), "unknown")
 
-	code = shardNodeMsgType + 1
-	assert.Equal(t, codeToStr(code), "unkwown")
+	code = recordMsgType + 1
+	assert.Equal(t, codeToStr(code), "unknown")
 }
 
 func Test_Message_ToNode(t *testing.T) {
@@ -240,3 +246,40 @@ func testTable() *Table {
 
 	return table
 }
+
+func Test_Message_PingExternalEndpoint(t *testing.T) {
+	// ping without external endpoint from the old version is still accepted
+	old := struct {
+		Version   uint
+		SelfID    common.Address
+		SelfShard uint
+	}{discoveryProtocolVersion, *crypto.MustGenerateShardAddress(1), 1}
+
+	var msg ping
+	assert.Equal(t, common.Deserialize(common.SerializePanic(old), &msg), nil)
+	assert.Equal(t, msg.SelfID, old.SelfID)
+	assert.Equal(t, msg.ExternalPort, uint16(0))
+
+	p := &ping{
+		Version:      discoveryProtocolVersion,
+		SelfID:       old.SelfID,
+		SelfShard:    1,
+		ExternalIP:   net.IPv4(1, 2, 3, 4).To4(),
+		ExternalPort: 9057,
+	}
+
+	msg = ping{}
+	assert.Equal(t, common.Deserialize(common.SerializePanic(p), &msg), nil)
+	assert.Equal(t, msg.ExternalIP, p.ExternalIP)
+	assert.Equal(t, msg.ExternalPort, uint16(9057))
+}
+
+func Test_Message_AdvertisedAddr(t *testing.T) {
+	from := &net.UDPAddr{IP: net.IPv4(1, 2, 3, 4), Port: 8057}
+
+	assert.Equal(t, advertisedAddr(from, nil, 0), from)
+	assert.Equal(t, advertisedAddr(from, net.IPv4(1, 2, 3, 4), 9057), &net.UDPAddr{IP: from.IP, Port: 9057})
+
+	// the endpoint of another host is ignored
+	assert.Equal(t, advertisedAddr(from, net.IPv4(5, 6, 7, 8), 9057), from)
+}
@@ -32,6 +32,9 @@ type Node struct {
 
 	Shard uint //node shard number
 
+	// Record is the signed record of the node, nil if not received yet
+	Record *Record
+
 	// node id for Kademlia, which is generated from public key
 	// better to get it with getSha()
 	sha common.Hash
@@ -0,0 +1,162 @@
+/**
+*  @file
+*  @copyright defined in scdo/LICENSE
+ */
+
+package discovery
+
+import (
+	"crypto/ecdsa"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/elcn233/go-scdo/common"
+	"github.com/elcn233/go-scdo/crypto"
+)
+
+// recordTextPrefix is the prefix of the text form of the record
+const recordTextPrefix = "snr:"
+
+var (
+	errRecordSignature = errors.New("invalid node record signature")
+	errRecordShard     = errors.New("invalid node record shard")
+	errRecordText      = errors.New("record text should start with " + recordTextPrefix)
+)
+
+// Record is the signed node record. A node signs a new record with a higher
+// sequence number whenever its endpoint or capabilities change, so that other
+// nodes replace the stale one.
+type Record struct {
+	Seq       uint64
+	ID        common.Address
+	IP        net.IP // empty if unknown, the ip the messages come from is used instead
+	UDPPort   uint16
+	TCPPort   uint16
+	Shard     uint
+	NetworkID string
+	Caps      []string // supported protocols in name/version format, e.g. scdo/1
+
+	Signature crypto.Signature
+}
+
+// recordContent is the signed content of the record
+type recordContent struct {
+	Seq       uint64
+	ID        common.Address
+	IP        net.IP
+	UDPPort   uint16
+	TCPPort   uint16
+	Shard     uint
+	NetworkID string
+	Caps      []string
+}
+
+func (r *Record) hash() common.Hash {
+	return crypto.MustHash(&recordContent{
+		Seq:       r.Seq,
+		ID:        r.ID,
+		IP:        r.IP,
+		UDPPort:   r.UDPPort,
+		TCPPort:   r.TCPPort,
+		Shard:     r.Shard,
+		NetworkID: r.NetworkID,
+		Caps:      r.Caps,
+	})
+}
+
+// Sign signs the record with the private key of the node
+func (r *Record) Sign(key *ecdsa.PrivateKey) error {
+	sig, err := crypto.Sign(key, r.hash().Bytes())
+	if err != nil {
+		return err
+	}
+
+	r.Signature = *sig
+	return nil
+}
+
+// Verify checks that the record is signed by the node
+func (r *Record) Verify() error {
+	if !isShardValid(r.Shard) || r.Shard == UndefinedShardNumber || r.ID.Shard() != r.Shard {
+		return errRecordShard
+	}
+
+	if !r.Signature.Verify(r.ID, r.hash().Bytes()) {
+		return errRecordSignature
+	}
+
+	return nil
+}
+
+// HasCap returns whether the node supports the protocol
+func (r *Record) HasCap(cap string) bool {
+	for _, c := range r.Caps {
+		if c == cap {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Endpoint returns the udp endpoint of the record, the endpoint the
+// record comes from is used if the record has no ip. Same as the endpoint
+// advertised in ping, the ip of the record is only accepted if it is the one
+// the record comes from, so that a node can't direct the traffic to other
+// hosts without bonding. The endpoint of the record from DNS (from is nil)
+// is accepted as is, since the node is pinged before being connected.
+func (r *Record) Endpoint(from *net.UDPAddr) *net.UDPAddr {
+	if len(r.IP) == 0 || r.IP.IsUnspecified() || r.UDPPort == 0 {
+		return from
+	}
+
+	if from == nil {
+		return &net.UDPAddr{IP: r.IP, Port: int(r.UDPPort)}
+	}
+
+	return advertisedAddr(from, r.IP, r.UDPPort)
+}
+
+func (r *Record) String() string {
+	return fmt.Sprintf("record(%s, seq %d, shard %d, network %s, caps %v)", r.ID.Hex(), r.Seq, r.Shard, r.NetworkID, r.Caps)
+}
+
+// MarshalText encodes the record as snr:<base64 of the record rlp>
+func (r Record) MarshalText() ([]byte, error) {
+	encoded, err := common.Serialize(&r)
+	if err != nil {
+		return nil, err
+	}
+
+	return []byte(recordTextPrefix + base64.RawURLEncoding.EncodeToString(encoded)), nil
+}
+
+// UnmarshalText decodes the record from the text form, the signature is not verified
+func (r *Record) UnmarshalText(text []byte) error {
+	str := string(text)
+	if !strings.HasPrefix(str, recordTextPrefix) {
+		return errRecordText
+	}
+
+	encoded, err := base64.RawURLEncoding.DecodeString(str[len(recordTextPrefix):])
+	if err != nil {
+		return err
+	}
+
+	return common.Deserialize(encoded, r)
+}
+
+// newRecordSeq returns the sequence number of a new record, the time is
+// used so that the sequence number keeps increasing after restart.
+func newRecordSeq(prev uint64) uint64 {
+	seq := uint64(time.Now().UnixNano() / int64(time.Millisecond))
+	if seq <= prev {
+		seq = prev + 1
+	}
+
+	return seq
+}
@@ -0,0 +1,196 @@
+/**
+*  @file
+*  @copyright defined in scdo/LICENSE
+ */
+
+package discovery
+
+import (
+	"net"
+	"strings"
+	"testing"
+
+	"github.com/elcn233/go-scdo/common"
+	"github.com/elcn233/go-scdo/crypto"
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestRecord(t *testing.T, seq uint64, ip string, port uint16) (*Record, func(*Record)) {
+	id, key := crypto.MustGenerateShardKeyPair(1)
+	record := &Record{
+		Seq:       seq,
+		ID:        *id,
+		IP:        net.ParseIP(ip),
+		UDPPort:   port,
+		TCPPort:   port,
+		Shard:     1,
+		NetworkID: "scdo",
+		Caps:      []string{"scdo/1"},
+	}
+	assert.Equal(t, record.Sign(key), nil)
+
+	resign := func(r *Record) {
+		assert.Equal(t, r.Sign(key), nil)
+	}
+
+	return record, resign
+}
+
+func Test_Record_SignAndVerify(t *testing.T) {
+	record, resign := newTestRecord(t, 1, "127.0.0.1", 9000)
+	assert.Equal(t, record.Verify(), nil)
+	assert.Equal(t, record.HasCap("scdo/1"), true)
+	assert.Equal(t, record.HasCap("lightScdo_1/1"), false)
+
+	// encoding round trip
+	var decoded Record
+	assert.Equal(t, common.Deserialize(common.SerializePanic(record), &decoded), nil)
+	assert.Equal(t, decoded.Verify(), nil)
+
+	// tampered record
+	record.UDPPort = 9001
+	assert.Equal(t, record.Verify(), errRecordSignature)
+
+	resign(record)
+	assert.Equal(t, record.Verify(), nil)
+
+	// signed by another node
+	record.ID = *crypto.MustGenerateShardAddress(1)
+	assert.Equal(t, record.Verify(), errRecordSignature)
+
+	record.Shard = 2
+	assert.Equal(t, record.Verify(), errRecordShard)
+}
+
+func Test_Record_Endpoint(t *testing.T) {
+	from := &net.UDPAddr{IP: net.IPv4(1, 2, 3, 4), Port: 8057}
+
+	record, _ := newTestRecord(t, 1, "1.2.3.4", 9000)
+	assert.Equal(t, record.Endpoint(from), &net.UDPAddr{IP: from.IP, Port: 9000})
+
+	// the ip other than the one the record comes from is ignored
+	record.IP = net.ParseIP("5.6.7.8")
+	assert.Equal(t, record.Endpoint(from), from)
+
+	// the record from DNS
+	assert.Equal(t, record.Endpoint(nil), &net.UDPAddr{IP: record.IP, Port: 9000})
+
+	record.IP = nil
+	assert.Equal(t, record.Endpoint(from), from)
+}
+
+func Test_UDP_UpdateRecord(t *testing.T) {
+	u := newTestUDP()
+	id, key := crypto.MustGenerateShardKeyPair(1)
+	u.self.ID = *id
+	assert.Equal(t, u.SetLocalRecord(key, "scdo", []string{"scdo/1"}), nil)
+
+	from := &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1), Port: 9000}
+	record, resign := newTestRecord(t, 10, "127.0.0.1", 9000)
+	u.updateRecord(record, from)
+
+	node, ok := u.db.FindByNodeID(record.ID)
+	assert.Equal(t, ok, true)
+	assert.Equal(t, node.Record, record)
+	assert.Equal(t, node.UDPPort, 9000)
+
+	// a ping from the node keeps the record
+	u.addNode(NewNodeWithAddr(record.ID, from, 1), false)
+	node, _ = u.db.FindByNodeID(record.ID)
+	assert.Equal(t, node.Record, record)
+
+	// newer record replaces the stale endpoint
+	newer := *record
+	newer.Seq, newer.UDPPort = 11, 9001
+	resign(&newer)
+	u.updateRecord(&newer, from)
+
+	node, _ = u.db.FindByNodeID(record.ID)
+	assert.Equal(t, node.Record.Seq, uint64(11))
+	assert.Equal(t, node.UDPPort, 9001)
+	assert.Equal(t, u.table.count(), 1)
+
+	// the record can't redirect the node to another host
+	redirect := newer
+	redirect.Seq, redirect.IP = 12, net.ParseIP("127.0.0.2")
+	resign(&redirect)
+	u.updateRecord(&redirect, from)
+
+	node, _ = u.db.FindByNodeID(record.ID)
+	assert.Equal(t, node.Record.Seq, uint64(12))
+	assert.Equal(t, node.IP.Equal(from.IP), true)
+	assert.Equal(t, node.UDPPort, 9000)
+
+	// older record is ignored
+	u.updateRecord(record, from)
+	node, _ = u.db.FindByNodeID(record.ID)
+	assert.Equal(t, node.Record.Seq, uint64(12))
+
+	// node of another network is removed
+	other := redirect
+	other.Seq, other.NetworkID = 13, "other"
+	resign(&other)
+	u.updateRecord(&other, from)
+	_, ok = u.db.FindByNodeID(record.ID)
+	assert.Equal(t, ok, false)
+	assert.Equal(t, u.table.count(), 0)
+}
+
+func Test_UDP_LocalRecord(t *testing.T) {
+	u := newTestUDP()
+	id, key := crypto.MustGenerateShardKeyPair(1)
+	u.self.ID = *id
+	assert.Equal(t, u.LocalRecord() == nil, true)
+	assert.Equal(t, u.localRecordSeq(), uint64(0))
+
+	assert.Equal(t, u.SetLocalRecord(key, "scdo", []string{"scdo/1"}), nil)
+	record := u.LocalRecord()
+	assert.Equal(t, record.Verify(), nil)
+	assert.Equal(t, record.UDPPort, uint16(9666))
+
+	// external endpoint is signed into a newer record
+	u.SetExternalEndpoint(net.IPv4(1, 2, 3, 4), 9057)
+	newer := u.LocalRecord()
+	assert.Equal(t, newer.Verify(), nil)
+	assert.Equal(t, newer.Seq > record.Seq, true)
+	assert.Equal(t, newer.IP.Equal(net.IPv4(1, 2, 3, 4)), true)
+	assert.Equal(t, newer.UDPPort, uint16(9057))
+	assert.Equal(t, newer.Caps, []string{"scdo/1"})
+}
+
+func Test_Record_Text(t *testing.T) {
+	record, _ := newTestRecord(t, 1, "127.0.0.1", 9000)
+	text, err := record.MarshalText()
+	assert.Equal(t, err, nil)
+	assert.Equal(t, strings.HasPrefix(string(text), recordTextPrefix), true)
+
+	var decoded Record
+	assert.Equal(t, decoded.UnmarshalText(text), nil)
+	assert.Equal(t, decoded.Verify(), nil)
+	assert.Equal(t, decoded.ID, record.ID)
+
+	assert.Equal(t, decoded.UnmarshalText(text[len(recordTextPrefix):]), errRecordText)
+}
+
+func Test_UDP_SeedRecords(t *testing.T) {
+	u := newTestUDP()
+	id, key := crypto.MustGenerateShardKeyPair(1)
+	u.self.ID = *id
+	assert.Equal(t, u.SetLocalRecord(key, "scdo", []string{"scdo/1"}), nil)
+
+	seeded, _ := newTestRecord(t, 1, "127.0.0.1", 9001)
+	noIP, _ := newTestRecord(t, 1, "", 9002)
+	other, resign := newTestRecord(t, 1, "127.0.0.1", 9003)
+	other.NetworkID = "other"
+	resign(other)
+	tampered, _ := newTestRecord(t, 1, "127.0.0.1", 9004)
+	tampered.UDPPort = 9005
+
+	u.SeedRecords([]*Record{seeded, noIP, other, tampered})
+	assert.Equal(t, u.table.count(), 1)
+
+	node, ok := u.db.FindByNodeID(seeded.ID)
+	assert.Equal(t, ok, true)
+	assert.Equal(t, node.Record, seeded)
+	assert.Equal(t, node.UDPPort, 9001)
+}
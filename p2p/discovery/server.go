@@ -21,5 +21,5 @@ func StartService(nodeDir string, myID common.Address, myAddr *net.UDPAddr, boot
 	udp.loadBlockList(nodeDir)
 	udp.StartServe(nodeDir)
 
-	return udp.db, &UDP{udp: *udp}
+	return udp.db, &UDP{udp: udp}
 }
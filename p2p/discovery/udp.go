@@ -7,13 +7,16 @@ package discovery
 
 import (
 	"container/list"
+	"crypto/ecdsa"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io/ioutil"
 	rand2 "math/rand"
 	"net"
 	"os"
 	"path/filepath"
+	"sync"
 	"time"
 
 	"github.com/elcn233/go-scdo/common"
@@ -40,10 +43,10 @@ const (
 	blockDuration             = 60 * time.Minute
 )
 
-var toTrustNodes []*Node
+var errTrustNodeNotFound = errors.New("trust node not found")
 
 type UDP struct {
-	udp
+	*udp
 }
 type udp struct {
 	conn           *net.UDPConn
@@ -62,6 +65,14 @@ type udp struct {
 
 	timeoutNodesCount cmap.ConcurrentMap //node id -> count
 	blockList         cmap.ConcurrentMap //blockList for ip, key is IP  and value is last (ping) message unix-timestamp
+
+	toTrustNodes []*Node      // trust nodes added by user
+	trustLock    sync.RWMutex // lock for toTrustNodes
+
+	external    *net.UDPAddr      // external endpoint behind NAT, advertised in ping and pong
+	privateKey  *ecdsa.PrivateKey // key to sign the local record
+	localRecord *Record           // signed record of the local node, nil if not set
+	recordLock  sync.RWMutex      // lock for external, privateKey and localRecord
 }
 
 type pending struct {
@@ -114,7 +125,6 @@ func newUDP(id common.Address, addr *net.UDPAddr, shard uint) *udp {
 		log:               discoverylog,
 		timeoutNodesCount: cmap.New(),
 		blockList:         cmap.New(),
-		// toTrustNodes:      make([]*Node, 0),
 	}
 
 	return transport
@@ -127,17 +137,65 @@ func (u *udp) AddTrustNode(strNode string) error {
 		u.log.Warn("Create Node from IP Error: %+v", err)
 		return err
 	}
-	toTrustNodes = append(toTrustNodes, node)
-	// toTrustNodes.Set(strNode, node)
-	u.log.Debug("trustNode len %d", len(toTrustNodes))
-	for i, n := range toTrustNodes {
+
+	u.trustLock.Lock()
+	defer u.trustLock.Unlock()
+
+	addr := node.GetUDPAddr().String()
+	for _, n := range u.toTrustNodes {
+		if n.GetUDPAddr().String() == addr {
+			return nil
+		}
+	}
+
+	u.toTrustNodes = append(u.toTrustNodes, node)
+	u.log.Debug("trustNode len %d", len(u.toTrustNodes))
+	for i, n := range u.toTrustNodes {
 		u.log.Debug("%dth node:%+v", i, n)
 	}
+
 	return nil
 }
 
+// RemoveTrustNode removes the node added by AddTrustNode
+func (u *udp) RemoveTrustNode(strNode string) error {
+	node, err := NewNodeFromIP(strNode)
+	if err != nil {
+		return err
+	}
+
+	u.trustLock.Lock()
+	defer u.trustLock.Unlock()
+
+	addr := node.GetUDPAddr().String()
+	for i, n := range u.toTrustNodes {
+		if n.GetUDPAddr().String() == addr {
+			u.toTrustNodes = append(u.toTrustNodes[:i], u.toTrustNodes[i+1:]...)
+			return nil
+		}
+	}
+
+	return errTrustNodeNotFound
+}
+
+// GetTrustNodes returns the addresses of the trust nodes added by user
+func (u *udp) GetTrustNodes() []string {
+	u.trustLock.RLock()
+	defer u.trustLock.RUnlock()
+
+	addrs := make([]string, 0, len(u.toTrustNodes))
+	for _, n := range u.toTrustNodes {
+		addrs = append(addrs, n.GetUDPAddr().String())
+	}
+
+	return addrs
+}
+
 func (u *udp) GetToTrustNodeCount() int {
-	return len(toTrustNodes)
+	u.trustLock.RLock()
+	defer u.trustLock.RUnlock()
+
+	return len(u.toTrustNodes)
 }
 
 func (u *udp) GetBlockListCount() int {
@@ -292,6 +350,28 @@ func (u *udp) handleMsg(from *net.UDPAddr, data []byte) {
 			}
 			msg.handle(u, from)
 
+		case findRecordMsgType:
+			msg := &findRecord{}
+			err := common.Deserialize(data[1:], &msg)
+			if err != nil {
+				u.log.Warn(err.Error())
+				return
+			}
+			if msg.Version != discoveryProtocolVersion {
+				u.log.Warn("findRecordMsg invalid discoveryProtocolVersion %d,addr:%s,nodeID:%s", msg.Version, from, msg.SelfID)
+				return
+			}
+			msg.handle(u, from)
+
+		case recordMsgType:
+			msg := &Record{}
+			err := common.Deserialize(data[1:], &msg)
+			if err != nil {
+				u.log.Warn(err.Error())
+				return
+			}
+			u.updateRecord(msg, from)
+
 		case shardNodeMsgType:
 			msg := &shardNode{}
 			err := common.Deserialize(data[1:], &msg)
@@ -477,15 +557,17 @@ func (u *udp) pingPongService() {
 			}
 
 		}
-		u.log.Debug("toTrustNodes len %d", len(toTrustNodes))
+		u.trustLock.RLock()
+		u.log.Debug("toTrustNodes len %d", len(u.toTrustNodes))
 
-		if len(toTrustNodes) > 0 {
-			for i := range toTrustNodes {
-				loopPingPongNodes[toTrustNodes[i].GetUDPAddr().String()] = toTrustNodes[i]
-				u.log.Debug("udp toTrustNodes from bootstrap %+v", toTrustNodes[i])
+		if len(u.toTrustNodes) > 0 {
+			for i := range u.toTrustNodes {
+				loopPingPongNodes[u.toTrustNodes[i].GetUDPAddr().String()] = u.toTrustNodes[i]
+				u.log.Debug("udp toTrustNodes from bootstrap %+v", u.toTrustNodes[i])
 
 			}
 		}
+		u.trustLock.RUnlock()
 
 		// loopPingPongNodes add db nodes, loop ping pong; if bootstrapNodes or trustNodes have the same key, will use the db node to update it
 		if len(copyMap) > 0 {
@@ -522,10 +604,198 @@ func (u *udp) ping(value *Node) {
 
 		to: value,
 	}
+	p.ExternalIP, p.ExternalPort = u.externalEndpoint()
+	p.RecordSeq = u.localRecordSeq()
 
 	p.send(u)
 }
 
+// SetExternalEndpoint sets the endpoint the node is reachable at from outside the NAT,
+// it is advertised to other nodes in ping and pong messages and the node record.
+func (u *udp) SetExternalEndpoint(ip net.IP, port int) {
+	u.recordLock.Lock()
+	defer u.recordLock.Unlock()
+
+	u.external = &net.UDPAddr{IP: ip, Port: port}
+	u.log.Info("external endpoint is set to %s", u.external)
+
+	if u.localRecord != nil {
+		u.signLocalRecord(u.localRecord.NetworkID, u.localRecord.Caps)
+	}
+}
+
+// ExternalEndpoint returns the external endpoint of the node, nil if not set
+func (u *udp) ExternalEndpoint() *net.UDPAddr {
+	u.recordLock.RLock()
+	defer u.recordLock.RUnlock()
+
+	return u.external
+}
+
+func (u *udp) externalEndpoint() (net.IP, uint16) {
+	external := u.ExternalEndpoint()
+	if external == nil {
+		return nil, 0
+	}
+
+	return external.IP, uint16(external.Port)
+}
+
+// SetLocalRecord signs the record of the local node with the network id and the
+// supported protocols, the record is sent to the nodes that request it.
+func (u *udp) SetLocalRecord(key *ecdsa.PrivateKey, networkID string, caps []string) error {
+	u.recordLock.Lock()
+	defer u.recordLock.Unlock()
+
+	u.privateKey = key
+	return u.signLocalRecord(networkID, caps)
+}
+
+// signLocalRecord signs a new local record with a higher sequence number,
+// must be called with the recordLock held
+func (u *udp) signLocalRecord(networkID string, caps []string) error {
+	var seq uint64
+	if u.localRecord != nil {
+		seq = u.localRecord.Seq
+	}
+
+	record := &Record{
+		Seq:       newRecordSeq(seq),
+		ID:        u.self.ID,
+		UDPPort:   uint16(u.self.UDPPort),
+		TCPPort:   uint16(u.self.UDPPort),
+		Shard:     u.self.Shard,
+		NetworkID: networkID,
+		Caps:      caps,
+	}
+
+	if u.external != nil {
+		record.IP = u.external.IP
+		record.UDPPort = uint16(u.external.Port)
+		record.TCPPort = uint16(u.external.Port)
+	} else if !u.self.IP.IsUnspecified() {
+		record.IP = u.self.IP
+	}
+
+	if err := record.Sign(u.privateKey); err != nil {
+		return err
+	}
+
+	u.localRecord = record
+	u.log.Debug("local node record is updated, %s", record)
+	return nil
+}
+
+// SeedRecords adds the nodes of the records to the database, e.g. the records from DNS.
+// The nodes are pinged before being notified to connect.
+func (u *udp) SeedRecords(records []*Record) {
+	local := u.LocalRecord()
+	count := 0
+	for _, record := range records {
+		if err := record.Verify(); err != nil || len(record.IP) == 0 || record.UDPPort == 0 {
+			continue
+		}
+
+		if local != nil && local.NetworkID != record.NetworkID {
+			continue
+		}
+
+		if n, ok := u.db.FindByNodeID(record.ID); ok && n.Record != nil && n.Record.Seq >= record.Seq {
+			continue
+		}
+
+		node := NewNodeWithAddr(record.ID, record.Endpoint(nil), record.Shard)
+		node.TCPPort = int(record.TCPPort)
+		node.Record = record
+		u.addNode(node, false)
+		count++
+	}
+
+	u.log.Info("seed %d nodes from %d records", count, len(records))
+}
+
+// LocalRecord returns the record of the local node, nil if not set
+func (u *udp) LocalRecord() *Record {
+	u.recordLock.RLock()
+	defer u.recordLock.RUnlock()
+
+	return u.localRecord
+}
+
+func (u *udp) localRecordSeq() uint64 {
+	if record := u.LocalRecord(); record != nil {
+		return record.Seq
+	}
+
+	return 0
+}
+
+// checkRecordSeq requests the record of the node if the known record is older than seq
+func (u *udp) checkRecordSeq(id common.Address, seq uint64, from *net.UDPAddr) {
+	if seq == 0 {
+		return
+	}
+
+	if n, ok := u.db.FindByNodeID(id); ok && n.Record != nil && n.Record.Seq >= seq {
+		return
+	}
+
+	req := &findRecord{
+		Version: discoveryProtocolVersion,
+		SelfID:  u.self.ID,
+	}
+
+	u.log.Debug("send [findRecordMsg] to: %s", from)
+	u.sendMsg(findRecordMsgType, req, id, from)
+}
+
+// updateRecord replaces the node with the newer record. The node is removed if it
+// is in another network, so that it is never dialed.
+func (u *udp) updateRecord(record *Record, from *net.UDPAddr) {
+	if err := record.Verify(); err != nil {
+		u.log.Debug("got invalid record from %s, %s", from, err)
+		return
+	}
+
+	if record.ID.Equal(u.self.ID) {
+		return
+	}
+
+	old, exist := u.db.FindByNodeID(record.ID)
+	if exist && old.Record != nil && old.Record.Seq >= record.Seq {
+		return
+	}
+
+	if local := u.LocalRecord(); local != nil && local.NetworkID != record.NetworkID {
+		u.log.Debug("remove node %s of network %s", record.ID.Hex(), record.NetworkID)
+		if exist {
+			u.table.deleteNode(old)
+			u.db.delete(old.getSha())
+		}
+		return
+	}
+
+	node := NewNodeWithAddr(record.ID, record.Endpoint(from), record.Shard)
+	node.TCPPort = int(record.TCPPort)
+	node.Record = record
+
+	if !exist {
+		u.addNode(node, true)
+		return
+	}
+
+	// replace the stale node, notify the new endpoint if it changes
+	changed := !old.IP.Equal(node.IP) || old.UDPPort != node.UDPPort
+	u.table.deleteNode(old)
+	if u.table.addNode(node) {
+		u.db.add(node, changed)
+	}
+
+	if changed {
+		u.log.Info("node %s changes endpoint from %s to %s", node.ID.Hex(), old.GetUDPAddr(), node.GetUDPAddr())
+	}
+}
+
 func (u *udp) StartServe(nodeDir string) {
 	go u.checkBlockList()
 	go u.readLoop()
@@ -610,6 +880,11 @@ func (u *udp) addNode(n *Node, notifyConnect bool) {
 		return
 	}
 
+	// keep the node of the signed record, its endpoint is replaced by newer records only
+	if old, ok := u.db.FindByNodeID(n.ID); ok && old.Record != nil && n.Record == nil {
+		n = old
+	}
+
 	count := u.db.size()
 
 	status := u.table.addNode(n)
@@ -625,6 +900,20 @@ func (u *udp) addNode(n *Node, notifyConnect bool) {
 	}
 }
 
+// RemoveNode removes the node from the table and database immediately,
+// so that it is neither dialed nor returned to other nodes.
+func (u *udp) RemoveNode(id common.Address) {
+	n, ok := u.db.FindByNodeID(id)
+	if !ok {
+		return
+	}
+
+	u.table.deleteNode(n)
+	u.db.delete(n.getSha())
+	u.timeoutNodesCount.Remove(id.Hex())
+	u.log.Info("remove node %s, total nodes:%d", n, u.db.size())
+}
+
 func (u *udp) deleteNode(n *Node) {
 	selfSha := u.self.getSha()
 	sha := n.getSha()
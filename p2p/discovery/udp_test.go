@@ -148,3 +148,33 @@ func Test_UDP_LoadNodes(t *testing.T) {
 	u.loadNodes("nonexistentfolder")
 	assert.Equal(t, len(u.bootstrapNodes), 0)
 }
+
+func Test_UDP_TrustNodes(t *testing.T) {
+	u := newTestUDP()
+
+	assert.Equal(t, u.AddTrustNode("127.0.0.1:9001"), nil)
+	assert.Equal(t, u.AddTrustNode("127.0.0.1:9002"), nil)
+	assert.Equal(t, u.AddTrustNode("127.0.0.1:9001"), nil)
+	assert.Equal(t, u.GetToTrustNodeCount(), 2)
+	assert.Equal(t, u.GetTrustNodes(), []string{"127.0.0.1:9001", "127.0.0.1:9002"})
+
+	assert.Equal(t, u.RemoveTrustNode("127.0.0.1:9001"), nil)
+	assert.Equal(t, u.RemoveTrustNode("127.0.0.1:9001"), errTrustNodeNotFound)
+	assert.Equal(t, u.GetTrustNodes(), []string{"127.0.0.1:9002"})
+}
+
+func Test_UDP_RemoveNode(t *testing.T) {
+	u := newTestUDP()
+	node := u.trustNodes[0]
+	u.addNode(node, false)
+	_, found := u.db.FindByNodeID(node.ID)
+	assert.Equal(t, found, true)
+
+	u.RemoveNode(node.ID)
+	_, found = u.db.FindByNodeID(node.ID)
+	assert.Equal(t, found, false)
+	assert.Equal(t, u.table.count(), 0)
+
+	// not found
+	u.RemoveNode(node.ID)
+}
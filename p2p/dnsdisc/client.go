@@ -0,0 +1,165 @@
+/**
+*  @file
+*  @copyright defined in scdo/LICENSE
+ */
+
+package dnsdisc
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/elcn233/go-scdo/common"
+	"github.com/elcn233/go-scdo/log"
+	"github.com/elcn233/go-scdo/p2p/discovery"
+)
+
+const (
+	// defaultTimeout is the max time to sync a tree
+	defaultTimeout = 30 * time.Second
+
+	// maxEntries limits the number of entries fetched from a tree
+	maxEntries = 10000
+)
+
+// Resolver looks up the TXT records of the domain, it is satisfied by net.Resolver
+type Resolver interface {
+	LookupTXT(ctx context.Context, domain string) ([]string, error)
+}
+
+// Client fetches the node records from the trees published in DNS
+type Client struct {
+	resolver Resolver
+	timeout  time.Duration
+	log      *log.ScdoLog
+}
+
+// NewClient creates the client, the system resolver is used if resolver is nil
+func NewClient(resolver Resolver) *Client {
+	if resolver == nil {
+		resolver = net.DefaultResolver
+	}
+
+	return &Client{
+		resolver: resolver,
+		timeout:  defaultTimeout,
+		log:      log.GetLogger("dnsdisc"),
+	}
+}
+
+// SyncTree fetches the whole tree at the url and verifies its entries
+func (c *Client) SyncTree(url string) (*Tree, error) {
+	signer, domain, err := ParseURL(url)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), c.timeout)
+	defer cancel()
+
+	root, err := c.resolveRoot(ctx, signer, domain)
+	if err != nil {
+		return nil, err
+	}
+
+	t := &Tree{root: root, entries: make(map[string]entry)}
+	queue := []string{root.eroot}
+	for len(queue) > 0 {
+		sd := queue[0]
+		queue = queue[1:]
+		if _, ok := t.entries[sd]; ok {
+			continue
+		}
+
+		if len(t.entries) >= maxEntries {
+			return nil, fmt.Errorf("tree %s has more than %d entries", domain, maxEntries)
+		}
+
+		e, err := c.resolveEntry(ctx, sd, domain)
+		if err != nil {
+			return nil, err
+		}
+
+		t.entries[sd] = e
+		if branch, ok := e.(*branchEntry); ok {
+			queue = append(queue, branch.children...)
+		}
+	}
+
+	return t, nil
+}
+
+// Records fetches the node records of the trees, the trees failed to sync are skipped
+func (c *Client) Records(urls []string) []*discovery.Record {
+	var records []*discovery.Record
+	for _, url := range urls {
+		t, err := c.SyncTree(url)
+		if err != nil {
+			c.log.Warn("failed to sync dns tree %s, %s", url, err)
+			continue
+		}
+
+		treeRecords := t.Records()
+		c.log.Info("synced dns tree %s with %d records, seq %d", url, len(treeRecords), t.Seq())
+		records = append(records, treeRecords...)
+	}
+
+	return records
+}
+
+func (c *Client) resolveRoot(ctx context.Context, signer common.Address, domain string) (*rootEntry, error) {
+	txts, err := c.resolver.LookupTXT(ctx, domain)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, txt := range txts {
+		if !strings.HasPrefix(txt, rootPrefix) {
+			continue
+		}
+
+		root, err := parseRoot(txt)
+		if err != nil {
+			return nil, err
+		}
+
+		if !root.verify(signer) {
+			return nil, errInvalidSig
+		}
+
+		return root, nil
+	}
+
+	return nil, fmt.Errorf("no root found at %s", domain)
+}
+
+func (c *Client) resolveEntry(ctx context.Context, sd, domain string) (entry, error) {
+	name := sd + "." + domain
+	txts, err := c.resolver.LookupTXT(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, txt := range txts {
+		e, err := parseEntry(txt)
+		if err == errUnknownEntry {
+			continue
+		}
+
+		if err != nil {
+			return nil, fmt.Errorf("invalid entry at %s, %s", name, err)
+		}
+
+		// the entry must match its hash, otherwise the tree is tampered
+		if hashText(txt) != sd {
+			return nil, fmt.Errorf("hash mismatch at %s", name)
+		}
+
+		return e, nil
+	}
+
+	return nil, fmt.Errorf("no entry found at %s", name)
+}
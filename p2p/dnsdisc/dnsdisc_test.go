@@ -0,0 +1,129 @@
+/**
+*  @file
+*  @copyright defined in scdo/LICENSE
+ */
+
+package dnsdisc
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strings"
+	"testing"
+
+	"github.com/elcn233/go-scdo/crypto"
+	"github.com/elcn233/go-scdo/p2p/discovery"
+	"github.com/stretchr/testify/assert"
+)
+
+// mapResolver resolves the TXT records from the map
+type mapResolver map[string]string
+
+func (m mapResolver) LookupTXT(ctx context.Context, domain string) ([]string, error) {
+	if txt, ok := m[domain]; ok {
+		return []string{txt}, nil
+	}
+
+	return nil, fmt.Errorf("%s not found", domain)
+}
+
+func newTestRecords(t *testing.T, count int) []*discovery.Record {
+	records := make([]*discovery.Record, count)
+	for i := range records {
+		id, key := crypto.MustGenerateShardKeyPair(1)
+		records[i] = &discovery.Record{
+			Seq:       1,
+			ID:        *id,
+			IP:        net.IPv4(10, 0, 0, byte(i)),
+			UDPPort:   8057,
+			TCPPort:   8057,
+			Shard:     1,
+			NetworkID: "scdo",
+			Caps:      []string{"scdo/1"},
+		}
+		assert.Equal(t, records[i].Sign(key), nil)
+	}
+
+	return records
+}
+
+func newTestTree(t *testing.T, count int) (*Tree, string, mapResolver) {
+	tree, err := MakeTree(3, newTestRecords(t, count))
+	assert.Equal(t, err, nil)
+
+	_, key := crypto.MustGenerateShardKeyPair(1)
+	url, err := tree.Sign(key, "nodes.example.org")
+	assert.Equal(t, err, nil)
+
+	return tree, url, mapResolver(tree.ToTXT("nodes.example.org"))
+}
+
+func Test_DNSDisc_SyncTree(t *testing.T) {
+	for _, count := range []int{1, maxChildren, 50} {
+		tree, url, resolver := newTestTree(t, count)
+
+		synced, err := NewClient(resolver).SyncTree(url)
+		assert.Equal(t, err, nil)
+		assert.Equal(t, synced.Seq(), uint(3))
+		assert.Equal(t, len(synced.Records()), count)
+		assert.Equal(t, synced.ToTXT("nodes.example.org"), tree.ToTXT("nodes.example.org"))
+	}
+}
+
+func Test_DNSDisc_RejectTampered(t *testing.T) {
+	tree, url, resolver := newTestTree(t, 20)
+
+	// root signed by another key
+	other := treePrefix + crypto.MustGenerateShardAddress(1).Hex() + "@nodes.example.org"
+	_, err := NewClient(resolver).SyncTree(other)
+	assert.Equal(t, err, errInvalidSig)
+
+	// replace a leaf with another valid record
+	for name, txt := range resolver {
+		if strings.HasPrefix(txt, recordPrefix) {
+			text, err := newTestRecords(t, 1)[0].MarshalText()
+			assert.Equal(t, err, nil)
+			resolver[name] = string(text)
+			break
+		}
+	}
+
+	_, err = NewClient(resolver).SyncTree(url)
+	assert.Equal(t, err != nil, true)
+	assert.Equal(t, strings.Contains(err.Error(), "hash mismatch"), true)
+	assert.Equal(t, len(tree.Records()), 20)
+}
+
+func Test_DNSDisc_ParseURL(t *testing.T) {
+	id := *crypto.MustGenerateShardAddress(1)
+	signer, domain, err := ParseURL(treePrefix + id.Hex() + "@nodes.example.org")
+	assert.Equal(t, err, nil)
+	assert.Equal(t, signer, id)
+	assert.Equal(t, domain, "nodes.example.org")
+
+	_, _, err = ParseURL("snode://" + id.Hex() + "@nodes.example.org")
+	assert.Equal(t, err, errInvalidURL)
+
+	_, _, err = ParseURL(treePrefix + id.Hex())
+	assert.Equal(t, err, errInvalidURL)
+}
+
+func Test_DNSDisc_Records(t *testing.T) {
+	_, url, resolver := newTestTree(t, 5)
+
+	// the trees failed to sync are skipped
+	missing := treePrefix + crypto.MustGenerateShardAddress(1).Hex() + "@missing.example.org"
+	records := NewClient(resolver).Records([]string{url, "scdotree://invalid", missing})
+	assert.Equal(t, len(records), 5)
+}
+
+func Test_DNSDisc_MakeTreeInvalid(t *testing.T) {
+	_, err := MakeTree(1, nil)
+	assert.Equal(t, err, errNoRecords)
+
+	records := newTestRecords(t, 2)
+	records[1].UDPPort = 1
+	_, err = MakeTree(1, records)
+	assert.Equal(t, err != nil, true)
+}
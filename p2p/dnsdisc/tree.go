@@ -0,0 +1,312 @@
+/**
+*  @file
+*  @copyright defined in scdo/LICENSE
+ */
+
+package dnsdisc
+
+import (
+	"crypto/ecdsa"
+	"encoding/base32"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/elcn233/go-scdo/common"
+	"github.com/elcn233/go-scdo/crypto"
+	"github.com/elcn233/go-scdo/p2p/discovery"
+)
+
+const (
+	rootPrefix   = "scdo-root:v1"
+	branchPrefix = "scdo-branch:"
+	recordPrefix = "snr:"
+	treePrefix   = "scdotree://"
+
+	// hashAbbrevSize is the number of hash bytes used as the subdomain of an entry
+	hashAbbrevSize = 16
+
+	// maxChildren is the max number of children of a branch, keeps the TXT record small
+	maxChildren = 13
+)
+
+var (
+	errUnknownEntry = errors.New("unknown entry type")
+	errInvalidRoot  = errors.New("invalid root entry")
+	errInvalidURL   = errors.New("invalid tree url, should be " + treePrefix + "<signer address>@<domain>")
+	errInvalidSig   = errors.New("invalid root signature")
+	errNoRecords    = errors.New("no records in the tree")
+
+	b32format = base32.StdEncoding.WithPadding(base32.NoPadding)
+	b64format = base64.RawURLEncoding
+)
+
+type entry interface {
+	fmt.Stringer
+}
+
+// rootEntry is the signed root of the tree, it is published at the domain
+type rootEntry struct {
+	eroot string // subdomain of the root branch
+	seq   uint
+	sig   []byte
+}
+
+// branchEntry refers to the child entries by their subdomains
+type branchEntry struct {
+	children []string
+}
+
+// recordEntry is a leaf of the tree with a signed node record
+type recordEntry struct {
+	record *discovery.Record
+}
+
+func (e *rootEntry) signedText() string {
+	return fmt.Sprintf("%s e=%s seq=%d", rootPrefix, e.eroot, e.seq)
+}
+
+func (e *rootEntry) sigHash() []byte {
+	return crypto.HashBytes([]byte(e.signedText())).Bytes()
+}
+
+func (e *rootEntry) verify(signer common.Address) bool {
+	sig := crypto.Signature{Sig: e.sig}
+	return sig.Verify(signer, e.sigHash())
+}
+
+func (e *rootEntry) String() string {
+	return e.signedText() + " sig=" + b64format.EncodeToString(e.sig)
+}
+
+func (e *branchEntry) String() string {
+	return branchPrefix + strings.Join(e.children, ",")
+}
+
+func (e *recordEntry) String() string {
+	text, _ := e.record.MarshalText()
+	return string(text)
+}
+
+// subdomain returns the subdomain of the entry, which is the abbreviated hash of the entry text
+func subdomain(e entry) string {
+	return hashText(e.String())
+}
+
+func hashText(text string) string {
+	h := crypto.HashBytes([]byte(text))
+	return b32format.EncodeToString(h.Bytes()[:hashAbbrevSize])
+}
+
+func parseRoot(text string) (*rootEntry, error) {
+	var eroot, seqStr, sigStr string
+	fields := strings.Fields(text)
+	if len(fields) != 4 || fields[0] != rootPrefix {
+		return nil, errInvalidRoot
+	}
+
+	for _, field := range fields[1:] {
+		kv := strings.SplitN(field, "=", 2)
+		if len(kv) != 2 {
+			return nil, errInvalidRoot
+		}
+
+		switch kv[0] {
+		case "e":
+			eroot = kv[1]
+		case "seq":
+			seqStr = kv[1]
+		case "sig":
+			sigStr = kv[1]
+		default:
+			return nil, errInvalidRoot
+		}
+	}
+
+	seq, err := strconv.ParseUint(seqStr, 10, 32)
+	if err != nil {
+		return nil, errInvalidRoot
+	}
+
+	if !isValidHash(eroot) {
+		return nil, errInvalidRoot
+	}
+
+	sig, err := b64format.DecodeString(sigStr)
+	if err != nil || len(sig) != 65 {
+		return nil, errInvalidSig
+	}
+
+	return &rootEntry{eroot: eroot, seq: uint(seq), sig: sig}, nil
+}
+
+func parseEntry(text string) (entry, error) {
+	switch {
+	case strings.HasPrefix(text, branchPrefix):
+		children := strings.Split(text[len(branchPrefix):], ",")
+		if len(children) == 1 && children[0] == "" {
+			children = nil
+		}
+
+		for _, c := range children {
+			if !isValidHash(c) {
+				return nil, fmt.Errorf("invalid child hash %s", c)
+			}
+		}
+
+		return &branchEntry{children: children}, nil
+	case strings.HasPrefix(text, recordPrefix):
+		record := new(discovery.Record)
+		if err := record.UnmarshalText([]byte(text)); err != nil {
+			return nil, err
+		}
+
+		if err := record.Verify(); err != nil {
+			return nil, err
+		}
+
+		return &recordEntry{record: record}, nil
+	default:
+		return nil, errUnknownEntry
+	}
+}
+
+func isValidHash(s string) bool {
+	dlen := b32format.DecodedLen(len(s))
+	if dlen != hashAbbrevSize || strings.ContainsAny(s, "\n\r") {
+		return false
+	}
+
+	buf := make([]byte, hashAbbrevSize)
+	_, err := b32format.Decode(buf, []byte(s))
+	return err == nil
+}
+
+// ParseURL parses the tree url scdotree://<signer address>@<domain>
+func ParseURL(url string) (signer common.Address, domain string, err error) {
+	if !strings.HasPrefix(url, treePrefix) {
+		return common.EmptyAddress, "", errInvalidURL
+	}
+
+	parts := strings.SplitN(url[len(treePrefix):], "@", 2)
+	if len(parts) != 2 || len(parts[1]) == 0 {
+		return common.EmptyAddress, "", errInvalidURL
+	}
+
+	signer, err = common.HexToAddress(parts[0])
+	if err != nil {
+		return common.EmptyAddress, "", err
+	}
+
+	return signer, parts[1], nil
+}
+
+// Tree is the signed tree of the node records published in DNS TXT records
+type Tree struct {
+	root    *rootEntry
+	entries map[string]entry // subdomain -> entry
+}
+
+// MakeTree creates the tree of the records, the tree must be signed before publishing
+func MakeTree(seq uint, records []*discovery.Record) (*Tree, error) {
+	if len(records) == 0 {
+		return nil, errNoRecords
+	}
+
+	// sort the records so that the same set of records makes the same tree
+	sorted := make([]*discovery.Record, len(records))
+	copy(sorted, records)
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i].ID.Hex() < sorted[j].ID.Hex()
+	})
+
+	t := &Tree{entries: make(map[string]entry)}
+	leaves := make([]entry, 0, len(sorted))
+	for _, record := range sorted {
+		if err := record.Verify(); err != nil {
+			return nil, fmt.Errorf("invalid record %s, %s", record.ID.Hex(), err)
+		}
+		leaves = append(leaves, &recordEntry{record: record})
+	}
+
+	root := t.build(leaves)
+	t.root = &rootEntry{eroot: subdomain(root), seq: seq}
+	return t, nil
+}
+
+// build adds the entries to the tree and returns the root branch
+func (t *Tree) build(entries []entry) entry {
+	if len(entries) == 1 {
+		if _, ok := entries[0].(*branchEntry); ok {
+			return entries[0]
+		}
+	}
+
+	if len(entries) <= maxChildren {
+		branch := &branchEntry{}
+		for _, e := range entries {
+			sd := subdomain(e)
+			t.entries[sd] = e
+			branch.children = append(branch.children, sd)
+		}
+		t.entries[subdomain(branch)] = branch
+		return branch
+	}
+
+	var branches []entry
+	for len(entries) > 0 {
+		size := maxChildren
+		if len(entries) < size {
+			size = len(entries)
+		}
+		branches = append(branches, t.build(entries[:size]))
+		entries = entries[size:]
+	}
+
+	return t.build(branches)
+}
+
+// Sign signs the root of the tree and returns the tree url
+func (t *Tree) Sign(key *ecdsa.PrivateKey, domain string) (string, error) {
+	sig, err := crypto.Sign(key, t.root.sigHash())
+	if err != nil {
+		return "", err
+	}
+
+	t.root.sig = sig.Sig
+	return treePrefix + crypto.PubkeyToAddress(key.PublicKey).Hex() + "@" + domain, nil
+}
+
+// Seq returns the sequence number of the tree
+func (t *Tree) Seq() uint {
+	return t.root.seq
+}
+
+// Records returns the node records in the tree
+func (t *Tree) Records() []*discovery.Record {
+	var records []*discovery.Record
+	for _, e := range t.entries {
+		if re, ok := e.(*recordEntry); ok {
+			records = append(records, re.record)
+		}
+	}
+
+	sort.Slice(records, func(i, j int) bool {
+		return records[i].ID.Hex() < records[j].ID.Hex()
+	})
+
+	return records
+}
+
+// ToTXT returns the TXT records of the tree, the key is the domain name of the record
+func (t *Tree) ToTXT(domain string) map[string]string {
+	records := map[string]string{domain: t.root.String()}
+	for sd, e := range t.entries {
+		records[sd+"."+domain] = e.String()
+	}
+
+	return records
+}
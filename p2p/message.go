@@ -46,6 +46,8 @@ func SendMessage(writer MsgWriter, code uint16, payload []byte) error {
 }
 
 // Zip compress message when the length of payload is greater than zipBytesLimit
+//
+// Deprecated: connections compress the payloads with the algorithm negotiated in the handshake.
 func (msg *Message) Zip() error {
 	if len(msg.Payload) <= zipBytesLimit {
 		if len(msg.Payload) > 0 {
@@ -98,12 +100,20 @@ func (msg *Message) UnZip() error {
 }
 
 // ProtoHandShake handshake message for two peer to exchange base information
-// TODO add public key or other information for encryption?
 type ProtoHandShake struct {
 	Caps      []Cap
 	NodeID    common.Address
 	Params    []byte
 	NetworkID string
+
+	// Version is the transport version, peers with a different version are rejected
+	Version uint `rlp:"optional"`
+
+	// EphemeralKey is the per connection public key used to derive the session keys
+	EphemeralKey []byte `rlp:"optional"`
+
+	// Compressions are the supported payload compressions in preference order
+	Compressions []string `rlp:"optional"`
 }
 
 // MsgReader interface
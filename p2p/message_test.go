@@ -4,11 +4,11 @@ import (
 	"compress/gzip"
 	"errors"
 	"math/rand"
+	"strings"
 	"testing"
 	"time"
 
 	"github.com/elcn233/go-scdo/common"
-	"github.com/elcn233/go-scdo/core"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -30,6 +30,13 @@ func getRandomString(l int) string {
 	return string(result)
 }
 
+// getZippableString returns a string longer than l that gzip is able to shrink,
+// random bytes alone may be stored uncompressed.
+func getZippableString(l int) string {
+	chunk := getRandomString(50)
+	return strings.Repeat(chunk, l/len(chunk)+1)
+}
+
 // TestMsgReadWriter is a test implementation of the MsgReadWriter interface.
 type TestMsgReadWriter struct {
 	reader TestMsgReader
@@ -80,7 +87,7 @@ func Test_message(t *testing.T) {
 	assert.Equal(t, err, nil)
 	assert.Equal(t, string(msg1.Payload), randStr1)
 
-	randStr2 := getRandomString(zipBytesLimit + 50)
+	randStr2 := getZippableString(zipBytesLimit + 50)
 	msg2 := newMessage(randStr2)
 
 	err = msg2.Zip()
@@ -102,7 +109,7 @@ func Test_message(t *testing.T) {
 	assert.Equal(t, string(msgEmpty.Payload), "")
 
 	// Corrupted data
-	randStr := getRandomString(zipBytesLimit + 50)
+	randStr := getZippableString(zipBytesLimit + 50)
 	msg := newMessage(randStr)
 	err = msg.Zip()
 	assert.Equal(t, err, nil)
@@ -113,8 +120,11 @@ func Test_message(t *testing.T) {
 	assert.Equal(t, err, gzip.ErrChecksum)
 }
 
+// benchBlockByteLimit is the block size limit of core.BlockByteLimit
+const benchBlockByteLimit = 1024 * 1024
+
 func Benchmark_message_Zip(b *testing.B) {
-	randStr := getRandomString(core.BlockByteLimit)
+	randStr := getRandomString(benchBlockByteLimit)
 
 	for i := 0; i < b.N; i++ {
 		b.StopTimer()
@@ -126,7 +136,7 @@ func Benchmark_message_Zip(b *testing.B) {
 }
 
 func Benchmark_message_UnZip(b *testing.B) {
-	randStr := getRandomString(core.BlockByteLimit)
+	randStr := getRandomString(benchBlockByteLimit)
 
 	for i := 0; i < b.N; i++ {
 		b.StopTimer()
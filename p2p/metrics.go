@@ -12,8 +12,13 @@ var (
 	metricsDeletePeerMeter = metrics.NewRegisteredMeter("p2p.deletepeer", nil)
 	metricsPeerCountGauge  = metrics.NewRegisteredGauge("p2p.peercount", nil)
 
-	metricsSendMessageCountMeter  = metrics.NewRegisteredMeter("p2p.sendmessagecount", nil)
-	metricsReceiveMessageCountMeter  = metrics.NewRegisteredMeter("p2p.receivemessagecount", nil)
-	metricsSendPortSpeedMeter = metrics.NewRegisteredMeter("p2p.sendportspeed", nil)
-	metricsReceivePortSpeedMeter = metrics.NewRegisteredMeter("p2p.receiveportspeed", nil)
+	metricsSendMessageCountMeter    = metrics.NewRegisteredMeter("p2p.sendmessagecount", nil)
+	metricsReceiveMessageCountMeter = metrics.NewRegisteredMeter("p2p.receivemessagecount", nil)
+	metricsSendPortSpeedMeter       = metrics.NewRegisteredMeter("p2p.sendportspeed", nil)
+	metricsReceivePortSpeedMeter    = metrics.NewRegisteredMeter("p2p.receiveportspeed", nil)
+
+	// compression ratio is recorded in percent of the compressed size to the raw size
+	metricsCompressRawBytesMeter        = metrics.NewRegisteredMeter("p2p.compress.rawbytes", nil)
+	metricsCompressCompressedBytesMeter = metrics.NewRegisteredMeter("p2p.compress.compressedbytes", nil)
+	metricsCompressRatioHistogram       = metrics.NewRegisteredHistogram("p2p.compress.ratio", nil, metrics.NewExpDecaySample(1028, 0.015))
 )
@@ -0,0 +1,67 @@
+/**
+*  @file
+*  @copyright defined in scdo/LICENSE
+ */
+
+package p2p
+
+import (
+	"net"
+
+	"github.com/elcn233/go-scdo/p2p/nat"
+)
+
+// natMappingName is the description of the port mappings on the gateway
+const natMappingName = "scdo p2p"
+
+// startNAT maps the listen port on the NAT gateway and advertises the external
+// endpoint to discovery. The tcp and udp share the same port, so the udp port
+// mapped by the gateway is also requested for tcp.
+func (srv *Server) startNAT(natm nat.Interface, addr *net.UDPAddr) {
+	defer srv.loopWG.Done()
+
+	ip, err := natm.ExternalIP()
+	if err != nil {
+		srv.log.Warn("nat %s failed to get external ip, %s", natm, err)
+		return
+	}
+
+	if _, ok := natm.(nat.ExtIP); ok {
+		srv.setExternalEndpoint(ip, addr.Port)
+		return
+	}
+
+	mapped := make(chan uint16)
+	srv.loopWG.Add(1)
+	go func() {
+		defer srv.loopWG.Done()
+		nat.Map(natm, srv.quit, "udp", addr.Port, addr.Port, natMappingName, mapped)
+	}()
+
+	tcpMapped := false
+	for {
+		select {
+		case port := <-mapped:
+			srv.setExternalEndpoint(ip, int(port))
+			if int(port) != addr.Port {
+				srv.log.Warn("nat %s mapped external port %d differs from listen port %d, inbound tcp connections may fail", natm, port, addr.Port)
+			}
+
+			if !tcpMapped {
+				tcpMapped = true
+				srv.loopWG.Add(1)
+				go func(port int) {
+					defer srv.loopWG.Done()
+					nat.Map(natm, srv.quit, "tcp", port, addr.Port, natMappingName, nil)
+				}(int(port))
+			}
+		case <-srv.quit:
+			return
+		}
+	}
+}
+
+func (srv *Server) setExternalEndpoint(ip net.IP, port int) {
+	srv.udp.SetExternalEndpoint(ip, port)
+	srv.log.Info("p2p node is reachable at external endpoint %s:%d", ip, port)
+}
@@ -0,0 +1,242 @@
+/**
+*  @file
+*  @copyright defined in scdo/LICENSE
+ */
+
+package nat
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/elcn233/go-scdo/log"
+)
+
+const (
+	// mapTimeout is the lifetime of the port mappings requested from the gateway
+	mapTimeout = 20 * time.Minute
+
+	// mapUpdateInterval is the interval the port mappings are renewed, must be less than mapTimeout
+	mapUpdateInterval = 15 * time.Minute
+
+	// discoverTimeout is the max time to wait for a gateway to respond
+	discoverTimeout = 3 * time.Second
+)
+
+var (
+	errInvalidNATSpec = errors.New("invalid nat spec, should be one of none, any, upnp, pmp, pmp:<gateway ip> or extip:<ip>")
+	errNoGateway      = errors.New("no nat gateway found")
+)
+
+// Interface is the port mapper of a NAT device
+type Interface interface {
+	// AddMapping maps the external port to the internal port of the protocol (tcp or udp)
+	// and returns the external port actually mapped by the gateway.
+	AddMapping(protocol string, extport, intport int, name string, lifetime time.Duration) (uint16, error)
+
+	// DeleteMapping removes the port mapping
+	DeleteMapping(protocol string, extport, intport int) error
+
+	// ExternalIP returns the public ip of the gateway
+	ExternalIP() (net.IP, error)
+
+	// String returns the name of the port mapper
+	String() string
+}
+
+// Parse parses the nat spec, which is one of:
+//
+//	""/"none"           no port mapping
+//	"any"               auto detect the gateway with UPnP or NAT-PMP
+//	"upnp"              use UPnP
+//	"pmp"/"pmp:<ip>"    use NAT-PMP, the gateway is auto detected if the ip is not specified
+//	"extip:<ip>"        the node is reachable at the ip without port mapping
+func Parse(spec string) (Interface, error) {
+	parts := strings.SplitN(spec, ":", 2)
+	mech := strings.ToLower(parts[0])
+
+	var ip net.IP
+	if len(parts) > 1 {
+		if ip = net.ParseIP(parts[1]); ip == nil {
+			return nil, fmt.Errorf("invalid ip %s in nat spec", parts[1])
+		}
+	}
+
+	switch mech {
+	case "", "none", "off":
+		if ip != nil {
+			return nil, errInvalidNATSpec
+		}
+		return nil, nil
+	case "any", "auto":
+		return Any(), nil
+	case "upnp":
+		return UPnP(), nil
+	case "pmp", "natpmp", "nat-pmp":
+		return PMP(ip), nil
+	case "extip", "ip":
+		if ip == nil {
+			return nil, errors.New("missing ip in extip nat spec")
+		}
+		return ExtIP(ip), nil
+	default:
+		return nil, errInvalidNATSpec
+	}
+}
+
+// Map adds the port mapping and keeps it alive until quit is closed, then removes the mapping.
+// The mapped external port is sent to mapped whenever the mapping is set up, mapped can be nil.
+func Map(m Interface, quit <-chan struct{}, protocol string, extport, intport int, name string, mapped chan<- uint16) {
+	logger := log.GetLogger("p2p")
+	refresh := time.NewTimer(mapUpdateInterval)
+	defer refresh.Stop()
+
+	addMapping := func() {
+		port, err := m.AddMapping(protocol, extport, intport, name, mapTimeout)
+		if err != nil {
+			logger.Warn("nat %s failed to map %s port %d -> %d, %s", m, protocol, extport, intport, err)
+			return
+		}
+
+		logger.Info("nat %s mapped %s port %d -> %d", m, protocol, port, intport)
+		extport = int(port)
+		if mapped != nil {
+			select {
+			case mapped <- port:
+			case <-quit:
+			}
+		}
+	}
+
+	addMapping()
+	for {
+		select {
+		case <-quit:
+			logger.Debug("nat %s deleting %s port mapping %d -> %d", m, protocol, extport, intport)
+			if err := m.DeleteMapping(protocol, extport, intport); err != nil {
+				logger.Debug("nat %s failed to delete port mapping, %s", m, err)
+			}
+			return
+		case <-refresh.C:
+			addMapping()
+			refresh.Reset(mapUpdateInterval)
+		}
+	}
+}
+
+// ExtIP assumes the node is reachable at the given ip, the ports are not mapped
+type ExtIP net.IP
+
+// AddMapping does nothing and returns the external port
+func (ExtIP) AddMapping(protocol string, extport, intport int, name string, lifetime time.Duration) (uint16, error) {
+	return uint16(extport), nil
+}
+
+// DeleteMapping does nothing
+func (ExtIP) DeleteMapping(protocol string, extport, intport int) error { return nil }
+
+// ExternalIP returns the ip
+func (n ExtIP) ExternalIP() (net.IP, error) { return net.IP(n), nil }
+
+func (n ExtIP) String() string { return fmt.Sprintf("extip:%v", net.IP(n)) }
+
+// Any returns a port mapper that uses the first gateway responding to UPnP or NAT-PMP
+func Any() Interface {
+	return startAutoDisc("UPnP or NAT-PMP", func() Interface {
+		found := make(chan Interface, 2)
+		go func() { found <- discoverUPnP() }()
+		go func() { found <- discoverPMP() }()
+
+		for i := 0; i < cap(found); i++ {
+			if n := <-found; n != nil {
+				return n
+			}
+		}
+
+		return nil
+	})
+}
+
+// UPnP returns a port mapper that uses the UPnP internet gateway found in the local network
+func UPnP() Interface {
+	return startAutoDisc("UPnP", discoverUPnP)
+}
+
+// PMP returns a port mapper that uses NAT-PMP. The gateway is auto detected if it is nil.
+func PMP(gateway net.IP) Interface {
+	if gateway != nil {
+		return newPMP(&net.UDPAddr{IP: gateway, Port: pmpPort})
+	}
+
+	return startAutoDisc("NAT-PMP", discoverPMP)
+}
+
+// autodisc discovers the gateway in the background on the first use,
+// so that parsing the nat spec doesn't block the node startup.
+type autodisc struct {
+	what string
+	once sync.Once
+	doit func() Interface
+
+	mu    sync.Mutex
+	found Interface
+}
+
+func startAutoDisc(what string, doit func() Interface) Interface {
+	return &autodisc{what: what, doit: doit}
+}
+
+func (n *autodisc) AddMapping(protocol string, extport, intport int, name string, lifetime time.Duration) (uint16, error) {
+	if err := n.wait(); err != nil {
+		return 0, err
+	}
+
+	return n.found.AddMapping(protocol, extport, intport, name, lifetime)
+}
+
+func (n *autodisc) DeleteMapping(protocol string, extport, intport int) error {
+	if err := n.wait(); err != nil {
+		return err
+	}
+
+	return n.found.DeleteMapping(protocol, extport, intport)
+}
+
+func (n *autodisc) ExternalIP() (net.IP, error) {
+	if err := n.wait(); err != nil {
+		return nil, err
+	}
+
+	return n.found.ExternalIP()
+}
+
+func (n *autodisc) String() string {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	if n.found == nil {
+		return n.what
+	}
+
+	return n.found.String()
+}
+
+func (n *autodisc) wait() error {
+	n.once.Do(func() {
+		found := n.doit()
+
+		n.mu.Lock()
+		n.found = found
+		n.mu.Unlock()
+	})
+
+	if n.found == nil {
+		return errNoGateway
+	}
+
+	return nil
+}
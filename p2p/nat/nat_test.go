@@ -0,0 +1,299 @@
+/**
+*  @file
+*  @copyright defined in scdo/LICENSE
+ */
+
+package nat
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"regexp"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var fakeExternalIP = net.IPv4(1, 2, 3, 4)
+
+func Test_NAT_Parse(t *testing.T) {
+	n, err := Parse("")
+	assert.Equal(t, err, nil)
+	assert.Equal(t, n, nil)
+
+	n, err = Parse("none")
+	assert.Equal(t, err, nil)
+	assert.Equal(t, n, nil)
+
+	n, err = Parse("extip:1.2.3.4")
+	assert.Equal(t, err, nil)
+	ip, err := n.ExternalIP()
+	assert.Equal(t, err, nil)
+	assert.Equal(t, ip.Equal(fakeExternalIP), true)
+	assert.Equal(t, n.String(), "extip:1.2.3.4")
+
+	port, err := n.AddMapping("tcp", 8057, 8057, "scdo", time.Minute)
+	assert.Equal(t, err, nil)
+	assert.Equal(t, port, uint16(8057))
+
+	n, err = Parse("pmp:192.168.1.1")
+	assert.Equal(t, err, nil)
+	assert.Equal(t, n.String(), "NAT-PMP(192.168.1.1)")
+
+	n, err = Parse("upnp")
+	assert.Equal(t, err, nil)
+	assert.Equal(t, n.String(), "UPnP")
+
+	n, err = Parse("any")
+	assert.Equal(t, err, nil)
+	assert.Equal(t, n.String(), "UPnP or NAT-PMP")
+
+	_, err = Parse("extip")
+	assert.Equal(t, err != nil, true)
+
+	_, err = Parse("extip:1.2.3")
+	assert.Equal(t, err != nil, true)
+
+	_, err = Parse("stun")
+	assert.Equal(t, err, errInvalidNATSpec)
+}
+
+// fakePMPGateway is a NAT-PMP gateway that maps the ports to port+1000
+type fakePMPGateway struct {
+	conn *net.UDPConn
+
+	lock     sync.Mutex
+	mappings map[string]uint32 // protocol:internal port -> lifetime
+}
+
+func newFakePMPGateway(t *testing.T) *fakePMPGateway {
+	conn, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	assert.Equal(t, err, nil)
+
+	gw := &fakePMPGateway{conn: conn, mappings: make(map[string]uint32)}
+	go gw.serve()
+	return gw
+}
+
+func (gw *fakePMPGateway) serve() {
+	buff := make([]byte, 64)
+	for {
+		n, addr, err := gw.conn.ReadFromUDP(buff)
+		if err != nil {
+			return
+		}
+
+		req := buff[:n]
+		switch {
+		case n == 2 && req[1] == pmpOpExternalAddr:
+			resp := make([]byte, 12)
+			resp[1] = pmpResponseFlag
+			copy(resp[8:], fakeExternalIP.To4())
+			gw.conn.WriteToUDP(resp, addr)
+		case n == 12 && (req[1] == pmpOpMapUDP || req[1] == pmpOpMapTCP):
+			intport := binary.BigEndian.Uint16(req[4:])
+			lifetime := binary.BigEndian.Uint32(req[8:])
+
+			gw.lock.Lock()
+			key := fmt.Sprintf("%d:%d", req[1], intport)
+			if lifetime == 0 {
+				delete(gw.mappings, key)
+			} else {
+				gw.mappings[key] = lifetime
+			}
+			gw.lock.Unlock()
+
+			resp := make([]byte, 16)
+			resp[1] = req[1] | pmpResponseFlag
+			binary.BigEndian.PutUint16(resp[8:], intport)
+			binary.BigEndian.PutUint16(resp[10:], intport+1000)
+			binary.BigEndian.PutUint32(resp[12:], lifetime)
+			gw.conn.WriteToUDP(resp, addr)
+		}
+	}
+}
+
+func (gw *fakePMPGateway) mappingCount() int {
+	gw.lock.Lock()
+	defer gw.lock.Unlock()
+
+	return len(gw.mappings)
+}
+
+func Test_NAT_PMP(t *testing.T) {
+	gw := newFakePMPGateway(t)
+	defer gw.conn.Close()
+
+	n := newPMP(gw.conn.LocalAddr().(*net.UDPAddr))
+	ip, err := n.ExternalIP()
+	assert.Equal(t, err, nil)
+	assert.Equal(t, ip.Equal(fakeExternalIP), true)
+
+	port, err := n.AddMapping("tcp", 8057, 8057, "scdo", mapTimeout)
+	assert.Equal(t, err, nil)
+	assert.Equal(t, port, uint16(9057))
+
+	port, err = n.AddMapping("udp", 8057, 8057, "scdo", mapTimeout)
+	assert.Equal(t, err, nil)
+	assert.Equal(t, port, uint16(9057))
+	assert.Equal(t, gw.mappingCount(), 2)
+
+	assert.Equal(t, n.DeleteMapping("tcp", 9057, 8057), nil)
+	assert.Equal(t, gw.mappingCount(), 1)
+
+	_, err = n.AddMapping("sctp", 8057, 8057, "scdo", mapTimeout)
+	assert.Equal(t, err != nil, true)
+}
+
+func Test_NAT_PMPTimeout(t *testing.T) {
+	conn, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	assert.Equal(t, err, nil)
+	defer conn.Close()
+
+	n := newPMP(conn.LocalAddr().(*net.UDPAddr))
+	n.timeout = 300 * time.Millisecond
+	_, err = n.ExternalIP()
+	assert.Equal(t, err != nil, true)
+}
+
+func Test_NAT_Map(t *testing.T) {
+	gw := newFakePMPGateway(t)
+	defer gw.conn.Close()
+
+	quit := make(chan struct{})
+	mapped := make(chan uint16)
+	done := make(chan struct{})
+	go func() {
+		Map(newPMP(gw.conn.LocalAddr().(*net.UDPAddr)), quit, "udp", 8057, 8057, "scdo", mapped)
+		close(done)
+	}()
+
+	assert.Equal(t, <-mapped, uint16(9057))
+	assert.Equal(t, gw.mappingCount(), 1)
+
+	close(quit)
+	<-done
+	assert.Equal(t, gw.mappingCount(), 0)
+}
+
+const fakeIGDDescription = `<?xml version="1.0"?>
+<root xmlns="urn:schemas-upnp-org:device-1-0">
+  <specVersion><major>1</major><minor>0</minor></specVersion>
+  <device>
+    <deviceType>urn:schemas-upnp-org:device:InternetGatewayDevice:1</deviceType>
+    <friendlyName>fake gateway</friendlyName>
+    <deviceList>
+      <device>
+        <deviceType>urn:schemas-upnp-org:device:WANDevice:1</deviceType>
+        <deviceList>
+          <device>
+            <deviceType>urn:schemas-upnp-org:device:WANConnectionDevice:1</deviceType>
+            <serviceList>
+              <service>
+                <serviceType>urn:schemas-upnp-org:service:WANIPConnection:1</serviceType>
+                <serviceId>urn:upnp-org:serviceId:WANIPConn1</serviceId>
+                <controlURL>/ctl</controlURL>
+                <eventSubURL>/evt</eventSubURL>
+                <SCPDURL>/scpd.xml</SCPDURL>
+              </service>
+            </serviceList>
+          </device>
+        </deviceList>
+      </device>
+    </deviceList>
+  </device>
+</root>`
+
+const fakeSOAPResponse = `<?xml version="1.0"?>
+<s:Envelope xmlns:s="http://schemas.xmlsoap.org/soap/envelope/" s:encodingStyle="http://schemas.xmlsoap.org/soap/encoding/">
+  <s:Body><u:%sResponse xmlns:u="urn:schemas-upnp-org:service:WANIPConnection:1">%s</u:%sResponse></s:Body>
+</s:Envelope>`
+
+// fakeIGD is a UPnP internet gateway device with the WANIPConnection:1 service
+type fakeIGD struct {
+	lock     sync.Mutex
+	mappings map[string]string // protocol:external port -> internal client:internal port
+}
+
+func (gw *fakeIGD) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch r.URL.Path {
+	case "/desc.xml":
+		w.Header().Set("Content-Type", "text/xml")
+		w.Write([]byte(fakeIGDDescription))
+	case "/ctl":
+		action := strings.Trim(r.Header.Get("SOAPACTION"), `"`)
+		action = action[strings.Index(action, "#")+1:]
+		body, _ := ioutil.ReadAll(r.Body)
+		arg := func(name string) string {
+			m := regexp.MustCompile("<" + name + ">(.*?)</" + name + ">").FindSubmatch(body)
+			if m == nil {
+				return ""
+			}
+			return string(m[1])
+		}
+
+		var out string
+		gw.lock.Lock()
+		switch action {
+		case "GetExternalIPAddress":
+			out = "<NewExternalIPAddress>" + fakeExternalIP.String() + "</NewExternalIPAddress>"
+		case "AddPortMapping":
+			key := arg("NewProtocol") + ":" + arg("NewExternalPort")
+			gw.mappings[key] = arg("NewInternalClient") + ":" + arg("NewInternalPort")
+		case "DeletePortMapping":
+			delete(gw.mappings, arg("NewProtocol")+":"+arg("NewExternalPort"))
+		default:
+			gw.lock.Unlock()
+			http.Error(w, "unknown action", http.StatusInternalServerError)
+			return
+		}
+		gw.lock.Unlock()
+
+		w.Header().Set("Content-Type", "text/xml")
+		fmt.Fprintf(w, fakeSOAPResponse, action, out, action)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func Test_NAT_UPnP(t *testing.T) {
+	gw := &fakeIGD{mappings: make(map[string]string)}
+	server := httptest.NewServer(gw)
+	defer server.Close()
+
+	loc, err := url.Parse(server.URL + "/desc.xml")
+	assert.Equal(t, err, nil)
+
+	n, err := newUPnPFromURL(loc)
+	assert.Equal(t, err, nil)
+	assert.Equal(t, n.String(), "UPnP IGD-IP1")
+
+	ip, err := n.ExternalIP()
+	assert.Equal(t, err, nil)
+	assert.Equal(t, ip.Equal(fakeExternalIP), true)
+
+	port, err := n.AddMapping("tcp", 8057, 8057, "scdo", mapTimeout)
+	assert.Equal(t, err, nil)
+	assert.Equal(t, port, uint16(8057))
+
+	_, err = n.AddMapping("udp", 8057, 8057, "scdo", mapTimeout)
+	assert.Equal(t, err, nil)
+
+	gw.lock.Lock()
+	assert.Equal(t, gw.mappings, map[string]string{"TCP:8057": "127.0.0.1:8057", "UDP:8057": "127.0.0.1:8057"})
+	gw.lock.Unlock()
+
+	assert.Equal(t, n.DeleteMapping("tcp", 8057, 8057), nil)
+
+	gw.lock.Lock()
+	assert.Equal(t, len(gw.mappings), 1)
+	gw.lock.Unlock()
+}
@@ -0,0 +1,202 @@
+/**
+*  @file
+*  @copyright defined in scdo/LICENSE
+ */
+
+package nat
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+)
+
+const (
+	// pmpPort is the port NAT-PMP gateways listen on
+	pmpPort = 5351
+
+	pmpVersion          = 0
+	pmpOpExternalAddr   = 0
+	pmpOpMapUDP         = 1
+	pmpOpMapTCP         = 2
+	pmpResponseFlag     = 128
+	pmpInitialRetryTime = 250 * time.Millisecond
+)
+
+var errPMPResponse = errors.New("invalid NAT-PMP response")
+
+// pmp is the NAT-PMP (RFC 6886) port mapper
+type pmp struct {
+	gateway *net.UDPAddr
+	timeout time.Duration
+}
+
+func newPMP(gateway *net.UDPAddr) *pmp {
+	return &pmp{gateway: gateway, timeout: discoverTimeout}
+}
+
+func (n *pmp) String() string {
+	return fmt.Sprintf("NAT-PMP(%v)", n.gateway.IP)
+}
+
+func (n *pmp) ExternalIP() (net.IP, error) {
+	resp, err := n.rpc([]byte{pmpVersion, pmpOpExternalAddr}, 12)
+	if err != nil {
+		return nil, err
+	}
+
+	return net.IPv4(resp[8], resp[9], resp[10], resp[11]), nil
+}
+
+func (n *pmp) AddMapping(protocol string, extport, intport int, name string, lifetime time.Duration) (uint16, error) {
+	if lifetime <= 0 {
+		return 0, errors.New("lifetime must not be <= 0")
+	}
+
+	return n.mapping(protocol, extport, intport, uint32(lifetime/time.Second))
+}
+
+func (n *pmp) DeleteMapping(protocol string, extport, intport int) error {
+	// the mapping is deleted by requesting a mapping with external port and lifetime 0
+	_, err := n.mapping(protocol, 0, intport, 0)
+	return err
+}
+
+func (n *pmp) mapping(protocol string, extport, intport int, lifetime uint32) (uint16, error) {
+	var op byte
+	switch strings.ToLower(protocol) {
+	case "udp":
+		op = pmpOpMapUDP
+	case "tcp":
+		op = pmpOpMapTCP
+	default:
+		return 0, fmt.Errorf("unknown protocol %s", protocol)
+	}
+
+	msg := make([]byte, 12)
+	msg[0] = pmpVersion
+	msg[1] = op
+	binary.BigEndian.PutUint16(msg[4:], uint16(intport))
+	binary.BigEndian.PutUint16(msg[6:], uint16(extport))
+	binary.BigEndian.PutUint32(msg[8:], lifetime)
+
+	resp, err := n.rpc(msg, 16)
+	if err != nil {
+		return 0, err
+	}
+
+	return binary.BigEndian.Uint16(resp[10:12]), nil
+}
+
+// rpc sends the request to the gateway and waits for the response,
+// the request is resent with doubled wait time until timeout.
+func (n *pmp) rpc(msg []byte, size int) ([]byte, error) {
+	conn, err := net.DialUDP("udp", nil, n.gateway)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	deadline := time.Now().Add(n.timeout)
+	buff := make([]byte, 16)
+	for wait := pmpInitialRetryTime; time.Now().Before(deadline); wait *= 2 {
+		if _, err = conn.Write(msg); err != nil {
+			return nil, err
+		}
+
+		readDeadline := time.Now().Add(wait)
+		if readDeadline.After(deadline) {
+			readDeadline = deadline
+		}
+		conn.SetReadDeadline(readDeadline)
+
+		var num int
+		num, err = conn.Read(buff)
+		if err != nil {
+			if e, ok := err.(net.Error); ok && e.Timeout() {
+				continue
+			}
+			return nil, err
+		}
+
+		return checkPMPResponse(msg, buff[:num], size)
+	}
+
+	if err == nil {
+		err = errors.New("NAT-PMP request timeout")
+	}
+
+	return nil, err
+}
+
+func checkPMPResponse(msg, resp []byte, size int) ([]byte, error) {
+	if len(resp) < size || resp[0] != pmpVersion || resp[1] != msg[1]|pmpResponseFlag {
+		return nil, errPMPResponse
+	}
+
+	if code := binary.BigEndian.Uint16(resp[2:4]); code != 0 {
+		return nil, fmt.Errorf("NAT-PMP gateway returns error code %d", code)
+	}
+
+	return resp, nil
+}
+
+// discoverPMP asks the potential gateways for the external address
+// and returns the first one that responds, nil if none responds.
+func discoverPMP() Interface {
+	gateways := potentialGateways()
+	found := make(chan *pmp, len(gateways))
+	for _, gw := range gateways {
+		go func(gw net.IP) {
+			n := newPMP(&net.UDPAddr{IP: gw, Port: pmpPort})
+			if _, err := n.ExternalIP(); err != nil {
+				found <- nil
+				return
+			}
+			found <- n
+		}(gw)
+	}
+
+	for range gateways {
+		if n := <-found; n != nil {
+			return n
+		}
+	}
+
+	return nil
+}
+
+// potentialGateways returns the first address (usually the router) of the
+// private networks the host is in.
+func potentialGateways() []net.IP {
+	ifaces, err := net.Interfaces()
+	if err != nil {
+		return nil
+	}
+
+	var gateways []net.IP
+	for _, iface := range ifaces {
+		addrs, err := iface.Addrs()
+		if err != nil {
+			continue
+		}
+
+		for _, addr := range addrs {
+			ipnet, ok := addr.(*net.IPNet)
+			if !ok || !ipnet.IP.IsPrivate() {
+				continue
+			}
+
+			ip := ipnet.IP.Mask(ipnet.Mask).To4()
+			if ip != nil {
+				ip[3] |= 1
+				gateways = append(gateways, ip)
+			}
+		}
+	}
+
+	return gateways
+}
@@ -0,0 +1,174 @@
+/**
+*  @file
+*  @copyright defined in scdo/LICENSE
+ */
+
+package nat
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/huin/goupnp"
+	"github.com/huin/goupnp/dcps/internetgateway2"
+)
+
+// upnpClient is the port mapping service of an internet gateway device
+type upnpClient interface {
+	GetExternalIPAddress() (string, error)
+	AddPortMapping(string, uint16, string, uint16, string, bool, string, uint32) error
+	DeletePortMapping(string, uint16, string) error
+	GetServiceClient() *goupnp.ServiceClient
+}
+
+// upnp is the UPnP port mapper
+type upnp struct {
+	client  upnpClient
+	service string
+
+	// the gateway doesn't handle concurrent requests well
+	lock sync.Mutex
+}
+
+func (n *upnp) String() string {
+	return "UPnP " + n.service
+}
+
+func (n *upnp) ExternalIP() (net.IP, error) {
+	n.lock.Lock()
+	defer n.lock.Unlock()
+
+	str, err := n.client.GetExternalIPAddress()
+	if err != nil {
+		return nil, err
+	}
+
+	ip := net.ParseIP(str)
+	if ip == nil {
+		return nil, fmt.Errorf("invalid external ip %s from gateway", str)
+	}
+
+	return ip, nil
+}
+
+func (n *upnp) AddMapping(protocol string, extport, intport int, name string, lifetime time.Duration) (uint16, error) {
+	ip, err := n.internalAddress()
+	if err != nil {
+		return 0, err
+	}
+
+	n.lock.Lock()
+	defer n.lock.Unlock()
+
+	protocol = strings.ToUpper(protocol)
+	// remove the stale mapping first, some gateways refuse to overwrite it
+	n.client.DeletePortMapping("", uint16(extport), protocol)
+
+	err = n.client.AddPortMapping("", uint16(extport), protocol, uint16(intport), ip.String(), true, name, uint32(lifetime/time.Second))
+	if err != nil {
+		return 0, err
+	}
+
+	return uint16(extport), nil
+}
+
+func (n *upnp) DeleteMapping(protocol string, extport, intport int) error {
+	n.lock.Lock()
+	defer n.lock.Unlock()
+
+	return n.client.DeletePortMapping("", uint16(extport), strings.ToUpper(protocol))
+}
+
+// internalAddress returns the local ip the gateway is reached from
+func (n *upnp) internalAddress() (net.IP, error) {
+	sc := n.client.GetServiceClient()
+	if ip := sc.LocalAddr(); ip != nil && !ip.IsUnspecified() {
+		return ip, nil
+	}
+
+	host := sc.RootDevice.URLBase.Hostname()
+	port := sc.RootDevice.URLBase.Port()
+	if port == "" {
+		port = "80"
+	}
+
+	conn, err := net.Dial("udp", net.JoinHostPort(host, port))
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	return conn.LocalAddr().(*net.UDPAddr).IP, nil
+}
+
+// discoverUPnP searches the internet gateway devices in the local network
+// and returns the first one providing the port mapping service, nil if not found.
+func discoverUPnP() Interface {
+	found := make(chan *upnp, 3)
+	go func() {
+		clients, _, _ := internetgateway2.NewWANIPConnection2Clients()
+		found <- firstUPnP("IGDv2-IP2", clients)
+	}()
+	go func() {
+		clients, _, _ := internetgateway2.NewWANIPConnection1Clients()
+		found <- firstUPnP("IGD-IP1", clients)
+	}()
+	go func() {
+		clients, _, _ := internetgateway2.NewWANPPPConnection1Clients()
+		found <- firstUPnP("IGD-PPP1", clients)
+	}()
+
+	timeout := time.After(discoverTimeout + time.Second)
+	for i := 0; i < cap(found); i++ {
+		select {
+		case n := <-found:
+			if n != nil {
+				return n
+			}
+		case <-timeout:
+			return nil
+		}
+	}
+
+	return nil
+}
+
+// newUPnPFromURL creates the UPnP port mapper for the gateway device description at the url
+func newUPnPFromURL(loc *url.URL) (*upnp, error) {
+	if clients, err := internetgateway2.NewWANIPConnection2ClientsByURL(loc); err == nil {
+		if n := firstUPnP("IGDv2-IP2", clients); n != nil {
+			return n, nil
+		}
+	}
+
+	if clients, err := internetgateway2.NewWANIPConnection1ClientsByURL(loc); err == nil {
+		if n := firstUPnP("IGD-IP1", clients); n != nil {
+			return n, nil
+		}
+	}
+
+	if clients, err := internetgateway2.NewWANPPPConnection1ClientsByURL(loc); err == nil {
+		if n := firstUPnP("IGD-PPP1", clients); n != nil {
+			return n, nil
+		}
+	}
+
+	return nil, errors.New("no port mapping service found in the gateway")
+}
+
+// firstUPnP returns the port mapper of the first client that reports its external ip
+func firstUPnP[T upnpClient](service string, clients []T) *upnp {
+	for _, c := range clients {
+		n := &upnp{client: c, service: service}
+		if _, err := n.ExternalIP(); err == nil {
+			return n
+		}
+	}
+
+	return nil
+}
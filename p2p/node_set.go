@@ -99,6 +99,30 @@ func (set *nodeSet) tryAdd(p *discovery.Node) {
 	} // add ip count
 }
 
+// update replaces the node if it exists with a different endpoint
+func (set *nodeSet) update(p *discovery.Node) {
+	set.lock.Lock()
+	defer set.lock.Unlock()
+
+	item := set.nodeMap[p.ID]
+	if item == nil {
+		return
+	}
+
+	old := item.node
+	item.node = p
+	if old.IP.Equal(p.IP) {
+		return
+	}
+
+	if set.ipSet != nil {
+		if cnt := set.ipSet[old.Shard][old.IP.String()]; cnt > 0 {
+			set.ipSet[old.Shard][old.IP.String()]--
+		}
+		set.ipSet[p.Shard][p.IP.String()]++
+	}
+}
+
 func (set *nodeSet) delete(p *discovery.Node) {
 	set.lock.Lock()
 	defer set.lock.Unlock()
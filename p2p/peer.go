@@ -31,6 +31,8 @@ type Peer struct {
 	disconnection chan string
 	protocolMap   map[string]protocolRW // protocol cap => protocol read write wrapper
 	rw            *connection
+	reputation    *reputation // reputation of the server, nil if misbehavior is not tracked
+	traffic       *peerTraffic
 
 	wg   sync.WaitGroup
 	log  *log.ScdoLog
@@ -48,6 +50,7 @@ func NewPeer(conn *connection, log *log.ScdoLog, node *discovery.Node) *Peer {
 		log:           log,
 		protocolErr:   make(chan error),
 		Node:          node,
+		traffic:       newPeerTraffic(),
 		lock:          sync.Mutex{},
 	}
 }
@@ -63,7 +66,9 @@ func (p *Peer) setProtocols(protocols []Protocol) {
 			Protocol: protocol,
 			in:       make(chan Message, 1),
 			close:    p.closed,
+			traffic:  p.traffic,
 		}
+		p.traffic.setLimits(protocol.Name, protocol.RateLimits)
 
 		protoMap[protocol.cap().String()] = protoRW
 		offset += protocol.Length
@@ -224,6 +229,13 @@ func (p *Peer) handle(msgRecv *Message) (err error) {
 		return fmt.Errorf(fmt.Sprintf("could not found mapping proto with code %d", msgRecv.Code))
 	}
 
+	// drop the message exceeding the rate limit, the peer is banned if it keeps flooding
+	code := msgRecv.Code - protocolTarget.offset
+	if p.traffic != nil && !p.traffic.ingress(protocolTarget.Name, code, len(msgRecv.Payload)) {
+		p.ReportMisbehavior(PenaltyRateLimited, fmt.Sprintf("rate limit of %s message %d exceeded", protocolTarget.Name, code))
+		return nil
+	}
+
 	if !protocolTarget.bQuited {
 		protocolTarget.in <- *msgRecv
 	}
@@ -252,6 +264,18 @@ func (p *Peer) Disconnect(reason string) {
 	}
 }
 
+// ReportMisbehavior lowers the reputation of the peer with the penalty.
+// The peer is disconnected and banned if its score drops too low.
+func (p *Peer) ReportMisbehavior(penalty int, reason string) {
+	if p.reputation == nil || p.Node == nil {
+		return
+	}
+
+	if p.reputation.report(p.Node.ID, penalty, reason) {
+		go p.Disconnect(fmt.Sprintf("banned for misbehavior, %s", reason))
+	}
+}
+
 type protocolRW struct {
 	Protocol
 	bQuited bool
@@ -259,6 +283,7 @@ type protocolRW struct {
 	in      chan Message // read message channel, message will be transferred here when it is a protocol message
 	rw      MsgReadWriter
 	close   chan struct{}
+	traffic *peerTraffic
 }
 
 func (rw *protocolRW) WriteMsg(msg *Message) (err error) {
@@ -266,6 +291,10 @@ func (rw *protocolRW) WriteMsg(msg *Message) (err error) {
 		return errors.New("invalid msg code")
 	}
 
+	if rw.traffic != nil {
+		rw.traffic.egress(rw.Name, msg.Code, len(msg.Payload))
+	}
+
 	msg.Code += rw.offset
 
 	return rw.rw.WriteMsg(msg)
@@ -302,6 +331,9 @@ type PeerInfo struct {
 	} `json:"network"`
 	Protocols map[string]interface{} `json:"protocols"` // Sub-protocol specific metadata fields
 	Shard     uint                   `json:"shard"`     // shard id of the node
+	Score     int                    `json:"score"`     // reputation score of the node, 0 is the best score
+
+	Traffic map[string]*ProtocolTraffic `json:"traffic"` // traffic of the protocols by message code
 }
 
 // Info returns data of the peer but not contain id and name.
@@ -331,6 +363,12 @@ func (p *Peer) Info() *PeerInfo {
 	}
 	info.Network.LocalAddress = p.LocalAddr().String()
 	info.Network.RemoteAddress = p.RemoteAddr().String()
+	if p.reputation != nil {
+		info.Score = p.reputation.score(p.Node.ID)
+	}
+	if p.traffic != nil {
+		info.Traffic = p.traffic.snapshot()
+	}
 
 	return info
 }
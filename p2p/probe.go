@@ -0,0 +1,93 @@
+/**
+*  @file
+*  @copyright defined in scdo/LICENSE
+ */
+
+package p2p
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"sort"
+	"time"
+
+	"github.com/elcn233/go-scdo/p2p/discovery"
+)
+
+// maxProbeSkippedMsgs limits the control messages skipped while reading a probe message
+const maxProbeSkippedMsgs = 16
+
+var errProbeNoProtocol = errors.New("no protocol negotiated with the node")
+
+// Probe dials the node and does the handshake without adding it as a peer. The fn
+// is called with the message channel of the first negotiated protocol if set, so
+// that the caller can read the protocol status of the node. It is used by the crawler.
+func (srv *Server) Probe(node *discovery.Node, timeout time.Duration, fn func(rw MsgReadWriter) error) (*ProtoHandShake, error) {
+	//TODO UDPPort==> TCPPort
+	fd, err := net.DialTimeout("tcp", fmt.Sprintf("%s:%d", node.IP.String(), node.UDPPort), timeout)
+	if err != nil {
+		return nil, err
+	}
+	defer fd.Close()
+
+	var caps []Cap
+	for _, proto := range srv.Protocols {
+		caps = append(caps, proto.cap())
+	}
+	sort.Sort(capsByNameAndVersion(caps))
+
+	peer := NewPeer(&connection{fd: fd, log: srv.log}, srv.log, node)
+	recvMsg, _, err := srv.doHandShake(caps, peer, outboundConn, node)
+	if err != nil {
+		return nil, err
+	}
+
+	if recvMsg == nil {
+		return nil, errors.New("recvMsg is nil")
+	}
+
+	if fn == nil {
+		return recvMsg, nil
+	}
+
+	if len(peer.protocolMap) == 0 {
+		return recvMsg, errProbeNoProtocol
+	}
+
+	return recvMsg, fn(&probeRW{conn: peer.rw, offset: baseProtoCode})
+}
+
+// probeRW is the message channel of the first negotiated protocol of the probe connection
+type probeRW struct {
+	conn   *connection
+	offset uint16
+}
+
+func (rw *probeRW) WriteMsg(msg *Message) error {
+	msg.Code += rw.offset
+	return rw.conn.WriteMsg(msg)
+}
+
+// ReadMsg reads the next protocol message, the ping is answered and the other
+// control messages are skipped. The closed connection is reported by the read error.
+func (rw *probeRW) ReadMsg() (*Message, error) {
+	for i := 0; i < maxProbeSkippedMsgs; i++ {
+		msg, err := rw.conn.ReadMsg()
+		if err != nil {
+			return nil, err
+		}
+
+		switch {
+		case msg.Code == ctlMsgPingCode:
+			if err = rw.conn.WriteMsg(&Message{Code: ctlMsgPongCode}); err != nil {
+				return nil, err
+			}
+		case msg.Code >= rw.offset:
+			msg.Code -= rw.offset
+			return msg, nil
+		}
+	}
+
+	return nil, errors.New("too many control messages")
+}
@@ -0,0 +1,151 @@
+/**
+*  @file
+*  @copyright defined in scdo/LICENSE
+ */
+
+// Package probe reads the chain head of the scdo nodes without running the scdo protocol,
+// so that tools like the crawler don't depend on the full node.
+package probe
+
+import (
+	"errors"
+	"math/big"
+	"math/rand"
+
+	"github.com/elcn233/go-scdo/common"
+	"github.com/elcn233/go-scdo/core/types"
+	"github.com/elcn233/go-scdo/p2p"
+)
+
+const (
+	// maxProbeMsgs limits the messages read while waiting for the probe response
+	maxProbeMsgs = 32
+
+	// the codes of the scdo protocol, see scdo/scdoprotocol.go and scdo/download/downloader.go
+	statusDataMsgCode      uint16 = 6
+	getBlockHeadersMsgCode uint16 = 8
+	blockHeadersMsgCode    uint16 = 9
+	protocolMsgCodeLength  uint16 = 14
+)
+
+var (
+	errHeaderNotFound = errors.New("head header not returned")
+	errMsgNotMatch    = errors.New("Message not match")
+)
+
+// statusData is the status exchanged in the scdo protocol handshake, it must be
+// encoded the same as the statusData of the scdo package.
+type statusData struct {
+	ProtocolVersion uint32
+	NetworkID       string
+	TD              *big.Int
+	CurrentBlock    common.Hash
+	GenesisBlock    common.Hash
+	Shard           uint
+	Difficult       uint64
+}
+
+// blockHeadersQuery is the block headers query of the scdo protocol, it must be
+// encoded the same as the blockHeadersQuery of the scdo package.
+type blockHeadersQuery struct {
+	Magic   uint32
+	Hash    common.Hash
+	Number  uint64
+	Amount  uint64
+	Reverse bool
+}
+
+// blockHeadersMsgBody is the block headers response of the scdo protocol, it must be
+// encoded the same as the BlockHeadersMsgBody of the download package.
+type blockHeadersMsgBody struct {
+	Magic   uint32
+	Headers []*types.BlockHeader
+}
+
+// ChainHead is the chain head of a remote node
+type ChainHead struct {
+	Shard  uint
+	TD     *big.Int
+	Hash   common.Hash
+	Height uint64
+}
+
+// Protocol returns the scdo protocol used to probe the nodes, the protocol
+// is only negotiated in the handshake and never runs on peers.
+func Protocol() p2p.Protocol {
+	return p2p.Protocol{
+		Name:    common.ScdoProtoName,
+		Version: common.ScdoVersion,
+		Length:  protocolMsgCodeLength,
+	}
+}
+
+// ReadChainHead reads the status of the node and requests the header of its head.
+// The status of the node is echoed back, so that the node accepts the probe without
+// the probe knowing the genesis of the shard. It is used by the crawler.
+func ReadChainHead(rw p2p.MsgReadWriter) (*ChainHead, error) {
+	msg, err := rw.ReadMsg()
+	if err != nil {
+		return nil, err
+	}
+
+	if msg.Code != statusDataMsgCode {
+		return nil, errMsgNotMatch
+	}
+
+	var status statusData
+	if err = common.Deserialize(msg.Payload, &status); err != nil {
+		return nil, err
+	}
+
+	echo := status
+	echo.TD = big.NewInt(0)
+	echo.CurrentBlock = status.GenesisBlock
+	if err = p2p.SendMessage(rw, statusDataMsgCode, common.SerializePanic(&echo)); err != nil {
+		return nil, err
+	}
+
+	head := &ChainHead{
+		Shard: status.Shard,
+		TD:    status.TD,
+		Hash:  status.CurrentBlock,
+	}
+
+	query := &blockHeadersQuery{
+		Magic:  rand.Uint32(),
+		Hash:   status.CurrentBlock,
+		Amount: 1,
+	}
+	if err = p2p.SendMessage(rw, getBlockHeadersMsgCode, common.SerializePanic(query)); err != nil {
+		return head, err
+	}
+
+	// the node may send the other messages, e.g. the new transactions, before the headers
+	for i := 0; i < maxProbeMsgs; i++ {
+		if msg, err = rw.ReadMsg(); err != nil {
+			return head, err
+		}
+
+		if msg.Code != blockHeadersMsgCode {
+			continue
+		}
+
+		var body blockHeadersMsgBody
+		if err = common.Deserialize(msg.Payload, &body); err != nil {
+			return head, err
+		}
+
+		if body.Magic != query.Magic {
+			continue
+		}
+
+		if len(body.Headers) == 0 {
+			return head, errHeaderNotFound
+		}
+
+		head.Height = body.Headers[0].Height
+		return head, nil
+	}
+
+	return head, errHeaderNotFound
+}
@@ -0,0 +1,73 @@
+/**
+*  @file
+*  @copyright defined in scdo/LICENSE
+ */
+
+package p2p
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/elcn233/go-scdo/core/types"
+	"github.com/elcn233/go-scdo/crypto"
+	"github.com/elcn233/go-scdo/p2p/discovery"
+	"github.com/stretchr/testify/assert"
+)
+
+func newProbeTestServer() *Server {
+	key, err := crypto.GenerateKey()
+	if err != nil {
+		panic(err)
+	}
+
+	var genesis types.GenesisInfo
+	config := Config{NetworkID: "scdo", PrivateKey: key}
+	srv := NewServer(genesis, config, []Protocol{{Name: "udp", Version: 1, Length: 1048}})
+	id := *crypto.PubkeyToAddress(srv.PrivateKey.PublicKey)
+	srv.SelfNode = discovery.NewNodeWithAddr(id, &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)}, 1)
+
+	return srv
+}
+
+func Test_Server_Probe(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.Equal(t, err, nil)
+	defer ln.Close()
+
+	remote := newProbeTestServer()
+	go func() {
+		fd, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer fd.Close()
+
+		peer := NewPeer(&connection{fd: fd, log: remote.log}, remote.log, nil)
+		if _, _, err = remote.doHandShake([]Cap{{"udp", 1}}, peer, inboundConn, nil); err != nil {
+			return
+		}
+
+		// ping first, the probe answers it and reads the protocol message
+		peer.rw.WriteMsg(&Message{Code: ctlMsgPingCode})
+		peer.rw.WriteMsg(&Message{Code: baseProtoCode + 6, Payload: []byte("status")})
+		peer.rw.ReadMsg()
+	}()
+
+	prober := newProbeTestServer()
+	port := ln.Addr().(*net.TCPAddr).Port
+	node := discovery.NewNodeWithAddr(remote.SelfNode.ID, &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1), Port: port}, 1)
+
+	var status *Message
+	hs, err := prober.Probe(node, time.Second, func(rw MsgReadWriter) (err error) {
+		status, err = rw.ReadMsg()
+		return err
+	})
+
+	assert.Equal(t, err, nil)
+	assert.Equal(t, hs.NodeID, remote.SelfNode.ID)
+	assert.Equal(t, hs.Caps, []Cap{{"udp", 1}})
+	assert.Equal(t, status.Code, uint16(6))
+	assert.Equal(t, status.Payload, []byte("status"))
+}
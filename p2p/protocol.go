@@ -28,6 +28,13 @@ type Protocol struct {
 	// Length should contain the number of message codes used by the protocol.
 	Length uint16
 
+	// ClientOnly indicates the node only requests the service of the peers with the protocol,
+	// e.g. light client. The protocol is not advertised in the node record.
+	ClientOnly bool
+
+	// RateLimits are the default rate limits of the expensive messages received from a peer
+	RateLimits []RateLimit
+
 	// AddPeer find a new peer will call this method
 	AddPeer func(peer *Peer, rw MsgReadWriter) bool
 
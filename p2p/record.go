@@ -0,0 +1,50 @@
+/**
+*  @file
+*  @copyright defined in scdo/LICENSE
+ */
+
+package p2p
+
+import (
+	"github.com/elcn233/go-scdo/p2p/discovery"
+)
+
+// recordCaps returns the protocols advertised in the node record
+func (srv *Server) recordCaps() []string {
+	caps := make([]string, 0, len(srv.Protocols))
+	for _, proto := range srv.Protocols {
+		if !proto.ClientOnly {
+			caps = append(caps, proto.cap().String())
+		}
+	}
+
+	return caps
+}
+
+// isRecordCompatible checks the latest record of the node, returns false if the node
+// is in another network or supports none of the local protocols. The node without
+// record is compatible as the record may not be received yet.
+func (srv *Server) isRecordCompatible(node *discovery.Node) bool {
+	record := node.Record
+	if srv.kadDB != nil {
+		if n, ok := srv.kadDB.FindByNodeID(node.ID); ok && n.Record != nil {
+			record = n.Record
+		}
+	}
+
+	if record == nil {
+		return true
+	}
+
+	if record.NetworkID != srv.NetworkID {
+		return false
+	}
+
+	for _, proto := range srv.Protocols {
+		if record.HasCap(proto.cap().String()) {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,297 @@
+/**
+*  @file
+*  @copyright defined in scdo/LICENSE
+ */
+
+package p2p
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/elcn233/go-scdo/common"
+	"github.com/elcn233/go-scdo/log"
+)
+
+// Penalties reported by the protocols when a peer misbehaves
+const (
+	PenaltyTimeout        = 5  // peer does not respond to a request in time
+	PenaltyInvalidMessage = 10 // peer sends a message that can not be decoded or is not allowed
+	PenaltyRateLimited    = 2  // peer sends messages faster than the rate limit of the code
+	PenaltyInvalidDebt    = 20 // peer sends a debt that fails validation
+	PenaltyInvalidBlock   = 50 // peer sends a block that fails validation
+)
+
+const (
+	// banListBackupFile is the file in the node directory the ban list is saved to
+	banListBackupFile = "banList.json"
+
+	// banScoreThreshold peers are disconnected and banned when their score drops to it
+	banScoreThreshold = -100
+
+	// scoreRecoveryPerMinute is the number of points a peer score recovers per minute
+	scoreRecoveryPerMinute = 2
+
+	// defaultBanDuration is the ban duration for peers banned due to low score
+	defaultBanDuration = 60 * time.Minute
+)
+
+var errPeerBanned = errors.New("peer is banned")
+
+// BanInfo is the ban record of a node
+type BanInfo struct {
+	ID      common.Address `json:"id"`
+	Reason  string         `json:"reason"`
+	Expires int64          `json:"expires"` // unix timestamp when the ban is lifted
+}
+
+type peerScore struct {
+	score     int
+	updated   time.Time // the time the score recovered up to
+	connected bool
+}
+
+// reputation keeps the scores of the peers and the ban list
+type reputation struct {
+	lock   sync.Mutex
+	scores map[common.Address]*peerScore
+	bans   map[common.Address]*BanInfo
+
+	// path of the ban list backup file, ban list is not saved if it is empty
+	path string
+	log  *log.ScdoLog
+}
+
+func newReputation(log *log.ScdoLog) *reputation {
+	return &reputation{
+		scores: make(map[common.Address]*peerScore),
+		bans:   make(map[common.Address]*BanInfo),
+		log:    log,
+	}
+}
+
+// load loads the ban list from the node directory, later changes are saved to the same file
+func (r *reputation) load(nodeDir string) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	r.path = filepath.Join(nodeDir, banListBackupFile)
+	if !common.FileOrFolderExists(r.path) {
+		r.log.Debug("ban list backup file doesn't exist in the path:%s", r.path)
+		return
+	}
+
+	data, err := ioutil.ReadFile(r.path)
+	if err != nil {
+		r.log.Error("failed to read ban list backup file for:[%s]", err)
+		return
+	}
+
+	var bans []*BanInfo
+	if err = json.Unmarshal(data, &bans); err != nil {
+		r.log.Error("failed to unmarshal ban list for:[%s]", err)
+		return
+	}
+
+	now := time.Now().Unix()
+	for _, ban := range bans {
+		if ban.Expires > now {
+			r.bans[ban.ID] = ban
+		}
+	}
+
+	r.log.Debug("load %d banned nodes from back file", len(r.bans))
+}
+
+// save writes the ban list to the backup file, must be called with the lock held
+func (r *reputation) save() {
+	if len(r.path) == 0 {
+		return
+	}
+
+	data, err := json.MarshalIndent(r.sortedBans(), "", "\t")
+	if err != nil {
+		r.log.Error("json marshal occur error, for:[%s]", err)
+		return
+	}
+
+	if err = os.MkdirAll(filepath.Dir(r.path), os.ModePerm); err != nil {
+		r.log.Error("filePath:[%s], failed to create folder, for:[%s]", r.path, err)
+		return
+	}
+
+	if err = ioutil.WriteFile(r.path, data, 0666); err != nil {
+		r.log.Error("ban list backup failed, for:[%s]", err)
+	}
+}
+
+// current returns the score of the node after recovery, must be called with the lock held
+func (r *reputation) current(id common.Address, now time.Time) *peerScore {
+	s, ok := r.scores[id]
+	if !ok {
+		s = &peerScore{updated: now}
+		r.scores[id] = s
+		return s
+	}
+
+	// the partial minute is carried forward to the next recovery
+	if minutes := now.Sub(s.updated) / time.Minute; minutes > 0 {
+		s.score += int(minutes) * scoreRecoveryPerMinute
+		s.updated = s.updated.Add(minutes * time.Minute)
+		if s.score >= 0 {
+			s.score = 0
+			s.updated = now
+		}
+	}
+
+	return s
+}
+
+// score returns the current score of the node
+func (r *reputation) score(id common.Address) int {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	if _, ok := r.scores[id]; !ok {
+		return 0
+	}
+
+	return r.current(id, time.Now()).score
+}
+
+// connect marks the node connected, whose score is kept until disconnected and recovered
+func (r *reputation) connect(id common.Address) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	r.current(id, time.Now()).connected = true
+}
+
+// disconnect marks the node disconnected, and evicts the scores of the disconnected
+// nodes which have recovered to neutral. The low scores are kept so that the nodes
+// can not reset them by reconnecting.
+func (r *reputation) disconnect(id common.Address) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	if s, ok := r.scores[id]; ok {
+		s.connected = false
+	}
+
+	now := time.Now()
+	for id, s := range r.scores {
+		if !s.connected && r.current(id, now).score == 0 {
+			delete(r.scores, id)
+		}
+	}
+}
+
+// report lowers the score of the node with the penalty. It returns true
+// if the score dropped to the threshold and the node is banned.
+func (r *reputation) report(id common.Address, penalty int, reason string) bool {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	s := r.current(id, time.Now())
+	s.score -= penalty
+	r.log.Debug("node %s misbehaved: %s, penalty %d, score %d", id.Hex(), reason, penalty, s.score)
+
+	if s.score > banScoreThreshold {
+		return false
+	}
+
+	r.log.Warn("node %s is banned for low score %d, last reason: %s", id.Hex(), s.score, reason)
+	r.banLocked(id, reason, defaultBanDuration)
+	return true
+}
+
+// ban bans the node for the duration
+func (r *reputation) ban(id common.Address, reason string, duration time.Duration) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	r.banLocked(id, reason, duration)
+}
+
+func (r *reputation) banLocked(id common.Address, reason string, duration time.Duration) {
+	r.bans[id] = &BanInfo{
+		ID:      id,
+		Reason:  reason,
+		Expires: time.Now().Add(duration).Unix(),
+	}
+
+	// the node starts from a clean score once the ban is lifted
+	delete(r.scores, id)
+	r.save()
+}
+
+// unban lifts the ban of the node, returns false if the node is not banned
+func (r *reputation) unban(id common.Address) bool {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	if _, ok := r.bans[id]; !ok {
+		return false
+	}
+
+	delete(r.bans, id)
+	r.save()
+	return true
+}
+
+// isBanned returns whether the node is banned, expired bans are removed
+func (r *reputation) isBanned(id common.Address) bool {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	ban, ok := r.bans[id]
+	if !ok {
+		return false
+	}
+
+	if ban.Expires <= time.Now().Unix() {
+		delete(r.bans, id)
+		r.save()
+		return false
+	}
+
+	return true
+}
+
+// list returns the active bans ordered by expiry
+func (r *reputation) list() []BanInfo {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	now := time.Now().Unix()
+	bans := make([]BanInfo, 0, len(r.bans))
+	for _, ban := range r.sortedBans() {
+		if ban.Expires > now {
+			bans = append(bans, *ban)
+		}
+	}
+
+	return bans
+}
+
+func (r *reputation) sortedBans() []*BanInfo {
+	bans := make([]*BanInfo, 0, len(r.bans))
+	for _, ban := range r.bans {
+		bans = append(bans, ban)
+	}
+
+	sort.Slice(bans, func(i, j int) bool {
+		if bans[i].Expires == bans[j].Expires {
+			return bans[i].ID.Hex() < bans[j].ID.Hex()
+		}
+		return bans[i].Expires < bans[j].Expires
+	})
+
+	return bans
+}
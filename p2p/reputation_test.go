@@ -0,0 +1,114 @@
+/**
+*  @file
+*  @copyright defined in scdo/LICENSE
+ */
+
+package p2p
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/elcn233/go-scdo/crypto"
+	"github.com/elcn233/go-scdo/log"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_Reputation_ReportAndBan(t *testing.T) {
+	r := newReputation(log.GetLogger("p2p"))
+	id := *crypto.MustGenerateShardAddress(1)
+
+	assert.Equal(t, r.report(id, PenaltyInvalidBlock, "invalid block"), false)
+	assert.Equal(t, r.score(id), -PenaltyInvalidBlock)
+	assert.Equal(t, r.isBanned(id), false)
+
+	assert.Equal(t, r.report(id, PenaltyInvalidBlock, "invalid block"), true)
+	assert.Equal(t, r.isBanned(id), true)
+	assert.Equal(t, len(r.list()), 1)
+	assert.Equal(t, r.list()[0].Reason, "invalid block")
+
+	// score is reset once banned
+	assert.Equal(t, r.score(id), 0)
+
+	assert.Equal(t, r.unban(id), true)
+	assert.Equal(t, r.unban(id), false)
+	assert.Equal(t, r.isBanned(id), false)
+}
+
+func Test_Reputation_ScoreRecovery(t *testing.T) {
+	r := newReputation(log.GetLogger("p2p"))
+	id := *crypto.MustGenerateShardAddress(1)
+
+	r.report(id, PenaltyInvalidMessage, "invalid msg")
+	r.scores[id].updated = time.Now().Add(-2 * time.Minute)
+	assert.Equal(t, r.score(id), -PenaltyInvalidMessage+2*scoreRecoveryPerMinute)
+
+	// score never recovers above 0
+	r.scores[id].updated = time.Now().Add(-time.Hour)
+	assert.Equal(t, r.score(id), 0)
+
+	// the partial minute is carried forward
+	r.report(id, PenaltyInvalidMessage, "invalid msg")
+	r.scores[id].updated = time.Now().Add(-90 * time.Second)
+	assert.Equal(t, r.score(id), -PenaltyInvalidMessage+scoreRecoveryPerMinute)
+	r.scores[id].updated = r.scores[id].updated.Add(-30 * time.Second)
+	assert.Equal(t, r.score(id), -PenaltyInvalidMessage+2*scoreRecoveryPerMinute)
+}
+
+func Test_Reputation_Eviction(t *testing.T) {
+	r := newReputation(log.GetLogger("p2p"))
+	good := *crypto.MustGenerateShardAddress(1)
+	bad := *crypto.MustGenerateShardAddress(1)
+
+	r.connect(good)
+	r.connect(bad)
+	r.report(bad, PenaltyInvalidMessage, "invalid msg")
+	assert.Equal(t, len(r.scores), 2)
+
+	// the neutral score is evicted once disconnected, and the low one is kept
+	r.disconnect(good)
+	r.disconnect(bad)
+	assert.Equal(t, len(r.scores), 1)
+	assert.Equal(t, r.score(bad), -PenaltyInvalidMessage)
+
+	// evicted once recovered
+	r.scores[bad].updated = time.Now().Add(-time.Hour)
+	r.disconnect(good)
+	assert.Equal(t, len(r.scores), 0)
+	assert.Equal(t, r.score(bad), 0)
+}
+
+func Test_Reputation_BanExpiry(t *testing.T) {
+	r := newReputation(log.GetLogger("p2p"))
+	id := *crypto.MustGenerateShardAddress(1)
+
+	r.ban(id, "expired", -time.Minute)
+	assert.Equal(t, r.isBanned(id), false)
+	assert.Equal(t, len(r.list()), 0)
+}
+
+func Test_Reputation_Persistence(t *testing.T) {
+	dir, err := ioutil.TempDir("", "reputation")
+	assert.Equal(t, err, nil)
+	defer os.RemoveAll(dir)
+
+	id1 := *crypto.MustGenerateShardAddress(1)
+	id2 := *crypto.MustGenerateShardAddress(1)
+
+	r := newReputation(log.GetLogger("p2p"))
+	r.load(dir)
+	r.ban(id1, "first", time.Hour)
+	r.ban(id2, "second", 2*time.Hour)
+
+	loaded := newReputation(log.GetLogger("p2p"))
+	loaded.load(dir)
+	assert.Equal(t, loaded.isBanned(id1), true)
+	assert.Equal(t, loaded.isBanned(id2), true)
+
+	bans := loaded.list()
+	assert.Equal(t, len(bans), 2)
+	assert.Equal(t, bans[0].ID, id1)
+	assert.Equal(t, bans[1].ID, id2)
+}
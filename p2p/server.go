@@ -8,8 +8,8 @@ package p2p
 import (
 	"bytes"
 	"crypto/ecdsa"
-	"crypto/md5"
 	"crypto/rand"
+	"crypto/sha256"
 	"encoding/binary"
 	"errors"
 	"fmt"
@@ -18,13 +18,16 @@ import (
 	"sort"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/elcn233/go-scdo/common"
-	"github.com/elcn233/go-scdo/core"
+	"github.com/elcn233/go-scdo/core/types"
 	"github.com/elcn233/go-scdo/crypto"
 	"github.com/elcn233/go-scdo/log"
 	"github.com/elcn233/go-scdo/p2p/discovery"
+	"github.com/elcn233/go-scdo/p2p/dnsdisc"
+	"github.com/elcn233/go-scdo/p2p/nat"
 	"github.com/sirupsen/logrus"
 	set "gopkg.in/fatih/set.v0"
 )
@@ -50,7 +53,7 @@ const (
 	outboundConn          = 2
 	//minLocalShardConn     = 13
 	// In transferring handshake msg, length of extra data
-	extraDataLen = 24
+	extraDataLen = 40
 
 	// Minimum recommended number of peers of one shard
 	minNumOfPeerPerShard = uint(2)
@@ -76,6 +79,15 @@ type Config struct {
 
 	// PrivateKey private key for p2p module, do not use it as any accounts
 	PrivateKey *ecdsa.PrivateKey `json:"-"`
+
+	// NAT is the port mapping mechanism, one of none, any, upnp, pmp, pmp:<gateway ip> or extip:<ip>
+	NAT string `json:"nat"`
+
+	// DNSDiscovery is the urls of the node trees in DNS, scdotree://<signer address>@<domain>
+	DNSDiscovery []string `json:"dnsDiscovery"`
+
+	// RateLimits overrides the rate limits of the protocols by message code, the key is the protocol name
+	RateLimits map[string][]RateLimit `json:"rateLimits"`
 }
 
 // Server manages all p2p peer connections.
@@ -109,24 +121,34 @@ type Server struct {
 
 	SelfNode *discovery.Node
 
-	genesis core.GenesisInfo
+	genesis types.GenesisInfo
 
 	// genesisHash is used for handshake
 	genesisHash common.Hash
 
 	// maxConnections represents max connections that node can connect to.
-	// Reject connections if srv.PeerCount > maxConnections.
-	maxConnections int
+	// Reject connections if srv.PeerCount > maxConnections. It is changed at
+	// runtime, so always accessed atomically.
+	maxConnections int32
+
+	// inboundSlots are the handshake slots of inbound connections
+	inboundSlots *connSlots
 
 	// maxActiveConnections represents max connections that node can actively connect to.
 	// Need not connect to a new node if srv.PeerCount > maxActiveConnections.
 	maxActiveConnections int
 
 	peerNumLock sync.Mutex // lock for num of peers per shard
+
+	// reputation keeps the peer scores and the ban list
+	reputation *reputation
+
+	// trustedPeers are the peers added by the admin at runtime
+	trustedPeers *trustedPeers
 }
 
 // NewServer initialize a server
-func NewServer(genesis core.GenesisInfo, config Config, protocols []Protocol) *Server {
+func NewServer(genesis types.GenesisInfo, config Config, protocols []Protocol) *Server {
 	// add genesisHash with shard set to 0 to calculate hash
 	shard := genesis.ShardNumber
 	genesis.ShardNumber = 0
@@ -142,6 +164,16 @@ func NewServer(genesis core.GenesisInfo, config Config, protocols []Protocol) *S
 	genesis.Masteraccount = masteraccount
 	genesis.Balance = balance
 
+	// apply the configured rate limits on a copy, the protocols of the caller are not changed
+	if len(config.RateLimits) > 0 {
+		protocols = append([]Protocol(nil), protocols...)
+		for i := range protocols {
+			if overrides, ok := config.RateLimits[protocols[i].Name]; ok {
+				protocols[i].RateLimits = mergeRateLimits(protocols[i].RateLimits, overrides)
+			}
+		}
+	}
+
 	return &Server{
 		Config:               config,
 		running:              false,
@@ -154,7 +186,10 @@ func NewServer(genesis core.GenesisInfo, config Config, protocols []Protocol) *S
 		genesis:              genesis,
 		genesisHash:          hash,
 		maxConnections:       maxConnsPerShard * common.ShardCount,
+		inboundSlots:         newConnSlots(maxConnsPerShard * common.ShardCount),
 		maxActiveConnections: maxActiveConnsPerShard * common.ShardCount,
+		reputation:           newReputation(log.GetLogger("p2p")),
+		trustedPeers:         newTrustedPeers(log.GetLogger("p2p")),
 	}
 }
 
@@ -180,13 +215,26 @@ func (srv *Server) Start(nodeDir string, shard uint) (err error) {
 		return err
 	}
 
+	natm, err := nat.Parse(srv.NAT)
+	if err != nil {
+		return err
+	}
+
 	srv.log.Debug("Starting P2P network...")
 	srv.SelfNode = discovery.NewNodeWithAddr(*address, addr, shard)
 
 	srv.log.Info("Starting P2P Server, MyNodeID [%s]", srv.SelfNode)
+	srv.reputation.load(nodeDir)
 	srv.kadDB, srv.udp = discovery.StartService(nodeDir, *address, addr, srv.Config.StaticNodes, shard)
 	srv.kadDB.SetHookForNewNode(srv.addNode)
 	srv.kadDB.SetHookForDeleteNode(srv.deleteNode)
+	if err := srv.udp.SetLocalRecord(srv.PrivateKey, srv.NetworkID, srv.recordCaps()); err != nil {
+		return err
+	}
+	// seed the discovery database with the node records published in DNS
+	if len(srv.DNSDiscovery) > 0 {
+		go srv.udp.SeedRecords(dnsdisc.NewClient(nil).Records(srv.DNSDiscovery))
+	}
 	// add static nodes to srv node set;
 	for _, node := range srv.Config.StaticNodes {
 		if err := node.ID.Validate(); !node.ID.IsEmpty() && err != nil {
@@ -194,6 +242,12 @@ func (srv *Server) Start(nodeDir string, shard uint) (err error) {
 		}
 
 	}
+	// add trusted peers to srv node set, they are dialed in the run loop
+	srv.trustedPeers.load(nodeDir)
+	for _, node := range srv.trustedPeers.list() {
+		srv.addTrustNode(node)
+		srv.nodeSet.tryAdd(node)
+	}
 	if err := srv.startListening(); err != nil {
 		return err
 	}
@@ -202,6 +256,11 @@ func (srv *Server) Start(nodeDir string, shard uint) (err error) {
 	go srv.run()
 	srv.running = true
 
+	if natm != nil {
+		srv.loopWG.Add(1)
+		go srv.startNAT(natm, addr)
+	}
+
 	// just in debug mode
 	if srv.log.GetLevel() >= logrus.DebugLevel {
 		go srv.printPeers()
@@ -250,6 +309,11 @@ func (srv *Server) addNode(node *discovery.Node) {
 	//}
 	//}
 
+	// the node with a newer record may change its endpoint
+	if node.Record != nil {
+		srv.nodeSet.update(node)
+	}
+
 	//only connect a node when certain condition met otherwise just add to nodeset
 	srv.nodeSet.tryAdd(node)
 
@@ -267,6 +331,16 @@ func (srv *Server) connectNode(node *discovery.Node) {
 		return
 	}
 
+	if srv.reputation.isBanned(node.ID) {
+		srv.log.Debug("skip connecting to banned node %s", node)
+		return
+	}
+
+	if !srv.isRecordCompatible(node) {
+		srv.log.Debug("skip connecting to incompatible node %s", node)
+		return
+	}
+
 	//TODO UDPPort==> TCPPort
 	addr, err := net.ResolveTCPAddr("tcp4", fmt.Sprintf("%s:%d", node.IP.String(), node.UDPPort))
 	if err != nil {
@@ -323,6 +397,7 @@ func (srv *Server) addPeer(p *Peer) (bool, bool) { //bool, bool: addPeer isAdd,
 
 	srv.peerSet.add(p)
 	srv.nodeSet.setNodeStatus(p.Node, true)
+	srv.reputation.connect(p.Node.ID)
 
 	srv.log.Debug("add peer to server, len(peers)=%d. peer %s", srv.PeerCount(), p.Node)
 	go p.notifyProtocolsAddPeer()
@@ -343,6 +418,7 @@ func (srv *Server) deletePeer(id common.Address) {
 
 		srv.nodeSet.setNodeStatus(p.Node, false)
 		srv.peerSet.delete(p)
+		srv.reputation.disconnect(id)
 		p.notifyProtocolsDeletePeer()
 		srv.log.Debug("server.run delPeerChan received. peer match. remove peer. peers num=%d", srv.PeerCount())
 
@@ -375,6 +451,7 @@ runloop:
 		case <-ticker.C:
 			//srv.log.Error("connect loop")
 			go srv.doSelectNodeToConnect()
+			go srv.connectTrustedPeers()
 			if srv.nodeSet.ifNeedAddNodes(common.LocalShardNumber) {
 				continue
 			} else {
@@ -480,18 +557,14 @@ func (srv *Server) Wait() {
 func (srv *Server) listenLoop() {
 	defer srv.loopWG.Done()
 	// If all slots are taken, no further connections are accepted.
-	tokens := srv.maxConnections
 	if srv.MaxPendingPeers > 0 {
-		tokens = srv.MaxPendingPeers
-	}
-	slots := make(chan struct{}, tokens)
-	for i := 0; i < tokens; i++ {
-		slots <- struct{}{}
+		srv.inboundSlots.setLimit(srv.MaxPendingPeers)
 	}
+	slots := srv.inboundSlots
 
 	for {
 		// Wait for a handshake slot before accepting.
-		<-slots
+		slots.acquire()
 		var (
 			fd  net.Conn
 			err error
@@ -519,16 +592,58 @@ func (srv *Server) listenLoop() {
 				return
 			}
 
-			slots <- struct{}{}
+			slots.release()
 		}()
 	}
 }
 
+// connSlots is the pool of handshake slots, whose size could be changed at runtime.
+type connSlots struct {
+	lock  sync.Mutex
+	cond  *sync.Cond
+	used  int
+	limit int
+}
+
+func newConnSlots(limit int) *connSlots {
+	slots := &connSlots{limit: limit}
+	slots.cond = sync.NewCond(&slots.lock)
+	return slots
+}
+
+// acquire waits until a slot is available and takes it
+func (s *connSlots) acquire() {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	for s.used >= s.limit {
+		s.cond.Wait()
+	}
+	s.used++
+}
+
+func (s *connSlots) release() {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.used--
+	s.cond.Signal()
+}
+
+// setLimit resizes the pool, the slots taken are kept if it shrinks
+func (s *connSlots) setLimit(limit int) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.limit = limit
+	s.cond.Broadcast()
+}
+
 // setupConn Confirm both side are valid peers, have sub-protocols supported by each other
 // Assume the inbound side is server side; outbound side is client side.
 func (srv *Server) setupConn(fd net.Conn, flags int, dialDest *discovery.Node) (err error) {
 
-	if flags == inboundConn && srv.PeerCount() > srv.maxConnections {
+	if flags == inboundConn && srv.PeerCount() > srv.MaxConnections() {
 		srv.log.Warn("setup connection with peer %s. reached max incoming connection limit, reject!", dialDest)
 		return errors.New("too many incoming connections")
 	}
@@ -540,6 +655,7 @@ func (srv *Server) setupConn(fd net.Conn, flags int, dialDest *discovery.Node) (
 
 	srv.log.Debug("setup connection with peer %s", dialDest)
 	peer := NewPeer(&connection{fd: fd, log: srv.log}, srv.log, dialDest)
+	peer.reputation = srv.reputation
 
 	var caps []Cap
 
@@ -563,6 +679,11 @@ func (srv *Server) setupConn(fd net.Conn, flags int, dialDest *discovery.Node) (
 	}
 	srv.log.Debug("handshake succeed. %s -> %s", fd.LocalAddr(), fd.RemoteAddr())
 	peerNodeID := recvMsg.NodeID
+	if srv.reputation.isBanned(peerNodeID) {
+		srv.log.Debug("reject banned node %s, %s", peerNodeID.Hex(), fd.RemoteAddr())
+		peer.close()
+		return errPeerBanned
+	}
 	if flags == inboundConn {
 		peerNode, ok := srv.kadDB.FindByNodeID(peerNodeID)
 
@@ -610,8 +731,18 @@ func (srv *Server) setupConn(fd net.Conn, flags int, dialDest *discovery.Node) (
 	return nil
 }
 
+// MaxConnections returns the max connections that node can connect to.
+func (srv *Server) MaxConnections() int {
+	return int(atomic.LoadInt32(&srv.maxConnections))
+}
+
+// SetMaxConnections changes the max connections, the handshake slots of inbound
+// connections are resized too unless limited by MaxPendingPeers.
 func (srv *Server) SetMaxConnections(maxConns int) {
-	srv.maxConnections = maxConns
+	atomic.StoreInt32(&srv.maxConnections, int32(maxConns))
+	if srv.MaxPendingPeers <= 0 {
+		srv.inboundSlots.setLimit(maxConns)
+	}
 }
 
 func (srv *Server) SetMaxActiveConnections(maxActiveConns int) {
@@ -632,7 +763,7 @@ func (srv *Server) peerIsValidate(recvMsg *ProtoHandShake) ([]Cap, bool) {
 		return nil, false
 	}
 
-	localCapSet := set.New()
+	localCapSet := set.New(set.ThreadSafe)
 	for _, proto := range srv.Protocols {
 		localCapSet.Add(proto.cap())
 	}
@@ -667,9 +798,17 @@ func (srv *Server) getProtocolsByCaps(capList []Cap) (proList []Protocol) {
 // doHandShake Communicate each other
 func (srv *Server) doHandShake(caps []Cap, peer *Peer, flags int, dialDest *discovery.Node) (recvMsg *ProtoHandShake, nounceCnt uint64, err error) {
 	var renounceCnt uint64
+	ephemeralKey, err := crypto.GenerateKey()
+	if err != nil {
+		return nil, 0, err
+	}
+
 	handshakeMsg := &ProtoHandShake{Caps: caps}
 	handshakeMsg.NetworkID = srv.Config.NetworkID
 	handshakeMsg.Params = srv.genesisHash.Bytes()
+	handshakeMsg.Version = transportVersion
+	handshakeMsg.EphemeralKey = crypto.FromECDSAPub(&ephemeralKey.PublicKey)
+	handshakeMsg.Compressions = supportedCompressions
 	nodeID := srv.SelfNode.ID
 	copy(handshakeMsg.NodeID[0:], nodeID[0:])
 	if flags == outboundConn {
@@ -738,11 +877,21 @@ func (srv *Server) doHandShake(caps []Cap, peer *Peer, flags int, dialDest *disc
 			return nil, 0, err
 		}
 	}
+
+	// the handshake is authenticated by the signatures, switch to encrypted frames
+	sess, err := newSession(ephemeralKey, recvMsg.EphemeralKey, flags == outboundConn)
+	if err != nil {
+		return nil, 0, err
+	}
+	peer.rw.session = sess
+	peer.rw.compressor = negotiateCompression(supportedCompressions, recvMsg.Compressions, flags == outboundConn)
+	srv.log.Debug("handshake with %s, payload compression %s", peer.rw.fd.RemoteAddr(), compressionName(peer.rw.compressor))
+
 	return
 }
 
 // packWrapHSMsg compose the wrapped send msg.
-// A 32 byte ExtraData is used for verification process.
+// A 40 byte ExtraData is used for verification process.
 func (srv *Server) packWrapHSMsg(handshakeMsg *ProtoHandShake, peerNodeID []byte, nounceCnt uint64) (*Message, error) {
 	// Serialize should handle big-endian
 	hdmsgRLP, err := common.Serialize(handshakeMsg)
@@ -753,16 +902,13 @@ func (srv *Server) packWrapHSMsg(handshakeMsg *ProtoHandShake, peerNodeID []byte
 	wrapMsg := Message{
 		Code: ctlMsgProtoHandshake,
 	}
-	md5Inst := md5.New()
-	if _, err := md5Inst.Write(hdmsgRLP); err != nil {
-		return &Message{}, err
-	}
+	digest := sha256.Sum256(hdmsgRLP)
 	extBuf := make([]byte, extraDataLen)
 
-	// first 16 bytes, contains md5sum of hdmsgRLP;
+	// first 32 bytes, contains sha256 digest of hdmsgRLP, it covers the ephemeral key;
 	// then 8 bytes for client side nounce;
-	copy(extBuf, md5Inst.Sum(nil))
-	binary.BigEndian.PutUint64(extBuf[16:], nounceCnt)
+	copy(extBuf, digest[:])
+	binary.BigEndian.PutUint64(extBuf[sha256.Size:], nounceCnt)
 
 	// Sign with local privateKey first
 	signature := crypto.MustSign(srv.PrivateKey, crypto.MustHash(extBuf).Bytes())
@@ -796,12 +942,19 @@ func (srv *Server) unPackWrapHSMsg(recvWrapMsg *Message) (recvMsg *ProtoHandShak
 
 	extraEncLen := binary.BigEndian.Uint32(recvWrapMsg.Payload[size-4:])
 	recvHSMsgLen := size - extraEncLen - 4
-	nounceCnt = binary.BigEndian.Uint64(recvWrapMsg.Payload[recvHSMsgLen+16:])
+	nounceCnt = binary.BigEndian.Uint64(recvWrapMsg.Payload[recvHSMsgLen+sha256.Size:])
 	recvEnc := recvWrapMsg.Payload[recvHSMsgLen : size-4]
 	recvMsg = &ProtoHandShake{}
 	if err = common.Deserialize(recvWrapMsg.Payload[:recvHSMsgLen], recvMsg); err != nil {
 		return
 	}
+
+	// negotiate the transport version first, the extra data of old versions has different layout
+	if recvMsg.Version != transportVersion {
+		err = fmt.Errorf("%w, local %d, remote %d", errTransportVersion, transportVersion, recvMsg.Version)
+		recvMsg, nounceCnt = nil, 0
+		return
+	}
 	// verify signature
 	sig := crypto.Signature{
 		Sig: recvEnc[extraDataLen:],
@@ -812,14 +965,10 @@ func (srv *Server) unPackWrapHSMsg(recvWrapMsg *Message) (recvMsg *ProtoHandShak
 		return
 	}
 
-	// verify recvMsg's payload md5sum to prevent modification
-	md5Inst := md5.New()
-	if _, err = md5Inst.Write(recvWrapMsg.Payload[:recvHSMsgLen]); err != nil {
-		return
-	}
-
-	if !bytes.Equal(md5Inst.Sum(nil), recvEnc[:16]) {
-		err = errors.New("unPackWrapHSMsg: received md5sum not match")
+	// verify recvMsg's payload digest to prevent modification
+	digest := sha256.Sum256(recvWrapMsg.Payload[:recvHSMsgLen])
+	if !bytes.Equal(digest[:], recvEnc[:sha256.Size]) {
+		err = errors.New("unPackWrapHSMsg: received digest not match")
 		return
 	}
 
@@ -869,6 +1018,34 @@ func (srv *Server) PeersInfo() []PeerInfo {
 	return infos
 }
 
+// BanPeer bans the node for the duration and disconnects it if connected
+func (srv *Server) BanPeer(id common.Address, duration time.Duration, reason string) {
+	srv.reputation.ban(id, reason, duration)
+
+	srv.peerLock.Lock()
+	p := srv.peerSet.find(id)
+	srv.peerLock.Unlock()
+
+	if p != nil {
+		go p.Disconnect(fmt.Sprintf("banned, %s", reason))
+	}
+}
+
+// UnbanPeer lifts the ban of the node, returns false if the node is not banned
+func (srv *Server) UnbanPeer(id common.Address) bool {
+	return srv.reputation.unban(id)
+}
+
+// Bans returns the active bans
+func (srv *Server) Bans() []BanInfo {
+	return srv.reputation.list()
+}
+
+// PeerScore returns the reputation score of the node, 0 is the best score
+func (srv *Server) PeerScore(id common.Address) int {
+	return srv.reputation.score(id)
+}
+
 // IsListening return whether the node is listen or not
 func (srv *Server) IsListening() bool {
 	return srv.listener != nil
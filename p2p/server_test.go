@@ -7,18 +7,19 @@ package p2p
 
 import (
 	"crypto/ecdsa"
+	"errors"
 	"strings"
 	"testing"
 
 	"github.com/elcn233/go-scdo/common"
-	"github.com/elcn233/go-scdo/core"
+	"github.com/elcn233/go-scdo/core/types"
 	"github.com/elcn233/go-scdo/crypto"
 	"github.com/elcn233/go-scdo/p2p/discovery"
 	"github.com/stretchr/testify/assert"
 )
 
 func Test_NewServer(t *testing.T) {
-	var genesis core.GenesisInfo
+	var genesis types.GenesisInfo
 	config := testConfig()
 	server := NewServer(genesis, *config, nil)
 
@@ -35,7 +36,7 @@ func Test_NewServer(t *testing.T) {
 }
 
 func Test_Start(t *testing.T) {
-	var genesis core.GenesisInfo
+	var genesis types.GenesisInfo
 	config := testConfig()
 	server := NewServer(genesis, *config, nil)
 
@@ -63,7 +64,7 @@ func Test_Start(t *testing.T) {
 }
 
 func Test_addNode(t *testing.T) {
-	var genesis core.GenesisInfo
+	var genesis types.GenesisInfo
 	config := testConfig()
 	server := NewServer(genesis, *config, nil)
 
@@ -78,7 +79,7 @@ func Test_addNode(t *testing.T) {
 }
 
 func Test_deleteNode(t *testing.T) {
-	var genesis core.GenesisInfo
+	var genesis types.GenesisInfo
 	config := testConfig()
 	server := NewServer(genesis, *config, nil)
 	assert.Equal(t, server.PeerCount(), 0)
@@ -107,7 +108,7 @@ func Test_deleteNode(t *testing.T) {
 }
 
 func Test_peerIsValidate(t *testing.T) {
-	var genesis core.GenesisInfo
+	var genesis types.GenesisInfo
 	config := testConfig()
 	server := NewServer(genesis, *config, testProtocol())
 	assert.Equal(t, server.PeerCount(), 0)
@@ -129,6 +130,15 @@ func Test_peerIsValidate(t *testing.T) {
 	message, err = server.packWrapHSMsg(handshakeMsg, node.ID[0:], outboundConn)
 	assert.Equal(t, err, nil)
 
+	// old transport version is rejected before verifying the extra data
+	recvMsg, renounceCnt, err = server.unPackWrapHSMsg(message)
+	assert.Equal(t, errors.Is(err, errTransportVersion), true)
+	assert.Equal(t, recvMsg == nil, true)
+
+	handshakeMsg.Version = transportVersion
+	message, err = server.packWrapHSMsg(handshakeMsg, node.ID[0:], outboundConn)
+	assert.Equal(t, err, nil)
+
 	recvMsg, renounceCnt, err = server.unPackWrapHSMsg(message)
 	assert.Equal(t, strings.Contains(err.Error(), " received public key not match"), true)
 }
@@ -141,7 +151,7 @@ func Test_PeerInfos(t *testing.T) {
 	peerInfos.Swap(0, 1)
 	assert.Equal(t, peerInfos.Less(0, 1), false)
 
-	var genesis core.GenesisInfo
+	var genesis types.GenesisInfo
 	config := testConfig()
 	server := NewServer(genesis, *config, testProtocol())
 
@@ -201,7 +211,7 @@ func testPeerInfos() PeerInfos {
 }
 
 func generatePrivKey() *ecdsa.PrivateKey {
-	_, keypair, err := crypto.GenerateKeyPair()
+	_, keypair, err := crypto.GenerateKeyPair(1)
 	if err != nil {
 		panic(err)
 	}
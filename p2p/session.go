@@ -0,0 +1,155 @@
+/**
+*  @file
+*  @copyright defined in scdo/LICENSE
+ */
+
+package p2p
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ecdsa"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+
+	"github.com/elcn233/go-scdo/crypto"
+	"github.com/elcn233/go-scdo/crypto/ecies"
+)
+
+// transportVersion is the version of the p2p transport. Peers announcing a
+// different version in the handshake are rejected. Version 1 introduces the
+// ephemeral key exchange and the encrypted frames.
+const transportVersion uint = 1
+
+const (
+	// length of the shared secret derived from the ephemeral keys
+	sessionSecretLen = 32
+
+	// length of the implicit frame nonce, the last 8 bytes hold the frame counter
+	sessionNonceLen = 12
+)
+
+var (
+	errTransportVersion   = errors.New("incompatible peer transport version")
+	errInvalidEphemeral   = errors.New("invalid ephemeral public key")
+	errFrameAuth          = errors.New("failed to authenticate frame")
+	errFrameCodeMissing   = errors.New("frame is too short to hold the message code")
+	errFrameCounterExceed = errors.New("frame counter exhausted, reconnect needed")
+)
+
+// session holds the per connection AEAD ciphers established during the handshake.
+// Each direction has its own key and its own frame counter, the counter is used
+// as the implicit nonce so that replayed, reordered or dropped frames fail to
+// authenticate.
+type session struct {
+	egress  cipher.AEAD
+	ingress cipher.AEAD
+
+	egressCounter  uint64
+	ingressCounter uint64
+}
+
+// newSession derives the session keys from the local ephemeral private key and the
+// remote ephemeral public key. The initiator is the side that dialed the connection.
+func newSession(localKey *ecdsa.PrivateKey, remotePub []byte, initiator bool) (*session, error) {
+	remoteKey := crypto.ToECDSAPub(remotePub)
+	if remoteKey == nil || remoteKey.X == nil || !remoteKey.Curve.IsOnCurve(remoteKey.X, remoteKey.Y) {
+		return nil, errInvalidEphemeral
+	}
+
+	secret, err := ecies.ImportECDSA(localKey).GenerateShared(ecies.ImportECDSAPublic(remoteKey), sessionSecretLen/2, sessionSecretLen/2)
+	if err != nil {
+		return nil, err
+	}
+
+	// bind both ephemeral keys to the secret, ordered by role, so that each
+	// direction uses a different key
+	localPub := crypto.FromECDSAPub(&localKey.PublicKey)
+	initPub, respPub := localPub, remotePub
+	if !initiator {
+		initPub, respPub = remotePub, localPub
+	}
+
+	initKey := deriveSessionKey(secret, initPub, respPub, "initiator")
+	respKey := deriveSessionKey(secret, initPub, respPub, "recipient")
+
+	egressKey, ingressKey := initKey, respKey
+	if !initiator {
+		egressKey, ingressKey = respKey, initKey
+	}
+
+	s := &session{}
+	if s.egress, err = newFrameAEAD(egressKey); err != nil {
+		return nil, err
+	}
+	if s.ingress, err = newFrameAEAD(ingressKey); err != nil {
+		return nil, err
+	}
+
+	return s, nil
+}
+
+func deriveSessionKey(secret, initPub, respPub []byte, label string) []byte {
+	h := sha256.New()
+	h.Write(secret)
+	h.Write(initPub)
+	h.Write(respPub)
+	h.Write([]byte(label))
+	return h.Sum(nil)
+}
+
+func newFrameAEAD(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+
+	return cipher.NewGCM(block)
+}
+
+func frameNonce(counter uint64) []byte {
+	nonce := make([]byte, sessionNonceLen)
+	binary.BigEndian.PutUint64(nonce[sessionNonceLen-8:], counter)
+	return nonce
+}
+
+// overhead returns the number of bytes added to each sealed frame
+func (s *session) overhead() int {
+	return s.egress.Overhead()
+}
+
+// seal encrypts the message code and payload, authenticating the frame header as well.
+func (s *session) seal(header []byte, code uint16, payload []byte) ([]byte, error) {
+	if s.egressCounter == ^uint64(0) {
+		return nil, errFrameCounterExceed
+	}
+
+	plain := make([]byte, 2+len(payload))
+	binary.BigEndian.PutUint16(plain, code)
+	copy(plain[2:], payload)
+
+	sealed := s.egress.Seal(nil, frameNonce(s.egressCounter), plain, header)
+	s.egressCounter++
+
+	return sealed, nil
+}
+
+// open decrypts and authenticates a frame received from the remote side.
+func (s *session) open(header []byte, sealed []byte) (uint16, []byte, error) {
+	if s.ingressCounter == ^uint64(0) {
+		return 0, nil, errFrameCounterExceed
+	}
+
+	plain, err := s.ingress.Open(nil, frameNonce(s.ingressCounter), sealed, header)
+	if err != nil {
+		return 0, nil, errFrameAuth
+	}
+	s.ingressCounter++
+
+	if len(plain) < 2 {
+		return 0, nil, errFrameCodeMissing
+	}
+
+	return binary.BigEndian.Uint16(plain), plain[2:], nil
+}
@@ -0,0 +1,119 @@
+/**
+*  @file
+*  @copyright defined in scdo/LICENSE
+ */
+
+package p2p
+
+import (
+	"testing"
+
+	"github.com/elcn233/go-scdo/crypto"
+	"github.com/elcn233/go-scdo/log"
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestSessions(t *testing.T) (*session, *session) {
+	initKey, _ := crypto.GenerateKey()
+	respKey, _ := crypto.GenerateKey()
+
+	initiator, err := newSession(initKey, crypto.FromECDSAPub(&respKey.PublicKey), true)
+	assert.Equal(t, err, nil)
+
+	recipient, err := newSession(respKey, crypto.FromECDSAPub(&initKey.PublicKey), false)
+	assert.Equal(t, err, nil)
+
+	return initiator, recipient
+}
+
+func Test_Session_SealAndOpen(t *testing.T) {
+	initiator, recipient := newTestSessions(t)
+	header := []byte{1, 2, 3}
+
+	// initiator -> recipient
+	sealed, err := initiator.seal(header, 7, []byte("hello"))
+	assert.Equal(t, err, nil)
+
+	code, payload, err := recipient.open(header, sealed)
+	assert.Equal(t, err, nil)
+	assert.Equal(t, code, uint16(7))
+	assert.Equal(t, payload, []byte("hello"))
+
+	// recipient -> initiator uses a different key
+	sealed, err = recipient.seal(header, 8, []byte("world"))
+	assert.Equal(t, err, nil)
+
+	_, _, err = recipient.open(header, sealed)
+	assert.Equal(t, err, errFrameAuth)
+
+	code, payload, err = initiator.open(header, sealed)
+	assert.Equal(t, err, nil)
+	assert.Equal(t, code, uint16(8))
+	assert.Equal(t, payload, []byte("world"))
+}
+
+func Test_Session_RejectTamperAndReplay(t *testing.T) {
+	initiator, recipient := newTestSessions(t)
+	header := []byte{1, 2, 3}
+
+	sealed, err := initiator.seal(header, 1, []byte("payload"))
+	assert.Equal(t, err, nil)
+
+	// tampered header
+	_, _, err = recipient.open([]byte{1, 2, 4}, sealed)
+	assert.Equal(t, err, errFrameAuth)
+
+	// tampered body
+	tampered := append([]byte{}, sealed...)
+	tampered[0] ^= 0xff
+	_, _, err = recipient.open(header, tampered)
+	assert.Equal(t, err, errFrameAuth)
+
+	_, _, err = recipient.open(header, sealed)
+	assert.Equal(t, err, nil)
+
+	// replayed frame
+	_, _, err = recipient.open(header, sealed)
+	assert.Equal(t, err, errFrameAuth)
+}
+
+func Test_Session_InvalidEphemeralKey(t *testing.T) {
+	key, _ := crypto.GenerateKey()
+
+	_, err := newSession(key, nil, true)
+	assert.Equal(t, err, errInvalidEphemeral)
+
+	_, err = newSession(key, []byte{4, 1, 2, 3}, true)
+	assert.Equal(t, err, errInvalidEphemeral)
+}
+
+func Test_Conn_SealedMsg(t *testing.T) {
+	con, ln, err := newConnection()
+	defer ln.Close()
+	defer con.close()
+	assert.Equal(t, err, nil)
+
+	fd1, err := ln.Accept()
+	assert.Equal(t, err, nil)
+	con1 := &connection{fd: fd1, log: log.GetLogger("p2p")}
+	defer con1.close()
+
+	con.session, con1.session = newTestSessions(t)
+
+	err = con.WriteMsg(&Message{Code: 5, Payload: []byte("sealed payload")})
+	assert.Equal(t, err, nil)
+
+	msg, err := con1.ReadMsg()
+	assert.Equal(t, err, nil)
+	assert.Equal(t, msg.Code, uint16(5))
+	assert.Equal(t, msg.Payload, []byte("sealed payload"))
+
+	// empty payload
+	err = con1.WriteMsg(&Message{Code: ctlMsgPingCode})
+	assert.Equal(t, err, nil)
+
+	msg, err = con.ReadMsg()
+	assert.Equal(t, err, nil)
+	assert.Equal(t, msg.Code, ctlMsgPingCode)
+	assert.Equal(t, len(msg.Payload), 0)
+}
@@ -0,0 +1,217 @@
+/**
+*  @file
+*  @copyright defined in scdo/LICENSE
+ */
+
+package p2p
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	metrics "github.com/rcrowley/go-metrics"
+)
+
+// RateLimit limits the rate of the messages with the code received from a peer
+type RateLimit struct {
+	Code  uint16  `json:"code"`  // message code of the protocol
+	Rate  float64 `json:"rate"`  // messages per second, 0 disables the limit
+	Burst int     `json:"burst"` // max messages accepted at once
+}
+
+// TrafficCounter counts the messages and their payload bytes
+type TrafficCounter struct {
+	Messages uint64 `json:"messages"`
+	Bytes    uint64 `json:"bytes"`
+}
+
+func (c *TrafficCounter) add(bytes int) {
+	c.Messages++
+	c.Bytes += uint64(bytes)
+}
+
+// CodeTraffic is the traffic of a message code
+type CodeTraffic struct {
+	Ingress TrafficCounter `json:"ingress"`
+	Egress  TrafficCounter `json:"egress"`
+	Limited uint64         `json:"limited"` // received messages dropped by the rate limit
+}
+
+// ProtocolTraffic is the traffic of a protocol, the payload bytes before compression are counted
+type ProtocolTraffic struct {
+	Ingress TrafficCounter          `json:"ingress"`
+	Egress  TrafficCounter          `json:"egress"`
+	Codes   map[uint16]*CodeTraffic `json:"codes"`
+}
+
+// trafficMeters caches the metrics meters of the protocol message codes
+var trafficMeters sync.Map
+
+func trafficMeter(name string) metrics.Meter {
+	if m, ok := trafficMeters.Load(name); ok {
+		return m.(metrics.Meter)
+	}
+
+	m, _ := trafficMeters.LoadOrStore(name, metrics.GetOrRegisterMeter(name, nil))
+	return m.(metrics.Meter)
+}
+
+func markTraffic(protocol string, code uint16, direction string, bytes int) {
+	prefix := fmt.Sprintf("p2p.traffic.%s.%d.%s", protocol, code, direction)
+	trafficMeter(prefix + ".messages").Mark(1)
+	trafficMeter(prefix + ".bytes").Mark(int64(bytes))
+}
+
+// rateLimiter is a token bucket refilled at the rate
+type rateLimiter struct {
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+}
+
+func newRateLimiter(limit RateLimit) *rateLimiter {
+	burst := float64(limit.Burst)
+	if burst < 1 {
+		burst = 1
+	}
+
+	return &rateLimiter{rate: limit.Rate, burst: burst, tokens: burst}
+}
+
+func (l *rateLimiter) allow(now time.Time) bool {
+	if !l.last.IsZero() {
+		l.tokens += now.Sub(l.last).Seconds() * l.rate
+		if l.tokens > l.burst {
+			l.tokens = l.burst
+		}
+	}
+	l.last = now
+
+	if l.tokens < 1 {
+		return false
+	}
+
+	l.tokens--
+	return true
+}
+
+// peerTraffic accounts the traffic of a peer and enforces the rate limits of the received messages
+type peerTraffic struct {
+	lock      sync.Mutex
+	protocols map[string]*ProtocolTraffic
+	limiters  map[string]map[uint16]*rateLimiter // protocol name -> code -> limiter
+}
+
+func newPeerTraffic() *peerTraffic {
+	return &peerTraffic{
+		protocols: make(map[string]*ProtocolTraffic),
+		limiters:  make(map[string]map[uint16]*rateLimiter),
+	}
+}
+
+// setLimits sets the rate limits of the protocol
+func (t *peerTraffic) setLimits(protocol string, limits []RateLimit) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	limiters := make(map[uint16]*rateLimiter)
+	for _, limit := range limits {
+		if limit.Rate > 0 {
+			limiters[limit.Code] = newRateLimiter(limit)
+		}
+	}
+
+	t.limiters[protocol] = limiters
+}
+
+func (t *peerTraffic) codeTraffic(protocol string, code uint16) (*ProtocolTraffic, *CodeTraffic) {
+	pt, ok := t.protocols[protocol]
+	if !ok {
+		pt = &ProtocolTraffic{Codes: make(map[uint16]*CodeTraffic)}
+		t.protocols[protocol] = pt
+	}
+
+	ct, ok := pt.Codes[code]
+	if !ok {
+		ct = &CodeTraffic{}
+		pt.Codes[code] = ct
+	}
+
+	return pt, ct
+}
+
+// ingress accounts the received message and returns false if it exceeds the rate limit
+func (t *peerTraffic) ingress(protocol string, code uint16, bytes int) bool {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	pt, ct := t.codeTraffic(protocol, code)
+	pt.Ingress.add(bytes)
+	ct.Ingress.add(bytes)
+	markTraffic(protocol, code, "ingress", bytes)
+
+	if limiter, ok := t.limiters[protocol][code]; ok && !limiter.allow(time.Now()) {
+		ct.Limited++
+		trafficMeter(fmt.Sprintf("p2p.traffic.%s.%d.limited", protocol, code)).Mark(1)
+		return false
+	}
+
+	return true
+}
+
+// egress accounts the sent message
+func (t *peerTraffic) egress(protocol string, code uint16, bytes int) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	pt, ct := t.codeTraffic(protocol, code)
+	pt.Egress.add(bytes)
+	ct.Egress.add(bytes)
+	markTraffic(protocol, code, "egress", bytes)
+}
+
+// snapshot returns a copy of the traffic of the protocols
+func (t *peerTraffic) snapshot() map[string]*ProtocolTraffic {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	result := make(map[string]*ProtocolTraffic, len(t.protocols))
+	for name, pt := range t.protocols {
+		copied := &ProtocolTraffic{
+			Ingress: pt.Ingress,
+			Egress:  pt.Egress,
+			Codes:   make(map[uint16]*CodeTraffic, len(pt.Codes)),
+		}
+
+		for code, ct := range pt.Codes {
+			c := *ct
+			copied.Codes[code] = &c
+		}
+
+		result[name] = copied
+	}
+
+	return result
+}
+
+// mergeRateLimits returns the limits of the protocol overridden by the configured limits of the same code
+func mergeRateLimits(limits []RateLimit, overrides []RateLimit) []RateLimit {
+	merged := make([]RateLimit, 0, len(limits)+len(overrides))
+	for _, limit := range limits {
+		overridden := false
+		for _, o := range overrides {
+			if o.Code == limit.Code {
+				overridden = true
+				break
+			}
+		}
+
+		if !overridden {
+			merged = append(merged, limit)
+		}
+	}
+
+	return append(merged, overrides...)
+}
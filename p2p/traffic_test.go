@@ -0,0 +1,83 @@
+/**
+*  @file
+*  @copyright defined in scdo/LICENSE
+ */
+
+package p2p
+
+import (
+	"testing"
+	"time"
+
+	"github.com/elcn233/go-scdo/log"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_RateLimiter(t *testing.T) {
+	l := newRateLimiter(RateLimit{Code: 1, Rate: 2, Burst: 3})
+	now := time.Now()
+
+	// burst is accepted at once
+	for i := 0; i < 3; i++ {
+		assert.Equal(t, l.allow(now), true)
+	}
+	assert.Equal(t, l.allow(now), false)
+
+	// 2 tokens are refilled per second
+	now = now.Add(time.Second)
+	assert.Equal(t, l.allow(now), true)
+	assert.Equal(t, l.allow(now), true)
+	assert.Equal(t, l.allow(now), false)
+
+	// the tokens never exceed the burst
+	now = now.Add(time.Minute)
+	for i := 0; i < 3; i++ {
+		assert.Equal(t, l.allow(now), true)
+	}
+	assert.Equal(t, l.allow(now), false)
+}
+
+func Test_PeerTraffic(t *testing.T) {
+	traffic := newPeerTraffic()
+	traffic.setLimits("scdo", []RateLimit{{Code: 4, Rate: 1, Burst: 1}, {Code: 5, Rate: 0}})
+
+	assert.Equal(t, traffic.ingress("scdo", 4, 10), true)
+	assert.Equal(t, traffic.ingress("scdo", 4, 10), false)
+	assert.Equal(t, traffic.ingress("scdo", 5, 100), true)
+	assert.Equal(t, traffic.ingress("scdo", 5, 100), true)
+	traffic.egress("scdo", 6, 50)
+	traffic.egress("light", 1, 7)
+
+	stats := traffic.snapshot()
+	assert.Equal(t, stats["scdo"].Ingress, TrafficCounter{Messages: 4, Bytes: 220})
+	assert.Equal(t, stats["scdo"].Egress, TrafficCounter{Messages: 1, Bytes: 50})
+	assert.Equal(t, stats["scdo"].Codes[4].Limited, uint64(1))
+	assert.Equal(t, stats["scdo"].Codes[5].Limited, uint64(0))
+	assert.Equal(t, stats["scdo"].Codes[6].Egress, TrafficCounter{Messages: 1, Bytes: 50})
+	assert.Equal(t, stats["light"].Egress, TrafficCounter{Messages: 1, Bytes: 7})
+
+	// the snapshot is a copy
+	traffic.egress("scdo", 6, 50)
+	assert.Equal(t, stats["scdo"].Codes[6].Egress.Messages, uint64(1))
+}
+
+func Test_Peer_HandleRateLimited(t *testing.T) {
+	peer := NewPeer(&connection{}, log.GetLogger("p2p"), nil)
+	peer.setProtocols([]Protocol{{Name: "scdo", Version: 1, Length: 10, RateLimits: []RateLimit{{Code: 4, Rate: 1, Burst: 1}}}})
+	rw := peer.protocolMap["scdo/1"]
+
+	msg := &Message{Code: baseProtoCode + 4, Payload: []byte{1, 2, 3}}
+	assert.Equal(t, peer.handle(msg), nil)
+	assert.Equal(t, len(rw.in), 1)
+
+	// the message exceeding the limit is dropped
+	assert.Equal(t, peer.handle(msg), nil)
+	assert.Equal(t, len(rw.in), 1)
+	assert.Equal(t, peer.traffic.snapshot()["scdo"].Codes[4].Limited, uint64(1))
+}
+
+func Test_MergeRateLimits(t *testing.T) {
+	limits := []RateLimit{{Code: 1, Rate: 1}, {Code: 2, Rate: 2}}
+	merged := mergeRateLimits(limits, []RateLimit{{Code: 2, Rate: 0}, {Code: 3, Rate: 3}})
+	assert.Equal(t, merged, []RateLimit{{Code: 1, Rate: 1}, {Code: 2, Rate: 0}, {Code: 3, Rate: 3}})
+}
@@ -0,0 +1,255 @@
+/**
+*  @file
+*  @copyright defined in scdo/LICENSE
+ */
+
+package p2p
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+
+	"github.com/elcn233/go-scdo/common"
+	"github.com/elcn233/go-scdo/log"
+	"github.com/elcn233/go-scdo/p2p/discovery"
+)
+
+// trustedPeersBackupFile is the file in the node directory the trusted peers are saved to
+const trustedPeersBackupFile = "trustedPeers.json"
+
+var (
+	errInvalidPeerNode = errors.New("invalid peer node, node id and shard are required")
+	errPeerNotTrusted  = errors.New("peer is neither connected nor trusted")
+	errInvalidMaxPeers = errors.New("max peers must be greater than 0")
+)
+
+// trustedPeers are the peers added at runtime, they are dialed whenever they are
+// not connected and saved in the node directory.
+type trustedPeers struct {
+	lock  sync.RWMutex
+	nodes map[common.Address]*discovery.Node
+
+	// path of the backup file, trusted peers are not saved if it is empty
+	path string
+	log  *log.ScdoLog
+}
+
+func newTrustedPeers(log *log.ScdoLog) *trustedPeers {
+	return &trustedPeers{
+		nodes: make(map[common.Address]*discovery.Node),
+		log:   log,
+	}
+}
+
+// load loads the trusted peers from the node directory, later changes are saved to the same file
+func (t *trustedPeers) load(nodeDir string) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	t.path = filepath.Join(nodeDir, trustedPeersBackupFile)
+	if !common.FileOrFolderExists(t.path) {
+		t.log.Debug("trusted peers backup file doesn't exist in the path:%s", t.path)
+		return
+	}
+
+	data, err := ioutil.ReadFile(t.path)
+	if err != nil {
+		t.log.Error("failed to read trusted peers backup file for:[%s]", err)
+		return
+	}
+
+	var nodes []string
+	if err = json.Unmarshal(data, &nodes); err != nil {
+		t.log.Error("failed to unmarshal trusted peers for:[%s]", err)
+		return
+	}
+
+	for _, str := range nodes {
+		node, err := discovery.NewNodeFromString(str)
+		if err != nil {
+			t.log.Debug("new node from string failed for:[%s]", err)
+			continue
+		}
+		t.nodes[node.ID] = node
+	}
+
+	t.log.Debug("load %d trusted peers from back file", len(t.nodes))
+}
+
+// save writes the trusted peers to the backup file, must be called with the lock held
+func (t *trustedPeers) save() {
+	if len(t.path) == 0 {
+		return
+	}
+
+	nodes := make([]string, 0, len(t.nodes))
+	for _, node := range t.sorted() {
+		nodes = append(nodes, node.String())
+	}
+
+	data, err := json.MarshalIndent(nodes, "", "\t")
+	if err != nil {
+		t.log.Error("json marshal occur error, for:[%s]", err)
+		return
+	}
+
+	if err = os.MkdirAll(filepath.Dir(t.path), os.ModePerm); err != nil {
+		t.log.Error("filePath:[%s], failed to create folder, for:[%s]", t.path, err)
+		return
+	}
+
+	if err = ioutil.WriteFile(t.path, data, 0666); err != nil {
+		t.log.Error("trusted peers backup failed, for:[%s]", err)
+	}
+}
+
+func (t *trustedPeers) add(node *discovery.Node) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	t.nodes[node.ID] = node
+	t.save()
+}
+
+// remove removes the trusted peer and returns it, returns nil if not found
+func (t *trustedPeers) remove(id common.Address) *discovery.Node {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	node, ok := t.nodes[id]
+	if !ok {
+		return nil
+	}
+
+	delete(t.nodes, id)
+	t.save()
+	return node
+}
+
+func (t *trustedPeers) has(id common.Address) bool {
+	t.lock.RLock()
+	defer t.lock.RUnlock()
+
+	_, ok := t.nodes[id]
+	return ok
+}
+
+// list returns the trusted peers ordered by node id
+func (t *trustedPeers) list() []*discovery.Node {
+	t.lock.RLock()
+	defer t.lock.RUnlock()
+
+	return t.sorted()
+}
+
+func (t *trustedPeers) sorted() []*discovery.Node {
+	nodes := make([]*discovery.Node, 0, len(t.nodes))
+	for _, node := range t.nodes {
+		nodes = append(nodes, node)
+	}
+
+	sort.Slice(nodes, func(i, j int) bool {
+		return nodes[i].ID.Hex() < nodes[j].ID.Hex()
+	})
+
+	return nodes
+}
+
+// AddPeer adds the node as a trusted peer and connects to it. Trusted peers
+// are reconnected when the connection drops and are kept across restarts.
+func (srv *Server) AddPeer(node *discovery.Node) error {
+	if node == nil || node.ID.IsEmpty() || node.Shard == discovery.UndefinedShardNumber {
+		return errInvalidPeerNode
+	}
+
+	if err := node.ID.Validate(); err != nil {
+		return err
+	}
+
+	if srv.reputation.isBanned(node.ID) {
+		return errPeerBanned
+	}
+
+	srv.trustedPeers.add(node)
+	srv.addTrustNode(node)
+	srv.nodeSet.tryAdd(node)
+	go srv.connectNode(node)
+
+	return nil
+}
+
+// addTrustNode lets discovery ping pong the trusted peer so that it stays in the node database
+func (srv *Server) addTrustNode(node *discovery.Node) {
+	if srv.udp == nil {
+		return
+	}
+
+	if err := srv.udp.AddTrustNode(node.GetUDPAddr().String()); err != nil {
+		srv.log.Warn("failed to add trust node %s to discovery, %s", node, err)
+	}
+}
+
+// RemovePeer removes the peer from the trusted peers and the discovery, and disconnects it,
+// so that it is not reconnected unless discovered again.
+func (srv *Server) RemovePeer(id common.Address) error {
+	node := srv.trustedPeers.remove(id)
+	if node != nil && srv.udp != nil {
+		if err := srv.udp.RemoveTrustNode(node.GetUDPAddr().String()); err != nil {
+			srv.log.Debug("failed to remove trust node %s from discovery, %s", node, err)
+		}
+	}
+
+	srv.peerLock.Lock()
+	p := srv.peerSet.find(id)
+	srv.peerLock.Unlock()
+
+	if p == nil && node == nil {
+		return errPeerNotTrusted
+	}
+
+	if node == nil {
+		node = p.Node
+	}
+	srv.nodeSet.delete(node)
+	if srv.udp != nil {
+		srv.udp.RemoveNode(id)
+	}
+
+	if p != nil {
+		go p.Disconnect("removed by admin")
+	}
+
+	return nil
+}
+
+// SetMaxPeers changes the max number of connections, it takes effect on new connections.
+func (srv *Server) SetMaxPeers(maxPeers int) error {
+	if maxPeers <= 0 {
+		return errInvalidMaxPeers
+	}
+
+	srv.SetMaxConnections(maxPeers)
+	return nil
+}
+
+// StaticNodes returns the static nodes of the config and the trusted peers
+func (srv *Server) StaticNodes() []*discovery.Node {
+	nodes := make([]*discovery.Node, 0, len(srv.Config.StaticNodes))
+	nodes = append(nodes, srv.Config.StaticNodes...)
+
+	return append(nodes, srv.trustedPeers.list()...)
+}
+
+// connectTrustedPeers dials the trusted peers that are not connected
+func (srv *Server) connectTrustedPeers() {
+	for _, node := range srv.trustedPeers.list() {
+		if !srv.checkPeerExist(node.ID) {
+			srv.connectNode(node)
+		}
+	}
+}
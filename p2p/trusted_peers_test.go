@@ -0,0 +1,68 @@
+/**
+*  @file
+*  @copyright defined in scdo/LICENSE
+ */
+
+package p2p
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/elcn233/go-scdo/crypto"
+	"github.com/elcn233/go-scdo/log"
+	"github.com/elcn233/go-scdo/p2p/discovery"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_TrustedPeers_Persistence(t *testing.T) {
+	dir, err := ioutil.TempDir("", "trustedPeers")
+	assert.Equal(t, err, nil)
+	defer os.RemoveAll(dir)
+
+	node1 := discovery.MustNewNodeWithAddr(*crypto.MustGenerateShardAddress(1), "127.0.0.1:9001", 1)
+	node2 := discovery.MustNewNodeWithAddr(*crypto.MustGenerateShardAddress(2), "127.0.0.1:9002", 2)
+
+	tp := newTrustedPeers(log.GetLogger("p2p"))
+	tp.load(dir)
+	tp.add(node1)
+	tp.add(node2)
+	assert.Equal(t, tp.has(node1.ID), true)
+
+	loaded := newTrustedPeers(log.GetLogger("p2p"))
+	loaded.load(dir)
+	assert.Equal(t, len(loaded.list()), 2)
+	assert.Equal(t, loaded.has(node1.ID), true)
+	assert.Equal(t, loaded.has(node2.ID), true)
+
+	assert.Equal(t, loaded.remove(node1.ID).String(), node1.String())
+	assert.Equal(t, loaded.remove(node1.ID) == nil, true)
+
+	loaded = newTrustedPeers(log.GetLogger("p2p"))
+	loaded.load(dir)
+	nodes := loaded.list()
+	assert.Equal(t, len(nodes), 1)
+	assert.Equal(t, nodes[0].String(), node2.String())
+}
+
+func Test_SetMaxPeers(t *testing.T) {
+	srv := &Server{inboundSlots: newConnSlots(1)}
+	assert.Equal(t, srv.SetMaxPeers(0), errInvalidMaxPeers)
+
+	srv.inboundSlots.acquire()
+	acquired := make(chan struct{})
+	go func() {
+		srv.inboundSlots.acquire()
+		close(acquired)
+	}()
+
+	// the waiting connection is accepted once the slots are resized
+	assert.Equal(t, srv.SetMaxPeers(2), nil)
+	assert.Equal(t, srv.MaxConnections(), 2)
+	<-acquired
+
+	srv.inboundSlots.release()
+	srv.inboundSlots.release()
+	assert.Equal(t, srv.inboundSlots.used, 0)
+}
@@ -73,7 +73,7 @@ type Server struct {
 
 	run               int32
 	codecsMu          sync.Mutex
-	codecs            *set.Set
+	codecs            set.Interface
 	minerRemoteRequst bool
 }
 
@@ -10,6 +10,10 @@ import (
 	"time"
 
 	"github.com/elcn233/go-scdo/common"
+	"github.com/elcn233/go-scdo/common/errors"
+	"github.com/elcn233/go-scdo/consensus"
+	"github.com/elcn233/go-scdo/core"
+	"github.com/elcn233/go-scdo/core/types"
 )
 
 const (
@@ -68,3 +72,28 @@ type chainHeadStatus struct {
 	TD           *big.Int
 	CurrentBlock common.Hash
 }
+
+// invalidBlockErrs are the errors of writing a block which mean the block itself is invalid,
+// the peer sending such block is penalized.
+var invalidBlockErrs = []error{
+	consensus.ErrBlockNonceInvalid,
+	consensus.ErrBlockDifficultInvalid,
+	types.ErrBlockHashMismatch,
+	types.ErrBlockTxsHashMismatch,
+	types.ErrBlockTxDebtHashMismatch,
+	types.ErrBlockDebtHashMismatch,
+	core.ErrBlockStateHashMismatch,
+	core.ErrBlockReceiptHashMismatch,
+	core.ErrBlockTooManyTxs,
+	core.ErrBlockExtraDataNotEmpty,
+}
+
+func isInvalidBlockErr(err error) bool {
+	for _, e := range invalidBlockErrs {
+		if errors.IsOrContains(err, e) {
+			return true
+		}
+	}
+
+	return false
+}
@@ -591,6 +591,7 @@ func (d *Downloader) processBlocks(headInfos []*downloadInfo, ancestor uint64, l
 			// get local block
 
 			if errors.IsOrContains(err, consensus.ErrBlockNonceInvalid) || errors.IsOrContains(err, consensus.ErrBlockDifficultInvalid) {
+				conn.peer.ReportMisbehavior(p2p.PenaltyInvalidBlock, err.Error())
 				conn.peer.DisconnectPeer("peerDownload anormaly")
 			}
 			d.Cancel()
@@ -76,6 +76,8 @@ type TestPeer struct {
 
 func (p *TestPeer) DisconnectPeer(reason string) {}
 
+func (p *TestPeer) ReportMisbehavior(penalty int, reason string) {}
+
 // Head retrieves a copy of the current head hash and total difficulty.
 func (p *TestPeer) Head() (hash common.Hash, td *big.Int) {
 	return p.head, new(big.Int).Set(p.td)
@@ -7,6 +7,7 @@ package downloader
 
 import (
 	"errors"
+	"fmt"
 	"math/big"
 	"sync"
 	"time"
@@ -32,6 +33,7 @@ type Peer interface {
 	RequestBlocksByHashOrNumber(magic uint32, origin common.Hash, num uint64, amount int) error
 	GetPeerRequestInfo() (uint32, common.Hash, uint64, int)
 	DisconnectPeer(reason string)
+	ReportMisbehavior(penalty int, reason string)
 }
 
 type peerConn struct {
@@ -116,6 +118,7 @@ Again:
 		}
 	case <-timeout.C:
 		p.log.Debug("Downloader.waitMsg  timeout msg=%s pid=%s", CodeToStr(msgCode), p.peerID)
+		p.peer.ReportMisbehavior(p2p.PenaltyTimeout, fmt.Sprintf("wait for %s timeout", CodeToStr(msgCode)))
 		//err = fmt.Errorf("Download.peerconn wait for msg %s timeout.magic= %d ip= %s", CodeToStr(msgCode), magic, p.peerID)
 		err = errReceivedQuitMsg
 	}
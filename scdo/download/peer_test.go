@@ -22,6 +22,8 @@ type TestDownloadPeer struct{}
 
 func (s TestDownloadPeer) DisconnectPeer(reason string) {}
 
+func (s TestDownloadPeer) ReportMisbehavior(penalty int, reason string) {}
+
 func (s TestDownloadPeer) Head() (common.Hash, *big.Int) {
 	return common.EmptyHash, nil
 }
@@ -0,0 +1,87 @@
+/**
+*  @file
+*  @copyright defined in scdo/LICENSE
+ */
+
+package scdo
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/elcn233/go-scdo/common"
+	"github.com/elcn233/go-scdo/core/types"
+	"github.com/elcn233/go-scdo/p2p"
+	"github.com/elcn233/go-scdo/p2p/probe"
+	downloader "github.com/elcn233/go-scdo/scdo/download"
+	"github.com/stretchr/testify/assert"
+)
+
+// probeTestRW answers the probe like a full node
+type probeTestRW struct {
+	status  statusData
+	height  uint64
+	echo    *statusData
+	pending []*p2p.Message
+}
+
+func (rw *probeTestRW) ReadMsg() (*p2p.Message, error) {
+	msg := rw.pending[0]
+	rw.pending = rw.pending[1:]
+	return msg, nil
+}
+
+func (rw *probeTestRW) WriteMsg(msg *p2p.Message) error {
+	switch msg.Code {
+	case statusDataMsgCode:
+		rw.echo = &statusData{}
+		return common.Deserialize(msg.Payload, rw.echo)
+	case downloader.GetBlockHeadersMsg:
+		var query blockHeadersQuery
+		if err := common.Deserialize(msg.Payload, &query); err != nil {
+			return err
+		}
+
+		headers := &downloader.BlockHeadersMsgBody{
+			Magic:   query.Magic,
+			Headers: []*types.BlockHeader{{Height: rw.height}},
+		}
+		rw.pending = append(rw.pending,
+			&p2p.Message{Code: transactionHashMsgCode, Payload: []byte{}},
+			&p2p.Message{Code: downloader.BlockHeadersMsg, Payload: common.SerializePanic(headers)})
+	}
+
+	return nil
+}
+
+// Test_ProbeChainHead checks that the probe package speaks the scdo protocol
+func Test_ProbeChainHead(t *testing.T) {
+	rw := &probeTestRW{
+		status: statusData{
+			ProtocolVersion: uint32(common.ScdoVersion),
+			NetworkID:       "scdo",
+			TD:              big.NewInt(100),
+			CurrentBlock:    common.StringToHash("head"),
+			GenesisBlock:    common.StringToHash("genesis"),
+			Shard:           2,
+			Difficult:       8000000,
+		},
+		height: 42,
+	}
+	rw.pending = []*p2p.Message{{Code: statusDataMsgCode, Payload: common.SerializePanic(&rw.status)}}
+
+	protocol := probe.Protocol()
+	assert.Equal(t, protocol.Name, common.ScdoProtoName)
+	assert.Equal(t, protocol.Length, protocolMsgCodeLength)
+
+	head, err := probe.ReadChainHead(rw)
+	assert.Equal(t, err, nil)
+	assert.Equal(t, head.Shard, uint(2))
+	assert.Equal(t, head.TD, big.NewInt(100))
+	assert.Equal(t, head.Hash, common.StringToHash("head"))
+	assert.Equal(t, head.Height, uint64(42))
+
+	// the echoed status passes the verification of the node
+	assert.Equal(t, verifyGenesisAndNetworkID(*rw.echo, rw.status.GenesisBlock, "scdo", 2, 8000000), nil)
+	assert.Equal(t, rw.echo.TD, big.NewInt(0))
+}
@@ -43,6 +43,14 @@ var (
 	protocolMsgCodeLength uint16 = 14
 )
 
+// defaultRateLimits limits the requests that make the node read blocks from the store,
+// the limits could be overridden in the p2p config.
+var defaultRateLimits = []p2p.RateLimit{
+	{Code: blockRequestMsgCode, Rate: 10, Burst: 20},
+	{Code: downloader.GetBlockHeadersMsg, Rate: 20, Burst: 50},
+	{Code: downloader.GetBlocksMsg, Rate: 10, Burst: 30},
+}
+
 func codeToStr(code uint16) string {
 	switch code {
 	case transactionHashMsgCode:
@@ -94,9 +102,10 @@ func (s *ScdoProtocol) Downloader() *downloader.Downloader { return s.downloader
 func NewScdoProtocol(scdo *ScdoService, log *log.ScdoLog) (s *ScdoProtocol, err error) {
 	s = &ScdoProtocol{
 		Protocol: p2p.Protocol{
-			Name:    common.ScdoProtoName,
-			Version: common.ScdoVersion,
-			Length:  protocolMsgCodeLength,
+			Name:       common.ScdoProtoName,
+			Version:    common.ScdoVersion,
+			Length:     protocolMsgCodeLength,
+			RateLimits: defaultRateLimits,
 		},
 		networkID:  scdo.networkID,
 		txPool:     scdo.TxPool(),
@@ -531,6 +540,7 @@ handler:
 			err := common.Deserialize(msg.Payload, &txHash)
 			if err != nil {
 				p.log.Warn("failed to deserialize transaction hash msg, %s", err.Error())
+				peer.ReportMisbehavior(p2p.PenaltyInvalidMessage, "invalid transaction hash msg")
 				continue
 			}
 
@@ -557,6 +567,7 @@ handler:
 			err := common.Deserialize(msg.Payload, &txHash)
 			if err != nil {
 				p.log.Warn("failed to deserialize transaction request msg %s", err.Error())
+				peer.ReportMisbehavior(p2p.PenaltyInvalidMessage, "invalid transaction request msg")
 				continue
 			}
 
@@ -584,6 +595,7 @@ handler:
 			err := common.Deserialize(msg.Payload, &txs)
 			if err != nil {
 				p.log.Warn("failed to deserialize transaction msg %s", err.Error())
+				peer.ReportMisbehavior(p2p.PenaltyInvalidMessage, "invalid transactions msg")
 				break
 			}
 
@@ -610,6 +622,7 @@ handler:
 			err := common.Deserialize(msg.Payload, &blockHash)
 			if err != nil {
 				p.log.Warn("failed to deserialize block hash msg %s", err.Error())
+				peer.ReportMisbehavior(p2p.PenaltyInvalidMessage, "invalid block hash msg")
 				continue
 			}
 
@@ -636,6 +649,7 @@ handler:
 			err := common.Deserialize(msg.Payload, &blockHash)
 			if err != nil {
 				p.log.Warn("failed to deserialize block request msg %s", err.Error())
+				peer.ReportMisbehavior(p2p.PenaltyInvalidMessage, "invalid block request msg")
 				continue
 			}
 
@@ -662,6 +676,13 @@ handler:
 			err := common.Deserialize(msg.Payload, &block)
 			if err != nil {
 				p.log.Warn("failed to deserialize block msg %s", err.Error())
+				peer.ReportMisbehavior(p2p.PenaltyInvalidMessage, "invalid block msg")
+				continue
+			}
+
+			if err = block.Validate(); err != nil {
+				p.log.Warn("got invalid block from %s, %s", peer.peerStrID, err)
+				peer.ReportMisbehavior(p2p.PenaltyInvalidBlock, err.Error())
 				continue
 			}
 
@@ -669,7 +690,11 @@ handler:
 			peer.knownBlocks.Add(block.HeaderHash, nil)
 			if block.GetShardNumber() == common.LocalShardNumber {
 				// @todo need to make sure WriteBlock handle block fork
-				go p.chain.WriteBlock(&block, p.txPool.Pool)
+				go func() {
+					if err := p.chain.WriteBlock(&block, p.txPool.Pool); isInvalidBlockErr(err) {
+						peer.ReportMisbehavior(p2p.PenaltyInvalidBlock, err.Error())
+					}
+				}()
 			}
 
 			// exit
@@ -683,15 +708,24 @@ handler:
 			err := common.Deserialize(msg.Payload, &debts)
 			if err != nil {
 				p.log.Warn("failed to deserialize debts msg %s", err)
+				peer.ReportMisbehavior(p2p.PenaltyInvalidMessage, "invalid debts msg")
 				continue
 			}
 
 			p.log.Debug("got %d debts message [%s]", len(debts), codeToStr(msg.Code))
+			validDebts := make([]*types.Debt, 0, len(debts))
 			for _, d := range debts {
+				if _, err := d.Validate(nil, true, common.UndefinedShardNumber); err != nil {
+					p.log.Warn("got invalid debt %s from %s, %s", d.Hash.Hex(), peer.peerStrID, err)
+					peer.ReportMisbehavior(p2p.PenaltyInvalidDebt, err.Error())
+					continue
+				}
+
 				peer.knownDebts.Add(d.Hash, nil)
+				validDebts = append(validDebts, d)
 			}
 
-			go p.debtPool.AddDebtArray(debts)
+			go p.debtPool.AddDebtArray(validDebts)
 
 			//exit
 			memory.Print(p.log, "handleMsg debtMsgCode exit", now, true)
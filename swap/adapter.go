@@ -0,0 +1,75 @@
+/**
+*  @file
+*  @copyright defined in scdo/LICENSE
+ */
+
+package swap
+
+import (
+	"fmt"
+	"math/big"
+	"sync"
+
+	"github.com/elcn233/go-scdo/common"
+)
+
+// Lock is a hash time lock on a chain
+type Lock struct {
+	ID       string
+	HashLock common.Bytes
+	Sender   string
+	Receiver string
+	Amount   *big.Int
+	Expiry   int64 // unix time in seconds, the lock could be refunded after it
+	Redeemed bool
+	Refunded bool
+	Preimage common.Bytes // revealed by the redemption
+}
+
+// ChainAdapter is the interface of a chain with hash time locks, the addresses
+// and the lock ids are in the chain's own string format.
+type ChainAdapter interface {
+	// Address returns the address of the swap account on the chain
+	Address() string
+	// Lock locks the amount to the receiver until the expiry and returns the lock id
+	Lock(hashLock []byte, receiver string, amount *big.Int, expiry int64) (string, error)
+	// FindLock returns the unspent lock with the hash lock to the swap account, nil if not found
+	FindLock(hashLock []byte) (*Lock, error)
+	// FindOwnLock returns the unspent lock with the hash lock from the swap account, nil if not found
+	FindOwnLock(hashLock []byte) (*Lock, error)
+	// GetLock returns the lock of the id, nil if not found
+	GetLock(id string) (*Lock, error)
+	// Redeem withdraws the lock with the preimage
+	Redeem(id string, preimage []byte) error
+	// Refund refunds the expired lock to the sender
+	Refund(id string) error
+}
+
+// AdapterFactory creates a chain adapter with the chain config
+type AdapterFactory func(config map[string]string) (ChainAdapter, error)
+
+var (
+	adapterFactoriesLock sync.RWMutex
+	adapterFactories     = make(map[string]AdapterFactory)
+)
+
+// RegisterAdapter registers the factory of a chain type, e.g. scdo, btc or eth
+func RegisterAdapter(chainType string, factory AdapterFactory) {
+	adapterFactoriesLock.Lock()
+	defer adapterFactoriesLock.Unlock()
+
+	adapterFactories[chainType] = factory
+}
+
+// NewAdapter creates the chain adapter of the registered chain type
+func NewAdapter(chainType string, config map[string]string) (ChainAdapter, error) {
+	adapterFactoriesLock.RLock()
+	factory, ok := adapterFactories[chainType]
+	adapterFactoriesLock.RUnlock()
+
+	if !ok {
+		return nil, fmt.Errorf("unsupported chain type %s", chainType)
+	}
+
+	return factory(config)
+}
@@ -0,0 +1,428 @@
+/**
+*  @file
+*  @copyright defined in scdo/LICENSE
+ */
+
+package swap
+
+import (
+	"bytes"
+	"crypto/rand"
+	"errors"
+	"math/big"
+	"sync"
+	"time"
+
+	"github.com/elcn233/go-scdo/log"
+)
+
+const preimageLength = 32
+
+// Config is the config of the swap manager
+type Config struct {
+	// LockDuration is the duration of the initiator's lock, the participant locks half of it
+	LockDuration time.Duration
+	// SafetyMargin is the min time left to redeem a lock before it expires
+	SafetyMargin time.Duration
+	// RetryInterval is the interval to resend the redeem or refund tx if it is not confirmed
+	RetryInterval time.Duration
+}
+
+// DefaultConfig is the default config of the swap manager
+var DefaultConfig = Config{
+	LockDuration:  48 * time.Hour,
+	SafetyMargin:  2 * time.Hour,
+	RetryInterval: 10 * time.Minute,
+}
+
+var (
+	errUnknownChain   = errors.New("unknown chain")
+	errSameChain      = errors.New("our chain and their chain must be different")
+	errInvalidAmount  = errors.New("amount must be positive")
+	errSwapExists     = errors.New("swap of the hash lock already exists")
+	errInvalidHashLen = errors.New("hash lock must be 32 bytes")
+)
+
+// Manager drives the atomic swaps through the chain adapters, the state of each
+// swap is persisted after every step so the swaps continue after a restart.
+type Manager struct {
+	config   Config
+	store    *Store
+	adapters map[string]ChainAdapter
+	now      func() time.Time
+	log      *log.ScdoLog
+	lock     sync.Mutex
+}
+
+// NewManager creates a swap manager with the adapters by chain name
+func NewManager(config Config, store *Store, adapters map[string]ChainAdapter) *Manager {
+	return &Manager{
+		config:   config,
+		store:    store,
+		adapters: adapters,
+		now:      time.Now,
+		log:      log.GetLogger("swap"),
+	}
+}
+
+// Initiate creates a swap with a new preimage, our lock is sent by the next Process
+func (m *Manager) Initiate(ourChain string, ourAmount *big.Int, counterparty string, theirChain string, theirAmount *big.Int) (*Swap, error) {
+	preimage := make([]byte, preimageLength)
+	if _, err := rand.Read(preimage); err != nil {
+		return nil, err
+	}
+
+	swap, err := m.newSwap(RoleInitiator, HashPreimage(preimage), ourChain, ourAmount, counterparty, theirChain, theirAmount)
+	if err != nil {
+		return nil, err
+	}
+
+	swap.Preimage = preimage
+	swap.OurExpiry = swap.CreateTime + int64(m.config.LockDuration/time.Second)
+	return swap, m.store.Save(swap)
+}
+
+// Participate creates a swap with the hash lock of the initiator, our lock is sent
+// after their lock is found. The swap is aborted if their lock is not found before the deadline.
+func (m *Manager) Participate(hashLock []byte, ourChain string, ourAmount *big.Int, counterparty string, theirChain string, theirAmount *big.Int, deadline time.Time) (*Swap, error) {
+	if len(hashLock) != preimageLength {
+		return nil, errInvalidHashLen
+	}
+
+	swap, err := m.newSwap(RoleParticipant, hashLock, ourChain, ourAmount, counterparty, theirChain, theirAmount)
+	if err != nil {
+		return nil, err
+	}
+
+	swap.Deadline = deadline.Unix()
+	return swap, m.store.Save(swap)
+}
+
+func (m *Manager) newSwap(role Role, hashLock []byte, ourChain string, ourAmount *big.Int, counterparty string, theirChain string, theirAmount *big.Int) (*Swap, error) {
+	if m.adapters[ourChain] == nil || m.adapters[theirChain] == nil {
+		return nil, errUnknownChain
+	}
+
+	if ourChain == theirChain {
+		return nil, errSameChain
+	}
+
+	if ourAmount == nil || ourAmount.Sign() <= 0 || theirAmount == nil || theirAmount.Sign() <= 0 {
+		return nil, errInvalidAmount
+	}
+
+	id := swapID(hashLock)
+	if m.store.Has(id) {
+		return nil, errSwapExists
+	}
+
+	return &Swap{
+		ID:           id,
+		Role:         role,
+		Status:       StatusNew,
+		HashLock:     hashLock,
+		OurChain:     ourChain,
+		OurAmount:    ourAmount,
+		Counterparty: counterparty,
+		TheirChain:   theirChain,
+		TheirAmount:  theirAmount,
+		CreateTime:   m.now().Unix(),
+	}, nil
+}
+
+// Run processes the swaps every interval until the quit channel is closed
+func (m *Manager) Run(interval time.Duration, quit <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		m.Process()
+
+		select {
+		case <-ticker.C:
+		case <-quit:
+			return
+		}
+	}
+}
+
+// Process steps all the swaps in progress, including the swaps added to the store by other processes
+func (m *Manager) Process() {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	swaps, err := m.store.All()
+	if err != nil {
+		m.log.Error("failed to load swaps, %s", err)
+		return
+	}
+
+	for _, swap := range swaps {
+		if swap.IsDone() {
+			continue
+		}
+
+		status := swap.Status
+		err := m.step(swap)
+		if err != nil {
+			m.log.Warn("failed to process %s, %s", swap, err)
+			swap.LastError = err.Error()
+		} else {
+			swap.LastError = ""
+		}
+
+		if swap.Status != status {
+			m.log.Info("swap %s status changed from %s to %s", swap.ID, status, swap.Status)
+		}
+
+		if err = m.store.Save(swap); err != nil {
+			m.log.Error("failed to save %s, %s", swap, err)
+		}
+	}
+}
+
+func (m *Manager) step(s *Swap) error {
+	if s.Role == RoleInitiator {
+		return m.stepInitiator(s)
+	}
+
+	return m.stepParticipant(s)
+}
+
+// stepInitiator locks first, redeems their lock with the preimage which reveals it
+// to the participant, or refunds after our lock expires.
+func (m *Manager) stepInitiator(s *Swap) error {
+	ours, theirs := m.adapters[s.OurChain], m.adapters[s.TheirChain]
+	now := m.now().Unix()
+
+	if s.OurLockID == "" {
+		return m.sendLock(s, ours)
+	}
+
+	// accept their lock only if the participant has enough time to redeem ours
+	if s.TheirLockID == "" && s.Status == StatusLocked && now+m.margin() < s.OurExpiry {
+		lock, err := theirs.FindLock(s.HashLock)
+		if err != nil {
+			return err
+		}
+
+		if lock != nil && m.acceptable(s, lock, theirs.Address(), now+m.margin()) {
+			s.TheirLockID, s.TheirExpiry, s.Status = lock.ID, lock.Expiry, StatusRedeeming
+		}
+	}
+
+	if s.Status == StatusRedeeming {
+		return m.redeem(s, theirs)
+	}
+
+	if now >= s.OurExpiry {
+		return m.refund(s, ours)
+	}
+
+	return nil
+}
+
+// stepParticipant waits for their lock, locks ours with an earlier expiry, learns
+// the preimage from the redemption of ours and redeems theirs, or refunds ours.
+func (m *Manager) stepParticipant(s *Swap) error {
+	ours, theirs := m.adapters[s.OurChain], m.adapters[s.TheirChain]
+	now := m.now().Unix()
+	lockSeconds := int64(m.config.LockDuration / 2 / time.Second)
+
+	if s.TheirLockID == "" {
+		lock, err := theirs.FindLock(s.HashLock)
+		if err != nil {
+			return err
+		}
+
+		// their lock must outlive ours by the margin, so that we could redeem it after ours is redeemed
+		if lock == nil || !m.acceptable(s, lock, theirs.Address(), now+lockSeconds+m.margin()) {
+			if now >= s.Deadline {
+				s.Status = StatusAborted
+			}
+
+			return nil
+		}
+
+		// the expiry of our lock is fixed once, so that the retries of the lock never
+		// leave less than the margin to redeem their lock after ours is redeemed
+		s.TheirLockID, s.TheirExpiry, s.OurExpiry = lock.ID, lock.Expiry, now+lockSeconds
+	}
+
+	if s.OurLockID == "" {
+		return m.sendLock(s, ours)
+	}
+
+	if s.Status == StatusLocked {
+		lock, err := ours.GetLock(s.OurLockID)
+		if err != nil {
+			return err
+		}
+
+		if lock != nil && lock.Redeemed && bytes.Equal(HashPreimage(lock.Preimage), s.HashLock) {
+			s.Preimage, s.Status = lock.Preimage, StatusRedeeming
+		}
+	}
+
+	if s.Status == StatusRedeeming {
+		return m.redeem(s, theirs)
+	}
+
+	if now >= s.OurExpiry {
+		return m.refund(s, ours)
+	}
+
+	return nil
+}
+
+// sendLock sends our lock that expires at OurExpiry unless it is already on chain. The swap is
+// saved before the lock is sent, so the lock is not sent again within the retry interval
+// if the process stops before the lock id is saved. The swap is aborted if the counterparty
+// would have less than the margin to redeem the lock.
+func (m *Manager) sendLock(s *Swap, ours ChainAdapter) error {
+	lock, err := ours.FindOwnLock(s.HashLock)
+	if err != nil {
+		return err
+	}
+
+	if lock != nil && lock.Receiver == s.Counterparty {
+		m.log.Info("swap %s found our lock %s", s.ID, lock.ID)
+		s.OurLockID, s.OurExpiry, s.Status, s.LastTxTime = lock.ID, lock.Expiry, StatusLocked, 0
+		return nil
+	}
+
+	now := m.now().Unix()
+	if now+m.margin() > s.OurExpiry {
+		m.log.Warn("swap %s our lock expires too early at %d", s.ID, s.OurExpiry)
+		s.Status, s.CompleteTime = StatusAborted, now
+		return nil
+	}
+
+	if s.LastTxTime > 0 && now < s.LastTxTime+int64(m.config.RetryInterval/time.Second) {
+		return nil
+	}
+
+	s.LastTxTime = now
+	if err = m.store.Save(s); err != nil {
+		return err
+	}
+
+	id, err := ours.Lock(s.HashLock, s.Counterparty, s.OurAmount, s.OurExpiry)
+	if err != nil {
+		return err
+	}
+
+	s.OurLockID, s.Status, s.LastTxTime = id, StatusLocked, 0
+	return nil
+}
+
+// acceptable checks their lock to the receiver is unspent with enough amount and the expiry is not earlier than minExpiry
+func (m *Manager) acceptable(s *Swap, lock *Lock, receiver string, minExpiry int64) bool {
+	if !bytes.Equal(lock.HashLock, s.HashLock) || lock.Redeemed || lock.Refunded {
+		return false
+	}
+
+	if lock.Receiver != receiver {
+		m.log.Warn("swap %s their lock %s is locked to %s instead of %s", s.ID, lock.ID, lock.Receiver, receiver)
+		return false
+	}
+
+	if lock.Amount == nil || lock.Amount.Cmp(s.TheirAmount) < 0 {
+		m.log.Warn("swap %s their lock %s amount %v is less than %v", s.ID, lock.ID, lock.Amount, s.TheirAmount)
+		return false
+	}
+
+	if lock.Expiry < minExpiry {
+		m.log.Warn("swap %s their lock %s expires too early at %d", s.ID, lock.ID, lock.Expiry)
+		return false
+	}
+
+	return true
+}
+
+// redeem completes the swap once their lock is redeemed, otherwise sends the redeem tx.
+// If their lock is refunded before redeemed, the swap goes back to refund our lock.
+func (m *Manager) redeem(s *Swap, theirs ChainAdapter) error {
+	lock, err := theirs.GetLock(s.TheirLockID)
+	if err != nil {
+		return err
+	}
+
+	if lock != nil && lock.Redeemed {
+		s.Status, s.CompleteTime = StatusCompleted, m.now().Unix()
+		return nil
+	}
+
+	if lock != nil && lock.Refunded {
+		m.log.Warn("swap %s their lock %s is refunded before redeemed", s.ID, s.TheirLockID)
+		s.Status, s.LastTxTime = StatusRefunding, 0
+		return nil
+	}
+
+	return m.sendTx(s, func() error { return theirs.Redeem(s.TheirLockID, s.Preimage) })
+}
+
+// refund finishes the swap once our lock is refunded, otherwise sends the refund tx
+func (m *Manager) refund(s *Swap, ours ChainAdapter) error {
+	s.Status = StatusRefunding
+	lock, err := ours.GetLock(s.OurLockID)
+	if err != nil {
+		return err
+	}
+
+	if lock == nil {
+		// our lock is never confirmed
+		s.Status, s.CompleteTime = StatusAborted, m.now().Unix()
+		return nil
+	}
+
+	if lock.Refunded {
+		s.Status, s.CompleteTime = StatusRefunded, m.now().Unix()
+		return nil
+	}
+
+	if lock.Redeemed {
+		// the preimage is revealed late, redeem theirs if any. The preimage known
+		// before means their lock is already refunded and there is nothing to redeem.
+		learned := len(s.Preimage) == 0
+		s.Preimage = lock.Preimage
+		if learned && s.TheirLockID != "" {
+			s.Status = StatusRedeeming
+			return nil
+		}
+
+		s.Status, s.CompleteTime = StatusCompleted, m.now().Unix()
+		return nil
+	}
+
+	return m.sendTx(s, func() error { return ours.Refund(s.OurLockID) })
+}
+
+// sendTx sends the redeem or refund tx, and resends it after the retry interval if not confirmed
+func (m *Manager) sendTx(s *Swap, send func() error) error {
+	now := m.now().Unix()
+	if s.LastTxTime > 0 && now < s.LastTxTime+int64(m.config.RetryInterval/time.Second) {
+		return nil
+	}
+
+	if err := send(); err != nil {
+		return err
+	}
+
+	s.LastTxTime = now
+	return nil
+}
+
+func (m *Manager) margin() int64 {
+	return int64(m.config.SafetyMargin / time.Second)
+}
+
+// Swaps returns all the swaps
+func (m *Manager) Swaps() ([]*Swap, error) {
+	return m.store.All()
+}
+
+// Get returns the swap of the id
+func (m *Manager) Get(id string) (*Swap, error) {
+	return m.store.Get(id)
+}
@@ -0,0 +1,399 @@
+/**
+*  @file
+*  @copyright defined in scdo/LICENSE
+ */
+
+package swap
+
+import (
+	"io/ioutil"
+	"math/big"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type testEnv struct {
+	now   time.Time
+	scdo  *MockChain
+	btc   *MockChain
+	alice *Manager
+	bob   *Manager
+	dirs  []string
+}
+
+func newTestEnv(t *testing.T) *testEnv {
+	env := &testEnv{now: time.Unix(1600000000, 0), scdo: NewMockChain(), btc: NewMockChain()}
+	env.scdo.Now, env.btc.Now = env.clock, env.clock
+	env.scdo.SetBalance("alice-scdo", big.NewInt(1000))
+	env.btc.SetBalance("bob-btc", big.NewInt(10))
+
+	env.alice = env.newManager(t, "alice", "")
+	env.bob = env.newManager(t, "bob", "")
+	return env
+}
+
+func (env *testEnv) clock() time.Time {
+	return env.now
+}
+
+func (env *testEnv) newManager(t *testing.T, name string, dir string) *Manager {
+	if dir == "" {
+		var err error
+		dir, err = ioutil.TempDir("", "swap")
+		assert.Equal(t, err, nil)
+		env.dirs = append(env.dirs, dir)
+	}
+
+	store, err := NewStore(dir)
+	assert.Equal(t, err, nil)
+
+	m := NewManager(DefaultConfig, store, map[string]ChainAdapter{
+		"scdo": env.scdo.Account(name + "-scdo"),
+		"btc":  env.btc.Account(name + "-btc"),
+	})
+	m.now = env.clock
+	return m
+}
+
+func (env *testEnv) dispose() {
+	for _, dir := range env.dirs {
+		os.RemoveAll(dir)
+	}
+}
+
+func (env *testEnv) status(t *testing.T, m *Manager, id string) Status {
+	swap, err := m.Get(id)
+	assert.Equal(t, err, nil)
+	return swap.Status
+}
+
+func Test_Swap_Completed(t *testing.T) {
+	env := newTestEnv(t)
+	defer env.dispose()
+
+	// alice sells 100 scdo for 1 btc
+	swap, err := env.alice.Initiate("scdo", big.NewInt(100), "bob-scdo", "btc", big.NewInt(1))
+	assert.Equal(t, err, nil)
+	assert.Equal(t, HashPreimage(swap.Preimage), []byte(swap.HashLock))
+
+	env.alice.Process()
+	assert.Equal(t, env.status(t, env.alice, swap.ID), StatusLocked)
+	assert.Equal(t, env.scdo.Balance("alice-scdo"), big.NewInt(900))
+
+	// bob learns the hash lock from alice
+	_, err = env.bob.Participate(swap.HashLock, "btc", big.NewInt(1), "alice-btc", "scdo", big.NewInt(100), env.now.Add(time.Hour))
+	assert.Equal(t, err, nil)
+
+	env.bob.Process()
+	assert.Equal(t, env.status(t, env.bob, swap.ID), StatusLocked)
+	assert.Equal(t, env.btc.Balance("bob-btc"), big.NewInt(9))
+
+	// alice restarts and redeems the btc with the preimage
+	env.alice = env.newManager(t, "alice", env.alice.store.dir)
+	env.now = env.now.Add(time.Minute)
+	env.alice.Process()
+	assert.Equal(t, env.status(t, env.alice, swap.ID), StatusRedeeming)
+	assert.Equal(t, env.btc.Balance("alice-btc"), big.NewInt(1))
+
+	env.alice.Process()
+	assert.Equal(t, env.status(t, env.alice, swap.ID), StatusCompleted)
+
+	// bob learns the preimage from the btc chain and redeems the scdo
+	env.bob.Process()
+	assert.Equal(t, env.status(t, env.bob, swap.ID), StatusRedeeming)
+	assert.Equal(t, env.scdo.Balance("bob-scdo"), big.NewInt(100))
+
+	env.bob.Process()
+	assert.Equal(t, env.status(t, env.bob, swap.ID), StatusCompleted)
+
+	bobSwap, err := env.bob.Get(swap.ID)
+	assert.Equal(t, err, nil)
+	assert.Equal(t, bobSwap.Preimage, swap.Preimage)
+}
+
+func Test_Swap_InitiatorRefund(t *testing.T) {
+	env := newTestEnv(t)
+	defer env.dispose()
+
+	swap, err := env.alice.Initiate("scdo", big.NewInt(100), "bob-scdo", "btc", big.NewInt(1))
+	assert.Equal(t, err, nil)
+
+	env.alice.Process()
+	assert.Equal(t, env.scdo.Balance("alice-scdo"), big.NewInt(900))
+
+	// bob never locks
+	env.now = env.now.Add(DefaultConfig.LockDuration)
+	env.alice.Process()
+	assert.Equal(t, env.status(t, env.alice, swap.ID), StatusRefunding)
+
+	env.alice.Process()
+	assert.Equal(t, env.status(t, env.alice, swap.ID), StatusRefunded)
+	assert.Equal(t, env.scdo.Balance("alice-scdo"), big.NewInt(1000))
+}
+
+func Test_Swap_ParticipantRefund(t *testing.T) {
+	env := newTestEnv(t)
+	defer env.dispose()
+
+	swap, err := env.alice.Initiate("scdo", big.NewInt(100), "bob-scdo", "btc", big.NewInt(1))
+	assert.Equal(t, err, nil)
+	env.alice.Process()
+
+	_, err = env.bob.Participate(swap.HashLock, "btc", big.NewInt(1), "alice-btc", "scdo", big.NewInt(100), env.now.Add(time.Hour))
+	assert.Equal(t, err, nil)
+	env.bob.Process()
+	assert.Equal(t, env.btc.Balance("bob-btc"), big.NewInt(9))
+
+	// alice never redeems, bob refunds after his lock expires
+	env.now = env.now.Add(DefaultConfig.LockDuration / 2)
+	env.bob.Process()
+	env.bob.Process()
+	assert.Equal(t, env.status(t, env.bob, swap.ID), StatusRefunded)
+	assert.Equal(t, env.btc.Balance("bob-btc"), big.NewInt(10))
+
+	// alice could not redeem with less than the safety margin left, and refunds later
+	env.now = env.now.Add(DefaultConfig.LockDuration / 2)
+	env.alice.Process()
+	env.alice.Process()
+	assert.Equal(t, env.status(t, env.alice, swap.ID), StatusRefunded)
+	assert.Equal(t, env.scdo.Balance("alice-scdo"), big.NewInt(1000))
+}
+
+// failRedeemAdapter fails to redeem, e.g. the redeem tx is never confirmed
+type failRedeemAdapter struct {
+	ChainAdapter
+}
+
+func (a *failRedeemAdapter) Redeem(id string, preimage []byte) error {
+	return errMockLockNotFound
+}
+
+func Test_Swap_InitiatorRefundAfterTheirRefund(t *testing.T) {
+	env := newTestEnv(t)
+	defer env.dispose()
+
+	swap, err := env.alice.Initiate("scdo", big.NewInt(100), "bob-scdo", "btc", big.NewInt(1))
+	assert.Equal(t, err, nil)
+	env.alice.Process()
+
+	_, err = env.bob.Participate(swap.HashLock, "btc", big.NewInt(1), "alice-btc", "scdo", big.NewInt(100), env.now.Add(time.Hour))
+	assert.Equal(t, err, nil)
+	env.bob.Process()
+
+	// the redeem of alice fails until bob refunds his lock
+	env.alice.adapters["btc"] = &failRedeemAdapter{env.alice.adapters["btc"]}
+	env.alice.Process()
+	assert.Equal(t, env.status(t, env.alice, swap.ID), StatusRedeeming)
+
+	env.now = env.now.Add(DefaultConfig.LockDuration / 2)
+	env.bob.Process()
+	env.bob.Process()
+	assert.Equal(t, env.status(t, env.bob, swap.ID), StatusRefunded)
+
+	// alice goes back to refund her lock after it expires
+	env.alice.Process()
+	assert.Equal(t, env.status(t, env.alice, swap.ID), StatusRefunding)
+
+	env.now = env.now.Add(DefaultConfig.LockDuration / 2)
+	env.alice.Process()
+	env.alice.Process()
+	assert.Equal(t, env.status(t, env.alice, swap.ID), StatusRefunded)
+	assert.Equal(t, env.scdo.Balance("alice-scdo"), big.NewInt(1000))
+}
+
+func Test_Swap_ParticipantLockExpiry(t *testing.T) {
+	env := newTestEnv(t)
+	defer env.dispose()
+
+	swap, err := env.alice.Initiate("scdo", big.NewInt(100), "bob-scdo", "btc", big.NewInt(1))
+	assert.Equal(t, err, nil)
+	env.alice.Process()
+
+	// the lock of bob fails without enough funds
+	env.btc.SetBalance("bob-btc", big.NewInt(0))
+	_, err = env.bob.Participate(swap.HashLock, "btc", big.NewInt(1), "alice-btc", "scdo", big.NewInt(100), env.now.Add(time.Hour))
+	assert.Equal(t, err, nil)
+	env.bob.Process()
+	assert.Equal(t, env.status(t, env.bob, swap.ID), StatusNew)
+
+	expiry := env.now.Add(DefaultConfig.LockDuration / 2).Unix()
+	bobSwap, err := env.bob.Get(swap.ID)
+	assert.Equal(t, err, nil)
+	assert.Equal(t, bobSwap.OurExpiry, expiry)
+
+	// the expiry of the retried lock is unchanged
+	env.btc.SetBalance("bob-btc", big.NewInt(10))
+	env.now = env.now.Add(DefaultConfig.RetryInterval)
+	env.bob.Process()
+	bobSwap, err = env.bob.Get(swap.ID)
+	assert.Equal(t, err, nil)
+	assert.Equal(t, bobSwap.Status, StatusLocked)
+	assert.Equal(t, bobSwap.OurExpiry, expiry)
+
+	lock, err := env.btc.Account("bob-btc").GetLock(bobSwap.OurLockID)
+	assert.Equal(t, err, nil)
+	assert.Equal(t, lock.Expiry, expiry)
+
+	// the lock is aborted if it leaves alice less than the margin to redeem it
+	swap2, err := env.alice.Initiate("scdo", big.NewInt(100), "bob-scdo", "btc", big.NewInt(1))
+	assert.Equal(t, err, nil)
+	env.alice.Process()
+
+	env.btc.SetBalance("bob-btc", big.NewInt(0))
+	_, err = env.bob.Participate(swap2.HashLock, "btc", big.NewInt(1), "alice-btc", "scdo", big.NewInt(100), env.now.Add(time.Hour))
+	assert.Equal(t, err, nil)
+	env.bob.Process()
+	assert.Equal(t, env.status(t, env.bob, swap2.ID), StatusNew)
+
+	env.btc.SetBalance("bob-btc", big.NewInt(10))
+	env.now = env.now.Add(DefaultConfig.LockDuration/2 - DefaultConfig.SafetyMargin + time.Second)
+	env.bob.Process()
+	assert.Equal(t, env.status(t, env.bob, swap2.ID), StatusAborted)
+	assert.Equal(t, env.btc.Balance("bob-btc"), big.NewInt(10))
+}
+
+func Test_Swap_ParticipantAbort(t *testing.T) {
+	env := newTestEnv(t)
+	defer env.dispose()
+
+	hashLock := HashPreimage([]byte("unknown"))
+	_, err := env.bob.Participate(hashLock, "btc", big.NewInt(1), "alice-btc", "scdo", big.NewInt(100), env.now.Add(time.Hour))
+	assert.Equal(t, err, nil)
+
+	env.bob.Process()
+	assert.Equal(t, env.status(t, env.bob, swapID(hashLock)), StatusNew)
+
+	env.now = env.now.Add(time.Hour)
+	env.bob.Process()
+	assert.Equal(t, env.status(t, env.bob, swapID(hashLock)), StatusAborted)
+	assert.Equal(t, env.btc.Balance("bob-btc"), big.NewInt(10))
+}
+
+func Test_Swap_RejectTheirLock(t *testing.T) {
+	env := newTestEnv(t)
+	defer env.dispose()
+
+	swap, err := env.alice.Initiate("scdo", big.NewInt(100), "bob-scdo", "btc", big.NewInt(2))
+	assert.Equal(t, err, nil)
+	env.alice.Process()
+
+	// the amount of bob's lock is less than alice expects
+	_, err = env.bob.Participate(swap.HashLock, "btc", big.NewInt(1), "alice-btc", "scdo", big.NewInt(100), env.now.Add(time.Hour))
+	assert.Equal(t, err, nil)
+	env.bob.Process()
+
+	env.alice.Process()
+	assert.Equal(t, env.status(t, env.alice, swap.ID), StatusLocked)
+	assert.Equal(t, env.btc.Balance("alice-btc"), big.NewInt(0))
+
+	// bob rejects the lock of alice that expires before his lock
+	swap2, err := env.alice.Initiate("scdo", big.NewInt(100), "bob-scdo", "btc", big.NewInt(1))
+	assert.Equal(t, err, nil)
+	env.alice.Process()
+
+	env.now = env.now.Add(DefaultConfig.LockDuration / 2)
+	_, err = env.bob.Participate(swap2.HashLock, "btc", big.NewInt(1), "alice-btc", "scdo", big.NewInt(100), env.now.Add(time.Hour))
+	assert.Equal(t, err, nil)
+	env.bob.Process()
+	assert.Equal(t, env.status(t, env.bob, swap2.ID), StatusNew)
+}
+
+// senderLocksAdapter finds the locks sent by the sender to anyone as the locks to us
+type senderLocksAdapter struct {
+	ChainAdapter
+	sender ChainAdapter
+}
+
+func (a *senderLocksAdapter) FindLock(hashLock []byte) (*Lock, error) {
+	return a.sender.FindOwnLock(hashLock)
+}
+
+func Test_Swap_RejectOtherReceiver(t *testing.T) {
+	env := newTestEnv(t)
+	defer env.dispose()
+
+	swap, err := env.alice.Initiate("scdo", big.NewInt(100), "bob-scdo", "btc", big.NewInt(1))
+	assert.Equal(t, err, nil)
+	env.alice.Process()
+
+	// bob locks to carol instead of alice
+	_, err = env.bob.Participate(swap.HashLock, "btc", big.NewInt(1), "carol-btc", "scdo", big.NewInt(100), env.now.Add(time.Hour))
+	assert.Equal(t, err, nil)
+	env.bob.Process()
+	assert.Equal(t, env.btc.Balance("bob-btc"), big.NewInt(9))
+
+	env.alice.adapters["btc"] = &senderLocksAdapter{env.alice.adapters["btc"], env.btc.Account("bob-btc")}
+	env.alice.Process()
+	assert.Equal(t, env.status(t, env.alice, swap.ID), StatusLocked)
+}
+
+func Test_Swap_LockOnce(t *testing.T) {
+	env := newTestEnv(t)
+	defer env.dispose()
+
+	swap, err := env.alice.Initiate("scdo", big.NewInt(100), "bob-scdo", "btc", big.NewInt(1))
+	assert.Equal(t, err, nil)
+	env.alice.Process()
+	assert.Equal(t, env.scdo.Balance("alice-scdo"), big.NewInt(900))
+
+	locked, err := env.alice.Get(swap.ID)
+	assert.Equal(t, err, nil)
+
+	// alice stops after the lock is sent but before the lock id is saved
+	saved := *locked
+	saved.OurLockID, saved.Status, saved.LastTxTime = "", StatusNew, env.now.Unix()
+	assert.Equal(t, env.alice.store.Save(&saved), nil)
+
+	env.alice = env.newManager(t, "alice", env.alice.store.dir)
+	env.alice.Process()
+	restored, err := env.alice.Get(swap.ID)
+	assert.Equal(t, err, nil)
+	assert.Equal(t, restored.Status, StatusLocked)
+	assert.Equal(t, restored.OurLockID, locked.OurLockID)
+	assert.Equal(t, env.scdo.Balance("alice-scdo"), big.NewInt(900))
+
+	// the lock tx is not confirmed yet, which is resent after the retry interval
+	swap2, err := env.alice.Initiate("scdo", big.NewInt(100), "bob-scdo", "btc", big.NewInt(1))
+	assert.Equal(t, err, nil)
+	swap2.LastTxTime = env.now.Unix()
+	assert.Equal(t, env.alice.store.Save(swap2), nil)
+
+	env.alice.Process()
+	assert.Equal(t, env.status(t, env.alice, swap2.ID), StatusNew)
+	assert.Equal(t, env.scdo.Balance("alice-scdo"), big.NewInt(900))
+
+	env.now = env.now.Add(DefaultConfig.RetryInterval)
+	env.alice.Process()
+	assert.Equal(t, env.status(t, env.alice, swap2.ID), StatusLocked)
+	assert.Equal(t, env.scdo.Balance("alice-scdo"), big.NewInt(800))
+}
+
+func Test_Swap_Validate(t *testing.T) {
+	env := newTestEnv(t)
+	defer env.dispose()
+
+	_, err := env.alice.Initiate("eth", big.NewInt(100), "bob-eth", "btc", big.NewInt(1))
+	assert.Equal(t, err, errUnknownChain)
+
+	_, err = env.alice.Initiate("scdo", big.NewInt(100), "bob-scdo", "scdo", big.NewInt(1))
+	assert.Equal(t, err, errSameChain)
+
+	_, err = env.alice.Initiate("scdo", big.NewInt(0), "bob-scdo", "btc", big.NewInt(1))
+	assert.Equal(t, err, errInvalidAmount)
+
+	_, err = env.bob.Participate([]byte{1, 2, 3}, "btc", big.NewInt(1), "alice-btc", "scdo", big.NewInt(100), env.now)
+	assert.Equal(t, err, errInvalidHashLen)
+
+	swap, err := env.alice.Initiate("scdo", big.NewInt(100), "bob-scdo", "btc", big.NewInt(1))
+	assert.Equal(t, err, nil)
+
+	_, err = env.alice.Participate(swap.HashLock, "scdo", big.NewInt(100), "bob-scdo", "btc", big.NewInt(1), env.now)
+	assert.Equal(t, err, errSwapExists)
+
+	swaps, err := env.alice.Swaps()
+	assert.Equal(t, err, nil)
+	assert.Equal(t, len(swaps), 1)
+}
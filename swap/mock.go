@@ -0,0 +1,190 @@
+/**
+*  @file
+*  @copyright defined in scdo/LICENSE
+ */
+
+package swap
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"math/big"
+	"sync"
+	"time"
+)
+
+var (
+	errMockLockNotFound  = errors.New("lock not found")
+	errMockLockSpent     = errors.New("lock is redeemed or refunded")
+	errMockNotReceiver   = errors.New("only the receiver is allowed to redeem")
+	errMockNotSender     = errors.New("only the sender is allowed to refund")
+	errMockExpired       = errors.New("lock is expired")
+	errMockNotExpired    = errors.New("lock is not expired")
+	errMockHashMismatch  = errors.New("preimage mismatch the hash lock")
+	errMockNoEnoughFunds = errors.New("balance is not enough")
+)
+
+// MockChain is an in-memory chain with hash time locks, which is used to test
+// the swaps without a real chain.
+type MockChain struct {
+	Now func() time.Time
+
+	balances map[string]*big.Int
+	locks    map[string]*Lock
+	lock     sync.Mutex
+}
+
+// NewMockChain creates a mock chain with the wall clock
+func NewMockChain() *MockChain {
+	return &MockChain{
+		Now:      time.Now,
+		balances: make(map[string]*big.Int),
+		locks:    make(map[string]*Lock),
+	}
+}
+
+// SetBalance sets the balance of the address
+func (c *MockChain) SetBalance(addr string, amount *big.Int) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	c.balances[addr] = new(big.Int).Set(amount)
+}
+
+// Balance returns the balance of the address
+func (c *MockChain) Balance(addr string) *big.Int {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	return new(big.Int).Set(c.balance(addr))
+}
+
+func (c *MockChain) balance(addr string) *big.Int {
+	if c.balances[addr] == nil {
+		c.balances[addr] = big.NewInt(0)
+	}
+
+	return c.balances[addr]
+}
+
+// Account returns the adapter of the address on the chain
+func (c *MockChain) Account(addr string) ChainAdapter {
+	return &mockAccount{c, addr}
+}
+
+type mockAccount struct {
+	chain *MockChain
+	addr  string
+}
+
+func (a *mockAccount) Address() string {
+	return a.addr
+}
+
+func (a *mockAccount) Lock(hashLock []byte, receiver string, amount *big.Int, expiry int64) (string, error) {
+	c := a.chain
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	balance := c.balance(a.addr)
+	if balance.Cmp(amount) < 0 {
+		return "", errMockNoEnoughFunds
+	}
+
+	balance.Sub(balance, amount)
+	lock := &Lock{
+		ID:       fmt.Sprintf("lock-%d", len(c.locks)),
+		HashLock: hashLock,
+		Sender:   a.addr,
+		Receiver: receiver,
+		Amount:   new(big.Int).Set(amount),
+		Expiry:   expiry,
+	}
+	c.locks[lock.ID] = lock
+
+	return lock.ID, nil
+}
+
+func (a *mockAccount) FindLock(hashLock []byte) (*Lock, error) {
+	return a.findLock(hashLock, func(lock *Lock) bool { return lock.Receiver == a.addr })
+}
+
+func (a *mockAccount) FindOwnLock(hashLock []byte) (*Lock, error) {
+	return a.findLock(hashLock, func(lock *Lock) bool { return lock.Sender == a.addr })
+}
+
+func (a *mockAccount) findLock(hashLock []byte, match func(*Lock) bool) (*Lock, error) {
+	c := a.chain
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	for _, lock := range c.locks {
+		if match(lock) && bytes.Equal(lock.HashLock, hashLock) && !lock.Redeemed && !lock.Refunded {
+			copied := *lock
+			return &copied, nil
+		}
+	}
+
+	return nil, nil
+}
+
+func (a *mockAccount) GetLock(id string) (*Lock, error) {
+	c := a.chain
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	lock, ok := c.locks[id]
+	if !ok {
+		return nil, nil
+	}
+
+	copied := *lock
+	return &copied, nil
+}
+
+func (a *mockAccount) Redeem(id string, preimage []byte) error {
+	c := a.chain
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	lock, ok := c.locks[id]
+	switch {
+	case !ok:
+		return errMockLockNotFound
+	case lock.Redeemed || lock.Refunded:
+		return errMockLockSpent
+	case lock.Receiver != a.addr:
+		return errMockNotReceiver
+	case c.Now().Unix() >= lock.Expiry:
+		return errMockExpired
+	case !bytes.Equal(HashPreimage(preimage), lock.HashLock):
+		return errMockHashMismatch
+	}
+
+	lock.Redeemed, lock.Preimage = true, preimage
+	c.balance(a.addr).Add(c.balance(a.addr), lock.Amount)
+	return nil
+}
+
+func (a *mockAccount) Refund(id string) error {
+	c := a.chain
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	lock, ok := c.locks[id]
+	switch {
+	case !ok:
+		return errMockLockNotFound
+	case lock.Redeemed || lock.Refunded:
+		return errMockLockSpent
+	case lock.Sender != a.addr:
+		return errMockNotSender
+	case c.Now().Unix() < lock.Expiry:
+		return errMockNotExpired
+	}
+
+	lock.Refunded = true
+	c.balance(a.addr).Add(c.balance(a.addr), lock.Amount)
+	return nil
+}
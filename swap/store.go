@@ -0,0 +1,104 @@
+/**
+*  @file
+*  @copyright defined in scdo/LICENSE
+ */
+
+package swap
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+const swapFileExt = ".json"
+
+var errSwapNotFound = errors.New("swap not found")
+
+// Store persists the swaps as json files in a folder, one file per swap, so that
+// the swaps could be added by the command line while the daemon is running.
+type Store struct {
+	dir string
+}
+
+// NewStore creates the store in the folder
+func NewStore(dir string) (*Store, error) {
+	if err := os.MkdirAll(dir, os.ModePerm); err != nil {
+		return nil, err
+	}
+
+	return &Store{dir}, nil
+}
+
+// Save writes the swap to its file atomically
+func (s *Store) Save(swap *Swap) error {
+	data, err := json.MarshalIndent(swap, "", "\t")
+	if err != nil {
+		return err
+	}
+
+	tmp := s.path(swap.ID) + ".tmp"
+	if err = ioutil.WriteFile(tmp, data, 0600); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp, s.path(swap.ID))
+}
+
+// Get reads the swap of the id
+func (s *Store) Get(id string) (*Swap, error) {
+	data, err := ioutil.ReadFile(s.path(id))
+	if os.IsNotExist(err) {
+		return nil, errSwapNotFound
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	var swap Swap
+	if err = json.Unmarshal(data, &swap); err != nil {
+		return nil, err
+	}
+
+	return &swap, nil
+}
+
+// Has returns true if the swap of the id exists
+func (s *Store) Has(id string) bool {
+	_, err := os.Stat(s.path(id))
+	return err == nil
+}
+
+// All reads all the swaps ordered by the create time
+func (s *Store) All() ([]*Swap, error) {
+	files, err := ioutil.ReadDir(s.dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var swaps []*Swap
+	for _, f := range files {
+		if f.IsDir() || !strings.HasSuffix(f.Name(), swapFileExt) {
+			continue
+		}
+
+		swap, err := s.Get(strings.TrimSuffix(f.Name(), swapFileExt))
+		if err != nil {
+			return nil, err
+		}
+
+		swaps = append(swaps, swap)
+	}
+
+	sort.SliceStable(swaps, func(i, j int) bool { return swaps[i].CreateTime < swaps[j].CreateTime })
+	return swaps, nil
+}
+
+func (s *Store) path(id string) string {
+	return filepath.Join(s.dir, id+swapFileExt)
+}
@@ -0,0 +1,92 @@
+/**
+*  @file
+*  @copyright defined in scdo/LICENSE
+ */
+
+package swap
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"math/big"
+
+	"github.com/elcn233/go-scdo/common"
+	"github.com/elcn233/go-scdo/common/hexutil"
+)
+
+// Role is the role in an atomic swap
+type Role string
+
+const (
+	// RoleInitiator generates the preimage and locks first
+	RoleInitiator Role = "initiator"
+	// RoleParticipant locks after the initiator and learns the preimage from the redemption
+	RoleParticipant Role = "participant"
+)
+
+// Status is the status of an atomic swap
+type Status string
+
+const (
+	// StatusNew is the swap without any lock
+	StatusNew Status = "new"
+	// StatusLocked is the swap with our lock sent
+	StatusLocked Status = "locked"
+	// StatusRedeeming is the swap that redeems their lock with the preimage
+	StatusRedeeming Status = "redeeming"
+	// StatusRefunding is the swap that refunds our expired lock
+	StatusRefunding Status = "refunding"
+	// StatusCompleted is the swap with their lock redeemed
+	StatusCompleted Status = "completed"
+	// StatusRefunded is the swap with our lock refunded
+	StatusRefunded Status = "refunded"
+	// StatusAborted is the swap that our lock could not be sent in time, or the swap of
+	// the participant that their lock is not found before the deadline
+	StatusAborted Status = "aborted"
+)
+
+// Swap is the persisted state of an atomic swap. Our lock is on OurChain to the
+// counterparty, and their lock is on TheirChain to us.
+type Swap struct {
+	ID       string
+	Role     Role
+	Status   Status
+	HashLock common.Bytes
+	Preimage common.Bytes `json:",omitempty"`
+
+	OurChain     string
+	OurAmount    *big.Int
+	Counterparty string // receiver of our lock on our chain
+	OurLockID    string
+	OurExpiry    int64
+
+	TheirChain   string
+	TheirAmount  *big.Int // min amount of their lock
+	TheirLockID  string
+	TheirExpiry  int64
+	Deadline     int64  // the participant aborts if their lock is not found before it
+	LastTxTime   int64  // time of the last lock, redeem or refund tx, to resend after the retry interval
+	LastError    string `json:",omitempty"`
+	CreateTime   int64
+	CompleteTime int64 `json:",omitempty"`
+}
+
+// IsDone returns true if the swap is completed, refunded or aborted
+func (s *Swap) IsDone() bool {
+	return s.Status == StatusCompleted || s.Status == StatusRefunded || s.Status == StatusAborted
+}
+
+func (s *Swap) String() string {
+	return fmt.Sprintf("Swap[ID=%v, Role=%v, Status=%v, Our=%v %v, Their=%v %v]", s.ID, s.Role, s.Status, s.OurAmount, s.OurChain, s.TheirAmount, s.TheirChain)
+}
+
+// HashPreimage returns the hash lock of the preimage, sha256 which is supported by the HTLCs of btc, eth and scdo
+func HashPreimage(preimage []byte) []byte {
+	hash := sha256.Sum256(preimage)
+	return hash[:]
+}
+
+// swapID returns the swap id of the hash lock
+func swapID(hashLock []byte) string {
+	return hexutil.BytesToHex(hashLock)
+}
@@ -8,6 +8,7 @@ package trie
 import (
 	"bytes"
 	"fmt"
+	"sort"
 
 	"github.com/elcn233/go-scdo/common"
 	"github.com/elcn233/go-scdo/crypto/sha3"
@@ -53,10 +54,9 @@ func (t *Trie) GetProof(key []byte) (map[string][]byte, error) {
 				return proof, fmt.Errorf("unhandled trie error: %s", err)
 			}
 		case *LeafNode:
+			// the leaf of other key is also in the proof to show the trie doesn't contain the key.
 			tn = nil
-			if len(key) >= len(n.Key) && bytes.Equal(n.Key, key[:len(n.Key)]) {
-				nodes = append(nodes, n)
-			}
+			nodes = append(nodes, n)
 		default:
 			panic(fmt.Sprintf("%T: invalid node: %v", tn, tn))
 		}
@@ -145,3 +145,34 @@ func get(tn noder, key []byte) ([]byte, noder) {
 		}
 	}
 }
+
+// ProofNode is an encoded trie node of merkle proof, which is used to
+// transfer the proof in JSON or RLP format.
+type ProofNode struct {
+	Key   common.Bytes `json:"key"`
+	Value common.Bytes `json:"value"`
+}
+
+// ProofToNodes converts the merkle proof to nodes sorted by key.
+func ProofToNodes(proof map[string][]byte) []ProofNode {
+	nodes := make([]ProofNode, 0, len(proof))
+	for k, v := range proof {
+		nodes = append(nodes, ProofNode{common.Bytes(k), common.CopyBytes(v)})
+	}
+
+	sort.Slice(nodes, func(i, j int) bool {
+		return bytes.Compare(nodes[i].Key, nodes[j].Key) < 0
+	})
+
+	return nodes
+}
+
+// NodesToProof converts the proof nodes to merkle proof.
+func NodesToProof(nodes []ProofNode) map[string][]byte {
+	proof := make(map[string][]byte)
+	for _, n := range nodes {
+		proof[string(n.Key)] = n.Value
+	}
+
+	return proof
+}
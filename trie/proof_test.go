@@ -67,6 +67,37 @@ func TestOneElementProof(t *testing.T) {
 	}
 }
 
+func TestMissingKeyProof(t *testing.T) {
+	_, trie, dispose := newTestTrie()
+	defer dispose()
+
+	// the root is the leaf of other key
+	trie.Put([]byte("k"), []byte("v"))
+	proofs, err := trie.GetProof([]byte("x"))
+	assert.Equal(t, err, nil)
+	assert.Equal(t, len(proofs), 1)
+
+	val, err := VerifyProof(trie.Hash(), []byte("x"), proofs)
+	assert.Equal(t, err, nil)
+	assert.Equal(t, len(val), 0)
+
+	// the path ends at either an empty branch child or a leaf of other key
+	for i := byte(0); i < 100; i++ {
+		trie.Put(common.LeftPadBytes([]byte{i, 1}, 32), []byte{i})
+	}
+
+	root := trie.Hash()
+	for i := byte(0); i < 200; i++ {
+		key := common.LeftPadBytes([]byte{i, 2}, 32)
+		proofs, err := trie.GetProof(key)
+		assert.Equal(t, err, nil)
+
+		val, err := VerifyProof(root, key, proofs)
+		assert.Equal(t, err, nil)
+		assert.Equal(t, len(val), 0)
+	}
+}
+
 func TestVerifyBadProof(t *testing.T) {
 	trie, vals, dispose := randomTrie(800)
 	defer dispose()
@@ -218,3 +249,24 @@ func Test_VerifyProof_Fake(t *testing.T) {
 	assert.Nil(t, result)
 	assert.NotNil(t, err)
 }
+
+func Test_ProofNodes(t *testing.T) {
+	trie, vals, dispose := randomTrie(100)
+	defer dispose()
+
+	root := trie.Hash()
+	for _, kv := range vals {
+		proof, err := trie.GetProof(kv.k)
+		assert.Equal(t, err, nil)
+
+		nodes := ProofToNodes(proof)
+		assert.Equal(t, len(nodes), len(proof))
+		for i := 1; i < len(nodes); i++ {
+			assert.Equal(t, bytes.Compare(nodes[i-1].Key, nodes[i].Key) < 0, true)
+		}
+
+		val, err := VerifyProof(root, kv.k, NodesToProof(nodes))
+		assert.Equal(t, err, nil)
+		assert.Equal(t, val, kv.v)
+	}
+}